@@ -0,0 +1,60 @@
+package api
+
+import "sync"
+
+// EventHandler is told about every event the server publishes. It runs on
+// the goroutine of the request that made the change, so it must not block:
+// a handler with slow work to do, such as calling a webhook, hands the event
+// to a goroutine of its own.
+type EventHandler func(Event)
+
+// bus is where the server publishes its events. Streams (server-sent events
+// and WebSockets) subscribe with a channel and drop events when they fall
+// behind, rather than blocking the request that produced them. Handlers,
+// such as the change log, get every event.
+type bus struct {
+	mu       sync.Mutex
+	subs     map[chan Event]struct{}
+	handlers []EventHandler
+}
+
+func newBus() *bus {
+	return &bus{subs: make(map[chan Event]struct{})}
+}
+
+func (b *bus) subscribe() chan Event {
+	ch := make(chan Event, 16)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+	return ch
+}
+
+func (b *bus) unsubscribe(ch chan Event) {
+	b.mu.Lock()
+	delete(b.subs, ch)
+	b.mu.Unlock()
+}
+
+// handle adds h to the handlers.
+func (b *bus) handle(h EventHandler) {
+	b.mu.Lock()
+	b.handlers = append(b.handlers, h)
+	b.mu.Unlock()
+}
+
+func (b *bus) publish(ev Event) {
+	b.mu.Lock()
+	handlers := b.handlers
+	for ch := range b.subs {
+		select {
+		case ch <- ev:
+		default:
+		}
+	}
+	b.mu.Unlock()
+
+	for _, h := range handlers {
+		h(ev)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestEventHandlers(t *testing.T) {
+	home := t.TempDir()
+	live := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(live), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(live, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+	tool := profile.ClaudeTool().WithHome(home)
+
+	var mu sync.Mutex
+	var got []Event
+	server := NewServer(WithTools(tool), WithEventHandler(func(ev Event) {
+		mu.Lock()
+		got = append(got, ev)
+		mu.Unlock()
+	}))
+	do := func(method, path string) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		if w.Code >= 300 {
+			t.Fatalf("%s %s: %d %s", method, path, w.Code, w.Body.String())
+		}
+	}
+	types := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		var out []string
+		for _, ev := range got {
+			out = append(out, ev.Type)
+		}
+		return out
+	}
+
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	do("POST", "/api/v1/claude/switch/work")
+	if err := os.WriteFile(live, []byte(`{"model":"opus"}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+	// Drift is published once, however often it is looked at.
+	do("GET", "/api/v1/claude/current")
+	do("GET", "/api/v1/claude/current")
+	want := []string{EventProfileSwitched, EventDriftDetected}
+	if !slices.Equal(types(), want) {
+		t.Fatalf("events = %v, want %v", types(), want)
+	}
+	mu.Lock()
+	drift := got[1]
+	mu.Unlock()
+	if drift.Profile != "work" || !slices.Equal(drift.Files, []string{"settings.json"}) {
+		t.Fatalf("drift event = %+v", drift)
+	}
+
+	// Once the profile changes, the same drift found again is news.
+	do("POST", "/api/v1/claude/drift/settings.json/revert")
+	if err := os.WriteFile(live, []byte(`{"model":"opus"}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/claude/switch/work", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("switch over drift: expected 409, got %d", w.Code)
+	}
+	want = append(want, EventProfileSwitched, EventDriftDetected)
+	if !slices.Equal(types(), want) {
+		t.Fatalf("events = %v, want %v", types(), want)
+	}
+}
@@ -0,0 +1,213 @@
+package api
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressMinSize is the smallest body worth compressing, when the handler
+// says how large it is.
+const compressMinSize = 1024
+
+// compressMiddleware gzips or deflates responses for clients that accept
+// it. Bodies that are small, already compressed or streamed as events pass
+// through unchanged, as do WebSocket handshakes.
+func compressMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		encoding := acceptedEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// acceptedEncoding returns the encoding to compress with for an
+// Accept-Encoding header: "gzip", "deflate" or "" for none. gzip wins ties.
+func acceptedEncoding(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "*" {
+			coding = "gzip"
+		}
+		if (coding != "gzip" && coding != "deflate") || q <= 0 {
+			continue
+		}
+		if q > bestQ || (q == bestQ && coding == "gzip") {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a body of contentType gets smaller when
+// compressed.
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch mediaType {
+	case "text/event-stream":
+		// Events must reach the client as they happen.
+		return false
+	case "application/json", "application/javascript", "application/xml", "image/svg+xml", "application/manifest+json":
+		return true
+	}
+	return strings.HasPrefix(mediaType, "text/")
+}
+
+// compressWriter holds back the start of a response until it knows
+// whether the response is worth compressing, then passes it through or
+// compresses it.
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	status   int
+	mode     compressMode
+	buf      []byte
+	w        io.WriteCloser
+}
+
+type compressMode int
+
+const (
+	// modeUndecided holds back a body of unknown size until it reaches
+	// compressMinSize.
+	modeUndecided compressMode = iota
+	modePassThrough
+	modeCompress
+)
+
+func (c *compressWriter) WriteHeader(status int) {
+	if c.status != 0 {
+		return
+	}
+	c.status = status
+	h := c.Header()
+	if status < 200 || status == http.StatusNoContent || status == http.StatusNotModified || status == http.StatusPartialContent ||
+		h.Get("Content-Encoding") != "" || !compressible(h.Get("Content-Type")) {
+		c.passThrough()
+		return
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil {
+		if n < compressMinSize {
+			c.passThrough()
+		} else {
+			c.compress()
+		}
+	}
+}
+
+func (c *compressWriter) passThrough() {
+	c.mode = modePassThrough
+	c.ResponseWriter.WriteHeader(c.status)
+}
+
+func (c *compressWriter) compress() {
+	c.mode = modeCompress
+	h := c.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", c.encoding)
+	// The compressed body is not byte for byte what the ETag named.
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+	if c.encoding == "gzip" {
+		c.w = gzip.NewWriter(c.ResponseWriter)
+	} else {
+		c.w = zlib.NewWriter(c.ResponseWriter)
+	}
+	c.ResponseWriter.WriteHeader(c.status)
+}
+
+func (c *compressWriter) Write(p []byte) (int, error) {
+	if c.status == 0 {
+		if c.Header().Get("Content-Type") == "" {
+			c.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		c.WriteHeader(http.StatusOK)
+	}
+	switch c.mode {
+	case modePassThrough:
+		return c.ResponseWriter.Write(p)
+	case modeCompress:
+		return c.w.Write(p)
+	}
+	c.buf = append(c.buf, p...)
+	if len(c.buf) >= compressMinSize {
+		c.compress()
+		if err := c.release(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// release writes out the body held back so far.
+func (c *compressWriter) release() error {
+	buf := c.buf
+	c.buf = nil
+	var err error
+	if c.mode == modeCompress {
+		_, err = c.w.Write(buf)
+	} else {
+		_, err = c.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// FlushError sends what has been written so far on to the client. A body
+// still held back is sent as is: whoever flushes wants it now.
+func (c *compressWriter) FlushError() error {
+	if c.status != 0 && c.mode == modeUndecided {
+		c.passThrough()
+		if err := c.release(); err != nil {
+			return err
+		}
+	}
+	if f, ok := c.w.(interface{ Flush() error }); ok {
+		if err := f.Flush(); err != nil {
+			return err
+		}
+	}
+	return http.NewResponseController(c.ResponseWriter).Flush()
+}
+
+func (c *compressWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+// close finishes the response once the handler is done.
+func (c *compressWriter) close() {
+	switch {
+	case c.status != 0 && c.mode == modeUndecided:
+		c.passThrough()
+		_ = c.release()
+	case c.mode == modeCompress:
+		c.w.Close()
+	}
+}
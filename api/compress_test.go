@@ -0,0 +1,106 @@
+package api
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestAcceptedEncoding(t *testing.T) {
+	cases := map[string]string{
+		"":                            "",
+		"gzip":                        "gzip",
+		"deflate":                     "deflate",
+		"deflate, gzip":               "gzip",
+		"gzip;q=0.5, deflate":         "deflate",
+		"gzip;q=0":                    "",
+		"br, identity":                "",
+		"*":                           "gzip",
+		"GZIP;q=0.8, deflate;q=oops":  "gzip",
+		"deflate;q=1.0, gzip;q=0.999": "deflate",
+	}
+	for header, want := range cases {
+		if got := acceptedEncoding(header); got != want {
+			t.Errorf("acceptedEncoding(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestCompression(t *testing.T) {
+	server := NewServer(WithTools(newTestTool(t)))
+	get := func(path, acceptEncoding string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+	plain := get("/api/ui-locale?lang=ja", "")
+	if plain.Header().Get("Content-Encoding") != "" || plain.Header().Get("Vary") == "" {
+		t.Fatalf("expected an uncompressed answer that varies on Accept-Encoding, got %v", plain.Header())
+	}
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		w := get("/api/ui-locale?lang=ja", encoding)
+		if got := w.Header().Get("Content-Encoding"); got != encoding {
+			t.Fatalf("Content-Encoding = %q, want %q", got, encoding)
+		}
+		var r io.Reader
+		var err error
+		if encoding == "gzip" {
+			r, err = gzip.NewReader(w.Body)
+		} else {
+			r, err = zlib.NewReader(w.Body)
+		}
+		if err != nil {
+			t.Fatalf("%s reader: %v", encoding, err)
+		}
+		body, err := io.ReadAll(r)
+		if err != nil {
+			t.Fatalf("decompress: %v", err)
+		}
+		if string(body) != plain.Body.String() {
+			t.Fatalf("%s body differs from the plain one", encoding)
+		}
+		var locale UILocale
+		if err := json.Unmarshal(body, &locale); err != nil || locale.Language != "ja" {
+			t.Fatalf("decode: %+v, %v", locale, err)
+		}
+	}
+
+	if w := get("/healthz", "gzip"); w.Header().Get("Content-Encoding") != "" || w.Code != http.StatusOK {
+		t.Fatalf("expected a small answer to stay uncompressed, got %v", w.Header())
+	}
+}
+
+func TestCompressionWeakensETags(t *testing.T) {
+	tool := newTestTool(t)
+	files := map[string][]byte{"settings.json": []byte(`{"model":"` + strings.Repeat("a", 4096) + `"}`)}
+	if err := profile.SaveFiles(tool, "work", files, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	server := NewServer(WithTools(tool))
+	get := func(ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/api/claude/profiles/work/files", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		req.Header.Set("If-None-Match", ifNoneMatch)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+	w := get("")
+	etag := w.Header().Get("ETag")
+	if w.Header().Get("Content-Encoding") != "gzip" || len(etag) < 3 || etag[:2] != "W/" {
+		t.Fatalf("expected a gzipped answer with a weak ETag, got %v", w.Header())
+	}
+	if w := get(etag); w.Code != http.StatusNotModified {
+		t.Fatalf("expected the weak ETag to revalidate, got %d", w.Code)
+	}
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// drainer tracks the mutating requests in flight so that shutdown can wait
+// for them: a switch interrupted between its rename steps leaves the live
+// config half replaced.
+type drainer struct {
+	mu       sync.Mutex
+	draining bool
+	inflight sync.WaitGroup
+}
+
+// begin registers a mutating request. It reports false once draining has
+// started.
+func (d *drainer) begin() bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.draining {
+		return false
+	}
+	d.inflight.Add(1)
+	return true
+}
+
+func (d *drainer) end() {
+	d.inflight.Done()
+}
+
+// Drain stops the server from accepting mutating API requests, which get 503
+// from now on, and waits until those in flight have finished or ctx is done.
+// Call it before http.Server.Shutdown, which does not wait for handlers.
+func (s *Server) Drain(ctx context.Context) error {
+	s.drain.mu.Lock()
+	s.drain.draining = true
+	s.drain.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.drain.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) drainMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAPIPath(r.URL.Path) || isSafeMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if !s.drain.begin() {
+			w.Header().Set("Retry-After", "5")
+			writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+			return
+		}
+		defer s.drain.end()
+		next.ServeHTTP(w, r)
+	})
+}
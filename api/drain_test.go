@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDrainWaitsForMutations(t *testing.T) {
+	server := NewServer(WithTools(newTestTool(t)))
+	started, release := make(chan struct{}), make(chan struct{})
+	h := server.drainMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	inflight := httptest.NewRecorder()
+	handled := make(chan struct{})
+	go func() {
+		h.ServeHTTP(inflight, httptest.NewRequest("POST", "/api/claude/switch/work", nil))
+		close(handled)
+	}()
+	<-started
+
+	drained := make(chan error, 1)
+	go func() { drained <- server.Drain(context.Background()) }()
+
+	// Mutations arriving during the drain are refused; reads still work.
+	deadline := time.Now().Add(time.Second)
+	for {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("DELETE", "/api/claude/profiles/work", nil))
+		if w.Code == http.StatusServiceUnavailable {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected 503 during the drain, got %d", w.Code)
+		}
+		time.Sleep(time.Millisecond)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/claude/profiles", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected reads to work during the drain, got %d", w.Code)
+	}
+
+	select {
+	case err := <-drained:
+		t.Fatalf("Drain returned before the switch finished: %v", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-handled
+	if err := <-drained; err != nil {
+		t.Fatalf("Drain: %v", err)
+	}
+	if inflight.Code != http.StatusOK {
+		t.Fatalf("expected the in-flight switch to complete, got %d", inflight.Code)
+	}
+}
+
+func TestDrainIsBounded(t *testing.T) {
+	server := NewServer(WithTools(newTestTool(t)))
+	if !server.drain.begin() {
+		t.Fatalf("expected begin to succeed before draining")
+	}
+	defer server.drain.end()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := server.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected DeadlineExceeded, got %v", err)
+	}
+}
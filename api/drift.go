@@ -0,0 +1,97 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+func (s *Server) driftRoutes() {
+	s.mux.HandleFunc("POST /api/{tool}/drift/{file}/accept", s.handleAcceptFile)
+	s.mux.HandleFunc("POST /api/{tool}/drift/{file}/revert", s.handleRevertFile)
+}
+
+// handleAcceptFile stores the live content of one drifted file in the
+// active profile, leaving the profile's other files as they were.
+func (s *Server) handleAcceptFile(w http.ResponseWriter, r *http.Request) {
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return
+	}
+	name := r.PathValue("file")
+	active, err := s.acceptLiveFile(tool, name)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"profile": active, "file": name})
+}
+
+// handleRevertFile puts back the active profile's copy of one drifted file,
+// discarding the live changes to it.
+func (s *Server) handleRevertFile(w http.ResponseWriter, r *http.Request) {
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return
+	}
+	name := r.PathValue("file")
+	active, err := s.revertLiveFile(tool, name)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"profile": active, "file": name})
+}
+
+// driftNotices remembers the drift each tool was last found with, so that
+// drift.detected is published once when the live config changes rather than
+// on every check.
+type driftNotices struct {
+	mu   sync.Mutex
+	seen map[string]string
+}
+
+// notice records key as the drift of tool, "" for none, and reports whether
+// it is new drift.
+func (d *driftNotices) notice(tool, key string) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.seen == nil {
+		d.seen = make(map[string]string)
+	}
+	if d.seen[tool] == key {
+		return false
+	}
+	d.seen[tool] = key
+	return key != ""
+}
+
+// forget is an EventHandler: once a profile of the tool changes, drift
+// found again is new drift.
+func (d *driftNotices) forget(ev Event) {
+	if !strings.HasPrefix(ev.Type, "profile.") {
+		return
+	}
+	d.mu.Lock()
+	delete(d.seen, ev.Tool)
+	d.mu.Unlock()
+}
+
+// noticeDrift publishes drift.detected when drift, the live files of tool
+// that differ from its active profile active, was not seen before.
+func (s *Server) noticeDrift(tool, active string, drift []profile.FileDiff) {
+	files := make([]string, len(drift))
+	for i, d := range drift {
+		files[i] = d.Name
+	}
+	key := ""
+	if len(files) > 0 {
+		key = active + "\x00" + strings.Join(files, "\x00")
+	}
+	if s.drift.notice(tool, key) {
+		s.events.publish(Event{Type: EventDriftDetected, Tool: tool, Profile: active, Time: time.Now().UTC(), Files: files})
+	}
+}
@@ -0,0 +1,61 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestResolveDriftPerFile(t *testing.T) {
+	home := t.TempDir()
+	tool := profile.CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	files := map[string][]byte{"auth.json": []byte(`{"OPENAI_API_KEY":"sk-a"}`), "config.toml": []byte("model = \"o3\"\n")}
+	if err := profile.SaveFiles(tool, "work", files, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	server := NewServer(WithTools(tool))
+	post := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("POST", path, nil))
+		return w
+	}
+
+	if w := post("/api/codex/drift/config.toml/accept"); w.Code != http.StatusNotFound {
+		t.Fatalf("accept without an active profile: expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "config.toml"), []byte("model = \"o4\"\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(`{"OPENAI_API_KEY":"sk-b"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+
+	if w := post("/api/codex/drift/config.toml/accept"); w.Code != http.StatusOK {
+		t.Fatalf("accept: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := post("/api/codex/drift/auth.json/revert"); w.Code != http.StatusOK {
+		t.Fatalf("revert: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	stored, err := profile.ReadFiles(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if string(stored["config.toml"]) != "model = \"o4\"\n" {
+		t.Fatalf("config.toml not accepted: %q", stored["config.toml"])
+	}
+	live, err := os.ReadFile(filepath.Join(codexDir, "auth.json"))
+	if err != nil || string(live) != `{"OPENAI_API_KEY":"sk-a"}` {
+		t.Fatalf("auth.json not reverted: %q, %v", live, err)
+	}
+	if w := post("/api/codex/drift/settings.json/revert"); w.Code != http.StatusNotFound {
+		t.Fatalf("unknown file: expected 404, got %d: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"tokyo/pkg/profile"
+)
+
+// Error codes are the stable, machine-readable "code" of an error answer,
+// next to its English "error" message. Errors without a code of their own
+// are given one derived from the status, e.g. "not_found".
+const (
+	CodeInvalidName        = "invalid_name"
+	CodeProfileReadOnly    = "profile_read_only"
+	CodeFileTooLarge       = "file_too_large"
+	CodeProfileNotFound    = "profile_not_found"
+	CodeConfigFileNotFound = "config_file_not_found"
+	CodeNoActiveProfile    = "no_active_profile"
+	CodeProfileMissingFile = "profile_missing_file"
+	CodeProfileExists      = "profile_exists"
+	CodeProfileLocked      = "profile_locked"
+	CodeProfileInUse       = "profile_in_use"
+	CodeConfigChanged      = "config_changed"
+	CodeProfileBusy        = "profile_busy"
+	CodeProfileProtected   = "profile_protected"
+	CodeAlreadyManaged     = "already_managed"
+	CodeSymlinkRejected    = "symlink_rejected"
+	CodeNotRegularFile     = "not_regular_file"
+	CodeInvalidBundle      = "invalid_bundle"
+
+	CodeUnknownTool        = "unknown_tool"
+	CodeToolNotAllowed     = "tool_not_allowed"
+	CodeReadOnly           = "read_only"
+	CodeUnsavedChanges     = "unsaved_changes"
+	CodeInvalidFile        = "invalid_file"
+	CodeUnsupportedVersion = "unsupported_version"
+	CodeTooManyShares      = "too_many_shares"
+)
+
+// profileErrors maps the sentinel errors of the profile package, and of
+// the server's own checks, to the status and code they are answered with, so that every route reports the
+// same failure the same way. The first match wins.
+var profileErrors = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{profile.ErrInvalidProfileName, http.StatusBadRequest, CodeInvalidName},
+	{profile.ErrInvalidBundle, http.StatusBadRequest, CodeInvalidBundle},
+	{profile.ErrProfileReadOnly, http.StatusForbidden, CodeProfileReadOnly},
+	{profile.ErrFileTooLarge, http.StatusRequestEntityTooLarge, CodeFileTooLarge},
+	{profile.ErrProfileNotFound, http.StatusNotFound, CodeProfileNotFound},
+	{profile.ErrConfigFileNotFound, http.StatusNotFound, CodeConfigFileNotFound},
+	{profile.ErrNoActiveProfile, http.StatusNotFound, CodeNoActiveProfile},
+	{profile.ErrProfileMissingFile, http.StatusNotFound, CodeProfileMissingFile},
+	{profile.ErrProfileAlreadyExists, http.StatusConflict, CodeProfileExists},
+	{profile.ErrProfileLocked, http.StatusConflict, CodeProfileLocked},
+	{profile.ErrProfileInUse, http.StatusConflict, CodeProfileInUse},
+	{profile.ErrConfigChanged, http.StatusConflict, CodeConfigChanged},
+	{profile.ErrProfileBusy, http.StatusConflict, CodeProfileBusy},
+	{profile.ErrProfileProtected, http.StatusConflict, CodeProfileProtected},
+	{profile.ErrAlreadyManaged, http.StatusConflict, CodeAlreadyManaged},
+	{errUnsavedChanges, http.StatusConflict, CodeUnsavedChanges},
+	{errInvalidFile, http.StatusUnprocessableEntity, CodeInvalidFile},
+	// The files on the server are not as tokyo expects: the request itself
+	// is fine.
+	{profile.ErrSymlinkNotAllowed, http.StatusInternalServerError, CodeSymlinkRejected},
+	{profile.ErrExpectedFileIsDir, http.StatusInternalServerError, CodeNotRegularFile},
+	{profile.ErrExpectedRegularFile, http.StatusInternalServerError, CodeNotRegularFile},
+}
+
+// errorStatus maps an error from the profile package to the status it is
+// answered with.
+func errorStatus(err error) int {
+	for _, e := range profileErrors {
+		if errors.Is(err, e.err) {
+			return e.status
+		}
+	}
+	return http.StatusInternalServerError
+}
+
+// errorCode maps an error from the profile package to its code.
+func errorCode(err error) string {
+	for _, e := range profileErrors {
+		if errors.Is(err, e.err) {
+			return e.code
+		}
+	}
+	return statusCode(http.StatusInternalServerError)
+}
+
+// statusCode is the code of an error answer with status that has no code of
+// its own: the status text in snake case, e.g. "not_found".
+func statusCode(status int) string {
+	return strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+}
+
+// writeProfileError answers a request that failed with err.
+func writeProfileError(w http.ResponseWriter, err error) {
+	writeErrorCode(w, errorStatus(err), errorCode(err), err.Error())
+}
+
+// validProfileName checks a profile name taken from a request with the same
+// rules the CLI applies, answering the request itself when it is invalid.
+func validProfileName(w http.ResponseWriter, name string) bool {
+	if err := profile.ValidateProfileName(name); err != nil {
+		writeProfileError(w, err)
+		return false
+	}
+	return true
+}
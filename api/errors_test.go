@@ -0,0 +1,158 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+// invalidProfileNames are names the CLI refuses; every API entry point must
+// refuse them the same way.
+var invalidProfileNames = []string{
+	"../etc",
+	"a/b",
+	".hidden",
+	"a b",
+	"<custom>",
+	"work (modified)",
+	"nul",
+	"COM1",
+	strings.Repeat("a", 65),
+}
+
+func TestInvalidProfileNamesRejectedEverywhere(t *testing.T) {
+	tool := profile.ClaudeTool().WithHome(t.TempDir())
+	server := NewServer(WithTools(tool), WithAuthToken("secret"), WithRegistry(t.TempDir()))
+
+	jsonBody := func(fields map[string]string) []byte {
+		data, err := json.Marshal(fields)
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		return data
+	}
+	entryPoints := []struct {
+		name    string
+		request func(name string) *http.Request
+	}{
+		{"switch path", func(name string) *http.Request {
+			return httptest.NewRequest("POST", "/api/claude/switch/"+url.PathEscape(name), nil)
+		}},
+		{"delete path", func(name string) *http.Request {
+			return httptest.NewRequest("DELETE", "/api/claude/profiles/"+url.PathEscape(name), nil)
+		}},
+		{"registry path", func(name string) *http.Request {
+			return httptest.NewRequest("GET", "/api/claude/registry/"+url.PathEscape(name), nil)
+		}},
+		{"diff query", func(name string) *http.Request {
+			return httptest.NewRequest("GET", "/api/claude/diff?profile="+url.QueryEscape(name), nil)
+		}},
+		{"save body", func(name string) *http.Request {
+			return httptest.NewRequest("POST", "/api/claude/profiles", bytes.NewReader(jsonBody(map[string]string{"profile": name})))
+		}},
+		{"save from body", func(name string) *http.Request {
+			return httptest.NewRequest("POST", "/api/claude/profiles", bytes.NewReader(jsonBody(map[string]string{"profile": "work", "from": name})))
+		}},
+		{"adopt body", func(name string) *http.Request {
+			return httptest.NewRequest("POST", "/api/claude/adopt", bytes.NewReader(jsonBody(map[string]string{"profile": name})))
+		}},
+	}
+
+	for _, ep := range entryPoints {
+		for _, name := range invalidProfileNames {
+			t.Run(fmt.Sprintf("%s/%q", ep.name, name), func(t *testing.T) {
+				req := ep.request(name)
+				req.Header.Set("Authorization", "Bearer secret")
+				w := httptest.NewRecorder()
+				server.ServeHTTP(w, req)
+				if w.Code != http.StatusBadRequest {
+					t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
+				}
+				var resp struct{ Error string }
+				if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+					t.Fatalf("unmarshal: %v", err)
+				}
+				if want := profile.ValidateProfileName(name).Error(); resp.Error != want {
+					t.Fatalf("error = %q, want the CLI's %q", resp.Error, want)
+				}
+			})
+		}
+	}
+}
+
+func TestErrorStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{profile.ValidateProfileName(".hidden"), http.StatusBadRequest},
+		{fmt.Errorf("switch: %w", profile.ErrProfileNotFound), http.StatusNotFound},
+		{profile.ErrConfigFileNotFound, http.StatusNotFound},
+		{profile.ErrProfileMissingFile, http.StatusNotFound},
+		{profile.ErrProfileAlreadyExists, http.StatusConflict},
+		{profile.ErrProfileLocked, http.StatusConflict},
+		{profile.ErrProfileInUse, http.StatusConflict},
+		{fmt.Errorf("settings.json: %w", profile.ErrFileTooLarge), http.StatusRequestEntityTooLarge},
+		{fmt.Errorf("disk on fire"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := errorStatus(tt.err); got != tt.want {
+			t.Errorf("errorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestErrorCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want string
+	}{
+		{profile.ValidateProfileName(".hidden"), CodeInvalidName},
+		{fmt.Errorf("switch: %w", profile.ErrProfileNotFound), CodeProfileNotFound},
+		{profile.ErrProfileAlreadyExists, CodeProfileExists},
+		{fmt.Errorf("%w: /home/me/.claude/settings.json", profile.ErrSymlinkNotAllowed), CodeSymlinkRejected},
+		{fmt.Errorf("disk on fire"), "internal_server_error"},
+	}
+	for _, tt := range tests {
+		if got := errorCode(tt.err); got != tt.want {
+			t.Errorf("errorCode(%v) = %q, want %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestErrorAnswersCarryCodes(t *testing.T) {
+	tool := newTestTool(t)
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	server := NewServer(WithTools(tool))
+
+	tests := []struct {
+		method, path, body string
+		status             int
+		code               string
+	}{
+		{"POST", "/api/v1/claude/profiles", `{"profile":"work"}`, http.StatusConflict, CodeProfileExists},
+		{"POST", "/api/v1/claude/switch/nope", "", http.StatusNotFound, CodeProfileNotFound},
+		{"GET", "/api/v1/gemini/profiles", "", http.StatusNotFound, CodeUnknownTool},
+		{"POST", "/api/v1/claude/profiles", `{`, http.StatusBadRequest, "bad_request"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body)))
+		var resp struct{ Error, Code string }
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s %s: unmarshal: %v", tt.method, tt.path, err)
+		}
+		if w.Code != tt.status || resp.Code != tt.code || resp.Error == "" {
+			t.Errorf("%s %s: got %d %+v, want %d with code %q", tt.method, tt.path, w.Code, resp, tt.status, tt.code)
+		}
+	}
+}
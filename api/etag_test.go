@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestETagsOnReads(t *testing.T) {
+	home := t.TempDir()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"a"}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	tool := profile.ClaudeTool().WithHome(home)
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	server := NewServer(WithTools(tool))
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	for _, path := range []string{"/api/claude/profiles", "/api/claude/current", "/api/claude/profiles/work/files", "/api/claude/diff?profile=work"} {
+		t.Run(path, func(t *testing.T) {
+			first := get(path, "")
+			etag := first.Header().Get("ETag")
+			if first.Code != http.StatusOK || etag == "" {
+				t.Fatalf("expected 200 with an ETag, got %d %q", first.Code, etag)
+			}
+			if w := get(path, etag); w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+				t.Fatalf("expected an empty 304, got %d %q", w.Code, w.Body.String())
+			}
+			if w := get(path, `"other", W/`+etag); w.Code != http.StatusNotModified {
+				t.Fatalf("expected a weak match in a list to count, got %d", w.Code)
+			}
+			if w := get(path, `"other"`); w.Code != http.StatusOK {
+				t.Fatalf("expected 200 for another ETag, got %d", w.Code)
+			}
+		})
+	}
+
+	etag := get("/api/claude/profiles", "").Header().Get("ETag")
+	if err := profile.Save(tool, "home", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if w := get("/api/claude/profiles", etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Fatalf("expected a new list with a new ETag after saving, got %d", w.Code)
+	}
+}
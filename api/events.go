@@ -0,0 +1,123 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+// Event types published on /api/events.
+const (
+	EventProfileSaved    = "profile.saved"
+	EventProfileSwitched = "profile.switched"
+	EventProfileDeleted  = "profile.deleted"
+	// EventDriftDetected is published when the server first finds the live
+	// config of a tool changed from its active profile; see Event.Files.
+	EventDriftDetected = "drift.detected"
+	// EventOperationProgress and EventOperationFinished carry an Operation
+	// started with "Prefer: respond-async".
+	EventOperationProgress = "operation.progress"
+	EventOperationFinished = "operation.finished"
+)
+
+const eventKeepAlive = 15 * time.Second
+
+// Event describes a change made through the API, or noticed by it.
+type Event struct {
+	Type    string    `json:"type"`
+	Tool    string    `json:"tool"`
+	Profile string    `json:"profile"`
+	Time    time.Time `json:"time"`
+	// Operation is the state of the operation an operation event is about.
+	Operation *Operation `json:"operation,omitempty"`
+	// Files are the changed live files of a drift event.
+	Files []string `json:"files,omitempty"`
+	// Error is why an event reporting a failure, such as the
+	// drift.remediation_failed event tokyo watch prints, failed.
+	Error string `json:"error,omitempty"`
+}
+
+func (s *Server) publish(eventType, tool, profile string) {
+	s.events.publish(Event{Type: eventType, Tool: tool, Profile: profile, Time: time.Now().UTC()})
+}
+
+// logEvent is an EventHandler writing a line per change and drift to the
+// server's logger. Operation progress is left out: the operation's finish and
+// the change it made are logged.
+func (s *Server) logEvent(ev Event) {
+	if ev.Type == EventOperationProgress {
+		return
+	}
+	attrs := []any{"event", ev.Type, "tool", ev.Tool, "profile", ev.Profile}
+	if len(ev.Files) > 0 {
+		attrs = append(attrs, "files", ev.Files)
+	}
+	if ev.Operation != nil {
+		attrs = append(attrs, "operation", ev.Operation.ID, "state", ev.Operation.State)
+	}
+	s.logger.Info("change", attrs...)
+}
+
+// handleEvents streams events as server-sent events until the client goes
+// away. Tokens limited to some tools only see those tools' events.
+func (s *Server) handleEvents(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	// The stream outlives the server's write timeout by design.
+	_ = rc.SetWriteDeadline(time.Time{})
+
+	tok, restricted := requestToken(r)
+	ch := s.events.subscribe()
+	defer s.events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(eventKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case ev := <-ch:
+			if restricted && !tok.AllowsTool(ev.Tool) {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", ev.Type, data); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// recordIntent is an EventHandler recording the profile each switch makes
+// active as the one its tool is meant to be on.
+func (s *Server) recordIntent(ev Event) {
+	if ev.Type != EventProfileSwitched {
+		return
+	}
+	tool, ok := s.tools[ev.Tool]
+	if !ok {
+		return
+	}
+	if err := profile.RecordIntent(tool, ev.Profile); err != nil && s.logger != nil {
+		s.logger.Warn("record intended profile", "tool", ev.Tool, "profile", ev.Profile, "error", err)
+	}
+}
@@ -0,0 +1,103 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+
+	"tokyo/pkg/profile"
+)
+
+// ProfileFile is the stored content of one config file of a profile.
+type ProfileFile struct {
+	Name    string `json:"name"`
+	Content string `json:"content"`
+}
+
+// FileProblem is one reason a file written through the API was refused.
+type FileProblem struct {
+	File string `json:"file"`
+	// Pointer is the JSON pointer of the offending value; "" is the whole
+	// file.
+	Pointer string `json:"pointer,omitempty"`
+	Message string `json:"message"`
+}
+
+func (s *Server) fileRoutes() {
+	s.mux.HandleFunc("GET /api/{tool}/profiles/{profile}/files", s.handleFiles)
+	s.mux.HandleFunc("PUT /api/{tool}/profiles/{profile}/files/{file}", s.handleWriteFile)
+}
+
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return
+	}
+	profileName := r.PathValue("profile")
+	if !validProfileName(w, profileName) {
+		return
+	}
+
+	stored, err := profile.ReadFiles(tool, profileName)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	files := make([]ProfileFile, 0, len(stored))
+	for name, data := range stored {
+		files = append(files, ProfileFile{Name: name, Content: string(data)})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+	writeJSONCached(w, r, map[string]any{"profile": profileName, "files": files})
+}
+
+// handleWriteFile replaces one stored file of a profile with the request
+// body, for quick fixes from the UI. Content that does not parse, or breaks
+// the file's schema, is refused with 422 and the problems found.
+func (s *Server) handleWriteFile(w http.ResponseWriter, r *http.Request) {
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return
+	}
+	profileName := r.PathValue("profile")
+	if !validProfileName(w, profileName) {
+		return
+	}
+	name := r.PathValue("file")
+
+	limit, limited := tool.FileSizeLimit()
+	body := r.Body
+	if limited {
+		body = http.MaxBytesReader(w, r.Body, limit)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeErrorCode(w, http.StatusRequestEntityTooLarge, CodeFileTooLarge, name+" is over the "+profile.FormatSize(limit)+" limit")
+			return
+		}
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := s.writeProfileFile(tool, profileName, name, data); err != nil {
+		var invalid *invalidFileError
+		if !errors.As(err, &invalid) {
+			writeProfileError(w, err)
+			return
+		}
+		problems := make([]FileProblem, len(invalid.Problems))
+		for i, p := range invalid.Problems {
+			problems[i] = FileProblem{File: p.File, Pointer: p.Pointer, Message: p.Message}
+		}
+		writeJSON(w, http.StatusUnprocessableEntity, map[string]any{
+			"error":    invalid.Error(),
+			"code":     CodeInvalidFile,
+			"problems": problems,
+		})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"profile": profileName, "file": name})
+}
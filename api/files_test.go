@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestEditProfileFiles(t *testing.T) {
+	tool := profile.CodexTool().WithHome(t.TempDir())
+	files := map[string][]byte{"auth.json": []byte(`{"OPENAI_API_KEY":"sk-a"}`), "config.toml": []byte("model = \"o3\"\n")}
+	if err := profile.SaveFiles(tool, "work", files, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	server := NewServer(WithTools(tool))
+	do := func(method, path, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("GET", "/api/codex/profiles/work/files", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("files: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var listed struct{ Files []ProfileFile }
+	if err := json.Unmarshal(w.Body.Bytes(), &listed); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(listed.Files) != 2 || listed.Files[0].Name != "auth.json" || listed.Files[1].Content != "model = \"o3\"\n" {
+		t.Fatalf("unexpected files %+v", listed.Files)
+	}
+
+	if w := do("PUT", "/api/codex/profiles/work/files/config.toml", "model = \"o4\"\n"); w.Code != http.StatusOK {
+		t.Fatalf("write: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	stored, err := profile.ReadFiles(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if string(stored["config.toml"]) != "model = \"o4\"\n" {
+		t.Fatalf("config.toml = %q", stored["config.toml"])
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		body    string
+		status  int
+		problem string
+	}{
+		{"broken TOML", "/api/codex/profiles/work/files/config.toml", "model = ", http.StatusUnprocessableEntity, "invalid TOML"},
+		{"broken JSON", "/api/codex/profiles/work/files/auth.json", `{"OPENAI_API_KEY":`, http.StatusUnprocessableEntity, "invalid JSON"},
+		{"schema violation", "/api/codex/profiles/work/files/auth.json", `{"OPENAI_API_KEY":42}`, http.StatusUnprocessableEntity, "/OPENAI_API_KEY"},
+		{"unknown file", "/api/codex/profiles/work/files/settings.json", `{}`, http.StatusNotFound, ""},
+		{"unknown profile", "/api/codex/profiles/missing/files/config.toml", "model = \"x\"\n", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do("PUT", tt.path, tt.body)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+			if tt.problem == "" {
+				return
+			}
+			var resp struct{ Problems []FileProblem }
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Problems) == 0 {
+				t.Fatalf("expected problems, got %s", w.Body.String())
+			}
+			if p := resp.Problems[0]; !strings.Contains(p.Message+p.Pointer, tt.problem) {
+				t.Fatalf("expected a problem mentioning %q, got %+v", tt.problem, p)
+			}
+		})
+	}
+	stored, _ = profile.ReadFiles(tool, "work")
+	if string(stored["auth.json"]) != `{"OPENAI_API_KEY":"sk-a"}` {
+		t.Fatalf("refused write changed auth.json to %q", stored["auth.json"])
+	}
+}
+
+func TestWriteFileRefusedWhenReadOnly(t *testing.T) {
+	tool := profile.CodexTool().WithHome(t.TempDir())
+	if err := profile.SaveFiles(tool, "work", map[string][]byte{"config.toml": []byte("model = \"o3\"\n")}, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	server := NewServer(WithTools(tool), WithReadOnly(true))
+	req := httptest.NewRequest("PUT", "/api/codex/profiles/work/files/config.toml", strings.NewReader("model = \"o4\"\n"))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", w.Code)
+	}
+}
@@ -0,0 +1,357 @@
+package api
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"slices"
+	"strings"
+
+	"tokyo/pkg/profile"
+)
+
+// The HTML UI is a server-rendered page with plain forms for the profile
+// operations, for builds without the web UI and browsers without
+// JavaScript. Builds without the web UI serve it at /, and every build at
+// /ui/.
+
+//go:embed htmlui.html
+var htmlUISource string
+
+var htmlUIPage = template.Must(template.New("htmlui").Parse(htmlUISource))
+
+// htmlUISessionCookie holds the token a browser signed in to the HTML UI
+// with. Only the HTML UI reads it; the API still wants a bearer token.
+const htmlUISessionCookie = "tokyo_session"
+
+// maxHTMLUIForm bounds the body of a form post.
+const maxHTMLUIForm = 64 << 10
+
+type htmlUIData struct {
+	// Base prefixes the form actions: the server may run under a base path.
+	Base string
+	// SignIn asks for a token: the server requires one and the browser has
+	// not signed in.
+	SignIn   bool
+	SignedIn bool
+	ReadOnly bool
+	// WebUI is set in builds that embed the web UI.
+	WebUI   bool
+	Error   string
+	Confirm *htmlUIConfirm
+	Tools   []htmlUITool
+}
+
+// htmlUIConfirm asks to repeat a form post with Fields set.
+type htmlUIConfirm struct {
+	Message string
+	Action  string
+	Profile string
+	Button  string
+	// Fields are the form fields the repeated post sets to true.
+	Fields []string
+}
+
+// htmlUIConfirmFields returns the confirmation fields r already set, and
+// field, for the next confirmation to carry over.
+func htmlUIConfirmFields(r *http.Request, field string) []string {
+	fields := []string{field}
+	for _, f := range []string{"confirm", "confirm_production"} {
+		if f != field && r.PostFormValue(f) != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// htmlUIProtected returns the confirmation to act on protected profile name
+// when err refused it.
+func htmlUIProtected(r *http.Request, err error, action, name, message, button string) *htmlUIConfirm {
+	if !errors.Is(err, profile.ErrProfileProtected) {
+		return nil
+	}
+	return &htmlUIConfirm{
+		Message: fmt.Sprintf(message, name),
+		Action:  action,
+		Profile: name,
+		Button:  button,
+		Fields:  htmlUIConfirmFields(r, "confirm_production"),
+	}
+}
+
+type htmlUITool struct {
+	Name        string
+	DisplayName string
+	Error       string
+	Profiles    []htmlUIProfile
+}
+
+type htmlUIProfile struct {
+	Name     string
+	Active   bool
+	Modified bool
+}
+
+func (s *Server) htmlUIRoutes() {
+	csrf := http.NewCrossOriginProtection()
+	s.mux.HandleFunc("GET /ui/{$}", s.handleHTMLUI)
+	s.mux.Handle("POST /ui/login", csrf.Handler(http.HandlerFunc(s.handleHTMLUILogin)))
+	s.mux.Handle("POST /ui/logout", csrf.Handler(http.HandlerFunc(s.handleHTMLUILogout)))
+	s.mux.Handle("POST /ui/{tool}/save", csrf.Handler(s.htmlUIAction(s.htmlUISave)))
+	s.mux.Handle("POST /ui/{tool}/switch", csrf.Handler(s.htmlUIAction(s.htmlUISwitch)))
+	s.mux.Handle("POST /ui/{tool}/delete", csrf.Handler(s.htmlUIAction(s.htmlUIDelete)))
+}
+
+// htmlUIHome is where the HTML UI is served and form posts lead back to.
+func (s *Server) htmlUIHome() string {
+	if hasWebUI() {
+		return s.basePath + "/ui/"
+	}
+	return s.basePath + "/"
+}
+
+func (s *Server) handleHTMLUI(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/ui/" {
+		http.NotFound(w, r)
+		return
+	}
+	s.renderHTMLUI(w, r, http.StatusOK, htmlUIData{})
+}
+
+// htmlUISession authenticates r with the token in its session cookie. The
+// returned request carries the token for requestToken. ok is false when the
+// server requires a token and r has no valid one.
+func (s *Server) htmlUISession(r *http.Request) (*http.Request, bool, error) {
+	if !s.authRequired() {
+		return r, true, nil
+	}
+	cookie, err := r.Cookie(htmlUISessionCookie)
+	if err != nil {
+		return r, false, nil
+	}
+	return s.authenticate(r, cookie.Value)
+}
+
+// htmlUIReadOnly reports whether r may only look.
+func (s *Server) htmlUIReadOnly(r *http.Request) bool {
+	tok, ok := requestToken(r)
+	return s.readOnly || ok && tok.ReadOnly()
+}
+
+// renderHTMLUI answers r with the page, filling in the tools and profiles r
+// may see.
+func (s *Server) renderHTMLUI(w http.ResponseWriter, r *http.Request, status int, data htmlUIData) {
+	r, signedIn, err := s.htmlUISession(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	data.Base = s.basePath
+	data.WebUI = hasWebUI()
+	data.ReadOnly = s.htmlUIReadOnly(r)
+	data.SignIn = !signedIn
+	data.SignedIn = signedIn && s.authRequired()
+	if !data.SignIn {
+		tok, hasToken := requestToken(r)
+		names := make([]string, 0, len(s.tools))
+		for name := range s.tools {
+			if !hasToken || tok.AllowsTool(name) {
+				names = append(names, name)
+			}
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			data.Tools = append(data.Tools, htmlUIToolStatus(s.tools[name]))
+		}
+	}
+
+	var page bytes.Buffer
+	if err := htmlUIPage.Execute(&page, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(status)
+	w.Write(page.Bytes())
+}
+
+func htmlUIToolStatus(tool profile.Tool) htmlUITool {
+	out := htmlUITool{Name: tool.Name, DisplayName: tool.DisplayName}
+	names, err := profile.List(tool)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	current, err := profile.Current(tool)
+	if err != nil {
+		out.Error = err.Error()
+		return out
+	}
+	active, modified := strings.CutSuffix(current, " (modified)")
+	for _, name := range names {
+		p := htmlUIProfile{Name: name}
+		if name == active {
+			p.Active, p.Modified = true, modified
+		}
+		out.Profiles = append(out.Profiles, p)
+	}
+	return out
+}
+
+// htmlUIAction turns action into the handler of a form post, applying the
+// checks the middleware applies to the API: the session token, read-only
+// and draining. It leads back to the page when action succeeds, and shows
+// the page with the error or confirmation otherwise.
+func (s *Server) htmlUIAction(action func(r *http.Request, tool profile.Tool) (*htmlUIConfirm, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxHTMLUIForm)
+		r, ok, err := s.htmlUISession(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.renderHTMLUI(w, r, http.StatusUnauthorized, htmlUIData{Error: "Sign in first."})
+			return
+		}
+		toolName := r.PathValue("tool")
+		tool, ok := s.tools[toolName]
+		if !ok {
+			s.renderHTMLUI(w, r, http.StatusNotFound, htmlUIData{Error: "unknown tool"})
+			return
+		}
+		if tok, scoped := requestToken(r); scoped && !tok.AllowsTool(toolName) {
+			s.renderHTMLUI(w, r, http.StatusForbidden, htmlUIData{Error: "token may not use " + toolName})
+			return
+		}
+		if s.readOnly {
+			s.renderHTMLUI(w, r, http.StatusForbidden, htmlUIData{Error: "server is read-only"})
+			return
+		}
+		if tok, scoped := requestToken(r); scoped && tok.ReadOnly() {
+			s.renderHTMLUI(w, r, http.StatusForbidden, htmlUIData{Error: "token is read-only"})
+			return
+		}
+		if !s.drain.begin() {
+			w.Header().Set("Retry-After", "5")
+			s.renderHTMLUI(w, r, http.StatusServiceUnavailable, htmlUIData{Error: "server is shutting down"})
+			return
+		}
+		defer s.drain.end()
+
+		confirm, err := action(r, tool)
+		switch {
+		case err != nil:
+			s.renderHTMLUI(w, r, errorStatus(err), htmlUIData{Error: err.Error()})
+		case confirm != nil:
+			confirm.Action = s.basePath + "/ui/" + toolName + confirm.Action
+			s.renderHTMLUI(w, r, http.StatusOK, htmlUIData{Confirm: confirm})
+		default:
+			http.Redirect(w, r, s.htmlUIHome(), http.StatusSeeOther)
+		}
+	})
+}
+
+// htmlUIProfileName returns the validated profile name of a form post.
+func htmlUIProfileName(r *http.Request) (string, error) {
+	name := strings.TrimSpace(r.PostFormValue("profile"))
+	return name, profile.ValidateProfileName(name)
+}
+
+func (s *Server) htmlUISave(r *http.Request, tool profile.Tool) (*htmlUIConfirm, error) {
+	name, err := htmlUIProfileName(r)
+	if err != nil {
+		return nil, err
+	}
+	return nil, s.saveProfile(tool, name, "", r.PostFormValue("force") != "")
+}
+
+func (s *Server) htmlUISwitch(r *http.Request, tool profile.Tool) (*htmlUIConfirm, error) {
+	name, err := htmlUIProfileName(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.PostFormValue("confirm_production") != "" {
+		tool = tool.WithProtectedConfirmed()
+	}
+	err = s.switchProfile(tool, name, r.PostFormValue("confirm") != "")
+	var unsaved *unsavedChangesError
+	if errors.As(err, &unsaved) {
+		return &htmlUIConfirm{
+			Message: fmt.Sprintf("Discard local changes to %s (not saved in %q)?", strings.Join(unsaved.fileNames(), ", "), unsaved.Profile),
+			Action:  "/switch",
+			Profile: name,
+			Button:  "Discard and switch",
+			Fields:  htmlUIConfirmFields(r, "confirm"),
+		}, nil
+	}
+	if confirm := htmlUIProtected(r, err, "/switch", name, "Profile %q is protected. Switch to it anyway?", "Switch"); confirm != nil {
+		return confirm, nil
+	}
+	return nil, err
+}
+
+func (s *Server) htmlUIDelete(r *http.Request, tool profile.Tool) (*htmlUIConfirm, error) {
+	name, err := htmlUIProfileName(r)
+	if err != nil {
+		return nil, err
+	}
+	if r.PostFormValue("confirm") == "" {
+		return &htmlUIConfirm{
+			Message: fmt.Sprintf("Delete profile %q?", name),
+			Action:  "/delete",
+			Profile: name,
+			Button:  "Delete",
+			Fields:  []string{"confirm"},
+		}, nil
+	}
+	if r.PostFormValue("confirm_production") != "" {
+		tool = tool.WithProtectedConfirmed()
+	}
+	_, err = s.deleteProfile(tool, name)
+	if confirm := htmlUIProtected(r, err, "/delete", name, "Profile %q is protected. Delete it anyway?", "Delete"); confirm != nil {
+		return confirm, nil
+	}
+	return nil, err
+}
+
+func (s *Server) handleHTMLUILogin(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxHTMLUIForm)
+	secret := r.PostFormValue("token")
+	if s.authRequired() {
+		_, ok, err := s.authenticate(r, secret)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if !ok {
+			s.renderHTMLUI(w, r, http.StatusUnauthorized, htmlUIData{Error: "Invalid token."})
+			return
+		}
+		s.setHTMLUISession(w, r, secret, 0)
+	}
+	http.Redirect(w, r, s.htmlUIHome(), http.StatusSeeOther)
+}
+
+func (s *Server) handleHTMLUILogout(w http.ResponseWriter, r *http.Request) {
+	s.setHTMLUISession(w, r, "", -1)
+	http.Redirect(w, r, s.htmlUIHome(), http.StatusSeeOther)
+}
+
+// setHTMLUISession stores secret in the session cookie, or with a negative
+// maxAge removes the cookie.
+func (s *Server) setHTMLUISession(w http.ResponseWriter, r *http.Request, secret string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     htmlUISessionCookie,
+		Value:    secret,
+		Path:     s.basePath + "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
@@ -0,0 +1,217 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+// postForm sends a same-origin form post to server.
+func postForm(server *Server, path string, form url.Values, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", path, strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Sec-Fetch-Site", "same-origin")
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return w
+}
+
+func getPage(server *Server, path string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", path, nil)
+	for _, c := range cookies {
+		req.AddCookie(c)
+	}
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	return w
+}
+
+func TestHTMLUIPage(t *testing.T) {
+	tool := newTestTool(t)
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	w := getPage(NewServer(WithTools(tool)), "/ui/")
+	body := w.Body.String()
+	if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Fatalf("expected an HTML page, got %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(body, tool.DisplayName) || !strings.Contains(body, `class="active">work (active)`) {
+		t.Fatalf("expected the active profile to be listed:\n%s", body)
+	}
+	if !strings.Contains(body, `action="/ui/claude/save"`) {
+		t.Fatalf("expected a save form:\n%s", body)
+	}
+
+	w = getPage(NewServer(WithTools(tool), WithBasePath("/tokyo")), "/tokyo/ui/")
+	if !strings.Contains(w.Body.String(), `action="/tokyo/ui/claude/delete"`) {
+		t.Fatalf("expected form actions under the base path:\n%s", w.Body.String())
+	}
+
+	if w := getPage(NewServer(WithTools(tool), WithReadOnly(true)), "/ui/"); strings.Contains(w.Body.String(), "<form") {
+		t.Fatalf("expected no forms on a read-only server:\n%s", w.Body.String())
+	}
+	if w := getPage(NewServer(WithTools(tool)), "/ui/missing.js"); w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for other paths, got %d", w.Code)
+	}
+}
+
+func TestHTMLUIActions(t *testing.T) {
+	home := t.TempDir()
+	live := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(live), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(live, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+	tool := profile.ClaudeTool().WithHome(home)
+	server := NewServer(WithTools(tool))
+
+	w := postForm(server, "/ui/claude/save", url.Values{"profile": {"work"}})
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != server.htmlUIHome() {
+		t.Fatalf("save: expected a redirect to the page, got %d %q: %s", w.Code, w.Header().Get("Location"), w.Body.String())
+	}
+	if w := postForm(server, "/ui/claude/save", url.Values{"profile": {"work"}}); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "already exists") {
+		t.Fatalf("save over an existing profile: expected 409 with the error, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := postForm(server, "/ui/claude/save", url.Values{"profile": {"../x"}}); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid name: expected 400, got %d", w.Code)
+	}
+	if err := profile.Save(tool, "home", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if w := postForm(server, "/ui/claude/switch", url.Values{"profile": {"work"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("switch: expected 303, got %d: %s", w.Code, w.Body.String())
+	}
+	if err := os.WriteFile(live, []byte(`{"model":"opus"}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+	w = postForm(server, "/ui/claude/switch", url.Values{"profile": {"home"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Discard local changes to settings.json") {
+		t.Fatalf("switch over drift: expected a confirmation, got %d: %s", w.Code, w.Body.String())
+	}
+	if current, _ := profile.Current(tool); current != "work (modified)" {
+		t.Fatalf("switched before confirming: current is %q", current)
+	}
+	if w := postForm(server, "/ui/claude/switch", url.Values{"profile": {"home"}, "confirm": {"true"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("confirmed switch: expected 303, got %d: %s", w.Code, w.Body.String())
+	}
+	if current, _ := profile.Current(tool); current != "home" {
+		t.Fatalf("expected home to be active, got %q", current)
+	}
+
+	w = postForm(server, "/ui/claude/delete", url.Values{"profile": {"work"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Delete profile &#34;work&#34;?") {
+		t.Fatalf("delete: expected a confirmation, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := postForm(server, "/ui/claude/delete", url.Values{"profile": {"work"}, "confirm": {"true"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("confirmed delete: expected 303, got %d: %s", w.Code, w.Body.String())
+	}
+	if exists, _ := profile.Exists(tool, "work"); exists {
+		t.Fatalf("expected work to be deleted")
+	}
+
+	req := httptest.NewRequest("POST", "/ui/claude/save", strings.NewReader("profile=evil"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Sec-Fetch-Site", "cross-site")
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("cross-site post: expected 403, got %d", w.Code)
+	}
+	if w := postForm(NewServer(WithTools(tool), WithReadOnly(true)), "/ui/claude/save", url.Values{"profile": {"x"}}); w.Code != http.StatusForbidden {
+		t.Fatalf("read-only server: expected 403, got %d", w.Code)
+	}
+}
+
+func TestHTMLUIConfirmsProtectedProfiles(t *testing.T) {
+	tool := newTestTool(t)
+	if err := profile.Save(tool, "prod", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.UpdateMeta(tool, "prod", func(m *profile.Meta) error {
+		m.Tags = map[string]string{profile.ProtectedTag: ""}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+	server := NewServer(WithTools(tool))
+
+	w := postForm(server, "/ui/claude/switch", url.Values{"profile": {"prod"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Profile &#34;prod&#34; is protected. Switch to it anyway?") ||
+		!strings.Contains(w.Body.String(), `name="confirm_production"`) {
+		t.Fatalf("switch: expected a confirmation, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := postForm(server, "/ui/claude/switch", url.Values{"profile": {"prod"}, "confirm_production": {"true"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("confirmed switch: expected 303, got %d: %s", w.Code, w.Body.String())
+	}
+	if current, _ := profile.Current(tool); current != "prod" {
+		t.Fatalf("expected prod to be active, got %q", current)
+	}
+
+	w = postForm(server, "/ui/claude/delete", url.Values{"profile": {"prod"}, "confirm": {"true"}})
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "Profile &#34;prod&#34; is protected. Delete it anyway?") ||
+		!strings.Contains(w.Body.String(), `name="confirm"`) || !strings.Contains(w.Body.String(), `name="confirm_production"`) {
+		t.Fatalf("delete: expected a confirmation keeping confirm, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := postForm(server, "/ui/claude/delete", url.Values{"profile": {"prod"}, "confirm": {"true"}, "confirm_production": {"true"}}); w.Code != http.StatusSeeOther {
+		t.Fatalf("confirmed delete: expected 303, got %d: %s", w.Code, w.Body.String())
+	}
+	if exists, _ := profile.Exists(tool, "prod"); exists {
+		t.Fatalf("expected prod to be deleted")
+	}
+}
+
+func TestHTMLUISignIn(t *testing.T) {
+	tool := newTestTool(t)
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	server := NewServer(WithTools(tool), WithAuthToken("s3cret"))
+
+	w := getPage(server, "/ui/")
+	if strings.Contains(w.Body.String(), "work") || !strings.Contains(w.Body.String(), `action="/ui/login"`) {
+		t.Fatalf("expected a sign-in form and no profiles:\n%s", w.Body.String())
+	}
+	if w := postForm(server, "/ui/claude/save", url.Values{"profile": {"x"}}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("post without signing in: expected 401, got %d", w.Code)
+	}
+	if w := postForm(server, "/ui/login", url.Values{"token": {"nope"}}); w.Code != http.StatusUnauthorized {
+		t.Fatalf("wrong token: expected 401, got %d", w.Code)
+	}
+
+	w = postForm(server, "/ui/login", url.Values{"token": {"s3cret"}})
+	cookies := w.Result().Cookies()
+	if w.Code != http.StatusSeeOther || len(cookies) != 1 || !cookies[0].HttpOnly {
+		t.Fatalf("sign in: expected a redirect setting an HttpOnly cookie, got %d %v", w.Code, cookies)
+	}
+	if w := getPage(server, "/ui/", cookies...); !strings.Contains(w.Body.String(), "work") {
+		t.Fatalf("expected profiles once signed in:\n%s", w.Body.String())
+	}
+	if w := postForm(server, "/ui/claude/save", url.Values{"profile": {"x"}}, cookies...); w.Code != http.StatusSeeOther {
+		t.Fatalf("post once signed in: expected 303, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := getPage(server, "/api/claude/profiles", cookies...); w.Code != http.StatusUnauthorized {
+		t.Fatalf("the session cookie must not authenticate the API, got %d", w.Code)
+	}
+
+	w = postForm(server, "/ui/logout", nil, cookies...)
+	if cleared := w.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Fatalf("sign out: expected the cookie to be removed, got %v", cleared)
+	}
+}
@@ -0,0 +1,148 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"tokyo/pkg/token"
+)
+
+// buildHandler assembles the middleware chain around the route mux.
+func (s *Server) buildHandler() http.Handler {
+	var h http.Handler = s.drainMiddleware(s.routeOperations(s.mux))
+	if s.readOnly {
+		h = readOnlyMiddleware(h)
+	}
+	if s.authRequired() {
+		h = s.authMiddleware(h)
+	}
+	h = compressMiddleware(h)
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		h = s.middleware[i](h)
+	}
+	h = s.versionMiddleware(h)
+	if s.basePath != "" {
+		h = http.StripPrefix(s.basePath, h)
+	}
+	if s.logger != nil {
+		h = loggingMiddleware(s.logger, h)
+	}
+	return h
+}
+
+func isAPIPath(path string) bool {
+	return strings.HasPrefix(path, "/api/")
+}
+
+// authRequired reports whether /api/ routes need a bearer token.
+func (s *Server) authRequired() bool {
+	return s.authToken != "" || s.tokenAuth
+}
+
+func (s *Server) authMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAPIPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		got, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok && r.URL.Path == "/api/ws" {
+			got, ok = webSocketToken(r)
+		}
+		if ok {
+			authed, valid, err := s.authenticate(r, got)
+			if err != nil {
+				writeError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			if valid {
+				if tok, scoped := requestToken(authed); scoped && tok.ReadOnly() && !isSafeMethod(r.Method) {
+					writeErrorCode(w, http.StatusForbidden, CodeReadOnly, "token is read-only")
+					return
+				}
+				next.ServeHTTP(w, authed)
+				return
+			}
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="tokyo"`)
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+	})
+}
+
+// authenticate checks secret against the WithAuthToken token and the tokens
+// file. When a token from the file matches, the returned request carries it
+// for requestToken.
+func (s *Server) authenticate(r *http.Request, secret string) (*http.Request, bool, error) {
+	if s.authToken != "" && subtle.ConstantTimeCompare([]byte(secret), []byte(s.authToken)) == 1 {
+		return r, true, nil
+	}
+	if s.tokenAuth {
+		tokens, err := token.Load(s.tokenRoot)
+		if err != nil {
+			return r, false, err
+		}
+		if tok, found := tokens.Authenticate(secret, time.Now()); found {
+			return r.WithContext(context.WithValue(r.Context(), tokenKey{}, tok)), true, nil
+		}
+	}
+	return r, false, nil
+}
+
+type tokenKey struct{}
+
+// requestToken returns the token from the tokens file that authenticated r.
+// Requests authenticated with the WithAuthToken token have none.
+func requestToken(r *http.Request) (token.Token, bool) {
+	tok, ok := r.Context().Value(tokenKey{}).(token.Token)
+	return tok, ok
+}
+
+func readOnlyMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if isAPIPath(r.URL.Path) && !isSafeMethod(r.Method) {
+			writeErrorCode(w, http.StatusForbidden, CodeReadOnly, "server is read-only")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		logger.Info("request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.status,
+			"duration", time.Since(start),
+		)
+	})
+}
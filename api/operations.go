@@ -0,0 +1,196 @@
+package api
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+// Kinds of Operation.
+const (
+	OperationSwitch = "switch"
+	OperationSave   = "save"
+)
+
+// States of an Operation.
+const (
+	OperationRunning   = "running"
+	OperationSucceeded = "succeeded"
+	OperationFailed    = "failed"
+)
+
+// operationRetention is how long a finished operation can still be looked
+// up.
+const operationRetention = 10 * time.Minute
+
+// Operation is a switch or save the server runs in the background because
+// the request asked for it with "Prefer: respond-async". Its progress is
+// published as operation events and can be polled at
+// /api/v1/operations/{id}.
+type Operation struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	Tool    string `json:"tool"`
+	Profile string `json:"profile"`
+	State   string `json:"state"`
+	// Step, Done and Total report how far the operation got: Done of Total
+	// config files have finished Step, one of the profile.Progress steps.
+	Step  string `json:"step,omitempty"`
+	Done  int    `json:"done"`
+	Total int    `json:"total"`
+	// Error and Code describe why a failed operation failed, as an error
+	// answer would.
+	Error    string     `json:"error,omitempty"`
+	Code     string     `json:"code,omitempty"`
+	Started  time.Time  `json:"started"`
+	Finished *time.Time `json:"finished,omitempty"`
+}
+
+// operations holds the operations a server has started. They live in
+// memory only.
+type operations struct {
+	mu  sync.Mutex
+	ops map[string]*Operation
+}
+
+// add stores op under a new random ID and returns it with the ID set. It
+// forgets operations that finished more than operationRetention ago.
+func (o *operations) add(op Operation, now time.Time) (Operation, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Operation{}, err
+	}
+	op.ID = hex.EncodeToString(id)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.ops == nil {
+		o.ops = make(map[string]*Operation)
+	}
+	for id, old := range o.ops {
+		if old.Finished != nil && now.Sub(*old.Finished) > operationRetention {
+			delete(o.ops, id)
+		}
+	}
+	o.ops[op.ID] = &op
+	return op, nil
+}
+
+// update applies fn to the operation id and returns a copy of the result.
+func (o *operations) update(id string, fn func(*Operation)) Operation {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	op := o.ops[id]
+	fn(op)
+	return *op
+}
+
+func (o *operations) get(id string) (Operation, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	op, ok := o.ops[id]
+	if !ok {
+		return Operation{}, false
+	}
+	return *op, true
+}
+
+// preferAsync reports whether r asks to be answered before the work is
+// done, with "Prefer: respond-async" (RFC 7240).
+func preferAsync(r *http.Request) bool {
+	for _, header := range r.Header.Values("Prefer") {
+		for _, pref := range strings.Split(header, ",") {
+			name, _, _ := strings.Cut(pref, ";")
+			name, _, _ = strings.Cut(name, "=")
+			if strings.EqualFold(strings.TrimSpace(name), "respond-async") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// startOperation runs a switch or save of profileName in the background and
+// answers 202 Accepted with the operation. run gets tool set up to report
+// progress. Shutdown waits for the operation like for a request in flight.
+func (s *Server) startOperation(w http.ResponseWriter, kind string, tool profile.Tool, profileName string, run func(profile.Tool) error) {
+	if !s.drain.begin() {
+		w.Header().Set("Retry-After", "5")
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	op, err := s.operations.add(Operation{
+		Kind:    kind,
+		Tool:    tool.Name,
+		Profile: profileName,
+		State:   OperationRunning,
+		Started: time.Now().UTC(),
+	}, time.Now())
+	if err != nil {
+		s.drain.end()
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	go func() {
+		defer s.drain.end()
+		tracked := tool.WithProgress(func(step string, done, total int) {
+			s.publishOperation(s.operations.update(op.ID, func(o *Operation) {
+				o.Step, o.Done, o.Total = step, done, total
+			}))
+		})
+		err := run(tracked)
+		finished := s.operations.update(op.ID, func(o *Operation) {
+			now := time.Now().UTC()
+			o.Finished = &now
+			o.State = OperationSucceeded
+			if err != nil {
+				o.State, o.Error, o.Code = OperationFailed, err.Error(), errorCode(err)
+			}
+		})
+		s.publishOperation(finished)
+	}()
+
+	w.Header().Set("Location", s.basePath+APIPrefix+"/operations/"+op.ID)
+	w.Header().Set("Preference-Applied", "respond-async")
+	writeJSON(w, http.StatusAccepted, op)
+}
+
+func (s *Server) publishOperation(op Operation) {
+	eventType := EventOperationProgress
+	if op.Finished != nil {
+		eventType = EventOperationFinished
+	}
+	s.events.publish(Event{Type: eventType, Tool: op.Tool, Profile: op.Profile, Time: time.Now().UTC(), Operation: &op})
+}
+
+// routeOperations sends /api/operations/ requests to s.opsMux and the rest
+// to next. One mux would find GET /api/operations/{id} ambiguous with
+// /api/{tool}/profiles and the like.
+func (s *Server) routeOperations(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/api/operations/") {
+			s.opsMux.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// handleOperation answers GET /api/operations/{id}.
+func (s *Server) handleOperation(w http.ResponseWriter, r *http.Request) {
+	op, ok := s.operations.get(r.PathValue("id"))
+	if tok, restricted := requestToken(r); ok && restricted && !tok.AllowsTool(op.Tool) {
+		ok = false
+	}
+	if !ok {
+		writeError(w, http.StatusNotFound, "unknown operation")
+		return
+	}
+	writeJSON(w, http.StatusOK, op)
+}
@@ -0,0 +1,118 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+func TestAsyncOperations(t *testing.T) {
+	tool := newTestTool(t)
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	server := NewServer(WithTools(tool))
+	events := server.events.subscribe()
+	defer server.events.unsubscribe(events)
+
+	start := func(method, path, body string) Operation {
+		t.Helper()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Prefer", "wait=0, respond-async")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted || w.Header().Get("Preference-Applied") != "respond-async" {
+			t.Fatalf("%s %s: expected 202, got %d: %s", method, path, w.Code, w.Body.String())
+		}
+		var op Operation
+		if err := json.Unmarshal(w.Body.Bytes(), &op); err != nil {
+			t.Fatalf("unmarshal: %v", err)
+		}
+		if w.Header().Get("Location") != "/api/v1/operations/"+op.ID || op.State != OperationRunning {
+			t.Fatalf("unexpected operation %+v at %q", op, w.Header().Get("Location"))
+		}
+		return op
+	}
+	// wait follows the operation's events until it finishes, then checks
+	// that polling reports the same.
+	wait := func(op Operation) Operation {
+		t.Helper()
+		progressed := false
+		for {
+			select {
+			case ev := <-events:
+				if ev.Operation == nil || ev.Operation.ID != op.ID {
+					continue
+				}
+				if ev.Type == EventOperationProgress {
+					progressed = true
+					continue
+				}
+				if !progressed && ev.Operation.State == OperationSucceeded {
+					t.Fatalf("expected progress events before %+v", ev.Operation)
+				}
+				w := httptest.NewRecorder()
+				server.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/operations/"+op.ID, nil))
+				var polled Operation
+				if err := json.Unmarshal(w.Body.Bytes(), &polled); err != nil || polled.State != ev.Operation.State {
+					t.Fatalf("poll: %d %s", w.Code, w.Body.String())
+				}
+				return polled
+			case <-time.After(5 * time.Second):
+				t.Fatalf("operation %s did not finish", op.ID)
+			}
+		}
+	}
+
+	op := wait(start("POST", "/api/v1/claude/switch/work", ""))
+	if op.State != OperationSucceeded || op.Done != op.Total || op.Finished == nil {
+		t.Fatalf("switch: %+v", op)
+	}
+	if current, _ := profile.Current(tool); current != "work" {
+		t.Fatalf("expected work to be active, got %q", current)
+	}
+
+	op = wait(start("POST", "/api/v1/claude/profiles", `{"profile":"home"}`))
+	if op.State != OperationSucceeded || op.Kind != OperationSave {
+		t.Fatalf("save: %+v", op)
+	}
+
+	op = wait(start("POST", "/api/v1/claude/switch/nope", ""))
+	if op.State != OperationFailed || op.Code != CodeProfileNotFound || op.Error == "" {
+		t.Fatalf("failed switch: %+v", op)
+	}
+
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/operations/unknown", nil))
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("unknown operation: expected 404, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/claude/nope", nil))
+	if strings.Contains(w.Body.String(), "operation") {
+		t.Fatalf("expected other two-segment paths not to reach the operations route: %s", w.Body.String())
+	}
+}
+
+func TestPreferAsync(t *testing.T) {
+	for header, want := range map[string]bool{
+		"":                       false,
+		"respond-async":          true,
+		"Respond-Async":          true,
+		"wait=10, respond-async": true,
+		"return=minimal":         false,
+	} {
+		r := httptest.NewRequest("POST", "/", nil)
+		if header != "" {
+			r.Header.Set("Prefer", header)
+		}
+		if got := preferAsync(r); got != want {
+			t.Errorf("preferAsync(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
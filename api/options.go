@@ -0,0 +1,103 @@
+package api
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"tokyo/pkg/profile"
+)
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithTools replaces the managed tools. The default is profile.Tools().
+func WithTools(tools ...profile.Tool) Option {
+	return func(s *Server) {
+		s.tools = make(map[string]profile.Tool, len(tools))
+		for _, t := range tools {
+			s.tools[t.Name] = t
+		}
+	}
+}
+
+// WithHome manages the config files and stores under home instead of the
+// process's home directory, e.g. for a service account. It applies to the
+// tools given with WithTools too, whatever the order of the options.
+func WithHome(home string) Option {
+	return func(s *Server) {
+		s.home = home
+	}
+}
+
+// WithBasePath mounts every route under prefix (e.g. "/tokyo"), for serving
+// behind a reverse proxy or inside another mux.
+func WithBasePath(prefix string) Option {
+	return func(s *Server) {
+		s.basePath = "/" + strings.Trim(prefix, "/")
+		if s.basePath == "/" {
+			s.basePath = ""
+		}
+	}
+}
+
+// WithAuthToken requires "Authorization: Bearer <token>" on /api/ routes.
+// Health checks and the static UI stay public.
+func WithAuthToken(token string) Option {
+	return func(s *Server) {
+		s.authToken = token
+	}
+}
+
+// WithTokens also accepts the tokens managed with `tokyo serve token` in the
+// tokens file under the tokyo directory root. The file is read on every
+// request, so new and revoked tokens take effect at once. Once the file
+// exists, /api/ routes require a token even after the last one is revoked.
+// Read-only tokens are refused mutating requests with 403.
+func WithTokens(root string) Option {
+	return func(s *Server) {
+		s.tokenRoot = root
+	}
+}
+
+// WithLogger logs one line per request to logger, and one per change made
+// or drift noticed.
+func WithLogger(logger *slog.Logger) Option {
+	return func(s *Server) {
+		s.logger = logger
+	}
+}
+
+// WithEventHandler has h told about every event the server publishes, the
+// same ones /api/events streams; see EventHandler. It is the place to hook
+// webhooks, notifications and the like onto changes.
+func WithEventHandler(h EventHandler) Option {
+	return func(s *Server) {
+		s.events.handle(h)
+	}
+}
+
+// WithRecordIntent has every switch the server makes recorded as the
+// profile its tool is meant to be on; see profile.RestoreIntent.
+func WithRecordIntent(record bool) Option {
+	return func(s *Server) {
+		if record {
+			s.events.handle(s.recordIntent)
+		}
+	}
+}
+
+// WithMiddleware wraps the server's handler. The first middleware is the
+// outermost one that user code can add (request logging sits outside it).
+func WithMiddleware(mw ...func(http.Handler) http.Handler) Option {
+	return func(s *Server) {
+		s.middleware = append(s.middleware, mw...)
+	}
+}
+
+// WithReadOnly rejects every mutating API request with 403.
+func WithReadOnly(readOnly bool) Option {
+	return func(s *Server) {
+		s.readOnly = readOnly
+	}
+}
@@ -0,0 +1,314 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tokyo/pkg/profile"
+	"tokyo/pkg/token"
+)
+
+func newTestTool(t *testing.T) profile.Tool {
+	t.Helper()
+	home := t.TempDir()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return profile.ClaudeTool().WithHome(home)
+}
+
+func TestWithAuthToken(t *testing.T) {
+	server := NewServer(WithTools(newTestTool(t)), WithAuthToken("s3cret"))
+
+	cases := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{name: "missing", path: "/api/claude/profiles", want: http.StatusUnauthorized},
+		{name: "wrong", path: "/api/claude/profiles", header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "ok", path: "/api/claude/profiles", header: "Bearer s3cret", want: http.StatusOK},
+		{name: "health_is_public", path: "/healthz", want: http.StatusOK},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tc.path, nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestWithTokens(t *testing.T) {
+	root := t.TempDir()
+	open := NewServer(WithTools(newTestTool(t)), WithTokens(root))
+	w := httptest.NewRecorder()
+	open.ServeHTTP(w, httptest.NewRequest("GET", "/api/claude/profiles", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected a server without a tokens file to stay open, got %d", w.Code)
+	}
+
+	tokens, err := token.Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	reader, err := tokens.Create("dashboard", token.ScopeRead, nil, time.Time{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	writer, err := tokens.Create("ci", token.ScopeReadWrite, nil, time.Time{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	expired, err := tokens.Create("old", token.ScopeReadWrite, nil, time.Now().Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	server := NewServer(WithTools(newTestTool(t)), WithTokens(root))
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{name: "missing", method: "GET", path: "/api/claude/profiles", want: http.StatusUnauthorized},
+		{name: "expired", method: "GET", path: "/api/claude/profiles", token: expired, want: http.StatusUnauthorized},
+		{name: "read", method: "GET", path: "/api/claude/profiles", token: reader, want: http.StatusOK},
+		{name: "read_only_cannot_write", method: "DELETE", path: "/api/claude/profiles/missing", token: reader, want: http.StatusForbidden},
+		{name: "read_write", method: "DELETE", path: "/api/claude/profiles/missing", token: writer, want: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			if w.Code != tc.want {
+				t.Fatalf("expected %d, got %d: %s", tc.want, w.Code, w.Body.String())
+			}
+		})
+	}
+
+	if _, err := tokens.Revoke("ci"); err != nil {
+		t.Fatalf("Revoke: %v", err)
+	}
+	req := httptest.NewRequest("GET", "/api/claude/profiles", nil)
+	req.Header.Set("Authorization", "Bearer "+writer)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("expected a revoked token to be rejected without a restart, got %d", w.Code)
+	}
+}
+
+func TestToolScopedTokens(t *testing.T) {
+	root := t.TempDir()
+	tokens, err := token.Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	claudeOnly, err := tokens.Create("dashboard", token.ScopeReadWrite, []string{"claude"}, time.Time{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	claude := newTestTool(t)
+	codex := profile.CodexTool().WithHome(t.TempDir())
+	server := NewServer(WithTools(claude, codex), WithTokens(root), WithRegistry(t.TempDir()))
+
+	routes := []struct{ method, path string }{
+		{"GET", "/api/%s/profiles"},
+		{"GET", "/api/%s/current"},
+		{"GET", "/api/%s/diff"},
+		{"POST", "/api/%s/profiles"},
+		{"POST", "/api/%s/switch/work"},
+		{"POST", "/api/%s/adopt"},
+		{"DELETE", "/api/%s/profiles/work"},
+		{"GET", "/api/%s/registry"},
+		{"GET", "/api/%s/registry/work"},
+		{"GET", "/api/%s/registry/work/1"},
+		{"POST", "/api/%s/registry/work"},
+	}
+	for _, route := range routes {
+		for _, tool := range []string{"claude", "codex"} {
+			path := fmt.Sprintf(route.path, tool)
+			t.Run(route.method+" "+path, func(t *testing.T) {
+				req := httptest.NewRequest(route.method, path, strings.NewReader(`{"name":"scoped"}`))
+				req.Header.Set("Authorization", "Bearer "+claudeOnly)
+				w := httptest.NewRecorder()
+				server.ServeHTTP(w, req)
+				if (w.Code == http.StatusForbidden) != (tool == "codex") || w.Code == http.StatusUnauthorized {
+					t.Fatalf("got %d for %s: %s", w.Code, tool, w.Body.String())
+				}
+			})
+		}
+	}
+}
+
+func TestToolScopedTokenEvents(t *testing.T) {
+	root := t.TempDir()
+	tokens, err := token.Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	codexOnly, err := tokens.Create("codex-watcher", token.ScopeRead, []string{"codex"}, time.Time{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	server := NewServer(WithTools(newTestTool(t), profile.CodexTool().WithHome(t.TempDir())), WithTokens(root))
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	req, err := http.NewRequest("GET", ts.URL+"/api/events", nil)
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+codexOnly)
+	resp, err := ts.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	server.publish(EventProfileSwitched, "claude", "work")
+	server.publish(EventProfileSwitched, "codex", "personal")
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+		if !ok {
+			continue
+		}
+		if !strings.Contains(data, `"tool":"codex"`) {
+			t.Fatalf("expected only codex events, got %s", data)
+		}
+		return
+	}
+	t.Fatalf("no event received: %v", scanner.Err())
+}
+
+func TestWithReadOnly(t *testing.T) {
+	server := NewServer(WithTools(newTestTool(t)), WithReadOnly(true))
+
+	req := httptest.NewRequest("POST", "/api/claude/profiles", bytes.NewBufferString(`{"profile":"work"}`))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/claude/profiles", nil)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestWithBasePath(t *testing.T) {
+	server := NewServer(WithTools(newTestTool(t)), WithBasePath("/tokyo/"))
+
+	req := httptest.NewRequest("GET", "/tokyo/api/claude/profiles", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", "/api/claude/profiles", nil)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 outside base path, got %d", w.Code)
+	}
+}
+
+func TestWithLoggerAndMiddleware(t *testing.T) {
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, nil))
+	var order []string
+	mw := func(name string) func(http.Handler) http.Handler {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	server := NewServer(WithTools(newTestTool(t)), WithLogger(logger), WithMiddleware(mw("first"), mw("second")))
+	req := httptest.NewRequest("GET", "/api/unknown/profiles", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+
+	if strings.Join(order, ",") != "first,second" {
+		t.Fatalf("expected middleware in order, got %v", order)
+	}
+	if !strings.Contains(logs.String(), "path=/api/unknown/profiles") || !strings.Contains(logs.String(), "status=404") {
+		t.Fatalf("expected request logged, got %q", logs.String())
+	}
+}
+
+func TestWithHome(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tool := newTestTool(t)
+	if err := profile.Save(tool, "service", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	server := NewServer(WithHome(tool.Home), WithTools(profile.ClaudeTool()))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/claude/profiles", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if !strings.Contains(w.Body.String(), `"service"`) {
+		t.Fatalf("expected profiles under the given home, got %s", w.Body.String())
+	}
+}
+
+func TestWithRecordIntent(t *testing.T) {
+	tool := newTestTool(t)
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	switchWork := func(server *Server) {
+		t.Helper()
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/claude/switch/work", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("switch: expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	switchWork(NewServer(WithTools(tool)))
+	if intended, err := profile.Intent(tool); err != nil || intended != "" {
+		t.Fatalf("Intent without WithRecordIntent = %q, %v", intended, err)
+	}
+	switchWork(NewServer(WithTools(tool), WithRecordIntent(true)))
+	if intended, err := profile.Intent(tool); err != nil || intended != "work" {
+		t.Fatalf("Intent = %q, %v, want work", intended, err)
+	}
+}
@@ -0,0 +1,260 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+// maxBundleSize bounds the bundles the registry accepts.
+const maxBundleSize = 10 << 20
+
+// VersionHeader carries the version of a bundle served by the registry.
+const VersionHeader = "Tokyo-Version"
+
+// RegistryVersion is one published version of a registry profile.
+type RegistryVersion struct {
+	Version   int       `json:"version"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Published time.Time `json:"published"`
+}
+
+// WithRegistry turns on registry mode: profiles published to the server are
+// kept as numbered bundle versions under dir/<tool>/<profile>/, for other
+// machines to pull. Publishing needs WithAuthToken or WithTokens; pulling
+// needs a token too when they are set.
+func WithRegistry(dir string) Option {
+	return func(s *Server) {
+		s.registry = dir
+	}
+}
+
+func (s *Server) registryRoutes() {
+	s.mux.HandleFunc("GET /api/{tool}/registry", s.handleRegistryList)
+	s.mux.HandleFunc("GET /api/{tool}/registry/{profile}", s.handleRegistryVersions)
+	s.mux.HandleFunc("GET /api/{tool}/registry/{profile}/{version}", s.handleRegistryPull)
+	s.mux.HandleFunc("POST /api/{tool}/registry/{profile}", s.handleRegistryPublish)
+}
+
+func (s *Server) handleRegistryList(w http.ResponseWriter, r *http.Request) {
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.registry, tool.Name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	profiles := []string{}
+	for _, e := range entries {
+		if e.IsDir() && profile.ValidateProfileName(e.Name()) == nil {
+			profiles = append(profiles, e.Name())
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"profiles": profiles})
+}
+
+func (s *Server) handleRegistryVersions(w http.ResponseWriter, r *http.Request) {
+	tool, profileName, ok := s.registryProfile(w, r)
+	if !ok {
+		return
+	}
+	versions, err := s.registryVersions(tool, profileName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(versions) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("profile %q is not published", profileName))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"profile": profileName, "versions": versions})
+}
+
+func (s *Server) handleRegistryPull(w http.ResponseWriter, r *http.Request) {
+	tool, profileName, ok := s.registryProfile(w, r)
+	if !ok {
+		return
+	}
+	versions, err := s.registryVersions(tool, profileName)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if len(versions) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("profile %q is not published", profileName))
+		return
+	}
+
+	version := versions[len(versions)-1].Version
+	if v := r.PathValue("version"); v != "latest" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "version must be a positive number or latest")
+			return
+		}
+		if !slices.ContainsFunc(versions, func(rv RegistryVersion) bool { return rv.Version == n }) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("profile %q has no version %d", profileName, n))
+			return
+		}
+		version = n
+	}
+
+	data, err := os.ReadFile(s.registryBundle(tool, profileName, version))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set(VersionHeader, strconv.Itoa(version))
+	w.Write(data)
+}
+
+func (s *Server) handleRegistryPublish(w http.ResponseWriter, r *http.Request) {
+	if !s.authRequired() {
+		writeError(w, http.StatusForbidden, "publishing needs a server started with an auth token")
+		return
+	}
+	tool, profileName, ok := s.registryProfile(w, r)
+	if !ok {
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBundleSize))
+	if err != nil {
+		writeError(w, http.StatusRequestEntityTooLarge, "bundle is too large")
+		return
+	}
+	info, err := profile.ReadBundleInfo(bytes.NewReader(data))
+	if err != nil {
+		writeErrorCode(w, http.StatusBadRequest, CodeInvalidBundle, err.Error())
+		return
+	}
+	if info.Tool != tool.Name || !slices.Equal(info.Profiles, []string{profileName}) {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("bundle must hold exactly the %s profile %q", tool.Name, profileName))
+		return
+	}
+
+	version, err := s.storeRegistryBundle(tool, profileName, data)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"profile": profileName, "version": version})
+}
+
+// registryProfile resolves the tool and profile of a registry request,
+// answering the request itself when either is invalid.
+func (s *Server) registryProfile(w http.ResponseWriter, r *http.Request) (profile.Tool, string, bool) {
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return profile.Tool{}, "", false
+	}
+	profileName := r.PathValue("profile")
+	if !validProfileName(w, profileName) {
+		return profile.Tool{}, "", false
+	}
+	return tool, profileName, true
+}
+
+func (s *Server) registryBundle(tool profile.Tool, profileName string, version int) string {
+	return filepath.Join(s.registry, tool.Name, profileName, strconv.Itoa(version)+".tar.gz")
+}
+
+// registryVersions returns the published versions of a profile, oldest
+// first.
+func (s *Server) registryVersions(tool profile.Tool, profileName string) ([]RegistryVersion, error) {
+	dir := filepath.Join(s.registry, tool.Name, profileName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	versions := []RegistryVersion{}
+	for _, e := range entries {
+		n, err := strconv.Atoi(strings.TrimSuffix(e.Name(), ".tar.gz"))
+		if err != nil || n < 1 || !strings.HasSuffix(e.Name(), ".tar.gz") || !e.Type().IsRegular() {
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		versions = append(versions, RegistryVersion{
+			Version:   n,
+			Size:      int64(len(data)),
+			SHA256:    hex.EncodeToString(sum[:]),
+			Published: info.ModTime().UTC(),
+		})
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i].Version < versions[j].Version })
+	return versions, nil
+}
+
+// storeRegistryBundle stores data as the next version of a profile. The
+// bundle is written in full before it is linked under its version number,
+// so pulls never see a partial bundle and concurrent publishes get distinct
+// numbers.
+func (s *Server) storeRegistryBundle(tool profile.Tool, profileName string, data []byte) (int, error) {
+	versions, err := s.registryVersions(tool, profileName)
+	if err != nil {
+		return 0, err
+	}
+	dir := filepath.Join(s.registry, tool.Name, profileName)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return 0, err
+	}
+	tmp, err := os.CreateTemp(dir, ".publish-")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return 0, err
+	}
+	if err := tmp.Close(); err != nil {
+		return 0, err
+	}
+
+	next := 1
+	if len(versions) > 0 {
+		next = versions[len(versions)-1].Version + 1
+	}
+	for ; ; next++ {
+		err := os.Link(tmp.Name(), s.registryBundle(tool, profileName, next))
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		return next, nil
+	}
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+// exportBundle saves content as the claude profile name under a scratch home
+// and returns it exported as a bundle.
+func exportBundle(t *testing.T, name, content string) []byte {
+	t.Helper()
+	tool := profile.ClaudeTool().WithHome(t.TempDir())
+	if err := profile.SaveFiles(tool, name, map[string][]byte{"settings.json": []byte(content)}, true); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := profile.Export(tool, []string{name}, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestRegistryPublishAndPull(t *testing.T) {
+	tool := profile.ClaudeTool().WithHome(t.TempDir())
+	server := NewServer(WithTools(tool), WithAuthToken("secret"), WithRegistry(t.TempDir()))
+	do := func(method, path string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	for i, content := range []string{`{"model":"a"}`, `{"model":"b"}`} {
+		w := do("POST", "/api/claude/registry/work", exportBundle(t, "work", content))
+		if w.Code != http.StatusCreated {
+			t.Fatalf("publish: expected 201, got %d: %s", w.Code, w.Body.String())
+		}
+		var resp struct{ Version int }
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		if resp.Version != i+1 {
+			t.Fatalf("publish %d: version = %d", i, resp.Version)
+		}
+	}
+
+	w := do("GET", "/api/claude/registry", nil)
+	if w.Code != http.StatusOK || !bytes.Contains(w.Body.Bytes(), []byte(`"work"`)) {
+		t.Fatalf("list: %d %s", w.Code, w.Body.String())
+	}
+	w = do("GET", "/api/claude/registry/work", nil)
+	var versions struct{ Versions []RegistryVersion }
+	if err := json.Unmarshal(w.Body.Bytes(), &versions); err != nil || len(versions.Versions) != 2 {
+		t.Fatalf("versions: %s (%v)", w.Body.String(), err)
+	}
+
+	for _, tc := range []struct {
+		version string
+		want    string
+		model   string
+	}{{"latest", "2", `{"model":"b"}`}, {"1", "1", `{"model":"a"}`}} {
+		w := do("GET", "/api/claude/registry/work/"+tc.version, nil)
+		if w.Code != http.StatusOK || w.Header().Get(VersionHeader) != tc.want {
+			t.Fatalf("pull %s: %d version %q", tc.version, w.Code, w.Header().Get(VersionHeader))
+		}
+		home := t.TempDir()
+		target := profile.ClaudeTool().WithHome(home)
+		if _, err := profile.Import(target, w.Body, false); err != nil {
+			t.Fatalf("Import: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(home, ".config", "tokyo", "claude", "profiles", "work", "settings.json"))
+		if err != nil || string(data) != tc.model {
+			t.Fatalf("pulled %s: settings.json = %q, %v", tc.version, data, err)
+		}
+	}
+
+	if w := do("GET", "/api/claude/registry/work/3", nil); w.Code != http.StatusNotFound {
+		t.Fatalf("missing version: expected 404, got %d", w.Code)
+	}
+	if w := do("POST", "/api/claude/registry/other", exportBundle(t, "work", `{}`)); w.Code != http.StatusBadRequest {
+		t.Fatalf("mismatched bundle: expected 400, got %d", w.Code)
+	}
+	if w := do("POST", "/api/claude/registry/work", []byte("not a bundle")); w.Code != http.StatusBadRequest {
+		t.Fatalf("invalid bundle: expected 400, got %d", w.Code)
+	}
+}
+
+func TestRegistryPublishNeedsAuthToken(t *testing.T) {
+	server := NewServer(WithTools(profile.ClaudeTool().WithHome(t.TempDir())), WithRegistry(t.TempDir()))
+	req := httptest.NewRequest("POST", "/api/claude/registry/work", bytes.NewReader(exportBundle(t, "work", `{}`)))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestRegistryDisabledByDefault(t *testing.T) {
+	server := NewServer(WithTools(profile.ClaudeTool().WithHome(t.TempDir())))
+	req := httptest.NewRequest("GET", "/api/claude/registry/work/latest", nil)
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code == http.StatusOK {
+		t.Fatalf("expected registry routes to be off, got %d", w.Code)
+	}
+}
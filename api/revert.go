@@ -0,0 +1,34 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+// ApplyReverts switches every tool whose time-boxed switch is over at now
+// back to the profile it came from (see profile.SwitchFor). Each switch back
+// holds the tool's lock and is published, so a running server returns its
+// clients to their profile on time.
+func (s *Server) ApplyReverts(now time.Time) error {
+	var errs []error
+	for _, tool := range s.tools {
+		if err := s.applyRevert(tool, now); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", tool.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) applyRevert(tool profile.Tool, now time.Time) error {
+	unlock := s.toolLocks.lock(tool.Name)
+	defer unlock()
+	r, done, err := profile.ApplyRevert(tool, now, false)
+	if err != nil || !done {
+		return err
+	}
+	s.publish(EventProfileSwitched, tool.Name, r.Profile)
+	return nil
+}
@@ -0,0 +1,44 @@
+package api
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+func TestApplyRevertsSwitchesBackAndPublishes(t *testing.T) {
+	home := t.TempDir()
+	tool := profile.CodexTool().WithHome(home)
+	for _, name := range []string{"work", "personal"} {
+		files := map[string][]byte{"auth.json": []byte(`{}`), "config.toml": []byte("model = \"" + name + "\"\n")}
+		if err := profile.SaveFiles(tool, name, files, false); err != nil {
+			t.Fatalf("SaveFiles %s: %v", name, err)
+		}
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	now := time.Now()
+	if _, err := profile.SwitchFor(tool, "personal", profile.SwitchOverwrite, time.Hour, now); err != nil {
+		t.Fatalf("SwitchFor: %v", err)
+	}
+
+	var events []Event
+	server := NewServer(WithTools(tool), WithEventHandler(func(ev Event) { events = append(events, ev) }))
+	if err := server.ApplyReverts(now.Add(time.Minute)); err != nil || len(events) != 0 {
+		t.Fatalf("ApplyReverts before it is due = %v, events %+v", err, events)
+	}
+	if err := server.ApplyReverts(now.Add(time.Hour)); err != nil {
+		t.Fatalf("ApplyReverts: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != EventProfileSwitched || events[0].Profile != "work" {
+		t.Fatalf("events = %+v, want one switch to work", events)
+	}
+	live, err := os.ReadFile(filepath.Join(home, ".codex", "config.toml"))
+	if err != nil || string(live) != "model = \"work\"\n" {
+		t.Fatalf("live config.toml = %q, %v", live, err)
+	}
+}
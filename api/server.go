@@ -3,71 +3,177 @@ package api
 import (
 	"encoding/json"
 	"errors"
+	"log/slog"
 	"net/http"
+	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"tokyo/pkg/profile"
+	"tokyo/pkg/token"
 )
 
 type Server struct {
-	mux   *http.ServeMux
-	tools map[string]profile.Tool
+	mux *http.ServeMux
+	// opsMux holds the /api/operations/ routes, which would conflict with
+	// the /api/{tool}/... patterns in mux; see routeOperations.
+	opsMux  *http.ServeMux
+	handler http.Handler
+	tools   map[string]profile.Tool
+
+	basePath   string
+	authToken  string
+	tokenRoot  string
+	tokenAuth  bool
+	logger     *slog.Logger
+	middleware []func(http.Handler) http.Handler
+	readOnly   bool
+	home       string
+	registry   string
+	theme      string
+
+	events     *bus
+	drain      drainer
+	shares     shares
+	operations operations
+	toolLocks  toolLocks
+	drift      driftNotices
 }
 
-func NewServer() *Server {
-	s := &Server{
-		mux: http.NewServeMux(),
-		tools: map[string]profile.Tool{
-			"claude": profile.ClaudeTool(),
-			"codex":  profile.CodexTool(),
-		},
+func NewServer(opts ...Option) *Server {
+	s := &Server{mux: http.NewServeMux(), opsMux: http.NewServeMux(), events: newBus()}
+	s.events.handle(s.drift.forget)
+	WithTools(profile.Tools()...)(s)
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.home != "" {
+		for name, t := range s.tools {
+			s.tools[name] = t.WithHome(s.home)
+		}
+	}
+	if s.tokenRoot != "" {
+		if _, err := os.Stat(token.Path(s.tokenRoot)); err == nil {
+			s.tokenAuth = true
+		}
+	}
+	if s.logger != nil {
+		s.events.handle(s.logEvent)
 	}
 	s.routes()
+	s.handler = s.buildHandler()
 	return s
 }
 
+// NewServerForTools returns a server that manages exactly the given tools.
+// Combined with profile.Tool.WithHome it lets integration tests run against
+// a scratch home directory without touching the process environment.
+func NewServerForTools(tools ...profile.Tool) *Server {
+	return NewServer(WithTools(tools...))
+}
+
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	s.mux.ServeHTTP(w, r)
+	s.handler.ServeHTTP(w, r)
 }
 
 func (s *Server) routes() {
+	s.mux.HandleFunc("GET /healthz", s.handleHealth)
+	s.mux.HandleFunc("GET /readyz", s.handleReady)
 	s.mux.HandleFunc("GET /api/{tool}/profiles", s.handleList)
 	s.mux.HandleFunc("GET /api/{tool}/current", s.handleCurrent)
+	s.mux.HandleFunc("GET /api/{tool}/diff", s.handleDiff)
+	s.mux.HandleFunc("GET /api/{tool}/usage", s.handleUsage)
+	s.mux.HandleFunc("GET /api/events", s.handleEvents)
+	s.mux.HandleFunc("GET /api/ws", s.handleWebSocket)
+	s.mux.HandleFunc("GET /api/ui-config", s.handleUIConfig)
+	s.mux.HandleFunc("GET /api/ui-locale", s.handleUILocale)
 	s.mux.HandleFunc("POST /api/{tool}/profiles", s.handleSave)
 	s.mux.HandleFunc("POST /api/{tool}/switch/{profile}", s.handleSwitch)
+	s.mux.HandleFunc("POST /api/{tool}/adopt", s.handleAdopt)
 	s.mux.HandleFunc("DELETE /api/{tool}/profiles/{profile}", s.handleDelete)
-	s.mux.Handle("/", staticHandler())
+	s.opsMux.HandleFunc("GET /api/operations/{id}", s.handleOperation)
+	s.fileRoutes()
+	s.shareRoutes()
+	s.driftRoutes()
+	if s.registry != "" {
+		s.registryRoutes()
+	}
+	s.htmlUIRoutes()
+	s.mux.Handle("/", s.staticHandler())
 }
 
-func (s *Server) getTool(r *http.Request) (profile.Tool, bool) {
+// getTool resolves the tool of a request, answering the request itself when
+// the tool is unknown or outside the tools the request's token may use.
+func (s *Server) getTool(w http.ResponseWriter, r *http.Request) (profile.Tool, bool) {
 	toolName := r.PathValue("tool")
 	tool, ok := s.tools[toolName]
-	return tool, ok
+	if !ok {
+		writeErrorCode(w, http.StatusNotFound, CodeUnknownTool, "unknown tool")
+		return profile.Tool{}, false
+	}
+	if tok, ok := requestToken(r); ok && !tok.AllowsTool(toolName) {
+		writeErrorCode(w, http.StatusForbidden, CodeToolNotAllowed, "token may not use "+toolName)
+		return profile.Tool{}, false
+	}
+	return tool, true
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"status": "ok"})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	ready := true
+	tools := make(map[string]any, len(s.tools))
+	for name, tool := range s.tools {
+		if err := profile.CheckStore(tool); err != nil {
+			ready = false
+			tools[name] = map[string]any{"ready": false, "error": err.Error()}
+			continue
+		}
+		tools[name] = map[string]any{"ready": true}
+	}
+
+	status := http.StatusOK
+	state := "ok"
+	if !ready {
+		status = http.StatusServiceUnavailable
+		state = "unavailable"
+	}
+	writeJSON(w, status, map[string]any{"status": state, "tools": tools})
 }
 
 func (s *Server) handleList(w http.ResponseWriter, r *http.Request) {
-	tool, ok := s.getTool(r)
+	tool, ok := s.getTool(w, r)
 	if !ok {
-		writeError(w, http.StatusNotFound, "unknown tool")
 		return
 	}
 
-	profiles, err := profile.List(tool)
+	profiles, err := profile.ListTagged(tool, r.URL.Query()["tag"])
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	writeJSON(w, http.StatusOK, map[string]any{"profiles": profiles})
+	writeJSONCached(w, r, map[string]any{"profiles": profiles})
 }
 
 func (s *Server) handleCurrent(w http.ResponseWriter, r *http.Request) {
-	tool, ok := s.getTool(r)
+	tool, ok := s.getTool(w, r)
 	if !ok {
-		writeError(w, http.StatusNotFound, "unknown tool")
 		return
 	}
 
+	detail := false
+	if v := r.URL.Query().Get("detail"); v != "" {
+		var err error
+		if detail, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "detail must be true or false")
+			return
+		}
+	}
+
 	status, err := profile.Current(tool)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, err.Error())
@@ -77,107 +183,261 @@ func (s *Server) handleCurrent(w http.ResponseWriter, r *http.Request) {
 	modified := strings.HasSuffix(status, " (modified)")
 	name := strings.TrimSuffix(status, " (modified)")
 	custom := name == "<custom>"
+	if modified {
+		if _, _, err := s.driftedFiles(tool); err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+	} else {
+		s.noticeDrift(tool.Name, "", nil)
+	}
 
-	writeJSON(w, http.StatusOK, map[string]any{
+	resp := map[string]any{
 		"profile":  name,
 		"modified": modified,
 		"custom":   custom,
-	})
+	}
+	if detail && !custom {
+		files, err := profile.Diff(tool, name)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		resp["files"] = files
+	}
+	writeJSONCached(w, r, resp)
+}
+
+// handleDiff compares the live config files with a profile, defaulting to
+// the active one.
+func (s *Server) handleDiff(w http.ResponseWriter, r *http.Request) {
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return
+	}
+
+	profileName := r.URL.Query().Get("profile")
+	if profileName == "" {
+		active, err := profile.ActiveProfile(tool)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if active == "" {
+			writeErrorCode(w, http.StatusNotFound, CodeNoActiveProfile, "no active profile")
+			return
+		}
+		profileName = active
+	}
+	if !validProfileName(w, profileName) {
+		return
+	}
+
+	files, err := profile.Diff(tool, profileName)
+	if err != nil {
+		writeProfileError(w, err)
+		return
+	}
+
+	writeJSONCached(w, r, map[string]any{"profile": profileName, "files": files})
+}
+
+// ProfileUsage is how much a profile has been used; see profile.Usage.
+type ProfileUsage struct {
+	Profile       string     `json:"profile"`
+	ActiveSeconds int64      `json:"active_seconds"`
+	Activations   int        `json:"activations"`
+	LastActive    *time.Time `json:"last_active,omitempty"`
+}
+
+// handleUsage reports how long each profile has been active, most used
+// first.
+func (s *Server) handleUsage(w http.ResponseWriter, r *http.Request) {
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return
+	}
+
+	stats, err := profile.UsageStats(tool, time.Now())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	usage := make([]ProfileUsage, len(stats))
+	for i, u := range stats {
+		usage[i] = ProfileUsage{Profile: u.Profile, ActiveSeconds: int64(u.Active / time.Second), Activations: u.Activations}
+		if !u.LastActive.IsZero() {
+			last := u.LastActive.UTC()
+			usage[i].LastActive = &last
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"usage": usage})
 }
 
 func (s *Server) handleSave(w http.ResponseWriter, r *http.Request) {
-	tool, ok := s.getTool(r)
+	tool, ok := s.getTool(w, r)
 	if !ok {
-		writeError(w, http.StatusNotFound, "unknown tool")
 		return
 	}
 
 	var req struct {
 		Profile string `json:"profile"`
 		Force   bool   `json:"force"`
+		From    string `json:"from"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
 		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
-	if err := profile.ValidateProfileName(req.Profile); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	if preferAsync(r) {
+		if err := checkSave(req.Profile, req.From); err != nil {
+			writeProfileError(w, err)
+			return
+		}
+		s.startOperation(w, OperationSave, tool, req.Profile, func(tool profile.Tool) error {
+			return s.saveProfile(tool, req.Profile, req.From, req.Force)
+		})
 		return
 	}
 
-	if err := profile.Save(tool, req.Profile, req.Force); err != nil {
-		switch {
-		case errors.Is(err, profile.ErrProfileAlreadyExists):
-			writeError(w, http.StatusConflict, err.Error())
-		case errors.Is(err, profile.ErrConfigFileNotFound):
-			writeError(w, http.StatusNotFound, err.Error())
-		default:
-			writeError(w, http.StatusInternalServerError, err.Error())
-		}
+	if err := s.saveProfile(tool, req.Profile, req.From, req.Force); err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"profile": req.Profile})
+}
+
+func (s *Server) handleAdopt(w http.ResponseWriter, r *http.Request) {
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Profile string `json:"profile"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body")
 		return
 	}
 
+	if err := s.adoptProfile(tool, req.Profile); err != nil {
+		writeProfileError(w, err)
+		return
+	}
 	writeJSON(w, http.StatusCreated, map[string]any{"profile": req.Profile})
 }
 
 func (s *Server) handleSwitch(w http.ResponseWriter, r *http.Request) {
-	tool, ok := s.getTool(r)
+	tool, ok := s.getTool(w, r)
 	if !ok {
-		writeError(w, http.StatusNotFound, "unknown tool")
 		return
 	}
 
 	profileName := r.PathValue("profile")
-	if err := profile.ValidateProfileName(profileName); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	force := false
+	if v := r.URL.Query().Get("force"); v != "" {
+		var err error
+		if force, err = strconv.ParseBool(v); err != nil {
+			writeError(w, http.StatusBadRequest, "force must be true or false")
+			return
+		}
+	}
+	tool, ok = confirmProduction(w, r, tool)
+	if !ok {
 		return
 	}
-
-	if err := profile.Switch(tool, profileName); err != nil {
-		if errors.Is(err, profile.ErrProfileNotFound) {
-			writeError(w, http.StatusNotFound, err.Error())
+	if preferAsync(r) {
+		// Refuse what would fail before answering; the operation checks
+		// again once it holds the tool.
+		unlock := s.toolLocks.lock(tool.Name)
+		err := s.checkSwitch(tool, profileName, force)
+		unlock()
+		if err != nil {
+			writeSwitchError(w, err)
 			return
 		}
-		writeError(w, http.StatusInternalServerError, err.Error())
+		s.startOperation(w, OperationSwitch, tool, profileName, func(tool profile.Tool) error {
+			return s.switchProfile(tool, profileName, force)
+		})
 		return
 	}
 
+	if err := s.switchProfile(tool, profileName, force); err != nil {
+		writeSwitchError(w, err)
+		return
+	}
 	writeJSON(w, http.StatusOK, map[string]any{"profile": profileName})
 }
 
+// writeSwitchError answers a switch that failed with err. Unsaved changes
+// are answered with the active profile and the changed files.
+func writeSwitchError(w http.ResponseWriter, err error) {
+	var unsaved *unsavedChangesError
+	if !errors.As(err, &unsaved) {
+		writeProfileError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusConflict, map[string]any{
+		"error":   unsaved.Error(),
+		"code":    CodeUnsavedChanges,
+		"profile": unsaved.Profile,
+		"files":   unsaved.Files,
+	})
+}
+
 func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
-	tool, ok := s.getTool(r)
+	tool, ok := s.getTool(w, r)
 	if !ok {
-		writeError(w, http.StatusNotFound, "unknown tool")
 		return
 	}
-
-	profileName := r.PathValue("profile")
-	if err := profile.ValidateProfileName(profileName); err != nil {
-		writeError(w, http.StatusBadRequest, err.Error())
+	tool, ok = confirmProduction(w, r, tool)
+	if !ok {
 		return
 	}
 
-	cleared, err := profile.Delete(tool, profileName)
+	cleared, err := s.deleteProfile(tool, r.PathValue("profile"))
 	if err != nil {
-		if errors.Is(err, profile.ErrProfileNotFound) {
-			writeError(w, http.StatusNotFound, err.Error())
-			return
-		}
-		writeError(w, http.StatusInternalServerError, err.Error())
+		writeProfileError(w, err)
 		return
 	}
-
 	writeJSON(w, http.StatusOK, map[string]any{"cleared": cleared})
 }
 
+// confirmProduction returns tool made to act on protected profiles when the
+// request sets confirm_production; without it they are refused with
+// CodeProfileProtected.
+func confirmProduction(w http.ResponseWriter, r *http.Request, tool profile.Tool) (profile.Tool, bool) {
+	v := r.URL.Query().Get("confirm_production")
+	if v == "" {
+		return tool, true
+	}
+	confirmed, err := strconv.ParseBool(v)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "confirm_production must be true or false")
+		return tool, false
+	}
+	if confirmed {
+		tool = tool.WithProtectedConfirmed()
+	}
+	return tool, true
+}
+
 func writeJSON(w http.ResponseWriter, status int, data any) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
 
+// writeError answers a request with an error whose code follows from
+// status; see writeErrorCode.
 func writeError(w http.ResponseWriter, status int, message string) {
-	writeJSON(w, status, map[string]string{"error": message})
+	writeErrorCode(w, status, statusCode(status), message)
+}
+
+// writeErrorCode answers a request with an error: an English message for
+// people and a stable code for programs.
+func writeErrorCode(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]string{"error": message, "code": code})
 }
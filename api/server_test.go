@@ -7,6 +7,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"tokyo/pkg/profile"
@@ -79,6 +80,60 @@ func TestCurrentStatus(t *testing.T) {
 	}
 }
 
+func TestCurrentStatusDetail(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	tool := profile.ClaudeTool()
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"changed":true}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	server := NewServer()
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/claude/current?detail=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Profile  string             `json:"profile"`
+		Modified bool               `json:"modified"`
+		Files    []profile.FileDiff `json:"files"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Profile != "work" || !resp.Modified {
+		t.Fatalf("expected work (modified), got %+v", resp)
+	}
+	if len(resp.Files) != 1 || resp.Files[0].Status != profile.FileModified || resp.Files[0].Path != configPath {
+		t.Fatalf("expected settings.json reported as modified, got %+v", resp.Files)
+	}
+	if resp.Files[0].LiveSize-resp.Files[0].ProfileSize != int64(len(`{"changed":true}`)-len(`{}`)) {
+		t.Fatalf("unexpected sizes: %+v", resp.Files[0])
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/claude/current?detail=maybe", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid detail, got %d", w.Code)
+	}
+}
+
 func TestSaveProfile(t *testing.T) {
 	home := t.TempDir()
 	t.Setenv("HOME", home)
@@ -172,6 +227,61 @@ func TestSwitchProfile(t *testing.T) {
 	}
 }
 
+func TestSwitchRequiresForceWhenModified(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"opus"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	server := NewServer()
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/api/claude/switch/work", nil))
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	var resp struct {
+		Profile string             `json:"profile"`
+		Files   []profile.FileDiff `json:"files"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Profile != "work" || len(resp.Files) != 1 || resp.Files[0].Status != profile.FileModified {
+		t.Fatalf("unexpected drift details: %+v", resp)
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/api/claude/switch/work?force=yes", nil))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid force, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("POST", "/api/claude/switch/work?force=true", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 with force, got %d: %s", w.Code, w.Body.String())
+	}
+	if status, _ := profile.Current(tool); status != "work" {
+		t.Fatalf("expected work, got %s", status)
+	}
+}
+
 func TestSwitchProfileNotFound(t *testing.T) {
 	home := t.TempDir()
 	t.Setenv("HOME", home)
@@ -248,3 +358,274 @@ func TestInvalidProfileName(t *testing.T) {
 		t.Fatalf("expected 400, got %d: %s", w.Code, w.Body.String())
 	}
 }
+
+func TestHealthz(t *testing.T) {
+	server := NewServer()
+	req := httptest.NewRequest("GET", "/healthz", nil)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestReadyz(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	server := NewServer()
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp struct {
+		Status string                    `json:"status"`
+		Tools  map[string]map[string]any `json:"tools"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if resp.Status != "ok" || resp.Tools["claude"]["ready"] != true || resp.Tools["codex"]["ready"] != true {
+		t.Fatalf("expected all tools ready, got %s", w.Body.String())
+	}
+}
+
+func TestReadyzUnwritableStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	// A regular file where the store directory should be makes it unusable.
+	if err := os.MkdirAll(filepath.Join(home, ".config"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(home, ".config", "tokyo"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	server := NewServer()
+	req := httptest.NewRequest("GET", "/readyz", nil)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestListProfilesFilteredByTag(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		if err := profile.Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	err := profile.UpdateMeta(tool, "work", func(m *profile.Meta) error {
+		m.Tags = map[string]string{"team": "ai"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+
+	server := NewServer()
+	req := httptest.NewRequest("GET", "/api/claude/profiles?tag=team=ai", nil)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp map[string][]string
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(resp["profiles"]) != 1 || resp["profiles"][0] != "work" {
+		t.Fatalf("expected [work], got %v", resp["profiles"])
+	}
+}
+
+func TestDeleteLockedProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.SetLocked(tool, "work", true); err != nil {
+		t.Fatalf("SetLocked: %v", err)
+	}
+
+	server := NewServer()
+	req := httptest.NewRequest("DELETE", "/api/claude/profiles/work", nil)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	exists, _ := profile.Exists(tool, "work")
+	if !exists {
+		t.Fatalf("locked profile should still exist")
+	}
+}
+
+func TestProtectedProfileNeedsConfirmProduction(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "prod", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.UpdateMeta(tool, "prod", func(m *profile.Meta) error {
+		m.Tags = map[string]string{profile.ProtectedTag: ""}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+
+	server := NewServer()
+	do := func(method, target string, header ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		if len(header) == 2 {
+			req.Header.Set(header[0], header[1])
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+	for _, target := range []string{"/api/claude/switch/prod", "/api/claude/switch/prod?confirm_production=false"} {
+		w := do("POST", target)
+		if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"code":"profile_protected"`) {
+			t.Fatalf("%s: expected 409 profile_protected, got %d: %s", target, w.Code, w.Body.String())
+		}
+	}
+	if w := do("POST", "/api/claude/switch/prod", "Prefer", "respond-async"); w.Code != http.StatusConflict {
+		t.Fatalf("async switch: expected 409, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("DELETE", "/api/claude/profiles/prod"); w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), `"code":"profile_protected"`) {
+		t.Fatalf("delete: expected 409 profile_protected, got %d: %s", w.Code, w.Body.String())
+	}
+	if w := do("POST", "/api/claude/switch/prod?confirm_production=maybe"); w.Code != http.StatusBadRequest {
+		t.Fatalf("bad confirm_production: expected 400, got %d", w.Code)
+	}
+
+	if w := do("POST", "/api/claude/switch/prod?confirm_production=true"); w.Code != http.StatusOK {
+		t.Fatalf("confirmed switch: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if status, _ := profile.Current(tool); status != "prod" {
+		t.Fatalf("expected prod, got %s", status)
+	}
+	if w := do("DELETE", "/api/claude/profiles/prod?confirm_production=true"); w.Code != http.StatusOK {
+		t.Fatalf("confirmed delete: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if exists, _ := profile.Exists(tool, "prod"); exists {
+		t.Fatalf("expected prod to be deleted")
+	}
+}
+
+func TestNewServerForToolsWithHome(t *testing.T) {
+	home := t.TempDir()
+	tool := profile.ClaudeTool().WithHome(home)
+
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	server := NewServerForTools(tool)
+	body := bytes.NewBufferString(`{"profile":"work"}`)
+	req := httptest.NewRequest("POST", "/api/claude/profiles", body)
+	w := httptest.NewRecorder()
+
+	server.ServeHTTP(w, req)
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "tokyo", "claude", "profiles", "work")); err != nil {
+		t.Fatalf("expected profile under injected home: %v", err)
+	}
+
+	req = httptest.NewRequest("GET", "/api/codex/profiles", nil)
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("expected unregistered tool to 404, got %d", w.Code)
+	}
+}
+
+func TestAdoptProfile(t *testing.T) {
+	home := t.TempDir()
+	tool := profile.ClaudeTool().WithHome(home)
+
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	server := NewServerForTools(tool)
+	req := httptest.NewRequest("POST", "/api/claude/adopt", bytes.NewBufferString(`{"profile":"work"}`))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+
+	status, err := profile.Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work" {
+		t.Fatalf("expected work, got %q", status)
+	}
+
+	req = httptest.NewRequest("POST", "/api/claude/adopt", bytes.NewBufferString(`{"profile":"other"}`))
+	w = httptest.NewRecorder()
+	server.ServeHTTP(w, req)
+	if w.Code != http.StatusConflict {
+		t.Fatalf("expected 409 once managed, got %d: %s", w.Code, w.Body.String())
+	}
+}
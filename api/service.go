@@ -0,0 +1,234 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"tokyo/pkg/profile"
+)
+
+// The methods in this file are the changes the server makes to profiles and
+// live files. Each validates its input, holds the tool's lock while it works,
+// and publishes what it changed, so every front end (the JSON API,
+// the HTML UI and background operations) behaves the same way. Front ends
+// only translate requests into these calls and errors into answers.
+
+// errUnsavedChanges is matched by an unsavedChangesError.
+var errUnsavedChanges = errors.New("live config has unsaved changes")
+
+// unsavedChangesError refuses a switch that would discard live changes not
+// saved in the active profile.
+type unsavedChangesError struct {
+	Profile string
+	Files   []profile.FileDiff
+}
+
+func (e *unsavedChangesError) Error() string {
+	return fmt.Sprintf("live config has changes not saved in profile %q (retry with force=true to discard them)", e.Profile)
+}
+
+func (e *unsavedChangesError) Unwrap() error { return errUnsavedChanges }
+
+// fileNames returns the names of the changed files.
+func (e *unsavedChangesError) fileNames() []string {
+	names := make([]string, len(e.Files))
+	for i, f := range e.Files {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// errInvalidFile is matched by an invalidFileError.
+var errInvalidFile = errors.New("invalid file")
+
+// invalidFileError refuses content that does not parse, or breaks the
+// file's schema.
+type invalidFileError struct {
+	File     string
+	Problems []profile.LintProblem
+}
+
+func (e *invalidFileError) Error() string { return e.File + " is not valid" }
+
+func (e *invalidFileError) Unwrap() error { return errInvalidFile }
+
+// checkSave validates the names of a save of name, optionally from the
+// stored profile from.
+func checkSave(name, from string) error {
+	if err := profile.ValidateProfileName(name); err != nil {
+		return err
+	}
+	if from != "" {
+		return profile.ValidateProfileName(from)
+	}
+	return nil
+}
+
+// saveProfile stores the live config, or with from a copy of that profile
+// with the live changes, as profile name.
+func (s *Server) saveProfile(tool profile.Tool, name, from string, force bool) error {
+	if err := checkSave(name, from); err != nil {
+		return err
+	}
+	unlock := s.toolLocks.lock(tool.Name)
+	defer unlock()
+	var err error
+	if from != "" {
+		err = profile.SaveFrom(tool, name, from, force)
+	} else {
+		err = profile.Save(tool, name, force)
+	}
+	if err != nil {
+		return err
+	}
+	s.publish(EventProfileSaved, tool.Name, name)
+	return nil
+}
+
+// adoptProfile saves the live config as name and makes it the active
+// profile.
+func (s *Server) adoptProfile(tool profile.Tool, name string) error {
+	if err := profile.ValidateProfileName(name); err != nil {
+		return err
+	}
+	unlock := s.toolLocks.lock(tool.Name)
+	defer unlock()
+	if err := profile.Adopt(tool, name); err != nil {
+		return err
+	}
+	s.publish(EventProfileSaved, tool.Name, name)
+	s.publish(EventProfileSwitched, tool.Name, name)
+	return nil
+}
+
+// checkSwitch validates a switch to name, refuses it with
+// profile.ErrProfileProtected unless tool confirms protected profiles and,
+// unless force is set, refuses it with an unsavedChangesError when the live
+// config has drifted from the active profile. Callers that act on the answer
+// hold the tool's lock.
+func (s *Server) checkSwitch(tool profile.Tool, name string, force bool) error {
+	if err := profile.ValidateProfileName(name); err != nil {
+		return err
+	}
+	if err := profile.CheckProtected(tool, name); err != nil {
+		return err
+	}
+	if force {
+		return nil
+	}
+	active, drift, err := s.driftedFiles(tool)
+	if err != nil {
+		return err
+	}
+	if len(drift) > 0 {
+		return &unsavedChangesError{Profile: active, Files: drift}
+	}
+	return nil
+}
+
+// switchProfile makes name the active profile; see checkSwitch.
+func (s *Server) switchProfile(tool profile.Tool, name string, force bool) error {
+	// Held from the drift check on, so no other request changes the live
+	// files before the switch.
+	unlock := s.toolLocks.lock(tool.Name)
+	defer unlock()
+	if err := s.checkSwitch(tool, name, force); err != nil {
+		return err
+	}
+	if err := profile.Switch(tool, name); err != nil {
+		return err
+	}
+	s.publish(EventProfileSwitched, tool.Name, name)
+	return nil
+}
+
+// driftedFiles returns the active profile of tool and the live files that
+// differ from it. Both are empty when no profile is active. Drift found is
+// published; see noticeDrift.
+func (s *Server) driftedFiles(tool profile.Tool) (string, []profile.FileDiff, error) {
+	status, err := profile.Current(tool)
+	if err != nil {
+		return "", nil, err
+	}
+	active, modified := strings.CutSuffix(status, " (modified)")
+	if !modified {
+		s.noticeDrift(tool.Name, "", nil)
+		return "", nil, nil
+	}
+	diffs, err := profile.Diff(tool, active)
+	if err != nil {
+		return "", nil, err
+	}
+	var drift []profile.FileDiff
+	for _, d := range diffs {
+		if d.Status != profile.FileUnchanged {
+			drift = append(drift, d)
+		}
+	}
+	s.noticeDrift(tool.Name, active, drift)
+	return active, drift, nil
+}
+
+// deleteProfile removes profile name. cleared reports whether it was the
+// active profile.
+func (s *Server) deleteProfile(tool profile.Tool, name string) (cleared bool, err error) {
+	if err := profile.ValidateProfileName(name); err != nil {
+		return false, err
+	}
+	unlock := s.toolLocks.lock(tool.Name)
+	defer unlock()
+	if cleared, err = profile.Delete(tool, name); err != nil {
+		return false, err
+	}
+	s.publish(EventProfileDeleted, tool.Name, name)
+	return cleared, nil
+}
+
+// writeProfileFile replaces the stored file of profile profileName with
+// data, refusing it with an invalidFileError when it is not valid.
+func (s *Server) writeProfileFile(tool profile.Tool, profileName, file string, data []byte) error {
+	if err := profile.ValidateProfileName(profileName); err != nil {
+		return err
+	}
+	problems, err := profile.ValidateFile(tool, file, data)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return &invalidFileError{File: file, Problems: problems}
+	}
+	unlock := s.toolLocks.lock(tool.Name)
+	defer unlock()
+	if err := profile.WriteFile(tool, profileName, file, data); err != nil {
+		return err
+	}
+	s.publish(EventProfileSaved, tool.Name, profileName)
+	return nil
+}
+
+// acceptLiveFile stores the live content of one drifted file in the active
+// profile, which it returns.
+func (s *Server) acceptLiveFile(tool profile.Tool, file string) (string, error) {
+	unlock := s.toolLocks.lock(tool.Name)
+	defer unlock()
+	active, err := profile.AcceptLiveFile(tool, file)
+	if err != nil {
+		return "", err
+	}
+	s.publish(EventProfileSaved, tool.Name, active)
+	return active, nil
+}
+
+// revertLiveFile puts back the active profile's copy of one drifted file,
+// and returns the active profile.
+func (s *Server) revertLiveFile(tool profile.Tool, file string) (string, error) {
+	unlock := s.toolLocks.lock(tool.Name)
+	defer unlock()
+	active, err := profile.RevertLiveFile(tool, file)
+	if err != nil {
+		return "", err
+	}
+	s.publish(EventProfileSwitched, tool.Name, active)
+	return active, nil
+}
@@ -0,0 +1,73 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestServiceSwitchRefusesUnsavedChanges(t *testing.T) {
+	home := t.TempDir()
+	live := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(live), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(live, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+	tool := profile.ClaudeTool().WithHome(home)
+	var logs bytes.Buffer
+	server := NewServer(WithTools(tool), WithLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+
+	if err := server.saveProfile(tool, "work", "", false); err != nil {
+		t.Fatalf("saveProfile: %v", err)
+	}
+	if err := server.saveProfile(tool, "home", "", false); err != nil {
+		t.Fatalf("saveProfile: %v", err)
+	}
+	if err := server.switchProfile(tool, "work", false); err != nil {
+		t.Fatalf("switchProfile: %v", err)
+	}
+	if err := os.WriteFile(live, []byte(`{"model":"opus"}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+
+	err := server.switchProfile(tool, "home", false)
+	var unsaved *unsavedChangesError
+	if !errors.As(err, &unsaved) || unsaved.Profile != "work" || strings.Join(unsaved.fileNames(), ",") != "settings.json" {
+		t.Fatalf("expected the unsaved change to settings.json to refuse the switch, got %v", err)
+	}
+	if errorStatus(err) != http.StatusConflict || errorCode(err) != CodeUnsavedChanges {
+		t.Fatalf("unsaved changes answer %d %q", errorStatus(err), errorCode(err))
+	}
+	if err := server.switchProfile(tool, "home", true); err != nil {
+		t.Fatalf("forced switchProfile: %v", err)
+	}
+	if err := server.switchProfile(tool, "../x", true); !errors.Is(err, profile.ErrInvalidProfileName) {
+		t.Fatalf("expected an invalid name to be refused, got %v", err)
+	}
+
+	if got := strings.Count(logs.String(), "msg=change"); got != 5 {
+		t.Fatalf("expected one log line per change and drift, got %d:\n%s", got, logs.String())
+	}
+}
+
+func TestServiceWriteFileRefusesInvalidContent(t *testing.T) {
+	tool := newTestTool(t)
+	server := NewServer(WithTools(tool))
+	if err := server.saveProfile(tool, "work", "", false); err != nil {
+		t.Fatalf("saveProfile: %v", err)
+	}
+	err := server.writeProfileFile(tool, "work", "settings.json", []byte(`{`))
+	var invalid *invalidFileError
+	if !errors.As(err, &invalid) || len(invalid.Problems) == 0 || errorCode(err) != CodeInvalidFile {
+		t.Fatalf("expected the content to be refused with its problems, got %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+const (
+	// DefaultShareTTL is how long a share link works when the request does
+	// not say.
+	DefaultShareTTL = 10 * time.Minute
+	// MaxShareTTL bounds the lifetime of share links.
+	MaxShareTTL = 24 * time.Hour
+	// MaxShares and MaxShareBytes bound the share links outstanding at a
+	// time, and the bundle bytes they hold in memory, so that handing out
+	// links cannot grow the server without bound.
+	MaxShares     = 100
+	MaxShareBytes = 64 << 20
+)
+
+// errSharesFull is returned by shares.add when MaxShares or MaxShareBytes
+// would be exceeded.
+var errSharesFull = errors.New("too many share links outstanding; wait for some to be downloaded or to expire")
+
+// ShareLink is a one-time link to an exported profile bundle.
+type ShareLink struct {
+	Profile string `json:"profile"`
+	URL     string `json:"url"`
+	// SHA256 is the hex digest of the bundle, for checking the download.
+	SHA256  string    `json:"sha256"`
+	Expires time.Time `json:"expires"`
+}
+
+// share is a bundle waiting to be downloaded once through its link.
+type share struct {
+	name    string
+	bundle  []byte
+	expires time.Time
+}
+
+// shares holds the share links a server has handed out. They live in
+// memory only, so a restart revokes them all.
+type shares struct {
+	mu    sync.Mutex
+	links map[string]share
+	bytes int
+}
+
+// add stores s under a new random token and returns the token. It fails
+// with errSharesFull when the links outstanding are at the limits.
+func (sh *shares) add(s share, now time.Time) (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	if sh.links == nil {
+		sh.links = make(map[string]share)
+	}
+	sh.purge(now)
+	if len(sh.links) >= MaxShares || sh.bytes+len(s.bundle) > MaxShareBytes {
+		return "", errSharesFull
+	}
+	sh.links[token] = s
+	sh.bytes += len(s.bundle)
+	return token, nil
+}
+
+// purge drops the expired links. sh.mu must be held.
+func (sh *shares) purge(now time.Time) {
+	for t, old := range sh.links {
+		if !now.Before(old.expires) {
+			sh.remove(t)
+		}
+	}
+}
+
+// remove drops the link of token. sh.mu must be held.
+func (sh *shares) remove(token string) {
+	sh.bytes -= len(sh.links[token].bundle)
+	delete(sh.links, token)
+}
+
+// take removes and returns the share of token, unless it has expired.
+func (sh *shares) take(token string, now time.Time) (share, bool) {
+	sh.mu.Lock()
+	defer sh.mu.Unlock()
+	s, ok := sh.links[token]
+	if ok {
+		sh.remove(token)
+	}
+	sh.purge(now)
+	return s, ok && now.Before(s.expires)
+}
+
+func (s *Server) shareRoutes() {
+	s.mux.HandleFunc("POST /api/{tool}/profiles/{profile}/share", s.handleShare)
+	s.mux.HandleFunc("GET /share/{token}", s.handleShareDownload)
+}
+
+// handleShare exports a profile into a one-time link. The link itself is
+// outside /api/ and needs no token: it is the credential, and it stops
+// working after one download or when it expires.
+func (s *Server) handleShare(w http.ResponseWriter, r *http.Request) {
+	if !s.authRequired() {
+		writeError(w, http.StatusForbidden, "sharing needs a server started with an auth token")
+		return
+	}
+	tool, ok := s.getTool(w, r)
+	if !ok {
+		return
+	}
+	profileName := r.PathValue("profile")
+	if !validProfileName(w, profileName) {
+		return
+	}
+
+	var req struct {
+		TTL string `json:"ttl"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	ttl := DefaultShareTTL
+	if req.TTL != "" {
+		d, err := time.ParseDuration(req.TTL)
+		if err != nil || d <= 0 || d > MaxShareTTL {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("ttl must be a duration up to %s", MaxShareTTL))
+			return
+		}
+		ttl = d
+	}
+
+	var bundle bytes.Buffer
+	if err := profile.Export(tool, []string{profileName}, &bundle); err != nil {
+		writeProfileError(w, err)
+		return
+	}
+	now := time.Now()
+	expires := now.Add(ttl).UTC().Truncate(time.Second)
+	token, err := s.shares.add(share{
+		name:    fmt.Sprintf("%s-%s.tar.gz", tool.Name, profileName),
+		bundle:  bundle.Bytes(),
+		expires: expires,
+	}, now)
+	if errors.Is(err, errSharesFull) {
+		writeErrorCode(w, http.StatusTooManyRequests, CodeTooManyShares, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	sum := sha256.Sum256(bundle.Bytes())
+	writeJSON(w, http.StatusCreated, ShareLink{
+		Profile: profileName,
+		URL:     s.externalURL(r, "/share/"+token),
+		SHA256:  hex.EncodeToString(sum[:]),
+		Expires: expires,
+	})
+}
+
+func (s *Server) handleShareDownload(w http.ResponseWriter, r *http.Request) {
+	sh, ok := s.shares.take(r.PathValue("token"), time.Now())
+	if !ok {
+		writeError(w, http.StatusNotFound, "share link is unknown, expired or already used")
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", sh.name))
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(sh.bundle)
+}
+
+// externalURL returns the absolute URL of path on this server as the client
+// of r reached it, through the base path and a TLS-terminating proxy.
+func (s *Server) externalURL(r *http.Request, path string) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + s.basePath + path
+}
@@ -0,0 +1,147 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+func TestShareLinkIsSingleUse(t *testing.T) {
+	tool := profile.ClaudeTool().WithHome(t.TempDir())
+	if err := profile.SaveFiles(tool, "work", map[string][]byte{"settings.json": []byte(`{"model":"opus"}`)}, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	server := NewServer(WithTools(tool), WithAuthToken("secret"), WithBasePath("/tokyo"))
+	do := func(method, path, body string, auth bool) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if auth {
+			req.Header.Set("Authorization", "Bearer secret")
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := do("POST", "/tokyo/api/claude/profiles/work/share", "", false); w.Code != http.StatusUnauthorized {
+		t.Fatalf("share without a token: expected 401, got %d", w.Code)
+	}
+	w := do("POST", "/tokyo/api/claude/profiles/work/share", `{"ttl":"5m"}`, true)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("share: expected 201, got %d: %s", w.Code, w.Body.String())
+	}
+	var link ShareLink
+	if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	path, ok := strings.CutPrefix(link.URL, "http://example.com/tokyo/share/")
+	if !ok {
+		t.Fatalf("unexpected url %q", link.URL)
+	}
+	if until := time.Until(link.Expires); until <= 4*time.Minute || until > 5*time.Minute {
+		t.Fatalf("expires in %s, want about 5m", until)
+	}
+
+	w = do("GET", "/tokyo/share/"+path, "", false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("download: expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if sum := sha256.Sum256(w.Body.Bytes()); hex.EncodeToString(sum[:]) != link.SHA256 {
+		t.Fatalf("sha256 %s does not match the download", link.SHA256)
+	}
+	info, err := profile.ReadBundleInfo(bytes.NewReader(w.Body.Bytes()))
+	if err != nil || info.Tool != "claude" || len(info.Profiles) != 1 || info.Profiles[0] != "work" {
+		t.Fatalf("bundle info = %+v, %v", info, err)
+	}
+	if w := do("GET", "/tokyo/share/"+path, "", false); w.Code != http.StatusNotFound {
+		t.Fatalf("second download: expected 404, got %d", w.Code)
+	}
+}
+
+func TestShareRequests(t *testing.T) {
+	tool := profile.ClaudeTool().WithHome(t.TempDir())
+	if err := profile.SaveFiles(tool, "work", map[string][]byte{"settings.json": []byte(`{}`)}, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	tests := []struct {
+		name   string
+		opts   []Option
+		path   string
+		body   string
+		status int
+	}{
+		{"no auth configured", nil, "/api/claude/profiles/work/share", "", http.StatusForbidden},
+		{"unknown profile", []Option{WithAuthToken("secret")}, "/api/claude/profiles/missing/share", "", http.StatusNotFound},
+		{"invalid name", []Option{WithAuthToken("secret")}, "/api/claude/profiles/..%2Fx/share", "", http.StatusBadRequest},
+		{"bad ttl", []Option{WithAuthToken("secret")}, "/api/claude/profiles/work/share", `{"ttl":"soon"}`, http.StatusBadRequest},
+		{"ttl too long", []Option{WithAuthToken("secret")}, "/api/claude/profiles/work/share", `{"ttl":"48h"}`, http.StatusBadRequest},
+		{"default ttl", []Option{WithAuthToken("secret")}, "/api/claude/profiles/work/share", "", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := NewServer(append([]Option{WithTools(tool)}, tt.opts...)...)
+			req := httptest.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer secret")
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, req)
+			if w.Code != tt.status {
+				t.Fatalf("expected %d, got %d: %s", tt.status, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestExpiredShareIsGone(t *testing.T) {
+	var sh shares
+	now := time.Now()
+	token, err := sh.add(share{bundle: []byte("x"), expires: now.Add(time.Minute)}, now)
+	if err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	if _, ok := sh.take(token, now.Add(2*time.Minute)); ok {
+		t.Fatal("expired share was handed out")
+	}
+	if _, ok := sh.take(token, now); ok {
+		t.Fatal("expired share was kept")
+	}
+}
+
+func TestSharesAreBounded(t *testing.T) {
+	var sh shares
+	now := time.Now()
+	for i := 0; i < MaxShares; i++ {
+		if _, err := sh.add(share{bundle: []byte("x"), expires: now.Add(time.Minute)}, now); err != nil {
+			t.Fatalf("add %d: %v", i, err)
+		}
+	}
+	if _, err := sh.add(share{bundle: []byte("x"), expires: now.Add(time.Minute)}, now); !errors.Is(err, errSharesFull) {
+		t.Fatalf("add over MaxShares: err = %v, want errSharesFull", err)
+	}
+
+	// Expired links make room again, and are purged on take as well.
+	later := now.Add(2 * time.Minute)
+	if _, ok := sh.take("unknown", later); ok {
+		t.Fatal("unknown token was handed out")
+	}
+	if len(sh.links) != 0 || sh.bytes != 0 {
+		t.Fatalf("expired links kept: %d links, %d bytes", len(sh.links), sh.bytes)
+	}
+	if _, err := sh.add(share{bundle: make([]byte, MaxShareBytes+1), expires: later.Add(time.Minute)}, later); !errors.Is(err, errSharesFull) {
+		t.Fatalf("add over MaxShareBytes: err = %v, want errSharesFull", err)
+	}
+	token, err := sh.add(share{bundle: make([]byte, MaxShareBytes), expires: later.Add(time.Minute)}, later)
+	if err != nil {
+		t.Fatalf("add up to MaxShareBytes: %v", err)
+	}
+	if _, ok := sh.take(token, later); !ok || sh.bytes != 0 {
+		t.Fatalf("take = %v, %d bytes left", ok, sh.bytes)
+	}
+}
@@ -2,19 +2,14 @@
 
 package api
 
-import (
-	"embed"
-	"io/fs"
-	"net/http"
-)
+import "net/http"
 
-//go:embed dist_placeholder/*
-var distFS embed.FS
+// staticHandler serves the HTML UI: this build has no web UI.
+func (s *Server) staticHandler() http.Handler {
+	return http.HandlerFunc(s.handleHTMLUI)
+}
 
-func staticHandler() http.Handler {
-	placeholder, err := fs.Sub(distFS, "dist_placeholder")
-	if err != nil {
-		return http.NotFoundHandler()
-	}
-	return http.FileServer(http.FS(placeholder))
+// hasWebUI reports whether this build serves the web UI at /.
+func hasWebUI() bool {
+	return false
 }
@@ -8,19 +8,35 @@ import (
 	"net/http"
 )
 
-//go:embed dist_placeholder/* dist/*
+// dist is the web UI built by `npm run build --prefix web`; building with
+// the embedui tag needs it.
+//
+//go:embed all:dist
 var distFS embed.FS
 
-func staticHandler() http.Handler {
-	if dist, err := fs.Sub(distFS, "dist"); err == nil {
-		if _, err := fs.Stat(dist, "index.html"); err == nil {
-			return http.FileServer(http.FS(dist))
-		}
+// webUI returns the embedded web UI, if dist holds a build of it.
+func webUI() (fs.FS, bool) {
+	dist, err := fs.Sub(distFS, "dist")
+	if err != nil {
+		return nil, false
+	}
+	if _, err := fs.Stat(dist, "index.html"); err != nil {
+		return nil, false
 	}
+	return dist, true
+}
 
-	placeholder, err := fs.Sub(distFS, "dist_placeholder")
-	if err != nil {
-		return http.NotFoundHandler()
+// staticHandler serves the web UI, or the HTML UI when dist holds no build
+// of it.
+func (s *Server) staticHandler() http.Handler {
+	if dist, ok := webUI(); ok {
+		return http.FileServer(http.FS(dist))
 	}
-	return http.FileServer(http.FS(placeholder))
+	return http.HandlerFunc(s.handleHTMLUI)
+}
+
+// hasWebUI reports whether this build serves the web UI at /.
+func hasWebUI() bool {
+	_, ok := webUI()
+	return ok
 }
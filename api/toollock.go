@@ -0,0 +1,31 @@
+package api
+
+import "sync"
+
+// toolLocks serializes the changes the server makes to each tool. Two
+// switches of the same tool running at once would interleave their renames
+// and leave the live config a mix of both profiles, and a switch could act
+// on a drift check made before another request changed the live files.
+// Other processes are not held off by it.
+type toolLocks struct {
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// lock waits until no other request changes tool and returns the func that
+// lets the next one in.
+func (l *toolLocks) lock(tool string) (unlock func()) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*sync.Mutex)
+	}
+	m, ok := l.locks[tool]
+	if !ok {
+		m = new(sync.Mutex)
+		l.locks[tool] = m
+	}
+	l.mu.Unlock()
+
+	m.Lock()
+	return m.Unlock
+}
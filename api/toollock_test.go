@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+func TestConcurrentSwitchesAreSerialized(t *testing.T) {
+	home := t.TempDir()
+	tool := profile.CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	for _, name := range []string{"work", "home"} {
+		files := map[string][]byte{
+			"auth.json":   []byte(`{"OPENAI_API_KEY":"sk-` + name + `"}`),
+			"config.toml": []byte("model = \"" + name + "\"\n"),
+		}
+		if err := profile.SaveFiles(tool, name, files, false); err != nil {
+			t.Fatalf("SaveFiles: %v", err)
+		}
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	server := NewServer(WithTools(tool))
+
+	const switches = 40
+	var wg sync.WaitGroup
+	failures := make(chan string, switches)
+	for i := range switches {
+		name := []string{"work", "home"}[i%2]
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w := httptest.NewRecorder()
+			server.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/codex/switch/"+name, nil))
+			if w.Code != http.StatusOK {
+				failures <- fmt.Sprintf("switch to %s: %d %s", name, w.Code, w.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	close(failures)
+	for f := range failures {
+		t.Error(f)
+	}
+
+	// Every switch ran whole: the live files are one profile's, the one
+	// recorded as current, and no staged file was left behind.
+	current, err := profile.Current(tool)
+	if err != nil || (current != "work" && current != "home") {
+		t.Fatalf("current = %q, %v", current, err)
+	}
+	for file, want := range map[string]string{"auth.json": "sk-" + current, "config.toml": `"` + current + `"`} {
+		data, err := os.ReadFile(filepath.Join(codexDir, file))
+		if err != nil || !strings.Contains(string(data), want) {
+			t.Fatalf("%s = %q, %v; want %s's", file, data, err, current)
+		}
+	}
+	entries, err := os.ReadDir(codexDir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".tokyo-") {
+			t.Fatalf("left behind %s", e.Name())
+		}
+	}
+}
+
+func TestToolLocks(t *testing.T) {
+	var locks toolLocks
+	unlock := locks.lock("claude")
+
+	// Another tool is not held up.
+	locks.lock("codex")()
+
+	acquired := make(chan struct{})
+	go func() {
+		locks.lock("claude")()
+		close(acquired)
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("the same tool was locked twice")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	select {
+	case <-acquired:
+	case <-time.After(time.Second):
+		t.Fatal("unlock did not let the waiting request in")
+	}
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+)
+
+// UIConfig holds the defaults the web UI starts from. A browser's own
+// choices, kept in its local storage, win over them.
+type UIConfig struct {
+	// Theme is "system", "dark" or "light".
+	Theme string `json:"theme"`
+	// Tools are the tools the request's token may use, in display order.
+	Tools []UITool `json:"tools"`
+	// ReadOnly is set when the UI can only look, so it hides the buttons
+	// that would be refused.
+	ReadOnly bool `json:"readOnly"`
+}
+
+// UITool names a tool in the web UI.
+type UITool struct {
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// WithTheme sets the web UI's default theme: "system" (the default), "dark"
+// or "light".
+func WithTheme(theme string) Option {
+	return func(s *Server) {
+		s.theme = theme
+	}
+}
+
+func (s *Server) handleUIConfig(w http.ResponseWriter, r *http.Request) {
+	cfg := UIConfig{Theme: s.theme, Tools: []UITool{}, ReadOnly: s.readOnly}
+	if cfg.Theme == "" {
+		cfg.Theme = "system"
+	}
+	tok, hasToken := requestToken(r)
+	if hasToken && tok.ReadOnly() {
+		cfg.ReadOnly = true
+	}
+	for name, tool := range s.tools {
+		if hasToken && !tok.AllowsTool(name) {
+			continue
+		}
+		cfg.Tools = append(cfg.Tools, UITool{Name: name, DisplayName: tool.DisplayName})
+	}
+	sort.Slice(cfg.Tools, func(i, j int) bool { return cfg.Tools[i].Name < cfg.Tools[j].Name })
+	writeJSON(w, http.StatusOK, cfg)
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"tokyo/pkg/profile"
+	"tokyo/pkg/token"
+)
+
+func TestUIConfig(t *testing.T) {
+	home := t.TempDir()
+	tools := []profile.Tool{profile.ClaudeTool().WithHome(home), profile.CodexTool().WithHome(home)}
+	get := func(server *Server, secret string) UIConfig {
+		t.Helper()
+		req := httptest.NewRequest("GET", "/api/ui-config", nil)
+		if secret != "" {
+			req.Header.Set("Authorization", "Bearer "+secret)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+		}
+		var cfg UIConfig
+		if err := json.Unmarshal(w.Body.Bytes(), &cfg); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return cfg
+	}
+
+	cfg := get(NewServer(WithTools(tools...)), "")
+	if cfg.Theme != "system" || cfg.ReadOnly || len(cfg.Tools) != 2 {
+		t.Fatalf("unexpected defaults %+v", cfg)
+	}
+	if cfg.Tools[0] != (UITool{Name: "claude", DisplayName: tools[0].DisplayName}) || cfg.Tools[1].Name != "codex" {
+		t.Fatalf("unexpected tools %+v", cfg.Tools)
+	}
+	if cfg := get(NewServer(WithTools(tools...), WithTheme("light"), WithReadOnly(true)), ""); cfg.Theme != "light" || !cfg.ReadOnly {
+		t.Fatalf("expected light and read-only, got %+v", cfg)
+	}
+
+	root := t.TempDir()
+	tokens, err := token.Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	reader, err := tokens.Create("dashboard", token.ScopeRead, []string{"codex"}, time.Time{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	cfg = get(NewServer(WithTools(tools...), WithTokens(root)), reader)
+	if !cfg.ReadOnly || len(cfg.Tools) != 1 || cfg.Tools[0].Name != "codex" {
+		t.Fatalf("expected a read-only codex-only config for the token, got %+v", cfg)
+	}
+}
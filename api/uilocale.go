@@ -0,0 +1,35 @@
+package api
+
+import (
+	"net/http"
+
+	"tokyo/pkg/i18n"
+)
+
+// UILocale is the web UI's message catalog for one language.
+type UILocale struct {
+	Language string `json:"language"`
+	// Languages are the languages the UI can be shown in.
+	Languages []string `json:"languages"`
+	// Messages translates the UI's English strings; missing ones stay in
+	// English.
+	Messages map[string]string `json:"messages"`
+}
+
+// handleUILocale serves the UI's catalog for the language in ?lang=, a
+// setting of the UI, or else the one the browser's Accept-Language prefers.
+func (s *Server) handleUILocale(w http.ResponseWriter, r *http.Request) {
+	lang := r.URL.Query().Get("lang")
+	if lang == "" {
+		lang = i18n.Negotiate(r.Header.Get("Accept-Language"))
+	} else if !i18n.Supported(lang) {
+		writeError(w, http.StatusBadRequest, "unsupported language "+lang)
+		return
+	}
+	w.Header().Set("Vary", "Accept-Language")
+	writeJSON(w, http.StatusOK, UILocale{
+		Language:  lang,
+		Languages: i18n.Languages(),
+		Messages:  i18n.UIMessages(lang),
+	})
+}
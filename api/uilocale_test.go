@@ -0,0 +1,39 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestUILocale(t *testing.T) {
+	server := NewServer(WithTools(newTestTool(t)))
+	get := func(path, acceptLanguage string) (int, UILocale) {
+		t.Helper()
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Accept-Language", acceptLanguage)
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		var locale UILocale
+		if w.Code == http.StatusOK {
+			if err := json.Unmarshal(w.Body.Bytes(), &locale); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return w.Code, locale
+	}
+
+	if code, locale := get("/api/ui-locale", "ja-JP,ja;q=0.9,en;q=0.8"); code != http.StatusOK || locale.Language != "ja" || locale.Messages["Switch"] == "" {
+		t.Fatalf("expected the Japanese catalog, got %d %+v", code, locale)
+	}
+	if code, locale := get("/api/ui-locale?lang=en", "ja"); code != http.StatusOK || locale.Language != "en" || len(locale.Messages) != 0 {
+		t.Fatalf("expected ?lang= to win with an empty English catalog, got %d %+v", code, locale)
+	}
+	if code, locale := get("/api/ui-locale", "fr"); code != http.StatusOK || locale.Language != "en" || len(locale.Languages) < 2 {
+		t.Fatalf("expected English for an unsupported browser language, got %d %+v", code, locale)
+	}
+	if code, _ := get("/api/ui-locale?lang=fr", ""); code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for an unsupported ?lang=, got %d", code)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// The API is served under APIPrefix. The unversioned /api/ paths are an
+// alias of the current version kept for one release; their answers point at
+// the versioned path. A version that changes the shape of responses gets a
+// new prefix, so an old client fails loudly instead of misreading answers.
+const (
+	// APIVersion is the version of the API this server speaks.
+	APIVersion = "1"
+	// APIPrefix is the path the routes of APIVersion are served under.
+	APIPrefix = "/api/v" + APIVersion
+	// APIVersionHeader names the request header a client states the version it
+	// expects with. Answers carry it with the version that served them.
+	APIVersionHeader = "Tokyo-Api-Version"
+)
+
+// versionMiddleware maps APIPrefix onto the routes, registered under /api/,
+// and refuses versions this server does not speak.
+func (s *Server) versionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isAPIPath(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set(APIVersionHeader, APIVersion)
+
+		segment, _, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/api/"), "/")
+		pathVersion, versioned := strings.CutPrefix(segment, "v")
+		if versioned && (pathVersion == "" || strings.Trim(pathVersion, "0123456789") != "") {
+			versioned = false
+		}
+		if versioned && pathVersion != APIVersion {
+			writeErrorCode(w, http.StatusNotFound, CodeUnsupportedVersion, unsupportedVersion(pathVersion))
+			return
+		}
+		if want := r.Header.Get(APIVersionHeader); want != "" && want != APIVersion {
+			writeErrorCode(w, http.StatusBadRequest, CodeUnsupportedVersion, unsupportedVersion(want))
+			return
+		}
+
+		if !versioned {
+			successor := s.basePath + APIPrefix + strings.TrimPrefix(r.URL.EscapedPath(), "/api")
+			w.Header().Set("Deprecation", "true")
+			w.Header().Add("Link", fmt.Sprintf("<%s>; rel=\"successor-version\"", successor))
+			next.ServeHTTP(w, r)
+			return
+		}
+		r2 := new(http.Request)
+		*r2 = *r
+		u := *r.URL
+		r2.URL = &u
+		r2.URL.Path = "/api" + strings.TrimPrefix(r.URL.Path, APIPrefix)
+		if r.URL.RawPath != "" {
+			r2.URL.RawPath = "/api" + strings.TrimPrefix(r.URL.RawPath, APIPrefix)
+		}
+		next.ServeHTTP(w, r2)
+	})
+}
+
+func unsupportedVersion(version string) string {
+	return fmt.Sprintf("unsupported API version %q: this server speaks version %s", version, APIVersion)
+}
@@ -0,0 +1,55 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestAPIVersioning(t *testing.T) {
+	tool := newTestTool(t)
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	server := NewServer(WithTools(tool), WithBasePath("/tokyo"))
+	get := func(path, version string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", path, nil)
+		if version != "" {
+			req.Header.Set(APIVersionHeader, version)
+		}
+		w := httptest.NewRecorder()
+		server.ServeHTTP(w, req)
+		return w
+	}
+
+	w := get("/tokyo/api/v1/claude/profiles", "")
+	if w.Code != http.StatusOK || w.Header().Get(APIVersionHeader) != APIVersion {
+		t.Fatalf("versioned path: expected 200 with the version header, got %d %q: %s", w.Code, w.Header().Get(APIVersionHeader), w.Body.String())
+	}
+	if w.Header().Get("Deprecation") != "" {
+		t.Fatalf("versioned path must not be marked deprecated")
+	}
+
+	w = get("/tokyo/api/claude/profiles", "")
+	if w.Code != http.StatusOK {
+		t.Fatalf("unversioned alias: expected 200, got %d", w.Code)
+	}
+	if w.Header().Get("Deprecation") != "true" || w.Header().Get("Link") != `</tokyo/api/v1/claude/profiles>; rel="successor-version"` {
+		t.Fatalf("unversioned alias: expected deprecation headers, got %v", w.Header())
+	}
+
+	if w := get("/tokyo/api/v1/claude/profiles", "1"); w.Code != http.StatusOK {
+		t.Fatalf("matching version header: expected 200, got %d", w.Code)
+	}
+	if w := get("/tokyo/api/v1/claude/profiles", "2"); w.Code != http.StatusBadRequest {
+		t.Fatalf("unsupported version header: expected 400, got %d", w.Code)
+	}
+	if w := get("/tokyo/api/v2/claude/profiles", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("unsupported path version: expected 404, got %d", w.Code)
+	}
+	if w := get("/tokyo/api/v1/ui-config", ""); w.Code != http.StatusOK {
+		t.Fatalf("versioned ui-config: expected 200, got %d", w.Code)
+	}
+}
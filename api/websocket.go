@@ -0,0 +1,252 @@
+package api
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WebSocketProtocol is the subprotocol /api/ws speaks: one JSON Event per
+// text message, server to client.
+const WebSocketProtocol = "tokyo.v1"
+
+// webSocketTokenPrefix marks the subprotocol a browser passes its bearer
+// token in, since it cannot set headers on a WebSocket handshake.
+const webSocketTokenPrefix = "bearer."
+
+const (
+	wsGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	wsOpText  = 0x1
+	wsOpClose = 0x8
+	wsOpPing  = 0x9
+	wsOpPong  = 0xa
+
+	// Clients only send control frames; anything larger is refused.
+	wsMaxPayload  = 64 << 10
+	wsWriteWait   = 10 * time.Second
+	wsCloseNormal = 1000
+	wsCloseAway   = 1001
+)
+
+// handleWebSocket streams the events of /api/events over a WebSocket, for
+// networks whose proxies buffer or cut server-sent events. The server pings
+// every eventKeepAlive and drops clients that stay silent for two.
+func (s *Server) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !headerContains(r.Header, "Connection", "upgrade") || !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		w.Header().Set("Upgrade", "websocket")
+		writeError(w, http.StatusUpgradeRequired, "expected a WebSocket handshake")
+		return
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		writeError(w, http.StatusUpgradeRequired, "unsupported WebSocket version")
+		return
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		writeError(w, http.StatusBadRequest, "missing Sec-WebSocket-Key")
+		return
+	}
+
+	tok, restricted := requestToken(r)
+	ch := s.events.subscribe()
+	defer s.events.unsubscribe(ch)
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	defer netConn.Close()
+	// The connection outlives the server's read and write timeouts by design.
+	_ = netConn.SetDeadline(time.Time{})
+
+	sum := sha1.Sum([]byte(key + wsGUID))
+	fmt.Fprintf(rw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n",
+		base64.StdEncoding.EncodeToString(sum[:]))
+	if headerContains(r.Header, "Sec-WebSocket-Protocol", WebSocketProtocol) {
+		fmt.Fprintf(rw, "Sec-WebSocket-Protocol: %s\r\n", WebSocketProtocol)
+	}
+	fmt.Fprint(rw, "\r\n")
+	if err := rw.Flush(); err != nil {
+		return
+	}
+
+	conn := &wsConn{conn: netConn, r: rw.Reader}
+	conn.touch()
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		conn.readLoop()
+	}()
+
+	ticker := time.NewTicker(eventKeepAlive)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-closed:
+			return
+		case <-ticker.C:
+			if time.Since(conn.lastSeen()) > 2*eventKeepAlive {
+				conn.writeClose(wsCloseAway)
+				return
+			}
+			if err := conn.write(wsOpPing, nil); err != nil {
+				return
+			}
+		case ev := <-ch:
+			if restricted && !tok.AllowsTool(ev.Tool) {
+				continue
+			}
+			data, err := json.Marshal(ev)
+			if err != nil {
+				continue
+			}
+			if err := conn.write(wsOpText, data); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// headerContains reports whether the comma-separated header name lists
+// value, ignoring case.
+func headerContains(h http.Header, name, value string) bool {
+	for _, line := range h.Values(name) {
+		for _, v := range strings.Split(line, ",") {
+			if strings.EqualFold(strings.TrimSpace(v), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// webSocketToken returns the bearer token a WebSocket handshake carries as
+// a "bearer.<token>" subprotocol.
+func webSocketToken(r *http.Request) (string, bool) {
+	for _, line := range r.Header.Values("Sec-WebSocket-Protocol") {
+		for _, v := range strings.Split(line, ",") {
+			if secret, ok := strings.CutPrefix(strings.TrimSpace(v), webSocketTokenPrefix); ok && secret != "" {
+				return secret, true
+			}
+		}
+	}
+	return "", false
+}
+
+// wsConn is the server end of a WebSocket connection (RFC 6455) that only
+// ever sends unfragmented frames.
+type wsConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+
+	mu   sync.Mutex // serializes writes
+	seen atomic.Int64
+}
+
+func (c *wsConn) touch() { c.seen.Store(time.Now().UnixNano()) }
+
+func (c *wsConn) lastSeen() time.Time { return time.Unix(0, c.seen.Load()) }
+
+func (c *wsConn) write(op byte, payload []byte) error {
+	header := []byte{0x80 | op}
+	switch n := len(payload); {
+	case n < 126:
+		header = append(header, byte(n))
+	case n <= 0xffff:
+		header = append(header, 126)
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header = append(header, 127)
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_ = c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	bufs := net.Buffers{header, payload}
+	_, err := bufs.WriteTo(c.conn)
+	return err
+}
+
+func (c *wsConn) writeClose(code uint16) {
+	_ = c.write(wsOpClose, binary.BigEndian.AppendUint16(nil, code))
+}
+
+// readLoop answers the client's pings and close until the connection ends.
+// Messages from the client mean nothing and are dropped.
+func (c *wsConn) readLoop() {
+	for {
+		op, payload, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		c.touch()
+		switch op {
+		case wsOpPing:
+			if c.write(wsOpPong, payload) != nil {
+				return
+			}
+		case wsOpClose:
+			c.writeClose(wsCloseNormal)
+			return
+		}
+	}
+}
+
+var errWSProtocol = errors.New("websocket: protocol error")
+
+func (c *wsConn) readFrame() (byte, []byte, error) {
+	var head [2]byte
+	if _, err := io.ReadFull(c.r, head[:]); err != nil {
+		return 0, nil, err
+	}
+	op := head[0] & 0x0f
+	if head[1]&0x80 == 0 {
+		// Clients must mask every frame.
+		return 0, nil, errWSProtocol
+	}
+	n := uint64(head[1] & 0x7f)
+	switch n {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.r, ext[:]); err != nil {
+			return 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(ext[:])
+	}
+	if n > wsMaxPayload {
+		return 0, nil, errWSProtocol
+	}
+	var mask [4]byte
+	if _, err := io.ReadFull(c.r, mask[:]); err != nil {
+		return 0, nil, err
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(c.r, payload); err != nil {
+		return 0, nil, err
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return op, payload, nil
+}
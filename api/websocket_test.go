@@ -0,0 +1,133 @@
+package api
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// dialWebSocket opens /api/ws on srv with a raw handshake and returns the
+// connection and the handshake response.
+func dialWebSocket(t *testing.T, srv *httptest.Server, protocols string) (net.Conn, *bufio.Reader, *http.Response) {
+	t.Helper()
+	conn, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	req, _ := http.NewRequest("GET", srv.URL+"/api/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	if protocols != "" {
+		req.Header.Set("Sec-WebSocket-Protocol", protocols)
+	}
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("handshake: %v", err)
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		t.Fatalf("read handshake: %v", err)
+	}
+	return conn, br, resp
+}
+
+// writeClientFrame sends a masked frame, as browsers do.
+func writeClientFrame(t *testing.T, conn net.Conn, op byte, payload []byte) {
+	t.Helper()
+	mask := [4]byte{1, 2, 3, 4}
+	frame := []byte{0x80 | op, 0x80 | byte(len(payload))}
+	frame = append(frame, mask[:]...)
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+	if _, err := conn.Write(frame); err != nil {
+		t.Fatalf("write frame: %v", err)
+	}
+}
+
+func readServerFrame(t *testing.T, conn net.Conn, br *bufio.Reader) (byte, []byte) {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	var head [2]byte
+	if _, err := io.ReadFull(br, head[:]); err != nil {
+		t.Fatalf("read frame: %v", err)
+	}
+	if head[1]&0x80 != 0 {
+		t.Fatalf("server frames must not be masked")
+	}
+	n := int(head[1] & 0x7f)
+	if n == 126 {
+		var ext [2]byte
+		io.ReadFull(br, ext[:])
+		n = int(binary.BigEndian.Uint16(ext[:]))
+	}
+	payload := make([]byte, n)
+	if _, err := io.ReadFull(br, payload); err != nil {
+		t.Fatalf("read payload: %v", err)
+	}
+	return head[0] & 0x0f, payload
+}
+
+func TestWebSocketEvents(t *testing.T) {
+	srv := httptest.NewServer(NewServer(WithTools(newTestTool(t)), WithAuthToken("secret")))
+	defer srv.Close()
+
+	if _, _, resp := dialWebSocket(t, srv, WebSocketProtocol); resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 without a token, got %s", resp.Status)
+	}
+	conn, br, resp := dialWebSocket(t, srv, WebSocketProtocol+", bearer.secret")
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %s", resp.Status)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Accept"); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Fatalf("Sec-WebSocket-Accept = %q", got)
+	}
+	if got := resp.Header.Get("Sec-WebSocket-Protocol"); got != WebSocketProtocol {
+		t.Fatalf("Sec-WebSocket-Protocol = %q, want %q and never the token", got, WebSocketProtocol)
+	}
+
+	writeClientFrame(t, conn, wsOpPing, []byte("hi"))
+	if op, payload := readServerFrame(t, conn, br); op != wsOpPong || string(payload) != "hi" {
+		t.Fatalf("expected pong hi, got op %d %q", op, payload)
+	}
+
+	req, _ := http.NewRequest("POST", srv.URL+"/api/claude/profiles", strings.NewReader(`{"profile":"work"}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	saved, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	saved.Body.Close()
+	if saved.StatusCode != http.StatusCreated && saved.StatusCode != http.StatusOK {
+		t.Fatalf("save: %s", saved.Status)
+	}
+	op, payload := readServerFrame(t, conn, br)
+	var ev Event
+	if op != wsOpText || json.Unmarshal(payload, &ev) != nil || ev.Type != EventProfileSaved || ev.Profile != "work" {
+		t.Fatalf("expected a saved event, got op %d %s", op, payload)
+	}
+
+	writeClientFrame(t, conn, wsOpClose, []byte{0x03, 0xe8})
+	if op, _ := readServerFrame(t, conn, br); op != wsOpClose {
+		t.Fatalf("expected the close to be answered, got op %d", op)
+	}
+}
+
+func TestWebSocketRequiresUpgrade(t *testing.T) {
+	server := NewServer(WithTools(newTestTool(t)))
+	w := httptest.NewRecorder()
+	server.ServeHTTP(w, httptest.NewRequest("GET", "/api/ws", nil))
+	if w.Code != http.StatusUpgradeRequired {
+		t.Fatalf("expected 426, got %d", w.Code)
+	}
+}
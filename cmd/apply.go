@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/manifest"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+// defaultManifest is the manifest plan and apply read without --manifest,
+// so a dotfile repository can keep it at its root.
+const defaultManifest = "tokyo.yaml"
+
+func init() {
+	rootCmd.AddCommand(newPlanCommand(), newApplyCommand())
+}
+
+func newPlanCommand() *cobra.Command {
+	var manifestPath string
+	var detailedExitCode bool
+
+	cmd := &cobra.Command{
+		Use:   "plan [--manifest <file>]",
+		Short: i18n.T("Show what apply would change to reach a manifest"),
+		Long: `Compare the state a manifest declares with the store and the live config and
+list the changes 'tokyo apply' would make, without making them.
+
+With --detailed-exitcode, plan exits with 2 when there are changes and 0
+when there are none, for use in scripts and CI.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := manifest.Load(manifestPath)
+			if err != nil {
+				return err
+			}
+			changes, err := manifest.Plan(manifestTools(cmd), m)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if len(changes) == 0 {
+				fmt.Fprintln(out, "No changes")
+				return nil
+			}
+			for _, c := range changes {
+				fmt.Fprintf(out, "%s %s\n", planSymbol(c.Action), describeChange(c))
+			}
+			fmt.Fprintf(out, "Plan: %d change(s)\n", len(changes))
+			if detailedExitCode {
+				cmd.SilenceUsage = true
+				return &ExitError{Code: 2}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&manifestPath, "manifest", "m", defaultManifest, "Manifest declaring profiles and active profiles")
+	cmd.Flags().BoolVar(&detailedExitCode, "detailed-exitcode", false, "Exit with 2 when there are changes")
+
+	return cmd
+}
+
+func newApplyCommand() *cobra.Command {
+	var manifestPath string
+	var confirmProduction bool
+
+	cmd := &cobra.Command{
+		Use:   "apply [--manifest <file>]",
+		Short: i18n.T("Converge profiles and active profiles to a manifest"),
+		Long: `Create or update the profiles a YAML manifest declares and switch each tool
+to its declared active profile, reporting every change made. Running apply
+again with the same manifest changes nothing, so it can be run from
+configuration management such as Ansible or cloud-init, or from a shared
+dotfile repository holding tokyo.yaml.
+
+  tools:
+    claude:
+      active: work
+      profiles:
+        work:
+          files:
+            settings.json:
+              content: |
+                {"model": "opus"}
+        personal:
+          files:
+            settings.json:
+              path: claude/personal.json   # relative to the manifest
+    codex:
+      active: personal                     # an existing profile
+
+A declared profile lists every config file of its tool. Profiles the
+manifest does not list are left alone. Switching overwrites changes made to
+the live config since the last switch; they are autosaved first.
+
+Apply is all or nothing: profiles are written first and tools switched
+after; if any step fails, every change already made is rolled back. Use
+'tokyo plan' to see the changes first. Switching to a profile tagged protected
+takes --confirm-production.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			m, err := manifest.Load(manifestPath)
+			if err != nil {
+				return err
+			}
+			tools := manifestTools(cmd)
+			if confirmProduction {
+				for i, t := range tools {
+					tools[i] = t.WithProtectedConfirmed()
+				}
+			}
+			changes, err := manifest.Apply(tools, m)
+			if err != nil {
+				return err
+			}
+			out := cmd.OutOrStdout()
+			if len(changes) == 0 {
+				fmt.Fprintln(out, "No changes")
+			}
+			printChanges(out, changes)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&manifestPath, "manifest", "m", defaultManifest, "Manifest declaring profiles and active profiles")
+	cmd.Flags().BoolVar(&confirmProduction, "confirm-production", false, "Switch to profiles tagged protected")
+
+	return cmd
+}
+
+// manifestTools returns every built-in tool as cliTool configures it; a
+// manifest names the tools it manages.
+func manifestTools(cmd *cobra.Command) []profile.Tool {
+	tools := allTools()
+	for i, t := range tools {
+		tools[i] = cliTool(cmd, t)
+	}
+	return tools
+}
+
+func printChanges(w io.Writer, changes []manifest.Change) {
+	for _, c := range changes {
+		switch c.Action {
+		case manifest.ActionCreate:
+			fmt.Fprintf(w, "%s: created profile %s\n", c.Tool, c.Profile)
+		case manifest.ActionUpdate:
+			fmt.Fprintf(w, "%s: updated profile %s\n", c.Tool, c.Profile)
+		case manifest.ActionSwitch:
+			fmt.Fprintf(w, "%s: switched to %s\n", c.Tool, c.Profile)
+		}
+	}
+}
+
+func planSymbol(action string) string {
+	switch action {
+	case manifest.ActionCreate:
+		return "+"
+	case manifest.ActionUpdate:
+		return "~"
+	}
+	return ">"
+}
+
+// describeChange describes a planned change.
+func describeChange(c manifest.Change) string {
+	switch c.Action {
+	case manifest.ActionCreate:
+		return fmt.Sprintf("%s: create profile %s", c.Tool, c.Profile)
+	case manifest.ActionUpdate:
+		return fmt.Sprintf("%s: update profile %s", c.Tool, c.Profile)
+	}
+	switch {
+	case c.Drifted:
+		return fmt.Sprintf("%s: switch to %s (live config has drifted)", c.Tool, c.Profile)
+	case c.From == "":
+		return fmt.Sprintf("%s: switch to %s (no active profile)", c.Tool, c.Profile)
+	case c.From == c.Profile:
+		return fmt.Sprintf("%s: switch to %s (profile changed)", c.Tool, c.Profile)
+	}
+	return fmt.Sprintf("%s: switch %s -> %s", c.Tool, c.From, c.Profile)
+}
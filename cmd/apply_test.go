@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPlanAndApplyManifest(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	manifestPath := filepath.Join(t.TempDir(), "tokyo.yaml")
+	manifest := "tools:\n  claude:\n    active: work\n    profiles:\n      work:\n        files:\n          settings.json:\n            content: '{}'\n"
+	if err := os.WriteFile(manifestPath, []byte(manifest), 0o600); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	oldOut := rootCmd.OutOrStdout()
+	t.Cleanup(func() {
+		rootCmd.SetOut(oldOut)
+		rootCmd.SetArgs(nil)
+	})
+
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(args)
+		err := Execute()
+		return out.String(), err
+	}
+
+	out, err := run("plan", "--manifest", manifestPath, "--detailed-exitcode")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 2 {
+		t.Fatalf("plan with changes: err = %v, want exit status 2", err)
+	}
+	if want := "+ claude: create profile work\n> claude: switch to work (no active profile)\nPlan: 2 change(s)\n"; out != want {
+		t.Fatalf("plan output:\n%s\nwant:\n%s", out, want)
+	}
+
+	out, err = run("apply", "--manifest", manifestPath)
+	if err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if !strings.Contains(out, "claude: switched to work") {
+		t.Fatalf("apply output:\n%s", out)
+	}
+	if data, err := os.ReadFile(filepath.Join(home, ".claude", "settings.json")); err != nil || string(data) != "{}" {
+		t.Fatalf("live settings = %q, %v", data, err)
+	}
+
+	out, err = run("plan", "--manifest", manifestPath, "--detailed-exitcode")
+	if err != nil || out != "No changes\n" {
+		t.Fatalf("plan after apply = %q, %v", out, err)
+	}
+}
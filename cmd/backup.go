@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newBackupCommand(), newRestoreCommand())
+}
+
+func newBackupCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "backup",
+		Short: i18n.T("Back up the profile stores of every tool"),
+		Long: `Write every tool's store — profiles, metadata, blobs, store settings and the
+current profile — to a tar.gz archive that 'tokyo restore' can read.
+
+The archive is written to tokyo-backup.tar.gz unless -o is given; use -o - for
+stdout.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var w io.Writer = cmd.OutOrStdout()
+			var file *os.File
+			if output != "-" {
+				var err error
+				file, err = os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+				if err != nil {
+					return err
+				}
+				w = file
+			}
+
+			if err := profile.Backup(allTools(), w); err != nil {
+				if file != nil {
+					file.Close()
+					os.Remove(output)
+				}
+				return err
+			}
+			if file != nil {
+				if err := file.Close(); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Wrote backup to %s\n", output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "tokyo-backup.tar.gz", "Output file (- for stdout)")
+
+	return cmd
+}
+
+func newRestoreCommand() *cobra.Command {
+	var merge bool
+	var replace bool
+
+	cmd := &cobra.Command{
+		Use:   "restore <backup> [--merge | --replace]",
+		Short: i18n.T("Restore profile stores from a backup"),
+		Long: `Restore a backup created by 'tokyo backup'. Use - to read from stdin.
+
+--merge (the default) adds profiles that do not exist yet and leaves existing
+ones, and the current profile, untouched. --replace discards each backed-up
+tool's store and puts the one from the backup in its place.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r io.Reader = cmd.InOrStdin()
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				r = file
+			}
+
+			mode := profile.RestoreMerge
+			if replace {
+				mode = profile.RestoreReplace
+			}
+			result, err := profile.Restore(allTools(), r, mode)
+			if err != nil {
+				return err
+			}
+			for _, p := range result.Restored {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: restored\n", p)
+			}
+			for _, p := range result.Skipped {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: skipped (already exists)\n", p)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&merge, "merge", false, "Only add profiles that do not exist yet (default)")
+	cmd.Flags().BoolVar(&replace, "replace", false, "Replace each tool's store with the backup")
+	cmd.MarkFlagsMutuallyExclusive("merge", "replace")
+
+	return cmd
+}
@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// clipboardPrefix starts the text export --clipboard copies, so that import
+// --clipboard can tell a bundle from whatever else is on the clipboard.
+const clipboardPrefix = "tokyo-bundle:"
+
+// clipboardCopy and clipboardPaste reach the system clipboard. Tests replace
+// them.
+var (
+	clipboardCopy  = copyToClipboard
+	clipboardPaste = pasteFromClipboard
+)
+
+// encodeClipboardBundle returns bundle as a single line of text that
+// survives chat clients and screen-sharing tools.
+func encodeClipboardBundle(bundle []byte) string {
+	return clipboardPrefix + base64.StdEncoding.EncodeToString(bundle)
+}
+
+// decodeClipboardBundle reverses encodeClipboardBundle. Whitespace that
+// chat clients add when wrapping long lines is ignored.
+func decodeClipboardBundle(text string) ([]byte, error) {
+	text = strings.Join(strings.Fields(text), "")
+	encoded, ok := strings.CutPrefix(text, clipboardPrefix)
+	if !ok {
+		return nil, errors.New("the clipboard does not hold a tokyo bundle (copy one with 'export --clipboard')")
+	}
+	bundle, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("the bundle on the clipboard is damaged: %w", err)
+	}
+	return bundle, nil
+}
+
+// clipboardCommand is a program that copies stdin to the clipboard or
+// pastes the clipboard to stdout.
+type clipboardCommand struct {
+	name string
+	args []string
+}
+
+// clipboardCommands returns the programs to try for copying, or pasting
+// when paste is set, on this platform, best first.
+func clipboardCommands(paste bool) []clipboardCommand {
+	switch runtime.GOOS {
+	case "darwin":
+		if paste {
+			return []clipboardCommand{{"pbpaste", nil}}
+		}
+		return []clipboardCommand{{"pbcopy", nil}}
+	case "windows":
+		if paste {
+			return []clipboardCommand{{"powershell", []string{"-NoProfile", "-Command", "Get-Clipboard -Raw"}}}
+		}
+		return []clipboardCommand{{"clip", nil}}
+	}
+	var cmds []clipboardCommand
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		if paste {
+			cmds = append(cmds, clipboardCommand{"wl-paste", []string{"--no-newline"}})
+		} else {
+			cmds = append(cmds, clipboardCommand{"wl-copy", nil})
+		}
+	}
+	if paste {
+		return append(cmds, clipboardCommand{"xclip", []string{"-selection", "clipboard", "-o"}}, clipboardCommand{"xsel", []string{"--clipboard", "--output"}})
+	}
+	return append(cmds, clipboardCommand{"xclip", []string{"-selection", "clipboard"}}, clipboardCommand{"xsel", []string{"--clipboard", "--input"}})
+}
+
+// findClipboardCommand returns the first of cmds that is installed.
+func findClipboardCommand(cmds []clipboardCommand) (clipboardCommand, error) {
+	for _, c := range cmds {
+		if _, err := exec.LookPath(c.name); err == nil {
+			return c, nil
+		}
+	}
+	names := make([]string, len(cmds))
+	for i, c := range cmds {
+		names[i] = c.name
+	}
+	return clipboardCommand{}, fmt.Errorf("no clipboard program found (install one of: %s)", strings.Join(names, ", "))
+}
+
+func copyToClipboard(text string) error {
+	c, err := findClipboardCommand(clipboardCommands(false))
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Stdin = strings.NewReader(text)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s: %w: %s", c.name, err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func pasteFromClipboard() (string, error) {
+	c, err := findClipboardCommand(clipboardCommands(true))
+	if err != nil {
+		return "", err
+	}
+	cmd := exec.Command(c.name, c.args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("%s: %w: %s", c.name, err, strings.TrimSpace(stderr.String()))
+	}
+	return string(out), nil
+}
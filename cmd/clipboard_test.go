@@ -0,0 +1,157 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/passphrase"
+	"tokyo/pkg/profile"
+	"tokyo/pkg/qr"
+)
+
+// fakeClipboard points clipboardCopy and clipboardPaste at a string for the
+// rest of the test.
+func fakeClipboard(t *testing.T) *string {
+	t.Helper()
+	var text string
+	oldCopy, oldPaste := clipboardCopy, clipboardPaste
+	clipboardCopy = func(s string) error { text = s; return nil }
+	clipboardPaste = func() (string, error) { return text, nil }
+	t.Cleanup(func() { clipboardCopy, clipboardPaste = oldCopy, oldPaste })
+	return &text
+}
+
+func TestClipboardRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		encrypt bool
+	}{
+		{"plain", false},
+		{"encrypted", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HOME", t.TempDir())
+			t.Setenv(passphrase.Env, "correct horse")
+			clip := fakeClipboard(t)
+			tool := profile.ClaudeTool()
+			if err := profile.SaveFiles(tool, "work", map[string][]byte{"settings.json": []byte(`{"model":"opus"}`)}, false); err != nil {
+				t.Fatalf("SaveFiles: %v", err)
+			}
+
+			args := []string{"work", "--clipboard"}
+			if tt.encrypt {
+				args = append(args, "--encrypt")
+			}
+			export := newExportCommand(tool)
+			export.SetErr(io.Discard)
+			export.SetArgs(args)
+			if err := export.Execute(); err != nil {
+				t.Fatalf("export --clipboard: %v", err)
+			}
+			if !strings.HasPrefix(*clip, clipboardPrefix) || strings.ContainsAny(*clip, "\n ") {
+				t.Fatalf("expected a single-line bundle, got %q", *clip)
+			}
+			data, err := decodeClipboardBundle(*clip)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if profile.IsSealed(data) != tt.encrypt {
+				t.Fatalf("sealed = %v, want %v", profile.IsSealed(data), tt.encrypt)
+			}
+
+			// Another machine, where a chat client wrapped the line.
+			t.Setenv("HOME", t.TempDir())
+			*clip = (*clip)[:40] + "\n" + (*clip)[40:] + "\n"
+			imp := newImportCommand(tool)
+			imp.SetOut(io.Discard)
+			imp.SetArgs([]string{"--clipboard"})
+			if err := imp.Execute(); err != nil {
+				t.Fatalf("import --clipboard: %v", err)
+			}
+			files, err := profile.ReadFiles(tool, "work")
+			if err != nil {
+				t.Fatalf("ReadFiles: %v", err)
+			}
+			if string(files["settings.json"]) != `{"model":"opus"}` {
+				t.Fatalf("imported %q", files["settings.json"])
+			}
+		})
+	}
+}
+
+func TestImportClipboardRejectsOtherText(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	clip := fakeClipboard(t)
+	*clip = "hello"
+	imp := newImportCommand(profile.ClaudeTool())
+	imp.SetOut(io.Discard)
+	imp.SetArgs([]string{"--clipboard"})
+	if err := imp.Execute(); err == nil || !strings.Contains(err.Error(), "does not hold a tokyo bundle") {
+		t.Fatalf("expected a not-a-bundle error, got %v", err)
+	}
+}
+
+func TestImportClipboardWrongPassphrase(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	clip := fakeClipboard(t)
+	sealed, err := profile.Seal([]byte("bundle"), "correct horse")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	*clip = encodeClipboardBundle(sealed)
+	t.Setenv(passphrase.Env, "battery staple")
+	imp := newImportCommand(profile.ClaudeTool())
+	imp.SetOut(io.Discard)
+	imp.SetArgs([]string{"--clipboard"})
+	if err := imp.Execute(); !errors.Is(err, profile.ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestExportClipboardFlagConflicts(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	fakeClipboard(t)
+	tool := profile.ClaudeTool()
+	if err := profile.SaveFiles(tool, "work", map[string][]byte{"settings.json": []byte(`{}`)}, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	for _, args := range [][]string{
+		{"work", "--clipboard", "-o", "x.tar.gz"},
+		{"work", "--clipboard", "--format", "script"},
+		{"work", "--encrypt"},
+	} {
+		export := newExportCommand(tool)
+		export.SetErr(io.Discard)
+		export.SetArgs(args)
+		if err := export.Execute(); err == nil {
+			t.Errorf("%v: expected an error", args)
+		}
+	}
+}
+
+func TestExportQR(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	tool := profile.ClaudeTool()
+	if err := profile.SaveFiles(tool, "work", map[string][]byte{"settings.json": []byte(`{"model":"opus"}`)}, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	export := newExportCommand(tool)
+	var out bytes.Buffer
+	export.SetOut(&out)
+	export.SetErr(io.Discard)
+	export.SetArgs([]string{"work", "--qr"})
+	if err := export.Execute(); err != nil {
+		t.Fatalf("export --qr: %v", err)
+	}
+	if !strings.Contains(out.String(), "█") {
+		t.Fatalf("expected a QR code, got:\n%s", out.String())
+	}
+
+	if err := writeQR(io.Discard, strings.Repeat("a", qr.MaxSize+1)); !errors.Is(err, qr.ErrTooLarge) {
+		t.Fatalf("expected qr.ErrTooLarge, got %v", err)
+	}
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newConfigCommand())
+}
+
+func newConfigCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: i18n.T("Read and change tokyo's own settings"),
+		Long: `Read and change tokyo's own settings, stored in ~/.config/tokyo/config.yaml.
+
+Keys:
+  serve.addr                 default address for 'tokyo serve'
+  serve.token                default bearer token for 'tokyo serve'
+  serve.theme                web UI theme: system, dark or light (default system)
+  color                      auto, always or never
+  confirm                    prompt before bulk deletes (default true)
+  hooks.pre_switch           shell command run before every switch
+  hooks.post_switch          shell command run after every switch
+  default_profiles.<tool>    profile used by 'tokyo <tool> switch' without arguments
+  drift_policy.<tool>        what 'tokyo <tool> watch' does about drift: record,
+                             restore or resave (default record)
+  tools                      comma-separated list of enabled tools (default: all)
+  remote                     base URL of a tokyo server
+  language                   en or ja (default: from LC_ALL, LC_MESSAGES or LANG)
+  workspace                  separate set of profile stores (default: none)
+
+Command-line flags override environment variables, which override the file:
+  TOKYO_HOME                 directory holding config.yaml and the profile stores
+  TOKYO_SYSTEM_HOME          directory holding the read-only system store
+  TOKYO_ADDR, TOKYO_TOKEN    serve.addr, serve.token
+  TOKYO_COLOR, TOKYO_CONFIRM color, confirm
+  TOKYO_TOOLS, TOKYO_REMOTE  tools, remote
+  TOKYO_LANGUAGE             language
+  TOKYO_WORKSPACE            workspace
+  TOKYO_NO_COLOR, NO_COLOR   any non-empty value sets color to never`,
+	}
+
+	cmd.AddCommand(newConfigGetCommand(), newConfigSetCommand(), newConfigShowCommand())
+	return cmd
+}
+
+func newConfigGetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get [key]",
+		Short: i18n.T("Print a setting, or every setting when no key is given"),
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if len(args) == 1 {
+				value, err := config.Get(cfg, args[0])
+				if err != nil {
+					return err
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), value)
+				return nil
+			}
+			for _, key := range config.Keys(cfg) {
+				value, err := config.Get(cfg, key)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s=%s\n", key, value)
+			}
+			return nil
+		},
+	}
+}
+
+func newConfigSetCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: i18n.T("Change a setting (an empty value restores the default)"),
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, err := config.Path()
+			if err != nil {
+				return err
+			}
+			cfg, err := config.LoadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := config.Set(&cfg, args[0], args[1]); err != nil {
+				return err
+			}
+			return config.SaveFile(path, cfg)
+		},
+	}
+}
+
+func newConfigShowCommand() *cobra.Command {
+	var resolved bool
+
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: i18n.T("Print the settings from the config file"),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			settings, err := config.Resolve()
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			for _, s := range settings {
+				if !resolved {
+					if s.Source == config.SourceFile {
+						fmt.Fprintf(w, "%s\t%s\n", s.Key, s.Value)
+					}
+					continue
+				}
+				fmt.Fprintf(w, "%s\t%s\t(%s)\n", s.Key, s.Value, s.Source)
+			}
+			return w.Flush()
+		},
+	}
+
+	cmd.Flags().BoolVar(&resolved, "resolved", false, "Print every effective setting and where it comes from")
+	return cmd
+}
+
+// enabledTools returns the tools enabled in cfg, under --home when it is set.
+func enabledTools(cfg config.Config) []profile.Tool {
+	var tools []profile.Tool
+	for _, t := range allTools() {
+		if cfg.ToolEnabled(t.Name) {
+			tools = append(tools, t)
+		}
+	}
+	return tools
+}
+
+// hideDisabledTools removes the commands of tools the config disables.
+func hideDisabledTools(root *cobra.Command, cfg config.Config) {
+	for _, t := range profile.Tools() {
+		if cfg.ToolEnabled(t.Name) {
+			continue
+		}
+		for _, c := range root.Commands() {
+			if c.Name() == t.Name {
+				root.RemoveCommand(c)
+			}
+		}
+	}
+}
+
+// colorEnabled reports whether output written to cmd should be colored.
+func colorEnabled(cmd *cobra.Command, cfg config.Config) bool {
+	switch cfg.Color {
+	case config.ColorAlways:
+		return true
+	case config.ColorNever:
+		return false
+	}
+	f, ok := cmd.OutOrStdout().(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
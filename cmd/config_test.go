@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestConfigSetAndGet(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	set := newConfigCommand()
+	set.SetArgs([]string{"set", "serve.addr", "127.0.0.1:9000"})
+	if err := set.Execute(); err != nil {
+		t.Fatalf("config set: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "tokyo", "config.yaml")); err != nil {
+		t.Fatalf("expected config.yaml to be written: %v", err)
+	}
+
+	get := newConfigCommand()
+	var out bytes.Buffer
+	get.SetOut(&out)
+	get.SetArgs([]string{"get", "serve.addr"})
+	if err := get.Execute(); err != nil {
+		t.Fatalf("config get: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "127.0.0.1:9000" {
+		t.Fatalf("expected 127.0.0.1:9000, got %q", got)
+	}
+}
+
+func TestSwitchUsesDefaultProfileAndHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks run through sh in this test")
+	}
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	for _, kv := range [][]string{
+		{"default_profiles.claude", "work"},
+		{"hooks.post_switch", `echo "post $TOKYO_TOOL $TOKYO_PROFILE"`},
+	} {
+		set := newConfigCommand()
+		set.SetArgs(append([]string{"set"}, kv...))
+		if err := set.Execute(); err != nil {
+			t.Fatalf("config set %s: %v", kv[0], err)
+		}
+	}
+
+	cmd := newSwitchCommand(tool)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("switch: %v", err)
+	}
+	if got := strings.TrimSpace(out.String()); got != "post claude work" {
+		t.Fatalf("expected post hook output, got %q", got)
+	}
+
+	status, err := profile.Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work" {
+		t.Fatalf("expected work, got %q", status)
+	}
+}
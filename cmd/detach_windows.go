@@ -0,0 +1,12 @@
+package cmd
+
+import "syscall"
+
+// detachedProcess is the DETACHED_PROCESS creation flag.
+const detachedProcess = 0x00000008
+
+// detachedProcAttr starts a process without a console, so that closing the
+// terminal does not stop it.
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP}
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+func init() {
+	rootCmd.AddCommand(newDocsCommand())
+}
+
+func newDocsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "docs",
+		Short: i18n.T("Generate documentation for every command"),
+	}
+
+	cmd.AddCommand(
+		newDocsGenCommand("man", i18n.T("Write a man page for every command"), func(root *cobra.Command, dir string) error {
+			header := &doc.GenManHeader{
+				Title:   "TOKYO",
+				Section: "1",
+				Source:  "tokyo " + Version,
+				Manual:  "Tokyo Manual",
+			}
+			return doc.GenManTree(root, header, dir)
+		}),
+		newDocsGenCommand("markdown", i18n.T("Write a Markdown page for every command"), doc.GenMarkdownTree),
+	)
+	return cmd
+}
+
+func newDocsGenCommand(format, short string, gen func(root *cobra.Command, dir string) error) *cobra.Command {
+	var dir string
+
+	cmd := &cobra.Command{
+		Use:   format,
+		Short: short,
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := os.MkdirAll(dir, 0o755); err != nil {
+				return err
+			}
+			root := docsRoot(cmd.Root())
+			if err := gen(root, dir); err != nil {
+				return fmt.Errorf("generate %s docs: %w", format, err)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Wrote %s docs to %s\n", format, dir)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&dir, "output", "o", ".", "Directory to write the pages to")
+	return cmd
+}
+
+// docsRoot prepares root for documentation: every built-in tool is
+// documented, even when config.yaml disables it, and pages carry no
+// generation date so packaged builds are reproducible.
+func docsRoot(root *cobra.Command) *cobra.Command {
+	for _, t := range profile.Tools() {
+		found := false
+		for _, c := range root.Commands() {
+			if c.Name() == t.Name {
+				found = true
+				break
+			}
+		}
+		if !found {
+			root.AddCommand(newToolCommand(t))
+		}
+	}
+	root.DisableAutoGenTag = true
+	return root
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDocsGeneratesPagesForToolCommands(t *testing.T) {
+	oldOut := rootCmd.OutOrStdout()
+	t.Cleanup(func() {
+		rootCmd.SetOut(oldOut)
+		rootCmd.SetArgs(nil)
+	})
+	rootCmd.SetOut(io.Discard)
+
+	for format, page := range map[string]string{
+		"man":      "tokyo-claude-switch.1",
+		"markdown": "tokyo_codex_switch.md",
+	} {
+		dir := filepath.Join(t.TempDir(), format)
+		rootCmd.SetArgs([]string{"docs", format, "-o", dir})
+		if err := rootCmd.Execute(); err != nil {
+			t.Fatalf("docs %s: %v", format, err)
+		}
+		data, err := os.ReadFile(filepath.Join(dir, page))
+		if err != nil {
+			t.Fatalf("read %s: %v", page, err)
+		}
+		if !strings.Contains(string(data), "switch") {
+			t.Fatalf("expected %s to document switch, got:\n%s", page, data)
+		}
+		if strings.Contains(string(data), "Auto generated") {
+			t.Fatalf("expected %s to carry no generation tag", page)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newExecCommand())
+}
+
+func newExecCommand() *cobra.Command {
+	var profileName string
+	var toolName string
+	var envOnly bool
+	var confirmProduction bool
+
+	cmd := &cobra.Command{
+		Use:   "exec --profile <profile> [--tool <tool>] -- <command> [args...]",
+		Short: i18n.T("Run a command with a profile temporarily active"),
+		Long: `Switch to a profile, run a command, and restore the previous configuration
+when the command exits (including on Ctrl-C).
+
+Without --tool, every tool that has the named profile is switched.
+
+With --env, no files are touched: the environment variables stored with the
+profile (see 'tokyo <tool> env') are added to the command's environment.
+
+A profile tagged protected is only switched to once its name is typed, or
+with --confirm-production.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tools, err := execTools(toolName, profileName)
+			if err != nil {
+				return err
+			}
+
+			if envOnly {
+				env, err := profileEnv(tools, profileName)
+				if err != nil {
+					return err
+				}
+				return childExitError(cmd, runChild(cmd, args, env))
+			}
+
+			var snapshots []*profile.Snapshot
+			restore := func() error {
+				var errs []error
+				for i := len(snapshots) - 1; i >= 0; i-- {
+					if err := snapshots[i].Restore(); err != nil {
+						errs = append(errs, err)
+					}
+				}
+				return errors.Join(errs...)
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			for i, t := range tools {
+				if tools[i], err = confirmProtected(cmd, cfg, t, profileName, "switch to", confirmProduction); err != nil {
+					return err
+				}
+			}
+
+			for _, t := range tools {
+				snap, err := profile.TakeSnapshot(t)
+				if err != nil {
+					return errors.Join(err, restore())
+				}
+				snapshots = append(snapshots, snap)
+				if err := profile.Switch(t, profileName); err != nil {
+					return errors.Join(err, restore())
+				}
+			}
+
+			runErr := runChild(cmd, args, nil)
+			if err := restore(); err != nil {
+				return errors.Join(runErr, fmt.Errorf("restore previous configuration: %w", err))
+			}
+			return childExitError(cmd, runErr)
+		},
+	}
+
+	cmd.Flags().StringVarP(&profileName, "profile", "p", "", "Profile to activate while the command runs")
+	cmd.Flags().StringVarP(&toolName, "tool", "t", "", "Only switch this tool (default: every tool with the profile)")
+	cmd.Flags().BoolVar(&envOnly, "env", false, "Inject the profile's stored environment variables instead of switching files")
+	cmd.Flags().BoolVar(&confirmProduction, "confirm-production", false, "Switch to a profile tagged protected without typing its name")
+	cmd.Flags().SetInterspersed(false)
+	_ = cmd.MarkFlagRequired("profile")
+
+	return cmd
+}
+
+func execTools(toolName, profileName string) ([]profile.Tool, error) {
+	if err := profile.ValidateProfileName(profileName); err != nil {
+		return nil, err
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	if toolName != "" {
+		t, ok := profile.LookupTool(toolName)
+		if !ok {
+			return nil, fmt.Errorf("unknown tool: %q", toolName)
+		}
+		if !cfg.ToolEnabled(t.Name) {
+			return nil, fmt.Errorf("tool %q is not enabled (see the tools setting)", toolName)
+		}
+		return []profile.Tool{withStoreFlags(t)}, nil
+	}
+
+	var tools []profile.Tool
+	for _, t := range enabledTools(cfg) {
+		exists, err := profile.Exists(t, profileName)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			tools = append(tools, t)
+		}
+	}
+	if len(tools) == 0 {
+		return nil, fmt.Errorf("%w: no tool has profile %q", profile.ErrProfileNotFound, profileName)
+	}
+	return tools, nil
+}
+
+// profileEnv merges the stored environment of profileName across tools. Tools
+// later in the list win on conflicting keys.
+func profileEnv(tools []profile.Tool, profileName string) ([]string, error) {
+	merged := map[string]string{}
+	for _, t := range tools {
+		meta, err := profile.ReadMeta(t, profileName)
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range meta.Env {
+			merged[k] = v
+		}
+	}
+
+	env := os.Environ()
+	for k, v := range merged {
+		env = append(env, k+"="+v)
+	}
+	return env, nil
+}
+
+// childExitError converts a child's non-zero exit into an ExitError so tokyo
+// exits with the same code without printing anything extra.
+func childExitError(cmd *cobra.Command, err error) error {
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		return &ExitError{Code: exitErr.ExitCode()}
+	}
+	return err
+}
+
+// runChild runs args[0] with the remaining arguments. A nil env inherits the
+// current environment. With --home the child runs with that HOME, so it
+// reads the config files tokyo switched.
+func runChild(cmd *cobra.Command, args []string, env []string) error {
+	if homeDir != "" {
+		if env == nil {
+			env = os.Environ()
+		}
+		env = append(env, "HOME="+homeDir)
+	}
+	child := exec.Command(args[0], args[1:]...)
+	child.Env = env
+	child.Stdin = cmd.InOrStdin()
+	child.Stdout = cmd.OutOrStdout()
+	child.Stderr = cmd.ErrOrStderr()
+
+	// The terminal delivers Ctrl-C to the whole process group, so the child
+	// sees it directly; we only need to stay alive long enough to restore.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, execSignals...)
+	defer signal.Stop(sigCh)
+
+	if err := child.Start(); err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- child.Wait()
+	}()
+
+	for {
+		select {
+		case sig := <-sigCh:
+			if sig != os.Interrupt {
+				_ = child.Process.Signal(sig)
+			}
+		case err := <-done:
+			return err
+		}
+	}
+}
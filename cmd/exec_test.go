@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestExecCommandRestoresPreviousConfig(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"account":"personal"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"account":"work"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cmd := newExecCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--profile", "personal", "--", "sh", "-c", "cat \"$HOME/.claude/settings.json\""})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("exec command: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "personal") {
+		t.Fatalf("expected child to see personal config, got %q", out.String())
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if string(data) != `{"account":"work"}` {
+		t.Fatalf("expected previous config restored, got %q", string(data))
+	}
+	status, _ := profile.Current(tool)
+	if status != "<custom>" {
+		t.Fatalf("expected <custom>, got %q", status)
+	}
+}
+
+func TestExecCommandConfirmsProtectedProfile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"account":"prod"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "prod", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.UpdateMeta(tool, "prod", func(m *profile.Meta) error {
+		m.Tags = map[string]string{profile.ProtectedTag: ""}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+
+	run := func(args ...string) (string, error) {
+		cmd := newExecCommand()
+		var out bytes.Buffer
+		cmd.SetIn(strings.NewReader(""))
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+	if out, err := run("--profile", "prod", "--", "sh", "-c", "echo ran"); err == nil || strings.Contains(out, "ran\n") {
+		t.Fatalf("expected exec to refuse a protected profile, got %q, %v", out, err)
+	}
+	if out, err := run("--profile", "prod", "--confirm-production", "--", "sh", "-c", "echo ran"); err != nil || out != "ran\n" {
+		t.Fatalf("exec --confirm-production = %q, %v", out, err)
+	}
+}
+
+func TestExecCommandPropagatesExitCode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	cmd := newExecCommand()
+	cmd.SetArgs([]string{"--profile", "work", "--tool", "claude", "--", "sh", "-c", "exit 3"})
+
+	err := cmd.Execute()
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 3 {
+		t.Fatalf("expected exit code 3, got %v", err)
+	}
+}
+
+func TestExecCommandUnknownProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cmd := newExecCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--profile", "missing", "--", "true"})
+
+	err := cmd.Execute()
+	if !errors.Is(err, profile.ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestExecCommandDisabledTool(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configPath := filepath.Join(home, ".config", "tokyo", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("tools: [claude]\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cmd := newExecCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--profile", "work", "--tool", "codex", "--", "true"})
+
+	if err := cmd.Execute(); err == nil || !strings.Contains(err.Error(), "not enabled") {
+		t.Fatalf("expected a disabled tool to be refused, got %v", err)
+	}
+}
+
+func TestExecCommandEnvDoesNotTouchFiles(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"account":"personal"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"account":"work"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	envCmd := newEnvCommand(tool)
+	envCmd.SetArgs([]string{"personal", "TOKYO_TEST_KEY=from-profile"})
+	if err := envCmd.Execute(); err != nil {
+		t.Fatalf("env command: %v", err)
+	}
+
+	cmd := newExecCommand()
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--profile", "personal", "--env", "--", "sh", "-c", "echo \"$TOKYO_TEST_KEY\"; cat \"$HOME/.claude/settings.json\""})
+
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("exec command: %v", err)
+	}
+
+	got := out.String()
+	if !strings.Contains(got, "from-profile") {
+		t.Fatalf("expected injected variable, got %q", got)
+	}
+	if !strings.Contains(got, `"work"`) {
+		t.Fatalf("expected live config untouched, got %q", got)
+	}
+}
@@ -0,0 +1,12 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// execSignals are the signals exec outlives to restore the previous profile.
+// SIGHUP is what closing the terminal sends.
+var execSignals = []os.Signal{os.Interrupt, syscall.SIGTERM, syscall.SIGHUP}
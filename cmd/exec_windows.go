@@ -0,0 +1,9 @@
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// execSignals are the signals exec outlives to restore the previous profile.
+var execSignals = []os.Signal{os.Interrupt, syscall.SIGTERM}
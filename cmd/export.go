@@ -0,0 +1,373 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+	"tokyo/pkg/qr"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportCommand(t profile.Tool) *cobra.Command {
+	var output string
+	var all bool
+	var match string
+	var format string
+	var signKey string
+	var clipboard bool
+	var qrCode bool
+	var encrypt bool
+
+	cmd := &cobra.Command{
+		Use:   "export [profile] [--all | --match <glob>]",
+		Short: i18n.Sprintf("Export %s profiles as a bundle", t.DisplayName),
+		Long: `Export one or more profiles, including their metadata, as a tar.gz bundle
+that 'tokyo <tool> import' can read.
+
+The bundle is written to <tool>-<profile>.tar.gz (or <tool>-profiles.tar.gz for
+several profiles) unless -o is given; use -o - for stdout.
+
+With --format script, a single profile is written as a shell script instead
+(<tool>-<profile>.sh) that recreates its config files on a machine without
+tokyo: sh claude-work.sh. Stored environment variables are not included.
+
+With --sign <private key>, the bundle is also signed with that SSH key into
+<bundle>.sig, so others can check it with 'tokyo <tool> import --verify-key'
+(or ssh-keygen -Y verify -n tokyo-bundle). A key with a passphrase is used
+through ssh-agent.
+
+With --clipboard, the bundle is copied to the system clipboard as a line of
+text instead, to paste into a chat or screen-sharing session and read back
+with 'tokyo <tool> import --clipboard'. Add --encrypt to protect it with a
+passphrase, which the importer is asked for.
+
+With --qr, the same text is drawn as a QR code in the terminal instead, to
+scan with a phone and paste on another device. A QR code holds about 2 KB of
+bundle; for larger profiles, draw a link with 'tokyo <tool> share --qr'.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			profiles, err := exportSelection(t, args, all, match)
+			if err != nil {
+				return err
+			}
+			if clipboard || qrCode {
+				if format != "bundle" || output != "" || signKey != "" {
+					return errors.New("--clipboard and --qr export a bundle as text and cannot be combined with --format script, -o or --sign")
+				}
+				text, err := textBundle(cmd, t, profiles, encrypt)
+				if err != nil {
+					return err
+				}
+				if qrCode {
+					return writeQR(cmd.OutOrStdout(), text)
+				}
+				if err := clipboardCopy(text); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Copied %d profile(s) to the clipboard (%d characters)\n", len(profiles), len(text))
+				return nil
+			}
+			if encrypt {
+				return errors.New("--encrypt needs --clipboard or --qr")
+			}
+
+			export := profile.Export
+			perm := os.FileMode(0o600)
+			switch format {
+			case "bundle":
+				if output == "" {
+					output = defaultBundleName(t, profiles, len(args) == 1)
+				}
+			case "script":
+				if len(args) != 1 {
+					return errors.New("--format script exports a single <profile>")
+				}
+				if output == "" {
+					output = fmt.Sprintf("%s-%s.sh", t.Name, profiles[0])
+				}
+				export = func(t profile.Tool, profiles []string, w io.Writer) error {
+					return profile.ExportScript(t, profiles[0], w)
+				}
+				perm = 0o700
+			default:
+				return fmt.Errorf("unsupported format %q (supported: bundle, script)", format)
+			}
+			if signKey != "" && (format != "bundle" || output == "-") {
+				return errors.New("--sign needs a bundle written to a file")
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			var file *os.File
+			if output != "-" {
+				file, err = os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+				if err != nil {
+					return err
+				}
+				w = file
+			}
+
+			if err := export(t, profiles, w); err != nil {
+				if file != nil {
+					file.Close()
+					os.Remove(output)
+				}
+				return err
+			}
+			if file != nil {
+				if err := file.Close(); err != nil {
+					return err
+				}
+				if signKey != "" {
+					if err := signBundleFile(output, signKey); err != nil {
+						os.Remove(output)
+						return err
+					}
+				}
+				for _, p := range profiles {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s: exported\n", p)
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "Wrote %d profile(s) to %s\n", len(profiles), output)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "", "Output file (- for stdout)")
+	cmd.Flags().BoolVar(&all, "all", false, "Export every profile")
+	cmd.Flags().StringVar(&match, "match", "", "Export every profile whose name matches this glob")
+	cmd.Flags().StringVar(&format, "format", "bundle", "Output format: bundle or script")
+	cmd.Flags().StringVar(&signKey, "sign", "", "Sign the bundle with this SSH private key into <bundle>.sig")
+	cmd.Flags().BoolVar(&clipboard, "clipboard", false, "Copy the bundle to the clipboard as text instead of writing a file")
+	cmd.Flags().BoolVar(&qrCode, "qr", false, "Draw the bundle as a QR code in the terminal instead of writing a file")
+	cmd.Flags().BoolVar(&encrypt, "encrypt", false, "Encrypt the bundle exported with --clipboard or --qr with a passphrase")
+	cmd.MarkFlagsMutuallyExclusive("clipboard", "qr")
+
+	return cmd
+}
+
+// textBundle returns profiles as a bundle in the text form of
+// encodeClipboardBundle, sealed with a passphrase when encrypt is set.
+func textBundle(cmd *cobra.Command, t profile.Tool, profiles []string, encrypt bool) (string, error) {
+	var bundle bytes.Buffer
+	if err := profile.Export(t, profiles, &bundle); err != nil {
+		return "", err
+	}
+	data := bundle.Bytes()
+	if encrypt {
+		cfg, err := config.Load()
+		if err != nil {
+			return "", err
+		}
+		pass, err := cfg.Passphrase().ReadNew(cmd.Context(), "Passphrase for the bundle")
+		if err != nil {
+			return "", err
+		}
+		if data, err = profile.Seal(data, pass); err != nil {
+			return "", err
+		}
+	}
+	for _, p := range profiles {
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s: exported\n", p)
+	}
+	return encodeClipboardBundle(data), nil
+}
+
+// writeQR draws text as a QR code on w.
+func writeQR(w io.Writer, text string) error {
+	code, err := qr.Encode([]byte(text))
+	if err != nil {
+		return fmt.Errorf("%d characters: %w; share a link with 'tokyo <tool> share --qr' instead", len(text), err)
+	}
+	return code.WriteTerminal(w)
+}
+
+// pasteBundle returns the bundle on the clipboard, asking for the
+// passphrase of an encrypted one.
+func pasteBundle(cmd *cobra.Command) ([]byte, error) {
+	text, err := clipboardPaste()
+	if err != nil {
+		return nil, err
+	}
+	data, err := decodeClipboardBundle(text)
+	if err != nil {
+		return nil, err
+	}
+	if !profile.IsSealed(data) {
+		return data, nil
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	pass, err := cfg.Passphrase().Read(cmd.Context(), "Passphrase for the bundle")
+	if err != nil {
+		return nil, err
+	}
+	return profile.Unseal(data, pass)
+}
+
+// signBundleFile writes the signature of the bundle at path to path.sig.
+func signBundleFile(path, key string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	sig, err := profile.SignBundle(data, key)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path+".sig", sig, 0o644)
+}
+
+func exportSelection(t profile.Tool, args []string, all bool, match string) ([]string, error) {
+	selectors := 0
+	if len(args) == 1 {
+		selectors++
+	}
+	if all {
+		selectors++
+	}
+	if match != "" {
+		selectors++
+	}
+	if selectors != 1 {
+		return nil, errors.New("specify exactly one of <profile>, --all, or --match")
+	}
+
+	switch {
+	case len(args) == 1:
+		if err := profile.ValidateProfileName(args[0]); err != nil {
+			return nil, err
+		}
+		return args, nil
+	case all:
+		profiles, err := profile.List(t)
+		if err != nil {
+			return nil, err
+		}
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("%w: no %s profiles to export", profile.ErrProfileNotFound, t.DisplayName)
+		}
+		return profiles, nil
+	default:
+		profiles, err := profile.Match(t, match)
+		if err != nil {
+			return nil, err
+		}
+		if len(profiles) == 0 {
+			return nil, fmt.Errorf("%w: no profile matches %q", profile.ErrProfileNotFound, match)
+		}
+		return profiles, nil
+	}
+}
+
+func defaultBundleName(t profile.Tool, profiles []string, single bool) string {
+	if single {
+		return fmt.Sprintf("%s-%s.tar.gz", t.Name, profiles[0])
+	}
+	return fmt.Sprintf("%s-profiles.tar.gz", t.Name)
+}
+
+func newImportCommand(t profile.Tool) *cobra.Command {
+	var force bool
+	var verifyKey string
+	var signature string
+	var fromURL string
+	var clipboard bool
+
+	cmd := &cobra.Command{
+		Use:   "import <bundle> | --from-url <url> | --clipboard",
+		Short: i18n.Sprintf("Import %s profiles from a bundle", t.DisplayName),
+		Long: `Import profiles from a bundle created by 'tokyo <tool> export'. Use - to read from stdin.
+
+With --verify-key <public keys>, nothing is imported unless the bundle's
+signature (<bundle>.sig, or --signature) was made by one of the SSH public
+keys in that file, which holds one key per line like authorized_keys.
+
+With --from-url, the bundle is downloaded over HTTPS instead. The URL must end
+in #sha256=<hex>, the SHA-256 of the bundle, which is checked before anything
+is imported.
+
+With --clipboard, the bundle is read from the system clipboard, where
+'tokyo <tool> export --clipboard' put it.`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if fromURL != "" || clipboard {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			var r io.Reader = cmd.InOrStdin()
+			source := "-"
+			if clipboard {
+				data, err := pasteBundle(cmd)
+				if err != nil {
+					return err
+				}
+				r = bytes.NewReader(data)
+			} else if fromURL != "" {
+				_, data, err := fetchVerified(cmd.Context(), fromURL, -1)
+				if err != nil {
+					return err
+				}
+				r = bytes.NewReader(data)
+			} else if source = args[0]; source != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				r = file
+			}
+
+			if verifyKey != "" {
+				if signature == "" {
+					if source == "-" {
+						return errors.New("--verify-key with a bundle from stdin, a URL or the clipboard needs --signature")
+					}
+					signature = source + ".sig"
+				}
+				data, err := io.ReadAll(r)
+				if err != nil {
+					return err
+				}
+				sig, err := os.ReadFile(signature)
+				if err != nil {
+					return err
+				}
+				if err := profile.VerifyBundle(data, sig, verifyKey); err != nil {
+					return err
+				}
+				r = bytes.NewReader(data)
+			} else if signature != "" {
+				return errors.New("--signature needs --verify-key")
+			}
+
+			imported, err := profile.Import(t, r, force)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Imported %s\n", strings.Join(imported, ", "))
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite existing profiles")
+	cmd.Flags().StringVar(&verifyKey, "verify-key", "", "Only import a bundle signed by one of the SSH public keys in this file")
+	cmd.Flags().StringVar(&signature, "signature", "", "Signature file to verify (default <bundle>.sig)")
+	cmd.Flags().StringVar(&fromURL, "from-url", "", "Download the bundle over HTTPS; the URL ends in #sha256=<hex>")
+	cmd.Flags().BoolVar(&clipboard, "clipboard", false, "Read the bundle from the clipboard")
+	cmd.MarkFlagsMutuallyExclusive("from-url", "clipboard")
+
+	return cmd
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+// fetchClient downloads --from-url content. Tests replace it with one that
+// trusts their TLS server.
+var fetchClient = &http.Client{Timeout: time.Minute}
+
+// errChecksumMismatch is returned when fetched content does not have the
+// digest its URL names.
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// fetchVerified downloads rawURL and returns its content with the base name
+// of the URL's path. The URL must use https and end in #sha256=<hex>, the
+// SHA-256 digest of the content, so that a command pasted from onboarding
+// docs installs exactly what its author published. Content over limit bytes
+// is refused; a negative limit means none.
+func fetchVerified(ctx context.Context, rawURL string, limit int64) (string, []byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", nil, fmt.Errorf("parse %s: %w", rawURL, err)
+	}
+	if u.Scheme != "https" {
+		return "", nil, fmt.Errorf("%s: only https URLs can be fetched", rawURL)
+	}
+	want, ok := strings.CutPrefix(u.Fragment, "sha256=")
+	if !ok {
+		return "", nil, fmt.Errorf("%s: add the SHA-256 of the content as #sha256=<hex> to the URL", rawURL)
+	}
+	sum, err := hex.DecodeString(want)
+	if err != nil || len(sum) != sha256.Size {
+		return "", nil, fmt.Errorf("%s: #sha256= must be followed by 64 hex digits", rawURL)
+	}
+	u.Fragment = ""
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return "", nil, fmt.Errorf("%s: the URL must name a file", rawURL)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", nil, err
+	}
+	resp, err := fetchClient.Do(req)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("fetch %s: %s", u, resp.Status)
+	}
+
+	var body io.Reader = resp.Body
+	if limit >= 0 {
+		body = io.LimitReader(resp.Body, limit+1)
+	}
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return "", nil, fmt.Errorf("fetch %s: %w", u, err)
+	}
+	if limit >= 0 && int64(len(data)) > limit {
+		return "", nil, fmt.Errorf("fetch %s: %w: over the %s limit", u, profile.ErrFileTooLarge, profile.FormatSize(limit))
+	}
+	if got := sha256.Sum256(data); !strings.EqualFold(hex.EncodeToString(got[:]), want) {
+		return "", nil, fmt.Errorf("fetch %s: %w: got sha256=%x", u, errChecksumMismatch, got)
+	}
+	return name, data, nil
+}
+
+// fetchLimit returns the size limit for fetched config files as
+// fetchVerified takes it: negative when there is none.
+func fetchLimit(t profile.Tool) int64 {
+	limit, ok := t.FileSizeLimit()
+	if !ok {
+		return -1
+	}
+	return limit
+}
+
+// fetchProfileFiles downloads the config files of t at urls, keyed by their
+// base names as profile.SaveFiles takes them.
+func fetchProfileFiles(ctx context.Context, t profile.Tool, urls []string) (map[string][]byte, error) {
+	files := make(map[string][]byte, len(urls))
+	for _, rawURL := range urls {
+		name, data, err := fetchVerified(ctx, rawURL, fetchLimit(t))
+		if err != nil {
+			return nil, err
+		}
+		if _, dup := files[name]; dup {
+			return nil, fmt.Errorf("%s is fetched twice", name)
+		}
+		files[name] = data
+	}
+	return files, nil
+}
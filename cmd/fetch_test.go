@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+// serveFiles serves files by path over TLS and points fetchClient at the
+// server for the rest of the test.
+func serveFiles(t *testing.T, files map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		io.WriteString(w, content)
+	}))
+	t.Cleanup(srv.Close)
+	old := fetchClient
+	fetchClient = srv.Client()
+	t.Cleanup(func() { fetchClient = old })
+	return srv
+}
+
+func sha256Fragment(content string) string {
+	return fmt.Sprintf("#sha256=%x", sha256.Sum256([]byte(content)))
+}
+
+func TestFetchVerified(t *testing.T) {
+	const content = `{"model":"opus"}`
+	srv := serveFiles(t, map[string]string{"/team/settings.json": content})
+	base := srv.URL + "/team/settings.json"
+
+	name, data, err := fetchVerified(context.Background(), base+sha256Fragment(content), -1)
+	if err != nil {
+		t.Fatalf("fetchVerified: %v", err)
+	}
+	if name != "settings.json" || string(data) != content {
+		t.Fatalf("got %q, %q", name, data)
+	}
+
+	tests := []struct {
+		name  string
+		url   string
+		limit int64
+		want  error
+	}{
+		{"no checksum", base, -1, nil},
+		{"bad checksum", base + "#sha256=xyz", -1, nil},
+		{"wrong checksum", base + sha256Fragment("other"), -1, errChecksumMismatch},
+		{"plain http", strings.Replace(base, "https:", "http:", 1) + sha256Fragment(content), -1, nil},
+		{"too large", base + sha256Fragment(content), 4, profile.ErrFileTooLarge},
+		{"not found", srv.URL + "/missing.json" + sha256Fragment(content), -1, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := fetchVerified(context.Background(), tt.url, tt.limit)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestSaveFromURL(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	const content = `{"model":"opus"}`
+	srv := serveFiles(t, map[string]string{"/settings.json": content})
+	tool := profile.ClaudeTool()
+
+	save := func(args ...string) error {
+		cmd := newSaveCommand(tool)
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(args)
+		return cmd.Execute()
+	}
+	good := srv.URL + "/settings.json" + sha256Fragment(content)
+	if err := save("work", "--from-url", good); err != nil {
+		t.Fatalf("save --from-url: %v", err)
+	}
+	files, err := profile.ReadFiles(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if string(files["settings.json"]) != content {
+		t.Fatalf("stored %q, want %q", files["settings.json"], content)
+	}
+
+	if err := save("work", "--from-url", good); !errors.Is(err, profile.ErrProfileAlreadyExists) {
+		t.Fatalf("expected ErrProfileAlreadyExists without --force, got %v", err)
+	}
+	if err := save("other", "--from-url", srv.URL+"/settings.json"+sha256Fragment("tampered")); !errors.Is(err, errChecksumMismatch) {
+		t.Fatalf("expected a checksum mismatch, got %v", err)
+	}
+	if exists, _ := profile.Exists(tool, "other"); exists {
+		t.Fatal("nothing should be saved when the checksum does not match")
+	}
+}
+
+func TestImportFromURL(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	source := profile.ClaudeTool().WithHome(t.TempDir())
+	if err := profile.SaveFiles(source, "team", map[string][]byte{"settings.json": []byte(`{}`)}, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	var bundle bytes.Buffer
+	if err := profile.Export(source, []string{"team"}, &bundle); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	srv := serveFiles(t, map[string]string{"/team.tar.gz": bundle.String()})
+
+	cmd := newImportCommand(profile.ClaudeTool())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--from-url", srv.URL + "/team.tar.gz" + sha256Fragment(bundle.String())})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("import --from-url: %v", err)
+	}
+	if exists, _ := profile.Exists(profile.ClaudeTool(), "team"); !exists {
+		t.Fatalf("team not imported; output:\n%s", out.String())
+	}
+}
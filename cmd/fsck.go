@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func newFsckCommand(t profile.Tool) *cobra.Command {
+	var repair []string
+
+	cmd := &cobra.Command{
+		Use:   "fsck",
+		Short: i18n.Sprintf("Check the %s store for broken profiles", t.DisplayName),
+		Long: fmt.Sprintf(`Check every saved %s profile and current.json for problems, one per line
+prefixed with its class:
+
+  missing  a profile lacks one of the tool's config files, so switching to it fails
+  extra    a profile directory holds a file tokyo did not put there
+  current  current.json names a profile that no longer exists
+  hash     a content-addressed profile's blob is missing or does not match its hash
+
+--repair fixes the given classes ("all" for every one): profiles with missing
+files or bad blobs are moved to the trash, extra files are removed, and a stale
+current.json is cleared. fsck exits with 1 while problems remain.`, t.DisplayName),
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			classes, err := parseFsckClasses(repair)
+			if err != nil {
+				return err
+			}
+			problems, err := profile.Fsck(t, time.Now())
+			if err != nil {
+				return err
+			}
+
+			repaired, repairErr := profile.RepairFsck(t, problems, classes)
+			remaining := 0
+			for _, p := range problems {
+				if slices.Contains(repaired, p) {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s (repaired)\n", p)
+					continue
+				}
+				fmt.Fprintln(cmd.OutOrStdout(), p)
+				remaining++
+			}
+			if repairErr != nil {
+				return repairErr
+			}
+			if remaining > 0 {
+				cmd.SilenceUsage = true
+				return &ExitError{Code: 1}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&repair, "repair", nil, "Repair problems of this class: missing, extra, current, hash or all (repeatable)")
+
+	return cmd
+}
+
+func parseFsckClasses(names []string) ([]profile.FsckClass, error) {
+	var classes []profile.FsckClass
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "all" {
+			return profile.FsckClasses, nil
+		}
+		class := profile.FsckClass(name)
+		if !slices.Contains(profile.FsckClasses, class) {
+			return nil, fmt.Errorf("unknown problem class %q (want missing, extra, current, hash or all)", name)
+		}
+		classes = append(classes, class)
+	}
+	return classes, nil
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestFsckCommandReportsAndRepairs(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	notes := filepath.Join(home, ".config", "tokyo", "claude", "profiles", "work", "notes.txt")
+	if err := os.WriteFile(notes, []byte("x"), 0o600); err != nil {
+		t.Fatalf("write notes: %v", err)
+	}
+
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := newFsckCommand(tool)
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SilenceErrors = true
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run()
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected exit 1, got %v", err)
+	}
+	if want := "extra: profile \"work\" has unexpected file notes.txt\n"; out != want {
+		t.Fatalf("fsck output = %q, want %q", out, want)
+	}
+
+	if _, err := run("--repair", "current"); !errors.As(err, &exitErr) {
+		t.Fatalf("expected exit 1 when another class is repaired, got %v", err)
+	}
+	if _, err := run("--repair", "bogus"); err == nil || errors.As(err, &exitErr) {
+		t.Fatalf("expected an unknown class to be refused, got %v", err)
+	}
+
+	out, err = run("--repair", "extra")
+	if err != nil {
+		t.Fatalf("fsck --repair extra: %v", err)
+	}
+	if want := "extra: profile \"work\" has unexpected file notes.txt (repaired)\n"; out != want {
+		t.Fatalf("fsck --repair output = %q, want %q", out, want)
+	}
+	if _, err := os.Stat(notes); !os.IsNotExist(err) {
+		t.Fatalf("expected notes.txt removed, got %v", err)
+	}
+	if out, err := run(); err != nil || out != "" {
+		t.Fatalf("fsck after repair = %q, %v", out, err)
+	}
+}
@@ -0,0 +1,99 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+// retentionInterval is how often `tokyo serve` applies the retention policy.
+const retentionInterval = time.Hour
+
+func init() {
+	rootCmd.AddCommand(newGCCommand())
+}
+
+func newGCCommand() *cobra.Command {
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: i18n.T("Remove old autosaves and trashed profiles"),
+		Long: `Apply the retention policy to every enabled tool: remove autosaves and trashed
+profiles beyond the configured count or age, then prune blobs only they used.
+
+Limits are set with 'tokyo config set retention.<autosaves|trash>.<max_count|max_age>'.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			policy, err := cfg.RetentionPolicy()
+			if err != nil {
+				return err
+			}
+
+			verb := "removed"
+			if dryRun {
+				verb = "would remove"
+			}
+			total := 0
+			for _, t := range enabledTools(cfg) {
+				result, err := profile.GC(t, policy, time.Now(), dryRun)
+				if err != nil {
+					return fmt.Errorf("%s: %w", t.Name, err)
+				}
+				for _, entry := range result.Removed {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: %s %s\n", t.Name, verb, describeEntry(entry))
+				}
+				if result.TempRemoved > 0 {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: removed %d unfinished save(s)\n", t.Name, result.TempRemoved)
+				}
+				total += len(result.Removed) + result.TempRemoved
+			}
+			if total == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "Nothing to remove.")
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Only list what would be removed")
+
+	return cmd
+}
+
+func describeEntry(entry profile.RetainedEntry) string {
+	created := entry.Created.Local().Format(time.DateTime)
+	if entry.Category == profile.CategoryTrash {
+		return fmt.Sprintf("trashed profile %q (deleted %s)", entry.Name, created)
+	}
+	return fmt.Sprintf("autosave from %s", created)
+}
+
+// runRetention applies policy to tools now and then every interval until ctx
+// is done. Errors are reported to errOut and do not stop the loop.
+func runRetention(ctx context.Context, tools []profile.Tool, policy profile.RetentionPolicy, interval time.Duration, errOut io.Writer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for _, t := range tools {
+			if _, err := profile.GC(t, policy, time.Now(), false); err != nil {
+				fmt.Fprintf(errOut, "retention: %s: %v\n", t.Name, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+	"tokyo/pkg/project"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newHookCommand())
+}
+
+// hookScripts are the shell functions 'tokyo hook <shell>' prints. %[1]s is
+// the quoted path of the tokyo binary.
+var hookScripts = map[string]string{
+	"bash": `_tokyo_hook() {
+  local status=$?
+  if [[ "$PWD" != "${_TOKYO_LAST_PWD-}" ]]; then
+    _TOKYO_LAST_PWD=$PWD
+    %[1]s hook run
+  fi
+  return $status
+}
+if [[ ";${PROMPT_COMMAND[*]:-};" != *";_tokyo_hook;"* ]]; then
+  PROMPT_COMMAND="_tokyo_hook${PROMPT_COMMAND:+;$PROMPT_COMMAND}"
+fi
+`,
+	"zsh": `_tokyo_hook() {
+  %[1]s hook run
+}
+typeset -ag chpwd_functions
+if (( ! ${chpwd_functions[(I)_tokyo_hook]} )); then
+  chpwd_functions=(_tokyo_hook $chpwd_functions)
+fi
+_tokyo_hook
+`,
+	"fish": `function __tokyo_hook --on-variable PWD
+    %[1]s hook run
+end
+__tokyo_hook
+`,
+}
+
+func newHookCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "hook",
+		Short: i18n.T("Switch profiles automatically when entering a directory"),
+		Long: `Switch to the profiles a directory declares whenever the shell enters it,
+in the style of direnv. Add the hook to your shell's startup file:
+
+  eval "$(tokyo hook zsh)"       # ~/.zshrc
+  eval "$(tokyo hook bash)"      # ~/.bashrc
+  tokyo hook fish | source       # ~/.config/fish/config.fish
+
+On every change of directory, the nearest .tokyo or .tokyo.yaml file in the
+directory or one of its parents is read and used as by 'tokyo use'.
+
+A .tokyo file is only acted on once you have allowed it with 'tokyo hook
+allow', and again after every change to it, so a cloned repository cannot
+switch your profiles on its own. Profiles that are already active are left
+alone; others are switched to, autosaving any changes to the live config
+first.`,
+	}
+
+	for _, shell := range []string{"bash", "fish", "zsh"} {
+		cmd.AddCommand(newHookShellCommand(shell))
+	}
+	cmd.AddCommand(newHookAllowCommand(), newHookDenyCommand(), newHookRunCommand())
+	return cmd
+}
+
+func newHookShellCommand(shell string) *cobra.Command {
+	return &cobra.Command{
+		Use:   shell,
+		Short: i18n.Sprintf("Print the %s hook", shell),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			self, err := os.Executable()
+			if err != nil {
+				self = "tokyo"
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), hookScripts[shell], shellQuote(self))
+			return nil
+		},
+	}
+}
+
+func newHookAllowCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "allow [dir]",
+		Short: i18n.T("Allow the hook to act on a .tokyo file"),
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, allowed, err := projectFile(args, true)
+			if err != nil {
+				return err
+			}
+			if _, err := project.Load(path); err != nil {
+				return err
+			}
+			if err := allowed.Allow(path); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Allowed %s\n", path)
+			return nil
+		},
+	}
+}
+
+func newHookDenyCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "deny [dir]",
+		Short: i18n.T("Stop the hook from acting on a .tokyo file"),
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, allowed, err := projectFile(args, true)
+			if err != nil {
+				return err
+			}
+			denied, err := allowed.Deny(path)
+			if err != nil {
+				return err
+			}
+			if !denied {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s was not allowed\n", path)
+				return nil
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Denied %s\n", path)
+			return nil
+		},
+	}
+}
+
+func newHookRunCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:    "run",
+		Short:  i18n.T("Switch to the profiles of the nearest allowed .tokyo file"),
+		Hidden: true,
+		Args:   cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			path, allowed, err := projectFile(nil, false)
+			if err != nil || path == "" {
+				return err
+			}
+			ok, err := allowed.Allowed(path)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Fprintf(cmd.ErrOrStderr(), "tokyo: %s is not allowed; run 'tokyo hook allow' to switch to its profiles\n", path)
+				return nil
+			}
+			f, err := project.Load(path)
+			if err != nil {
+				return err
+			}
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			// The hook runs at the prompt: its output, and that of switch
+			// hooks, goes to stderr. It cannot ask, so protected profiles are
+			// left to 'tokyo use'.
+			var protected string
+			confirm := func(t profile.Tool, name string) (profile.Tool, error) {
+				protected = name
+				return t, profile.CheckProtected(t, name)
+			}
+			switched, err := switchToProject(cmd, cfg, f, cmd.ErrOrStderr(), confirm)
+			for _, sw := range switched {
+				fmt.Fprintf(cmd.ErrOrStderr(), "tokyo: switched %s to %s%s\n", sw.tool, sw.profile, sw.note())
+			}
+			if errors.Is(err, profile.ErrProfileProtected) {
+				fmt.Fprintf(cmd.ErrOrStderr(), "tokyo: %s is protected; run 'tokyo use' to switch to it\n", protected)
+				return nil
+			}
+			return err
+		},
+	}
+}
+
+// findProjectFile finds the nearest .tokyo file from args[0], or else the
+// working directory. The path is "" when there is no .tokyo file and none is
+// required.
+func findProjectFile(args []string, required bool) (string, error) {
+	dir := "."
+	if len(args) == 1 {
+		dir = args[0]
+	}
+	path, err := project.Find(dir)
+	if err != nil {
+		return "", err
+	}
+	if path == "" && required {
+		return "", fmt.Errorf("no .tokyo file in %s or its parents", dir)
+	}
+	return path, nil
+}
+
+// projectFile is findProjectFile, also loading the allow-list.
+func projectFile(args []string, required bool) (string, *project.AllowList, error) {
+	path, err := findProjectFile(args, required)
+	if err != nil {
+		return "", nil, err
+	}
+	root, err := storeRoot()
+	if err != nil {
+		return "", nil, err
+	}
+	allowed, err := project.LoadAllowList(root)
+	if err != nil {
+		return "", nil, err
+	}
+	return path, allowed, nil
+}
@@ -0,0 +1,145 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+	"tokyo/pkg/project"
+)
+
+func TestHookSwitchesOnlyAllowedProjects(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		if err := os.WriteFile(configPath, []byte(`{"profile":"`+name+`"}`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := profile.Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := profile.Switch(tool, "personal"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, ".tokyo"), []byte("claude: work\n"), 0o600); err != nil {
+		t.Fatalf("write .tokyo: %v", err)
+	}
+	nested := filepath.Join(repo, "src")
+	if err := os.Mkdir(nested, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	t.Chdir(nested)
+
+	oldOut, oldErr := rootCmd.OutOrStdout(), rootCmd.ErrOrStderr()
+	t.Cleanup(func() {
+		rootCmd.SetOut(oldOut)
+		rootCmd.SetErr(oldErr)
+		rootCmd.SetArgs(nil)
+	})
+	run := func(args ...string) (string, string) {
+		t.Helper()
+		var stdout, stderr bytes.Buffer
+		rootCmd.SetOut(&stdout)
+		rootCmd.SetErr(&stderr)
+		rootCmd.SetArgs(args)
+		if err := Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return stdout.String(), stderr.String()
+	}
+	active := func() string {
+		t.Helper()
+		name, err := profile.ActiveProfile(tool)
+		if err != nil {
+			t.Fatalf("ActiveProfile: %v", err)
+		}
+		return name
+	}
+
+	if out, _ := run("hook", "zsh"); !strings.Contains(out, "hook run") || !strings.Contains(out, "chpwd_functions") {
+		t.Fatalf("zsh hook:\n%s", out)
+	}
+
+	if _, stderr := run("hook", "run"); !strings.Contains(stderr, "is not allowed") || active() != "personal" {
+		t.Fatalf("expected an unallowed .tokyo to be ignored, stderr %q, active %s", stderr, active())
+	}
+
+	run("hook", "allow")
+	if _, stderr := run("hook", "run"); !strings.Contains(stderr, "switched claude to work") || active() != "work" {
+		t.Fatalf("expected a switch to work, stderr %q, active %s", stderr, active())
+	}
+	if _, stderr := run("hook", "run"); stderr != "" {
+		t.Fatalf("expected no switch when work is active, stderr %q", stderr)
+	}
+
+	if err := os.WriteFile(filepath.Join(repo, ".tokyo"), []byte("claude: personal\n"), 0o600); err != nil {
+		t.Fatalf("rewrite .tokyo: %v", err)
+	}
+	if _, stderr := run("hook", "run"); !strings.Contains(stderr, "is not allowed") || active() != "work" {
+		t.Fatalf("expected a changed .tokyo to need allowing again, stderr %q", stderr)
+	}
+
+	if err := profile.UpdateMeta(tool, "personal", func(m *profile.Meta) error {
+		m.Tags = map[string]string{profile.ProtectedTag: ""}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+	run("hook", "allow")
+	if _, stderr := run("hook", "run"); !strings.Contains(stderr, "personal is protected") || active() != "work" {
+		t.Fatalf("expected the hook to leave a protected profile alone, stderr %q, active %s", stderr, active())
+	}
+	use := func(args ...string) error {
+		cmd := newUseCommand()
+		cmd.SetIn(strings.NewReader(""))
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(args)
+		return cmd.Execute()
+	}
+	if err := use(); err == nil || active() != "work" {
+		t.Fatalf("expected use to refuse a protected profile, got %v, active %s", err, active())
+	}
+	if err := use("--confirm-production"); err != nil || active() != "personal" {
+		t.Fatalf("use --confirm-production: %v, active %s", err, active())
+	}
+
+	run("hook", "deny", repo)
+	if out, _ := run("hook", "deny", repo); !strings.Contains(out, "was not allowed") {
+		t.Fatalf("deny output %q", out)
+	}
+}
+
+func TestHookAllowListUsesHome(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(profile.StoreRootEnv, "")
+	other := t.TempDir()
+	homeDir = other
+	t.Cleanup(func() { homeDir = "" })
+
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, ".tokyo"), []byte("claude: work\n"), 0o600); err != nil {
+		t.Fatalf("write .tokyo: %v", err)
+	}
+	cmd := newHookAllowCommand()
+	cmd.SetOut(io.Discard)
+	cmd.SetArgs([]string{repo})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("hook allow: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(other, ".config", "tokyo", project.AllowFileName)); err != nil {
+		t.Fatalf("expected the allow-list under --home: %v", err)
+	}
+}
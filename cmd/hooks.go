@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+
+	"github.com/spf13/cobra"
+)
+
+// runHook runs a configured shell hook with the tool and profile exported
+// as TOKYO_TOOL and TOKYO_PROFILE, writing its output to out. An empty hook
+// does nothing.
+func runHook(cmd *cobra.Command, out io.Writer, name, hook, tool, profileName string) error {
+	if hook == "" {
+		return nil
+	}
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	c := exec.CommandContext(cmd.Context(), shell, flag, hook)
+	c.Stdin = cmd.InOrStdin()
+	c.Stdout = out
+	c.Stderr = cmd.ErrOrStderr()
+	c.Env = append(os.Environ(), "TOKYO_TOOL="+tool, "TOKYO_PROFILE="+profileName)
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("%s hook: %w", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newImportFromCommand())
+}
+
+func newImportFromCommand() *cobra.Command {
+	var toolName string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "import-from <" + strings.Join(profile.ImportSources(), "|") + "> <dir>",
+		Short: i18n.T("Convert profiles kept by another switcher into tokyo profiles"),
+		Long: `Convert the profiles another configuration switcher keeps into tokyo
+profiles, for every enabled tool the source has profiles for.
+
+Sources:
+  ccswitch          cc-switch's directory (usually ~/.cc-switch), whose
+                    config.json lists Claude Code and Codex providers
+  claude-profiles   a directory with one subdirectory per profile, holding
+                    copies of the config files (settings.json for Claude
+                    Code; config.toml and auth.json for Codex)
+
+Provider and directory names are turned into valid profile names. Existing
+profiles are only replaced with --force.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var tools []profile.Tool
+			if toolName != "" {
+				t, ok := profile.LookupTool(toolName)
+				if !ok {
+					return fmt.Errorf("unknown tool: %q", toolName)
+				}
+				tools = []profile.Tool{withStoreFlags(t)}
+			} else {
+				cfg, err := config.Load()
+				if err != nil {
+					return err
+				}
+				tools = enabledTools(cfg)
+			}
+
+			total := 0
+			for _, t := range tools {
+				imported, err := profile.ImportFrom(cliTool(cmd, t), args[0], args[1], force)
+				if err != nil {
+					return fmt.Errorf("%s: %w", t.Name, err)
+				}
+				if len(imported) > 0 {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: imported %s\n", t.Name, strings.Join(imported, ", "))
+				}
+				total += len(imported)
+			}
+			if total == 0 {
+				return fmt.Errorf("no profiles found in %s", args[1])
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&toolName, "tool", "t", "", "Only import profiles for this tool")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite existing profiles")
+
+	return cmd
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"fmt"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newRestoreIntentCommand())
+}
+
+func newRestoreIntentCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "restore-intent",
+		Short: i18n.T("Return every tool to the profile it is meant to be on"),
+		Long: `With the restore_intent setting on, every switch meant to last, made with
+'tokyo <tool> switch' or through 'tokyo serve', records its profile as the one
+the tool is meant to be on. Temporary switches do not: those of 'tokyo exec'
+and 'switch --for'.
+
+restore-intent switches each tool that is on another profile back to the one
+it is meant to be on, autosaving the live config first. A time-boxed switch
+that is still running is left to end on its own. Nothing is printed unless
+something is switched, so it is cheap to run from a login shell, to undo a
+temporary switch that a crash or a killed 'tokyo exec' left behind:
+
+  tokyo config set restore_intent true
+  echo 'tokyo restore-intent' >> ~/.profile`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if !cfg.RestoreIntent {
+				return nil
+			}
+			for _, t := range profile.Tools() {
+				if !cfg.ToolEnabled(t.Name) {
+					continue
+				}
+				t = cliTool(cmd, t)
+				restored, err := profile.RestoreIntent(t)
+				if err != nil {
+					return fmt.Errorf("%s: %w", t.Name, err)
+				}
+				if restored != "" {
+					fmt.Fprintf(cmd.ErrOrStderr(), "tokyo: switched %s back to %s\n", t.Name, restored)
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func TestRestoreIntentCommand(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		if err := os.WriteFile(configPath, []byte(`{"profile":"`+name+`"}`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := profile.Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+
+	run := func(cmd *cobra.Command, args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		cmd.SetErr(&out)
+		cmd.SetArgs(args)
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	// Off by default: switches record nothing and nothing is restored.
+	run(newSwitchCommand(tool), "work", "--strategy", profile.SwitchOverwrite)
+	if intended, _ := profile.Intent(tool); intended != "" {
+		t.Fatalf("intent recorded with restore_intent off: %q", intended)
+	}
+
+	run(newConfigCommand(), "set", "restore_intent", "true")
+	run(newSwitchCommand(tool), "work", "--strategy", profile.SwitchOverwrite)
+	// Left on personal, as by a tokyo exec that was killed.
+	if err := profile.Switch(tool, "personal"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	if out := run(newRestoreIntentCommand()); out != "tokyo: switched claude back to work\n" {
+		t.Fatalf("restore-intent printed %q", out)
+	}
+	if active, _ := profile.ActiveProfile(tool); active != "work" {
+		t.Fatalf("active = %q, want work", active)
+	}
+	if out := run(newRestoreIntentCommand()); out != "" {
+		t.Fatalf("second restore-intent printed %q", out)
+	}
+}
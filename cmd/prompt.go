@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// confirm asks a yes/no question on the command's streams. Anything other
+// than "y" or "yes" counts as no.
+func confirm(cmd *cobra.Command, question string) (bool, error) {
+	switch ask(cmd, question+" [y/N]") {
+	case "y", "yes":
+		return true, nil
+	}
+	return false, nil
+}
+
+// ask prints question on the command's streams and returns the answer in
+// lower case, or "" when there is none.
+func ask(cmd *cobra.Command, question string) string {
+	return strings.ToLower(askExact(cmd, question))
+}
+
+// askExact is ask with the answer's case kept, for answers such as profile
+// names that must match exactly.
+func askExact(cmd *cobra.Command, question string) string {
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s ", question)
+	line, _ := bufio.NewReader(cmd.InOrStdin()).ReadString('\n')
+	return strings.TrimSpace(line)
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"tokyo/pkg/client"
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newPublishCommand(), newPullCommand())
+}
+
+func newPublishCommand() *cobra.Command {
+	var to string
+	var token string
+
+	cmd := &cobra.Command{
+		Use:   "publish <tool>/<profile> [--to <url>]",
+		Short: i18n.T("Publish a profile to a registry server"),
+		Long: `Publish a profile to the registry of a tokyo server started with
+'tokyo serve --registry <dir> --token <token>'. Each publish becomes a new
+version, numbered from 1, that other machines install with 'tokyo pull'.
+
+The server is --to, or else the remote setting (TOKYO_REMOTE); the token is
+--token, or else serve.token (TOKYO_TOKEN).`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t, name, version, err := parseRegistryRef(args[0])
+			if err != nil {
+				return err
+			}
+			if version != 0 {
+				return errors.New("versions are assigned by the server; drop the @version")
+			}
+			t = cliTool(cmd, t)
+			c, url, err := registryClient(to, token)
+			if err != nil {
+				return err
+			}
+
+			var bundle bytes.Buffer
+			if err := profile.Export(t, []string{name}, &bundle); err != nil {
+				return err
+			}
+			published, err := c.Publish(cmd.Context(), t.Name, name, bundle.Bytes())
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Published %s/%s version %d to %s\n", t.Name, name, published, url)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", "Registry server URL (default: the remote setting)")
+	cmd.Flags().StringVar(&token, "token", "", "Bearer token for the server (default: serve.token)")
+
+	return cmd
+}
+
+func newPullCommand() *cobra.Command {
+	var from string
+	var token string
+	var force bool
+
+	cmd := &cobra.Command{
+		Use:   "pull <tool>/<profile>[@<version>] [--from <url>]",
+		Short: i18n.T("Install a profile from a registry server"),
+		Long: `Install a profile published to a tokyo server's registry. Without @<version>
+the latest version is installed; pin a version for reproducible setups:
+
+  tokyo pull claude/work --from https://tokyo.corp
+  tokyo pull claude/work@3 --from https://tokyo.corp
+
+The server is --from, or else the remote setting (TOKYO_REMOTE). An
+existing profile of the same name is only replaced with --force.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t, name, version, err := parseRegistryRef(args[0])
+			if err != nil {
+				return err
+			}
+			t = cliTool(cmd, t)
+			c, _, err := registryClient(from, token)
+			if err != nil {
+				return err
+			}
+
+			bundle, pulled, err := c.Pull(cmd.Context(), t.Name, name, version)
+			if err != nil {
+				return err
+			}
+			info, err := profile.ReadBundleInfo(bytes.NewReader(bundle))
+			if err != nil {
+				return err
+			}
+			if len(info.Profiles) != 1 || info.Profiles[0] != name {
+				return fmt.Errorf("%w: server sent %v instead of %s", profile.ErrInvalidBundle, info.Profiles, name)
+			}
+			if _, err := profile.Import(t, bytes.NewReader(bundle), force); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Pulled %s/%s version %d\n", t.Name, name, pulled)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&from, "from", "", "Registry server URL (default: the remote setting)")
+	cmd.Flags().StringVar(&token, "token", "", "Bearer token for the server (default: serve.token)")
+	cmd.Flags().BoolVarP(&force, "force", "f", false, "Replace an existing profile")
+
+	return cmd
+}
+
+// parseRegistryRef splits "<tool>/<profile>[@<version>]". A missing version
+// is 0.
+func parseRegistryRef(ref string) (profile.Tool, string, int, error) {
+	toolName, rest, ok := strings.Cut(ref, "/")
+	if !ok {
+		return profile.Tool{}, "", 0, fmt.Errorf("expected <tool>/<profile>, got %q", ref)
+	}
+	t, ok := profile.LookupTool(toolName)
+	if !ok {
+		return profile.Tool{}, "", 0, fmt.Errorf("unknown tool: %q", toolName)
+	}
+	name, v, pinned := strings.Cut(rest, "@")
+	if err := profile.ValidateProfileName(name); err != nil {
+		return profile.Tool{}, "", 0, err
+	}
+	version := 0
+	if pinned {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return profile.Tool{}, "", 0, fmt.Errorf("version must be a positive number, got %q", v)
+		}
+		version = n
+	}
+	return t, name, version, nil
+}
+
+// registryClient returns a client for url, or the remote setting when url
+// is empty, authenticating with token or else serve.token.
+func registryClient(url, token string) (*client.Client, string, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, "", err
+	}
+	if url == "" {
+		url = cfg.Remote
+	}
+	if url == "" {
+		return nil, "", errors.New("no registry server given (use --from/--to or tokyo config set remote <url>)")
+	}
+	if token == "" {
+		token = cfg.Serve.Token
+	}
+	c, err := client.New(url, client.WithToken(token))
+	if err != nil {
+		return nil, "", err
+	}
+	return c, url, nil
+}
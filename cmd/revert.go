@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"time"
+
+	"tokyo/api"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+// revertInterval is how often 'tokyo serve' looks for switches back that
+// are due.
+const revertInterval = 30 * time.Second
+
+func newSwitchBackCommand(t profile.Tool) *cobra.Command {
+	var cancel bool
+	var wait bool
+
+	cmd := &cobra.Command{
+		Use:   "switch-back",
+		Short: i18n.Sprintf("End a time-boxed %s switch now", t.DisplayName),
+		Long: `'switch <profile> --for <duration>' switches to a profile and schedules the
+switch back to the one that was active. The switch back is kept on disk, and
+made by a timer started with the switch, by 'tokyo serve', or by the next
+tokyo command run once it is due, whichever comes first. It is dropped when
+another profile has been switched to since.
+
+switch-back makes the switch back now; with --cancel, the temporary profile
+stays active instead.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			if wait {
+				return waitForRevert(cmd.Context(), t)
+			}
+			r, ok, err := profile.PendingRevert(t)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return errors.New("no time-boxed switch to end")
+			}
+			if cancel {
+				if err := profile.CancelRevert(t); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Staying on %s\n", r.From)
+				return nil
+			}
+			r, done, err := profile.ApplyRevert(t, time.Now(), true)
+			if err != nil {
+				return err
+			}
+			if !done {
+				return fmt.Errorf("%s is no longer active; the switch back to %s was dropped", r.From, r.Profile)
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Switched back to %s\n", r.Profile)
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&cancel, "cancel", false, "Stay on the temporary profile")
+	cmd.Flags().BoolVar(&wait, "wait", false, "Wait until the switch back is due and make it")
+	_ = cmd.Flags().MarkHidden("wait")
+
+	return cmd
+}
+
+// formatRevertTime formats the time of a switch back, with the date only
+// when it is not today.
+func formatRevertTime(at, now time.Time) string {
+	at, now = at.Local(), now.Local()
+	if y, m, d := at.Date(); y == now.Year() && m == now.Month() && d == now.Day() {
+		return at.Format("15:04")
+	}
+	return at.Format("Jan 2 15:04")
+}
+
+// startRevertTimer starts a process that waits for the switch back of t and
+// makes it, detached so that it outlives the switch command.
+var startRevertTimer = func(t profile.Tool) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	ws := workspace
+	if ws == "" {
+		ws = profile.DefaultWorkspace
+	}
+	args := []string{"--workspace", ws}
+	if homeDir != "" {
+		args = append(args, "--home", homeDir)
+	}
+	if systemStore {
+		args = append(args, "--system")
+	}
+	args = append(args, t.Name, "switch-back", "--wait")
+	timer := exec.Command(exe, args...)
+	timer.SysProcAttr = detachedProcAttr()
+	if err := timer.Start(); err != nil {
+		return err
+	}
+	return timer.Process.Release()
+}
+
+// waitForRevert sleeps until the switch back of t is due and makes it. It
+// reads the switch back again after each sleep, so one rescheduled or
+// cancelled meanwhile is followed.
+func waitForRevert(ctx context.Context, t profile.Tool) error {
+	for {
+		r, ok, err := profile.PendingRevert(t)
+		if err != nil || !ok {
+			return err
+		}
+		if wait := time.Until(r.At); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(wait):
+			}
+			continue
+		}
+		_, _, err = profile.ApplyRevert(t, time.Now(), false)
+		return err
+	}
+}
+
+// applyDueReverts makes the switches back that are due, in case no timer or
+// server was there to make them.
+func applyDueReverts(cmd *cobra.Command) {
+	if cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd {
+		return
+	}
+	for _, t := range profile.Tools() {
+		r, done, err := profile.ApplyRevert(cliTool(cmd, t), time.Now(), false)
+		if done {
+			fmt.Fprintf(cmd.ErrOrStderr(), "tokyo: switched %s back to %s\n", t.Name, r.Profile)
+		}
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: switching %s back to %s: %v\n", t.Name, r.Profile, err)
+		}
+	}
+}
+
+// runReverts makes the switches back of the tools h serves as they fall due,
+// until ctx is done.
+func runReverts(ctx context.Context, h *api.Server, interval time.Duration, errOut io.Writer) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if err := h.ApplyReverts(time.Now()); err != nil {
+			fmt.Fprintf(errOut, "switch back: %v\n", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+func TestSwitchForAndSwitchBack(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	timers := 0
+	oldTimer := startRevertTimer
+	startRevertTimer = func(profile.Tool) error { timers++; return nil }
+	t.Cleanup(func() { startRevertTimer = oldTimer })
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		if err := os.WriteFile(configPath, []byte(`{"profile":"`+name+`"}`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := profile.Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	run := func(cmdArgs ...string) (string, error) {
+		cmd := newSwitchCommand(tool)
+		if cmdArgs[0] == "switch-back" {
+			cmd, cmdArgs = newSwitchBackCommand(tool), cmdArgs[1:]
+		}
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&out)
+		cmd.SetArgs(cmdArgs)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+	active := func() string {
+		name, err := profile.ActiveProfile(tool)
+		if err != nil {
+			t.Fatalf("ActiveProfile: %v", err)
+		}
+		return name
+	}
+
+	out, err := run("personal", "--for", "2h", "--strategy", profile.SwitchOverwrite)
+	if err != nil {
+		t.Fatalf("switch --for: %v", err)
+	}
+	if !strings.HasPrefix(out, "Switching back to work at ") || timers != 1 {
+		t.Fatalf("switch --for printed %q and started %d timers", out, timers)
+	}
+	if active() != "personal" {
+		t.Fatalf("active = %q, want personal", active())
+	}
+	if out, err = run("switch-back"); err != nil || out != "Switched back to work\n" {
+		t.Fatalf("switch-back = %q, %v", out, err)
+	}
+	if active() != "work" {
+		t.Fatalf("active = %q, want work", active())
+	}
+	if _, err = run("switch-back"); err == nil {
+		t.Fatalf("expected switch-back without a pending switch back to fail")
+	}
+
+	if _, err = run("personal", "--for", "30m", "--strategy", profile.SwitchOverwrite); err != nil {
+		t.Fatalf("switch --for: %v", err)
+	}
+	if out, err = run("switch-back", "--cancel"); err != nil || out != "Staying on personal\n" {
+		t.Fatalf("switch-back --cancel = %q, %v", out, err)
+	}
+	if _, ok, _ := profile.PendingRevert(tool); ok || active() != "personal" {
+		t.Fatalf("switch-back --cancel left a switch back pending or changed profile")
+	}
+
+	if _, err = run("work", "--for", "30m", "--strategy", profile.SwitchOverwrite); err != nil {
+		t.Fatalf("switch --for: %v", err)
+	}
+	if _, err = run("work", "--strategy", profile.SwitchOverwrite); err != nil {
+		t.Fatalf("switch: %v", err)
+	}
+	if _, ok, _ := profile.PendingRevert(tool); ok {
+		t.Fatalf("expected a plain switch to cancel the switch back")
+	}
+	if _, err = run("personal", "--for", "-1h", "--strategy", profile.SwitchOverwrite); err == nil {
+		t.Fatalf("expected a negative duration to be refused")
+	}
+}
+
+func TestFormatRevertTime(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.Local)
+	if got := formatRevertTime(now.Add(2*time.Hour), now); got != "11:00" {
+		t.Fatalf("same day = %q, want 11:00", got)
+	}
+	if got := formatRevertTime(now.Add(24*time.Hour), now); got != "Mar 2 09:00" {
+		t.Fatalf("next day = %q, want Mar 2 09:00", got)
+	}
+}
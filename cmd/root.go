@@ -1,23 +1,232 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
 	"github.com/spf13/cobra"
 )
 
 // Version is set by goreleaser via ldflags
 var Version = "dev"
 
+// language is resolved during package initialization, before the init
+// functions build the commands, so their descriptions are translated.
+var language = setupLanguage()
+
 var rootCmd = &cobra.Command{
 	Use:     "tokyo",
-	Short:   "Tokyo - Manage Claude Code and Codex configuration profiles",
+	Short:   i18n.T("Tokyo - Manage Claude Code and Codex configuration profiles"),
 	Long:    `Tokyo is a CLI tool for managing Claude Code and Codex configuration profiles.`,
 	Version: Version,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := resolveWorkspace(cmd); err != nil {
+			return err
+		}
+		resolveLimits()
+		recoverSwitches(cmd)
+		applyDueReverts(cmd)
+		return nil
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		return cmd.Help()
 	},
+	// Execute prints errors itself so that they can be translated.
+	SilenceErrors: true,
+}
+
+// setupLanguage selects the language configured in config.yaml or
+// TOKYO_LANGUAGE, falling back to the locale.
+func setupLanguage() string {
+	var configured string
+	if cfg, err := config.Load(); err == nil {
+		configured = cfg.Language
+	}
+	lang := i18n.Detect(configured)
+	i18n.SetLanguage(lang)
+	return lang
+}
+
+// localizeError returns the message of err in the selected language.
+func localizeError(err error) string {
+	if format, args, ok := profile.UserMessage(err); ok {
+		return i18n.Sprintf(format, args...)
+	}
+	return err.Error()
+}
+
+// homeDir is the --home flag: the home directory holding the managed config
+// files and the tokyo store, for managing another account's profiles.
+var homeDir string
+
+// workspace is the selected workspace: the --workspace flag, or else the
+// workspace setting. Empty means the default set of stores.
+var workspace string
+
+// systemStore is the --system flag: work on the machine-wide store of
+// read-only profiles instead of the user's, as an administrator.
+var systemStore bool
+
+// retryPolicy is the configured switch retry policy; nil means the default.
+var retryPolicy *profile.RetryPolicy
+
+// maxFileSize is the configured config file size limit, as
+// profile.Tool.MaxFileSize takes it.
+var maxFileSize int64
+
+func init() {
+	rootCmd.PersistentFlags().Bool("debug", false, "Log every file operation to stderr")
+	rootCmd.PersistentFlags().StringVar(&homeDir, "home", "", "Manage the config files and store under this home directory instead of $HOME")
+	rootCmd.PersistentFlags().String("workspace", "", "Use this workspace's profiles instead of the configured one")
+	rootCmd.PersistentFlags().BoolVar(&systemStore, "system", false, "Manage the machine-wide store of read-only profiles instead of your own (for administrators)")
+}
+
+// resolveWorkspace selects the workspace for the command being run. A broken
+// config file is left for the commands that read it to report.
+func resolveWorkspace(cmd *cobra.Command) error {
+	if cmd.Flags().Changed("workspace") {
+		name, _ := cmd.Flags().GetString("workspace")
+		if name == profile.DefaultWorkspace {
+			name = ""
+		} else if err := profile.ValidateWorkspaceName(name); err != nil {
+			return err
+		}
+		workspace = name
+		return nil
+	}
+	workspace = ""
+	if cfg, err := config.Load(); err == nil {
+		workspace = cfg.Workspace
+	}
+	return nil
+}
+
+// resolveLimits reads the switch retry policy and the file size limit from
+// the config. A broken config file is left for the commands that read it to
+// report.
+func resolveLimits() {
+	retryPolicy = nil
+	maxFileSize = 0
+	cfg, err := config.Load()
+	if err != nil {
+		return
+	}
+	if policy, err := cfg.RetryPolicy(); err == nil {
+		retryPolicy = &policy
+	}
+	if limit, err := cfg.FileSizeLimit(); err == nil {
+		maxFileSize = limit
+	}
+}
+
+// recoverSwitches finishes switches that a crashed tokyo process left
+// half done, before the command looks at the live config. Failures are
+// reported but do not stop the command, which may be the one to fix them.
+func recoverSwitches(cmd *cobra.Command) {
+	if cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd {
+		return
+	}
+	for _, t := range profile.Tools() {
+		recovered, err := profile.Recover(cliTool(cmd, t))
+		for _, r := range recovered {
+			if r.Completed {
+				fmt.Fprintf(cmd.ErrOrStderr(), "tokyo: completed an interrupted switch of %s to %s\n", t.Name, r.Profile)
+			} else {
+				fmt.Fprintf(cmd.ErrOrStderr(), "tokyo: rolled back an interrupted switch of %s to %s\n", t.Name, r.Profile)
+			}
+		}
+		if err != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: recovering an interrupted %s switch: %v\n", t.Name, err)
+		}
+	}
+}
+
+// cliTool returns t as the global flags configure it: in the selected
+// workspace, under --home when set, and logging to stderr with --debug.
+func cliTool(cmd *cobra.Command, t profile.Tool) profile.Tool {
+	t = withStoreFlags(t)
+	if debug, _ := cmd.Flags().GetBool("debug"); !debug {
+		return t
+	}
+	handler := slog.NewTextHandler(cmd.ErrOrStderr(), &slog.HandlerOptions{Level: slog.LevelDebug})
+	return t.WithLogger(slog.New(handler))
+}
+
+// withStoreFlags returns t under --home when it is set, in the selected
+// workspace or the system store with --system, with the configured retry
+// policy and file size limit.
+func withStoreFlags(t profile.Tool) profile.Tool {
+	if homeDir != "" {
+		t = t.WithHome(homeDir)
+	}
+	if retryPolicy != nil {
+		t = t.WithRetry(*retryPolicy)
+	}
+	if maxFileSize != 0 {
+		t = t.WithMaxFileSize(maxFileSize)
+	}
+	if systemStore {
+		return t.WithSystemStore()
+	}
+	return t.WithWorkspace(workspace)
+}
+
+// storeRoot returns tokyo's directory for files shared by every tool, such as
+// tokens.json: $TOKYO_HOME when set, else .config/tokyo under --home when it
+// is set, else profile.DefaultStoreRoot.
+func storeRoot() (string, error) {
+	if homeDir != "" && os.Getenv(profile.StoreRootEnv) == "" {
+		return filepath.Join(homeDir, ".config", "tokyo"), nil
+	}
+	return profile.DefaultStoreRoot()
+}
+
+// allTools returns every built-in tool as withStoreFlags configures it.
+func allTools() []profile.Tool {
+	tools := profile.Tools()
+	for i, t := range tools {
+		tools[i] = withStoreFlags(t)
+	}
+	return tools
+}
+
+// ExitError requests a specific process exit code without printing a message.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return "exit status " + strconv.Itoa(e.Code)
 }
 
 // Execute runs the root command
 func Execute() error {
-	return rootCmd.Execute()
+	// A broken config file is reported by the commands that read it; it must
+	// not stop 'tokyo config' from running.
+	if cfg, err := config.Load(); err == nil {
+		hideDisabledTools(rootCmd, cfg)
+	}
+	err := rootCmd.Execute()
+	var exitErr *ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		fmt.Fprintln(rootCmd.ErrOrStderr(), i18n.T("Error:"), localizeError(err))
+	}
+	return err
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+func ExitCode(err error) int {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.Code
+	}
+	return 1
 }
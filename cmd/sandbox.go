@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"fmt"
+
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newSandboxCommand())
+}
+
+func newSandboxCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "sandbox <tool> <profile>",
+		Short: i18n.T("Use a profile in one shell session without switching"),
+		Long: `Write a profile's config files into a new private temporary directory and
+print the export commands that point the tool at it (CLAUDE_CONFIG_DIR for
+Claude Code, CODEX_HOME for Codex), along with the profile's stored
+environment variables. The live config and the active profile are left
+alone, so other terminals keep using them:
+
+  eval "$(tokyo sandbox claude work)"
+
+The tool may write more files into the directory as it runs. Remove it when
+done: rm -rf "$CLAUDE_CONFIG_DIR".`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t, ok := profile.LookupTool(args[0])
+			if !ok {
+				return fmt.Errorf("unknown tool: %q", args[0])
+			}
+			t = cliTool(cmd, t)
+			_, env, err := profile.Sandbox(t, args[1])
+			if err != nil {
+				return err
+			}
+			printExports(cmd.OutOrStdout(), env)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestSandboxPrintsConfigDir(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"opus"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(profile.ClaudeTool(), "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	oldOut := rootCmd.OutOrStdout()
+	t.Cleanup(func() {
+		rootCmd.SetOut(oldOut)
+		rootCmd.SetArgs(nil)
+	})
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	rootCmd.SetArgs([]string{"sandbox", "claude", "work"})
+	if err := Execute(); err != nil {
+		t.Fatalf("sandbox: %v", err)
+	}
+
+	line := strings.TrimSpace(out.String())
+	dir, ok := strings.CutPrefix(line, "export CLAUDE_CONFIG_DIR=")
+	if !ok {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	dir = strings.Trim(dir, "'")
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	data, err := os.ReadFile(filepath.Join(dir, "settings.json"))
+	if err != nil || string(data) != `{"model":"opus"}` {
+		t.Fatalf("sandbox settings.json = %q, %v", data, err)
+	}
+}
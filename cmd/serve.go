@@ -11,6 +11,8 @@ import (
 	"time"
 
 	"tokyo/api"
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
 
 	"github.com/spf13/cobra"
 )
@@ -21,12 +23,46 @@ func init() {
 
 func newServeCommand() *cobra.Command {
 	var addr string
+	var token string
+	var readOnly bool
+	var basePath string
+	var registry string
 
 	cmd := &cobra.Command{
 		Use:   "serve",
-		Short: "Start the HTTP API server",
+		Short: i18n.T("Start the HTTP API server"),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			h := api.NewServer()
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if !cmd.Flags().Changed("addr") && cfg.Serve.Addr != "" {
+				addr = cfg.Serve.Addr
+			}
+			if !cmd.Flags().Changed("token") {
+				token = cfg.Serve.Token
+			}
+
+			root, err := storeRoot()
+			if err != nil {
+				return err
+			}
+			opts := []api.Option{
+				api.WithTools(enabledTools(cfg)...),
+				api.WithAuthToken(token),
+				api.WithTokens(root),
+				api.WithReadOnly(readOnly),
+				api.WithBasePath(basePath),
+				api.WithTheme(cfg.Serve.Theme),
+				api.WithRecordIntent(cfg.RestoreIntent),
+			}
+			if homeDir != "" {
+				opts = append(opts, api.WithHome(homeDir))
+			}
+			if registry != "" {
+				opts = append(opts, api.WithRegistry(registry))
+			}
+			h := api.NewServer(opts...)
 
 			srv := &http.Server{
 				Addr:              addr,
@@ -40,6 +76,13 @@ func newServeCommand() *cobra.Command {
 			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
 			defer stop()
 
+			policy, err := cfg.RetentionPolicy()
+			if err != nil {
+				return err
+			}
+			go runRetention(ctx, enabledTools(cfg), policy, retentionInterval, cmd.ErrOrStderr())
+			go runReverts(ctx, h, revertInterval, cmd.ErrOrStderr())
+
 			errCh := make(chan error, 1)
 			go func() {
 				errCh <- srv.ListenAndServe()
@@ -51,6 +94,9 @@ func newServeCommand() *cobra.Command {
 			case <-ctx.Done():
 				shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 				defer cancel()
+				if err := h.Drain(shutdownCtx); err != nil {
+					fmt.Fprintln(cmd.ErrOrStderr(), "Warning: stopping with requests still changing profiles")
+				}
 				_ = srv.Shutdown(shutdownCtx)
 				return nil
 			case err := <-errCh:
@@ -63,6 +109,12 @@ func newServeCommand() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&addr, "addr", "a", ":8080", "Address to listen on")
+	cmd.Flags().StringVar(&token, "token", "", "Require this bearer token on API requests")
+	cmd.Flags().BoolVar(&readOnly, "read-only", false, "Reject requests that modify profiles")
+	cmd.Flags().StringVar(&basePath, "base-path", "", "Serve everything under this URL prefix")
+	cmd.Flags().StringVar(&registry, "registry", "", "Keep profiles published with 'tokyo publish' in this directory")
+
+	cmd.AddCommand(newServeTokenCommand())
 
 	return cmd
 }
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"tokyo/api"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func newShareCommand(t profile.Tool) *cobra.Command {
+	var to string
+	var token string
+	var ttl time.Duration
+	var qrCode bool
+
+	cmd := &cobra.Command{
+		Use:   "share <profile> [--ttl <duration>]",
+		Short: i18n.Sprintf("Print a one-time download link for a %s profile", t.DisplayName),
+		Long: `Ask a tokyo server started with an auth token for a link that downloads a
+bundle of the profile once, then stops working. The link also stops working
+when --ttl runs out (at most 24h) or the server restarts. The link ends in
+the #sha256= of the bundle, so a server reached over https can be installed
+from directly with 'tokyo <tool> import --from-url <link>'.
+
+The profile is the one stored on the server. The server is --to, or else the
+remote setting (TOKYO_REMOTE); the token is --token, or else serve.token
+(TOKYO_TOKEN). With --qr, the link is also drawn as a QR code to scan with a
+phone.
+
+  tokyo claude share work --ttl 10m --qr`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			if err := profile.ValidateProfileName(args[0]); err != nil {
+				return err
+			}
+			if ttl < 0 || ttl > api.MaxShareTTL {
+				return fmt.Errorf("--ttl must be at most %s", api.MaxShareTTL)
+			}
+			c, _, err := registryClient(to, token)
+			if err != nil {
+				return err
+			}
+			link, err := c.Share(cmd.Context(), t.Name, args[0], ttl)
+			if err != nil {
+				return err
+			}
+			url := link.URL + "#sha256=" + link.SHA256
+			fmt.Fprintln(cmd.OutOrStdout(), url)
+			fmt.Fprintf(cmd.ErrOrStderr(), "The link works once, until %s\n", link.Expires.Local().Format(time.DateTime))
+			if qrCode {
+				return writeQR(cmd.OutOrStdout(), url)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVar(&to, "to", "", "Server URL (default: the remote setting)")
+	cmd.Flags().StringVar(&token, "token", "", "Bearer token for the server (default: serve.token)")
+	cmd.Flags().DurationVar(&ttl, "ttl", api.DefaultShareTTL, "How long the link works")
+	cmd.Flags().BoolVar(&qrCode, "qr", false, "Also draw the link as a QR code")
+
+	return cmd
+}
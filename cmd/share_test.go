@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"tokyo/api"
+	"tokyo/pkg/profile"
+)
+
+func TestShareCommand(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	serverTool := profile.ClaudeTool().WithHome(t.TempDir())
+	if err := profile.SaveFiles(serverTool, "work", map[string][]byte{"settings.json": []byte(`{}`)}, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	srv := httptest.NewServer(api.NewServer(api.WithTools(serverTool), api.WithAuthToken("secret")))
+	defer srv.Close()
+
+	cmd := newShareCommand(profile.ClaudeTool())
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"work", "--to", srv.URL, "--token", "secret", "--ttl", "1m"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("share: %v", err)
+	}
+	link := strings.TrimSpace(out.String())
+	if !strings.HasPrefix(link, srv.URL+"/share/") || !strings.Contains(link, "#sha256=") {
+		t.Fatalf("unexpected link %q", link)
+	}
+	resp, err := http.Get(link)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("download: %s", resp.Status)
+	}
+
+	cmd = newShareCommand(profile.ClaudeTool())
+	cmd.SetOut(io.Discard)
+	cmd.SetErr(io.Discard)
+	cmd.SetArgs([]string{"work", "--to", srv.URL, "--token", "secret", "--ttl", "48h"})
+	if err := cmd.Execute(); err == nil {
+		t.Fatal("expected an error for a ttl over the maximum")
+	}
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"fmt"
+
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func newStoreCommand(t profile.Tool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "store",
+		Short: i18n.Sprintf("Inspect and maintain the %s profile store", t.DisplayName),
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "show",
+			Short: i18n.T("Show store layout settings and blob usage"),
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				settings, err := profile.ReadStoreSettings(t)
+				if err != nil {
+					return err
+				}
+				count, size, err := profile.BlobStats(t)
+				if err != nil {
+					return err
+				}
+				compression := settings.Compression
+				if compression == profile.CompressionNone {
+					compression = "none"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "content-addressed: %t\n", settings.ContentAddressed)
+				compare := settings.Compare
+				if compare == profile.CompareBytes {
+					compare = "bytes"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "compression: %s\n", compression)
+				fmt.Fprintf(cmd.OutOrStdout(), "compare: %s\n", compare)
+				fmt.Fprintf(cmd.OutOrStdout(), "follow-symlinks: %t\n", settings.FollowDirSymlinks)
+				switchMode := settings.SwitchMode
+				if switchMode == profile.SwitchModeCopy {
+					switchMode = "copy"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "switch-mode: %s\n", switchMode)
+				fmt.Fprintf(cmd.OutOrStdout(), "hardlinks: %t\n", settings.HardLinks)
+				fmt.Fprintf(cmd.OutOrStdout(), "blobs: %d (%d bytes)\n", count, size)
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "dedup",
+			Short: i18n.T("Switch to content-addressed storage and migrate existing profiles"),
+			Long: `Store each distinct file payload once under blobs/<sha256>, with profiles
+holding a manifest of hashes. Existing profiles are migrated in place.`,
+			Args: cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				return profile.EnableContentAddressing(t)
+			},
+		},
+		&cobra.Command{
+			Use:   "compress <none|gzip>",
+			Short: i18n.T("Set compression for newly saved payloads"),
+			Long: `Set how newly saved profile payloads are compressed. Existing payloads
+stay readable in whatever form they were written and are converted the next
+time the profile is saved.`,
+			Args:      cobra.ExactArgs(1),
+			ValidArgs: []string{"none", "gzip"},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				return profile.SetCompression(t, args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "compare <bytes|json>",
+			Short: i18n.T("Set how live config files are compared with profiles"),
+			Long: `Set how live config files are compared with profiles. With json, files that
+parse as JSON count as unchanged when only key order or whitespace differ, so
+a tool re-serializing its config does not mark the profile as modified. Other
+files are always compared byte for byte.`,
+			Args:      cobra.ExactArgs(1),
+			ValidArgs: []string{"bytes", "json"},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				return profile.SetCompare(t, args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "follow-symlinks <on|off>",
+			Short: i18n.T("Allow symlinked directories on the way to the config files"),
+			Long: `Allow or forbid symlinked directories between your home directory and the
+config files, as created by dotfile managers that link ~/.claude or ~/.codex
+into a repository. Tokyo replaces config files by renaming over them, so with
+this on, switches write wherever the symlink points: only turn it on for links
+you control. Symlinked config files themselves are rejected unless tokyo made
+them in symlink switch mode.`,
+			Args:      cobra.ExactArgs(1),
+			ValidArgs: []string{"on", "off"},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				switch args[0] {
+				case "on":
+					return profile.SetFollowDirSymlinks(t, true)
+				case "off":
+					return profile.SetFollowDirSymlinks(t, false)
+				}
+				return fmt.Errorf("expected on or off, got %q", args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "switch-mode <copy|symlink>",
+			Short: i18n.T("Set whether switches copy profile files or link to them"),
+			Long: `Set how switching replaces the live config files. With copy, the default, the
+profile's files are copied into place. With symlink, each live file becomes a
+symlink into the active profile, so switching only repoints the links and the
+tool's own edits land in the profile directly.
+
+Changing the mode migrates the live files of the active profile, which must
+not have unsaved changes. Symlink mode needs every profile to keep its files
+as-is: it cannot be combined with content-addressed or compressed storage,
+and profiles based on another profile must be saved in full to be switched to.`,
+			Args:      cobra.ExactArgs(1),
+			ValidArgs: []string{"copy", "symlink"},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				return profile.SetSwitchMode(t, args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "hardlinks <on|off>",
+			Short: i18n.T("Hard-link files identical to one in another profile when saving"),
+			Long: `With hard links on, saving a profile stores each file that is identical to the
+same file in another profile as a hard link to it instead of a copy, so many
+profiles sharing a large CLAUDE.md or prompt take its space once. Profiles
+never see each other's changes: tokyo replaces stored files rather than
+writing into them. Files on a file system without hard links are copied as
+before. Hard links cannot be combined with symlink switching.`,
+			Args:      cobra.ExactArgs(1),
+			ValidArgs: []string{"on", "off"},
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				switch args[0] {
+				case "on":
+					return profile.SetHardLinks(t, true)
+				case "off":
+					return profile.SetHardLinks(t, false)
+				}
+				return fmt.Errorf("expected on or off, got %q", args[0])
+			},
+		},
+		newStoreIgnoreCommand(t),
+		&cobra.Command{
+			Use:   "prune",
+			Short: i18n.T("Remove blobs no profile references"),
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				removed, err := profile.PruneBlobs(t)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Removed %d unreferenced blob(s).\n", removed)
+				return nil
+			},
+		},
+	)
+
+	return cmd
+}
+
+func newStoreIgnoreCommand(t profile.Tool) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "ignore",
+		Short: i18n.T("List the rules for content that does not count as a modification"),
+		Long: `Ignore rules drop content from both the live config and the profile before
+they are compared, so fields the tool rewrites on its own do not mark the
+profile as modified. A rule is either a JSON pointer such as
+/feedbackSurveyState, applied to JSON files, or re:<regexp>, matched against
+every line of any config file.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			settings, err := profile.ReadStoreSettings(t)
+			if err != nil {
+				return err
+			}
+			for _, rule := range t.Ignore {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s (built in)\n", rule)
+			}
+			for _, rule := range settings.Ignore {
+				fmt.Fprintln(cmd.OutOrStdout(), rule)
+			}
+			return nil
+		},
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "add <rule>",
+			Short: i18n.T("Add an ignore rule"),
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				return profile.AddIgnoreRule(t, args[0])
+			},
+		},
+		&cobra.Command{
+			Use:   "remove <rule>",
+			Short: i18n.T("Remove an ignore rule"),
+			Args:  cobra.ExactArgs(1),
+			RunE: func(cmd *cobra.Command, args []string) error {
+				t := cliTool(cmd, t)
+				return profile.RemoveIgnoreRule(t, args[0])
+			},
+		},
+	)
+	return cmd
+}
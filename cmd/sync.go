@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newSyncCommand())
+}
+
+func newSyncCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "sync",
+		Short: i18n.T("Move profiles between machines"),
+		Long: `Move profiles between machines that cannot reach a shared server, such as
+air-gapped ones, in a single passphrase-encrypted file carried on a USB stick.`,
+	}
+	cmd.AddCommand(newSyncBundleCommand(), newSyncUnbundleCommand())
+	return cmd
+}
+
+func newSyncBundleCommand() *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "bundle [<tool>/<profile>...]",
+		Short: i18n.T("Write the profile stores, or selected profiles, to an encrypted file"),
+		Long: `Write every tool's store, like 'tokyo backup', or only the given profiles to
+a file encrypted with a passphrase, for 'tokyo sync unbundle' on another
+machine. Selected profiles bring the profiles they are based on along.
+
+The passphrase is taken from TOKYO_PASSPHRASE, the askpass command, or the
+terminal, where it is asked for twice.
+
+  tokyo sync bundle -o /media/usb/tokyo.sealed
+  tokyo sync bundle claude/work codex/work -o /media/usb/work.sealed`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var archive bytes.Buffer
+			if len(args) == 0 {
+				if err := profile.Backup(allTools(), &archive); err != nil {
+					return err
+				}
+			} else {
+				selection := map[string][]string{}
+				for _, arg := range args {
+					t, name, version, err := parseRegistryRef(arg)
+					if err != nil {
+						return err
+					}
+					if version != 0 {
+						return fmt.Errorf("expected <tool>/<profile>, got %q", arg)
+					}
+					selection[t.Name] = append(selection[t.Name], name)
+				}
+				if err := profile.BackupProfiles(allTools(), selection, &archive); err != nil {
+					return err
+				}
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			pass, err := cfg.Passphrase().ReadNew(cmd.Context(), "Passphrase for the sync bundle")
+			if err != nil {
+				return err
+			}
+			sealed, err := profile.Seal(archive.Bytes(), pass)
+			if err != nil {
+				return err
+			}
+
+			if output == "-" {
+				_, err := cmd.OutOrStdout().Write(sealed)
+				return err
+			}
+			if err := os.WriteFile(output, sealed, 0o600); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.ErrOrStderr(), "Wrote sync bundle to %s\n", output)
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&output, "output", "o", "tokyo-sync.sealed", "Output file (- for stdout)")
+
+	return cmd
+}
+
+func newSyncUnbundleCommand() *cobra.Command {
+	var replace bool
+
+	cmd := &cobra.Command{
+		Use:   "unbundle <file> [--replace]",
+		Short: i18n.T("Read profiles from a file written by sync bundle"),
+		Long: `Decrypt a file written by 'tokyo sync bundle' and restore what it holds. Use -
+to read from stdin.
+
+Profiles that do not exist yet are added and existing ones are left alone,
+as with 'tokyo restore --merge'. With --replace, a bundle of whole stores
+replaces each tool's store; bundles of selected profiles can only be merged.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r io.Reader = cmd.InOrStdin()
+			if args[0] != "-" {
+				file, err := os.Open(args[0])
+				if err != nil {
+					return err
+				}
+				defer file.Close()
+				r = file
+			}
+			sealed, err := io.ReadAll(r)
+			if err != nil {
+				return err
+			}
+			if !profile.IsSealed(sealed) {
+				return fmt.Errorf("%s: %w", args[0], profile.ErrNotSealed)
+			}
+
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			pass, err := cfg.Passphrase().Read(cmd.Context(), "Passphrase for the sync bundle")
+			if err != nil {
+				return err
+			}
+			archive, err := profile.Unseal(sealed, pass)
+			if err != nil {
+				return err
+			}
+
+			mode := profile.RestoreMerge
+			if replace {
+				mode = profile.RestoreReplace
+			}
+			result, err := profile.Restore(allTools(), bytes.NewReader(archive), mode)
+			if err != nil {
+				return err
+			}
+			for _, p := range result.Restored {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: restored\n", p)
+			}
+			for _, p := range result.Skipped {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: skipped (already exists)\n", p)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&replace, "replace", false, "Replace each tool's store with the bundle's")
+
+	return cmd
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/passphrase"
+	"tokyo/pkg/profile"
+)
+
+func TestSyncBundleRoundTrip(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(passphrase.Env, "correct horse")
+	tool := profile.ClaudeTool()
+	for _, name := range []string{"work", "personal"} {
+		if err := profile.SaveFiles(tool, name, map[string][]byte{"settings.json": []byte(`{"model":"` + name + `"}`)}, false); err != nil {
+			t.Fatalf("SaveFiles: %v", err)
+		}
+	}
+	sealed := filepath.Join(t.TempDir(), "work.sealed")
+
+	run := func(args ...string) (string, error) {
+		cmd := newSyncCommand()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+	if _, err := run("bundle", "claude/work", "-o", sealed); err != nil {
+		t.Fatalf("bundle: %v", err)
+	}
+	data, err := os.ReadFile(sealed)
+	if err != nil {
+		t.Fatalf("read bundle: %v", err)
+	}
+	if !profile.IsSealed(data) || bytes.Contains(data, []byte("settings.json")) {
+		t.Fatal("expected an encrypted bundle")
+	}
+
+	// Another machine.
+	t.Setenv("HOME", t.TempDir())
+	out, err := run("unbundle", sealed)
+	if err != nil {
+		t.Fatalf("unbundle: %v", err)
+	}
+	if strings.TrimSpace(out) != "claude/work: restored" {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+	profiles, err := profile.List(tool)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0] != "work" {
+		t.Fatalf("profiles = %v, want [work]", profiles)
+	}
+
+	t.Setenv(passphrase.Env, "wrong")
+	if _, err := run("unbundle", sealed); !errors.Is(err, profile.ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+	"tokyo/pkg/token"
+
+	"github.com/spf13/cobra"
+)
+
+func newServeTokenCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "token",
+		Short: i18n.T("Manage the API tokens the server accepts"),
+		Long: `Manage named API tokens for 'tokyo serve', kept hashed in tokens.json in
+the tokyo directory. Once the file exists the server requires a token on
+/api/ routes, alongside any --token; tokens created or revoked while it runs
+take effect at once. Read-only tokens cannot modify anything, and tokens
+created with --tool cannot touch other tools' profiles.`,
+	}
+	cmd.AddCommand(newTokenCreateCommand(), newTokenListCommand(), newTokenRevokeCommand())
+	return cmd
+}
+
+func loadTokens() (*token.Store, error) {
+	root, err := storeRoot()
+	if err != nil {
+		return nil, err
+	}
+	return token.Load(root)
+}
+
+func newTokenCreateCommand() *cobra.Command {
+	var readOnly bool
+	var expires string
+	var tools []string
+
+	cmd := &cobra.Command{
+		Use:   "create <name>",
+		Short: i18n.T("Create an API token and print it"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var expiry time.Time
+			if expires != "" {
+				age, err := config.ParseAge(expires)
+				if err != nil {
+					return err
+				}
+				expiry = time.Now().Add(age).Truncate(time.Second)
+			}
+			for _, name := range tools {
+				if _, ok := profile.LookupTool(name); !ok {
+					return fmt.Errorf("unknown tool: %q", name)
+				}
+			}
+			scope := token.ScopeReadWrite
+			if readOnly {
+				scope = token.ScopeRead
+			}
+			tokens, err := loadTokens()
+			if err != nil {
+				return err
+			}
+			secret, err := tokens.Create(args[0], scope, tools, expiry)
+			if errors.Is(err, token.ErrExists) {
+				return fmt.Errorf("token %q already exists (revoke it first)", args[0])
+			}
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), secret)
+			fmt.Fprintln(cmd.ErrOrStderr(), "Store this token now; it cannot be shown again.")
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&readOnly, "read-only", false, "Only allow requests that do not modify anything")
+	cmd.Flags().StringSliceVar(&tools, "tool", nil, "Only allow this tool (repeatable; default: every tool)")
+	cmd.Flags().StringVar(&expires, "expires", "", "Stop accepting the token after this long (e.g. 30d or 12h)")
+
+	return cmd
+}
+
+func newTokenListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: i18n.T("List API tokens"),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tokens, err := loadTokens()
+			if err != nil {
+				return err
+			}
+			list := tokens.List()
+			if len(list) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "No tokens")
+				return nil
+			}
+			now := time.Now()
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "NAME\tSCOPE\tTOOLS\tCREATED\tEXPIRES")
+			for _, t := range list {
+				expiry := "never"
+				if !t.Expires.IsZero() {
+					expiry = t.Expires.Local().Format(time.DateTime)
+					if t.Expired(now) {
+						expiry += " (expired)"
+					}
+				}
+				tools := "all"
+				if len(t.Tools) > 0 {
+					tools = strings.Join(t.Tools, ",")
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", t.Name, t.Scope, tools, t.Created.Local().Format(time.DateTime), expiry)
+			}
+			return w.Flush()
+		},
+	}
+}
+
+func newTokenRevokeCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "revoke <name>",
+		Short: i18n.T("Revoke an API token"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			tokens, err := loadTokens()
+			if err != nil {
+				return err
+			}
+			revoked, err := tokens.Revoke(args[0])
+			if err != nil {
+				return err
+			}
+			if !revoked {
+				return fmt.Errorf("token %q not found", args[0])
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Revoked %s\n", args[0])
+			return nil
+		},
+	}
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"tokyo/pkg/profile"
+	"tokyo/pkg/token"
+)
+
+func TestServeTokenCommands(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	run := func(args ...string) (string, error) {
+		t.Helper()
+		cmd := newServeTokenCommand()
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetErr(&bytes.Buffer{})
+		cmd.SetArgs(args)
+		cmd.SilenceErrors = true
+		cmd.SilenceUsage = true
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	secret, err := run("create", "dashboard", "--read-only", "--expires", "30d")
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	secret = strings.TrimSpace(secret)
+	if _, err := run("create", "dashboard"); err == nil {
+		t.Fatalf("expected a duplicate name to be rejected")
+	}
+
+	root, err := profile.DefaultStoreRoot()
+	if err != nil {
+		t.Fatalf("DefaultStoreRoot: %v", err)
+	}
+	tokens, err := token.Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	tok, ok := tokens.Authenticate(secret, time.Now())
+	if !ok || !tok.ReadOnly() || tok.Expires.IsZero() {
+		t.Fatalf("Authenticate = %+v, %v; want a read-only token with an expiry", tok, ok)
+	}
+
+	out, err := run("list")
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if !strings.Contains(out, "dashboard") || !strings.Contains(out, token.ScopeRead) || strings.Contains(out, secret) {
+		t.Fatalf("unexpected list output:\n%s", out)
+	}
+
+	if out, err := run("revoke", "dashboard"); err != nil || out != "Revoked dashboard\n" {
+		t.Fatalf("revoke = %q, %v", out, err)
+	}
+	if _, err := run("revoke", "dashboard"); err == nil {
+		t.Fatalf("expected revoking an unknown token to fail")
+	}
+	if out, _ := run("list"); out != "No tokens\n" {
+		t.Fatalf("list after revoke = %q", out)
+	}
+}
+
+func TestServeTokenCommandsUseHome(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv(profile.StoreRootEnv, "")
+	other := t.TempDir()
+	homeDir = other
+	t.Cleanup(func() { homeDir = "" })
+
+	cmd := newServeTokenCommand()
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetArgs([]string{"create", "ci"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	tokens, err := token.Load(filepath.Join(other, ".config", "tokyo"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(tokens.List()) != 1 {
+		t.Fatalf("expected the token under --home, got %d", len(tokens.List()))
+	}
+}
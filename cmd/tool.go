@@ -1,8 +1,19 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
 
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
 	"tokyo/pkg/profile"
 
 	"github.com/spf13/cobra"
@@ -16,94 +27,941 @@ func init() {
 func newToolCommand(t profile.Tool) *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   t.Name,
-		Short: fmt.Sprintf("Manage %s configuration profiles", t.DisplayName),
+		Short: i18n.Sprintf("Manage %s configuration profiles", t.DisplayName),
 	}
 
 	cmd.AddCommand(
 		newSwitchCommand(t),
+		newSwitchBackCommand(t),
 		newCurrentCommand(t),
+		newIsActiveCommand(t),
 		newListCommand(t),
 		newSaveCommand(t),
 		newDeleteCommand(t),
+		newEnvCommand(t),
+		newTagCommand(t),
+		newAnnotateCommand(t),
+		newGrepCommand(t),
+		newExportCommand(t),
+		newImportCommand(t),
+		newLockCommand(t, true),
+		newLockCommand(t, false),
+		newStoreCommand(t),
+		newWhichCommand(t),
+		newLintCommand(t),
+		newAdoptCommand(t),
+		newRenameCommand(t),
+		newCopyCommand(t),
+		newShareCommand(t),
+		newWatchCommand(t),
+		newFsckCommand(t),
 	)
 
 	return cmd
 }
 
 func newSwitchCommand(t profile.Tool) *cobra.Command {
-	return &cobra.Command{
-		Use:   "switch <profile>",
-		Short: fmt.Sprintf("Switch %s to a profile", t.DisplayName),
-		Args:  cobra.ExactArgs(1),
+	var strategy string
+	var printEnv bool
+	var confirmProduction bool
+	var duration time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "switch [profile]",
+		Short: i18n.Sprintf("Switch %s to a profile", t.DisplayName),
+		Args:  cobra.MaximumNArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return profile.Switch(t, args[0])
+			t := cliTool(cmd, t)
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			var profileName string
+			if len(args) == 1 {
+				profileName = args[0]
+			} else if profileName = cfg.DefaultProfile(t.Name); profileName == "" {
+				return errors.New(i18n.Sprintf("no profile given and no default set (tokyo config set default_profiles.%s <profile>)", t.Name))
+			}
+
+			if t, err = confirmProtected(cmd, cfg, t, profileName, "switch to", confirmProduction); err != nil {
+				return err
+			}
+			if strategy == "" {
+				if strategy, err = askDriftStrategy(cmd, cfg, t, profileName); err != nil {
+					return err
+				}
+			}
+			if err := profile.ValidateSwitchStrategy(strategy); err != nil {
+				return err
+			}
+
+			// With --print-env, stdout is for eval; hooks talk on stderr.
+			hookOut := cmd.OutOrStdout()
+			if printEnv {
+				hookOut = cmd.ErrOrStderr()
+			}
+			if err := runHook(cmd, hookOut, "pre_switch", cfg.Hooks.PreSwitch, t.Name, profileName); err != nil {
+				return err
+			}
+			if cmd.Flags().Changed("for") {
+				r, err := profile.SwitchFor(t, profileName, strategy, duration, time.Now())
+				if err != nil {
+					return err
+				}
+				if err := startRevertTimer(t); err != nil {
+					fmt.Fprintf(cmd.ErrOrStderr(), "Warning: no timer for the switch back (%v); the next tokyo command after it is due makes it\n", err)
+				}
+				fmt.Fprintf(hookOut, "Switching back to %s at %s (tokyo %s switch-back ends it sooner)\n", r.Profile, formatRevertTime(r.At, time.Now()), t.Name)
+			} else {
+				if err := profile.SwitchWithStrategy(t, profileName, strategy); err != nil {
+					return err
+				}
+				// A switch without --for is meant to last.
+				if err := profile.CancelRevert(t); err != nil {
+					return err
+				}
+				if cfg.RestoreIntent {
+					if err := profile.RecordIntent(t, profileName); err != nil {
+						return err
+					}
+				}
+			}
+			if err := warnToolVersion(cmd, t, profileName); err != nil {
+				return err
+			}
+			if err := runHook(cmd, hookOut, "post_switch", cfg.Hooks.PostSwitch, t.Name, profileName); err != nil {
+				return err
+			}
+			meta, err := profile.ReadMeta(t, profileName)
+			if err != nil {
+				return err
+			}
+			if note := meta.AnnotationSummary(cfg.Switch.Annotations); note != "" {
+				fmt.Fprintf(hookOut, "Switched to %s — %s\n", profileName, note)
+			}
+			if printEnv {
+				printExports(cmd.OutOrStdout(), meta.Env)
+			}
+			return nil
 		},
 	}
+
+	cmd.Flags().StringVar(&strategy, "strategy", "", "How to handle changes to the live config: keep, overwrite or merge (default: ask)")
+	cmd.Flags().BoolVar(&printEnv, "print-env", false, "Print export commands for the profile's stored environment variables, for eval")
+	cmd.Flags().BoolVar(&confirmProduction, "confirm-production", false, "Switch to a profile tagged protected without typing its name")
+	cmd.Flags().DurationVar(&duration, "for", 0, "Switch back to the active profile after this long, such as 2h")
+	return cmd
+}
+
+// confirmProtected returns t made to act on profile name when it is tagged
+// protected: confirmed must be set or the user must type the name. With
+// prompts turned off, only confirmed will do. Without the returned tool,
+// profile refuses the action with ErrProfileProtected.
+func confirmProtected(cmd *cobra.Command, cfg config.Config, t profile.Tool, name, action string, confirmed bool) (profile.Tool, error) {
+	if confirmed {
+		return t.WithProtectedConfirmed(), nil
+	}
+	err := profile.CheckProtected(t, name)
+	if !errors.Is(err, profile.ErrProfileProtected) {
+		return t, err
+	}
+	refused := fmt.Errorf("profile %q is protected (pass --confirm-production, or type its name when asked)", name)
+	if !cfg.ShouldConfirm() {
+		return t, refused
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s is tagged %s.\n", name, profile.ProtectedTag)
+	if askExact(cmd, fmt.Sprintf("Type its name to %s it:", action)) != name {
+		return t, refused
+	}
+	return t.WithProtectedConfirmed(), nil
+}
+
+// askDriftStrategy asks how to handle live changes made since the active
+// profile was switched to. Without drift, or with prompts turned off, the
+// live files are overwritten; they are autosaved either way.
+func askDriftStrategy(cmd *cobra.Command, cfg config.Config, t profile.Tool, target string) (string, error) {
+	status, err := profile.Current(t)
+	if err != nil {
+		return "", err
+	}
+	active, modified := strings.CutSuffix(status, " (modified)")
+	if !modified || !cfg.ShouldConfirm() {
+		return profile.SwitchOverwrite, nil
+	}
+
+	fmt.Fprintf(cmd.ErrOrStderr(), "The live config has changed since %s was switched to.\n", active)
+	switch ask(cmd, fmt.Sprintf("[k]eep the changes in %s, [o]verwrite them, [m]erge them into %s, or [a]bort?", active, target)) {
+	case "k", "keep":
+		return profile.SwitchKeep, nil
+	case "o", "overwrite":
+		return profile.SwitchOverwrite, nil
+	case "m", "merge":
+		return profile.SwitchMerge, nil
+	}
+	return "", errors.New("aborted")
 }
 
 func newCurrentCommand(t profile.Tool) *cobra.Command {
-	return &cobra.Command{
+	var verbose bool
+
+	cmd := &cobra.Command{
 		Use:   "current",
-		Short: fmt.Sprintf("Show current %s profile", t.DisplayName),
+		Short: i18n.Sprintf("Show current %s profile", t.DisplayName),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
 			status, err := profile.Current(t)
 			if err != nil {
 				return err
 			}
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			name, modified := strings.CutSuffix(status, " (modified)")
+			if modified && colorEnabled(cmd, cfg) {
+				status = name + " \x1b[33m(modified)\x1b[0m"
+			}
 			fmt.Fprintln(cmd.OutOrStdout(), status)
+
+			if !verbose || !modified {
+				return nil
+			}
+			diffs, err := profile.Diff(t, name)
+			if err != nil {
+				return err
+			}
+			return writeFileDetail(cmd.OutOrStdout(), diffs)
+		},
+	}
+
+	cmd.Flags().BoolVarP(&verbose, "verbose", "v", false, "List the files that differ from the active profile")
+	return cmd
+}
+
+// newIsActiveCommand answers whether a profile is active with its exit code
+// alone, for scripts.
+func newIsActiveCommand(t profile.Tool) *cobra.Command {
+	var allowModified bool
+
+	cmd := &cobra.Command{
+		Use:   "is-active <profile>",
+		Short: i18n.Sprintf("Check whether a %s profile is active", t.DisplayName),
+		Long: `Exit with 0 when the profile is active and the live config matches it, and
+with 1 otherwise, printing nothing, for shell scripts and CI steps:
+
+  tokyo claude is-active work || tokyo claude switch work
+
+With --allow-modified, changes made to the live config since the switch are
+ignored. An unknown profile is an error.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			active, err := profile.IsActive(t, args[0], allowModified)
+			if err != nil {
+				return err
+			}
+			if !active {
+				cmd.SilenceUsage = true
+				return &ExitError{Code: 1}
+			}
 			return nil
 		},
 	}
+
+	cmd.Flags().BoolVar(&allowModified, "allow-modified", false, "Also succeed when the live config has changed since the switch")
+
+	return cmd
+}
+
+// writeFileDetail prints one line per file that differs from its stored
+// copy: status, path, size change and abbreviated hashes.
+func writeFileDetail(w io.Writer, diffs []profile.FileDiff) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, d := range diffs {
+		if d.Status == profile.FileUnchanged {
+			continue
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%+d B\t%s -> %s\n",
+			strings.ReplaceAll(d.Status, "_", " "), d.Path, d.LiveSize-d.ProfileSize,
+			shortHash(d.ProfileHash), shortHash(d.LiveHash))
+	}
+	return tw.Flush()
+}
+
+func shortHash(hash string) string {
+	if hash == "" {
+		return "-"
+	}
+	return hash[:min(len(hash), 12)]
 }
 
 func newListCommand(t profile.Tool) *cobra.Command {
-	return &cobra.Command{
+	var tags []string
+	var tree bool
+	var long bool
+
+	cmd := &cobra.Command{
 		Use:   "list",
-		Short: fmt.Sprintf("List %s profiles", t.DisplayName),
+		Short: i18n.Sprintf("List %s profiles", t.DisplayName),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			profiles, err := profile.List(t)
+			t := cliTool(cmd, t)
+			profiles, err := profile.ListTagged(t, tags)
 			if err != nil {
 				return err
 			}
+			if tree {
+				return writeProfileTree(cmd.OutOrStdout(), t, profiles)
+			}
+			if long {
+				return writeProfileUsage(cmd.OutOrStdout(), t, profiles, time.Now())
+			}
 			for _, p := range profiles {
 				fmt.Fprintln(cmd.OutOrStdout(), p)
 			}
 			return nil
 		},
 	}
+
+	cmd.Flags().StringArrayVar(&tags, "tag", nil, "Only list profiles with this tag (key or key=value, repeatable)")
+	cmd.Flags().BoolVar(&tree, "tree", false, "Show the files stored in each profile and whether they match the live config")
+	cmd.Flags().BoolVarP(&long, "long", "l", false, "Show how long each profile has been active, how often it was switched to and when it was last used")
+
+	return cmd
+}
+
+// writeProfileTree prints each profile with the files it stores below it,
+// marking which ones match the live config, so that profiles differing only
+// in one file are easy to tell apart.
+func writeProfileTree(w io.Writer, t profile.Tool, profiles []string) error {
+	active, err := profile.ActiveProfile(t)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, p := range profiles {
+		diffs, err := profile.Diff(t, p)
+		if err != nil {
+			return err
+		}
+		system, err := profile.IsSystemProfile(t, p)
+		if err != nil {
+			return err
+		}
+		var notes []string
+		if p == active {
+			notes = append(notes, "active")
+		}
+		if system {
+			notes = append(notes, "system")
+		}
+		if len(notes) > 0 {
+			fmt.Fprintf(tw, "%s (%s)\n", p, strings.Join(notes, ", "))
+		} else {
+			fmt.Fprintln(tw, p)
+		}
+		var stored []profile.FileDiff
+		for _, d := range diffs {
+			if d.Status != profile.FileNotStored {
+				stored = append(stored, d)
+			}
+		}
+		for i, d := range stored {
+			branch := "├── "
+			if i == len(stored)-1 {
+				branch = "└── "
+			}
+			state := "differs from live"
+			switch d.Status {
+			case profile.FileUnchanged:
+				state = "matches live"
+			case profile.FileMissing:
+				state = "not live"
+			}
+			fmt.Fprintf(tw, "%s%s\t%s\n", branch, d.Name, state)
+		}
+	}
+	return tw.Flush()
+}
+
+// writeProfileUsage prints profiles with their usage, most used first.
+func writeProfileUsage(w io.Writer, t profile.Tool, profiles []string, now time.Time) error {
+	usage, err := profile.UsageStats(t, now)
+	if err != nil {
+		return err
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "PROFILE\tACTIVE\tSWITCHES\tLAST USED")
+	for _, u := range usage {
+		if !slices.Contains(profiles, u.Profile) {
+			continue
+		}
+		last := "never"
+		if !u.LastActive.IsZero() {
+			last = u.LastActive.Local().Format(time.DateTime)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\n", u.Profile, formatActive(u.Active), u.Activations, last)
+	}
+	return tw.Flush()
+}
+
+// formatActive rounds d to its two largest units, e.g. "3d 4h" or "25m".
+func formatActive(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return "0m"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh %dm", int(d/time.Hour), int(d%time.Hour/time.Minute))
+	default:
+		return fmt.Sprintf("%dd %dh", int(d/(24*time.Hour)), int(d%(24*time.Hour)/time.Hour))
+	}
 }
 
 func newSaveCommand(t profile.Tool) *cobra.Command {
 	var force bool
+	var from string
+	var current bool
+	var captureEnv []string
+	var fromURLs []string
 
 	cmd := &cobra.Command{
 		Use:   "save <profile>",
-		Short: fmt.Sprintf("Save current %s configuration as a profile", t.DisplayName),
-		Args:  cobra.ExactArgs(1),
+		Short: i18n.Sprintf("Save current %s configuration as a profile", t.DisplayName),
+		Long: `Save the live config as a profile.
+
+With --from-url, the profile is made of config files downloaded over HTTPS
+instead, such as one a team publishes for onboarding. Each URL must name the
+SHA-256 of its content, and nothing is saved unless every file matches:
+
+  tokyo claude save work --from-url 'https://example.com/settings.json#sha256=<hex>'`,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if current {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return profile.Save(t, args[0], force)
+			t := cliTool(cmd, t)
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if cfg.ShouldScanSecrets() && len(fromURLs) == 0 {
+				if err := warnSecrets(cmd, t); err != nil {
+					return err
+				}
+			}
+			var name string
+			switch {
+			case current:
+				if name, err = profile.SaveCurrent(t); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Saved live config into %s\n", name)
+			case len(fromURLs) > 0:
+				name = args[0]
+				var files map[string][]byte
+				if files, err = fetchProfileFiles(cmd.Context(), t, fromURLs); err == nil {
+					err = profile.SaveFiles(t, name, files, force)
+				}
+			case from != "":
+				name = args[0]
+				err = profile.SaveFrom(t, name, from, force)
+			default:
+				name = args[0]
+				err = profile.Save(t, name, force)
+			}
+			if err != nil {
+				return err
+			}
+			if err := recordToolVersion(cmd, t, name); err != nil {
+				return err
+			}
+
+			names := append(slices.Clone(cfg.CaptureEnv), captureEnv...)
+			if len(names) == 0 {
+				return nil
+			}
+			captured, err := profile.CaptureEnv(t, name, names, os.LookupEnv)
+			if err != nil {
+				return err
+			}
+			if len(captured) > 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "Captured %s\n", strings.Join(captured, ", "))
+			}
+			return nil
 		},
 	}
 
 	cmd.Flags().BoolVarP(&force, "force", "f", false, "Overwrite existing profile")
+	cmd.Flags().StringVar(&from, "from", "", "Only store files that differ from this base profile")
+	cmd.Flags().BoolVar(&current, "current", false, "Update the active profile with the live config")
+	cmd.Flags().StringSliceVar(&captureEnv, "capture-env", nil, "Store the current value of this environment variable with the profile (repeatable; adds to capture_env)")
+	cmd.Flags().StringArrayVar(&fromURLs, "from-url", nil, "Save this config file, fetched over HTTPS, instead of the live config; the URL ends in #sha256=<hex> (repeatable)")
+	cmd.MarkFlagsMutuallyExclusive("current", "from")
+	cmd.MarkFlagsMutuallyExclusive("current", "force")
+	cmd.MarkFlagsMutuallyExclusive("current", "from-url")
+	cmd.MarkFlagsMutuallyExclusive("from", "from-url")
 
 	return cmd
 }
 
-func newDeleteCommand(t profile.Tool) *cobra.Command {
+func newLintCommand(t profile.Tool) *cobra.Command {
+	return &cobra.Command{
+		Use:   "lint [profile]",
+		Short: i18n.Sprintf("Check %s config files for unknown or invalid settings", t.DisplayName),
+		Long: fmt.Sprintf(`Check the config files of a saved %s profile, or of the live config when
+no profile is given, against the JSON schemas tokyo ships for them. Typos
+such as "permisions" are reported instead of being silently ignored by the
+tool. Each problem is printed with the JSON pointer of the offending value,
+and lint exits with 1 when there are any.`, t.DisplayName),
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			problems, err := profile.Lint(t, name)
+			if err != nil {
+				return err
+			}
+			for _, p := range problems {
+				fmt.Fprintln(cmd.OutOrStdout(), p)
+			}
+			if len(problems) > 0 {
+				cmd.SilenceUsage = true
+				return &ExitError{Code: 1}
+			}
+			return nil
+		},
+	}
+}
+
+// warnSecrets warns on stderr about credentials in the live config outside
+// the fields the tool keeps its own in, before save stores them.
+func warnSecrets(cmd *cobra.Command, t profile.Tool) error {
+	findings, err := profile.ScanLiveSecrets(t)
+	if err != nil || len(findings) == 0 {
+		return err
+	}
+	for _, f := range findings {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s\n", f)
+	}
+	fmt.Fprintln(cmd.ErrOrStderr(), "These values will be stored in the profile. Move them out of the config, or turn this check off with 'tokyo config set scan_secrets false'.")
+	return nil
+}
+
+func newRenameCommand(t profile.Tool) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rename <profile> <new-name>",
+		Short: i18n.Sprintf("Rename a %s profile", t.DisplayName),
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			return profile.Rename(t, args[0], args[1])
+		},
+	}
+}
+
+func newCopyCommand(t profile.Tool) *cobra.Command {
+	return &cobra.Command{
+		Use:   "copy <profile> <new-profile>",
+		Short: i18n.Sprintf("Copy a %s profile", t.DisplayName),
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			return profile.Copy(t, args[0], args[1])
+		},
+	}
+}
+
+func newAdoptCommand(t profile.Tool) *cobra.Command {
 	return &cobra.Command{
-		Use:   "delete <profile>",
-		Short: fmt.Sprintf("Delete a %s profile", t.DisplayName),
+		Use:   "adopt <profile>",
+		Short: i18n.Sprintf("Save unmanaged %s config as a profile and make it current", t.DisplayName),
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cleared, err := profile.Delete(t, args[0])
+			t := cliTool(cmd, t)
+			return profile.Adopt(t, args[0])
+		},
+	}
+}
+
+func newWhichCommand(t profile.Tool) *cobra.Command {
+	return &cobra.Command{
+		Use:   "which <file>",
+		Short: i18n.Sprintf("Show which %s profiles match a live config file", t.DisplayName),
+		Long: fmt.Sprintf(`Show which saved %s profiles hold exactly the current content of a live
+config file, given its path or name (for example auth.json). The answer comes
+from the file contents, so it works even when current reports <custom>.`, t.DisplayName),
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			live, profiles, err := profile.Which(t, args[0])
+			if err != nil {
+				return err
+			}
+			if len(profiles) == 0 {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: no saved profile matches\n", live)
+				return nil
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", live, strings.Join(profiles, ", "))
+			return nil
+		},
+	}
+}
+
+func newDeleteCommand(t profile.Tool) *cobra.Command {
+	var match string
+	var yes bool
+	var confirmProduction bool
+
+	cmd := &cobra.Command{
+		Use:   "delete <profile> | --match <glob>",
+		Short: i18n.Sprintf("Delete a %s profile", t.DisplayName),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if match != "" {
+				return cobra.NoArgs(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			cfg, err := config.Load()
 			if err != nil {
 				return err
 			}
-			if cleared {
-				fmt.Fprintln(cmd.OutOrStdout(), "Deleted active profile; current profile is now <custom>.")
+			if match == "" {
+				t, err := confirmProtected(cmd, cfg, t, args[0], "delete", confirmProduction)
+				if err != nil {
+					return err
+				}
+				cleared, err := profile.Delete(t, args[0])
+				if err != nil {
+					return err
+				}
+				if cleared {
+					fmt.Fprintln(cmd.OutOrStdout(), "Deleted active profile; current profile is now <custom>.")
+				}
+				return nil
+			}
+
+			profiles, err := profile.Match(t, match)
+			if err != nil {
+				return err
+			}
+			if len(profiles) == 0 {
+				return fmt.Errorf("%w: no profile matches %q", profile.ErrProfileNotFound, match)
+			}
+			confirmed := map[string]profile.Tool{}
+			for _, p := range profiles {
+				if confirmed[p], err = confirmProtected(cmd, cfg, t, p, "delete", confirmProduction); err != nil {
+					return err
+				}
+			}
+			if !yes && cfg.ShouldConfirm() {
+				fmt.Fprintf(cmd.ErrOrStderr(), "About to delete %d profile(s): %s\n", len(profiles), strings.Join(profiles, ", "))
+				ok, err := confirm(cmd, "Proceed?")
+				if err != nil {
+					return err
+				}
+				if !ok {
+					return errors.New("aborted")
+				}
+			}
+
+			failed := 0
+			for _, p := range profiles {
+				cleared, err := profile.Delete(confirmed[p], p)
+				if err != nil {
+					failed++
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: failed: %v\n", p, err)
+					continue
+				}
+				if cleared {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: deleted (was active; current profile is now <custom>)\n", p)
+				} else {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: deleted\n", p)
+				}
+			}
+			if failed > 0 {
+				return fmt.Errorf("failed to delete %d of %d profile(s)", failed, len(profiles))
 			}
 			return nil
 		},
 	}
+
+	cmd.Flags().StringVar(&match, "match", "", "Delete every profile whose name matches this glob")
+	cmd.Flags().BoolVarP(&yes, "yes", "y", false, "Skip the confirmation prompt")
+	cmd.Flags().BoolVar(&confirmProduction, "confirm-production", false, "Delete profiles tagged protected without typing their names")
+
+	return cmd
+}
+
+func newEnvCommand(t profile.Tool) *cobra.Command {
+	var unset []string
+	var export bool
+
+	cmd := &cobra.Command{
+		Use:   "env <profile> [KEY=VALUE...]",
+		Short: i18n.Sprintf("Show or set environment variables stored with a %s profile", t.DisplayName),
+		Long: `Show or set environment variables stored with a profile.
+
+Stored variables are injected into the child process by 'tokyo exec --env'.
+Without assignments or --unset, the stored variables are printed; with
+--export, as shell commands to load them into the current shell:
+
+  eval "$(tokyo claude env work --export)"
+
+'save --capture-env NAME' (or the capture_env setting) stores the value a
+variable has when the profile is saved.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			name, assignments := args[0], args[1:]
+
+			if len(assignments) == 0 && len(unset) == 0 {
+				meta, err := profile.ReadMeta(t, name)
+				if err != nil {
+					return err
+				}
+				if export {
+					printExports(cmd.OutOrStdout(), meta.Env)
+					return nil
+				}
+				keys := make([]string, 0, len(meta.Env))
+				for k := range meta.Env {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s=%s\n", k, meta.Env[k])
+				}
+				return nil
+			}
+
+			return profile.UpdateMeta(t, name, func(meta *profile.Meta) error {
+				if meta.Env == nil {
+					meta.Env = map[string]string{}
+				}
+				for _, a := range assignments {
+					k, v, ok := strings.Cut(a, "=")
+					if !ok || k == "" {
+						return fmt.Errorf("invalid assignment %q (expected KEY=VALUE)", a)
+					}
+					meta.Env[k] = v
+				}
+				for _, k := range unset {
+					delete(meta.Env, k)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&unset, "unset", nil, "Remove a stored variable (repeatable)")
+	cmd.Flags().BoolVar(&export, "export", false, "Print the stored variables as export commands for eval")
+	cmd.MarkFlagsMutuallyExclusive("export", "unset")
+
+	return cmd
+}
+
+// printExports writes env as POSIX shell export commands, sorted by name.
+func printExports(w io.Writer, env map[string]string) {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "export %s=%s\n", k, shellQuote(env[k]))
+	}
+}
+
+// shellQuote quotes s for a POSIX shell.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func newTagCommand(t profile.Tool) *cobra.Command {
+	var remove []string
+
+	cmd := &cobra.Command{
+		Use:   "tag <profile> [key[=value]...]",
+		Short: i18n.Sprintf("Show or set tags on a %s profile", t.DisplayName),
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			name, tags := args[0], args[1:]
+
+			if len(tags) == 0 && len(remove) == 0 {
+				meta, err := profile.ReadMeta(t, name)
+				if err != nil {
+					return err
+				}
+				keys := make([]string, 0, len(meta.Tags))
+				for k := range meta.Tags {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					if v := meta.Tags[k]; v != "" {
+						fmt.Fprintf(cmd.OutOrStdout(), "%s=%s\n", k, v)
+					} else {
+						fmt.Fprintln(cmd.OutOrStdout(), k)
+					}
+				}
+				return nil
+			}
+
+			return profile.UpdateMeta(t, name, func(meta *profile.Meta) error {
+				if meta.Tags == nil {
+					meta.Tags = map[string]string{}
+				}
+				for _, tag := range tags {
+					k, v, err := profile.ParseTag(tag)
+					if err != nil {
+						return err
+					}
+					meta.Tags[k] = v
+				}
+				for _, k := range remove {
+					delete(meta.Tags, k)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&remove, "remove", nil, "Remove a tag by key (repeatable)")
+
+	return cmd
+}
+
+func newAnnotateCommand(t profile.Tool) *cobra.Command {
+	var remove []string
+
+	cmd := &cobra.Command{
+		Use:   "annotate <profile> [key=value...]",
+		Short: i18n.Sprintf("Show or set annotations on a %s profile", t.DisplayName),
+		Long: `Annotations note which account a profile belongs to, such as its org,
+billing account or plan. Switching to the profile prints them:
+
+  tokyo claude annotate work org=acme plan=Max
+  tokyo claude switch work
+  Switched to work — org: acme, plan: Max
+
+The switch.annotations setting picks which annotations are printed, and in
+what order; by default all of them are.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			name, annotations := args[0], args[1:]
+
+			if len(annotations) == 0 && len(remove) == 0 {
+				meta, err := profile.ReadMeta(t, name)
+				if err != nil {
+					return err
+				}
+				keys := make([]string, 0, len(meta.Annotations))
+				for k := range meta.Annotations {
+					keys = append(keys, k)
+				}
+				sort.Strings(keys)
+				for _, k := range keys {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s=%s\n", k, meta.Annotations[k])
+				}
+				return nil
+			}
+
+			return profile.UpdateMeta(t, name, func(meta *profile.Meta) error {
+				if meta.Annotations == nil {
+					meta.Annotations = map[string]string{}
+				}
+				for _, annotation := range annotations {
+					k, v, err := profile.ParseAnnotation(annotation)
+					if err != nil {
+						return err
+					}
+					meta.Annotations[k] = v
+				}
+				for _, k := range remove {
+					delete(meta.Annotations, k)
+				}
+				return nil
+			})
+		},
+	}
+
+	cmd.Flags().StringSliceVar(&remove, "remove", nil, "Remove an annotation by key (repeatable)")
+
+	return cmd
+}
+
+func newGrepCommand(t profile.Tool) *cobra.Command {
+	var ignoreCase bool
+	var profilesOnly bool
+
+	cmd := &cobra.Command{
+		Use:   "grep <pattern>",
+		Short: i18n.Sprintf("Search stored %s profile files", t.DisplayName),
+		Long: `Search the files stored in every profile for a regular expression.
+
+Matching lines are printed as profile:file:line: text, with credential-like
+values redacted. Use -l to print only the names of matching profiles.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			pattern := args[0]
+			if ignoreCase {
+				pattern = "(?i)" + pattern
+			}
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return fmt.Errorf("invalid pattern: %w", err)
+			}
+
+			matches, err := profile.Grep(t, re)
+			if err != nil {
+				return err
+			}
+
+			seen := map[string]bool{}
+			for _, m := range matches {
+				if profilesOnly {
+					if !seen[m.Profile] {
+						seen[m.Profile] = true
+						fmt.Fprintln(cmd.OutOrStdout(), m.Profile)
+					}
+					continue
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s:%s:%d: %s\n", m.Profile, m.File, m.Line, m.Text)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&ignoreCase, "ignore-case", "i", false, "Match case-insensitively")
+	cmd.Flags().BoolVarP(&profilesOnly, "profiles-with-matches", "l", false, "Print only the names of matching profiles")
+
+	return cmd
+}
+
+func newLockCommand(t profile.Tool, lock bool) *cobra.Command {
+	use, short := "lock <profile>", i18n.Sprintf("Protect a %s profile from delete and overwrite", t.DisplayName)
+	if !lock {
+		use, short = "unlock <profile>", i18n.Sprintf("Allow a locked %s profile to be deleted or overwritten", t.DisplayName)
+	}
+
+	return &cobra.Command{
+		Use:   use,
+		Short: short,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			return profile.SetLocked(t, args[0], lock)
+		},
+	}
 }
@@ -2,12 +2,18 @@ package cmd
 
 import (
 	"bytes"
+	"errors"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"tokyo/pkg/i18n"
 	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
 )
 
 func TestExecuteDoesNotDuplicateErrors(t *testing.T) {
@@ -155,3 +161,715 @@ func TestSwitchCommandSuccess(t *testing.T) {
 		t.Fatalf("expected work, got %q", status)
 	}
 }
+
+func TestTagAndListByTag(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+	if err := profile.Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+
+	tagCmd := newTagCommand(tool)
+	tagCmd.SetArgs([]string{"work", "billing=acme", "team=ai"})
+	if err := tagCmd.Execute(); err != nil {
+		t.Fatalf("tag command: %v", err)
+	}
+
+	cmd := newListCommand(tool)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--tag", "team=ai"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("list command: %v", err)
+	}
+
+	if strings.TrimSpace(out.String()) != "work" {
+		t.Fatalf("expected only 'work', got %q", out.String())
+	}
+}
+
+func TestSwitchPrintsAnnotations(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		if err := profile.Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+
+	annotate := newAnnotateCommand(tool)
+	annotate.SetArgs([]string{"work", "org=acme", "plan=Max", "account=billing-7"})
+	if err := annotate.Execute(); err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	annotate = newAnnotateCommand(tool)
+	annotate.SetArgs([]string{"work", "plan"})
+	if err := annotate.Execute(); err == nil {
+		t.Fatalf("expected an annotation without a value to be refused")
+	}
+
+	switchTo := func(name string) string {
+		cmd := newSwitchCommand(tool)
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SetArgs([]string{name, "--strategy", profile.SwitchOverwrite})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("switch %s: %v", name, err)
+		}
+		return out.String()
+	}
+	if got, want := switchTo("work"), "Switched to work — account: billing-7, org: acme, plan: Max\n"; got != want {
+		t.Fatalf("switch output = %q, want %q", got, want)
+	}
+	if got := switchTo("personal"); got != "" {
+		t.Fatalf("switch to a profile without annotations printed %q", got)
+	}
+
+	set := newConfigCommand()
+	set.SetArgs([]string{"set", "switch.annotations", "plan,org"})
+	if err := set.Execute(); err != nil {
+		t.Fatalf("config set: %v", err)
+	}
+	if got, want := switchTo("work"), "Switched to work — plan: Max, org: acme\n"; got != want {
+		t.Fatalf("switch output with switch.annotations = %q, want %q", got, want)
+	}
+
+	annotate = newAnnotateCommand(tool)
+	var out bytes.Buffer
+	annotate.SetOut(&out)
+	annotate.SetArgs([]string{"work", "--remove", "account"})
+	if err := annotate.Execute(); err != nil {
+		t.Fatalf("annotate --remove: %v", err)
+	}
+	annotate = newAnnotateCommand(tool)
+	annotate.SetOut(&out)
+	annotate.SetArgs([]string{"work"})
+	if err := annotate.Execute(); err != nil {
+		t.Fatalf("annotate: %v", err)
+	}
+	if got, want := out.String(), "org=acme\nplan=Max\n"; got != want {
+		t.Fatalf("annotations = %q, want %q", got, want)
+	}
+}
+
+func TestProtectedProfileNeedsConfirmation(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"prod", "dev"} {
+		if err := profile.Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	tag := newTagCommand(tool)
+	tag.SetArgs([]string{"prod", profile.ProtectedTag})
+	if err := tag.Execute(); err != nil {
+		t.Fatalf("tag: %v", err)
+	}
+
+	run := func(cmd *cobra.Command, input string, args ...string) error {
+		cmd.SetIn(strings.NewReader(input))
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(io.Discard)
+		cmd.SetArgs(args)
+		return cmd.Execute()
+	}
+	current := func() string {
+		status, err := profile.Current(tool)
+		if err != nil {
+			t.Fatalf("Current: %v", err)
+		}
+		return status
+	}
+
+	if err := run(newSwitchCommand(tool), "", "prod", "--strategy", profile.SwitchOverwrite); err == nil {
+		t.Fatalf("expected a switch to a protected profile to need confirmation")
+	}
+	if err := run(newSwitchCommand(tool), "dev\n", "prod", "--strategy", profile.SwitchOverwrite); err == nil {
+		t.Fatalf("expected the wrong name to be refused")
+	}
+	if err := run(newSwitchCommand(tool), "PROD\n", "prod", "--strategy", profile.SwitchOverwrite); err == nil {
+		t.Fatalf("expected the name in another case to be refused")
+	}
+	if got := current(); got == "prod" {
+		t.Fatalf("switched to prod without confirmation")
+	}
+	if err := run(newSwitchCommand(tool), "prod\n", "prod", "--strategy", profile.SwitchOverwrite); err != nil {
+		t.Fatalf("switch after typing the name: %v", err)
+	}
+	if got := current(); got != "prod" {
+		t.Fatalf("current = %q, want prod", got)
+	}
+	if err := run(newSwitchCommand(tool), "", "dev", "--strategy", profile.SwitchOverwrite); err != nil {
+		t.Fatalf("switch to an unprotected profile: %v", err)
+	}
+	if err := run(newSwitchCommand(tool), "", "prod", "--strategy", profile.SwitchOverwrite, "--confirm-production"); err != nil {
+		t.Fatalf("switch --confirm-production: %v", err)
+	}
+
+	if err := run(newDeleteCommand(tool), "", "prod"); err == nil {
+		t.Fatalf("expected deleting a protected profile to need confirmation")
+	}
+	if err := run(newDeleteCommand(tool), "", "--match", "p*", "--yes"); err == nil {
+		t.Fatalf("expected --yes not to confirm a protected profile")
+	}
+	if exists, _ := profile.Exists(tool, "prod"); !exists {
+		t.Fatalf("prod was deleted without confirmation")
+	}
+	if err := run(newDeleteCommand(tool), "", "prod", "--confirm-production"); err != nil {
+		t.Fatalf("delete --confirm-production: %v", err)
+	}
+	if exists, _ := profile.Exists(tool, "prod"); exists {
+		t.Fatalf("prod still exists after delete --confirm-production")
+	}
+}
+
+func TestGrepCommandListsProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"proxy":"http://OLD-proxy"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "legacy", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"proxy":"http://new-proxy"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	cmd := newGrepCommand(tool)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"-il", "old-proxy"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("grep command: %v", err)
+	}
+
+	if strings.TrimSpace(out.String()) != "legacy" {
+		t.Fatalf("expected only 'legacy', got %q", out.String())
+	}
+}
+
+func TestDeleteCommandMatch(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"tmp-1", "tmp-2", "work"} {
+		if err := profile.Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+
+	declined := newDeleteCommand(tool)
+	declined.SetIn(strings.NewReader("n\n"))
+	declined.SetOut(&bytes.Buffer{})
+	declined.SetErr(&bytes.Buffer{})
+	declined.SetArgs([]string{"--match", "tmp-*"})
+	if err := declined.Execute(); err == nil {
+		t.Fatalf("expected declined prompt to abort")
+	}
+
+	cmd := newDeleteCommand(tool)
+	var out bytes.Buffer
+	cmd.SetIn(strings.NewReader("y\n"))
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	cmd.SetArgs([]string{"--match", "tmp-*"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("delete command: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "tmp-1: deleted") || !strings.Contains(out.String(), "tmp-2: deleted") {
+		t.Fatalf("expected per-profile results, got %q", out.String())
+	}
+	profiles, _ := profile.List(tool)
+	if len(profiles) != 1 || profiles[0] != "work" {
+		t.Fatalf("expected only work left, got %v", profiles)
+	}
+}
+
+func TestExecuteTranslatesErrors(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	i18n.SetLanguage("ja")
+	oldErr := rootCmd.ErrOrStderr()
+	t.Cleanup(func() {
+		i18n.SetLanguage(language)
+		rootCmd.SetErr(oldErr)
+		rootCmd.SetArgs(nil)
+	})
+
+	var stderr bytes.Buffer
+	rootCmd.SetErr(&stderr)
+	rootCmd.SetArgs([]string{"claude", "switch", "missing"})
+
+	err := Execute()
+	if !errors.Is(err, profile.ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+	if want := `エラー: プロファイル "missing" が見つかりません`; !strings.Contains(stderr.String(), want) {
+		t.Fatalf("expected %q in stderr, got:\n%s", want, stderr.String())
+	}
+}
+
+func TestCurrentVerboseListsChangedFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"x"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cmd := newCurrentCommand(tool)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--verbose"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("current: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || lines[0] != "work (modified)" {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	fields := strings.Fields(lines[1])
+	if len(fields) != 7 || fields[0] != "modified" || fields[1] != configPath || fields[2] != "+11" {
+		t.Fatalf("unexpected file line: %q", lines[1])
+	}
+}
+
+func TestSwitchCommandAsksAboutDrift(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, p := range []struct{ name, content string }{{"personal", `{"model":"sonnet"}`}, {"work", `{"model":"opus"}`}} {
+		if err := os.WriteFile(configPath, []byte(p.content), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := profile.Save(tool, p.name, false); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"opus","theme":"dark"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cmd := newSwitchCommand(tool)
+	cmd.SetArgs([]string{"personal"})
+	cmd.SetIn(strings.NewReader("\n"))
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err == nil || err.Error() != "aborted" {
+		t.Fatalf("expected abort without an answer, got %v", err)
+	}
+
+	cmd = newSwitchCommand(tool)
+	cmd.SetArgs([]string{"personal"})
+	cmd.SetIn(strings.NewReader("m\n"))
+	cmd.SetErr(io.Discard)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("switch command: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if !strings.Contains(string(data), `"model": "sonnet"`) || !strings.Contains(string(data), `"theme": "dark"`) {
+		t.Fatalf("expected drift merged into personal, got %s", data)
+	}
+}
+
+func TestHomeFlagManagesAnotherHome(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	other := t.TempDir()
+	configPath := filepath.Join(other, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	oldOut := rootCmd.OutOrStdout()
+	t.Cleanup(func() {
+		homeDir = ""
+		rootCmd.SetOut(oldOut)
+		rootCmd.SetArgs(nil)
+	})
+
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetArgs([]string{"--home", other, "claude", "adopt", "service"})
+	if err := Execute(); err != nil {
+		t.Fatalf("adopt: %v", err)
+	}
+
+	status, err := profile.Current(profile.ClaudeTool().WithHome(other))
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "service" {
+		t.Fatalf("expected service under --home, got %q", status)
+	}
+	profiles, err := profile.List(profile.ClaudeTool())
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(profiles) != 0 {
+		t.Fatalf("expected $HOME's store to stay empty, got %v", profiles)
+	}
+}
+
+func TestSaveCapturesEnvAndSwitchPrintsIt(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("ANTHROPIC_BASE_URL", "https://gw.corp/it's")
+	t.Setenv("TOKYO_CAPTURE_ENV", "HTTPS_PROXY")
+	t.Setenv("HTTPS_PROXY", "http://proxy:3128")
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	save := newSaveCommand(tool)
+	var out bytes.Buffer
+	save.SetOut(&out)
+	save.SetArgs([]string{"work", "--capture-env", "ANTHROPIC_BASE_URL,UNSET_VAR"})
+	if err := save.Execute(); err != nil {
+		t.Fatalf("save command: %v", err)
+	}
+	if got := out.String(); got != "Captured HTTPS_PROXY, ANTHROPIC_BASE_URL\n" {
+		t.Fatalf("save output = %q", got)
+	}
+
+	sw := newSwitchCommand(tool)
+	out.Reset()
+	sw.SetOut(&out)
+	sw.SetArgs([]string{"work", "--print-env"})
+	if err := sw.Execute(); err != nil {
+		t.Fatalf("switch command: %v", err)
+	}
+	want := "export ANTHROPIC_BASE_URL='https://gw.corp/it'\\''s'\nexport HTTPS_PROXY='http://proxy:3128'\n"
+	if out.String() != want {
+		t.Fatalf("switch --print-env output = %q, want %q", out.String(), want)
+	}
+
+	env := newEnvCommand(tool)
+	out.Reset()
+	env.SetOut(&out)
+	env.SetArgs([]string{"work", "--export"})
+	if err := env.Execute(); err != nil {
+		t.Fatalf("env command: %v", err)
+	}
+	if out.String() != want {
+		t.Fatalf("env --export output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestIsActiveCommandExitCode(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"x":1}`), 0o600); err != nil {
+		t.Fatalf("modify config: %v", err)
+	}
+
+	run := func(args ...string) (string, error) {
+		cmd := newIsActiveCommand(tool)
+		var out bytes.Buffer
+		cmd.SetOut(&out)
+		cmd.SilenceErrors = true
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("work")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected exit 1 for modified profile, got %v", err)
+	}
+	if out != "" {
+		t.Fatalf("expected no output, got %q", out)
+	}
+	if _, err := run("work", "--allow-modified"); err != nil {
+		t.Fatalf("is-active --allow-modified: %v", err)
+	}
+	if _, err := run("missing"); err == nil || errors.As(err, &exitErr) {
+		t.Fatalf("expected an error for an unknown profile, got %v", err)
+	}
+}
+
+func TestLintCommandReportsTypos(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"permisions":{"allow":[]}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	cmd := newLintCommand(tool)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SilenceErrors = true
+	err := cmd.Execute()
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("expected exit 1, got %v", err)
+	}
+	if want := "settings.json: additional properties 'permisions' not allowed\n"; out.String() != want {
+		t.Fatalf("lint output = %q, want %q", out.String(), want)
+	}
+}
+
+func TestSaveWarnsAboutSecrets(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"env":{"GITHUB_TOKEN":"ghp_0123456789abcdefghij0123"}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	save := func(name string) string {
+		t.Helper()
+		cmd := newSaveCommand(tool)
+		var stderr bytes.Buffer
+		cmd.SetOut(io.Discard)
+		cmd.SetErr(&stderr)
+		cmd.SetArgs([]string{name})
+		if err := cmd.Execute(); err != nil {
+			t.Fatalf("save command: %v", err)
+		}
+		return stderr.String()
+	}
+	if got := save("work"); !strings.Contains(got, "Warning: settings.json /env/GITHUB_TOKEN looks like a GitHub token") {
+		t.Fatalf("expected a secret warning, got %q", got)
+	}
+	t.Setenv("TOKYO_SCAN_SECRETS", "false")
+	if got := save("other"); got != "" {
+		t.Fatalf("expected no warning with scan_secrets off, got %q", got)
+	}
+}
+
+func TestInvalidProfileNamesRejectedByCLI(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	oldOut := rootCmd.OutOrStdout()
+	oldErr := rootCmd.ErrOrStderr()
+	t.Cleanup(func() {
+		rootCmd.SetOut(oldOut)
+		rootCmd.SetErr(oldErr)
+		rootCmd.SetArgs(nil)
+	})
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+
+	// The same names the API refuses; see api.invalidProfileNames.
+	names := []string{"../etc", "a/b", ".hidden", "a b", "<custom>", "work (modified)", "nul", "COM1", strings.Repeat("a", 65)}
+	commands := [][]string{
+		{"save"},
+		{"switch"},
+		{"delete"},
+		{"adopt"},
+		{"rename", "work"},
+		{"copy", "work"},
+	}
+	for _, command := range commands {
+		for _, name := range names {
+			args := append([]string{"claude"}, command...)
+			args = append(args, name)
+			rootCmd.SetArgs(args)
+			if err := Execute(); !errors.Is(err, profile.ErrInvalidProfileName) {
+				t.Errorf("%v: expected ErrInvalidProfileName, got %v", args, err)
+			}
+		}
+	}
+}
+
+func TestListTreeShowsStoredFiles(t *testing.T) {
+	home := t.TempDir()
+	tool := profile.CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(codexDir, name), []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	write("config.toml", "model = \"o3\"\n")
+	write("auth.json", `{"token":"personal"}`)
+	if err := profile.Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+	write("auth.json", `{"token":"work"}`)
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	cmd := newListCommand(tool)
+	var out bytes.Buffer
+	cmd.SetOut(&out)
+	cmd.SetArgs([]string{"--tree"})
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+
+	want := `personal
+├── config.toml  matches live
+└── auth.json    differs from live
+work (active)
+├── config.toml  matches live
+└── auth.json    matches live
+`
+	if out.String() != want {
+		t.Fatalf("unexpected output:\n%s\nwant:\n%s", out.String(), want)
+	}
+}
+
+func TestListLongShowsUsage(t *testing.T) {
+	home := t.TempDir()
+	tool := profile.ClaudeTool().WithHome(home)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		if err := profile.Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := profile.Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	var out bytes.Buffer
+	if err := writeProfileUsage(&out, tool, []string{"personal", "work"}, time.Now().Add(3*time.Hour+5*time.Minute)); err != nil {
+		t.Fatalf("writeProfileUsage: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "PROFILE") {
+		t.Fatalf("unexpected output:\n%s", out.String())
+	}
+	if fields := strings.Fields(lines[1]); fields[0] != "work" || fields[1] != "3h" || fields[2] != "5m" || fields[3] != "1" {
+		t.Fatalf("expected work first with 3h 5m and one switch, got %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[0] != "personal" || fields[1] != "0m" || fields[3] != "never" {
+		t.Fatalf("expected personal unused, got %q", lines[2])
+	}
+}
+
+func TestFormatActive(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		30 * time.Second:              "0m",
+		45 * time.Minute:              "45m",
+		2*time.Hour + 5*time.Minute:   "2h 5m",
+		50*time.Hour + 20*time.Minute: "2d 2h",
+	} {
+		if got := formatActive(d); got != want {
+			t.Errorf("formatActive(%s) = %q, want %q", d, got, want)
+		}
+	}
+}
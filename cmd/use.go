@@ -0,0 +1,189 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+	"tokyo/pkg/project"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newUseCommand(), newCheckCommand())
+}
+
+func newUseCommand() *cobra.Command {
+	var confirmProduction bool
+
+	cmd := &cobra.Command{
+		Use:   "use [dir]",
+		Short: i18n.T("Switch to the profiles a project's .tokyo file declares"),
+		Long: `Switch every tool to the profile the project declares in the nearest .tokyo
+or .tokyo.yaml file, looked up from dir (default: the working directory) and
+its parents. The file maps tools to profiles:
+
+  claude: work
+  codex: work
+
+Profiles that are already active are left alone; others are switched to,
+autosaving any changes to the live config first. A profile tagged protected
+is only switched to once its name is typed, or with --confirm-production. To
+switch automatically on cd, see 'tokyo hook'.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, cfg, err := loadProject(args)
+			if err != nil {
+				return err
+			}
+			confirm := func(t profile.Tool, name string) (profile.Tool, error) {
+				return confirmProtected(cmd, cfg, t, name, "switch to", confirmProduction)
+			}
+			switched, err := switchToProject(cmd, cfg, f, cmd.OutOrStdout(), confirm)
+			for _, sw := range switched {
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: switched to %s%s\n", sw.tool, sw.profile, sw.note())
+			}
+			if err == nil && len(switched) == 0 {
+				fmt.Fprintln(cmd.OutOrStdout(), "Already using the project's profiles")
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().BoolVar(&confirmProduction, "confirm-production", false, "Switch to profiles tagged protected without typing their names")
+
+	return cmd
+}
+
+func newCheckCommand() *cobra.Command {
+	var allowModified bool
+
+	cmd := &cobra.Command{
+		Use:   "check [dir]",
+		Short: i18n.T("Check that the profiles a project's .tokyo file declares are active"),
+		Long: `Check that every tool uses the profile the project declares in the nearest
+.tokyo or .tokyo.yaml file, for CI and pre-commit hooks. Each mismatch is
+reported and check exits with 1; it exits with 0 when all declared profiles
+are active and the live config matches them.
+
+With --allow-modified, changes made to the live config since the switch are
+ignored.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			f, cfg, err := loadProject(args)
+			if err != nil {
+				return err
+			}
+			failed := false
+			for _, name := range f.Tools() {
+				if !cfg.ToolEnabled(name) {
+					continue
+				}
+				t, _ := profile.LookupTool(name)
+				t = cliTool(cmd, t)
+				want := f.Profiles[name]
+				active, err := profile.IsActive(t, want, allowModified)
+				if err != nil {
+					return fmt.Errorf("%s: %w", f.Path, err)
+				}
+				if active {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", name, want)
+					continue
+				}
+				status, err := profile.Current(t)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s: expected %s, got %s\n", name, want, status)
+				failed = true
+			}
+			if failed {
+				cmd.SilenceUsage = true
+				return &ExitError{Code: 1}
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVar(&allowModified, "allow-modified", false, "Accept live config changed since the switch")
+
+	return cmd
+}
+
+// loadProject loads the nearest project file from args[0], or else the
+// working directory, and the config.
+func loadProject(args []string) (project.File, config.Config, error) {
+	path, err := findProjectFile(args, true)
+	if err != nil {
+		return project.File{}, config.Config{}, err
+	}
+	f, err := project.Load(path)
+	if err != nil {
+		return project.File{}, config.Config{}, err
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return project.File{}, config.Config{}, err
+	}
+	return f, cfg, nil
+}
+
+// projectSwitch is a switch made by switchToProject.
+type projectSwitch struct {
+	tool, profile string
+	// annotations are the profile's annotations switch.annotations selects;
+	// see profile.Meta.AnnotationSummary.
+	annotations string
+}
+
+// note returns the annotations to append to the line reporting the switch.
+func (sw projectSwitch) note() string {
+	if sw.annotations == "" {
+		return ""
+	}
+	return " — " + sw.annotations
+}
+
+// switchToProject switches every enabled tool of f to the profile f names,
+// unless that profile is already active, and returns the switches made.
+// Switch hooks write to hookOut. confirm returns the tool to switch with,
+// as confirmProtected does.
+func switchToProject(cmd *cobra.Command, cfg config.Config, f project.File, hookOut io.Writer, confirm func(profile.Tool, string) (profile.Tool, error)) ([]projectSwitch, error) {
+	var switched []projectSwitch
+	for _, name := range f.Tools() {
+		if !cfg.ToolEnabled(name) {
+			continue
+		}
+		t, _ := profile.LookupTool(name)
+		t = cliTool(cmd, t)
+		profileName := f.Profiles[name]
+		active, err := profile.IsActive(t, profileName, true)
+		if err != nil {
+			return switched, fmt.Errorf("%s: %w", f.Path, err)
+		}
+		if active {
+			continue
+		}
+		if t, err = confirm(t, profileName); err != nil {
+			return switched, err
+		}
+		if err := runHook(cmd, hookOut, "pre_switch", cfg.Hooks.PreSwitch, t.Name, profileName); err != nil {
+			return switched, err
+		}
+		if err := profile.SwitchWithStrategy(t, profileName, profile.SwitchOverwrite); err != nil {
+			return switched, err
+		}
+		meta, err := profile.ReadMeta(t, profileName)
+		switched = append(switched, projectSwitch{tool: name, profile: profileName, annotations: meta.AnnotationSummary(cfg.Switch.Annotations)})
+		if err != nil {
+			return switched, err
+		}
+		if err := runHook(cmd, hookOut, "post_switch", cfg.Hooks.PostSwitch, t.Name, profileName); err != nil {
+			return switched, err
+		}
+	}
+	return switched, nil
+}
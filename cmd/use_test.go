@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestUseAndCheckProject(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		if err := os.WriteFile(configPath, []byte(`{"profile":"`+name+`"}`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := profile.Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := profile.Switch(tool, "personal"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	repo := t.TempDir()
+	if err := os.WriteFile(filepath.Join(repo, ".tokyo.yaml"), []byte("claude: work\n"), 0o600); err != nil {
+		t.Fatalf("write .tokyo.yaml: %v", err)
+	}
+
+	oldOut := rootCmd.OutOrStdout()
+	t.Cleanup(func() {
+		rootCmd.SetOut(oldOut)
+		rootCmd.SetArgs(nil)
+	})
+	run := func(args ...string) (string, error) {
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(args)
+		err := Execute()
+		return out.String(), err
+	}
+
+	out, err := run("check", repo, "--allow-modified=false")
+	var exitErr *ExitError
+	if !errors.As(err, &exitErr) || exitErr.Code != 1 {
+		t.Fatalf("check before use: err = %v, want exit status 1", err)
+	}
+	if want := "claude: expected work, got personal\n"; out != want {
+		t.Fatalf("check output = %q, want %q", out, want)
+	}
+
+	if out, err = run("use", repo); err != nil || out != "claude: switched to work\n" {
+		t.Fatalf("use = %q, %v", out, err)
+	}
+	if out, err = run("use", repo); err != nil || out != "Already using the project's profiles\n" {
+		t.Fatalf("second use = %q, %v", out, err)
+	}
+	if out, err = run("check", repo, "--allow-modified=false"); err != nil || out != "claude: work\n" {
+		t.Fatalf("check after use = %q, %v", out, err)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"edited":true}`), 0o600); err != nil {
+		t.Fatalf("modify config: %v", err)
+	}
+	if _, err = run("check", repo, "--allow-modified=false"); !errors.As(err, &exitErr) {
+		t.Fatalf("check with a modified live config: err = %v, want exit status 1", err)
+	}
+	if _, err = run("check", repo, "--allow-modified"); err != nil {
+		t.Fatalf("check --allow-modified: %v", err)
+	}
+}
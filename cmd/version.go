@@ -0,0 +1,61 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"time"
+
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+// versionTimeout bounds how long a tool's --version may take.
+const versionTimeout = 5 * time.Second
+
+// installedVersion returns the version of t's executable, or "" when it is
+// not installed or its version cannot be read.
+func installedVersion(cmd *cobra.Command, t profile.Tool) string {
+	if t.Binary == "" {
+		return ""
+	}
+	path, err := exec.LookPath(t.Binary)
+	if err != nil {
+		return ""
+	}
+	ctx, cancel := context.WithTimeout(cmd.Context(), versionTimeout)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, "--version").Output()
+	if err != nil {
+		return ""
+	}
+	return profile.ParseToolVersion(string(out))
+}
+
+// recordToolVersion stores the installed version of t with profileName.
+func recordToolVersion(cmd *cobra.Command, t profile.Tool, profileName string) error {
+	version := installedVersion(cmd, t)
+	if version == "" {
+		return nil
+	}
+	return profile.UpdateMeta(t, profileName, func(meta *profile.Meta) error {
+		meta.ToolVersion = version
+		return nil
+	})
+}
+
+// warnToolVersion warns on stderr when profileName was saved under a version
+// of t far from the installed one, since its settings may no longer apply.
+func warnToolVersion(cmd *cobra.Command, t profile.Tool, profileName string) error {
+	meta, err := profile.ReadMeta(t, profileName)
+	if err != nil || meta.ToolVersion == "" {
+		return err
+	}
+	installed := installedVersion(cmd, t)
+	if profile.VersionsDiffer(meta.ToolVersion, installed) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s was saved with %s %s, but %s is installed; its settings may have changed.\n",
+			profileName, t.DisplayName, meta.ToolVersion, installed)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+// fakeClaude puts a claude executable on PATH that reports version.
+func fakeClaude(t *testing.T, version string) {
+	t.Helper()
+	bin := t.TempDir()
+	script := "#!/bin/sh\necho '" + version + " (Claude Code)'\n"
+	if err := os.WriteFile(filepath.Join(bin, "claude"), []byte(script), 0o700); err != nil {
+		t.Fatalf("write fake claude: %v", err)
+	}
+	t.Setenv("PATH", bin)
+}
+
+func TestSaveRecordsToolVersionAndSwitchWarns(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	tool := profile.ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	fakeClaude(t, "1.0.43")
+	save := newSaveCommand(tool)
+	save.SetOut(&bytes.Buffer{})
+	save.SetArgs([]string{"work"})
+	if err := save.Execute(); err != nil {
+		t.Fatalf("save command: %v", err)
+	}
+	meta, err := profile.ReadMeta(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadMeta: %v", err)
+	}
+	if meta.ToolVersion != "1.0.43" {
+		t.Fatalf("tool version = %q, want 1.0.43", meta.ToolVersion)
+	}
+
+	switchTo := func() string {
+		t.Helper()
+		sw := newSwitchCommand(tool)
+		var stderr bytes.Buffer
+		sw.SetOut(&bytes.Buffer{})
+		sw.SetErr(&stderr)
+		sw.SetArgs([]string{"work", "--strategy", "overwrite"})
+		if err := sw.Execute(); err != nil {
+			t.Fatalf("switch command: %v", err)
+		}
+		return stderr.String()
+	}
+	if warning := switchTo(); warning != "" {
+		t.Fatalf("expected no warning under a close version, got %q", warning)
+	}
+	fakeClaude(t, "2.0.1")
+	if warning := switchTo(); !strings.Contains(warning, "saved with Claude Code 1.0.43, but 2.0.1 is installed") {
+		t.Fatalf("expected a version warning, got %q", warning)
+	}
+}
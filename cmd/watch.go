@@ -0,0 +1,312 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"tokyo/api"
+	"tokyo/pkg/client"
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func newWatchCommand(t profile.Tool) *cobra.Command {
+	var server string
+	var token string
+	var asJSON bool
+	var interval time.Duration
+	var policy string
+
+	cmd := &cobra.Command{
+		Use:   "watch [--server <url>]",
+		Short: i18n.Sprintf("Print %s profile changes as they happen", t.DisplayName),
+		Long: `Print a line for every profile saved, switched or deleted, and whenever the
+live config drifts from the active profile, until interrupted.
+
+With --server, the events of a running 'tokyo serve' are followed; the token
+is --token, or else serve.token (TOKYO_TOKEN). Without it, the profiles and
+live config on this machine are checked every --interval, which also sees
+changes made with the CLI.
+
+--drift-policy, or else the drift_policy.<tool> setting, decides what happens
+to drift: "record" only reports it, "restore" puts back the active profile's
+copies of the drifted files, and "resave" stores them in the active profile.
+What was done is printed as a drift.restored or drift.resaved event, and files
+that could not be fixed, say because the active profile is locked, as a
+drift.remediation_failed event; watch carries on either way. Restore keeps a kiosk or shared machine on its blessed config:
+
+  tokyo config set drift_policy.claude restore
+  tokyo claude watch
+
+With --json, each event is printed as one JSON object per line, in the shape
+the server's /api/v1/events stream uses:
+
+  tokyo claude watch --json | jq -r 'select(.type == "drift.detected") | .files[]'`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, t)
+			if server == "" && interval <= 0 {
+				return errors.New("--interval must be positive")
+			}
+			if policy == "" {
+				cfg, err := config.Load()
+				if err != nil {
+					return err
+				}
+				policy = cfg.DriftPolicy(t.Name)
+			}
+			switch policy {
+			case config.DriftRecord, config.DriftRestore, config.DriftResave:
+			default:
+				return fmt.Errorf("--drift-policy must be %s, %s or %s, got %q", config.DriftRecord, config.DriftRestore, config.DriftResave, policy)
+			}
+			ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+
+			// fix puts back or stores one drifted file, on the server when
+			// following one.
+			fix := func(file string) (string, error) {
+				if policy == config.DriftResave {
+					return profile.AcceptLiveFile(t, file)
+				}
+				return profile.RevertLiveFile(t, file)
+			}
+			var c *client.Client
+			if server != "" {
+				var err error
+				if c, _, err = registryClient(server, token); err != nil {
+					return err
+				}
+				fix = func(file string) (string, error) {
+					if policy == config.DriftResave {
+						return c.AcceptFile(ctx, t.Name, file)
+					}
+					return c.RevertFile(ctx, t.Name, file)
+				}
+			}
+			emit := func(ev api.Event) error {
+				if err := writeWatchEvent(cmd.OutOrStdout(), ev, asJSON); err != nil {
+					return err
+				}
+				if ev.Type != api.EventDriftDetected || policy == config.DriftRecord {
+					return nil
+				}
+				for _, done := range remediateDrift(ev, policy, fix) {
+					if err := writeWatchEvent(cmd.OutOrStdout(), done, asJSON); err != nil {
+						return err
+					}
+				}
+				return nil
+			}
+
+			var err error
+			if c != nil {
+				err = watchServer(ctx, t, c, emit)
+			} else {
+				err = watchLocal(ctx, t, interval, emit)
+			}
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().StringVar(&server, "server", "", "Follow the events of this tokyo server instead of the local files")
+	cmd.Flags().StringVar(&token, "token", "", "Bearer token for the server (default: serve.token)")
+	cmd.Flags().BoolVar(&asJSON, "json", false, "Print one JSON event per line")
+	cmd.Flags().DurationVar(&interval, "interval", 2*time.Second, "How often to check the local files")
+	cmd.Flags().StringVar(&policy, "drift-policy", "", "record, restore or resave drift (default: the drift_policy setting)")
+
+	return cmd
+}
+
+// Events printed by watch for what a drift policy did about drift.
+const (
+	eventDriftRestored          = "drift.restored"
+	eventDriftResaved           = "drift.resaved"
+	eventDriftRemediationFailed = "drift.remediation_failed"
+)
+
+// remediateDrift applies policy, restore or resave, to each file of the
+// drift event ev with fix, and returns the events reporting it: one for the
+// files fixed and one for those that failed, when there are any. A failure
+// does not stop watch, which is meant to keep running unattended.
+func remediateDrift(ev api.Event, policy string, fix func(file string) (string, error)) []api.Event {
+	var fixed, failed, errs []string
+	for _, file := range ev.Files {
+		if _, err := fix(file); err != nil {
+			failed = append(failed, file)
+			errs = append(errs, fmt.Sprintf("%s: %v", file, err))
+			continue
+		}
+		fixed = append(fixed, file)
+	}
+
+	now := time.Now().UTC()
+	var events []api.Event
+	if len(fixed) > 0 {
+		done := api.Event{Type: eventDriftRestored, Tool: ev.Tool, Profile: ev.Profile, Time: now, Files: fixed}
+		if policy == config.DriftResave {
+			done.Type = eventDriftResaved
+		}
+		events = append(events, done)
+	}
+	if len(failed) > 0 {
+		events = append(events, api.Event{
+			Type:    eventDriftRemediationFailed,
+			Tool:    ev.Tool,
+			Profile: ev.Profile,
+			Time:    now,
+			Files:   failed,
+			Error:   fmt.Sprintf("%s: %s", policy, strings.Join(errs, "; ")),
+		})
+	}
+	return events
+}
+
+// watchServer calls fn with the profile and drift events of t from the
+// server c.
+func watchServer(ctx context.Context, t profile.Tool, c *client.Client, fn func(api.Event) error) error {
+	return c.Events(ctx, func(ev api.Event) error {
+		if ev.Tool != t.Name || strings.HasPrefix(ev.Type, "operation.") {
+			return nil
+		}
+		return fn(ev)
+	})
+}
+
+// watchLocal calls fn with the events the changes to t's profiles and live
+// config amount to, checking every interval.
+func watchLocal(ctx context.Context, t profile.Tool, interval time.Duration, fn func(api.Event) error) error {
+	var w localWatch
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		events, err := w.poll(t)
+		if err != nil {
+			return err
+		}
+		for _, ev := range events {
+			if err := fn(ev); err != nil {
+				return err
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// localWatch is what the previous poll of a tool found.
+type localWatch struct {
+	started  bool
+	profiles []string
+	active   string
+	drift    string
+}
+
+// poll returns the events between the previous poll and now. The first
+// poll only reports drift that is already there.
+func (w *localWatch) poll(t profile.Tool) ([]api.Event, error) {
+	profiles, err := profile.List(t)
+	if err != nil {
+		return nil, err
+	}
+	status, err := profile.Current(t)
+	if err != nil {
+		return nil, err
+	}
+	active, modified := strings.CutSuffix(status, " (modified)")
+	if active == "<custom>" {
+		active = ""
+	}
+	var drifted []string
+	if modified {
+		diffs, err := profile.Diff(t, active)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range diffs {
+			if d.Status != profile.FileUnchanged {
+				drifted = append(drifted, d.Name)
+			}
+		}
+	}
+
+	now := time.Now().UTC()
+	event := func(eventType, name string) api.Event {
+		return api.Event{Type: eventType, Tool: t.Name, Profile: name, Time: now}
+	}
+	var events []api.Event
+	if w.started {
+		for _, name := range profiles {
+			if !slices.Contains(w.profiles, name) {
+				events = append(events, event(api.EventProfileSaved, name))
+			}
+		}
+		for _, name := range w.profiles {
+			if !slices.Contains(profiles, name) {
+				events = append(events, event(api.EventProfileDeleted, name))
+			}
+		}
+		if active != w.active && active != "" {
+			events = append(events, event(api.EventProfileSwitched, active))
+		}
+	}
+	drift := ""
+	if len(drifted) > 0 {
+		drift = active + "\x00" + strings.Join(drifted, "\x00")
+	}
+	if drift != "" && drift != w.drift {
+		ev := event(api.EventDriftDetected, active)
+		ev.Files = drifted
+		events = append(events, ev)
+	}
+
+	w.started, w.profiles, w.active, w.drift = true, profiles, active, drift
+	return events, nil
+}
+
+// writeWatchEvent prints ev as a line of text, or of JSON.
+func writeWatchEvent(w io.Writer, ev api.Event, asJSON bool) error {
+	if asJSON {
+		return json.NewEncoder(w).Encode(ev)
+	}
+	when := ev.Time.Local().Format(time.TimeOnly)
+	var what string
+	switch ev.Type {
+	case api.EventProfileSaved:
+		what = fmt.Sprintf("saved %s", ev.Profile)
+	case api.EventProfileSwitched:
+		what = fmt.Sprintf("switched to %s", ev.Profile)
+	case api.EventProfileDeleted:
+		what = fmt.Sprintf("deleted %s", ev.Profile)
+	case api.EventDriftDetected:
+		what = fmt.Sprintf("live config differs from %s: %s", ev.Profile, strings.Join(ev.Files, ", "))
+	case eventDriftRestored:
+		what = fmt.Sprintf("restored %s from %s", strings.Join(ev.Files, ", "), ev.Profile)
+	case eventDriftResaved:
+		what = fmt.Sprintf("saved %s in %s", strings.Join(ev.Files, ", "), ev.Profile)
+	case eventDriftRemediationFailed:
+		what = fmt.Sprintf("could not fix %s (%s)", strings.Join(ev.Files, ", "), ev.Error)
+	default:
+		what = fmt.Sprintf("%s %s", ev.Type, ev.Profile)
+	}
+	_, err := fmt.Fprintf(w, "%s %s: %s\n", when, ev.Tool, what)
+	return err
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"tokyo/api"
+	"tokyo/pkg/client"
+	"tokyo/pkg/config"
+	"tokyo/pkg/profile"
+)
+
+func TestLocalWatchPoll(t *testing.T) {
+	home := t.TempDir()
+	live := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(live), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(live, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+	tool := profile.ClaudeTool().WithHome(home)
+	if err := profile.Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	var w localWatch
+	poll := func() []string {
+		t.Helper()
+		events, err := w.poll(tool)
+		if err != nil {
+			t.Fatalf("poll: %v", err)
+		}
+		var out []string
+		for _, ev := range events {
+			out = append(out, ev.Type+" "+ev.Profile+" "+strings.Join(ev.Files, ","))
+		}
+		return out
+	}
+	if got := poll(); got != nil {
+		t.Fatalf("first poll = %v, want nothing", got)
+	}
+
+	if err := profile.Save(tool, "home", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := profile.Switch(tool, "home"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if got, want := poll(), []string{"profile.saved home ", "profile.switched home "}; !slices.Equal(got, want) {
+		t.Fatalf("poll = %q, want %q", got, want)
+	}
+
+	if err := os.WriteFile(live, []byte(`{"model":"opus"}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+	if got, want := poll(), []string{"drift.detected home settings.json"}; !slices.Equal(got, want) {
+		t.Fatalf("poll = %q, want %q", got, want)
+	}
+	if got := poll(); got != nil {
+		t.Fatalf("drift reported again: %v", got)
+	}
+
+	if _, err := profile.Delete(tool, "work"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if got, want := poll(), []string{"profile.deleted work "}; !slices.Equal(got, want) {
+		t.Fatalf("poll = %q, want %q", got, want)
+	}
+}
+
+func TestRemediateDrift(t *testing.T) {
+	home := t.TempDir()
+	live := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(live), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(live, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write settings: %v", err)
+	}
+	tool := profile.ClaudeTool().WithHome(home)
+	if err := profile.Adopt(tool, "kiosk"); err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+
+	for _, tt := range []struct {
+		policy, event, want string
+	}{
+		{config.DriftRestore, "drift.restored", `{}`},
+		{config.DriftResave, "drift.resaved", `{"model":"opus"}`},
+	} {
+		if err := os.WriteFile(live, []byte(`{"model":"opus"}`), 0o600); err != nil {
+			t.Fatalf("write settings: %v", err)
+		}
+		var w localWatch
+		events, err := w.poll(tool)
+		if err != nil || len(events) != 1 || events[0].Type != api.EventDriftDetected {
+			t.Fatalf("poll = %+v, %v", events, err)
+		}
+		fix := profile.RevertLiveFile
+		if tt.policy == config.DriftResave {
+			fix = profile.AcceptLiveFile
+		}
+		done := remediateDrift(events[0], tt.policy, func(file string) (string, error) { return fix(tool, file) })
+		if len(done) != 1 || done[0].Type != tt.event || !slices.Equal(done[0].Files, []string{"settings.json"}) {
+			t.Fatalf("%s: remediateDrift = %+v", tt.policy, done)
+		}
+		if status, _ := profile.Current(tool); status != "kiosk" {
+			t.Fatalf("%s: current = %q, want kiosk unmodified", tt.policy, status)
+		}
+		if data, _ := os.ReadFile(live); string(data) != tt.want {
+			t.Fatalf("%s: settings.json = %s, want %s", tt.policy, data, tt.want)
+		}
+	}
+}
+
+func TestRemediateDriftReportsFailures(t *testing.T) {
+	ev := api.Event{Type: api.EventDriftDetected, Tool: "claude", Profile: "kiosk", Files: []string{"CLAUDE.md", "settings.json"}}
+	fix := func(file string) (string, error) {
+		if file == "settings.json" {
+			return "", errors.New("profile is locked")
+		}
+		return file, nil
+	}
+
+	events := remediateDrift(ev, config.DriftRestore, fix)
+	if len(events) != 2 {
+		t.Fatalf("remediateDrift = %+v, want a restored and a failed event", events)
+	}
+	if events[0].Type != "drift.restored" || !slices.Equal(events[0].Files, []string{"CLAUDE.md"}) {
+		t.Fatalf("restored event = %+v", events[0])
+	}
+	failed := events[1]
+	if failed.Type != "drift.remediation_failed" || !slices.Equal(failed.Files, []string{"settings.json"}) ||
+		failed.Error != "restore: settings.json: profile is locked" {
+		t.Fatalf("failed event = %+v", failed)
+	}
+
+	var out bytes.Buffer
+	if err := writeWatchEvent(&out, failed, false); err != nil {
+		t.Fatalf("writeWatchEvent: %v", err)
+	}
+	if !strings.HasSuffix(out.String(), "claude: could not fix settings.json (restore: settings.json: profile is locked)\n") {
+		t.Fatalf("output = %q", out.String())
+	}
+}
+
+func TestWatchServer(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	serverTool := profile.ClaudeTool().WithHome(t.TempDir())
+	if err := profile.SaveFiles(serverTool, "work", map[string][]byte{"settings.json": []byte(`{}`)}, false); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	srv := httptest.NewServer(api.NewServer(api.WithTools(serverTool)))
+	defer srv.Close()
+
+	c, err := client.New(srv.URL)
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var out bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- watchServer(ctx, profile.ClaudeTool(), c, func(ev api.Event) error {
+			if err := writeWatchEvent(&out, ev, true); err != nil {
+				return err
+			}
+			cancel()
+			return nil
+		})
+	}()
+
+	// The stream may not be subscribed yet; switch until the event arrives.
+	for ctx.Err() == nil {
+		if err := c.Switch(context.Background(), "claude", "work", client.SwitchOptions{}); err != nil {
+			t.Fatalf("Switch: %v", err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("watchServer: %v", err)
+	}
+
+	var ev api.Event
+	line, _, _ := strings.Cut(out.String(), "\n")
+	if err := json.Unmarshal([]byte(line), &ev); err != nil {
+		t.Fatalf("unmarshal %q: %v", out.String(), err)
+	}
+	if ev.Type != api.EventProfileSwitched || ev.Tool != "claude" || ev.Profile != "work" {
+		t.Fatalf("event = %+v", ev)
+	}
+}
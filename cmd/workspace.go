@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"fmt"
+	"slices"
+
+	"tokyo/pkg/config"
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/profile"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(newWorkspaceCommand())
+}
+
+func newWorkspaceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "workspace",
+		Short: i18n.T("Keep separate sets of profiles, such as one per client"),
+		Long: `A workspace is a separate set of profile stores under
+~/.config/tokyo/workspaces/<name>/. Profiles, their metadata and the current
+state of each tool are kept per workspace; the live config files are shared.
+
+The workspace is chosen by --workspace, then TOKYO_WORKSPACE, then the
+workspace setting that 'tokyo workspace use' writes. "default" is the set of
+stores used without a workspace.`,
+	}
+
+	cmd.AddCommand(newWorkspaceListCommand(), newWorkspaceUseCommand(), newWorkspaceCurrentCommand())
+	return cmd
+}
+
+func newWorkspaceListCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "list",
+		Short: i18n.T("List workspaces, marking the selected one"),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			t := cliTool(cmd, profile.ClaudeTool())
+			names, err := profile.Workspaces(t)
+			if err != nil {
+				return err
+			}
+			if workspace != "" && !slices.Contains(names, workspace) {
+				names = append(names, workspace)
+				slices.Sort(names)
+			}
+			for _, name := range append([]string{profile.DefaultWorkspace}, names...) {
+				marker := " "
+				if name == workspaceName() {
+					marker = "*"
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s %s\n", marker, name)
+			}
+			return nil
+		},
+	}
+}
+
+func newWorkspaceUseCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "use <workspace>",
+		Short: i18n.T("Select the workspace used by later commands"),
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			if name == profile.DefaultWorkspace {
+				name = ""
+			}
+			path, err := config.Path()
+			if err != nil {
+				return err
+			}
+			cfg, err := config.LoadFile(path)
+			if err != nil {
+				return err
+			}
+			if err := config.Set(&cfg, "workspace", name); err != nil {
+				return err
+			}
+			if err := config.SaveFile(path, cfg); err != nil {
+				return err
+			}
+			fmt.Fprintf(cmd.OutOrStdout(), "Using workspace %s\n", args[0])
+			return nil
+		},
+	}
+}
+
+func newWorkspaceCurrentCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "current",
+		Short: i18n.T("Print the selected workspace"),
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Fprintln(cmd.OutOrStdout(), workspaceName())
+			return nil
+		},
+	}
+}
+
+// workspaceName returns the selected workspace, or "default".
+func workspaceName() string {
+	if workspace == "" {
+		return profile.DefaultWorkspace
+	}
+	return workspace
+}
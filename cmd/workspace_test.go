@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+func TestWorkspaceUseSelectsStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	oldOut := rootCmd.OutOrStdout()
+	t.Cleanup(func() {
+		workspace = ""
+		flag := rootCmd.PersistentFlags().Lookup("workspace")
+		flag.Value.Set("")
+		flag.Changed = false
+		rootCmd.SetOut(oldOut)
+		rootCmd.SetArgs(nil)
+	})
+
+	run := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		rootCmd.SetOut(&out)
+		rootCmd.SetArgs(args)
+		if err := Execute(); err != nil {
+			t.Fatalf("%s: %v", strings.Join(args, " "), err)
+		}
+		return out.String()
+	}
+
+	run("workspace", "use", "acme")
+	run("claude", "save", "client")
+	if exists, _ := profile.Exists(profile.ClaudeTool().WithWorkspace("acme"), "client"); !exists {
+		t.Fatalf("expected client in workspace acme")
+	}
+	if exists, _ := profile.Exists(profile.ClaudeTool(), "client"); exists {
+		t.Fatalf("expected default workspace to stay empty")
+	}
+	if out := run("workspace", "list"); out != "  default\n* acme\n" {
+		t.Fatalf("unexpected list output:\n%s", out)
+	}
+
+	if out := run("--workspace", "default", "claude", "list"); strings.Contains(out, "client") {
+		t.Fatalf("expected --workspace to override the setting, got:\n%s", out)
+	}
+	if out := run("workspace", "current"); out != "default\n" {
+		t.Fatalf("expected flag to select default, got %q", out)
+	}
+}
@@ -0,0 +1,124 @@
+// Command benchcheck compares two runs of `go test -bench` and fails when a
+// benchmark got much slower, for `make bench-check`.
+//
+// Usage:
+//
+//	benchcheck [-threshold 1.5] baseline.txt current.txt
+//
+// Each benchmark's fastest ns/op across -count runs is compared, which keeps
+// noise from a busy machine from reading as a regression.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+func main() {
+	threshold := flag.Float64("threshold", 1.5, "fail when a benchmark's time grows by more than this factor")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: benchcheck [-threshold factor] baseline.txt current.txt")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	baseline, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	current, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	regressions := compare(os.Stdout, baseline, current, *threshold)
+	if regressions > 0 {
+		fmt.Fprintf(os.Stderr, "%d benchmark(s) slowed down by more than %.2fx\n", regressions, *threshold)
+		os.Exit(1)
+	}
+}
+
+func parseFile(path string) (map[string]float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	results, err := parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if len(results) == 0 {
+		return nil, fmt.Errorf("%s holds no benchmark results", path)
+	}
+	return results, nil
+}
+
+// procsSuffix is the -GOMAXPROCS suffix go test appends to benchmark names.
+var procsSuffix = regexp.MustCompile(`-\d+$`)
+
+// parse returns the fastest ns/op of each benchmark in go test output.
+func parse(r io.Reader) (map[string]float64, error) {
+	results := map[string]float64{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		name := procsSuffix.ReplaceAllString(fields[0], "")
+		for i := 2; i+1 < len(fields); i += 2 {
+			if fields[i+1] != "ns/op" {
+				continue
+			}
+			ns, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("parse %q: %w", scanner.Text(), err)
+			}
+			if best, ok := results[name]; !ok || ns < best {
+				results[name] = ns
+			}
+		}
+	}
+	return results, scanner.Err()
+}
+
+// compare prints every benchmark present in both runs and returns how many
+// slowed down by more than threshold.
+func compare(w io.Writer, baseline, current map[string]float64, threshold float64) int {
+	names := make([]string, 0, len(current))
+	for name := range current {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	regressions := 0
+	for _, name := range names {
+		base, ok := baseline[name]
+		if !ok || base == 0 {
+			fmt.Fprintf(w, "%-50s %14s %14.0f  (new)\n", name, "-", current[name])
+			continue
+		}
+		ratio := current[name] / base
+		mark := ""
+		if ratio > threshold {
+			mark = "  REGRESSION"
+			regressions++
+		}
+		fmt.Fprintf(w, "%-50s %14.0f %14.0f  %.2fx%s\n", name, base, current[name], ratio, mark)
+	}
+	return regressions
+}
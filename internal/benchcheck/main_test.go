@@ -0,0 +1,35 @@
+package main
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+const baselineOutput = `goos: linux
+BenchmarkSave/files=1/size=1KiB-8     	    1000	   1000000 ns/op	   1.05 MB/s
+BenchmarkSave/files=1/size=1KiB-8     	    1000	    900000 ns/op	   1.10 MB/s
+BenchmarkCurrent/files=1/size=1KiB-8  	   30000	     40000 ns/op
+PASS
+`
+
+func TestParseKeepsFastestRun(t *testing.T) {
+	results, err := parse(strings.NewReader(baselineOutput))
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if got := results["BenchmarkSave/files=1/size=1KiB"]; got != 900000 {
+		t.Fatalf("Save = %v, want the fastest run 900000", got)
+	}
+	if got := results["BenchmarkCurrent/files=1/size=1KiB"]; got != 40000 {
+		t.Fatalf("Current = %v, want 40000", got)
+	}
+}
+
+func TestCompareCountsRegressions(t *testing.T) {
+	baseline := map[string]float64{"BenchmarkSave": 1000, "BenchmarkSwitch": 1000}
+	current := map[string]float64{"BenchmarkSave": 1400, "BenchmarkSwitch": 2000, "BenchmarkNew": 10}
+	if n := compare(io.Discard, baseline, current, 1.5); n != 1 {
+		t.Fatalf("expected 1 regression, got %d", n)
+	}
+}
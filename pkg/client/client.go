@@ -0,0 +1,610 @@
+// Package client is a typed Go client for the tokyo HTTP API served by
+// `tokyo serve`.
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"tokyo/api"
+	"tokyo/pkg/profile"
+)
+
+// Event is a change notification received from the events stream.
+type Event = api.Event
+
+// FileDiff describes how one live config file differs from a profile.
+type FileDiff = profile.FileDiff
+
+// RegistryVersion is one published version of a registry profile.
+type RegistryVersion = api.RegistryVersion
+
+// ShareLink is a one-time link to an exported profile bundle.
+type ShareLink = api.ShareLink
+
+// Operation is a switch or save the server runs in the background.
+type Operation = api.Operation
+
+// ProfileUsage is how much a profile has been used.
+type ProfileUsage = api.ProfileUsage
+
+// ProfileFile is the stored content of one config file of a profile.
+type ProfileFile = api.ProfileFile
+
+// FileProblem is one reason WriteFile was refused.
+type FileProblem = api.FileProblem
+
+// Status is the active profile of a tool as reported by the server.
+type Status struct {
+	Profile  string `json:"profile"`
+	Modified bool   `json:"modified"`
+	Custom   bool   `json:"custom"`
+	// Files compares each live config file with the active profile. It is
+	// only filled in by CurrentDetail.
+	Files []FileDiff `json:"files,omitempty"`
+}
+
+// SaveOptions controls how Save stores a profile.
+type SaveOptions struct {
+	// Force overwrites an existing profile.
+	Force bool
+	// From stores the profile as a delta on top of this base profile.
+	From string
+}
+
+// SwitchOptions controls how Switch treats unsaved live changes.
+type SwitchOptions struct {
+	// Force discards live changes not saved in the active profile. Without
+	// it such changes make Switch fail with a conflict listing the files.
+	Force bool
+	// ConfirmProduction switches to a profile tagged protected, which is
+	// otherwise refused with the code profile_protected.
+	ConfirmProduction bool
+}
+
+func (o SwitchOptions) query() url.Values {
+	query := url.Values{}
+	if o.Force {
+		query.Set("force", "true")
+	}
+	if o.ConfirmProduction {
+		query.Set("confirm_production", "true")
+	}
+	return query
+}
+
+// Error is returned when the server answers with a non-2xx status.
+type Error struct {
+	StatusCode int
+	Message    string
+	// Code is the server's machine-readable code for the error, one of the
+	// api.Code constants or a status in snake case such as "not_found".
+	// Compare it rather than Message, which is meant for people.
+	Code string
+	// Files lists the drifted live files when Switch is refused because of
+	// unsaved changes.
+	Files []FileDiff
+	// Problems lists what is wrong with the content WriteFile was refused.
+	Problems []FileProblem
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("tokyo api: %s", http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("tokyo api: %s (%d)", e.Message, e.StatusCode)
+}
+
+// IsNotFound reports whether err is a 404 answer from the server.
+func IsNotFound(err error) bool { return hasStatus(err, http.StatusNotFound) }
+
+// IsConflict reports whether err is a 409 answer from the server.
+func IsConflict(err error) bool { return hasStatus(err, http.StatusConflict) }
+
+// HasCode reports whether err is an answer from the server with the given
+// error code, e.g. api.CodeProfileExists.
+func HasCode(err error, code string) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.Code == code
+}
+
+func hasStatus(err error, code int) bool {
+	var apiErr *Error
+	return errors.As(err, &apiErr) && apiErr.StatusCode == code
+}
+
+// Client talks to a tokyo API server. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	token      string
+	retries    int
+	backoff    time.Duration
+	cache      etagCache
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithHTTPClient uses hc instead of http.DefaultClient.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithToken sends token as a bearer token on every request.
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries retries idempotent requests up to n extra times when the
+// server is unreachable or answers 502, 503 or 504, waiting backoff before
+// the first retry and doubling it after each attempt.
+func WithRetries(n int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.retries = max(n, 0)
+		c.backoff = backoff
+	}
+}
+
+// New returns a client for the server at baseURL, e.g.
+// "http://localhost:8080". A base path configured with `serve --base-path`
+// belongs in baseURL.
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parse base url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("base url %q must use http or https", baseURL)
+	}
+	c := &Client{
+		baseURL:    strings.TrimSuffix(u.String(), "/"),
+		httpClient: http.DefaultClient,
+		backoff:    200 * time.Millisecond,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
+}
+
+// List returns the saved profiles of tool, optionally filtered by tags
+// ("key" or "key=value").
+func (c *Client) List(ctx context.Context, tool string, tags ...string) ([]string, error) {
+	query := url.Values{}
+	for _, tag := range tags {
+		query.Add("tag", tag)
+	}
+	var resp struct {
+		Profiles []string `json:"profiles"`
+	}
+	if err := c.do(ctx, http.MethodGet, toolPath(tool, "profiles"), query, nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Profiles, nil
+}
+
+// Current returns the active profile of tool.
+func (c *Client) Current(ctx context.Context, tool string) (Status, error) {
+	var status Status
+	err := c.do(ctx, http.MethodGet, toolPath(tool, "current"), nil, nil, true, &status)
+	return status, err
+}
+
+// CurrentDetail is Current with a per-file comparison against the active
+// profile.
+func (c *Client) CurrentDetail(ctx context.Context, tool string) (Status, error) {
+	var status Status
+	query := url.Values{"detail": {"true"}}
+	err := c.do(ctx, http.MethodGet, toolPath(tool, "current"), query, nil, true, &status)
+	return status, err
+}
+
+// Save stores the live config files of tool as profile.
+func (c *Client) Save(ctx context.Context, tool, profile string, opts SaveOptions) error {
+	body := map[string]any{"profile": profile, "force": opts.Force}
+	if opts.From != "" {
+		body["from"] = opts.From
+	}
+	return c.do(ctx, http.MethodPost, toolPath(tool, "profiles"), nil, body, false, nil)
+}
+
+// Adopt saves the unmanaged live config of tool as profile and makes it
+// current.
+func (c *Client) Adopt(ctx context.Context, tool, profile string) error {
+	body := map[string]any{"profile": profile}
+	return c.do(ctx, http.MethodPost, toolPath(tool, "adopt"), nil, body, false, nil)
+}
+
+// Switch makes profile the active profile of tool.
+func (c *Client) Switch(ctx context.Context, tool, profile string, opts SwitchOptions) error {
+	return c.do(ctx, http.MethodPost, toolPath(tool, "switch", profile), opts.query(), nil, true, nil)
+}
+
+// StartSwitch asks the server to switch in the background and returns as
+// soon as the switch has started; follow it with Operation or the operation
+// events of Events. Unsaved live changes are still refused right away. A
+// server that cannot run operations in the background switches before
+// answering, and StartSwitch returns a finished Operation without an ID.
+func (c *Client) StartSwitch(ctx context.Context, tool, profile string, opts SwitchOptions) (Operation, error) {
+	return c.start(ctx, api.OperationSwitch, tool, profile, toolPath(tool, "switch", profile), opts.query(), nil)
+}
+
+// StartSave is Save run in the background by the server, as StartSwitch.
+func (c *Client) StartSave(ctx context.Context, tool, profile string, opts SaveOptions) (Operation, error) {
+	body := map[string]any{"profile": profile, "force": opts.Force}
+	if opts.From != "" {
+		body["from"] = opts.From
+	}
+	return c.start(ctx, api.OperationSave, tool, profile, toolPath(tool, "profiles"), nil, body)
+}
+
+// Operation returns the state of the operation id.
+func (c *Client) Operation(ctx context.Context, id string) (Operation, error) {
+	var op Operation
+	err := c.do(ctx, http.MethodGet, api.APIPrefix+"/operations/"+url.PathEscape(id), nil, nil, true, &op)
+	return op, err
+}
+
+// preferAsyncKey marks the context of a request sent with
+// "Prefer: respond-async".
+type preferAsyncKey struct{}
+
+func (c *Client) start(ctx context.Context, kind, tool, profile, path string, query url.Values, body any) (Operation, error) {
+	var op Operation
+	ctx = context.WithValue(ctx, preferAsyncKey{}, true)
+	if err := c.do(ctx, http.MethodPost, path, query, body, false, &op); err != nil {
+		return Operation{}, err
+	}
+	if op.ID == "" {
+		op = Operation{Kind: kind, Tool: tool, Profile: profile, State: api.OperationSucceeded}
+	}
+	return op, nil
+}
+
+// Delete removes profile and reports whether it was the active one.
+func (c *Client) Delete(ctx context.Context, tool, profile string) (bool, error) {
+	var resp struct {
+		Cleared bool `json:"cleared"`
+	}
+	if err := c.do(ctx, http.MethodDelete, toolPath(tool, "profiles", profile), nil, nil, false, &resp); err != nil {
+		return false, err
+	}
+	return resp.Cleared, nil
+}
+
+// Diff compares the live config files of tool with profile, or with the
+// active profile when profile is empty.
+func (c *Client) Diff(ctx context.Context, tool, profile string) ([]FileDiff, error) {
+	query := url.Values{}
+	if profile != "" {
+		query.Set("profile", profile)
+	}
+	var resp struct {
+		Files []FileDiff `json:"files"`
+	}
+	if err := c.do(ctx, http.MethodGet, toolPath(tool, "diff"), query, nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Files, nil
+}
+
+// Usage reports how long each profile of tool has been active, most used
+// first.
+func (c *Client) Usage(ctx context.Context, tool string) ([]ProfileUsage, error) {
+	var resp struct {
+		Usage []ProfileUsage `json:"usage"`
+	}
+	if err := c.do(ctx, http.MethodGet, toolPath(tool, "usage"), nil, nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Usage, nil
+}
+
+// Publish uploads bundle, an export of profile alone, to the server's
+// registry and returns the version it was published as.
+func (c *Client) Publish(ctx context.Context, tool, profile string, bundle []byte) (int, error) {
+	var resp struct {
+		Version int `json:"version"`
+	}
+	body := rawBody{contentType: "application/gzip", data: bundle}
+	if err := c.do(ctx, http.MethodPost, toolPath(tool, "registry", profile), nil, body, false, &resp); err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+// Versions lists the published versions of profile in the server's
+// registry, oldest first.
+func (c *Client) Versions(ctx context.Context, tool, profile string) ([]RegistryVersion, error) {
+	var resp struct {
+		Versions []RegistryVersion `json:"versions"`
+	}
+	if err := c.do(ctx, http.MethodGet, toolPath(tool, "registry", profile), nil, nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Versions, nil
+}
+
+// Pull downloads a published version of profile from the server's registry,
+// or the latest one when version is 0, and returns the bundle and its
+// version.
+func (c *Client) Pull(ctx context.Context, tool, profile string, version int) ([]byte, int, error) {
+	v := "latest"
+	if version > 0 {
+		v = strconv.Itoa(version)
+	}
+	resp, err := c.send(ctx, http.MethodGet, toolPath(tool, "registry", profile, v), nil, nil, true)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, 0, fmt.Errorf("read bundle: %w", err)
+	}
+	got, err := strconv.Atoi(resp.Header.Get(api.VersionHeader))
+	if err != nil {
+		return nil, 0, fmt.Errorf("missing %s header", api.VersionHeader)
+	}
+	return data, got, nil
+}
+
+// Files returns the stored config files of profile, sorted by name.
+func (c *Client) Files(ctx context.Context, tool, profile string) ([]ProfileFile, error) {
+	var resp struct {
+		Files []ProfileFile `json:"files"`
+	}
+	if err := c.do(ctx, http.MethodGet, toolPath(tool, "profiles", profile, "files"), nil, nil, true, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Files, nil
+}
+
+// WriteFile replaces the stored content of the config file name in
+// profile. Content that does not parse or breaks the file's schema is
+// refused with an *Error listing the Problems.
+func (c *Client) WriteFile(ctx context.Context, tool, profile, name string, content []byte) error {
+	body := rawBody{contentType: "application/octet-stream", data: content}
+	return c.do(ctx, http.MethodPut, toolPath(tool, "profiles", profile, "files", name), nil, body, true, nil)
+}
+
+// AcceptFile stores the live copy of the config file name in the active
+// profile, resolving its drift in favour of the live config. It returns the
+// active profile.
+func (c *Client) AcceptFile(ctx context.Context, tool, name string) (string, error) {
+	return c.resolveDrift(ctx, tool, name, "accept")
+}
+
+// RevertFile puts the active profile's copy of the config file name back in
+// place, discarding the live changes to it. It returns the active profile.
+func (c *Client) RevertFile(ctx context.Context, tool, name string) (string, error) {
+	return c.resolveDrift(ctx, tool, name, "revert")
+}
+
+func (c *Client) resolveDrift(ctx context.Context, tool, name, action string) (string, error) {
+	var resp struct {
+		Profile string `json:"profile"`
+	}
+	if err := c.do(ctx, http.MethodPost, toolPath(tool, "drift", name, action), nil, nil, true, &resp); err != nil {
+		return "", err
+	}
+	return resp.Profile, nil
+}
+
+// Share asks the server for a one-time link to a bundle of profile that
+// expires after ttl, or the server's default when ttl is 0.
+func (c *Client) Share(ctx context.Context, tool, profile string, ttl time.Duration) (ShareLink, error) {
+	body := map[string]any{}
+	if ttl > 0 {
+		body["ttl"] = ttl.String()
+	}
+	var link ShareLink
+	err := c.do(ctx, http.MethodPost, toolPath(tool, "profiles", profile, "share"), nil, body, false, &link)
+	return link, err
+}
+
+// Events subscribes to the server's event stream and calls fn for each
+// event until ctx is cancelled, the stream ends, or fn returns an error.
+// Cancellation returns ctx.Err().
+func (c *Client) Events(ctx context.Context, fn func(Event) error) error {
+	resp, err := c.send(ctx, http.MethodGet, api.APIPrefix+"/events", nil, nil, true)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	var data strings.Builder
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data.Len() == 0 {
+				continue
+			}
+			var ev Event
+			if err := json.Unmarshal([]byte(data.String()), &ev); err != nil {
+				return fmt.Errorf("decode event: %w", err)
+			}
+			data.Reset()
+			if err := fn(ev); err != nil {
+				return err
+			}
+		case strings.HasPrefix(line, "data:"):
+			data.WriteString(strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read events: %w", err)
+	}
+	return io.ErrUnexpectedEOF
+}
+
+func toolPath(tool string, parts ...string) string {
+	escaped := []string{api.APIPrefix, url.PathEscape(tool)}
+	for _, p := range parts {
+		escaped = append(escaped, url.PathEscape(p))
+	}
+	return strings.Join(escaped, "/")
+}
+
+// do sends a request and decodes a JSON answer into out when non-nil.
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, body any, idempotent bool, out any) error {
+	resp, err := c.send(ctx, method, path, query, body, idempotent)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode %s %s: %w", method, path, err)
+	}
+	return nil
+}
+
+// send performs the request, retrying idempotent ones, and returns the
+// response of the first 2xx answer. Other answers are turned into *Error.
+// GET answers that carry an ETag are remembered and revalidated next time,
+// a 304 being answered from memory.
+func (c *Client) send(ctx context.Context, method, path string, query url.Values, body any, idempotent bool) (*http.Response, error) {
+	var payload []byte
+	contentType := "application/json"
+	if raw, ok := body.(rawBody); ok {
+		payload, contentType = raw.data, raw.contentType
+	} else if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("encode request: %w", err)
+		}
+	}
+
+	target := c.baseURL + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	attempts := 1
+	if idempotent {
+		attempts += c.retries
+	}
+	backoff := c.backoff
+	ifNoneMatch := ""
+	if method == http.MethodGet {
+		ifNoneMatch = c.cache.etag(target)
+	}
+	for attempt := 1; ; attempt++ {
+		resp, err := c.sendOnce(ctx, method, target, contentType, payload, ifNoneMatch)
+		if err == nil && resp.StatusCode == http.StatusNotModified {
+			if cached, ok := c.cache.replay(target, resp); ok {
+				return cached, nil
+			}
+		}
+		if err == nil && resp.StatusCode < 300 {
+			if method == http.MethodGet {
+				return c.cache.store(target, resp)
+			}
+			return resp, nil
+		}
+		if err == nil {
+			err = readError(resp)
+		}
+		if attempt >= attempts || !retryable(ctx, err) {
+			return nil, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// rawBody is a request body sent as is rather than encoded as JSON.
+type rawBody struct {
+	contentType string
+	data        []byte
+}
+
+func (c *Client) sendOnce(ctx context.Context, method, target, contentType string, payload []byte, ifNoneMatch string) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(api.APIVersionHeader, api.APIVersion)
+	if ctx.Value(preferAsyncKey{}) != nil {
+		req.Header.Set("Prefer", "respond-async")
+	}
+	if ifNoneMatch != "" {
+		req.Header.Set("If-None-Match", ifNoneMatch)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	return c.httpClient.Do(req)
+}
+
+func readError(resp *http.Response) error {
+	defer resp.Body.Close()
+	apiErr := &Error{StatusCode: resp.StatusCode}
+	var body struct {
+		Error    string        `json:"error"`
+		Code     string        `json:"code"`
+		Files    []FileDiff    `json:"files"`
+		Problems []FileProblem `json:"problems"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if json.Unmarshal(data, &body) == nil && body.Error != "" {
+		apiErr.Message = body.Error
+		apiErr.Code = body.Code
+		apiErr.Files = body.Files
+		apiErr.Problems = body.Problems
+	} else {
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	return apiErr
+}
+
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return true
+}
@@ -0,0 +1,423 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"tokyo/api"
+	"tokyo/pkg/profile"
+)
+
+func newTestServer(t *testing.T, opts ...api.Option) (*httptest.Server, string) {
+	t.Helper()
+	home := t.TempDir()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"a"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	tool := profile.ClaudeTool().WithHome(home)
+	srv := httptest.NewServer(api.NewServer(append([]api.Option{api.WithTools(tool)}, opts...)...))
+	t.Cleanup(srv.Close)
+	return srv, configPath
+}
+
+func TestClientRoundTrip(t *testing.T) {
+	srv, configPath := newTestServer(t, api.WithAuthToken("secret"))
+	c, err := New(srv.URL, WithToken("secret"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	if err := c.Save(ctx, "claude", "work", SaveOptions{}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := c.Save(ctx, "claude", "work", SaveOptions{}); !IsConflict(err) || !HasCode(err, api.CodeProfileExists) {
+		t.Fatalf("expected conflict on second save, got %v", err)
+	}
+	profiles, err := c.List(ctx, "claude")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(profiles) != 1 || profiles[0] != "work" {
+		t.Fatalf("expected [work], got %v", profiles)
+	}
+	if err := c.Switch(ctx, "claude", "work", SwitchOptions{}); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"model":"b"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	status, err := c.Current(ctx, "claude")
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status.Profile != "work" || !status.Modified {
+		t.Fatalf("expected modified work, got %+v", status)
+	}
+	if status.Files != nil {
+		t.Fatalf("expected no files without detail, got %+v", status.Files)
+	}
+	status, err = c.CurrentDetail(ctx, "claude")
+	if err != nil {
+		t.Fatalf("CurrentDetail: %v", err)
+	}
+	if len(status.Files) != 1 || status.Files[0].Path != configPath {
+		t.Fatalf("expected settings.json in detail, got %+v", status.Files)
+	}
+	diffs, err := c.Diff(ctx, "claude", "")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(diffs) != 1 || diffs[0].Status != profile.FileModified {
+		t.Fatalf("expected one modified file, got %+v", diffs)
+	}
+	err = c.Switch(ctx, "claude", "work", SwitchOptions{})
+	var apiErr *Error
+	if !IsConflict(err) || !errors.As(err, &apiErr) || len(apiErr.Files) != 1 || apiErr.Files[0].Path != configPath {
+		t.Fatalf("expected conflict listing settings.json, got %v", err)
+	}
+	if err := c.Switch(ctx, "claude", "work", SwitchOptions{Force: true}); err != nil {
+		t.Fatalf("Switch force: %v", err)
+	}
+	usage, err := c.Usage(ctx, "claude")
+	if err != nil {
+		t.Fatalf("Usage: %v", err)
+	}
+	if len(usage) != 1 || usage[0].Profile != "work" || usage[0].Activations != 1 || usage[0].LastActive == nil {
+		t.Fatalf("expected work to have been switched to once, got %+v", usage)
+	}
+
+	cleared, err := c.Delete(ctx, "claude", "work")
+	if err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if !cleared {
+		t.Fatalf("expected active profile to be cleared")
+	}
+	if _, err := c.Delete(ctx, "claude", "work"); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestClientUnauthorized(t *testing.T) {
+	srv, _ := newTestServer(t, api.WithAuthToken("secret"))
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	_, err = c.List(context.Background(), "claude")
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 error, got %v", err)
+	}
+}
+
+func TestClientRetriesIdempotentRequests(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, `{"error":"busy"}`, http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"profiles":["work"]}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL, WithRetries(2, time.Millisecond))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	profiles, err := c.List(context.Background(), "claude")
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(profiles) != 1 || calls.Load() != 3 {
+		t.Fatalf("expected success after 3 calls, got %v after %d", profiles, calls.Load())
+	}
+
+	calls.Store(0)
+	err = c.Save(context.Background(), "claude", "work", SaveOptions{})
+	if !hasStatus(err, http.StatusServiceUnavailable) || calls.Load() != 1 {
+		t.Fatalf("expected save not to be retried, got %v after %d calls", err, calls.Load())
+	}
+}
+
+func TestClientEvents(t *testing.T) {
+	srv, _ := newTestServer(t)
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	got := make(chan Event, 1)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.Events(ctx, func(ev Event) error {
+			got <- ev
+			return errors.New("stop")
+		})
+	}()
+
+	// Keep saving until the subscription is live and sees an event.
+	for i := 0; ; i++ {
+		if err := c.Save(ctx, "claude", "work", SaveOptions{Force: true}); err != nil {
+			t.Fatalf("Save: %v", err)
+		}
+		select {
+		case ev := <-got:
+			if ev.Type != api.EventProfileSaved || ev.Tool != "claude" || ev.Profile != "work" {
+				t.Fatalf("unexpected event %+v", ev)
+			}
+			if err := <-done; err == nil || err.Error() != "stop" {
+				t.Fatalf("expected callback error, got %v", err)
+			}
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+		if ctx.Err() != nil {
+			t.Fatalf("no event received")
+		}
+	}
+}
+
+func TestClientRegistry(t *testing.T) {
+	srv, _ := newTestServer(t, api.WithAuthToken("secret"), api.WithRegistry(t.TempDir()))
+	c, err := New(srv.URL, WithToken("secret"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	tool := profile.ClaudeTool().WithHome(t.TempDir())
+	if err := profile.SaveFiles(tool, "work", map[string][]byte{"settings.json": []byte(`{}`)}, true); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	var bundle bytes.Buffer
+	if err := profile.Export(tool, []string{"work"}, &bundle); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	version, err := c.Publish(ctx, "claude", "work", bundle.Bytes())
+	if err != nil || version != 1 {
+		t.Fatalf("Publish = %d, %v", version, err)
+	}
+	versions, err := c.Versions(ctx, "claude", "work")
+	if err != nil || len(versions) != 1 || versions[0].Version != 1 {
+		t.Fatalf("Versions = %+v, %v", versions, err)
+	}
+	data, pulled, err := c.Pull(ctx, "claude", "work", 0)
+	if err != nil || pulled != 1 || !bytes.Equal(data, bundle.Bytes()) {
+		t.Fatalf("Pull = %d, %v", pulled, err)
+	}
+	if _, _, err := c.Pull(ctx, "claude", "work", 2); !IsNotFound(err) {
+		t.Fatalf("expected not found for version 2, got %v", err)
+	}
+}
+
+func TestClientShare(t *testing.T) {
+	srv, _ := newTestServer(t, api.WithAuthToken("secret"))
+	c, err := New(srv.URL, WithToken("secret"))
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+	if err := c.Save(ctx, "claude", "work", SaveOptions{}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	link, err := c.Share(ctx, "claude", "work", time.Minute)
+	if err != nil {
+		t.Fatalf("Share: %v", err)
+	}
+	if !strings.HasPrefix(link.URL, srv.URL+"/share/") || link.Profile != "work" {
+		t.Fatalf("unexpected link %+v", link)
+	}
+	resp, err := http.Get(link.URL)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("download: %s", resp.Status)
+	}
+	if _, err := c.Share(ctx, "claude", "missing", 0); !IsNotFound(err) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestClientFiles(t *testing.T) {
+	srv, _ := newTestServer(t)
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+	if err := c.Save(ctx, "claude", "work", SaveOptions{}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := c.WriteFile(ctx, "claude", "work", "settings.json", []byte(`{"model":"b"}`)); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	files, err := c.Files(ctx, "claude", "work")
+	if err != nil || len(files) != 1 || files[0].Content != `{"model":"b"}` {
+		t.Fatalf("Files = %+v, %v", files, err)
+	}
+
+	err = c.WriteFile(ctx, "claude", "work", "settings.json", []byte(`{"model":`))
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnprocessableEntity || len(apiErr.Problems) != 1 {
+		t.Fatalf("expected 422 with a problem, got %v", err)
+	}
+}
+
+func TestClientResolveDrift(t *testing.T) {
+	srv, configPath := newTestServer(t)
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+	if _, err := c.AcceptFile(ctx, "claude", "settings.json"); !IsNotFound(err) {
+		t.Fatalf("expected not found without an active profile, got %v", err)
+	}
+	if err := c.Save(ctx, "claude", "work", SaveOptions{}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := c.Switch(ctx, "claude", "work", SwitchOptions{}); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"model":"b"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if active, err := c.RevertFile(ctx, "claude", "settings.json"); err != nil || active != "work" {
+		t.Fatalf("RevertFile = %q, %v", active, err)
+	}
+	if data, _ := os.ReadFile(configPath); string(data) != `{"model":"a"}` {
+		t.Fatalf("settings.json not reverted: %s", data)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"model":"c"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if active, err := c.AcceptFile(ctx, "claude", "settings.json"); err != nil || active != "work" {
+		t.Fatalf("AcceptFile = %q, %v", active, err)
+	}
+	status, err := c.Current(ctx, "claude")
+	if err != nil || status.Modified {
+		t.Fatalf("expected no drift after accepting, got %+v, %v", status, err)
+	}
+}
+
+func TestClientRevalidatesWithETags(t *testing.T) {
+	var notModified atomic.Int32
+	srv, _ := newTestServer(t)
+	counting := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied, _ := http.NewRequest(r.Method, srv.URL+r.URL.String(), r.Body)
+		proxied.Header = r.Header
+		resp, err := http.DefaultClient.Do(proxied)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotModified {
+			notModified.Add(1)
+		}
+		for k, v := range resp.Header {
+			w.Header()[k] = v
+		}
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+	}))
+	defer counting.Close()
+
+	c, err := New(counting.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+	if err := c.Save(ctx, "claude", "work", SaveOptions{}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		profiles, err := c.List(ctx, "claude")
+		if err != nil || len(profiles) != 1 || profiles[0] != "work" {
+			t.Fatalf("List #%d = %v, %v", i, profiles, err)
+		}
+	}
+	if got := notModified.Load(); got != 2 {
+		t.Fatalf("expected the repeated lists to be answered 304, got %d", got)
+	}
+	if err := c.Save(ctx, "claude", "home", SaveOptions{}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if profiles, err := c.List(ctx, "claude"); err != nil || len(profiles) != 2 {
+		t.Fatalf("expected a fresh list after saving, got %v, %v", profiles, err)
+	}
+}
+
+func TestClientUsesVersionedAPI(t *testing.T) {
+	var path, version string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, version = r.URL.Path, r.Header.Get(api.APIVersionHeader)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"profiles":[]}`))
+	}))
+	defer srv.Close()
+
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	if _, err := c.List(context.Background(), "claude"); err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if path != "/api/v1/claude/profiles" || version != api.APIVersion {
+		t.Fatalf("expected a versioned request, got %q with version %q", path, version)
+	}
+}
+
+func TestClientOperations(t *testing.T) {
+	srv, _ := newTestServer(t)
+	c, err := New(srv.URL)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	op, err := c.StartSave(ctx, "claude", "work", SaveOptions{})
+	if err != nil || op.ID == "" || op.Kind != api.OperationSave {
+		t.Fatalf("StartSave: %+v, %v", op, err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for op.State == api.OperationRunning && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+		if op, err = c.Operation(ctx, op.ID); err != nil {
+			t.Fatalf("Operation: %v", err)
+		}
+	}
+	if op.State != api.OperationSucceeded {
+		t.Fatalf("expected the save to succeed, got %+v", op)
+	}
+	if _, err := c.Operation(ctx, "unknown"); !IsNotFound(err) {
+		t.Fatalf("expected an unknown operation to be not found, got %v", err)
+	}
+}
@@ -0,0 +1,75 @@
+package client
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// maxCachedResponses bounds the answers a Client keeps for revalidation.
+const maxCachedResponses = 64
+
+// etagCache keeps the last answer to each GET that carried an ETag, so that
+// asking again sends If-None-Match and a 304 is answered from memory.
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]cachedResponse
+}
+
+type cachedResponse struct {
+	etag string
+	body []byte
+}
+
+// etag returns the ETag to revalidate target with, if any.
+func (c *etagCache) etag(target string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[target].etag
+}
+
+// store remembers resp, a 2xx answer to a GET of target, when it has an
+// ETag. The returned response replaces resp, whose body has been read.
+func (c *etagCache) store(target string, resp *http.Response) (*http.Response, error) {
+	etag := resp.Header.Get("ETag")
+	if etag == "" {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if c.entries == nil {
+		c.entries = make(map[string]cachedResponse)
+	}
+	if _, ok := c.entries[target]; !ok && len(c.entries) >= maxCachedResponses {
+		for k := range c.entries {
+			delete(c.entries, k)
+			break
+		}
+	}
+	c.entries[target] = cachedResponse{etag: etag, body: body}
+	c.mu.Unlock()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// replay turns resp, a 304 answer to a GET of target, into the 200 answer
+// it confirmed. ok is false when nothing is cached for target.
+func (c *etagCache) replay(target string, resp *http.Response) (*http.Response, bool) {
+	c.mu.Lock()
+	cached, ok := c.entries[target]
+	c.mu.Unlock()
+	if !ok {
+		return nil, false
+	}
+	resp.Body.Close()
+	resp.StatusCode = http.StatusOK
+	resp.Status = "200 OK"
+	resp.Body = io.NopCloser(bytes.NewReader(cached.body))
+	resp.ContentLength = int64(len(cached.body))
+	return resp, true
+}
@@ -0,0 +1,662 @@
+// Package config loads tokyo's own settings from ~/.config/tokyo/config.yaml.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"tokyo/pkg/i18n"
+	"tokyo/pkg/passphrase"
+	"tokyo/pkg/profile"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// FileName is the name of the config file inside the tokyo directory.
+const FileName = "config.yaml"
+
+// Color modes.
+const (
+	ColorAuto   = "auto"
+	ColorAlways = "always"
+	ColorNever  = "never"
+)
+
+// Web UI themes.
+const (
+	ThemeSystem = "system"
+	ThemeDark   = "dark"
+	ThemeLight  = "light"
+)
+
+// Drift policies: what `tokyo <tool> watch` does when the live config
+// drifts from the active profile.
+const (
+	// DriftRecord only reports the drift.
+	DriftRecord = "record"
+	// DriftRestore puts back the active profile's copies of the drifted
+	// files.
+	DriftRestore = "restore"
+	// DriftResave stores the drifted files in the active profile.
+	DriftResave = "resave"
+)
+
+// ErrUnknownKey is returned by Get and Set for keys that do not exist.
+var ErrUnknownKey = errors.New("unknown config key")
+
+// Config holds tokyo's own settings. The zero value is the default
+// configuration.
+type Config struct {
+	Serve           Serve             `yaml:"serve,omitempty"`
+	Color           string            `yaml:"color,omitempty"`
+	Confirm         *bool             `yaml:"confirm,omitempty"`
+	Hooks           Hooks             `yaml:"hooks,omitempty"`
+	DefaultProfiles map[string]string `yaml:"default_profiles,omitempty"`
+	// DriftPolicies holds the drift policy of each tool; see DriftPolicy.
+	DriftPolicies map[string]string `yaml:"drift_policy,omitempty"`
+	Tools         []string          `yaml:"tools,omitempty"`
+	// Remote is the base URL of a tokyo server used by commands that talk
+	// to one instead of the local store.
+	Remote string `yaml:"remote,omitempty"`
+	// Retention bounds autosaves and trashed profiles; see `tokyo gc`.
+	Retention Retention `yaml:"retention,omitempty"`
+	// Language selects the language of CLI messages. Empty means the
+	// locale from LC_ALL, LC_MESSAGES or LANG.
+	Language string `yaml:"language,omitempty"`
+	// Workspace selects a separate set of profile stores; see
+	// profile.Tool.WithWorkspace. Empty means the default set.
+	Workspace string `yaml:"workspace,omitempty"`
+	// CaptureEnv names environment variables `tokyo <tool> save` stores
+	// with the profile.
+	CaptureEnv []string `yaml:"capture_env,omitempty"`
+	// ScanSecrets turns off the warnings save prints for credentials found
+	// outside the fields a tool keeps its own in when false.
+	ScanSecrets *bool `yaml:"scan_secrets,omitempty"`
+	// Askpass is a shell command that prints a passphrase when one is
+	// needed and TOKYO_PASSPHRASE is unset; see passphrase.Source.
+	Askpass string `yaml:"askpass,omitempty"`
+	// Switch bounds how switches retry transient file errors.
+	Switch SwitchSettings `yaml:"switch,omitempty"`
+	// MaxFileSize is the largest config file save and switch accept, such
+	// as "64MiB"; see FileSizeLimit.
+	MaxFileSize string `yaml:"max_file_size,omitempty"`
+	// RestoreIntent records the profile each lasting switch makes active,
+	// for `tokyo restore-intent` to put back; see profile.RestoreIntent.
+	RestoreIntent bool `yaml:"restore_intent,omitempty"`
+}
+
+// SwitchSettings hold the retry policy of switches, and what they print.
+// Unset retry fields keep the defaults of profile.DefaultRetryPolicy; a
+// timeout of "0" removes the limit.
+type SwitchSettings struct {
+	Retries *int   `yaml:"retries,omitempty"`
+	Timeout string `yaml:"timeout,omitempty"`
+	// Annotations names the profile annotations a switch prints, in order.
+	// Empty prints them all.
+	Annotations []string `yaml:"annotations,omitempty"`
+}
+
+// Retention holds per-category limits. Unset fields keep the defaults of
+// profile.DefaultRetention.
+type Retention struct {
+	Autosaves Limits `yaml:"autosaves,omitempty"`
+	Trash     Limits `yaml:"trash,omitempty"`
+}
+
+// Limits bounds one retention category. A max_count of 0 or a max_age of
+// "0" removes that limit.
+type Limits struct {
+	MaxCount *int   `yaml:"max_count,omitempty"`
+	MaxAge   string `yaml:"max_age,omitempty"`
+}
+
+// Serve holds defaults for `tokyo serve`.
+type Serve struct {
+	Addr  string `yaml:"addr,omitempty"`
+	Token string `yaml:"token,omitempty"`
+	// Theme is the web UI's theme until a browser picks its own. Empty
+	// means ThemeSystem.
+	Theme string `yaml:"theme,omitempty"`
+}
+
+// Hooks are shell commands run around `tokyo <tool> switch`.
+type Hooks struct {
+	PreSwitch  string `yaml:"pre_switch,omitempty"`
+	PostSwitch string `yaml:"post_switch,omitempty"`
+}
+
+// ShouldConfirm reports whether destructive bulk operations prompt first.
+func (c Config) ShouldConfirm() bool {
+	return c.Confirm == nil || *c.Confirm
+}
+
+// ShouldScanSecrets reports whether save warns about credentials in the
+// files it stores.
+func (c Config) ShouldScanSecrets() bool {
+	return c.ScanSecrets == nil || *c.ScanSecrets
+}
+
+// Passphrase returns where features that need a passphrase get it from.
+func (c Config) Passphrase() passphrase.Source {
+	return passphrase.Source{Askpass: c.Askpass}
+}
+
+// ToolEnabled reports whether the tool called name is enabled. All tools are
+// enabled when Tools is empty.
+func (c Config) ToolEnabled(name string) bool {
+	return len(c.Tools) == 0 || slices.Contains(c.Tools, name)
+}
+
+// DefaultProfile returns the configured default profile for tool, if any.
+func (c Config) DefaultProfile(tool string) string {
+	return c.DefaultProfiles[tool]
+}
+
+// DriftPolicy returns the drift policy configured for tool, DriftRecord
+// when none is.
+func (c Config) DriftPolicy(tool string) string {
+	if policy := c.DriftPolicies[tool]; policy != "" {
+		return policy
+	}
+	return DriftRecord
+}
+
+// Path returns the location of the config file, inside $TOKYO_HOME when set.
+func Path() (string, error) {
+	root, err := profile.DefaultStoreRoot()
+	if err != nil {
+		return "", fmt.Errorf("resolve home: %w", err)
+	}
+	return filepath.Join(root, FileName), nil
+}
+
+// Load reads the config file and applies environment overrides. A missing
+// file yields the default config. Command-line flags take precedence over
+// both and are applied by the commands that define them.
+func Load() (Config, error) {
+	path, err := Path()
+	if err != nil {
+		return Config{}, err
+	}
+	cfg, err := LoadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	if _, err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return cfg, err
+	}
+	return cfg, nil
+}
+
+// LoadFile reads the config file at path. A missing file yields the default
+// config.
+func LoadFile(path string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cfg, nil
+		}
+		return cfg, fmt.Errorf("read %s: %w", path, err)
+	}
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return cfg, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// SaveFile writes cfg to path, creating its directory if needed.
+func SaveFile(path string, cfg Config) error {
+	data, err := yaml.Marshal(cfg)
+	if err != nil {
+		return fmt.Errorf("encode config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("create config dir: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".config-*.yaml")
+	if err != nil {
+		return fmt.Errorf("create temp config: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("replace config: %w", err)
+	}
+	return nil
+}
+
+// RetentionPolicy returns the configured retention policy.
+func (c Config) RetentionPolicy() (profile.RetentionPolicy, error) {
+	policy := profile.DefaultRetention()
+	var err error
+	if policy.Autosaves, err = c.Retention.Autosaves.apply(policy.Autosaves); err != nil {
+		return policy, fmt.Errorf("retention.autosaves: %w", err)
+	}
+	if policy.Trash, err = c.Retention.Trash.apply(policy.Trash); err != nil {
+		return policy, fmt.Errorf("retention.trash: %w", err)
+	}
+	return policy, nil
+}
+
+// RetryPolicy returns the switch retry policy with the defaults applied.
+func (c Config) RetryPolicy() (profile.RetryPolicy, error) {
+	policy := profile.DefaultRetryPolicy()
+	if c.Switch.Retries != nil {
+		policy.Retries = *c.Switch.Retries
+	}
+	if c.Switch.Timeout != "" {
+		timeout, err := ParseAge(c.Switch.Timeout)
+		if err != nil {
+			return policy, fmt.Errorf("switch.timeout: %w", err)
+		}
+		policy.Timeout = timeout
+	}
+	return policy, nil
+}
+
+// FileSizeLimit returns the size limit for config files in bytes, as
+// profile.Tool.MaxFileSize takes it: zero for the default and negative when
+// max_file_size is "0".
+func (c Config) FileSizeLimit() (int64, error) {
+	if c.MaxFileSize == "" {
+		return 0, nil
+	}
+	n, err := ParseSize(c.MaxFileSize)
+	if err != nil {
+		return 0, fmt.Errorf("max_file_size: %w", err)
+	}
+	if n == 0 {
+		return -1, nil
+	}
+	return n, nil
+}
+
+// ParseSize parses a byte count such as "1048576", "512KiB" or "64 MB". Units
+// are powers of 1024 whichever spelling is used.
+func ParseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	num := strings.TrimRight(s, "KMGTiBkmgtib ")
+	unit := strings.ToUpper(strings.TrimSpace(s[len(num):]))
+	n, err := strconv.ParseInt(num, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	shift := map[string]uint{"": 0, "B": 0, "K": 10, "KB": 10, "KIB": 10, "M": 20, "MB": 20, "MIB": 20, "G": 30, "GB": 30, "GIB": 30, "T": 40, "TB": 40, "TIB": 40}
+	bits, ok := shift[unit]
+	if !ok || n > math.MaxInt64>>bits {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n << bits, nil
+}
+
+func (l Limits) apply(r profile.Retention) (profile.Retention, error) {
+	if l.MaxCount != nil {
+		r.MaxCount = *l.MaxCount
+	}
+	if l.MaxAge != "" {
+		age, err := ParseAge(l.MaxAge)
+		if err != nil {
+			return r, err
+		}
+		r.MaxAge = age
+	}
+	return r, nil
+}
+
+// ParseAge parses a duration such as "720h" or "30d".
+func ParseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid age %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age %q", s)
+	}
+	return d, nil
+}
+
+func (c Config) validate() error {
+	switch c.Color {
+	case "", ColorAuto, ColorAlways, ColorNever:
+	default:
+		return fmt.Errorf("color must be %s, %s or %s, got %q", ColorAuto, ColorAlways, ColorNever, c.Color)
+	}
+	switch c.Serve.Theme {
+	case "", ThemeSystem, ThemeDark, ThemeLight:
+	default:
+		return fmt.Errorf("serve.theme must be %s, %s or %s, got %q", ThemeSystem, ThemeDark, ThemeLight, c.Serve.Theme)
+	}
+	for tool, policy := range c.DriftPolicies {
+		if err := validateDriftPolicy(policy); err != nil {
+			return fmt.Errorf("%s%s: %w", driftPolicyPrefix, tool, err)
+		}
+	}
+	if c.Language != "" && !i18n.Supported(c.Language) {
+		return fmt.Errorf("language must be one of %s, got %q", strings.Join(i18n.Languages(), ", "), c.Language)
+	}
+	if c.Workspace != "" {
+		if err := profile.ValidateWorkspaceName(c.Workspace); err != nil {
+			return err
+		}
+	}
+	if _, err := c.RetentionPolicy(); err != nil {
+		return err
+	}
+	if _, err := c.RetryPolicy(); err != nil {
+		return err
+	}
+	_, err := c.FileSizeLimit()
+	return err
+}
+
+func validateDriftPolicy(policy string) error {
+	switch policy {
+	case DriftRecord, DriftRestore, DriftResave:
+		return nil
+	}
+	return fmt.Errorf("drift policy must be %s, %s or %s, got %q", DriftRecord, DriftRestore, DriftResave, policy)
+}
+
+type field struct {
+	get func(c *Config) string
+	set func(c *Config, value string) error
+}
+
+var fields = map[string]field{
+	"askpass": {
+		get: func(c *Config) string { return c.Askpass },
+		set: func(c *Config, v string) error { c.Askpass = v; return nil },
+	},
+	"capture_env": listField(func(c *Config) *[]string { return &c.CaptureEnv }),
+	"serve.addr": {
+		get: func(c *Config) string { return c.Serve.Addr },
+		set: func(c *Config, v string) error { c.Serve.Addr = v; return nil },
+	},
+	"serve.token": {
+		get: func(c *Config) string { return c.Serve.Token },
+		set: func(c *Config, v string) error { c.Serve.Token = v; return nil },
+	},
+	"serve.theme": {
+		get: func(c *Config) string { return c.Serve.Theme },
+		set: func(c *Config, v string) error { c.Serve.Theme = v; return c.validate() },
+	},
+	"color": {
+		get: func(c *Config) string { return c.Color },
+		set: func(c *Config, v string) error { c.Color = v; return c.validate() },
+	},
+	"confirm": boolField("confirm", func(c *Config) **bool { return &c.Confirm }),
+	"hooks.pre_switch": {
+		get: func(c *Config) string { return c.Hooks.PreSwitch },
+		set: func(c *Config, v string) error { c.Hooks.PreSwitch = v; return nil },
+	},
+	"hooks.post_switch": {
+		get: func(c *Config) string { return c.Hooks.PostSwitch },
+		set: func(c *Config, v string) error { c.Hooks.PostSwitch = v; return nil },
+	},
+	"language": {
+		get: func(c *Config) string { return c.Language },
+		set: func(c *Config, v string) error { c.Language = v; return c.validate() },
+	},
+	"remote": {
+		get: func(c *Config) string { return c.Remote },
+		set: func(c *Config, v string) error { c.Remote = v; return nil },
+	},
+	"retention.autosaves.max_count": limitCountField(autosaveLimits, profile.DefaultRetention().Autosaves),
+	"retention.autosaves.max_age":   limitAgeField(autosaveLimits, profile.DefaultRetention().Autosaves),
+	"retention.trash.max_count":     limitCountField(trashLimits, profile.DefaultRetention().Trash),
+	"retention.trash.max_age":       limitAgeField(trashLimits, profile.DefaultRetention().Trash),
+	"switch.annotations":            listField(func(c *Config) *[]string { return &c.Switch.Annotations }),
+	"switch.retries": {
+		get: func(c *Config) string {
+			if n := c.Switch.Retries; n != nil {
+				return strconv.Itoa(*n)
+			}
+			return strconv.Itoa(profile.DefaultRetryPolicy().Retries)
+		},
+		set: func(c *Config, v string) error {
+			if v == "" {
+				c.Switch.Retries = nil
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("switch.retries must be a non-negative integer, got %q", v)
+			}
+			c.Switch.Retries = &n
+			return nil
+		},
+	},
+	"switch.timeout": {
+		get: func(c *Config) string {
+			if c.Switch.Timeout != "" {
+				return c.Switch.Timeout
+			}
+			return profile.DefaultRetryPolicy().Timeout.String()
+		},
+		set: func(c *Config, v string) error {
+			if v != "" {
+				if _, err := ParseAge(v); err != nil {
+					return err
+				}
+			}
+			c.Switch.Timeout = v
+			return nil
+		},
+	},
+	"max_file_size": {
+		get: func(c *Config) string {
+			if c.MaxFileSize != "" {
+				return c.MaxFileSize
+			}
+			return strings.ReplaceAll(profile.FormatSize(profile.DefaultMaxFileSize), " ", "")
+		},
+		set: func(c *Config, v string) error {
+			if v != "" {
+				if _, err := ParseSize(v); err != nil {
+					return err
+				}
+			}
+			c.MaxFileSize = v
+			return nil
+		},
+	},
+	"restore_intent": {
+		get: func(c *Config) string { return strconv.FormatBool(c.RestoreIntent) },
+		set: func(c *Config, v string) error {
+			if v == "" {
+				c.RestoreIntent = false
+				return nil
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("restore_intent must be true or false, got %q", v)
+			}
+			c.RestoreIntent = b
+			return nil
+		},
+	},
+	"scan_secrets": boolField("scan_secrets", func(c *Config) **bool { return &c.ScanSecrets }),
+	"tools":        listField(func(c *Config) *[]string { return &c.Tools }),
+	"workspace": {
+		get: func(c *Config) string { return c.Workspace },
+		set: func(c *Config, v string) error { c.Workspace = v; return c.validate() },
+	},
+}
+
+func autosaveLimits(c *Config) *Limits { return &c.Retention.Autosaves }
+func trashLimits(c *Config) *Limits    { return &c.Retention.Trash }
+
+// boolField reads and writes an optional boolean that defaults to true.
+func boolField(name string, value func(*Config) **bool) field {
+	return field{
+		get: func(c *Config) string {
+			b := *value(c)
+			return strconv.FormatBool(b == nil || *b)
+		},
+		set: func(c *Config, v string) error {
+			if v == "" {
+				*value(c) = nil
+				return nil
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false, got %q", name, v)
+			}
+			*value(c) = &b
+			return nil
+		},
+	}
+}
+
+// listField reads and writes a comma-separated list.
+func listField(list func(*Config) *[]string) field {
+	return field{
+		get: func(c *Config) string { return strings.Join(*list(c), ",") },
+		set: func(c *Config, v string) error {
+			*list(c) = nil
+			for _, item := range strings.Split(v, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					*list(c) = append(*list(c), item)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// limitCountField and limitAgeField report the default when a limit is unset.
+func limitCountField(limits func(*Config) *Limits, def profile.Retention) field {
+	return field{
+		get: func(c *Config) string {
+			if n := limits(c).MaxCount; n != nil {
+				return strconv.Itoa(*n)
+			}
+			return strconv.Itoa(def.MaxCount)
+		},
+		set: func(c *Config, v string) error {
+			if v == "" {
+				limits(c).MaxCount = nil
+				return nil
+			}
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				return fmt.Errorf("max_count must be a non-negative integer, got %q", v)
+			}
+			limits(c).MaxCount = &n
+			return nil
+		},
+	}
+}
+
+func limitAgeField(limits func(*Config) *Limits, def profile.Retention) field {
+	return field{
+		get: func(c *Config) string {
+			if age := limits(c).MaxAge; age != "" {
+				return age
+			}
+			return def.MaxAge.String()
+		},
+		set: func(c *Config, v string) error {
+			if v != "" {
+				if _, err := ParseAge(v); err != nil {
+					return err
+				}
+			}
+			limits(c).MaxAge = v
+			return nil
+		},
+	}
+}
+
+const (
+	defaultProfilesPrefix = "default_profiles."
+	driftPolicyPrefix     = "drift_policy."
+)
+
+// Keys returns every settable key. default_profiles.<tool> and
+// drift_policy.<tool> are listed once per configured tool.
+func Keys(c Config) []string {
+	keys := make([]string, 0, len(fields)+len(c.DefaultProfiles)+len(c.DriftPolicies))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	for tool := range c.DefaultProfiles {
+		keys = append(keys, defaultProfilesPrefix+tool)
+	}
+	for tool := range c.DriftPolicies {
+		keys = append(keys, driftPolicyPrefix+tool)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Get returns the value of key as a string.
+func Get(c Config, key string) (string, error) {
+	if tool, ok := strings.CutPrefix(key, defaultProfilesPrefix); ok && tool != "" {
+		return c.DefaultProfiles[tool], nil
+	}
+	if tool, ok := strings.CutPrefix(key, driftPolicyPrefix); ok && tool != "" {
+		return c.DriftPolicy(tool), nil
+	}
+	f, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", ErrUnknownKey, key)
+	}
+	return f.get(&c), nil
+}
+
+// Set assigns value to key. An empty value resets the key to its default.
+func Set(c *Config, key, value string) error {
+	if tool, ok := strings.CutPrefix(key, defaultProfilesPrefix); ok && tool != "" {
+		if value == "" {
+			delete(c.DefaultProfiles, tool)
+			return nil
+		}
+		if c.DefaultProfiles == nil {
+			c.DefaultProfiles = map[string]string{}
+		}
+		c.DefaultProfiles[tool] = value
+		return nil
+	}
+	if tool, ok := strings.CutPrefix(key, driftPolicyPrefix); ok && tool != "" {
+		if value == "" {
+			delete(c.DriftPolicies, tool)
+			return nil
+		}
+		if err := validateDriftPolicy(value); err != nil {
+			return err
+		}
+		if c.DriftPolicies == nil {
+			c.DriftPolicies = map[string]string{}
+		}
+		c.DriftPolicies[tool] = value
+		return nil
+	}
+	f, ok := fields[key]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownKey, key)
+	}
+	return f.set(c, value)
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"tokyo/pkg/profile"
+)
+
+func TestLoadMissingFileReturnsDefaults(t *testing.T) {
+	cfg, err := LoadFile(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if !cfg.ShouldConfirm() || !cfg.ToolEnabled("claude") || cfg.DefaultProfile("claude") != "" {
+		t.Fatalf("unexpected defaults: %+v", cfg)
+	}
+}
+
+func TestSetGetRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tokyo", FileName)
+
+	var cfg Config
+	for key, value := range map[string]string{
+		"serve.addr":              ":9090",
+		"serve.theme":             "light",
+		"confirm":                 "false",
+		"tools":                   "claude, codex",
+		"default_profiles.claude": "work",
+		"drift_policy.claude":     "restore",
+		"hooks.post_switch":       "echo done",
+		"color":                   "never",
+		"language":                "ja",
+		"capture_env":             "ANTHROPIC_BASE_URL, HTTPS_PROXY",
+		"scan_secrets":            "false",
+		"askpass":                 "pass show tokyo",
+		"switch.retries":          "2",
+		"switch.timeout":          "1m",
+		"switch.annotations":      "org, plan",
+		"restore_intent":          "true",
+		"max_file_size":           "10MiB",
+	} {
+		if err := Set(&cfg, key, value); err != nil {
+			t.Fatalf("Set %s: %v", key, err)
+		}
+	}
+	if err := SaveFile(path, cfg); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+
+	loaded, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	for key, want := range map[string]string{
+		"serve.addr":              ":9090",
+		"serve.theme":             "light",
+		"confirm":                 "false",
+		"tools":                   "claude,codex",
+		"default_profiles.claude": "work",
+		"drift_policy.claude":     "restore",
+		"hooks.post_switch":       "echo done",
+		"color":                   "never",
+		"language":                "ja",
+		"capture_env":             "ANTHROPIC_BASE_URL,HTTPS_PROXY",
+		"scan_secrets":            "false",
+		"askpass":                 "pass show tokyo",
+		"switch.retries":          "2",
+		"switch.timeout":          "1m",
+		"switch.annotations":      "org,plan",
+		"restore_intent":          "true",
+		"max_file_size":           "10MiB",
+	} {
+		got, err := Get(loaded, key)
+		if err != nil {
+			t.Fatalf("Get %s: %v", key, err)
+		}
+		if got != want {
+			t.Fatalf("%s = %q, want %q", key, got, want)
+		}
+	}
+	if policy, err := loaded.RetryPolicy(); err != nil || policy.Retries != 2 || policy.Timeout != time.Minute {
+		t.Fatalf("RetryPolicy = %+v, %v", policy, err)
+	}
+	if limit, err := loaded.FileSizeLimit(); err != nil || limit != 10<<20 {
+		t.Fatalf("FileSizeLimit = %d, %v", limit, err)
+	}
+	if loaded.DriftPolicy("claude") != DriftRestore || loaded.DriftPolicy("codex") != DriftRecord {
+		t.Fatalf("DriftPolicy = %q, %q", loaded.DriftPolicy("claude"), loaded.DriftPolicy("codex"))
+	}
+	if loaded.ToolEnabled("other") {
+		t.Fatalf("expected only listed tools to be enabled")
+	}
+}
+
+func TestSetRejectsInvalidValues(t *testing.T) {
+	var cfg Config
+	if err := Set(&cfg, "nope", "x"); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey, got %v", err)
+	}
+	if err := Set(&cfg, "color", "purple"); err == nil {
+		t.Fatalf("expected invalid color to be rejected")
+	}
+	if err := Set(&cfg, "serve.theme", "sepia"); err == nil {
+		t.Fatalf("expected invalid theme to be rejected")
+	}
+	if err := Set(&cfg, "confirm", "maybe"); err == nil {
+		t.Fatalf("expected invalid bool to be rejected")
+	}
+	if err := Set(&cfg, "scan_secrets", "sometimes"); err == nil {
+		t.Fatalf("expected invalid scan_secrets to be rejected")
+	}
+	if err := Set(&cfg, "restore_intent", "at login"); err == nil {
+		t.Fatalf("expected invalid restore_intent to be rejected")
+	}
+	if err := Set(&cfg, "language", "fr"); err == nil {
+		t.Fatalf("expected unsupported language to be rejected")
+	}
+	if err := Set(&cfg, "drift_policy.claude", "ignore"); err == nil {
+		t.Fatalf("expected unknown drift policy to be rejected")
+	}
+}
+
+func TestRetentionPolicyOverridesDefaults(t *testing.T) {
+	var cfg Config
+	if err := Set(&cfg, "retention.trash.max_age", "7d"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if err := Set(&cfg, "retention.autosaves.max_count", "0"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	policy, err := cfg.RetentionPolicy()
+	if err != nil {
+		t.Fatalf("RetentionPolicy: %v", err)
+	}
+	if policy.Trash.MaxAge != 7*24*time.Hour || policy.Autosaves.MaxCount != 0 {
+		t.Fatalf("unexpected policy: %+v", policy)
+	}
+	if policy.Trash.MaxCount != profile.DefaultRetention().Trash.MaxCount {
+		t.Fatalf("expected unset limits to keep defaults, got %+v", policy)
+	}
+	if err := Set(&cfg, "retention.trash.max_age", "soon"); err == nil {
+		t.Fatalf("expected invalid age to be rejected")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	for in, want := range map[string]int64{
+		"1048576": 1 << 20,
+		"512KiB":  512 << 10,
+		"64 MB":   64 << 20,
+		"2g":      2 << 30,
+		"0":       0,
+	} {
+		got, err := ParseSize(in)
+		if err != nil || got != want {
+			t.Errorf("ParseSize(%q) = %d, %v; want %d", in, got, err, want)
+		}
+	}
+	for _, in := range []string{"", "big", "-1MB", "10PB"} {
+		if _, err := ParseSize(in); err == nil {
+			t.Errorf("expected ParseSize(%q) to fail", in)
+		}
+	}
+
+	var cfg Config
+	if limit, err := cfg.FileSizeLimit(); err != nil || limit != 0 {
+		t.Fatalf("FileSizeLimit = %d, %v; want the default", limit, err)
+	}
+	if err := Set(&cfg, "max_file_size", "0"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	if limit, err := cfg.FileSizeLimit(); err != nil || limit >= 0 {
+		t.Fatalf("FileSizeLimit = %d, %v; want no limit", limit, err)
+	}
+}
@@ -0,0 +1,115 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"tokyo/pkg/profile"
+)
+
+// Sources reported by Resolve.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+)
+
+// envOverrides maps environment variables to the keys they override.
+var envOverrides = []struct {
+	env string
+	key string
+}{
+	{"TOKYO_ADDR", "serve.addr"},
+	{"TOKYO_TOKEN", "serve.token"},
+	{"TOKYO_COLOR", "color"},
+	{"TOKYO_CONFIRM", "confirm"},
+	{"TOKYO_TOOLS", "tools"},
+	{"TOKYO_REMOTE", "remote"},
+	{"TOKYO_LANGUAGE", "language"},
+	{"TOKYO_WORKSPACE", "workspace"},
+	{"TOKYO_CAPTURE_ENV", "capture_env"},
+	{"TOKYO_SCAN_SECRETS", "scan_secrets"},
+	{"TOKYO_ASKPASS", "askpass"},
+	{"TOKYO_SWITCH_RETRIES", "switch.retries"},
+	{"TOKYO_SWITCH_TIMEOUT", "switch.timeout"},
+	{"TOKYO_MAX_FILE_SIZE", "max_file_size"},
+}
+
+// noColorEnvs force color off when set to any non-empty value.
+var noColorEnvs = []string{"TOKYO_NO_COLOR", "NO_COLOR"}
+
+// applyEnv overrides cfg with environment variables and returns the name of
+// the variable that set each key.
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) (map[string]string, error) {
+	sources := map[string]string{}
+	for _, o := range envOverrides {
+		value, ok := lookup(o.env)
+		if !ok {
+			continue
+		}
+		if err := Set(cfg, o.key, value); err != nil {
+			return nil, fmt.Errorf("%s: %w", o.env, err)
+		}
+		sources[o.key] = o.env
+	}
+	for _, env := range noColorEnvs {
+		if value, ok := lookup(env); ok && value != "" {
+			cfg.Color = ColorNever
+			sources["color"] = env
+			break
+		}
+	}
+	return sources, nil
+}
+
+// Setting is one resolved config value and where it came from.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Resolve returns every setting after applying the config file and the
+// environment, with the source of each value: "default", "file", or the
+// name of the environment variable.
+func Resolve() ([]Setting, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	file, err := LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	cfg := file
+	envSources, err := applyEnv(&cfg, os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := []Setting{{Key: "home", Value: filepath.Dir(path), Source: SourceDefault}}
+	if os.Getenv(profile.StoreRootEnv) != "" {
+		settings[0].Source = profile.StoreRootEnv
+	}
+
+	var defaults Config
+	for _, key := range Keys(cfg) {
+		value, err := Get(cfg, key)
+		if err != nil {
+			return nil, err
+		}
+		source := SourceDefault
+		if env, ok := envSources[key]; ok {
+			source = env
+		} else if fileValue, _ := Get(file, key); fileValue != defaultValue(defaults, key) {
+			source = SourceFile
+		}
+		settings = append(settings, Setting{Key: key, Value: value, Source: source})
+	}
+	return settings, nil
+}
+
+func defaultValue(defaults Config, key string) string {
+	value, _ := Get(defaults, key)
+	return value
+}
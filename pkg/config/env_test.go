@@ -0,0 +1,57 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestEnvOverridesFile(t *testing.T) {
+	root := t.TempDir()
+	t.Setenv("TOKYO_HOME", root)
+	t.Setenv("NO_COLOR", "")
+
+	cfg := Config{Serve: Serve{Addr: ":9000", Token: "from-file"}}
+	if err := SaveFile(filepath.Join(root, FileName), cfg); err != nil {
+		t.Fatalf("SaveFile: %v", err)
+	}
+	t.Setenv("TOKYO_TOKEN", "from-env")
+	t.Setenv("TOKYO_NO_COLOR", "1")
+
+	loaded, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if loaded.Serve.Addr != ":9000" || loaded.Serve.Token != "from-env" || loaded.Color != ColorNever {
+		t.Fatalf("unexpected resolved config: %+v", loaded)
+	}
+
+	settings, err := Resolve()
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	sources := map[string]string{}
+	for _, s := range settings {
+		sources[s.Key] = s.Source
+	}
+	want := map[string]string{
+		"home":        "TOKYO_HOME",
+		"serve.addr":  SourceFile,
+		"serve.token": "TOKYO_TOKEN",
+		"color":       "TOKYO_NO_COLOR",
+		"confirm":     SourceDefault,
+	}
+	for key, source := range want {
+		if sources[key] != source {
+			t.Fatalf("source of %s = %q, want %q", key, sources[key], source)
+		}
+	}
+}
+
+func TestEnvRejectsInvalidValues(t *testing.T) {
+	t.Setenv("TOKYO_HOME", t.TempDir())
+	t.Setenv("TOKYO_CONFIRM", "sometimes")
+
+	if _, err := Load(); err == nil {
+		t.Fatalf("expected invalid TOKYO_CONFIRM to be rejected")
+	}
+}
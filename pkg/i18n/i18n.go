@@ -0,0 +1,92 @@
+// Package i18n translates tokyo's user-facing CLI messages.
+//
+// Messages are looked up by their English text, which doubles as the English
+// catalog: a message missing from the active catalog is printed in English.
+package i18n
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// English is the language messages are written in.
+const English = "en"
+
+var catalogs = map[string]map[string]string{
+	"ja": japanese,
+}
+
+var current atomic.Value
+
+// Languages returns the supported language codes.
+func Languages() []string {
+	langs := []string{English}
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// Supported reports whether lang names a supported language.
+func Supported(lang string) bool {
+	return lang == English || catalogs[lang] != nil
+}
+
+// Detect returns the language to use. configured wins when set; otherwise
+// the first of LC_ALL, LC_MESSAGES and LANG that is set decides. Unsupported
+// locales fall back to English.
+func Detect(configured string) string {
+	if configured != "" {
+		return normalize(configured)
+	}
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(env); value != "" {
+			return normalize(value)
+		}
+	}
+	return English
+}
+
+// normalize turns a locale such as "ja_JP.UTF-8" into a supported language
+// code.
+func normalize(locale string) string {
+	lang, _, _ := strings.Cut(locale, ".")
+	lang, _, _ = strings.Cut(lang, "@")
+	lang, _, _ = strings.Cut(lang, "_")
+	lang, _, _ = strings.Cut(lang, "-")
+	lang = strings.ToLower(lang)
+	if !Supported(lang) {
+		return English
+	}
+	return lang
+}
+
+// SetLanguage selects the catalog used by T and Sprintf.
+func SetLanguage(lang string) {
+	current.Store(normalize(lang))
+}
+
+// Language returns the selected language.
+func Language() string {
+	if lang, ok := current.Load().(string); ok {
+		return lang
+	}
+	return English
+}
+
+// T returns msg in the selected language.
+func T(msg string) string {
+	if translated, ok := catalogs[Language()][msg]; ok {
+		return translated
+	}
+	return msg
+}
+
+// Sprintf translates format and then formats it with args.
+func Sprintf(format string, args ...any) string {
+	return fmt.Sprintf(T(format), args...)
+}
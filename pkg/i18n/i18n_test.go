@@ -0,0 +1,111 @@
+package i18n
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		name       string
+		configured string
+		env        map[string]string
+		want       string
+	}{
+		{"default", "", nil, English},
+		{"lang", "", map[string]string{"LANG": "ja_JP.UTF-8"}, "ja"},
+		{"lc_all wins", "", map[string]string{"LC_ALL": "en_US.UTF-8", "LANG": "ja_JP.UTF-8"}, English},
+		{"lc_messages", "", map[string]string{"LC_MESSAGES": "ja_JP@euro", "LANG": "C"}, "ja"},
+		{"configured wins", "ja", map[string]string{"LC_ALL": "en_US.UTF-8"}, "ja"},
+		{"unsupported", "", map[string]string{"LANG": "fr_FR.UTF-8"}, English},
+		{"posix", "", map[string]string{"LANG": "C"}, English},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+				t.Setenv(env, tc.env[env])
+			}
+			if got := Detect(tc.configured); got != tc.want {
+				t.Fatalf("Detect(%q) = %q, want %q", tc.configured, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	t.Cleanup(func() { SetLanguage(English) })
+
+	SetLanguage(English)
+	if got := Sprintf("profile %q not found", "work"); got != `profile "work" not found` {
+		t.Fatalf("unexpected English message: %q", got)
+	}
+
+	SetLanguage("ja")
+	if got := Sprintf("profile %q not found", "work"); got != `プロファイル "work" が見つかりません` {
+		t.Fatalf("unexpected Japanese message: %q", got)
+	}
+	if got := T("not in any catalog"); got != "not in any catalog" {
+		t.Fatalf("expected untranslated messages to fall back to English, got %q", got)
+	}
+}
+
+func TestCatalogsKeepVerbs(t *testing.T) {
+	for lang, catalog := range catalogs {
+		for msg, translated := range catalog {
+			if verbs(msg) != verbs(translated) {
+				t.Errorf("%s: %q translates %q with different format verbs", lang, translated, msg)
+			}
+		}
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	cases := map[string]string{
+		"":                           English,
+		"ja":                         "ja",
+		"ja-JP,ja;q=0.9,en;q=0.8":    "ja",
+		"en-US,en;q=0.9,ja;q=0.8":    English,
+		"fr-FR,ja;q=0.5":             "ja",
+		"fr;q=0.9,ja;q=0.4,en;q=0.5": English,
+		"ja;q=0,en":                  English,
+		"*":                          English,
+		"ja;q=oops,fr":               English,
+	}
+	for header, want := range cases {
+		if got := Negotiate(header); got != want {
+			t.Errorf("Negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+var placeholder = regexp.MustCompile(`\{[a-z]+\}`)
+
+func TestUICatalogsKeepPlaceholders(t *testing.T) {
+	for lang, catalog := range uiCatalogs {
+		for msg, translated := range catalog {
+			want := placeholder.FindAllString(msg, -1)
+			got := placeholder.FindAllString(translated, -1)
+			slices.Sort(want)
+			slices.Sort(got)
+			if !slices.Equal(want, got) {
+				t.Errorf("%s: %q translates %q with different placeholders", lang, translated, msg)
+			}
+		}
+	}
+	if len(UIMessages(English)) != 0 || UIMessages("ja")["Switch"] == "" {
+		t.Fatalf("unexpected catalogs")
+	}
+}
+
+// verbs returns the format verbs of s in order.
+func verbs(s string) string {
+	var out []byte
+	for i := 0; i < len(s)-1; i++ {
+		if s[i] == '%' {
+			out = append(out, s[i+1])
+			i++
+		}
+	}
+	return string(out)
+}
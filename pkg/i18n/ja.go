@@ -0,0 +1,157 @@
+package i18n
+
+var japanese = map[string]string{
+	// Errors.
+	"Error:": "エラー:",
+	"live config is managed by profile %q (use save instead)":            "現在の設定はプロファイル %q で管理されています (save を使用してください)",
+	"expected regular file: %s":                                          "通常ファイルである必要があります: %s",
+	"profile %q is missing file: %s":                                     "プロファイル %q にファイルがありません: %s",
+	"profile %q already exists (use --force to overwrite)":               "プロファイル %q は既に存在します (上書きするには --force を指定してください)",
+	"profile %q is being modified by another tokyo process":              "プロファイル %q は別の tokyo プロセスが変更中です",
+	"profile %q already exists":                                          "プロファイル %q は既に存在します",
+	"profile %q is the base of %s":                                       "プロファイル %q は %s のベースです",
+	"profile %q is locked (run 'tokyo %s unlock %s' first)":              "プロファイル %q はロックされています (先に 'tokyo %s unlock %s' を実行してください)",
+	"profile %q is tagged protected (confirm with --confirm-production)": "プロファイル %q は protected タグ付きです (--confirm-production で確認してください)",
+	"profile %q is provided by the system store and is read-only (copy it to a profile of your own, or change it with --system)": "プロファイル %q はシステムストアで提供されている読み取り専用のプロファイルです (自分のプロファイルにコピーするか、--system を指定して変更してください)",
+	"profile %q not found":                                                                 "プロファイル %q が見つかりません",
+	"profile is missing file: %s":                                                          "プロファイルにファイルがありません: %s",
+	"no active profile (switch to or adopt one first)":                                     "有効なプロファイルがありません (先に switch または adopt を実行してください)",
+	"cannot merge %s into profile %q: %s changed on both sides":                            "%s をプロファイル %q にマージできません: %s が両方で変更されています",
+	"cannot merge %s into profile %q: conflicting changes near line %d":                    "%s をプロファイル %q にマージできません: %d 行目付近の変更が競合しています",
+	"config file not found: %s":                                                            "設定ファイルが見つかりません: %s",
+	"config file kept changing while saving: %s (try again)":                               "保存中に設定ファイルが変更され続けました: %s (もう一度お試しください)",
+	"no profile given and no default set (tokyo config set default_profiles.%s <profile>)": "プロファイルが指定されておらず、既定値もありません (tokyo config set default_profiles.%s <profile>)",
+
+	// Command descriptions.
+	"Tokyo - Manage Claude Code and Codex configuration profiles":          "Tokyo - Claude Code と Codex の設定プロファイルを管理します",
+	"Back up the profile stores of every tool":                             "すべてのツールのプロファイルストアをバックアップします",
+	"Change a setting (an empty value restores the default)":               "設定を変更します (空の値で既定値に戻します)",
+	"Print a setting, or every setting when no key is given":               "設定を表示します (キーを省略するとすべて表示します)",
+	"Print the settings from the config file":                              "設定ファイルの内容を表示します",
+	"Read and change tokyo's own settings":                                 "tokyo 自体の設定を表示・変更します",
+	"Remove blobs no profile references":                                   "どのプロファイルからも参照されていない blob を削除します",
+	"Remove old autosaves and trashed profiles":                            "古い自動保存とゴミ箱のプロファイルを削除します",
+	"Move profiles between machines":                                       "プロファイルをマシン間で移動します",
+	"Write the profile stores, or selected profiles, to an encrypted file": "プロファイルストアまたは選択したプロファイルを暗号化ファイルに書き出します",
+	"Read profiles from a file written by sync bundle":                     "sync bundle で書き出したファイルからプロファイルを読み込みます",
+	"Restore profile stores from a backup":                                 "バックアップからプロファイルストアを復元します",
+	"Run a command with a profile temporarily active":                      "プロファイルを一時的に有効にしてコマンドを実行します",
+	"Set compression for newly saved payloads":                             "新しく保存するデータの圧縮方式を設定します",
+	"Show store layout settings and blob usage":                            "ストアの構成と blob の使用量を表示します",
+	"Generate documentation for every command":                             "すべてのコマンドのドキュメントを生成します",
+	"Write a man page for every command":                                   "すべてのコマンドの man ページを書き出します",
+	"Write a Markdown page for every command":                              "すべてのコマンドの Markdown ページを書き出します",
+	"List the rules for content that does not count as a modification":     "変更として扱わない内容のルールを一覧表示します",
+	"Allow symlinked directories on the way to the config files":           "設定ファイルまでの経路にあるシンボリックリンクのディレクトリを許可します",
+	"Set whether switches copy profile files or link to them":              "切り替え時にプロファイルのファイルをコピーするかリンクするかを設定します",
+	"Hard-link files identical to one in another profile when saving":      "保存時に他のプロファイルと同一のファイルをハードリンクにします",
+	"Set how live config files are compared with profiles":                 "現在の設定ファイルとプロファイルの比較方法を設定します",
+	"Add an ignore rule":                                                   "無視ルールを追加します",
+	"Remove an ignore rule":                                                "無視ルールを削除します",
+	"Keep separate sets of profiles, such as one per client":               "クライアントごとなど、プロファイルの組を分けて管理します",
+	"List workspaces, marking the selected one":                            "ワークスペースを一覧表示し、選択中のものに印を付けます",
+	"Select the workspace used by later commands":                          "以降のコマンドで使うワークスペースを選択します",
+	"Print the selected workspace":                                         "選択中のワークスペースを表示します",
+	"Convert profiles kept by another switcher into tokyo profiles":        "他の切り替えツールのプロファイルを tokyo のプロファイルに変換します",
+	"Converge profiles and active profiles to a manifest":                  "プロファイルと有効なプロファイルをマニフェストの状態に揃えます",
+	"Show what apply would change to reach a manifest":                     "マニフェストの状態にするために apply が行う変更を表示します",
+	"Publish a profile to a registry server":                               "プロファイルをレジストリサーバーに公開します",
+	"Install a profile from a registry server":                             "レジストリサーバーからプロファイルをインストールします",
+	"Switch to the profiles a project's .tokyo file declares":              "プロジェクトの .tokyo ファイルが宣言するプロファイルに切り替えます",
+	"Check that the profiles a project's .tokyo file declares are active":  "プロジェクトの .tokyo ファイルが宣言するプロファイルが有効か確認します",
+	"Use a profile in one shell session without switching":                 "切り替えずに 1 つのシェルセッションだけでプロファイルを使います",
+	"Switch profiles automatically when entering a directory":              "ディレクトリに入ったときにプロファイルを自動で切り替えます",
+	"Print the %s hook":                                                    "%s 用のフックを表示します",
+	"Allow the hook to act on a .tokyo file":                               ".tokyo ファイルに従った切り替えをフックに許可します",
+	"Stop the hook from acting on a .tokyo file":                           ".tokyo ファイルに従った切り替えの許可を取り消します",
+	"Switch to the profiles of the nearest allowed .tokyo file":            "最も近い許可済みの .tokyo ファイルのプロファイルに切り替えます",
+	"Start the HTTP API server":                                            "HTTP API サーバーを起動します",
+	"Manage the API tokens the server accepts":                             "サーバーが受け付ける API トークンを管理します",
+	"Create an API token and print it":                                     "API トークンを作成して表示します",
+	"List API tokens":                                                      "API トークンを一覧表示します",
+	"Revoke an API token":                                                  "API トークンを無効にします",
+	"Switch to content-addressed storage and migrate existing profiles":    "コンテンツアドレス方式のストアに切り替え、既存のプロファイルを移行します",
+	"Copy a %s profile":                                                    "%s のプロファイルをコピーします",
+	"Delete a %s profile":                                                  "%s のプロファイルを削除します",
+	"Export %s profiles as a bundle":                                       "%s のプロファイルをバンドルとしてエクスポートします",
+	"Import %s profiles from a bundle":                                     "バンドルから %s のプロファイルをインポートします",
+	"Print a one-time download link for a %s profile":                      "%s のプロファイルの一回限りのダウンロードリンクを表示します",
+	"Inspect and maintain the %s profile store":                            "%s のプロファイルストアを確認・保守します",
+	"List %s profiles":                                                     "%s のプロファイルを一覧表示します",
+	"Manage %s configuration profiles":                                     "%s の設定プロファイルを管理します",
+	"Rename a %s profile":                                                  "%s のプロファイルの名前を変更します",
+	"Save current %s configuration as a profile":                           "現在の %s の設定をプロファイルとして保存します",
+	"Save unmanaged %s config as a profile and make it current":            "管理されていない %s の設定をプロファイルとして保存し、有効にします",
+	"Search stored %s profile files":                                       "保存済みの %s のプロファイルファイルを検索します",
+	"Print %s profile changes as they happen":                              "%s のプロファイルの変更を発生時に表示します",
+	"Show current %s profile":                                              "現在の %s のプロファイルを表示します",
+	"Check %s config files for unknown or invalid settings":                "%s の設定ファイルに未知の設定や不正な値がないか確認します",
+	"Check the %s store for broken profiles":                               "%s のストアに壊れたプロファイルがないか確認します",
+	"Check whether a %s profile is active":                                 "%s のプロファイルが有効かどうかを確認します",
+	"Show or set environment variables stored with a %s profile":           "%s のプロファイルに保存された環境変数を表示・設定します",
+	"Show or set tags on a %s profile":                                     "%s のプロファイルのタグを表示・設定します",
+	"Show or set annotations on a %s profile":                              "%s のプロファイルの注記を表示・設定します",
+	"End a time-boxed %s switch now":                                       "%s の時間制限付きの切り替えを今すぐ終了します",
+	"Return every tool to the profile it is meant to be on":                "すべてのツールを本来のプロファイルに戻します",
+	"Show which %s profiles match a live config file":                      "現在の設定ファイルと一致する %s のプロファイルを表示します",
+	"Switch %s to a profile":                                               "%s をプロファイルに切り替えます",
+	"Protect a %s profile from delete and overwrite":                       "%s のプロファイルを削除と上書きから保護します",
+	"Allow a locked %s profile to be deleted or overwritten":               "ロックされた %s のプロファイルの削除と上書きを許可します",
+}
+
+var japaneseUI = map[string]string{
+	"Profile Manager":  "プロファイルマネージャー",
+	"Theme":            "テーマ",
+	"System":           "システム",
+	"Dark":             "ダーク",
+	"Light":            "ライト",
+	"Language":         "言語",
+	"Automatic":        "自動",
+	"Current:":         "現在:",
+	"(modified)":       "(変更あり)",
+	"New profile name": "新しいプロファイル名",
+	"Save Current":     "現在の設定を保存",
+	"Profiles":         "プロファイル",
+	"Search ( / )":     "検索 ( / )",
+	"Search profiles":  "プロファイルを検索",
+	"Loading...":       "読み込み中...",
+	"Switching to {profile}: {done} of {total} files": "{profile} に切り替え中: {total} 件中 {done} 件",
+	"No profiles saved":             "保存されたプロファイルはありません",
+	"No profiles match \"{query}\"": "\"{query}\" に一致するプロファイルはありません",
+	"Switch":                        "切り替え",
+	"Edit":                          "編集",
+	"Delete":                        "削除",
+	"select":                        "選択",
+	"switch":                        "切り替え",
+	"search":                        "検索",
+	"Failed to load":                "読み込みに失敗しました",
+	"Failed to switch":              "切り替えに失敗しました",
+	"Failed to save":                "保存に失敗しました",
+	"Failed to delete":              "削除に失敗しました",
+	"Delete profile \"{profile}\"?": "プロファイル \"{profile}\" を削除しますか?",
+	"Discard local changes to {files} (not saved in \"{profile}\")?": "{files} への変更 (\"{profile}\" に保存されていません) を破棄しますか?",
+	"Discard local changes to {files}?":                              "{files} への変更を破棄しますか?",
+	"Edit {profile}":                                                 "{profile} を編集",
+	"Cancel":                                                         "キャンセル",
+	"Discard":                                                        "破棄",
+	"Close":                                                          "閉じる",
+	"Save":                                                           "保存",
+	"Unsaved changes":                                                "未保存の変更があります",
+	"Discard unsaved changes to {file}?":                             "{file} の未保存の変更を破棄しますか?",
+	"This profile stores no files":                                   "このプロファイルにはファイルがありません",
+	"Failed to load files":                                           "ファイルの読み込みに失敗しました",
+	"Invalid JSON":                                                   "JSON が不正です",
+	"Changes not saved in {profile}":                                 "{profile} に保存されていない変更",
+	"Accept all":                                                     "すべて取り込む",
+	"Revert all":                                                     "すべて元に戻す",
+	"Accept":                                                         "取り込む",
+	"Revert":                                                         "元に戻す",
+	"Unchanged":                                                      "変更なし",
+	"Modified":                                                       "変更あり",
+	"Deleted":                                                        "削除済み",
+	"Not in profile":                                                 "プロファイルにありません",
+	"Failed to compare files":                                        "ファイルの比較に失敗しました",
+	"Failed to resolve drift":                                        "変更の解決に失敗しました",
+	"Time active":                                                    "使用時間",
+	"{count} switches":                                               "{count} 回切り替え",
+}
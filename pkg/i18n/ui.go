@@ -0,0 +1,67 @@
+package i18n
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// uiCatalogs translate the web UI. Like the CLI catalogs they are keyed by
+// the English text; placeholders are written {name} and filled in by the UI.
+var uiCatalogs = map[string]map[string]string{
+	"ja": japaneseUI,
+}
+
+// UIMessages returns the web UI's catalog for lang. English, the language
+// the UI is written in, has an empty one.
+func UIMessages(lang string) map[string]string {
+	messages := make(map[string]string, len(uiCatalogs[lang]))
+	for msg, translated := range uiCatalogs[lang] {
+		messages[msg] = translated
+	}
+	return messages
+}
+
+// Negotiate returns the supported language that best matches an HTTP
+// Accept-Language header, or English when none does.
+func Negotiate(acceptLanguage string) string {
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if tag == "" || q <= 0 {
+			continue
+		}
+		ranges = append(ranges, weighted{tag, q})
+	}
+	slices.SortStableFunc(ranges, func(a, b weighted) int {
+		switch {
+		case a.q > b.q:
+			return -1
+		case a.q < b.q:
+			return 1
+		}
+		return 0
+	})
+	for _, r := range ranges {
+		if r.lang == "*" {
+			return English
+		}
+		lang, _, _ := strings.Cut(strings.ToLower(r.lang), "-")
+		if Supported(lang) {
+			return lang
+		}
+	}
+	return English
+}
@@ -0,0 +1,340 @@
+// Package manifest converges tokyo's profiles and active profiles to a state
+// declared in a YAML file, for use from configuration management.
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+
+	"tokyo/pkg/profile"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// Manifest is the desired state of one or more tools, by tool name.
+type Manifest struct {
+	Tools map[string]ToolSpec `yaml:"tools"`
+}
+
+// ToolSpec declares profiles of a tool and the profile that should be
+// active. Profiles not listed are left alone.
+type ToolSpec struct {
+	Active   string                 `yaml:"active,omitempty"`
+	Profiles map[string]ProfileSpec `yaml:"profiles,omitempty"`
+}
+
+// ProfileSpec declares the content of every config file of a profile, by
+// base name: settings.json for Claude Code; auth.json and config.toml for
+// Codex.
+type ProfileSpec struct {
+	Files map[string]FileSpec `yaml:"files"`
+}
+
+// FileSpec is the content of one config file: either inline, or the path of
+// a file to read, relative to the manifest. Load reads referenced files into
+// Content.
+type FileSpec struct {
+	Content *string `yaml:"content,omitempty"`
+	Path    string  `yaml:"path,omitempty"`
+}
+
+// Actions reported in a Change.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionSwitch = "switch"
+)
+
+// Change is one step needed to reach the declared state.
+type Change struct {
+	Tool    string
+	Profile string
+	Action  string
+	// From is the active profile a switch leaves, or "" when none is.
+	From string
+	// Drifted marks a switch back to the active profile because the live
+	// config no longer matches it.
+	Drifted bool
+}
+
+// Load reads and checks the manifest at path.
+func Load(path string) (Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	m, err := Parse(data)
+	if err != nil {
+		return Manifest{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	for _, spec := range m.Tools {
+		for _, p := range spec.Profiles {
+			for name, file := range p.Files {
+				if file.Content != nil {
+					continue
+				}
+				ref := file.Path
+				if !filepath.IsAbs(ref) {
+					ref = filepath.Join(dir, ref)
+				}
+				data, err := os.ReadFile(ref)
+				if err != nil {
+					return Manifest{}, fmt.Errorf("read %s: %w", ref, err)
+				}
+				content := string(data)
+				file.Content = &content
+				p.Files[name] = file
+			}
+		}
+	}
+	return m, nil
+}
+
+// Parse decodes and checks a manifest. File references are not read.
+func Parse(data []byte) (Manifest, error) {
+	var m Manifest
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&m); err != nil {
+		return Manifest{}, err
+	}
+	if err := m.validate(); err != nil {
+		return Manifest{}, err
+	}
+	return m, nil
+}
+
+func (m Manifest) validate() error {
+	if len(m.Tools) == 0 {
+		return errors.New("no tools declared")
+	}
+	for _, toolName := range sortedKeys(m.Tools) {
+		t, ok := profile.LookupTool(toolName)
+		if !ok {
+			return fmt.Errorf("unknown tool: %q", toolName)
+		}
+		spec := m.Tools[toolName]
+		if spec.Active != "" {
+			if err := profile.ValidateProfileName(spec.Active); err != nil {
+				return fmt.Errorf("%s: active: %w", toolName, err)
+			}
+		}
+
+		var want []string
+		for _, rel := range t.ConfigRelPaths {
+			want = append(want, filepath.Base(rel))
+		}
+		sort.Strings(want)
+		for _, name := range sortedKeys(spec.Profiles) {
+			if err := profile.ValidateProfileName(name); err != nil {
+				return fmt.Errorf("%s: %w", toolName, err)
+			}
+			files := spec.Profiles[name].Files
+			if got := sortedKeys(files); !slices.Equal(got, want) {
+				return fmt.Errorf("%s: profile %q must declare exactly %v, got %v", toolName, name, want, got)
+			}
+			for _, file := range sortedKeys(files) {
+				spec := files[file]
+				if (spec.Content == nil) == (spec.Path == "") {
+					return fmt.Errorf("%s: profile %q: %s needs either content or path", toolName, name, file)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Plan lists the changes Apply would make to reach m, for each tool in m,
+// looked up among tools so their store settings are used. A profile is
+// created or updated when its stored files differ from m; the declared
+// active profile is switched to when it is not active, was just changed, or
+// the live config has drifted from it.
+func Plan(tools []profile.Tool, m Manifest) ([]Change, error) {
+	var changes []Change
+	for _, toolName := range sortedKeys(m.Tools) {
+		t, err := findTool(tools, toolName)
+		if err != nil {
+			return nil, err
+		}
+		spec := m.Tools[toolName]
+
+		changed := map[string]bool{}
+		for _, name := range sortedKeys(spec.Profiles) {
+			exists, err := profile.Exists(t, name)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				changes = append(changes, Change{Tool: toolName, Profile: name, Action: ActionCreate})
+				changed[name] = true
+				continue
+			}
+			stored, err := profile.ReadFiles(t, name)
+			if err != nil {
+				return nil, err
+			}
+			if !maps.EqualFunc(stored, contents(spec.Profiles[name]), bytes.Equal) {
+				changes = append(changes, Change{Tool: toolName, Profile: name, Action: ActionUpdate})
+				changed[name] = true
+			}
+		}
+
+		if spec.Active == "" {
+			continue
+		}
+		c := Change{Tool: toolName, Profile: spec.Active, Action: ActionSwitch}
+		switchTo, err := needsSwitch(t, &c, changed[spec.Active])
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", toolName, err)
+		}
+		if switchTo {
+			changes = append(changes, c)
+		}
+	}
+	return changes, nil
+}
+
+// Apply makes the changes Plan lists and returns them. Profiles are written
+// first, then every tool is switched; if any step fails, the switched tools
+// get their previous live config and active profile back and the written
+// profiles their previous content, so the machine is left as it was.
+// Switching overwrites drift in the live config, which is autosaved first as
+// on every switch.
+func Apply(tools []profile.Tool, m Manifest) (changes []Change, err error) {
+	changes, err = Plan(tools, m)
+	if err != nil {
+		return nil, err
+	}
+
+	var undo []func() error
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(undo) - 1; i >= 0; i-- {
+			if undoErr := undo[i](); undoErr != nil {
+				err = errors.Join(err, fmt.Errorf("roll back: %w", undoErr))
+			}
+		}
+		changes = nil
+	}()
+
+	for _, c := range changes {
+		if c.Action == ActionSwitch {
+			continue
+		}
+		t, _ := findTool(tools, c.Tool)
+		var previous map[string][]byte
+		if c.Action == ActionUpdate {
+			if previous, err = profile.ReadFiles(t, c.Profile); err != nil {
+				return changes, err
+			}
+		}
+		if err = profile.SaveFiles(t, c.Profile, contents(m.Tools[c.Tool].Profiles[c.Profile]), true); err != nil {
+			return changes, fmt.Errorf("%s: %s %s: %w", c.Tool, c.Action, c.Profile, err)
+		}
+		undo = append(undo, func() error {
+			if previous == nil {
+				// Created by this apply, so no one has confirmed it yet.
+				_, err := profile.Delete(t.WithProtectedConfirmed(), c.Profile)
+				return err
+			}
+			return profile.SaveFiles(t, c.Profile, previous, true)
+		})
+	}
+
+	var snaps []*profile.Snapshot
+	for _, c := range changes {
+		if c.Action != ActionSwitch {
+			continue
+		}
+		t, _ := findTool(tools, c.Tool)
+		snap, snapErr := profile.TakeSnapshot(t)
+		if snapErr != nil {
+			return changes, snapErr
+		}
+		if err = profile.SwitchWithStrategy(t, c.Profile, profile.SwitchOverwrite); err != nil {
+			// A failed switch has already rolled itself back.
+			snap.Discard()
+			return changes, fmt.Errorf("%s: switch %s: %w", c.Tool, c.Profile, err)
+		}
+		undo = append(undo, snap.Restore)
+		snaps = append(snaps, snap)
+	}
+	for _, snap := range snaps {
+		snap.Discard()
+	}
+	return changes, nil
+}
+
+// needsSwitch reports whether c.Profile has to be switched to, filling in
+// c.From and c.Drifted.
+func needsSwitch(t profile.Tool, c *Change, changed bool) (bool, error) {
+	active, err := profile.ActiveProfile(t)
+	if err != nil {
+		return false, err
+	}
+	c.From = active
+	if changed {
+		return true, nil
+	}
+	exists, err := profile.Exists(t, c.Profile)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		return false, fmt.Errorf("active profile %q is neither declared nor stored", c.Profile)
+	}
+	if active != c.Profile {
+		return true, nil
+	}
+	diffs, err := profile.Diff(t, c.Profile)
+	if err != nil {
+		return false, err
+	}
+	for _, d := range diffs {
+		if d.Status == profile.FileModified || d.Status == profile.FileMissing {
+			c.Drifted = true
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// contents returns the declared content of p's files. Load has resolved
+// every file to Content.
+func contents(p ProfileSpec) map[string][]byte {
+	files := make(map[string][]byte, len(p.Files))
+	for name, file := range p.Files {
+		if file.Content != nil {
+			files[name] = []byte(*file.Content)
+		}
+	}
+	return files
+}
+
+func findTool(tools []profile.Tool, name string) (profile.Tool, error) {
+	for _, t := range tools {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return profile.Tool{}, fmt.Errorf("unknown tool: %q", name)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,187 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"tokyo/pkg/profile"
+)
+
+const testManifest = `tools:
+  claude:
+    active: work
+    profiles:
+      work:
+        files:
+          settings.json:
+            content: |
+              {"model": "opus"}
+      personal:
+        files:
+          settings.json:
+            path: personal.json
+`
+
+func writeManifest(t *testing.T, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "personal.json"), []byte("{\"model\": \"sonnet\"}\n"), 0o600); err != nil {
+		t.Fatalf("write personal.json: %v", err)
+	}
+	path := filepath.Join(dir, "manifest.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	return path
+}
+
+func TestApplyConverges(t *testing.T) {
+	home := t.TempDir()
+	tools := []profile.Tool{profile.ClaudeTool().WithHome(home)}
+	m, err := Load(writeManifest(t, testManifest))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	changes, err := Apply(tools, m)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	want := []Change{
+		{Tool: "claude", Profile: "personal", Action: ActionCreate},
+		{Tool: "claude", Profile: "work", Action: ActionCreate},
+		{Tool: "claude", Profile: "work", Action: ActionSwitch},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Fatalf("changes = %v, want %v", changes, want)
+	}
+	live := filepath.Join(home, ".claude", "settings.json")
+	data, err := os.ReadFile(live)
+	if err != nil {
+		t.Fatalf("read live settings: %v", err)
+	}
+	if string(data) != "{\"model\": \"opus\"}\n" {
+		t.Fatalf("live settings = %q", data)
+	}
+
+	changes, err = Apply(tools, m)
+	if err != nil {
+		t.Fatalf("Apply again: %v", err)
+	}
+	if len(changes) != 0 {
+		t.Fatalf("second Apply changed %v", changes)
+	}
+
+	// Drift in the live config is put back.
+	if err := os.WriteFile(live, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("write live settings: %v", err)
+	}
+	changes, err = Apply(tools, m)
+	if err != nil {
+		t.Fatalf("Apply after drift: %v", err)
+	}
+	if !reflect.DeepEqual(changes, []Change{{Tool: "claude", Profile: "work", Action: ActionSwitch, From: "work", Drifted: true}}) {
+		t.Fatalf("changes after drift = %v", changes)
+	}
+}
+
+func TestPlanUpdatesChangedProfile(t *testing.T) {
+	home := t.TempDir()
+	tool := profile.ClaudeTool().WithHome(home)
+	if err := profile.SaveFiles(tool, "work", map[string][]byte{"settings.json": []byte("{}\n")}, true); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	m, err := Load(writeManifest(t, testManifest))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	changes, err := Plan([]profile.Tool{tool}, m)
+	if err != nil {
+		t.Fatalf("Plan: %v", err)
+	}
+	if len(changes) != 3 || changes[1] != (Change{Tool: "claude", Profile: "work", Action: ActionUpdate}) {
+		t.Fatalf("unexpected plan: %v", changes)
+	}
+	if got, _ := profile.ReadFiles(tool, "work"); string(got["settings.json"]) != "{}\n" {
+		t.Fatalf("Plan changed the profile: %q", got)
+	}
+}
+
+func TestParseRejectsInvalidManifests(t *testing.T) {
+	for name, content := range map[string]string{
+		"empty":         "",
+		"unknown tool":  "tools:\n  vim: {}\n",
+		"unknown field": "tools:\n  claude:\n    actve: work\n",
+		"missing file":  "tools:\n  codex:\n    profiles:\n      work:\n        files:\n          auth.json: {content: '{}'}\n",
+		"both sources":  "tools:\n  claude:\n    profiles:\n      work:\n        files:\n          settings.json: {content: '{}', path: a.json}\n",
+		"bad name":      "tools:\n  claude:\n    active: ../x\n",
+	} {
+		if _, err := Parse([]byte(content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestApplyRejectsUnknownActiveProfile(t *testing.T) {
+	tools := []profile.Tool{profile.ClaudeTool().WithHome(t.TempDir())}
+	m, err := Parse([]byte("tools:\n  claude:\n    active: nope\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, err := Apply(tools, m); err == nil {
+		t.Fatal("expected error for an active profile that does not exist")
+	}
+}
+
+func TestApplyRollsBackOnFailure(t *testing.T) {
+	home := t.TempDir()
+	claude := profile.ClaudeTool().WithHome(home)
+	codex := profile.CodexTool().WithHome(home)
+	live := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(live), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(live, []byte("{}\n"), 0o600); err != nil {
+		t.Fatalf("write live settings: %v", err)
+	}
+	if err := profile.Adopt(claude, "old"); err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+	// Codex cannot be switched: a config file is a directory.
+	if err := profile.SaveFiles(codex, "work", map[string][]byte{"auth.json": []byte("{}"), "config.toml": nil}, true); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(home, ".codex", "config.toml"), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	m, err := Load(writeManifest(t, testManifest+"  codex:\n    active: work\n"))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	changes, err := Apply([]profile.Tool{claude, codex}, m)
+	if err == nil {
+		t.Fatal("expected Apply to fail")
+	}
+	if changes != nil {
+		t.Fatalf("changes after rollback = %v", changes)
+	}
+
+	data, err := os.ReadFile(live)
+	if err != nil {
+		t.Fatalf("read live settings: %v", err)
+	}
+	if string(data) != "{}\n" {
+		t.Fatalf("live settings = %q, want the original", data)
+	}
+	if active, err := profile.ActiveProfile(claude); err != nil || active != "old" {
+		t.Fatalf("active profile = %q, %v; want old", active, err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		if exists, err := profile.Exists(claude, name); err != nil || exists {
+			t.Fatalf("profile %s exists = %v, %v; want it removed", name, exists, err)
+		}
+	}
+}
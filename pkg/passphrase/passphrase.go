@@ -0,0 +1,153 @@
+// Package passphrase asks for passphrases the way pinentry does: from the
+// environment, from an askpass command, or on the terminal, so features that
+// need one work both interactively and in headless deployments such as
+// `tokyo serve`.
+package passphrase
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Env is the environment variable that supplies the passphrase without
+// prompting.
+const Env = "TOKYO_PASSPHRASE"
+
+// ErrUnavailable is returned when there is no way to get a passphrase: the
+// variable is unset, no askpass command is configured and there is no
+// terminal to prompt on.
+var ErrUnavailable = errors.New("no passphrase: set " + Env + ", configure askpass, or run in a terminal")
+
+// ErrMismatch is returned by ReadNew when the confirmation typed on the
+// terminal differs from the passphrase.
+var ErrMismatch = errors.New("passphrases do not match")
+
+// Source gets passphrases from, in order: the TOKYO_PASSPHRASE variable,
+// the Askpass command, and the terminal. The zero value uses the process
+// environment and the controlling terminal.
+type Source struct {
+	// Askpass is a shell command that prints the passphrase on stdout. The
+	// prompt is exported to it as TOKYO_PROMPT.
+	Askpass string
+	// Lookup reads environment variables; nil means os.LookupEnv.
+	Lookup func(string) (string, bool)
+	// Terminal prints prompt and reads a line without echoing it; nil
+	// means the controlling terminal. It returns ErrUnavailable when there
+	// is no terminal.
+	Terminal func(prompt string) (string, error)
+}
+
+// Read returns the passphrase for prompt, such as "Passphrase for the
+// claude store".
+func (s Source) Read(ctx context.Context, prompt string) (string, error) {
+	return s.read(ctx, prompt, false)
+}
+
+// ReadNew returns a new passphrase for prompt. On the terminal it is asked
+// for twice and both entries must match; the environment and the askpass
+// command are trusted to supply the intended value.
+func (s Source) ReadNew(ctx context.Context, prompt string) (string, error) {
+	return s.read(ctx, prompt, true)
+}
+
+func (s Source) read(ctx context.Context, prompt string, confirm bool) (string, error) {
+	lookup := s.Lookup
+	if lookup == nil {
+		lookup = os.LookupEnv
+	}
+	if value, ok := lookup(Env); ok && value != "" {
+		return value, nil
+	}
+	if s.Askpass != "" {
+		return runAskpass(ctx, s.Askpass, prompt)
+	}
+
+	terminal := s.Terminal
+	if terminal == nil {
+		terminal = readTerminal
+	}
+	value, err := terminal(prompt + ": ")
+	if err != nil {
+		return "", err
+	}
+	if value == "" {
+		return "", errors.New("empty passphrase")
+	}
+	if confirm {
+		again, err := terminal("Repeat " + lowerFirst(prompt) + ": ")
+		if err != nil {
+			return "", err
+		}
+		if again != value {
+			return "", ErrMismatch
+		}
+	}
+	return value, nil
+}
+
+// runAskpass runs command through the shell and returns the first line it
+// prints.
+func runAskpass(ctx context.Context, command, prompt string) (string, error) {
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd", "/C"
+	}
+	c := exec.CommandContext(ctx, shell, flag, command)
+	c.Env = append(os.Environ(), "TOKYO_PROMPT="+prompt)
+	c.Stderr = os.Stderr
+	out, err := c.Output()
+	if err != nil {
+		return "", fmt.Errorf("askpass: %w", err)
+	}
+	line, _, _ := bytes.Cut(out, []byte("\n"))
+	value := strings.TrimSuffix(string(line), "\r")
+	if value == "" {
+		return "", errors.New("askpass: empty passphrase")
+	}
+	return value, nil
+}
+
+// readTerminal prompts on the controlling terminal, which works even when
+// stdin and stdout are redirected.
+func readTerminal(prompt string) (string, error) {
+	tty, err := openTerminal()
+	if err != nil {
+		return "", ErrUnavailable
+	}
+	defer tty.Close()
+	if !term.IsTerminal(int(tty.Fd())) {
+		return "", ErrUnavailable
+	}
+	if _, err := io.WriteString(tty, prompt); err != nil {
+		return "", err
+	}
+	value, err := term.ReadPassword(int(tty.Fd()))
+	_, _ = io.WriteString(tty, "\n")
+	if err != nil {
+		return "", fmt.Errorf("read passphrase: %w", err)
+	}
+	return string(value), nil
+}
+
+func openTerminal() (*os.File, error) {
+	if runtime.GOOS == "windows" {
+		return os.OpenFile("CONIN$", os.O_RDWR, 0)
+	}
+	return os.OpenFile("/dev/tty", os.O_RDWR, 0)
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
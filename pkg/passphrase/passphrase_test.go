@@ -0,0 +1,94 @@
+package passphrase
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func noEnv(string) (string, bool) { return "", false }
+
+// fakeTerminal answers prompts in order and records them.
+func fakeTerminal(answers ...string) (func(string) (string, error), *[]string) {
+	var prompts []string
+	return func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		if len(answers) == 0 {
+			return "", ErrUnavailable
+		}
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	}, &prompts
+}
+
+func TestReadPrefersEnvironment(t *testing.T) {
+	terminal, prompts := fakeTerminal("typed")
+	s := Source{
+		Askpass:  "echo askpass",
+		Lookup:   func(name string) (string, bool) { return "from-env", name == Env },
+		Terminal: terminal,
+	}
+	got, err := s.Read(context.Background(), "Passphrase")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got != "from-env" || len(*prompts) != 0 {
+		t.Fatalf("got %q after prompts %q, want the environment value without prompting", got, *prompts)
+	}
+}
+
+func TestReadRunsAskpass(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("askpass test uses sh")
+	}
+	s := Source{Askpass: `printf 'secret\nignored\n'; test "$TOKYO_PROMPT" = "Passphrase"`, Lookup: noEnv}
+	got, err := s.Read(context.Background(), "Passphrase")
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if got != "secret" {
+		t.Fatalf("got %q, want secret", got)
+	}
+
+	s.Askpass = "exit 1"
+	if _, err := s.Read(context.Background(), "Passphrase"); err == nil {
+		t.Fatalf("expected a failing askpass command to be an error")
+	}
+}
+
+func TestReadNewConfirmsOnTerminal(t *testing.T) {
+	terminal, prompts := fakeTerminal("secret", "secret")
+	s := Source{Lookup: noEnv, Terminal: terminal}
+	got, err := s.ReadNew(context.Background(), "New passphrase")
+	if err != nil {
+		t.Fatalf("ReadNew: %v", err)
+	}
+	if got != "secret" {
+		t.Fatalf("got %q, want secret", got)
+	}
+	if want := []string{"New passphrase: ", "Repeat new passphrase: "}; len(*prompts) != 2 || (*prompts)[0] != want[0] || (*prompts)[1] != want[1] {
+		t.Fatalf("prompts = %q, want %q", *prompts, want)
+	}
+
+	terminal, _ = fakeTerminal("secret", "typo")
+	s.Terminal = terminal
+	if _, err := s.ReadNew(context.Background(), "New passphrase"); !errors.Is(err, ErrMismatch) {
+		t.Fatalf("expected ErrMismatch, got %v", err)
+	}
+}
+
+func TestReadWithoutAnySource(t *testing.T) {
+	terminal, _ := fakeTerminal()
+	s := Source{Lookup: noEnv, Terminal: terminal}
+	if _, err := s.Read(context.Background(), "Passphrase"); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("expected ErrUnavailable, got %v", err)
+	}
+
+	terminal, _ = fakeTerminal("")
+	s.Terminal = terminal
+	if _, err := s.Read(context.Background(), "Passphrase"); err == nil {
+		t.Fatalf("expected an empty passphrase to be rejected")
+	}
+}
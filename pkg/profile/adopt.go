@@ -0,0 +1,36 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Adopt saves the live config as a new profile and marks it current in one
+// step. It is meant for onboarding, so it only works while the live config
+// is unmanaged, i.e. while Current reports "<custom>".
+func Adopt(t Tool, profile string) error {
+	if err := ValidateProfileName(profile); err != nil {
+		return err
+	}
+
+	active, err := ActiveProfile(t)
+	if err != nil {
+		return err
+	}
+	if active != "" {
+		return newUserError(ErrAlreadyManaged, "live config is managed by profile %q (use save instead)", active)
+	}
+
+	if err := Save(t, profile, false); err != nil {
+		return err
+	}
+	if err := writeCurrentProfile(t, profile); err != nil {
+		profileDir, dirErr := t.profileDir(profile)
+		if dirErr == nil {
+			dirErr = os.RemoveAll(profileDir)
+		}
+		return errors.Join(fmt.Errorf("adopt failed: %w", err), dirErr)
+	}
+	return nil
+}
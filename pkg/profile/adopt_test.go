@@ -0,0 +1,40 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAdoptSavesAndActivates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"a"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := Adopt(tool, "work"); err != nil {
+		t.Fatalf("Adopt: %v", err)
+	}
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work" {
+		t.Fatalf("expected work, got %q", status)
+	}
+
+	if err := Adopt(tool, "other"); !errors.Is(err, ErrAlreadyManaged) {
+		t.Fatalf("expected ErrAlreadyManaged, got %v", err)
+	}
+	if exists, _ := Exists(tool, "other"); exists {
+		t.Fatalf("expected no profile to be saved when adopt is refused")
+	}
+}
@@ -0,0 +1,485 @@
+package profile
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	backupManifestName = "tokyo-backup.json"
+	backupVersion      = 1
+)
+
+// RestoreMode selects how Restore treats an existing store.
+type RestoreMode int
+
+const (
+	// RestoreMerge adds profiles and blobs missing locally and keeps
+	// everything that already exists, including the current profile.
+	RestoreMerge RestoreMode = iota
+	// RestoreReplace swaps each tool's store for the one in the backup.
+	RestoreReplace
+)
+
+type backupManifest struct {
+	Version int       `json:"version"`
+	Created time.Time `json:"created"`
+	Tools   []string  `json:"tools"`
+	// Partial marks an archive of selected profiles, which cannot replace
+	// a store.
+	Partial bool `json:"partial,omitempty"`
+}
+
+// RestoreResult lists what Restore did, as "tool/profile" entries.
+type RestoreResult struct {
+	Restored []string
+	Skipped  []string
+}
+
+// Backup writes the complete store of every tool — profiles, metadata,
+// blobs, store settings and the current profile — to w as a gzip-compressed
+// tar archive that Restore understands. Unlike Export it copies the store
+// as it is laid out on disk.
+func Backup(tools []Tool, w io.Writer) error {
+	return backup(tools, nil, w)
+}
+
+// BackupProfiles is Backup limited to the profiles selection names for each
+// tool, for carrying a few profiles rather than whole stores. The profiles
+// they are based on and the blobs they reference come along; the current
+// profile and other profiles do not. Tools missing from selection are left
+// out, and Restore only merges the archive.
+func BackupProfiles(tools []Tool, selection map[string][]string, w io.Writer) error {
+	var selected []Tool
+	filters := map[string]func(rel string) bool{}
+	for _, t := range tools {
+		profiles := selection[t.Name]
+		if len(profiles) == 0 {
+			continue
+		}
+		include, err := selectedStoreFiles(t, profiles)
+		if err != nil {
+			return err
+		}
+		selected = append(selected, t)
+		filters[t.Name] = include
+	}
+	return backup(selected, filters, w)
+}
+
+// selectedStoreFiles returns whether a file, given by its slash-separated
+// path in t's store, belongs to profiles or is needed to read them.
+func selectedStoreFiles(t Tool, profiles []string) (func(rel string) bool, error) {
+	keep := map[string]bool{}
+	blobs := map[string]bool{}
+	for _, p := range profiles {
+		for p != "" && !keep[p] {
+			if err := requireProfile(t, p); err != nil {
+				return nil, err
+			}
+			if system, err := IsSystemProfile(t, p); err != nil || system {
+				return nil, errors.Join(err, fmt.Errorf("profile %q is in the system store, not yours", p))
+			}
+			keep[p] = true
+			m, err := readManifest(t, p)
+			if err != nil {
+				return nil, err
+			}
+			for _, hash := range m.Files {
+				blobs[hash] = true
+			}
+			meta, err := readMetaFile(t, p)
+			if err != nil {
+				return nil, err
+			}
+			p = meta.Base
+		}
+	}
+	return func(rel string) bool {
+		dir, rest, _ := strings.Cut(rel, "/")
+		switch dir {
+		case "store.json":
+			return true
+		case "profiles":
+			name, _, _ := strings.Cut(rest, "/")
+			return keep[name]
+		case "blobs":
+			return blobs[strings.TrimSuffix(rest, gzipSuffix)]
+		}
+		return false
+	}, nil
+}
+
+// backup writes the archive of Backup. With filters, only the files of each
+// tool's store that its filter accepts are written and the archive is
+// marked partial.
+func backup(tools []Tool, filters map[string]func(rel string) bool, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest := backupManifest{Version: backupVersion, Created: time.Now().UTC(), Partial: filters != nil}
+	for _, t := range tools {
+		manifest.Tools = append(manifest.Tools, t.Name)
+	}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, backupManifestName, data); err != nil {
+		return err
+	}
+
+	for _, t := range tools {
+		root, err := t.tokyoDir()
+		if err != nil {
+			return err
+		}
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) && p == root {
+					return filepath.SkipDir
+				}
+				return err
+			}
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			if rel == "." {
+				return nil
+			}
+			if skipInBackup(d.Name()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			if include := filters[t.Name]; include != nil && !include(filepath.ToSlash(rel)) {
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return newUserError(ErrExpectedRegularFile, "expected regular file: %s", p)
+			}
+			data, err := os.ReadFile(p)
+			if err != nil {
+				return err
+			}
+			return writeTarFile(tw, path.Join(t.Name, filepath.ToSlash(rel)), data)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// skipInBackup reports whether a store entry is scratch space: rollback
+// directories left by an interrupted switch and hidden temp files.
+func skipInBackup(name string) bool {
+	return strings.HasPrefix(name, "rollback-") || strings.HasPrefix(name, ".")
+}
+
+// Restore reads an archive produced by Backup into the stores of tools. Every
+// tool in the archive must be among tools.
+func Restore(tools []Tool, r io.Reader, mode RestoreMode) (RestoreResult, error) {
+	var result RestoreResult
+
+	byName := map[string]Tool{}
+	for _, t := range tools {
+		byName[t.Name] = t
+	}
+
+	staging := map[string]string{}
+	defer func() {
+		for _, dir := range staging {
+			os.RemoveAll(filepath.Dir(dir))
+		}
+	}()
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return result, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	defer gz.Close()
+
+	var manifest backupManifest
+	tr := tar.NewReader(gz)
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return result, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return result, fmt.Errorf("%w: unsupported entry %q", ErrInvalidBundle, hdr.Name)
+		}
+
+		// The manifest comes first so the version is checked before any
+		// file is written.
+		if i == 0 {
+			if hdr.Name != backupManifestName {
+				return result, fmt.Errorf("%w: missing %s", ErrInvalidBundle, backupManifestName)
+			}
+			if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+				return result, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+			}
+			if manifest.Version > backupVersion {
+				return result, fmt.Errorf("%w: backup version %d is newer than this tokyo supports (%d)", ErrInvalidBundle, manifest.Version, backupVersion)
+			}
+			if manifest.Version < 1 {
+				return result, fmt.Errorf("%w: unsupported backup version %d", ErrInvalidBundle, manifest.Version)
+			}
+			for _, name := range manifest.Tools {
+				if _, ok := byName[name]; !ok {
+					return result, fmt.Errorf("%w: unknown tool %q", ErrInvalidBundle, name)
+				}
+			}
+			if manifest.Partial && mode == RestoreReplace {
+				return result, errors.New("this archive holds selected profiles, not whole stores, and can only be merged")
+			}
+			continue
+		}
+
+		toolName, rel, ok := strings.Cut(hdr.Name, "/")
+		if !ok || path.Clean(hdr.Name) != hdr.Name || rel == "" || strings.HasPrefix(rel, "../") || path.IsAbs(hdr.Name) {
+			return result, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBundle, hdr.Name)
+		}
+		if !slices.Contains(manifest.Tools, toolName) {
+			return result, fmt.Errorf("%w: tool %q not listed in manifest", ErrInvalidBundle, toolName)
+		}
+
+		dir, ok := staging[toolName]
+		if !ok {
+			if dir, err = stageRestore(byName[toolName]); err != nil {
+				return result, err
+			}
+			staging[toolName] = dir
+		}
+		dst := filepath.Join(dir, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+			return result, err
+		}
+		f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return result, err
+		}
+		if _, err := io.Copy(f, tr); err != nil {
+			f.Close()
+			return result, fmt.Errorf("extract %s: %w", hdr.Name, err)
+		}
+		if err := f.Close(); err != nil {
+			return result, err
+		}
+	}
+	if manifest.Version == 0 {
+		return result, fmt.Errorf("%w: missing %s", ErrInvalidBundle, backupManifestName)
+	}
+
+	for _, name := range manifest.Tools {
+		t := byName[name]
+		dir, ok := staging[name]
+		if !ok {
+			// The tool had no store when the backup was taken.
+			if dir, err = stageRestore(t); err != nil {
+				return result, err
+			}
+			staging[name] = dir
+		}
+
+		var restored, skipped []string
+		if mode == RestoreReplace {
+			restored, err = replaceStore(t, dir)
+		} else {
+			restored, skipped, err = mergeStore(t, dir)
+		}
+		if err != nil {
+			return result, fmt.Errorf("restore %s: %w", name, err)
+		}
+		for _, p := range restored {
+			result.Restored = append(result.Restored, name+"/"+p)
+		}
+		for _, p := range skipped {
+			result.Skipped = append(result.Skipped, name+"/"+p)
+		}
+	}
+	return result, nil
+}
+
+// stageRestore creates an empty staging store next to t's store, so it can
+// be renamed into place.
+func stageRestore(t Tool) (string, error) {
+	root, err := t.tokyoDir()
+	if err != nil {
+		return "", err
+	}
+	parent := filepath.Dir(root)
+	if err := os.MkdirAll(parent, 0o700); err != nil {
+		return "", err
+	}
+	tmp, err := os.MkdirTemp(parent, ".restore-"+t.Name+"-")
+	if err != nil {
+		return "", err
+	}
+	dir := filepath.Join(tmp, t.Name)
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		os.RemoveAll(tmp)
+		return "", err
+	}
+	return dir, nil
+}
+
+func stagedProfiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(dir, "profiles"))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var profiles []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if err := ValidateProfileName(entry.Name()); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		profiles = append(profiles, entry.Name())
+	}
+	sort.Strings(profiles)
+	return profiles, nil
+}
+
+// replaceStore swaps t's store for the staged one. Locked profiles block the
+// swap because it would delete them.
+func replaceStore(t Tool, staged string) ([]string, error) {
+	restored, err := stagedProfiles(staged)
+	if err != nil {
+		return nil, err
+	}
+	local, err := listUserProfiles(t)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range local {
+		if err := checkUnlocked(t, p); err != nil {
+			return nil, err
+		}
+	}
+
+	root, err := t.tokyoDir()
+	if err != nil {
+		return nil, err
+	}
+	old := filepath.Join(filepath.Dir(staged), "old")
+	if err := os.Rename(root, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err := os.Rename(staged, root); err != nil {
+		if restoreErr := os.Rename(old, root); restoreErr != nil && !errors.Is(restoreErr, fs.ErrNotExist) {
+			return nil, errors.Join(err, restoreErr)
+		}
+		return nil, err
+	}
+	return restored, nil
+}
+
+// mergeStore moves staged profiles and blobs that do not exist locally into
+// t's store. The current profile and store settings are only taken from the
+// backup when the local store has none.
+func mergeStore(t Tool, staged string) (restored, skipped []string, err error) {
+	profiles, err := stagedProfiles(staged)
+	if err != nil {
+		return nil, nil, err
+	}
+	profilesDir, err := t.profilesDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := os.MkdirAll(profilesDir, 0o700); err != nil {
+		return nil, nil, err
+	}
+	for _, p := range profiles {
+		// A profile from the system store does not keep the user's own
+		// copy from being restored.
+		exists, err := dirExists(filepath.Join(profilesDir, p))
+		if err != nil {
+			return nil, nil, err
+		}
+		if exists {
+			skipped = append(skipped, p)
+			continue
+		}
+		if err := os.Rename(filepath.Join(staged, "profiles", p), filepath.Join(profilesDir, p)); err != nil {
+			return nil, nil, err
+		}
+		restored = append(restored, p)
+	}
+
+	blobs, err := os.ReadDir(filepath.Join(staged, "blobs"))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, nil, err
+	}
+	if len(blobs) > 0 {
+		blobsDir, err := t.blobsDir()
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := os.MkdirAll(blobsDir, 0o700); err != nil {
+			return nil, nil, err
+		}
+		for _, blob := range blobs {
+			dst := filepath.Join(blobsDir, blob.Name())
+			if _, err := os.Lstat(dst); err == nil {
+				continue
+			}
+			if err := os.Rename(filepath.Join(staged, "blobs", blob.Name()), dst); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	root, err := t.tokyoDir()
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, name := range []string{"store.json", "current.json"} {
+		src := filepath.Join(staged, name)
+		dst := filepath.Join(root, name)
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		if name == "current.json" {
+			var state currentState
+			data, err := os.ReadFile(src)
+			if err != nil || json.Unmarshal(data, &state) != nil || !slices.Contains(restored, state.Profile) {
+				continue
+			}
+		}
+		if err := os.Rename(src, dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, nil, err
+		}
+	}
+	return restored, skipped, nil
+}
@@ -0,0 +1,177 @@
+package profile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBackupRestoreMergeAndReplace(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"a"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+	if err := Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+	if err := UpdateMeta(tool, "work", func(m *Meta) error { m.Tags = map[string]string{"team": "ai"}; return nil }); err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := Backup(Tools(), &archive); err != nil {
+		t.Fatalf("Backup: %v", err)
+	}
+
+	if _, err := Delete(tool, "work"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"b"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "personal", true); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+	if err := Save(tool, "scratch", false); err != nil {
+		t.Fatalf("Save scratch: %v", err)
+	}
+
+	result, err := Restore(Tools(), bytes.NewReader(archive.Bytes()), RestoreMerge)
+	if err != nil {
+		t.Fatalf("Restore merge: %v", err)
+	}
+	if !reflect.DeepEqual(result.Restored, []string{"claude/work"}) || !reflect.DeepEqual(result.Skipped, []string{"claude/personal"}) {
+		t.Fatalf("unexpected merge result: %+v", result)
+	}
+	meta, err := ReadMeta(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadMeta: %v", err)
+	}
+	if meta.Tags["team"] != "ai" {
+		t.Fatalf("expected metadata to be restored, got %+v", meta)
+	}
+	profiles, err := List(tool)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !reflect.DeepEqual(profiles, []string{"personal", "scratch", "work"}) {
+		t.Fatalf("unexpected profiles after merge: %v", profiles)
+	}
+
+	if _, err := Restore(Tools(), bytes.NewReader(archive.Bytes()), RestoreReplace); err != nil {
+		t.Fatalf("Restore replace: %v", err)
+	}
+	profiles, err = List(tool)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !reflect.DeepEqual(profiles, []string{"personal", "work"}) {
+		t.Fatalf("unexpected profiles after replace: %v", profiles)
+	}
+	current, err := readCurrentProfile(tool)
+	if err != nil {
+		t.Fatalf("readCurrentProfile: %v", err)
+	}
+	if current != "work" {
+		t.Fatalf("expected current profile work, got %q", current)
+	}
+	leftovers, err := filepath.Glob(filepath.Join(home, ".config", "tokyo", ".restore-*"))
+	if err != nil || len(leftovers) != 0 {
+		t.Fatalf("expected staging dirs to be cleaned up, got %v %v", leftovers, err)
+	}
+	if ok, err := sameAsProfileFile(tool, "personal", writeTemp(t, `{"model":"a"}`)); err != nil || !ok {
+		t.Fatalf("expected personal to hold the backed-up content, got %v %v", ok, err)
+	}
+}
+
+func TestRestoreRejectsNewerVersion(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	var archive bytes.Buffer
+	gz := gzip.NewWriter(&archive)
+	tw := tar.NewWriter(gz)
+	if err := writeTarFile(tw, backupManifestName, []byte(`{"version":99,"tools":["claude"]}`)); err != nil {
+		t.Fatalf("writeTarFile: %v", err)
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+
+	if _, err := Restore(Tools(), &archive, RestoreReplace); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("expected ErrInvalidBundle, got %v", err)
+	}
+}
+
+func writeTemp(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "settings.json")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write temp: %v", err)
+	}
+	return path
+}
+
+func TestBackupProfilesCarriesSelectionAndBases(t *testing.T) {
+	tool, _ := setupCodexProfiles(t)
+	if err := SaveFrom(tool, "child", "work", false); err != nil {
+		t.Fatalf("SaveFrom: %v", err)
+	}
+
+	var archive bytes.Buffer
+	if err := BackupProfiles([]Tool{tool, ClaudeTool().WithHome(t.TempDir())}, map[string][]string{"codex": {"child"}}, &archive); err != nil {
+		t.Fatalf("BackupProfiles: %v", err)
+	}
+
+	target := CodexTool().WithHome(t.TempDir())
+	if _, err := Restore([]Tool{target}, bytes.NewReader(archive.Bytes()), RestoreReplace); err == nil {
+		t.Fatal("expected a partial archive to be refused for replace")
+	}
+	result, err := Restore([]Tool{target}, bytes.NewReader(archive.Bytes()), RestoreMerge)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if !reflect.DeepEqual(result.Restored, []string{"codex/child", "codex/work"}) {
+		t.Fatalf("restored %v, want child and its base work", result.Restored)
+	}
+	if current, err := readCurrentProfile(target); err != nil || current != "" {
+		t.Fatalf("the current profile should not travel with a selection, got %q %v", current, err)
+	}
+	want, err := ReadFiles(tool, "child")
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	got, err := ReadFiles(target, "child")
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("restored child = %q, want %q", got, want)
+	}
+
+	if err := BackupProfiles([]Tool{tool}, map[string][]string{"codex": {"missing"}}, io.Discard); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+}
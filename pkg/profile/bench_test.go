@@ -0,0 +1,116 @@
+package profile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// benchCases are the store shapes every benchmark runs against: a tool with
+// 1, 10 or 100 config files of 1 KiB or 64 KiB each.
+var benchCases = []struct {
+	files int
+	size  int
+}{
+	{1, 1 << 10}, {1, 64 << 10},
+	{10, 1 << 10}, {10, 64 << 10},
+	{100, 1 << 10}, {100, 64 << 10},
+}
+
+func benchName(files, size int) string {
+	return fmt.Sprintf("files=%d/size=%s", files, strings.ReplaceAll(FormatSize(int64(size)), " ", ""))
+}
+
+// benchTool returns a tool with files config files under a fresh home, each
+// holding size copies of fill.
+func benchTool(b *testing.B, files, size int, fill byte) Tool {
+	b.Helper()
+	tool := Tool{Name: "bench", DisplayName: "Bench", Home: b.TempDir()}
+	for i := range files {
+		tool.ConfigRelPaths = append(tool.ConfigRelPaths, filepath.Join(".bench", fmt.Sprintf("file%03d.json", i)))
+	}
+	writeBenchFiles(b, tool, size, fill)
+	return tool
+}
+
+func writeBenchFiles(b *testing.B, tool Tool, size int, fill byte) {
+	b.Helper()
+	paths, err := tool.configFiles()
+	if err != nil {
+		b.Fatalf("configFiles: %v", err)
+	}
+	data := bytes.Repeat([]byte{fill}, size)
+	for _, path := range paths {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			b.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			b.Fatalf("write %s: %v", path, err)
+		}
+	}
+}
+
+func BenchmarkSave(b *testing.B) {
+	for _, c := range benchCases {
+		b.Run(benchName(c.files, c.size), func(b *testing.B) {
+			tool := benchTool(b, c.files, c.size, 'a')
+			b.SetBytes(int64(c.files * c.size))
+			for b.Loop() {
+				if err := Save(tool, "work", true); err != nil {
+					b.Fatalf("Save: %v", err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSwitch(b *testing.B) {
+	for _, c := range benchCases {
+		b.Run(benchName(c.files, c.size), func(b *testing.B) {
+			tool := benchTool(b, c.files, c.size, 'a')
+			if err := Save(tool, "work", false); err != nil {
+				b.Fatalf("Save work: %v", err)
+			}
+			writeBenchFiles(b, tool, c.size, 'b')
+			if err := Save(tool, "personal", false); err != nil {
+				b.Fatalf("Save personal: %v", err)
+			}
+			b.SetBytes(int64(c.files * c.size))
+			profiles := []string{"work", "personal"}
+			i := 0
+			for b.Loop() {
+				if err := Switch(tool, profiles[i%2]); err != nil {
+					b.Fatalf("Switch: %v", err)
+				}
+				i++
+			}
+		})
+	}
+}
+
+func BenchmarkCurrent(b *testing.B) {
+	for _, c := range benchCases {
+		b.Run(benchName(c.files, c.size), func(b *testing.B) {
+			tool := benchTool(b, c.files, c.size, 'a')
+			if err := Save(tool, "work", false); err != nil {
+				b.Fatalf("Save: %v", err)
+			}
+			if err := Switch(tool, "work"); err != nil {
+				b.Fatalf("Switch: %v", err)
+			}
+			b.SetBytes(int64(c.files * c.size))
+			for b.Loop() {
+				current, err := Current(tool)
+				if err != nil {
+					b.Fatalf("Current: %v", err)
+				}
+				if current != "work" {
+					b.Fatalf("Current = %q, want work", current)
+				}
+			}
+		})
+	}
+}
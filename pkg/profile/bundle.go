@@ -0,0 +1,351 @@
+package profile
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"path"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"time"
+)
+
+const (
+	bundleManifestName = "tokyo-bundle.json"
+	bundleVersion      = 1
+
+	// maxBundleEntries and maxBundleSize bound what readBundle unpacks, as
+	// a small gzip stream can expand to far more than fits in memory.
+	maxBundleEntries       = 10000
+	maxBundleSize    int64 = 256 << 20
+)
+
+// ErrInvalidBundle reports a bundle that cannot be imported.
+var ErrInvalidBundle = errors.New("invalid bundle")
+
+type bundleManifest struct {
+	Version  int      `json:"version"`
+	Tool     string   `json:"tool"`
+	Profiles []string `json:"profiles"`
+}
+
+// Match returns the profiles whose names match a shell glob such as "tmp-*".
+func Match(t Tool, pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+	}
+	profiles, err := List(t)
+	if err != nil {
+		return nil, err
+	}
+	matched := []string{}
+	for _, p := range profiles {
+		if ok, _ := path.Match(pattern, p); ok {
+			matched = append(matched, p)
+		}
+	}
+	return matched, nil
+}
+
+// Export writes the given profiles, including their metadata, to w as a
+// gzip-compressed tar bundle that Import understands.
+func Export(t Tool, profiles []string, w io.Writer) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+
+	manifest := bundleManifest{Version: bundleVersion, Tool: t.Name, Profiles: profiles}
+	data, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	if err := writeTarFile(tw, bundleManifestName, data); err != nil {
+		return err
+	}
+
+	for _, p := range profiles {
+		if err := requireProfile(t, p); err != nil {
+			return err
+		}
+
+		// Bundles are self-contained: files inherited from a base profile
+		// are written out and the base reference is dropped.
+		pairs, err := profilePairs(t, p)
+		if err != nil {
+			return err
+		}
+		for _, pair := range pairs {
+			name := filepath.Base(pair.dst)
+			if err := ensureRegularFile(pair.src); err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return newUserError(ErrProfileMissingFile, "profile %q is missing file: %s", p, name)
+				}
+				return err
+			}
+			data, err := readStored(pair.src)
+			if err != nil {
+				return err
+			}
+			if err := writeTarFile(tw, path.Join("profiles", p, name), data); err != nil {
+				return err
+			}
+		}
+
+		meta, err := readMetaFile(t, p)
+		if err != nil {
+			return err
+		}
+		meta.Base = ""
+		if !reflect.DeepEqual(meta, Meta{}) {
+			data, err := json.Marshal(meta)
+			if err != nil {
+				return err
+			}
+			if err := writeTarFile(tw, path.Join("profiles", p, metaFileName), data); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Import reads a bundle produced by Export and stores its profiles. Existing
+// profiles are only replaced when force is set. It returns the imported
+// profile names.
+func Import(t Tool, r io.Reader, force bool) ([]string, error) {
+	limit, ok := t.FileSizeLimit()
+	if !ok {
+		limit = -1
+	}
+	manifest, files, err := readBundle(r, limit)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Tool != t.Name {
+		return nil, fmt.Errorf("%w: bundle is for %q, not %q", ErrInvalidBundle, manifest.Tool, t.Name)
+	}
+
+	allowed := map[string]bool{}
+	for _, name := range storedFileNames(t) {
+		allowed[name] = true
+	}
+	for _, p := range manifest.Profiles {
+		if err := ValidateProfileName(p); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		for name := range files[p] {
+			if !allowed[name] {
+				return nil, fmt.Errorf("%w: unexpected file %q in profile %q", ErrInvalidBundle, name, p)
+			}
+		}
+		for _, rel := range t.ConfigRelPaths {
+			if _, ok := files[p][filepath.Base(rel)]; !ok {
+				return nil, fmt.Errorf("%w: profile %q is missing file: %s", ErrInvalidBundle, p, filepath.Base(rel))
+			}
+		}
+	}
+
+	selected := make(map[string]map[string][]byte, len(manifest.Profiles))
+	for _, p := range manifest.Profiles {
+		selected[p] = files[p]
+	}
+	return writeProfiles(t, selected, force)
+}
+
+// writeProfiles saves each entry of files, a profile name mapped to its file
+// contents by name, as a profile of t the way SaveFiles does. A meta.json
+// among the files becomes the profile's metadata, without a base. Locked
+// profiles and profiles others are based on are never replaced, and existing
+// ones only when force is set. Every profile is checked before the first is
+// saved; a failure while saving one leaves those saved before it in place.
+// It returns the profile names, sorted.
+func writeProfiles(t Tool, files map[string]map[string][]byte, force bool) ([]string, error) {
+	names := make([]string, 0, len(files))
+	for p := range files {
+		names = append(names, p)
+	}
+	sort.Strings(names)
+
+	configs := make(map[string]map[string][]byte, len(names))
+	metas := make(map[string]*Meta, len(names))
+	for _, p := range names {
+		if err := ValidateProfileName(p); err != nil {
+			return nil, err
+		}
+		if err := checkCaseCollision(t, p, ""); err != nil {
+			return nil, err
+		}
+		exists, err := Exists(t, p)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			if !force {
+				return nil, newUserError(ErrProfileAlreadyExists, "profile %q already exists (use --force to overwrite)", p)
+			}
+			if err := checkUnlocked(t, p); err != nil {
+				return nil, err
+			}
+			if err := checkNoDependents(t, p); err != nil {
+				return nil, err
+			}
+		}
+
+		config := maps.Clone(files[p])
+		delete(config, metaFileName)
+		if err := checkFileContents(t, config); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", p, err)
+		}
+		meta := &Meta{}
+		if data, ok := files[p][metaFileName]; ok {
+			if err := json.Unmarshal(data, meta); err != nil {
+				return nil, fmt.Errorf("%w: parse %s of profile %q: %v", ErrInvalidBundle, metaFileName, p, err)
+			}
+			meta.Base = ""
+		}
+		configs[p], metas[p] = config, meta
+	}
+
+	for _, p := range names {
+		if err := saveFiles(t, p, "", configs[p], metas[p], force); err != nil {
+			return nil, err
+		}
+	}
+	return names, nil
+}
+
+// BundleInfo describes what a bundle holds.
+type BundleInfo struct {
+	Tool     string   `json:"tool"`
+	Profiles []string `json:"profiles"`
+}
+
+// ReadBundleInfo checks that r holds a bundle and returns its tool and
+// profiles. Files over DefaultMaxFileSize are refused.
+func ReadBundleInfo(r io.Reader) (BundleInfo, error) {
+	manifest, _, err := readBundle(r, DefaultMaxFileSize)
+	if err != nil {
+		return BundleInfo{}, err
+	}
+	return BundleInfo{Tool: manifest.Tool, Profiles: manifest.Profiles}, nil
+}
+
+// readBundle unpacks the bundle in r. Entries over entryLimit bytes, when it
+// is not negative, and bundles over maxBundleEntries or maxBundleSize
+// uncompressed are refused with ErrInvalidBundle.
+func readBundle(r io.Reader, entryLimit int64) (bundleManifest, map[string]map[string][]byte, error) {
+	var manifest bundleManifest
+	files := map[string]map[string][]byte{}
+
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return manifest, nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+	}
+	defer gz.Close()
+
+	seenManifest := false
+	entries, total := 0, int64(0)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return manifest, nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return manifest, nil, fmt.Errorf("%w: unsupported entry %q", ErrInvalidBundle, hdr.Name)
+		}
+		if entries++; entries > maxBundleEntries {
+			return manifest, nil, fmt.Errorf("%w: more than %d entries", ErrInvalidBundle, maxBundleEntries)
+		}
+		if entryLimit >= 0 && hdr.Size > entryLimit {
+			return manifest, nil, fmt.Errorf("%w: %s is over the %s limit for config files", ErrInvalidBundle, hdr.Name, FormatSize(entryLimit))
+		}
+		if total+hdr.Size > maxBundleSize {
+			return manifest, nil, fmt.Errorf("%w: over %s uncompressed", ErrInvalidBundle, FormatSize(maxBundleSize))
+		}
+		// The header's size is checked; the reader is limited to it as well.
+		data, err := io.ReadAll(io.LimitReader(tr, hdr.Size))
+		if err != nil {
+			return manifest, nil, fmt.Errorf("%w: read %s: %v", ErrInvalidBundle, hdr.Name, err)
+		}
+		total += int64(len(data))
+
+		if hdr.Name == bundleManifestName {
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				return manifest, nil, fmt.Errorf("%w: %v", ErrInvalidBundle, err)
+			}
+			seenManifest = true
+			continue
+		}
+
+		dir, name := path.Split(hdr.Name)
+		profileName := path.Base(dir)
+		if path.Clean(hdr.Name) != hdr.Name || path.Dir(path.Dir(hdr.Name)) != "profiles" {
+			return manifest, nil, fmt.Errorf("%w: unexpected entry %q", ErrInvalidBundle, hdr.Name)
+		}
+		if files[profileName] == nil {
+			files[profileName] = map[string][]byte{}
+		}
+		files[profileName][name] = data
+	}
+
+	if !seenManifest {
+		return manifest, nil, fmt.Errorf("%w: missing %s", ErrInvalidBundle, bundleManifestName)
+	}
+	if manifest.Version != bundleVersion {
+		return manifest, nil, fmt.Errorf("%w: unsupported bundle version %d", ErrInvalidBundle, manifest.Version)
+	}
+	listed := map[string]bool{}
+	for _, p := range manifest.Profiles {
+		listed[p] = true
+	}
+	for p := range files {
+		if !listed[p] {
+			return manifest, nil, fmt.Errorf("%w: profile %q not listed in manifest", ErrInvalidBundle, p)
+		}
+	}
+	return manifest, files, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte) error {
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    0o600,
+		Size:    int64(len(data)),
+		ModTime: time.Now(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
+
+// storedFileNames lists the file names a profile directory may contain.
+func storedFileNames(t Tool) []string {
+	names := make([]string, 0, len(t.ConfigRelPaths)+1)
+	for _, rel := range t.ConfigRelPaths {
+		names = append(names, filepath.Base(rel))
+	}
+	return append(names, metaFileName)
+}
+
+func (t Tool) profileFile(profile, name string) (string, error) {
+	profileDir, err := t.profileDir(profile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(profileDir, name), nil
+}
@@ -0,0 +1,187 @@
+package profile
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"x":1}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"tmp-1", "tmp-2", "work"} {
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	err := UpdateMeta(tool, "tmp-1", func(m *Meta) error {
+		m.Tags = map[string]string{"team": "ai"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+
+	matched, err := Match(tool, "tmp-*")
+	if err != nil {
+		t.Fatalf("Match: %v", err)
+	}
+	if strings.Join(matched, ",") != "tmp-1,tmp-2" {
+		t.Fatalf("expected [tmp-1 tmp-2], got %v", matched)
+	}
+
+	var buf bytes.Buffer
+	if err := Export(tool, matched, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	otherHome := t.TempDir()
+	t.Setenv("HOME", otherHome)
+
+	imported, err := Import(tool, bytes.NewReader(buf.Bytes()), false)
+	if err != nil {
+		t.Fatalf("Import: %v", err)
+	}
+	if strings.Join(imported, ",") != "tmp-1,tmp-2" {
+		t.Fatalf("expected [tmp-1 tmp-2] imported, got %v", imported)
+	}
+	meta, err := ReadMeta(tool, "tmp-1")
+	if err != nil {
+		t.Fatalf("ReadMeta: %v", err)
+	}
+	if meta.Tags["team"] != "ai" {
+		t.Fatalf("expected tags imported, got %+v", meta)
+	}
+
+	if _, err := Import(tool, bytes.NewReader(buf.Bytes()), false); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("expected ErrProfileAlreadyExists, got %v", err)
+	}
+	if _, err := Import(tool, bytes.NewReader(buf.Bytes()), true); err != nil {
+		t.Fatalf("Import --force: %v", err)
+	}
+	if _, err := Import(CodexTool(), bytes.NewReader(buf.Bytes()), false); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("expected ErrInvalidBundle for wrong tool, got %v", err)
+	}
+}
+
+func TestImportRejectsPathTraversal(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	if err := writeTarFile(tw, bundleManifestName, []byte(`{"version":1,"tool":"claude","profiles":["work"]}`)); err != nil {
+		t.Fatalf("write manifest: %v", err)
+	}
+	if err := writeTarFile(tw, "profiles/work/../../../evil", []byte("x")); err != nil {
+		t.Fatalf("write entry: %v", err)
+	}
+	tw.Close()
+	gz.Close()
+
+	if _, err := Import(ClaudeTool(), &buf, false); !errors.Is(err, ErrInvalidBundle) {
+		t.Fatalf("expected ErrInvalidBundle, got %v", err)
+	}
+}
+
+func TestReadBundleLimitsWhatItUnpacks(t *testing.T) {
+	bundle := func(entries map[string][]byte, extra int) *bytes.Buffer {
+		t.Helper()
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		tw := tar.NewWriter(gz)
+		if err := writeTarFile(tw, bundleManifestName, []byte(`{"version":1,"tool":"claude","profiles":["work"]}`)); err != nil {
+			t.Fatalf("write manifest: %v", err)
+		}
+		for name, data := range entries {
+			if err := writeTarFile(tw, name, data); err != nil {
+				t.Fatalf("write entry: %v", err)
+			}
+		}
+		for i := 0; i < extra; i++ {
+			if err := writeTarFile(tw, "profiles/work/settings.json", []byte(`{}`)); err != nil {
+				t.Fatalf("write entry: %v", err)
+			}
+		}
+		tw.Close()
+		gz.Close()
+		return &buf
+	}
+
+	// Compresses to a few bytes, expands past the tool's limit.
+	big := bundle(map[string][]byte{"profiles/work/settings.json": bytes.Repeat([]byte(" "), 4096)}, 0)
+	if _, err := Import(ClaudeTool().WithMaxFileSize(1024), big, false); !errors.Is(err, ErrInvalidBundle) || !strings.Contains(err.Error(), "limit") {
+		t.Fatalf("expected an oversized entry refused, got %v", err)
+	}
+
+	many := bundle(nil, maxBundleEntries)
+	if _, err := ReadBundleInfo(many); !errors.Is(err, ErrInvalidBundle) || !strings.Contains(err.Error(), "entries") {
+		t.Fatalf("expected too many entries refused, got %v", err)
+	}
+}
+
+func TestImportSavesLikeSave(t *testing.T) {
+	tool, configPath := setupClaudeHome(t)
+	if err := os.WriteFile(configPath, []byte(`{"x":1}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"base", "other"} {
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := Export(tool, []string{"base", "other"}, &buf); err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+
+	// The store's settings apply to imported profiles.
+	if err := EnableContentAddressing(tool); err != nil {
+		t.Fatalf("EnableContentAddressing: %v", err)
+	}
+	if err := SaveFrom(tool, "delta", "base", false); err != nil {
+		t.Fatalf("SaveFrom: %v", err)
+	}
+	if _, err := Delete(tool, "other"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	// A profile others are based on is not replaced, and nothing is
+	// imported when one profile is refused.
+	if _, err := Import(tool, bytes.NewReader(buf.Bytes()), true); !errors.Is(err, ErrProfileInUse) {
+		t.Fatalf("expected ErrProfileInUse replacing a base profile, got %v", err)
+	}
+	if exists, _ := Exists(tool, "other"); exists {
+		t.Fatalf("other was imported though the import was refused")
+	}
+
+	if _, err := Delete(tool, "delta"); err != nil {
+		t.Fatalf("Delete delta: %v", err)
+	}
+	if _, err := Import(tool, bytes.NewReader(buf.Bytes()), true); err != nil {
+		t.Fatalf("Import --force: %v", err)
+	}
+	m, err := readManifest(tool, "other")
+	if err != nil || m.Files["settings.json"] == "" {
+		t.Fatalf("imported profile not content-addressed: %+v, %v", m, err)
+	}
+	files, err := ReadFiles(tool, "other")
+	if err != nil || string(files["settings.json"]) != `{"x":1}` {
+		t.Fatalf("ReadFiles = %q, %v", files["settings.json"], err)
+	}
+}
@@ -0,0 +1,389 @@
+package profile
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const manifestFileName = "manifest.json"
+
+// manifest maps the file names of a content-addressed profile to the sha256
+// of their payload in the blob directory.
+type manifest struct {
+	Files map[string]string `json:"files"`
+}
+
+func (t Tool) blobsDir() (string, error) {
+	base, err := t.tokyoDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "blobs"), nil
+}
+
+// blobPath returns the path of the blob holding hash, which may be stored
+// compressed. Blobs the user store lacks are looked up in the system store,
+// whose profiles may be content-addressed too.
+func (t Tool) blobPath(hash string) (string, error) {
+	dir, err := t.blobsDir()
+	if err != nil {
+		return "", err
+	}
+	plain := filepath.Join(dir, hash)
+	for _, candidate := range []string{plain + gzipSuffix, plain} {
+		if _, err := os.Lstat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	systemDir, ok, err := t.systemProfilesDir()
+	if err != nil || !ok {
+		return plain, err
+	}
+	system := filepath.Join(filepath.Dir(systemDir), "blobs", hash)
+	for _, candidate := range []string{system + gzipSuffix, system} {
+		if _, err := os.Lstat(candidate); err == nil {
+			return candidate, nil
+		}
+	}
+	return plain, nil
+}
+
+// blobHash returns the hash encoded in path if it points into the blob
+// directory.
+func (t Tool) blobHash(path string) (string, bool) {
+	dir, err := t.blobsDir()
+	if err != nil || filepath.Dir(path) != dir {
+		return "", false
+	}
+	return strings.TrimSuffix(filepath.Base(path), gzipSuffix), true
+}
+
+func readManifest(t Tool, profile string) (manifest, error) {
+	path, err := t.profileFile(profile, manifestFileName)
+	if err != nil {
+		return manifest{}, err
+	}
+	return readManifestFile(path)
+}
+
+func readManifestFile(path string) (manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return manifest{}, nil
+		}
+		return manifest{}, err
+	}
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return manifest{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return m, nil
+}
+
+func writeManifestFile(path string, m manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o600)
+}
+
+// manifestFile returns the blob path a profile's manifest records for name.
+func (t Tool) manifestFile(profile, name string) (string, bool, error) {
+	m, err := readManifest(t, profile)
+	if err != nil {
+		return "", false, err
+	}
+	hash, ok := m.Files[name]
+	if !ok {
+		return "", false, nil
+	}
+	path, err := t.blobPath(hash)
+	return path, true, err
+}
+
+// storeProfileFile copies src as name into the profile directory dir, using
+// the blob store when the tool's store is content-addressed.
+func storeProfileFile(t Tool, settings StoreSettings, dir, name, src string) error {
+	if !settings.ContentAddressed {
+		dst := filepath.Join(dir, storedName(name, settings.Compression))
+		if err := writeStoredFile(src, dst, settings.Compression); err != nil {
+			return err
+		}
+		// Drop the payload in its other encoding so reads are unambiguous.
+		other := filepath.Join(dir, storedName(name, otherCompression(settings.Compression)))
+		if err := os.Remove(other); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		if settings.HardLinks {
+			return linkIdenticalFile(t, dst)
+		}
+		return nil
+	}
+
+	hash, err := writeBlob(t, src, settings.Compression)
+	if err != nil {
+		return err
+	}
+	manifestPath := filepath.Join(dir, manifestFileName)
+	m, err := readManifestFile(manifestPath)
+	if err != nil {
+		return err
+	}
+	if m.Files == nil {
+		m.Files = map[string]string{}
+	}
+	m.Files[name] = hash
+	return writeManifestFile(manifestPath, m)
+}
+
+// writeBlob stores the content of src in the blob directory and returns the
+// hash of the uncompressed content. Existing blobs are reused.
+func writeBlob(t Tool, src, compression string) (string, error) {
+	dir, err := t.blobsDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+
+	in, err := openStored(src)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	tmp, err := os.CreateTemp(dir, ".tokyo-blob-")
+	if err != nil {
+		return "", err
+	}
+	tmpName := tmp.Name()
+	defer os.Remove(tmpName)
+
+	hasher := sha256.New()
+	w, flush := compressWriter(tmp, compression)
+	if _, err := io.Copy(io.MultiWriter(w, hasher), in); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("copy %s to %s: %w", src, tmpName, err)
+	}
+	if err := flush(); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("compress %s: %w", tmpName, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	hash := hex.EncodeToString(hasher.Sum(nil))
+	for _, name := range []string{hash, hash + gzipSuffix} {
+		if _, err := os.Lstat(filepath.Join(dir, name)); err == nil {
+			return hash, nil
+		}
+	}
+	if err := os.Rename(tmpName, filepath.Join(dir, storedName(hash, compression))); err != nil {
+		return "", err
+	}
+	return hash, nil
+}
+
+// EnableContentAddressing switches t's store to content-addressed layout and
+// moves the payloads of existing profiles into the blob directory.
+func EnableContentAddressing(t Tool) error {
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return err
+	}
+	if settings.SwitchMode == SwitchModeSymlink {
+		return errors.New("content-addressed storage cannot be combined with symlink switching; run 'store switch-mode copy' first")
+	}
+	settings.ContentAddressed = true
+
+	profiles, err := listUserProfiles(t)
+	if err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		profileDir, err := t.profileDir(p)
+		if err != nil {
+			return err
+		}
+		for _, rel := range t.ConfigRelPaths {
+			name := filepath.Base(rel)
+			for _, stored := range []string{name, name + gzipSuffix} {
+				path, err := t.profileFile(p, stored)
+				if err != nil {
+					return err
+				}
+				exists, err := ensureRegularFileIfExists(path)
+				if err != nil {
+					return err
+				}
+				if !exists {
+					continue
+				}
+				if err := storeProfileFile(t, settings, profileDir, name, path); err != nil {
+					return err
+				}
+				if err := os.Remove(path); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return writeStoreSettings(t, settings)
+}
+
+// PruneBlobs removes blobs no profile manifest, including those of trashed
+// profiles, references and returns how many were removed.
+func PruneBlobs(t Tool) (int, error) {
+	dir, err := t.blobsDir()
+	if err != nil {
+		return 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	referenced, err := referencedBlobs(t)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		// Dot files are blobs still being written.
+		if referenced[strings.TrimSuffix(entry.Name(), gzipSuffix)] || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func referencedBlobs(t Tool) (map[string]bool, error) {
+	profiles, err := listUserProfiles(t)
+	if err != nil {
+		return nil, err
+	}
+	referenced := map[string]bool{}
+	for _, p := range profiles {
+		m, err := readManifest(t, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range m.Files {
+			referenced[hash] = true
+		}
+	}
+
+	// Trashed profiles keep their blobs until retention purges them, and
+	// profiles still being saved need theirs once renamed into place.
+	var dirs []string
+	trash, err := retainedEntries(t, CategoryTrash)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range trash {
+		dirs = append(dirs, entry.Path)
+	}
+	building, err := tempProfileDirs(t)
+	if err != nil {
+		return nil, err
+	}
+	dirs = append(dirs, building...)
+	for _, dir := range dirs {
+		m, err := readManifestFile(filepath.Join(dir, manifestFileName))
+		if err != nil {
+			return nil, err
+		}
+		for _, hash := range m.Files {
+			referenced[hash] = true
+		}
+	}
+	return referenced, nil
+}
+
+// BlobStats reports how many blobs the store holds and their total size.
+func BlobStats(t Tool) (count int, size int64, err error) {
+	dir, err := t.blobsDir()
+	if err != nil {
+		return 0, 0, err
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return 0, 0, err
+		}
+		count++
+		size += info.Size()
+	}
+	return count, size, nil
+}
+
+// storedFileEqual compares a stored file with a live one, disregarding
+// content matched by the tool's ignore rules and, in CompareJSON mode, JSON
+// formatting.
+func (t Tool) storedFileEqual(stored, live string) (bool, error) {
+	equal, err := t.storedFileIdentical(stored, live)
+	if err != nil || equal {
+		return equal, err
+	}
+	c, err := t.comparer()
+	if err != nil || c.exact() {
+		return false, err
+	}
+	return c.equal(stored, live)
+}
+
+// storedFileIdentical compares the content of a stored file with a live one.
+// Blobs are named by their hash, so only the live file has to be read; other
+// compressed payloads are compared by the hash of their decompressed content.
+func (t Tool) storedFileIdentical(stored, live string) (bool, error) {
+	hash, ok := t.blobHash(stored)
+	if !ok && !strings.HasSuffix(stored, gzipSuffix) {
+		return filesEqual(stored, live)
+	}
+	if !ok {
+		var err error
+		if hash, err = storedHash(stored); err != nil {
+			return false, err
+		}
+	}
+	if err := ensureRegularFile(live); err != nil {
+		return false, err
+	}
+	liveHash, err := fileHash(live)
+	if err != nil {
+		return false, err
+	}
+	return liveHash == hash, nil
+}
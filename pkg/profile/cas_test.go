@@ -0,0 +1,100 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestContentAddressedStoreDeduplicates(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := CodexTool()
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(codexDir, "config.toml")
+	authPath := filepath.Join(codexDir, "auth.json")
+	if err := os.WriteFile(configPath, []byte(`model = "o3"`), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"work"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if err := Save(tool, "legacy", false); err != nil {
+		t.Fatalf("Save legacy: %v", err)
+	}
+
+	if err := EnableContentAddressing(tool); err != nil {
+		t.Fatalf("EnableContentAddressing: %v", err)
+	}
+	legacyDir := filepath.Join(home, ".config", "tokyo", "codex", "profiles", "legacy")
+	if _, err := os.Stat(filepath.Join(legacyDir, "config.toml")); !os.IsNotExist(err) {
+		t.Fatalf("expected legacy payload migrated, got %v", err)
+	}
+
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"personal"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if err := Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+
+	count, _, err := BlobStats(tool)
+	if err != nil {
+		t.Fatalf("BlobStats: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected 3 distinct blobs, got %d", count)
+	}
+
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work" {
+		t.Fatalf("expected work, got %q", status)
+	}
+	if err := os.WriteFile(configPath, []byte(`model = "o4"`), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	status, err = Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work (modified)" {
+		t.Fatalf("expected work (modified), got %q", status)
+	}
+
+	if _, err := Delete(tool, "personal"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	count, _, err = BlobStats(tool)
+	if err != nil {
+		t.Fatalf("BlobStats: %v", err)
+	}
+	if count != 3 {
+		t.Fatalf("expected trashed profile to keep its blob, got %d blobs", count)
+	}
+
+	policy := RetentionPolicy{Trash: Retention{MaxAge: time.Nanosecond}}
+	if _, err := GC(tool, policy, time.Now().Add(time.Second), false); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	count, _, err = BlobStats(tool)
+	if err != nil {
+		t.Fatalf("BlobStats: %v", err)
+	}
+	if count != 2 {
+		t.Fatalf("expected unreferenced blob pruned, got %d blobs", count)
+	}
+}
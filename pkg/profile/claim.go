@@ -0,0 +1,225 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// claimPrefix marks the claim file a writer holds on a profile name in
+// profiles/. Like build directories it starts with a dot, so it can never
+// be mistaken for a profile.
+const claimPrefix = ".claim-"
+
+// claimProfile atomically claims profile for the calling writer by creating
+// profiles/.claim-<name> with O_EXCL. Concurrent Save, Rename and Copy calls
+// touching the same name fail with ErrProfileBusy instead of interleaving.
+// Claims older than staleTempAge are assumed to be left by a crash and are
+// taken over. The returned func releases the claim.
+func claimProfile(t Tool, profile string) (func(), error) {
+	profilesDir, err := t.profilesDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(profilesDir, 0o700); err != nil {
+		return nil, err
+	}
+	path := filepath.Join(profilesDir, claimPrefix+profile)
+
+	for attempt := 0; ; attempt++ {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("claim profile %q: %w", profile, err)
+		}
+		info, statErr := os.Stat(path)
+		if attempt == 0 && statErr == nil && time.Since(info.ModTime()) > staleTempAge {
+			if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			continue
+		}
+		return nil, newUserError(ErrProfileBusy, "profile %q is being modified by another tokyo process", profile)
+	}
+}
+
+// claimProfiles claims several names, releasing those already held if one
+// of them is busy.
+func claimProfiles(t Tool, profiles ...string) (func(), error) {
+	var releases []func()
+	releaseAll := func() {
+		for i := len(releases) - 1; i >= 0; i-- {
+			releases[i]()
+		}
+	}
+	for _, p := range profiles {
+		release, err := claimProfile(t, p)
+		if err != nil {
+			releaseAll()
+			return nil, err
+		}
+		releases = append(releases, release)
+	}
+	return releaseAll, nil
+}
+
+func profileExistsError(profile string) error {
+	return newUserError(ErrProfileAlreadyExists, "profile %q already exists", profile)
+}
+
+// checkCaseCollision rejects profile when another profile's name differs
+// from it only in case, other than except. On the case-insensitive file
+// systems macOS and Windows use by default both names share one directory,
+// so saving one would overwrite the other; they are refused everywhere so a
+// store stays valid when it is copied between machines.
+func checkCaseCollision(t Tool, profile, except string) error {
+	profiles, err := List(t)
+	if err != nil {
+		return err
+	}
+	for _, p := range profiles {
+		if p != profile && p != except && strings.EqualFold(p, profile) {
+			return newUserError(ErrProfileAlreadyExists, "profile %q already exists as %q; names differing only in case share a directory on macOS and Windows, so use %q or another name", profile, p, p)
+		}
+	}
+	return nil
+}
+
+// Rename gives profile a new name. The active and the intended profile
+// follow the rename.
+// Locked profiles and profiles other profiles are based on cannot be renamed.
+func Rename(t Tool, from, to string) error {
+	if err := ValidateProfileName(to); err != nil {
+		return err
+	}
+	if err := requireProfile(t, from); err != nil {
+		return err
+	}
+	release, err := claimProfiles(t, from, to)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := checkCaseCollision(t, to, from); err != nil {
+		return err
+	}
+
+	if err := checkWritable(t, from); err != nil {
+		return err
+	}
+	if err := checkUnlocked(t, from); err != nil {
+		return err
+	}
+	if err := checkNoDependents(t, from); err != nil {
+		return err
+	}
+
+	fromDir, err := t.profileDir(from)
+	if err != nil {
+		return err
+	}
+	toDir, err := t.userProfileDir(to)
+	if err != nil {
+		return err
+	}
+	if err := renameNoReplace(fromDir, toDir); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return profileExistsError(to)
+		}
+		return err
+	}
+
+	current, err := readCurrentProfile(t)
+	if err != nil {
+		return err
+	}
+	recordHistory(t, historyEntry{Time: time.Now().UTC(), Profile: to, RenamedFrom: from})
+	if err := renameIntent(t, from, to); err != nil {
+		return err
+	}
+	if current == from {
+		if err := writeCurrentState(t, to); err != nil {
+			return err
+		}
+		return relinkLive(t, to)
+	}
+	return nil
+}
+
+// Copy duplicates profile src as dst, including its metadata. The copy is
+// not locked even when src is.
+func Copy(t Tool, src, dst string) error {
+	if err := ValidateProfileName(dst); err != nil {
+		return err
+	}
+	if err := requireProfile(t, src); err != nil {
+		return err
+	}
+	release, err := claimProfile(t, dst)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := checkCaseCollision(t, dst, ""); err != nil {
+		return err
+	}
+	exists, err := Exists(t, dst)
+	if err != nil {
+		return err
+	}
+	if exists {
+		return profileExistsError(dst)
+	}
+
+	srcDir, err := t.profileDir(src)
+	if err != nil {
+		return err
+	}
+	dstDir, err := t.userProfileDir(dst)
+	if err != nil {
+		return err
+	}
+	build, err := createProfileBuildDir(t, dst)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(build)
+
+	entries, err := os.ReadDir(srcDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.Name() == metaFileName {
+			continue
+		}
+		if err := copyFile(filepath.Join(srcDir, entry.Name()), filepath.Join(build, entry.Name())); err != nil {
+			return err
+		}
+	}
+	meta, err := readMetaFile(t, src)
+	if err != nil {
+		return err
+	}
+	meta.Locked = false
+	if err := writeMetaDir(build, meta); err != nil {
+		return err
+	}
+
+	if err := renameNoReplace(build, dstDir); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return profileExistsError(dst)
+		}
+		return err
+	}
+	return nil
+}
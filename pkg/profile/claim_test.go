@@ -0,0 +1,132 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupClaudeHome(t *testing.T) (Tool, string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	return ClaudeTool(), configPath
+}
+
+func TestClaimBlocksConcurrentWriters(t *testing.T) {
+	tool, _ := setupClaudeHome(t)
+
+	release, err := claimProfile(tool, "work")
+	if err != nil {
+		t.Fatalf("claimProfile: %v", err)
+	}
+	if err := Save(tool, "work", false); !errors.Is(err, ErrProfileBusy) {
+		t.Fatalf("expected ErrProfileBusy while claimed, got %v", err)
+	}
+	release()
+
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save after release: %v", err)
+	}
+	if err := Save(tool, "work", false); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("expected ErrProfileAlreadyExists, got %v", err)
+	}
+	profiles, err := List(tool)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(profiles) != 1 {
+		t.Fatalf("expected claim files to stay hidden, got %v", profiles)
+	}
+}
+
+func TestRenameAndCopy(t *testing.T) {
+	tool, _ := setupClaudeHome(t)
+
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+	if err := Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := SetLocked(tool, "personal", true); err != nil {
+		t.Fatalf("SetLocked: %v", err)
+	}
+
+	if err := Rename(tool, "work", "personal"); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("expected ErrProfileAlreadyExists, got %v", err)
+	}
+	if err := Rename(tool, "work", "job"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "job" {
+		t.Fatalf("expected current to follow the rename, got %q", status)
+	}
+	if err := Rename(tool, "personal", "home"); !errors.Is(err, ErrProfileLocked) {
+		t.Fatalf("expected ErrProfileLocked, got %v", err)
+	}
+
+	if err := Copy(tool, "personal", "home"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	meta, err := ReadMeta(tool, "home")
+	if err != nil {
+		t.Fatalf("ReadMeta: %v", err)
+	}
+	if meta.Locked {
+		t.Fatalf("expected the copy to be unlocked")
+	}
+	if err := Copy(tool, "personal", "job"); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("expected ErrProfileAlreadyExists, got %v", err)
+	}
+}
+
+func TestCaseCollisions(t *testing.T) {
+	tool, _ := setupCodexProfiles(t)
+
+	for _, name := range []string{"Work", "WORK", "wOrK"} {
+		err := Save(tool, name, true)
+		if !errors.Is(err, ErrProfileAlreadyExists) {
+			t.Fatalf("Save %s: expected ErrProfileAlreadyExists, got %v", name, err)
+		}
+		if !strings.Contains(err.Error(), `"work"`) {
+			t.Fatalf("expected the error to name the existing profile, got %v", err)
+		}
+	}
+	if err := Copy(tool, "personal", "Work"); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("Copy: expected ErrProfileAlreadyExists, got %v", err)
+	}
+	if err := Rename(tool, "personal", "WORK"); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("Rename: expected ErrProfileAlreadyExists, got %v", err)
+	}
+
+	// Case folding covers letters beyond ASCII.
+	if err := Save(tool, "été", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := Save(tool, "ÉTÉ", false); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("Save ÉTÉ: expected ErrProfileAlreadyExists, got %v", err)
+	}
+
+	// A profile may change only the case of its own name.
+	if err := Rename(tool, "personal", "Personal"); err != nil {
+		t.Fatalf("Rename to a new case: %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+)
+
+const (
+	// CompareBytes treats any byte difference as a modification.
+	CompareBytes = ""
+	// CompareJSON compares files that parse as JSON by value, so key order
+	// and whitespace do not matter.
+	CompareJSON = "json"
+)
+
+// ValidateCompare checks that mode is a supported comparison setting.
+func ValidateCompare(mode string) error {
+	switch mode {
+	case CompareBytes, CompareJSON:
+		return nil
+	}
+	return fmt.Errorf("unsupported comparison %q (supported: bytes, json)", mode)
+}
+
+// SetCompare changes how t's live files are compared with stored profiles.
+func SetCompare(t Tool, mode string) error {
+	if mode == "bytes" {
+		mode = CompareBytes
+	}
+	if err := ValidateCompare(mode); err != nil {
+		return err
+	}
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return err
+	}
+	settings.Compare = mode
+	return writeStoreSettings(t, settings)
+}
+
+// comparer decides whether a live file whose bytes differ from the stored
+// copy still counts as unchanged.
+type comparer struct {
+	canonicalJSON bool
+	ignore        ignoreRules
+}
+
+func (t Tool) comparer() (comparer, error) {
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return comparer{}, err
+	}
+	rules, err := t.ignoreRules(settings)
+	if err != nil {
+		return comparer{}, err
+	}
+	return comparer{canonicalJSON: settings.Compare == CompareJSON, ignore: rules}, nil
+}
+
+// exact reports whether only identical bytes compare equal.
+func (c comparer) exact() bool {
+	return !c.canonicalJSON && c.ignore.empty()
+}
+
+// equal compares a stored payload with a live file after normalizing both.
+func (c comparer) equal(stored, live string) (bool, error) {
+	storedData, err := readStored(stored)
+	if err != nil {
+		return false, err
+	}
+	if err := ensureRegularFile(live); err != nil {
+		return false, err
+	}
+	liveData, err := os.ReadFile(live)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(c.normalize(storedData), c.normalize(liveData)), nil
+}
+
+// normalize returns data without the content ignore rules match. Data that
+// parses as JSON is re-encoded with sorted keys when comparing canonically
+// or when JSON pointers have to be removed.
+func (c comparer) normalize(data []byte) []byte {
+	if c.canonicalJSON || len(c.ignore.pointers) > 0 {
+		if doc, ok := decodeJSON(data); ok {
+			for _, pointer := range c.ignore.pointers {
+				doc = removePointer(doc, pointer)
+			}
+			if out, err := json.MarshalIndent(doc, "", "  "); err == nil {
+				data = out
+			}
+		}
+	}
+	if len(c.ignore.lines) == 0 {
+		return data
+	}
+	var out [][]byte
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if !slices.ContainsFunc(c.ignore.lines, func(re *regexp.Regexp) bool { return re.Match(line) }) {
+			out = append(out, line)
+		}
+	}
+	return bytes.Join(out, []byte("\n"))
+}
@@ -0,0 +1,81 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareJSONIgnoresFormatting(t *testing.T) {
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(codexDir, "config.toml")
+	authPath := filepath.Join(codexDir, "auth.json")
+	if err := os.WriteFile(configPath, []byte("model = \"o3\"\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"a","expires":10}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	// The tool re-serializes auth.json with other key order and indentation.
+	if err := os.WriteFile(authPath, []byte("{\n    \"expires\": 10,\n    \"token\": \"a\"\n}\n"), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	assertStatus(t, tool, "work (modified)")
+
+	if err := SetCompare(tool, CompareJSON); err != nil {
+		t.Fatalf("SetCompare: %v", err)
+	}
+	assertStatus(t, tool, "work")
+
+	// Formatting-only changes to non-JSON files still count.
+	if err := os.WriteFile(configPath, []byte("model =  \"o3\"\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	assertStatus(t, tool, "work (modified)")
+	if err := os.WriteFile(configPath, []byte("model = \"o3\"\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+
+	// So do changed values.
+	if err := os.WriteFile(authPath, []byte(`{"expires":11,"token":"a"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	assertStatus(t, tool, "work (modified)")
+
+	if err := SetCompare(tool, "bytes"); err != nil {
+		t.Fatalf("SetCompare bytes: %v", err)
+	}
+	settings, err := ReadStoreSettings(tool)
+	if err != nil {
+		t.Fatalf("ReadStoreSettings: %v", err)
+	}
+	if settings.Compare != CompareBytes {
+		t.Fatalf("expected bytes comparison, got %q", settings.Compare)
+	}
+	if err := SetCompare(tool, "yaml"); err == nil {
+		t.Fatalf("expected unsupported comparison to be rejected")
+	}
+}
+
+func assertStatus(t *testing.T, tool Tool, want string) {
+	t.Helper()
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != want {
+		t.Fatalf("expected status %q, got %q", want, status)
+	}
+}
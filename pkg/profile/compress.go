@@ -0,0 +1,173 @@
+package profile
+
+import (
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const (
+	// CompressionNone stores payloads as-is.
+	CompressionNone = ""
+	// CompressionGzip stores payloads gzip-compressed with a ".gz" suffix.
+	CompressionGzip = "gzip"
+
+	gzipSuffix = ".gz"
+)
+
+// ValidateCompression checks that algo is a supported compression setting.
+func ValidateCompression(algo string) error {
+	switch algo {
+	case CompressionNone, CompressionGzip:
+		return nil
+	}
+	return fmt.Errorf("unsupported compression %q (supported: none, gzip)", algo)
+}
+
+// SetCompression changes how new payloads in t's store are compressed.
+// Existing payloads stay readable in whatever form they were written.
+func SetCompression(t Tool, algo string) error {
+	if algo == "none" {
+		algo = CompressionNone
+	}
+	if err := ValidateCompression(algo); err != nil {
+		return err
+	}
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return err
+	}
+	if algo != CompressionNone && settings.SwitchMode == SwitchModeSymlink {
+		return errors.New("compression cannot be combined with symlink switching; run 'store switch-mode copy' first")
+	}
+	settings.Compression = algo
+	return writeStoreSettings(t, settings)
+}
+
+// storedName returns the on-disk name of a payload under the given
+// compression setting.
+func storedName(name, compression string) string {
+	if compression == CompressionGzip {
+		return name + gzipSuffix
+	}
+	return name
+}
+
+func otherCompression(compression string) string {
+	if compression == CompressionGzip {
+		return CompressionNone
+	}
+	return CompressionGzip
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	file *os.File
+}
+
+func (r gzipReadCloser) Close() error {
+	return errors.Join(r.Reader.Close(), r.file.Close())
+}
+
+// openStored opens a stored payload, decompressing it if needed.
+func openStored(path string) (io.ReadCloser, error) {
+	if err := ensureRegularFile(path); err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(path, gzipSuffix) {
+		return file, nil
+	}
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("decompress %s: %w", path, err)
+	}
+	return gzipReadCloser{Reader: zr, file: file}, nil
+}
+
+// readStored returns the uncompressed content of a stored payload.
+func readStored(path string) ([]byte, error) {
+	r, err := openStored(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	return data, nil
+}
+
+// storedHash returns the sha256 of a stored payload's uncompressed content.
+func storedHash(path string) (string, error) {
+	hash, _, err := storedDigest(path)
+	return hash, err
+}
+
+// storedDigest returns the sha256 and size of a file's uncompressed content.
+func storedDigest(path string) (string, int64, error) {
+	r, err := openStored(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer r.Close()
+	hasher := sha256.New()
+	n, err := io.Copy(hasher, r)
+	if err != nil {
+		return "", 0, fmt.Errorf("read %s: %w", path, err)
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), n, nil
+}
+
+// compressWriter wraps w according to the compression setting. The returned
+// closer flushes the compressor without closing w.
+func compressWriter(w io.Writer, compression string) (io.Writer, func() error) {
+	if compression != CompressionGzip {
+		return w, func() error { return nil }
+	}
+	zw := gzip.NewWriter(w)
+	return zw, zw.Close
+}
+
+// writeStoredFile copies src to dst, compressing according to the setting.
+func writeStoredFile(src, dst, compression string) error {
+	if compression == CompressionNone {
+		return copyFile(src, dst)
+	}
+	in, err := openStored(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	if err := ensureParentDir(dst); err != nil {
+		return err
+	}
+	if err := rejectNonRegularFile(dst); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	w, flush := compressWriter(out, compression)
+	if _, err := io.Copy(w, in); err != nil {
+		out.Close()
+		return fmt.Errorf("copy %s to %s: %w", src, dst, err)
+	}
+	if err := flush(); err != nil {
+		out.Close()
+		return fmt.Errorf("compress %s: %w", dst, err)
+	}
+	return out.Close()
+}
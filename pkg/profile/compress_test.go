@@ -0,0 +1,107 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestCompressedProfilesAreTransparent(t *testing.T) {
+	for _, cas := range []bool{false, true} {
+		name := "plain_store"
+		if cas {
+			name = "content_addressed_store"
+		}
+		t.Run(name, func(t *testing.T) {
+			home := t.TempDir()
+			t.Setenv("HOME", home)
+
+			tool := ClaudeTool()
+			configPath := filepath.Join(home, ".claude", "settings.json")
+			if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			content := `{"proxy":"http://proxy.example.com","padding":"` + strings.Repeat("x", 4096) + `"}`
+			if err := os.WriteFile(configPath, []byte(content), 0o600); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+
+			if err := SetCompression(tool, CompressionGzip); err != nil {
+				t.Fatalf("SetCompression: %v", err)
+			}
+			if cas {
+				if err := EnableContentAddressing(tool); err != nil {
+					t.Fatalf("EnableContentAddressing: %v", err)
+				}
+			}
+			if err := Save(tool, "work", false); err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			stored, err := tool.resolveProfileFile("work", "settings.json")
+			if err != nil {
+				t.Fatalf("resolveProfileFile: %v", err)
+			}
+			if !strings.HasSuffix(stored, gzipSuffix) {
+				t.Fatalf("expected compressed payload, got %s", stored)
+			}
+			info, err := os.Stat(stored)
+			if err != nil {
+				t.Fatalf("stat payload: %v", err)
+			}
+			if info.Size() >= int64(len(content)) {
+				t.Fatalf("expected payload smaller than %d bytes, got %d", len(content), info.Size())
+			}
+
+			if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+				t.Fatalf("write config: %v", err)
+			}
+			if err := Switch(tool, "work"); err != nil {
+				t.Fatalf("Switch: %v", err)
+			}
+			data, err := os.ReadFile(configPath)
+			if err != nil {
+				t.Fatalf("read config: %v", err)
+			}
+			if string(data) != content {
+				t.Fatalf("expected decompressed content after switch")
+			}
+			status, err := Current(tool)
+			if err != nil {
+				t.Fatalf("Current: %v", err)
+			}
+			if status != "work" {
+				t.Fatalf("expected work, got %q", status)
+			}
+
+			matches, err := Grep(tool, regexp.MustCompile(`proxy\.example`))
+			if err != nil {
+				t.Fatalf("Grep: %v", err)
+			}
+			if len(matches) != 1 || matches[0].File != "settings.json" {
+				t.Fatalf("expected one match in settings.json, got %+v", matches)
+			}
+
+			if cas {
+				removed, err := PruneBlobs(tool)
+				if err != nil {
+					t.Fatalf("PruneBlobs: %v", err)
+				}
+				if removed != 0 {
+					t.Fatalf("expected referenced compressed blob kept, removed %d", removed)
+				}
+			}
+		})
+	}
+}
+
+func TestSetCompressionRejectsUnknown(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if err := SetCompression(ClaudeTool(), "zstd"); err == nil {
+		t.Fatalf("expected unsupported compression error")
+	}
+}
@@ -0,0 +1,124 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// resolveProfileFile returns the stored path that provides name for profile:
+// a plain file in the profile directory, a blob listed in its manifest, or
+// failing both, the same lookup in the profile's base chain.
+// When no profile in the chain has the file, the path inside profile is
+// returned so callers see a normal not-exist error.
+func (t Tool) resolveProfileFile(profile, name string) (string, error) {
+	own, err := t.profileFile(profile, name)
+	if err != nil {
+		return "", err
+	}
+
+	seen := map[string]bool{}
+	for p := profile; ; {
+		seen[p] = true
+		path, err := t.profileFile(p, name)
+		if err != nil {
+			return "", err
+		}
+		for _, candidate := range []string{path, path + gzipSuffix} {
+			if _, err := os.Lstat(candidate); err == nil {
+				return candidate, nil
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+		blob, ok, err := t.manifestFile(p, name)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			return blob, nil
+		}
+
+		meta, err := readMetaFile(t, p)
+		if err != nil {
+			return "", err
+		}
+		if meta.Base == "" {
+			return own, nil
+		}
+		if seen[meta.Base] {
+			return "", fmt.Errorf("profile %q has a cyclic base chain", profile)
+		}
+		p = meta.Base
+	}
+}
+
+// sameAsProfileFile reports whether the live file at path has the same
+// content as the file profile provides for it. Ignore rules do not apply:
+// a file differing only in ignored content is still stored.
+func sameAsProfileFile(t Tool, profile, path string) (bool, error) {
+	stored, err := t.resolveProfileFile(profile, filepath.Base(path))
+	if err != nil {
+		return false, err
+	}
+	exists, err := ensureRegularFileIfExists(stored)
+	if err != nil || !exists {
+		return false, err
+	}
+	exists, err = ensureRegularFileIfExists(path)
+	if err != nil || !exists {
+		return false, err
+	}
+	return t.storedFileIdentical(stored, path)
+}
+
+// checkNotAncestor rejects basing profile on base when profile is already
+// part of base's chain.
+func checkNotAncestor(t Tool, profile, base string) error {
+	seen := map[string]bool{}
+	for p := base; p != "" && !seen[p]; {
+		if p == profile {
+			return fmt.Errorf("profile %q cannot be based on %q: it would create a cycle", profile, base)
+		}
+		seen[p] = true
+		meta, err := readMetaFile(t, p)
+		if err != nil {
+			return err
+		}
+		p = meta.Base
+	}
+	return nil
+}
+
+// Dependents returns the profiles that use profile as their base.
+func Dependents(t Tool, profile string) ([]string, error) {
+	profiles, err := List(t)
+	if err != nil {
+		return nil, err
+	}
+	var dependents []string
+	for _, p := range profiles {
+		meta, err := readMetaFile(t, p)
+		if err != nil {
+			return nil, err
+		}
+		if meta.Base == profile {
+			dependents = append(dependents, p)
+		}
+	}
+	return dependents, nil
+}
+
+func checkNoDependents(t Tool, profile string) error {
+	dependents, err := Dependents(t, profile)
+	if err != nil {
+		return err
+	}
+	if len(dependents) > 0 {
+		return newUserError(ErrProfileInUse, "profile %q is the base of %s", profile, strings.Join(dependents, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveFromStoresOnlyDifferingFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := CodexTool()
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(codexDir, "config.toml")
+	authPath := filepath.Join(codexDir, "auth.json")
+	if err := os.WriteFile(configPath, []byte(`model = "o3"`), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"work"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+
+	if err := os.WriteFile(authPath, []byte(`{"token":"personal"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if err := SaveFrom(tool, "personal", "work", false); err != nil {
+		t.Fatalf("SaveFrom: %v", err)
+	}
+
+	personalDir := filepath.Join(home, ".config", "tokyo", "codex", "profiles", "personal")
+	if _, err := os.Stat(filepath.Join(personalDir, "config.toml")); !os.IsNotExist(err) {
+		t.Fatalf("expected config.toml not stored in delta profile, got %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(personalDir, "auth.json")); err != nil {
+		t.Fatalf("expected auth.json stored in delta profile: %v", err)
+	}
+
+	if err := Switch(tool, "personal"); err != nil {
+		t.Fatalf("Switch personal: %v", err)
+	}
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "personal" {
+		t.Fatalf("expected personal, got %q", status)
+	}
+
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch work: %v", err)
+	}
+	if err := Switch(tool, "personal"); err != nil {
+		t.Fatalf("Switch personal again: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config.toml: %v", err)
+	}
+	if string(data) != `model = "o3"` {
+		t.Fatalf("expected inherited config.toml, got %q", string(data))
+	}
+
+	if _, err := Delete(tool, "work"); !errors.Is(err, ErrProfileInUse) {
+		t.Fatalf("expected ErrProfileInUse deleting base, got %v", err)
+	}
+	if err := SaveFrom(tool, "work", "personal", true); err == nil {
+		t.Fatalf("expected cycle to be rejected")
+	}
+}
@@ -0,0 +1,188 @@
+package profile
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// File states reported by Diff.
+const (
+	FileUnchanged = "unchanged"
+	FileModified  = "modified"
+	// FileMissing means the live config file does not exist.
+	FileMissing = "missing"
+	// FileNotStored means the profile has no copy of the file.
+	FileNotStored = "not_stored"
+)
+
+// FileDiff compares one live config file with the copy stored in a profile.
+type FileDiff struct {
+	Name        string `json:"name"`
+	Path        string `json:"path"`
+	Status      string `json:"status"`
+	LiveSize    int64  `json:"liveSize"`
+	ProfileSize int64  `json:"profileSize"`
+	LiveHash    string `json:"liveHash,omitempty"`
+	ProfileHash string `json:"profileHash,omitempty"`
+}
+
+// Diff compares every live config file of t with the copy stored in profile.
+func Diff(t Tool, profile string) ([]FileDiff, error) {
+	if err := requireProfile(t, profile); err != nil {
+		return nil, err
+	}
+	pairs, err := profilePairs(t, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	diffs := make([]FileDiff, 0, len(pairs))
+	for _, pair := range pairs {
+		d := FileDiff{Name: filepath.Base(pair.dst), Path: pair.dst}
+
+		storedExists, err := ensureRegularFileIfExists(pair.src)
+		if err != nil {
+			return nil, err
+		}
+		if storedExists {
+			if d.ProfileHash, d.ProfileSize, err = storedDigest(pair.src); err != nil {
+				return nil, err
+			}
+		}
+
+		live, err := t.liveFile(pair.dst)
+		if err != nil {
+			return nil, err
+		}
+		liveExists, err := ensureRegularFileIfExists(live)
+		if err != nil {
+			return nil, err
+		}
+		if liveExists {
+			if d.LiveHash, d.LiveSize, err = storedDigest(live); err != nil {
+				return nil, err
+			}
+		}
+
+		switch {
+		case !liveExists:
+			d.Status = FileMissing
+		case !storedExists:
+			d.Status = FileNotStored
+		case d.LiveHash == d.ProfileHash:
+			d.Status = FileUnchanged
+		default:
+			d.Status = FileModified
+			// Differences confined to ignored content or formatting do
+			// not count.
+			c, err := t.comparer()
+			if err != nil {
+				return nil, err
+			}
+			if !c.exact() {
+				equal, err := c.equal(pair.src, live)
+				if err != nil {
+					return nil, err
+				}
+				if equal {
+					d.Status = FileUnchanged
+				}
+			}
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// ActiveProfile returns the profile recorded as current, or "" when there is
+// none or it no longer exists.
+func ActiveProfile(t Tool) (string, error) {
+	profile, err := readCurrentProfile(t)
+	if err != nil || profile == "" {
+		return "", err
+	}
+	exists, err := Exists(t, profile)
+	if err != nil || !exists {
+		return "", err
+	}
+	return profile, nil
+}
+
+// AcceptLiveFile stores the live copy of t's config file name, a base name
+// as Diff reports, in the active profile, resolving that file's drift in
+// favour of the live config. It returns the active profile.
+func AcceptLiveFile(t Tool, name string) (string, error) {
+	active, pair, err := activeFilePair(t, name)
+	if err != nil {
+		return "", err
+	}
+	live, err := t.liveFile(pair.dst)
+	if err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(live)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", newUserError(ErrConfigFileNotFound, "live config file %s does not exist", pair.dst)
+	}
+	if err != nil {
+		return "", err
+	}
+	return active, WriteFile(t, active, name, data)
+}
+
+// RevertLiveFile overwrites the live copy of t's config file name with the
+// active profile's, resolving that file's drift in favour of the profile.
+// It returns the active profile.
+func RevertLiveFile(t Tool, name string) (string, error) {
+	mode, err := t.switchMode()
+	if err != nil {
+		return "", err
+	}
+	if mode == SwitchModeSymlink {
+		return "", errors.New("with symlink switching the live files are the profile's own and cannot drift")
+	}
+	active, pair, err := activeFilePair(t, name)
+	if err != nil {
+		return "", err
+	}
+	stored, err := ensureRegularFileIfExists(pair.src)
+	if err != nil {
+		return "", err
+	}
+	if !stored {
+		return "", newUserError(ErrProfileMissingFile, "profile %q has no copy of %s", active, name)
+	}
+	data, err := readStored(pair.src)
+	if err != nil {
+		return "", err
+	}
+	perm := os.FileMode(0o600)
+	if info, err := os.Stat(pair.dst); err == nil {
+		perm = info.Mode().Perm()
+	}
+	return active, writeFileAtomic(pair.dst, data, perm)
+}
+
+// activeFilePair returns the active profile of t and the stored and live
+// paths of its config file name.
+func activeFilePair(t Tool, name string) (string, filePair, error) {
+	active, err := ActiveProfile(t)
+	if err != nil {
+		return "", filePair{}, err
+	}
+	if active == "" {
+		return "", filePair{}, newUserError(ErrNoActiveProfile, "no active profile")
+	}
+	pairs, err := profilePairs(t, active)
+	if err != nil {
+		return "", filePair{}, err
+	}
+	for _, pair := range pairs {
+		if filepath.Base(pair.dst) == name {
+			return active, pair, nil
+		}
+	}
+	return "", filePair{}, newUserError(ErrConfigFileNotFound, "%s has no config file %q", t.DisplayName, name)
+}
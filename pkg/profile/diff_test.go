@@ -0,0 +1,125 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiffReportsPerFileStatus(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := CodexTool()
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(codexDir, "config.toml")
+	authPath := filepath.Join(codexDir, "auth.json")
+	if err := os.WriteFile(configPath, []byte(`model = "o3"`), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"a"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if err := os.WriteFile(authPath, []byte(`{"token":"abc"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+
+	diffs, err := Diff(tool, "work")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if len(diffs) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", diffs)
+	}
+	if diffs[0].Name != "config.toml" || diffs[0].Status != FileUnchanged {
+		t.Fatalf("expected config.toml unchanged, got %+v", diffs[0])
+	}
+	if diffs[1].Name != "auth.json" || diffs[1].Status != FileModified || diffs[1].LiveSize-diffs[1].ProfileSize != 2 {
+		t.Fatalf("expected auth.json modified with +2 bytes, got %+v", diffs[1])
+	}
+
+	if err := os.Remove(configPath); err != nil {
+		t.Fatalf("remove config.toml: %v", err)
+	}
+	diffs, err = Diff(tool, "work")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if diffs[0].Status != FileMissing {
+		t.Fatalf("expected config.toml missing, got %+v", diffs[0])
+	}
+}
+
+func TestAcceptAndRevertLiveFile(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	configPath := filepath.Join(codexDir, "config.toml")
+	authPath := filepath.Join(codexDir, "auth.json")
+	if err := os.WriteFile(configPath, []byte("model = \"edited\"\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"edited"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+
+	if active, err := AcceptLiveFile(tool, "config.toml"); err != nil || active != "work" {
+		t.Fatalf("AcceptLiveFile = %q, %v", active, err)
+	}
+	if active, err := RevertLiveFile(tool, "auth.json"); err != nil || active != "work" {
+		t.Fatalf("RevertLiveFile = %q, %v", active, err)
+	}
+
+	stored, err := ReadFiles(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if string(stored["config.toml"]) != "model = \"edited\"\n" {
+		t.Fatalf("accepted config.toml not stored: %q", stored["config.toml"])
+	}
+	live, err := os.ReadFile(authPath)
+	if err != nil || string(live) != `{"token":"work"}` {
+		t.Fatalf("reverted auth.json = %q, %v", live, err)
+	}
+	diffs, err := Diff(tool, "work")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	for _, d := range diffs {
+		if d.Status != FileUnchanged {
+			t.Fatalf("%s still drifts: %s", d.Name, d.Status)
+		}
+	}
+
+	if _, err := AcceptLiveFile(tool, "settings.json"); !errors.Is(err, ErrConfigFileNotFound) {
+		t.Fatalf("expected ErrConfigFileNotFound, got %v", err)
+	}
+	if err := os.Remove(configPath); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if _, err := AcceptLiveFile(tool, "config.toml"); !errors.Is(err, ErrConfigFileNotFound) {
+		t.Fatalf("expected ErrConfigFileNotFound for a missing live file, got %v", err)
+	}
+	if _, err := RevertLiveFile(tool, "config.toml"); err != nil {
+		t.Fatalf("RevertLiveFile restoring a missing file: %v", err)
+	}
+	if _, err := os.Stat(configPath); err != nil {
+		t.Fatalf("config.toml not restored: %v", err)
+	}
+}
+
+func TestAcceptLiveFileNeedsActiveProfile(t *testing.T) {
+	tool := CodexTool().WithHome(t.TempDir())
+	if _, err := AcceptLiveFile(tool, "config.toml"); !errors.Is(err, ErrNoActiveProfile) {
+		t.Fatalf("expected ErrNoActiveProfile, got %v", err)
+	}
+}
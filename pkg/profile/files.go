@@ -0,0 +1,113 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// ReadFiles returns the content stored in profile for each of t's config
+// files, by base name. Files the profile has no copy of are left out.
+func ReadFiles(t Tool, profile string) (map[string][]byte, error) {
+	if err := requireProfile(t, profile); err != nil {
+		return nil, err
+	}
+	pairs, err := profilePairs(t, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string][]byte, len(pairs))
+	for _, pair := range pairs {
+		exists, err := ensureRegularFileIfExists(pair.src)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+		data, err := readStored(pair.src)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Base(pair.dst)] = data
+	}
+	return files, nil
+}
+
+// SaveFiles stores files, keyed by the base names of t's config files, as
+// profile, the way save stores the live config. With force, an existing
+// profile is replaced unless it is locked; its metadata is kept.
+func SaveFiles(t Tool, profile string, files map[string][]byte, force bool) error {
+	return saveFiles(t, profile, "", files, nil, force)
+}
+
+// WriteFile replaces the stored content of the config file name, a base
+// name as ReadFiles returns, in an existing profile and keeps the rest. A
+// profile based on another stays a delta on top of it. The live config is
+// not touched, even when profile is active.
+func WriteFile(t Tool, profile, name string, data []byte) error {
+	files, err := ReadFiles(t, profile)
+	if err != nil {
+		return err
+	}
+	if name == metaFileName || !slices.Contains(storedFileNames(t), name) {
+		return newUserError(ErrConfigFileNotFound, "%s has no config file %q", t.DisplayName, name)
+	}
+	if err := checkWritable(t, profile); err != nil {
+		return err
+	}
+	meta, err := ReadMeta(t, profile)
+	if err != nil {
+		return err
+	}
+	files[name] = data
+	return saveFiles(t, profile, meta.Base, files, nil, true)
+}
+
+// saveFiles is saveWith for config files given by content. A non-nil meta
+// replaces the profile's metadata.
+func saveFiles(t Tool, profile, base string, files map[string][]byte, meta *Meta, force bool) error {
+	if err := checkFileContents(t, files); err != nil {
+		return err
+	}
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return saveWith(t, profile, base, meta, force, func(dir string) ([]string, error) {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return nil, err
+		}
+		paths := make([]string, 0, len(names))
+		for _, name := range names {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, files[name], 0o600); err != nil {
+				return nil, fmt.Errorf("write %s: %w", path, err)
+			}
+			paths = append(paths, path)
+		}
+		return paths, nil
+	})
+}
+
+// checkFileContents rejects files, config file contents by base name, with
+// a name that is not one of t's config files or content over its size
+// limit.
+func checkFileContents(t Tool, files map[string][]byte) error {
+	known := storedFileNames(t)
+	limit, limited := t.FileSizeLimit()
+	for name, data := range files {
+		if name == metaFileName || !slices.Contains(known, name) {
+			return fmt.Errorf("%s has no config file %q", t.DisplayName, name)
+		}
+		if limited && int64(len(data)) > limit {
+			return fmt.Errorf("%s: %w: over the %s limit", name, ErrFileTooLarge, FormatSize(limit))
+		}
+	}
+	return nil
+}
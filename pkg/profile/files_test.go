@@ -0,0 +1,113 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSaveFilesKeepsMetadata(t *testing.T) {
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	files := map[string][]byte{"auth.json": []byte("{}\n"), "config.toml": []byte("model = \"o3\"\n")}
+	if err := SaveFiles(tool, "work", files, true); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	if err := UpdateMeta(tool, "work", func(m *Meta) error {
+		m.Tags = map[string]string{"team": "a"}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+
+	files["config.toml"] = []byte("model = \"o4\"\n")
+	if err := SaveFiles(tool, "work", files, false); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("SaveFiles without force: expected ErrProfileAlreadyExists, got %v", err)
+	}
+	if err := SaveFiles(tool, "work", files, true); err != nil {
+		t.Fatalf("SaveFiles again: %v", err)
+	}
+	got, err := ReadFiles(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if string(got["config.toml"]) != "model = \"o4\"\n" || string(got["auth.json"]) != "{}\n" {
+		t.Fatalf("unexpected files: %q", got)
+	}
+	meta, err := ReadMeta(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadMeta: %v", err)
+	}
+	if meta.Tags["team"] != "a" {
+		t.Fatalf("tags = %v, want team=a", meta.Tags)
+	}
+}
+
+func TestSaveFilesRejectsUnknownFile(t *testing.T) {
+	tool := ClaudeTool().WithHome(t.TempDir())
+	if err := SaveFiles(tool, "work", map[string][]byte{"config.toml": nil}, true); err == nil {
+		t.Fatal("expected error for a file Claude Code does not use")
+	}
+	if err := SaveFiles(tool, "work", map[string][]byte{metaFileName: nil}, true); err == nil {
+		t.Fatal("expected error for meta.json")
+	}
+}
+
+func TestReadFilesSkipsMissingFiles(t *testing.T) {
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	if err := SaveFiles(tool, "work", map[string][]byte{"auth.json": []byte("{}\n")}, true); err != nil {
+		t.Fatalf("SaveFiles: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "tokyo", "codex", "profiles", "work", "config.toml")); !os.IsNotExist(err) {
+		t.Fatalf("config.toml should not be stored: %v", err)
+	}
+	got, err := ReadFiles(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if len(got) != 1 || string(got["auth.json"]) != "{}\n" {
+		t.Fatalf("unexpected files: %q", got)
+	}
+}
+
+func TestWriteFileKeepsOtherFilesAndBase(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	home := filepath.Dir(codexDir)
+	writeCodexFiles(t, codexDir, "work")
+	if err := os.WriteFile(filepath.Join(codexDir, "config.toml"), []byte("model = \"child\"\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := SaveFrom(tool, "child", "work", false); err != nil {
+		t.Fatalf("SaveFrom: %v", err)
+	}
+
+	if err := WriteFile(tool, "child", "config.toml", []byte("model = \"edited\"\n")); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	got, err := ReadFiles(tool, "child")
+	if err != nil {
+		t.Fatalf("ReadFiles: %v", err)
+	}
+	if string(got["config.toml"]) != "model = \"edited\"\n" || string(got["auth.json"]) != `{"token":"work"}` {
+		t.Fatalf("unexpected files: %q", got)
+	}
+	meta, err := ReadMeta(tool, "child")
+	if err != nil || meta.Base != "work" {
+		t.Fatalf("base = %q, %v; want work", meta.Base, err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "tokyo", "codex", "profiles", "child", "auth.json")); !os.IsNotExist(err) {
+		t.Fatalf("auth.json should still come from the base: %v", err)
+	}
+
+	if err := WriteFile(tool, "missing", "config.toml", nil); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+	if err := WriteFile(tool, "child", "settings.json", nil); !errors.Is(err, ErrConfigFileNotFound) {
+		t.Fatalf("expected ErrConfigFileNotFound for a file Codex does not use, got %v", err)
+	}
+	if err := WriteFile(tool.WithMaxFileSize(4), "child", "config.toml", []byte("model = 1\n")); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected ErrFileTooLarge, got %v", err)
+	}
+}
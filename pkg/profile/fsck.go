@@ -0,0 +1,272 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FsckClass is a kind of problem Fsck finds. Each class is repaired its own
+// way; see RepairFsck.
+type FsckClass string
+
+const (
+	// FsckMissing is a profile without one of the tool's config files, which
+	// switching to it would fail on. Repair moves the profile to the trash.
+	FsckMissing FsckClass = "missing"
+	// FsckExtra is a file in a profile directory that tokyo did not put
+	// there, or a payload shadowed by its other encoding. Repair removes it.
+	FsckExtra FsckClass = "extra"
+	// FsckCurrent is a current.json naming a profile that no longer exists,
+	// or that cannot be read. Repair records that no profile is active.
+	FsckCurrent FsckClass = "current"
+	// FsckHash is a manifest entry whose blob is missing or does not hash to
+	// its name. Repair moves the profile to the trash.
+	FsckHash FsckClass = "hash"
+)
+
+// FsckClasses lists every FsckClass in the order Fsck reports them.
+var FsckClasses = []FsckClass{FsckMissing, FsckExtra, FsckCurrent, FsckHash}
+
+// FsckProblem is one inconsistency in a tool's store.
+type FsckProblem struct {
+	Class FsckClass
+	// Profile is the profile the problem is in; "" for FsckCurrent.
+	Profile string
+	// File is the base name of the file concerned inside the profile
+	// directory.
+	File    string
+	Message string
+}
+
+func (p FsckProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Class, p.Message)
+}
+
+// Fsck checks the profiles in t's user store and its current.json for
+// problems that commands would otherwise only run into one at a time.
+// Dot files younger than staleTempAge at now are taken to belong to a write
+// still in progress and are not reported.
+func Fsck(t Tool, now time.Time) ([]FsckProblem, error) {
+	profiles, err := listUserProfiles(t)
+	if err != nil {
+		return nil, err
+	}
+	var problems []FsckProblem
+	hashed := map[string]error{}
+	for _, p := range profiles {
+		found, err := fsckProfile(t, p, now, hashed)
+		if err != nil {
+			return nil, fmt.Errorf("check profile %q: %w", p, err)
+		}
+		problems = append(problems, found...)
+	}
+
+	currentFile, err := t.currentFile()
+	if err != nil {
+		return nil, err
+	}
+	// Checked first as reading a FIFO would block.
+	current := ""
+	if err = rejectNonRegularFile(currentFile); err == nil {
+		current, err = readCurrentProfile(t)
+	}
+	if err != nil {
+		problems = append(problems, FsckProblem{Class: FsckCurrent, Message: err.Error()})
+	} else if current != "" {
+		exists, err := Exists(t, current)
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			problems = append(problems, FsckProblem{
+				Class:   FsckCurrent,
+				Message: fmt.Sprintf("current.json names profile %q, which does not exist", current),
+			})
+		}
+	}
+
+	slices.SortStableFunc(problems, func(a, b FsckProblem) int {
+		return slices.Index(FsckClasses, a.Class) - slices.Index(FsckClasses, b.Class)
+	})
+	return problems, nil
+}
+
+// fsckProfile checks one profile. hashed caches the result of hashing each
+// blob, which profiles commonly share.
+func fsckProfile(t Tool, p string, now time.Time, hashed map[string]error) ([]FsckProblem, error) {
+	var problems []FsckProblem
+	dir, err := t.userProfileDir(p)
+	if err != nil {
+		return nil, err
+	}
+
+	m, err := readManifestFile(filepath.Join(dir, manifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(m.Files))
+	for name := range m.Files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		hash := m.Files[name]
+		checked, ok := hashed[hash]
+		if !ok {
+			checked = checkBlob(t, hash)
+			hashed[hash] = checked
+		}
+		if checked != nil {
+			problems = append(problems, FsckProblem{
+				Class:   FsckHash,
+				Profile: p,
+				File:    name,
+				Message: fmt.Sprintf("profile %q: %s: %v", p, name, checked),
+			})
+		}
+	}
+
+	expected := map[string]bool{manifestFileName: true}
+	for _, name := range storedFileNames(t) {
+		expected[name] = true
+		if name != metaFileName {
+			expected[name+gzipSuffix] = true
+		}
+		if name == metaFileName {
+			continue
+		}
+		path, err := t.resolveProfileFile(p, name)
+		if err != nil {
+			return nil, err
+		}
+		// Blobs are checked by the profile whose manifest lists them.
+		if _, ok := t.blobHash(path); ok {
+			continue
+		}
+		if exists, err := ensureRegularFileIfExists(path); err != nil {
+			return nil, err
+		} else if !exists {
+			problems = append(problems, FsckProblem{
+				Class:   FsckMissing,
+				Profile: p,
+				File:    name,
+				Message: fmt.Sprintf("profile %q has no %s", p, name),
+			})
+		}
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if expected[name] && !entry.IsDir() {
+			// Reads take the plain payload, so a compressed copy next to it
+			// is never used.
+			if base, ok := strings.CutSuffix(name, gzipSuffix); ok && containsEntry(entries, base) {
+				problems = append(problems, FsckProblem{
+					Class:   FsckExtra,
+					Profile: p,
+					File:    name,
+					Message: fmt.Sprintf("profile %q has both %s and %s", p, base, name),
+				})
+			}
+			continue
+		}
+		if strings.HasPrefix(name, ".") {
+			info, err := entry.Info()
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+			if now.Sub(info.ModTime()) < staleTempAge {
+				continue
+			}
+		}
+		problems = append(problems, FsckProblem{
+			Class:   FsckExtra,
+			Profile: p,
+			File:    name,
+			Message: fmt.Sprintf("profile %q has unexpected file %s", p, name),
+		})
+	}
+	return problems, nil
+}
+
+func containsEntry(entries []fs.DirEntry, name string) bool {
+	return slices.ContainsFunc(entries, func(e fs.DirEntry) bool { return e.Name() == name })
+}
+
+// checkBlob returns why the blob for hash cannot be trusted, or nil.
+func checkBlob(t Tool, hash string) error {
+	path, err := t.blobPath(hash)
+	if err != nil {
+		return err
+	}
+	if exists, err := ensureRegularFileIfExists(path); err != nil {
+		return err
+	} else if !exists {
+		return fmt.Errorf("blob %s is missing", hash)
+	}
+	got, err := storedHash(path)
+	if err != nil {
+		return err
+	}
+	if got != hash {
+		return fmt.Errorf("blob %s holds content hashing to %s", hash, got)
+	}
+	return nil
+}
+
+// RepairFsck repairs the problems of the given classes among problems, as
+// Fsck returned them, and returns the ones it repaired. A profile is moved
+// to the trash at most once, and further problems in it count as repaired
+// with it. Problems that fail to repair are skipped and their errors
+// returned together.
+func RepairFsck(t Tool, problems []FsckProblem, classes []FsckClass) ([]FsckProblem, error) {
+	var repaired []FsckProblem
+	var errs []error
+	trashed := map[string]bool{}
+	for _, p := range problems {
+		if !slices.Contains(classes, p.Class) {
+			continue
+		}
+		if p.Profile != "" && trashed[p.Profile] {
+			repaired = append(repaired, p)
+			continue
+		}
+		var err error
+		switch p.Class {
+		case FsckMissing, FsckHash:
+			t.logger().Debug("trashing broken profile", "profile", p.Profile, "problem", p.Message)
+			_, err = Delete(t, p.Profile)
+			if err == nil {
+				trashed[p.Profile] = true
+			}
+		case FsckExtra:
+			var dir string
+			if dir, err = t.userProfileDir(p.Profile); err == nil {
+				err = os.RemoveAll(filepath.Join(dir, p.File))
+			}
+		case FsckCurrent:
+			err = writeCurrentState(t, "")
+		default:
+			err = fmt.Errorf("unknown problem class %q", p.Class)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("repair %s: %w", p, err))
+			continue
+		}
+		repaired = append(repaired, p)
+	}
+	return repaired, errors.Join(errs...)
+}
@@ -0,0 +1,134 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestFsckFindsAndRepairsProblems(t *testing.T) {
+	tool, _ := setupClaudeHome(t)
+	for _, name := range []string{"good", "broken", "cluttered", "gone"} {
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := Switch(tool, "gone"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	profilesDir, err := tool.profilesDir()
+	if err != nil {
+		t.Fatalf("profilesDir: %v", err)
+	}
+	if problems, err := Fsck(tool, time.Now()); err != nil || len(problems) != 0 {
+		t.Fatalf("Fsck on a clean store = %v, %v", problems, err)
+	}
+
+	// Removed behind tokyo's back, leaving current.json pointing at it.
+	if err := os.RemoveAll(filepath.Join(profilesDir, "gone")); err != nil {
+		t.Fatalf("remove gone: %v", err)
+	}
+	if err := os.Remove(filepath.Join(profilesDir, "broken", "settings.json")); err != nil {
+		t.Fatalf("remove settings.json: %v", err)
+	}
+	cluttered := filepath.Join(profilesDir, "cluttered")
+	for _, name := range []string{"notes.txt", ".tokyo-old", ".tokyo-new", "settings.json.gz"} {
+		if err := os.WriteFile(filepath.Join(cluttered, name), []byte("x"), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	old := time.Now().Add(-2 * staleTempAge)
+	if err := os.Chtimes(filepath.Join(cluttered, ".tokyo-old"), old, old); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+
+	problems, err := Fsck(tool, time.Now())
+	if err != nil {
+		t.Fatalf("Fsck: %v", err)
+	}
+	var got []string
+	for _, p := range problems {
+		got = append(got, string(p.Class)+" "+p.Profile+" "+p.File)
+	}
+	want := []string{
+		"missing broken settings.json",
+		"extra cluttered .tokyo-old",
+		"extra cluttered notes.txt",
+		"extra cluttered settings.json.gz",
+		"current  ",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("Fsck = %q, want %q", got, want)
+	}
+
+	repaired, err := RepairFsck(tool, problems, []FsckClass{FsckExtra, FsckCurrent})
+	if err != nil {
+		t.Fatalf("RepairFsck: %v", err)
+	}
+	if len(repaired) != 4 {
+		t.Fatalf("repaired %d problems, want 4", len(repaired))
+	}
+	if problems, err = Fsck(tool, time.Now()); err != nil || len(problems) != 1 || problems[0].Class != FsckMissing {
+		t.Fatalf("Fsck after repairing extra and current = %v, %v", problems, err)
+	}
+	if _, err := os.Stat(filepath.Join(cluttered, ".tokyo-new")); err != nil {
+		t.Fatalf("a write in progress was removed: %v", err)
+	}
+	if active, _ := ActiveProfile(tool); active != "" {
+		t.Fatalf("active = %q, want none", active)
+	}
+
+	if _, err := RepairFsck(tool, problems, FsckClasses); err != nil {
+		t.Fatalf("RepairFsck missing: %v", err)
+	}
+	if exists, _ := Exists(tool, "broken"); exists {
+		t.Fatalf("expected the broken profile moved to the trash")
+	}
+	if problems, err = Fsck(tool, time.Now()); err != nil || len(problems) != 0 {
+		t.Fatalf("Fsck after repair = %v, %v", problems, err)
+	}
+}
+
+func TestFsckChecksBlobHashes(t *testing.T) {
+	tool, _ := setupClaudeHome(t)
+	if err := EnableContentAddressing(tool); err != nil {
+		t.Fatalf("EnableContentAddressing: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	m, err := readManifest(tool, "work")
+	if err != nil {
+		t.Fatalf("readManifest: %v", err)
+	}
+	blob, err := tool.blobPath(m.Files["settings.json"])
+	if err != nil {
+		t.Fatalf("blobPath: %v", err)
+	}
+	if err := os.WriteFile(blob, []byte(`{"tampered":true}`), 0o600); err != nil {
+		t.Fatalf("corrupt blob: %v", err)
+	}
+
+	problems, err := Fsck(tool, time.Now())
+	if err != nil {
+		t.Fatalf("Fsck: %v", err)
+	}
+	if len(problems) != 1 || problems[0].Class != FsckHash || problems[0].Profile != "work" {
+		t.Fatalf("Fsck = %v, want one hash problem in work", problems)
+	}
+
+	if err := os.Remove(blob); err != nil {
+		t.Fatalf("remove blob: %v", err)
+	}
+	if problems, err = Fsck(tool, time.Now()); err != nil || len(problems) != 1 || problems[0].Class != FsckHash {
+		t.Fatalf("Fsck with the blob gone = %v, %v", problems, err)
+	}
+	if _, err := RepairFsck(tool, problems, []FsckClass{FsckHash}); err != nil {
+		t.Fatalf("RepairFsck: %v", err)
+	}
+	if exists, _ := Exists(tool, "work"); exists {
+		t.Fatalf("expected the profile moved to the trash")
+	}
+}
@@ -0,0 +1,90 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzValidateProfileName(f *testing.F) {
+	for _, seed := range []string{
+		"work", "my-profile_2", "", " ", "..", ".", "../etc", "a/b", `a\b`,
+		"<custom>", "work (modified)", ".hidden", "\x00", "仕事", "é",
+		strings.Repeat("a", 64), strings.Repeat("a", 65), "\xff\xfe",
+	} {
+		f.Add(seed)
+	}
+	profilesDir := filepath.Join("store", "profiles")
+	f.Fuzz(func(t *testing.T, name string) {
+		if err := ValidateProfileName(name); err != nil {
+			return
+		}
+		if !utf8.ValidString(name) {
+			t.Fatalf("accepted invalid UTF-8 %q", name)
+		}
+		if strings.ContainsAny(name, `/\`) || strings.ContainsRune(name, 0) {
+			t.Fatalf("accepted a separator or NUL in %q", name)
+		}
+		if dir := filepath.Join(profilesDir, name); filepath.Dir(dir) != profilesDir {
+			t.Fatalf("profile %q resolves to %s, outside %s", name, dir, profilesDir)
+		}
+		if name == "<custom>" || strings.HasSuffix(name, " (modified)") {
+			t.Fatalf("accepted a name Current prints for other states: %q", name)
+		}
+	})
+}
+
+func FuzzReadCurrentProfile(f *testing.F) {
+	for _, seed := range []string{
+		`{"profile":"work"}`, `{"profile":""}`, `{}`, `null`, ``, `{`, `[]`,
+		`{"profile":"../../etc"}`, `{"profile":1}`, `{"profile":"` + strings.Repeat("x", 1<<16) + `"}`,
+		`{"profile":"\u0000"}`, `{"profile":"work","profile":"personal"}`, "\xef\xbb\xbf{}",
+	} {
+		f.Add([]byte(seed))
+	}
+	tool := ClaudeTool().WithHome(f.TempDir())
+	path, err := tool.currentFile()
+	if err != nil {
+		f.Fatalf("currentFile: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		f.Fatalf("mkdir: %v", err)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := os.WriteFile(path, data, 0o600); err != nil {
+			t.Fatalf("write current.json: %v", err)
+		}
+		profile, err := readCurrentProfile(tool)
+		if err != nil || profile == "" {
+			return
+		}
+		if err := ValidateProfileName(profile); err != nil {
+			t.Fatalf("returned invalid profile %q: %v", profile, err)
+		}
+		if err := writeCurrentProfile(tool, profile); err != nil {
+			t.Fatalf("writeCurrentProfile: %v", err)
+		}
+		if again, err := readCurrentProfile(tool); err != nil || again != profile {
+			t.Fatalf("round trip = %q, %v; want %q", again, err, profile)
+		}
+	})
+}
+
+func TestReadCurrentProfileRejectsInvalidName(t *testing.T) {
+	tool := ClaudeTool().WithHome(t.TempDir())
+	path, err := tool.currentFile()
+	if err != nil {
+		t.Fatalf("currentFile: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(`{"profile":"../../outside"}`), 0o600); err != nil {
+		t.Fatalf("write current.json: %v", err)
+	}
+	if profile, err := readCurrentProfile(tool); err == nil {
+		t.Fatalf("expected an error, got profile %q", profile)
+	}
+}
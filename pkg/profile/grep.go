@@ -0,0 +1,72 @@
+package profile
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+)
+
+// GrepMatch is a line in a stored profile file that matched a search.
+type GrepMatch struct {
+	Profile string `json:"profile"`
+	File    string `json:"file"`
+	Line    int    `json:"line"`
+	// Text is the matching line with credential-like values redacted.
+	Text string `json:"text"`
+}
+
+// Grep searches the stored files of every profile for lines matching re.
+// Matching runs on the raw content; returned text is redacted.
+func Grep(t Tool, re *regexp.Regexp) ([]GrepMatch, error) {
+	profiles, err := List(t)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []GrepMatch
+	for _, p := range profiles {
+		pairs, err := profilePairs(t, p)
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range pairs {
+			found, err := grepFile(pair.src, filepath.Base(pair.dst), re)
+			if err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					continue
+				}
+				return nil, err
+			}
+			for i := range found {
+				found[i].Profile = p
+			}
+			matches = append(matches, found...)
+		}
+	}
+	return matches, nil
+}
+
+func grepFile(path, name string, re *regexp.Regexp) ([]GrepMatch, error) {
+	file, err := openStored(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var matches []GrepMatch
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := scanner.Text()
+		if re.MatchString(line) {
+			matches = append(matches, GrepMatch{
+				File: name,
+				Line: n,
+				Text: Redact(line),
+			})
+		}
+	}
+	return matches, scanner.Err()
+}
@@ -0,0 +1,66 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// SetHardLinks turns hard-link deduplication of saved files on or off.
+// Turning it off leaves existing links in place; they are ordinary files
+// that happen to share their data until a profile is saved again. It cannot
+// be combined with symlink switching, where the tool edits stored files in
+// place and an edit would reach every profile sharing the file.
+func SetHardLinks(t Tool, on bool) error {
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return err
+	}
+	if on && settings.SwitchMode == SwitchModeSymlink {
+		return errors.New("hard links cannot be combined with symlink switching; run 'store switch-mode copy' first")
+	}
+	settings.HardLinks = on
+	return writeStoreSettings(t, settings)
+}
+
+// linkIdenticalFile replaces path, a payload just written for a profile being
+// saved, with a hard link to an identical payload of the same name in another
+// profile, if there is one. Stored files are only ever replaced by rename, so
+// profiles sharing a file never see each other's changes. Where hard links
+// are unavailable the copy is kept.
+func linkIdenticalFile(t Tool, path string) error {
+	profiles, err := listUserProfiles(t)
+	if err != nil {
+		return err
+	}
+	name := filepath.Base(path)
+	for _, p := range profiles {
+		candidate, err := t.profileFile(p, name)
+		if err != nil {
+			return err
+		}
+		exists, err := ensureRegularFileIfExists(candidate)
+		if err != nil || !exists {
+			continue
+		}
+		same, err := filesEqual(candidate, path)
+		if err != nil {
+			return err
+		}
+		if !same {
+			continue
+		}
+		tmp := path + ".link"
+		if err := os.Link(candidate, tmp); err != nil {
+			t.logger().Debug("hard link failed, keeping copy", "src", candidate, "dst", path, "err", err)
+			return nil
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			os.Remove(tmp)
+			return err
+		}
+		t.logger().Debug("hard linked", "src", candidate, "dst", path)
+		return nil
+	}
+	return nil
+}
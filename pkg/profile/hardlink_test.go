@@ -0,0 +1,82 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func sameStoredFile(t *testing.T, tool Tool, a, b, name string) bool {
+	t.Helper()
+	var infos []os.FileInfo
+	for _, p := range []string{a, b} {
+		path, err := tool.profileFile(p, name)
+		if err != nil {
+			t.Fatalf("profileFile: %v", err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", path, err)
+		}
+		infos = append(infos, info)
+	}
+	return os.SameFile(infos[0], infos[1])
+}
+
+func TestHardLinkIdenticalFiles(t *testing.T) {
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := SetHardLinks(tool, true); err != nil {
+		t.Fatalf("SetHardLinks: %v", err)
+	}
+
+	writeCodexFiles(t, codexDir, "work")
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "config.toml"), []byte("model = \"personal\"\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+	if !sameStoredFile(t, tool, "work", "personal", "auth.json") {
+		t.Fatal("expected the identical auth.json to be hard-linked")
+	}
+	if sameStoredFile(t, tool, "work", "personal", "config.toml") {
+		t.Fatal("expected the differing config.toml to be stored separately")
+	}
+
+	// Saving over one profile leaves the other's copy alone.
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(`{"token":"new"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if err := Save(tool, "personal", true); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	assertLive(t, codexDir, "work")
+}
+
+func TestHardLinksExcludeSymlinkMode(t *testing.T) {
+	tool, _ := setupCodexProfiles(t)
+	enableSymlinkMode(t, tool)
+	if err := SetHardLinks(tool, true); err == nil {
+		t.Fatal("expected hard links to be refused in symlink mode")
+	}
+	if err := SetSwitchMode(tool, SwitchModeCopy); err != nil {
+		t.Fatalf("SetSwitchMode: %v", err)
+	}
+	if err := SetHardLinks(tool, true); err != nil {
+		t.Fatalf("SetHardLinks: %v", err)
+	}
+	if err := SetSwitchMode(tool, SwitchModeSymlink); err == nil {
+		t.Fatal("expected symlink mode to be refused with hard links on")
+	}
+}
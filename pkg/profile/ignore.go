@@ -0,0 +1,132 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ErrInvalidIgnoreRule is returned for ignore rules that cannot be parsed.
+var ErrInvalidIgnoreRule = errors.New("invalid ignore rule")
+
+// ignoreRegexPrefix marks an ignore rule as a regular expression matched
+// against each line of a config file. Other rules are JSON pointers.
+const ignoreRegexPrefix = "re:"
+
+// ignoreRules are parsed ignore rules. Content they match is dropped from
+// both the live and the stored file before comparing them, so fields a tool
+// rewrites on its own do not make the config count as modified.
+type ignoreRules struct {
+	pointers [][]string
+	lines    []*regexp.Regexp
+}
+
+func (r ignoreRules) empty() bool {
+	return len(r.pointers) == 0 && len(r.lines) == 0
+}
+
+func parseIgnoreRule(rule string, rules *ignoreRules) error {
+	if expr, ok := strings.CutPrefix(rule, ignoreRegexPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return fmt.Errorf("%w: %q: %v", ErrInvalidIgnoreRule, rule, err)
+		}
+		rules.lines = append(rules.lines, re)
+		return nil
+	}
+	if !strings.HasPrefix(rule, "/") || rule == "/" {
+		return fmt.Errorf("%w: %q (use a JSON pointer such as /feedbackSurveyState or re:<regexp>)", ErrInvalidIgnoreRule, rule)
+	}
+	var tokens []string
+	for _, token := range strings.Split(rule[1:], "/") {
+		token = strings.ReplaceAll(token, "~1", "/")
+		tokens = append(tokens, strings.ReplaceAll(token, "~0", "~"))
+	}
+	rules.pointers = append(rules.pointers, tokens)
+	return nil
+}
+
+// IgnoreRules returns the ignore rules applied to t: the tool's built-in
+// rules followed by those added to its store.
+func IgnoreRules(t Tool) ([]string, error) {
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return nil, err
+	}
+	return append(slices.Clone(t.Ignore), settings.Ignore...), nil
+}
+
+func (t Tool) ignoreRules(settings StoreSettings) (ignoreRules, error) {
+	var rules ignoreRules
+	for _, rule := range append(slices.Clone(t.Ignore), settings.Ignore...) {
+		if err := parseIgnoreRule(rule, &rules); err != nil {
+			return ignoreRules{}, err
+		}
+	}
+	return rules, nil
+}
+
+// AddIgnoreRule adds rule to the store of t. A rule starting with "re:" is a
+// regular expression for lines to ignore in any config file; any other rule
+// is a JSON pointer (RFC 6901) to a value to ignore in JSON config files.
+func AddIgnoreRule(t Tool, rule string) error {
+	if err := parseIgnoreRule(rule, &ignoreRules{}); err != nil {
+		return err
+	}
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(t.Ignore, rule) || slices.Contains(settings.Ignore, rule) {
+		return nil
+	}
+	settings.Ignore = append(settings.Ignore, rule)
+	return writeStoreSettings(t, settings)
+}
+
+// RemoveIgnoreRule removes rule from the store of t. Built-in rules cannot be
+// removed.
+func RemoveIgnoreRule(t Tool, rule string) error {
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return err
+	}
+	i := slices.Index(settings.Ignore, rule)
+	if i < 0 {
+		if slices.Contains(t.Ignore, rule) {
+			return fmt.Errorf("%w: %q is built in", ErrInvalidIgnoreRule, rule)
+		}
+		return fmt.Errorf("%w: %q is not set", ErrInvalidIgnoreRule, rule)
+	}
+	settings.Ignore = slices.Delete(settings.Ignore, i, i+1)
+	return writeStoreSettings(t, settings)
+}
+
+// removePointer deletes the value at pointer from doc, if present.
+func removePointer(doc any, pointer []string) any {
+	if len(pointer) == 0 {
+		return doc
+	}
+	key, rest := pointer[0], pointer[1:]
+	switch v := doc.(type) {
+	case map[string]any:
+		if len(rest) == 0 {
+			delete(v, key)
+		} else if child, ok := v[key]; ok {
+			v[key] = removePointer(child, rest)
+		}
+	case []any:
+		i, err := strconv.Atoi(key)
+		if err != nil || i < 0 || i >= len(v) {
+			return doc
+		}
+		if len(rest) == 0 {
+			return slices.Delete(v, i, i+1)
+		}
+		v[i] = removePointer(v[i], rest)
+	}
+	return doc
+}
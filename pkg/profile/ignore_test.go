@@ -0,0 +1,150 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestBuiltInIgnoreRuleKeepsStatusClean(t *testing.T) {
+	home := t.TempDir()
+	tool := ClaudeTool().WithHome(home)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"opus","feedbackSurveyState":{"lastShown":1}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	// The tool rewrites the survey state and reformats the file.
+	if err := os.WriteFile(configPath, []byte("{\n  \"feedbackSurveyState\": {\"lastShown\": 2},\n  \"model\": \"opus\"\n}\n"), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work" {
+		t.Fatalf("expected ignored churn to keep status clean, got %q", status)
+	}
+	diffs, err := Diff(tool, "work")
+	if err != nil {
+		t.Fatalf("Diff: %v", err)
+	}
+	if diffs[0].Status != FileUnchanged {
+		t.Fatalf("expected diff to report unchanged, got %+v", diffs[0])
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"model":"sonnet","feedbackSurveyState":{"lastShown":3}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	status, err = Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work (modified)" {
+		t.Fatalf("expected real change to count, got %q", status)
+	}
+}
+
+func TestStoreIgnoreRules(t *testing.T) {
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(codexDir, "config.toml")
+	authPath := filepath.Join(codexDir, "auth.json")
+	if err := os.WriteFile(configPath, []byte("model = \"o3\"\nlast_used = 1\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"a","tokens":{"refreshed":1}}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte("model = \"o3\"\nlast_used = 2\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"a","tokens":{"refreshed":2}}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work (modified)" {
+		t.Fatalf("expected modified without rules, got %q", status)
+	}
+
+	for _, rule := range []string{`re:^last_used\s*=`, "/tokens/refreshed"} {
+		if err := AddIgnoreRule(tool, rule); err != nil {
+			t.Fatalf("AddIgnoreRule %s: %v", rule, err)
+		}
+	}
+	status, err = Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work" {
+		t.Fatalf("expected ignore rules to apply, got %q", status)
+	}
+
+	if err := RemoveIgnoreRule(tool, "/tokens/refreshed"); err != nil {
+		t.Fatalf("RemoveIgnoreRule: %v", err)
+	}
+	rules, err := IgnoreRules(tool)
+	if err != nil {
+		t.Fatalf("IgnoreRules: %v", err)
+	}
+	if len(rules) != 1 || rules[0] != `re:^last_used\s*=` {
+		t.Fatalf("unexpected rules: %v", rules)
+	}
+	status, err = Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work (modified)" {
+		t.Fatalf("expected removed rule to stop applying, got %q", status)
+	}
+}
+
+func TestAddIgnoreRuleRejectsInvalidRules(t *testing.T) {
+	tool := ClaudeTool().WithHome(t.TempDir())
+	for _, rule := range []string{"", "model", "/", "re:("} {
+		if err := AddIgnoreRule(tool, rule); !errors.Is(err, ErrInvalidIgnoreRule) {
+			t.Fatalf("AddIgnoreRule(%q): expected ErrInvalidIgnoreRule, got %v", rule, err)
+		}
+	}
+	if err := RemoveIgnoreRule(tool, "/feedbackSurveyState"); !errors.Is(err, ErrInvalidIgnoreRule) {
+		t.Fatalf("expected built-in rule removal to fail, got %v", err)
+	}
+}
+
+func TestRemovePointer(t *testing.T) {
+	rules := ignoreRules{}
+	for _, rule := range []string{"/a~1b", "/list/1", "/missing/deep"} {
+		if err := parseIgnoreRule(rule, &rules); err != nil {
+			t.Fatalf("parseIgnoreRule: %v", err)
+		}
+	}
+	got := string(comparer{ignore: rules}.normalize([]byte(`{"a/b":1,"list":[1,2,3],"keep":true}`)))
+	want := "{\n  \"keep\": true,\n  \"list\": [\n    1,\n    3\n  ]\n}"
+	if got != want {
+		t.Fatalf("normalize = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,230 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Layouts of other profile switchers that ImportFrom reads.
+const (
+	// SourceCCSwitch is the config.json of cc-switch: providers for Claude
+	// Code (and, in newer versions, Codex), each holding the settings to
+	// write.
+	SourceCCSwitch = "ccswitch"
+	// SourceClaudeProfiles is a directory with one subdirectory per
+	// profile, holding copies of the tool's config files.
+	SourceClaudeProfiles = "claude-profiles"
+)
+
+// ErrUnknownSource is returned by ImportFrom for a layout it cannot read.
+var ErrUnknownSource = errors.New("unknown import source")
+
+// ImportSources lists the layouts ImportFrom reads.
+func ImportSources() []string {
+	return []string{SourceCCSwitch, SourceClaudeProfiles}
+}
+
+// ImportFrom converts the profiles another switcher keeps in dir into
+// profiles of t, with the same rules for existing profiles as Import. It
+// returns the imported profile names, which are empty when dir holds no
+// profiles for t.
+func ImportFrom(t Tool, source, dir string, force bool) ([]string, error) {
+	var files map[string]map[string][]byte
+	var err error
+	switch source {
+	case SourceCCSwitch:
+		files, err = readCCSwitch(t, dir)
+	case SourceClaudeProfiles:
+		files, err = readProfileDirs(t, dir)
+	default:
+		return nil, fmt.Errorf("%w: %q (supported: %s)", ErrUnknownSource, source, strings.Join(ImportSources(), ", "))
+	}
+	if err != nil || len(files) == 0 {
+		return nil, err
+	}
+	return writeProfiles(t, files, force)
+}
+
+type ccSwitchProvider struct {
+	ID             string          `json:"id"`
+	Name           string          `json:"name"`
+	SettingsConfig json.RawMessage `json:"settingsConfig"`
+}
+
+type ccSwitchApp struct {
+	Providers map[string]ccSwitchProvider `json:"providers"`
+}
+
+// ccSwitchConfig covers both config.json versions: the first kept Claude
+// Code providers at the top level, the second one section per app.
+type ccSwitchConfig struct {
+	ccSwitchApp
+	Claude *ccSwitchApp `json:"claude"`
+	Codex  *ccSwitchApp `json:"codex"`
+}
+
+func readCCSwitch(t Tool, dir string) (map[string]map[string][]byte, error) {
+	path := filepath.Join(dir, "config.json")
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	var cfg ccSwitchConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	var app *ccSwitchApp
+	switch t.Name {
+	case "claude":
+		app = cfg.Claude
+		if app == nil {
+			app = &cfg.ccSwitchApp
+		}
+	case "codex":
+		app = cfg.Codex
+	}
+	if app == nil {
+		return nil, nil
+	}
+
+	ids := make([]string, 0, len(app.Providers))
+	for id := range app.Providers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	files := map[string]map[string][]byte{}
+	for _, id := range ids {
+		provider := app.Providers[id]
+		converted, err := convertCCSwitchProvider(t, provider.SettingsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("%s: provider %q: %w", path, provider.Name, err)
+		}
+		name := provider.Name
+		if name == "" {
+			name = id
+		}
+		files[uniqueProfileName(importedProfileName(name), files)] = converted
+	}
+	return files, nil
+}
+
+// convertCCSwitchProvider turns a provider's settingsConfig into config
+// files: the settings.json object for Claude Code, or for Codex an object
+// holding the auth.json object and the config.toml text.
+func convertCCSwitchProvider(t Tool, settings json.RawMessage) (map[string][]byte, error) {
+	switch t.Name {
+	case "claude":
+		data, err := indentJSON(settings)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"settings.json": data}, nil
+	case "codex":
+		var codex struct {
+			Auth   json.RawMessage `json:"auth"`
+			Config string          `json:"config"`
+		}
+		if err := json.Unmarshal(settings, &codex); err != nil {
+			return nil, err
+		}
+		auth, err := indentJSON(codex.Auth)
+		if err != nil {
+			return nil, err
+		}
+		return map[string][]byte{"auth.json": auth, "config.toml": []byte(codex.Config)}, nil
+	}
+	return nil, fmt.Errorf("cc-switch has no profiles for %s", t.DisplayName)
+}
+
+func indentJSON(raw json.RawMessage) ([]byte, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		raw = json.RawMessage("{}")
+	}
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return nil, err
+	}
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
+
+// readProfileDirs reads every subdirectory of dir holding all of t's config
+// files, by base name, as a profile named after the subdirectory.
+func readProfileDirs(t Tool, dir string) (map[string]map[string][]byte, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	files := map[string]map[string][]byte{}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		profileFiles := map[string][]byte{}
+		for _, rel := range t.ConfigRelPaths {
+			name := filepath.Base(rel)
+			path := filepath.Join(dir, e.Name(), name)
+			if err := ensureRegularFile(path); err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					profileFiles = nil
+					break
+				}
+				return nil, err
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("read %s: %w", path, err)
+			}
+			profileFiles[name] = data
+		}
+		if profileFiles != nil {
+			files[uniqueProfileName(importedProfileName(e.Name()), files)] = profileFiles
+		}
+	}
+	return files, nil
+}
+
+// importedProfileName turns a name from another tool into a valid profile
+// name, replacing each run of unsupported characters with "-".
+func importedProfileName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash {
+			b.WriteRune('-')
+			dash = true
+		}
+	}
+	converted := strings.Trim(b.String(), "-")
+	if len(converted) > 60 {
+		converted = converted[:60]
+	}
+	if converted == "" {
+		return "imported"
+	}
+	return converted
+}
+
+// uniqueProfileName appends -2, -3, ... to name until no entry of taken uses
+// it.
+func uniqueProfileName(name string, taken map[string]map[string][]byte) string {
+	candidate := name
+	for i := 2; ; i++ {
+		if _, ok := taken[candidate]; !ok {
+			return candidate
+		}
+		candidate = fmt.Sprintf("%s-%d", name, i)
+	}
+}
@@ -0,0 +1,118 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestImportFromCCSwitch(t *testing.T) {
+	home := t.TempDir()
+	src := t.TempDir()
+	config := `{
+  "version": 2,
+  "claude": {
+    "providers": {
+      "a1": {"id": "a1", "name": "Work (Anthropic)", "settingsConfig": {"env": {"ANTHROPIC_BASE_URL": "https://api.example.com?a=1&b=2"}}},
+      "b2": {"id": "b2", "name": "Work (Anthropic)", "settingsConfig": {"model": "opus"}}
+    },
+    "current": "a1"
+  },
+  "codex": {
+    "providers": {
+      "c3": {"id": "c3", "name": "personal", "settingsConfig": {"auth": {"OPENAI_API_KEY": "sk-1"}, "config": "model = \"o3\"\n"}}
+    }
+  }
+}`
+	if err := os.WriteFile(filepath.Join(src, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatalf("write config.json: %v", err)
+	}
+
+	claude := ClaudeTool().WithHome(home)
+	imported, err := ImportFrom(claude, SourceCCSwitch, src, false)
+	if err != nil {
+		t.Fatalf("ImportFrom claude: %v", err)
+	}
+	if !slices.Equal(imported, []string{"Work-Anthropic", "Work-Anthropic-2"}) {
+		t.Fatalf("unexpected claude profiles: %v", imported)
+	}
+	data, err := os.ReadFile(filepath.Join(home, ".config", "tokyo", "claude", "profiles", "Work-Anthropic", "settings.json"))
+	if err != nil {
+		t.Fatalf("read imported settings.json: %v", err)
+	}
+	want := "{\n  \"env\": {\n    \"ANTHROPIC_BASE_URL\": \"https://api.example.com?a=1&b=2\"\n  }\n}\n"
+	if string(data) != want {
+		t.Fatalf("settings.json = %q, want %q", data, want)
+	}
+
+	codex := CodexTool().WithHome(home)
+	imported, err = ImportFrom(codex, SourceCCSwitch, src, false)
+	if err != nil {
+		t.Fatalf("ImportFrom codex: %v", err)
+	}
+	if !slices.Equal(imported, []string{"personal"}) {
+		t.Fatalf("unexpected codex profiles: %v", imported)
+	}
+	if err := Switch(codex, "personal"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(home, ".codex", "config.toml"))
+	if err != nil || string(data) != "model = \"o3\"\n" {
+		t.Fatalf("config.toml = %q, %v", data, err)
+	}
+
+	if _, err := ImportFrom(claude, SourceCCSwitch, src, false); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("expected ErrProfileAlreadyExists on re-import, got %v", err)
+	}
+}
+
+func TestImportFromCCSwitchFirstVersion(t *testing.T) {
+	src := t.TempDir()
+	config := `{"providers": {"x": {"id": "x", "name": "team", "settingsConfig": {"model": "sonnet"}}}, "current": "x"}`
+	if err := os.WriteFile(filepath.Join(src, "config.json"), []byte(config), 0o600); err != nil {
+		t.Fatalf("write config.json: %v", err)
+	}
+	home := t.TempDir()
+	imported, err := ImportFrom(ClaudeTool().WithHome(home), SourceCCSwitch, src, false)
+	if err != nil || !slices.Equal(imported, []string{"team"}) {
+		t.Fatalf("ImportFrom claude = %v, %v", imported, err)
+	}
+	imported, err = ImportFrom(CodexTool().WithHome(home), SourceCCSwitch, src, false)
+	if err != nil || len(imported) != 0 {
+		t.Fatalf("expected no codex profiles, got %v, %v", imported, err)
+	}
+}
+
+func TestImportFromProfileDirs(t *testing.T) {
+	src := t.TempDir()
+	for name, files := range map[string][]string{
+		"work":       {"settings.json"},
+		"codex only": {"config.toml", "auth.json"},
+	} {
+		for _, file := range files {
+			path := filepath.Join(src, name, file)
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			if err := os.WriteFile(path, []byte(`{}`), 0o600); err != nil {
+				t.Fatalf("write %s: %v", path, err)
+			}
+		}
+	}
+
+	home := t.TempDir()
+	imported, err := ImportFrom(ClaudeTool().WithHome(home), SourceClaudeProfiles, src, false)
+	if err != nil || !slices.Equal(imported, []string{"work"}) {
+		t.Fatalf("ImportFrom claude = %v, %v", imported, err)
+	}
+	imported, err = ImportFrom(CodexTool().WithHome(home), SourceClaudeProfiles, src, false)
+	if err != nil || !slices.Equal(imported, []string{"codex-only"}) {
+		t.Fatalf("ImportFrom codex = %v, %v", imported, err)
+	}
+
+	if _, err := ImportFrom(ClaudeTool().WithHome(home), "nope", src, false); !errors.Is(err, ErrUnknownSource) {
+		t.Fatalf("expected ErrUnknownSource, got %v", err)
+	}
+}
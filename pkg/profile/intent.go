@@ -0,0 +1,139 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// intentFileName is the file next to current.json naming the profile the
+// last lasting switch made active. Unlike current.json, it is not changed by
+// temporary switches, so it tells what the machine should be on after one
+// was cut short.
+const intentFileName = "intent.json"
+
+type intentState struct {
+	Profile string `json:"profile"`
+}
+
+func (t Tool) intentFile() (string, error) {
+	base, err := t.tokyoDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, intentFileName), nil
+}
+
+// RecordIntent records profile as the one t is meant to be on; see
+// RestoreIntent.
+func RecordIntent(t Tool, profile string) error {
+	if err := ValidateProfileName(profile); err != nil {
+		return err
+	}
+	path, err := t.intentFile()
+	if err != nil {
+		return err
+	}
+	if err := ensureParentDir(path); err != nil {
+		return err
+	}
+	if err := rejectNonRegularFile(path); err != nil {
+		return err
+	}
+	data, err := json.Marshal(intentState{Profile: profile})
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o600)
+}
+
+// Intent returns the profile recorded by RecordIntent, or "" when there is
+// none.
+func Intent(t Tool) (string, error) {
+	path, err := t.intentFile()
+	if err != nil {
+		return "", err
+	}
+	if err := rejectNonRegularFile(path); err != nil {
+		return "", err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", nil
+		}
+		return "", err
+	}
+	var state intentState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return "", fmt.Errorf("parse %s: %w", path, err)
+	}
+	// The name ends up in paths, as with current.json.
+	if state.Profile != "" {
+		if err := ValidateProfileName(state.Profile); err != nil {
+			return "", fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	return state.Profile, nil
+}
+
+// renameIntent makes the intent follow the rename of from to to.
+func renameIntent(t Tool, from, to string) error {
+	intended, err := Intent(t)
+	if err != nil || intended != from {
+		return err
+	}
+	return RecordIntent(t, to)
+}
+
+// forgetIntent drops the intent when it names profile, which is being
+// deleted, so a later profile of the same name is not switched to.
+func forgetIntent(t Tool, profile string) error {
+	intended, err := Intent(t)
+	if err != nil || intended != profile {
+		return err
+	}
+	path, err := t.intentFile()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// RestoreIntent switches t back to the profile it is meant to be on when
+// another one is active, as a temporary switch that was never undone leaves
+// it. It returns the profile switched to, or "" when nothing was done.
+// Changes to the live config are autosaved first. A time-boxed switch that
+// is still running is left to end on its own (see SwitchFor), and an intent
+// whose profile has since been deleted is ignored.
+func RestoreIntent(t Tool) (string, error) {
+	intended, err := Intent(t)
+	if err != nil || intended == "" {
+		return "", err
+	}
+	exists, err := Exists(t, intended)
+	if err != nil || !exists {
+		return "", err
+	}
+	active, err := ActiveProfile(t)
+	if err != nil || active == intended {
+		return "", err
+	}
+	if r, ok, err := PendingRevert(t); err != nil {
+		return "", err
+	} else if ok && r.From == active {
+		return "", nil
+	}
+	t.logger().Debug("restoring intended profile", "profile", intended, "active", active)
+	// Intents are recorded by switches, which confirmed a protected profile.
+	if err := SwitchWithStrategy(t.WithProtectedConfirmed(), intended, SwitchOverwrite); err != nil {
+		return "", err
+	}
+	return intended, nil
+}
@@ -0,0 +1,93 @@
+package profile
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestRestoreIntent(t *testing.T) {
+	tool, configPath := setupClaudeHome(t)
+	for _, name := range []string{"work", "personal"} {
+		if err := os.WriteFile(configPath, []byte(`{"profile":"`+name+`"}`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if restored, err := RestoreIntent(tool); err != nil || restored != "" {
+		t.Fatalf("RestoreIntent without an intent = %q, %v", restored, err)
+	}
+
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := RecordIntent(tool, "work"); err != nil {
+		t.Fatalf("RecordIntent: %v", err)
+	}
+	if restored, err := RestoreIntent(tool); err != nil || restored != "" {
+		t.Fatalf("RestoreIntent on the intended profile = %q, %v", restored, err)
+	}
+
+	// A temporary switch that was never undone, as after tokyo exec was
+	// killed.
+	if err := Switch(tool, "personal"); err != nil {
+		t.Fatalf("Switch personal: %v", err)
+	}
+	if restored, err := RestoreIntent(tool); err != nil || restored != "work" {
+		t.Fatalf("RestoreIntent = %q, %v, want work", restored, err)
+	}
+	if active, _ := ActiveProfile(tool); active != "work" {
+		t.Fatalf("active = %q, want work", active)
+	}
+
+	// A time-boxed switch still running is left alone.
+	if _, err := SwitchFor(tool, "personal", SwitchOverwrite, time.Hour, time.Now()); err != nil {
+		t.Fatalf("SwitchFor: %v", err)
+	}
+	if restored, err := RestoreIntent(tool); err != nil || restored != "" {
+		t.Fatalf("RestoreIntent during a time-boxed switch = %q, %v", restored, err)
+	}
+	if intended, err := Intent(tool); err != nil || intended != "work" {
+		t.Fatalf("Intent = %q, %v, want work", intended, err)
+	}
+}
+
+func TestIntentFollowsRenameAndDelete(t *testing.T) {
+	tool, configPath := setupClaudeHome(t)
+	for _, name := range []string{"work", "personal"} {
+		if err := os.WriteFile(configPath, []byte(`{"profile":"`+name+`"}`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := RecordIntent(tool, "work"); err != nil {
+		t.Fatalf("RecordIntent: %v", err)
+	}
+
+	if err := Rename(tool, "work", "job"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if intended, err := Intent(tool); err != nil || intended != "job" {
+		t.Fatalf("Intent after rename = %q, %v, want job", intended, err)
+	}
+	if err := Switch(tool, "personal"); err != nil {
+		t.Fatalf("Switch personal: %v", err)
+	}
+	if restored, err := RestoreIntent(tool); err != nil || restored != "job" {
+		t.Fatalf("RestoreIntent after rename = %q, %v, want job", restored, err)
+	}
+
+	if _, err := Delete(tool, "job"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if intended, err := Intent(tool); err != nil || intended != "" {
+		t.Fatalf("Intent after delete = %q, %v, want none", intended, err)
+	}
+}
@@ -0,0 +1,204 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// journalName is the file in a switch's rollback directory that describes
+// the switch while it replaces the live files. It is removed with the
+// directory when the switch returns, so one that is left behind means the
+// process died mid-switch.
+const journalName = "journal.json"
+
+// rejectedJournalName is what a journal that fails checkJournal is renamed
+// to. Recover then leaves its directory, and any backups in it, alone.
+const rejectedJournalName = "journal.rejected.json"
+
+type switchJournal struct {
+	// PID is the process running the switch.
+	PID           int           `json:"pid"`
+	Profile       string        `json:"profile"`
+	Previous      string        `json:"previous"`
+	PreviousKnown bool          `json:"previousKnown"`
+	Files         []journalFile `json:"files"`
+}
+
+type journalFile struct {
+	Target string `json:"target"`
+	// Stage is the staged copy of the profile's file, renamed onto Target.
+	Stage string `json:"stage"`
+	// Backup is the copy of Target taken before the switch; "" when
+	// Target did not exist or was a managed symlink.
+	Backup string `json:"backup,omitempty"`
+	// Link is the target of the managed symlink that stood at Target.
+	Link string `json:"link,omitempty"`
+}
+
+// Recovery is an interrupted switch that Recover finished.
+type Recovery struct {
+	// Profile is the profile the switch was making live.
+	Profile string
+	// Completed is true when every file had already been replaced and the
+	// switch was completed, false when it was rolled back.
+	Completed bool
+}
+
+// writeSwitchJournal records an upcoming switch in rollbackDir before any
+// live file is replaced.
+func writeSwitchJournal(rollbackDir, profile, previous string, previousKnown bool, stageFiles map[string]string, entries []rollbackEntry) error {
+	j := switchJournal{PID: os.Getpid(), Profile: profile, Previous: previous, PreviousKnown: previousKnown}
+	for _, entry := range entries {
+		j.Files = append(j.Files, journalFile{Target: entry.target, Stage: stageFiles[entry.target], Backup: entry.backup, Link: entry.link})
+	}
+	data, err := json.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(rollbackDir, journalName), data, 0o600)
+}
+
+// Recover finishes switches of t that were interrupted by a crash, found
+// through the journals left in rollback directories. A switch whose files
+// had all been replaced is completed by recording its profile as current;
+// any other is rolled back to the files and profile from before it.
+// Journals of switches still running in another process are left alone.
+func Recover(t Tool) ([]Recovery, error) {
+	base, err := t.tokyoDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(base)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var recovered []Recovery
+	var errs []error
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), "rollback-") {
+			continue
+		}
+		dir := filepath.Join(base, entry.Name())
+		r, ok, err := recoverSwitch(t, dir)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if ok {
+			recovered = append(recovered, r)
+		}
+	}
+	return recovered, errors.Join(errs...)
+}
+
+func recoverSwitch(t Tool, dir string) (Recovery, bool, error) {
+	path := filepath.Join(dir, journalName)
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Recovery{}, false, nil
+		}
+		return Recovery{}, false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Recovery{}, false, fmt.Errorf("read %s: %w", path, err)
+	}
+	var j switchJournal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return Recovery{}, false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	running := j.PID == os.Getpid() || processAlive(j.PID)
+	if running && time.Since(info.ModTime()) < staleTempAge {
+		return Recovery{}, false, nil
+	}
+
+	log := t.logger()
+	if err := checkJournal(t, dir, j); err != nil {
+		// A damaged or planted journal would have recovery write anywhere
+		// the user can.
+		log.Debug("rejecting switch journal", "journal", path, "err", err)
+		if renameErr := os.Rename(path, filepath.Join(dir, rejectedJournalName)); renameErr != nil {
+			return Recovery{}, false, errors.Join(fmt.Errorf("parse %s: %w", path, err), renameErr)
+		}
+		return Recovery{}, false, fmt.Errorf("parse %s: %w (set aside as %s)", path, err, rejectedJournalName)
+	}
+	completed := true
+	for _, f := range j.Files {
+		if _, err := os.Lstat(f.Stage); err == nil {
+			completed = false
+			break
+		}
+	}
+
+	if completed {
+		log.Debug("completing interrupted switch", "profile", j.Profile, "journal", path)
+		if err := writeCurrentProfile(t, j.Profile); err != nil {
+			return Recovery{}, false, fmt.Errorf("complete interrupted switch to %q: %w", j.Profile, err)
+		}
+	} else {
+		log.Debug("rolling back interrupted switch", "profile", j.Profile, "journal", path)
+		rollback := make([]rollbackEntry, 0, len(j.Files))
+		for _, f := range j.Files {
+			rollback = append(rollback, rollbackEntry{target: f.Target, backup: f.Backup, existed: f.Backup != "" || f.Link != "", link: f.Link})
+		}
+		if err := rollbackSwitch(t, j.Previous, j.PreviousKnown, rollback); err != nil {
+			return Recovery{}, false, fmt.Errorf("roll back interrupted switch to %q: %w", j.Profile, err)
+		}
+		for _, f := range j.Files {
+			_ = os.Remove(f.Stage)
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return Recovery{}, false, err
+	}
+	return Recovery{Profile: j.Profile, Completed: completed}, true, nil
+}
+
+// checkJournal checks that j, read from the rollback directory dir, only
+// names t's config files, stage files next to them, backups in dir and links
+// into t's profiles.
+func checkJournal(t Tool, dir string, j switchJournal) error {
+	if err := ValidateProfileName(j.Profile); err != nil {
+		return err
+	}
+	if j.Previous != "" {
+		if err := ValidateProfileName(j.Previous); err != nil {
+			return err
+		}
+	}
+	targets, err := t.configFiles()
+	if err != nil {
+		return err
+	}
+	profilesDir, err := t.profilesDir()
+	if err != nil {
+		return err
+	}
+	for _, f := range j.Files {
+		if !slices.Contains(targets, f.Target) {
+			return fmt.Errorf("%s is not a %s config file", f.Target, t.DisplayName)
+		}
+		if filepath.Dir(f.Stage) != filepath.Dir(f.Target) || !strings.HasPrefix(filepath.Base(f.Stage), ".tokyo-stage-") {
+			return fmt.Errorf("stage file %s is not next to %s", f.Stage, f.Target)
+		}
+		if f.Backup != "" && filepath.Dir(f.Backup) != filepath.Clean(dir) {
+			return fmt.Errorf("backup %s is not in %s", f.Backup, dir)
+		}
+		if f.Link != "" && !withinDir(profilesDir, f.Link) {
+			return fmt.Errorf("link target %s is not in %s", f.Link, profilesDir)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,254 @@
+package profile
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setupCodexProfiles saves codex profiles work and personal, each with its
+// own config.toml and auth.json, and switches to personal.
+func setupCodexProfiles(t *testing.T) (Tool, string) {
+	t.Helper()
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		writeCodexFiles(t, codexDir, name)
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := Switch(tool, "personal"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	return tool, codexDir
+}
+
+func writeCodexFiles(t *testing.T, codexDir, name string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(codexDir, "config.toml"), []byte("model = \""+name+"\"\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(`{"token":"`+name+`"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+}
+
+// interruptSwitch starts switching to profile as SwitchWithStrategy does and
+// stops after renaming the first renamed files, as if the process had died.
+func interruptSwitch(t *testing.T, tool Tool, profile string, renamed int) string {
+	t.Helper()
+	pairs, err := profilePairs(tool, profile)
+	if err != nil {
+		t.Fatalf("profilePairs: %v", err)
+	}
+	stageFiles, err := stageProfileFiles(tool, pairs)
+	if err != nil {
+		t.Fatalf("stageProfileFiles: %v", err)
+	}
+	previous, err := readCurrentProfile(tool)
+	if err != nil {
+		t.Fatalf("readCurrentProfile: %v", err)
+	}
+	dir, err := createRollbackDir(tool)
+	if err != nil {
+		t.Fatalf("createRollbackDir: %v", err)
+	}
+	entries, err := backupCurrentFiles(tool, pairs, dir)
+	if err != nil {
+		t.Fatalf("backupCurrentFiles: %v", err)
+	}
+	if err := writeSwitchJournal(dir, profile, previous, true, stageFiles, entries); err != nil {
+		t.Fatalf("writeSwitchJournal: %v", err)
+	}
+
+	// Attribute the journal to a process that no longer exists.
+	path := filepath.Join(dir, journalName)
+	var j switchJournal
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read journal: %v", err)
+	}
+	if err := json.Unmarshal(data, &j); err != nil {
+		t.Fatalf("parse journal: %v", err)
+	}
+	j.PID = -1
+	if data, err = json.Marshal(j); err != nil {
+		t.Fatalf("marshal journal: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write journal: %v", err)
+	}
+
+	for _, pair := range pairs[:renamed] {
+		if err := os.Rename(stageFiles[pair.dst], pair.dst); err != nil {
+			t.Fatalf("rename: %v", err)
+		}
+	}
+	return dir
+}
+
+func assertLive(t *testing.T, codexDir, want string) {
+	t.Helper()
+	for name, content := range map[string]string{
+		"config.toml": "model = \"" + want + "\"\n",
+		"auth.json":   `{"token":"` + want + `"}`,
+	} {
+		got, err := os.ReadFile(filepath.Join(codexDir, name))
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if string(got) != content {
+			t.Fatalf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestRecoverRollsBackPartialSwitch(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	dir := interruptSwitch(t, tool, "work", 1)
+
+	recovered, err := Recover(tool)
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if len(recovered) != 1 || recovered[0] != (Recovery{Profile: "work", Completed: false}) {
+		t.Fatalf("Recover = %+v, want a rolled back switch to work", recovered)
+	}
+	assertLive(t, codexDir, "personal")
+	assertStatus(t, tool, "personal")
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("expected the rollback directory to be removed, got %v", err)
+	}
+	leftovers, err := filepath.Glob(filepath.Join(codexDir, ".tokyo-stage-*"))
+	if err != nil || len(leftovers) != 0 {
+		t.Fatalf("expected stage files to be removed, got %v (%v)", leftovers, err)
+	}
+
+	if recovered, err := Recover(tool); err != nil || len(recovered) != 0 {
+		t.Fatalf("second Recover = %+v, %v; want nothing to do", recovered, err)
+	}
+}
+
+func TestRecoverCompletesFinishedSwitch(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	interruptSwitch(t, tool, "work", 2)
+
+	recovered, err := Recover(tool)
+	if err != nil {
+		t.Fatalf("Recover: %v", err)
+	}
+	if len(recovered) != 1 || recovered[0] != (Recovery{Profile: "work", Completed: true}) {
+		t.Fatalf("Recover = %+v, want a completed switch to work", recovered)
+	}
+	assertLive(t, codexDir, "work")
+	assertStatus(t, tool, "work")
+}
+
+func TestRecoverSkipsRunningSwitch(t *testing.T) {
+	tool, _ := setupCodexProfiles(t)
+	dir, err := createRollbackDir(tool)
+	if err != nil {
+		t.Fatalf("createRollbackDir: %v", err)
+	}
+	if err := writeSwitchJournal(dir, "work", "personal", true, nil, nil); err != nil {
+		t.Fatalf("writeSwitchJournal: %v", err)
+	}
+	if recovered, err := Recover(tool); err != nil || len(recovered) != 0 {
+		t.Fatalf("Recover = %+v, %v; want the running switch left alone", recovered, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, journalName)); err != nil {
+		t.Fatalf("expected the journal to stay: %v", err)
+	}
+}
+
+func TestRecoverRejectsJournalOutsideItsPaths(t *testing.T) {
+	outside := t.TempDir()
+	victim := filepath.Join(outside, "victim")
+	planted := filepath.Join(outside, "planted")
+
+	for name, plant := range map[string]func(j *switchJournal, dir, codexDir string){
+		"target": func(j *switchJournal, dir, codexDir string) {
+			j.Files = []journalFile{{Target: victim, Stage: filepath.Join(outside, ".tokyo-stage-1"), Backup: filepath.Join(dir, "config.toml")}}
+		},
+		"stage": func(j *switchJournal, dir, codexDir string) {
+			j.Files = []journalFile{{Target: filepath.Join(codexDir, "config.toml"), Stage: victim}}
+		},
+		"backup": func(j *switchJournal, dir, codexDir string) {
+			j.Files = []journalFile{{Target: filepath.Join(codexDir, "config.toml"), Stage: filepath.Join(codexDir, ".tokyo-stage-1"), Backup: planted}}
+		},
+		"link": func(j *switchJournal, dir, codexDir string) {
+			j.Files = []journalFile{{Target: filepath.Join(codexDir, "config.toml"), Stage: filepath.Join(codexDir, ".tokyo-stage-1"), Link: planted}}
+		},
+		"profile": func(j *switchJournal, dir, codexDir string) {
+			j.Profile = "../../../outside"
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			tool, codexDir := setupCodexProfiles(t)
+			for path, content := range map[string]string{victim: "victim", planted: "planted"} {
+				if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+					t.Fatalf("write %s: %v", path, err)
+				}
+			}
+			dir, err := createRollbackDir(tool)
+			if err != nil {
+				t.Fatalf("createRollbackDir: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "config.toml"), []byte("planted"), 0o600); err != nil {
+				t.Fatalf("write backup: %v", err)
+			}
+			// A stage file left in place makes Recover roll back.
+			if err := os.WriteFile(filepath.Join(codexDir, ".tokyo-stage-1"), nil, 0o600); err != nil {
+				t.Fatalf("write stage: %v", err)
+			}
+			j := switchJournal{PID: -1, Profile: "work", Previous: "personal", PreviousKnown: true}
+			plant(&j, dir, codexDir)
+			data, err := json.Marshal(j)
+			if err != nil {
+				t.Fatalf("marshal journal: %v", err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, journalName), data, 0o600); err != nil {
+				t.Fatalf("write journal: %v", err)
+			}
+
+			if _, err := Recover(tool); err == nil {
+				t.Fatalf("expected the journal to be rejected")
+			}
+			if got, _ := os.ReadFile(victim); string(got) != "victim" {
+				t.Fatalf("victim = %q, want it untouched", got)
+			}
+			if _, err := os.Stat(victim); err != nil {
+				t.Fatalf("victim removed: %v", err)
+			}
+			assertLive(t, codexDir, "personal")
+			assertStatus(t, tool, "personal")
+			if _, err := os.Stat(filepath.Join(dir, rejectedJournalName)); err != nil {
+				t.Fatalf("expected the journal set aside: %v", err)
+			}
+			if recovered, err := Recover(tool); err != nil || len(recovered) != 0 {
+				t.Fatalf("second Recover = %+v, %v; want the rejected journal left alone", recovered, err)
+			}
+		})
+	}
+}
+
+func TestSwitchLeavesNoJournal(t *testing.T) {
+	tool, _ := setupCodexProfiles(t)
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	base, err := tool.tokyoDir()
+	if err != nil {
+		t.Fatalf("tokyoDir: %v", err)
+	}
+	journals, err := filepath.Glob(filepath.Join(base, "rollback-*", journalName))
+	if err != nil || len(journals) != 0 {
+		t.Fatalf("expected no journal after a switch, got %v (%v)", journals, err)
+	}
+}
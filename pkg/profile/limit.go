@@ -0,0 +1,75 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+)
+
+// ErrFileTooLarge is returned when a config file to save or switch to is
+// larger than the tool's MaxFileSize.
+var ErrFileTooLarge = errors.New("file too large")
+
+// DefaultMaxFileSize is the size limit used when none is configured. Config
+// files are small; one this large is almost certainly a cache or log a
+// config path was pointed at by mistake.
+const DefaultMaxFileSize int64 = 64 << 20
+
+// WithMaxFileSize returns a copy of t that refuses to save or switch to
+// files larger than limit bytes. A negative limit removes it.
+func (t Tool) WithMaxFileSize(limit int64) Tool {
+	t.MaxFileSize = limit
+	return t
+}
+
+// FileSizeLimit returns the largest config file t saves or switches to, and
+// false when t has no limit.
+func (t Tool) FileSizeLimit() (int64, bool) {
+	switch {
+	case t.MaxFileSize == 0:
+		return DefaultMaxFileSize, true
+	case t.MaxFileSize < 0:
+		return 0, false
+	}
+	return t.MaxFileSize, true
+}
+
+// checkFileSize rejects path, which is missing or a regular file, when it is
+// over t's size limit. Missing files are left for the caller to report.
+func (t Tool) checkFileSize(path string) error {
+	limit, ok := t.FileSizeLimit()
+	if !ok {
+		return nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if info.Size() <= limit {
+		return nil
+	}
+	return newUserError(ErrFileTooLarge, "%s is %s, over the %s limit for config files; check that the config path does not point at a cache or log, or raise max_file_size",
+		path, FormatSize(info.Size()), FormatSize(limit))
+}
+
+// FormatSize formats a byte count with a binary unit, such as "64 MiB".
+func FormatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	value := float64(n) / float64(div)
+	if value == float64(int64(value)) {
+		return fmt.Sprintf("%d %ciB", int64(value), "KMGTPE"[exp])
+	}
+	return fmt.Sprintf("%.1f %ciB", value, "KMGTPE"[exp])
+}
@@ -0,0 +1,87 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSizeLimit(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	config := filepath.Join(codexDir, "config.toml")
+	if err := os.WriteFile(config, []byte(strings.Repeat("#", 2048)), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+
+	limited := tool.WithMaxFileSize(1024)
+	err := Save(limited, "big", false)
+	if !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected ErrFileTooLarge, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "2 KiB") || !strings.Contains(err.Error(), "1 KiB") {
+		t.Fatalf("expected the sizes in the error, got %v", err)
+	}
+
+	if err := Save(tool, "big", false); err != nil {
+		t.Fatalf("Save under the default limit: %v", err)
+	}
+	if err := Switch(limited, "big"); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected the switch to be refused, got %v", err)
+	}
+	if err := Switch(tool.WithMaxFileSize(-1), "big"); err != nil {
+		t.Fatalf("Switch without a limit: %v", err)
+	}
+}
+
+func TestFileSizeLimitAppliesToDecompressedSize(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	if err := SetCompression(tool, CompressionGzip); err != nil {
+		t.Fatalf("SetCompression: %v", err)
+	}
+	config := filepath.Join(codexDir, "config.toml")
+	if err := os.WriteFile(config, []byte(strings.Repeat("#", 64<<10)), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := Save(tool, "big", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := os.WriteFile(config, []byte(`model = "o3"`), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+
+	// The gzip payload is far smaller than the limit; its content is not.
+	if err := Switch(tool.WithMaxFileSize(1024), "big"); !errors.Is(err, ErrFileTooLarge) {
+		t.Fatalf("expected the switch to be refused, got %v", err)
+	}
+	if data, err := os.ReadFile(config); err != nil || string(data) != `model = "o3"` {
+		t.Fatalf("live config changed: %q, %v", data, err)
+	}
+}
+
+func TestToolFileSizeLimit(t *testing.T) {
+	for max, want := range map[int64]int64{0: DefaultMaxFileSize, 1024: 1024, -1: -1} {
+		limit, ok := Tool{MaxFileSize: max}.FileSizeLimit()
+		if want < 0 && ok {
+			t.Fatalf("MaxFileSize %d: got limit %d, want none", max, limit)
+		}
+		if want >= 0 && (!ok || limit != want) {
+			t.Fatalf("MaxFileSize %d: FileSizeLimit = %d, %v, want %d", max, limit, ok, want)
+		}
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		512:                "512 B",
+		2048:               "2 KiB",
+		1536:               "1.5 KiB",
+		DefaultMaxFileSize: "64 MiB",
+		3 << 30:            "3 GiB",
+	} {
+		if got := FormatSize(n); got != want {
+			t.Errorf("FormatSize(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package profile
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+//go:embed schemas/*.json
+var schemaFS embed.FS
+
+// schemaFiles maps "<tool>/<config file>" to the JSON schema shipped for it.
+// Config files without a schema are not linted.
+var schemaFiles = map[string]string{
+	"claude/settings.json": "schemas/claude-settings.json",
+	"codex/auth.json":      "schemas/codex-auth.json",
+}
+
+var schemaPrinter = message.NewPrinter(language.English)
+
+// LintProblem is one way a config file breaks its tool's schema.
+type LintProblem struct {
+	// File is the base name of the config file.
+	File string
+	// Pointer is the JSON pointer of the offending value; "" is the whole
+	// file.
+	Pointer string
+	Message string
+}
+
+func (p LintProblem) String() string {
+	if p.Pointer == "" {
+		return fmt.Sprintf("%s: %s", p.File, p.Message)
+	}
+	return fmt.Sprintf("%s: %s: %s", p.File, p.Pointer, p.Message)
+}
+
+// Lint checks the config files of profile, or of the live config when
+// profile is "", against the schemas shipped for t, catching typos such as
+// "permisions" that the tool would silently ignore. Missing files are
+// skipped.
+func Lint(t Tool, profile string) ([]LintProblem, error) {
+	var files map[string][]byte
+	var err error
+	if profile == "" {
+		files, err = readLiveFiles(t)
+	} else {
+		files, err = ReadFiles(t, profile)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []LintProblem
+	for _, name := range names {
+		found, err := LintFile(t, name, files[name])
+		if err != nil {
+			return nil, err
+		}
+		problems = append(problems, found...)
+	}
+	return problems, nil
+}
+
+// LintFile checks data, the content of t's config file name, against its
+// schema. Files without a schema have no problems.
+func LintFile(t Tool, name string, data []byte) ([]LintProblem, error) {
+	schemaPath, ok := schemaFiles[t.Name+"/"+name]
+	if !ok {
+		return nil, nil
+	}
+	schema, err := compileSchema(schemaPath)
+	if err != nil {
+		return nil, err
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return []LintProblem{{File: name, Message: fmt.Sprintf("invalid JSON: %v", err)}}, nil
+	}
+	err = schema.Validate(instance)
+	var invalid *jsonschema.ValidationError
+	if !errors.As(err, &invalid) {
+		return nil, err
+	}
+	var problems []LintProblem
+	collectProblems(name, invalid, &problems)
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Pointer < problems[j].Pointer })
+	return problems, nil
+}
+
+// ValidateFile checks data, the content of t's config file name, before it
+// is stored by hand: it must parse as the JSON or TOML its extension
+// promises, and pass LintFile.
+func ValidateFile(t Tool, name string, data []byte) ([]LintProblem, error) {
+	if problems := syntaxProblems(name, data); len(problems) > 0 {
+		return problems, nil
+	}
+	return LintFile(t, name, data)
+}
+
+// syntaxProblems reports data, the content of a file called name, when it
+// does not parse as the JSON or TOML its extension promises.
+func syntaxProblems(name string, data []byte) []LintProblem {
+	switch filepath.Ext(name) {
+	case ".json":
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return []LintProblem{{File: name, Message: fmt.Sprintf("invalid JSON: %v", err)}}
+		}
+	case ".toml":
+		var v map[string]any
+		if err := toml.Unmarshal(data, &v); err != nil {
+			return []LintProblem{{File: name, Message: fmt.Sprintf("invalid TOML: %v", err)}}
+		}
+	}
+	return nil
+}
+
+// collectProblems flattens a validation error into its leaf causes.
+func collectProblems(name string, e *jsonschema.ValidationError, problems *[]LintProblem) {
+	if len(e.Causes) > 0 {
+		for _, cause := range e.Causes {
+			collectProblems(name, cause, problems)
+		}
+		return
+	}
+	pointer := ""
+	if len(e.InstanceLocation) > 0 {
+		pointer = "/" + strings.Join(e.InstanceLocation, "/")
+	}
+	*problems = append(*problems, LintProblem{
+		File:    name,
+		Pointer: pointer,
+		Message: e.ErrorKind.LocalizedString(schemaPrinter),
+	})
+}
+
+func compileSchema(path string) (*jsonschema.Schema, error) {
+	data, err := schemaFS.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(path, doc); err != nil {
+		return nil, err
+	}
+	return c.Compile(path)
+}
+
+// readLiveFiles returns the content of t's live config files, by base name.
+// Missing files are left out.
+func readLiveFiles(t Tool) (map[string][]byte, error) {
+	configFiles, err := t.configFiles()
+	if err != nil {
+		return nil, err
+	}
+	files := make(map[string][]byte, len(configFiles))
+	for _, path := range configFiles {
+		live, err := t.liveFile(path)
+		if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(live)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", path, err)
+		}
+		files[filepath.Base(path)] = data
+	}
+	return files, nil
+}
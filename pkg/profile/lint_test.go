@@ -0,0 +1,92 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLintCatchesTyposInStoredAndLiveConfig(t *testing.T) {
+	home := t.TempDir()
+	tool := ClaudeTool().WithHome(home)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"opus","permissions":{"allow":["Bash(ls)"]}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "good", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	problems, err := Lint(tool, "good")
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("Lint good = %v, %v", problems, err)
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"permisions":{},"env":{"DEBUG":1}}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	problems, err = Lint(tool, "")
+	if err != nil {
+		t.Fatalf("Lint live: %v", err)
+	}
+	if len(problems) != 2 {
+		t.Fatalf("expected 2 problems, got %v", problems)
+	}
+	if problems[0].Pointer != "" || !strings.Contains(problems[0].Message, "permisions") {
+		t.Fatalf("expected the typo to be reported, got %v", problems[0])
+	}
+	if problems[1].Pointer != "/env/DEBUG" || problems[1].File != "settings.json" {
+		t.Fatalf("expected the non-string env value to be reported, got %v", problems[1])
+	}
+
+	if err := os.WriteFile(configPath, []byte(`{"model":`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if problems, err = Lint(tool, ""); err != nil || len(problems) != 1 || !strings.Contains(problems[0].String(), "invalid JSON") {
+		t.Fatalf("Lint broken JSON = %v, %v", problems, err)
+	}
+}
+
+func TestLintSkipsFilesWithoutSchema(t *testing.T) {
+	problems, err := LintFile(CodexTool(), "config.toml", []byte("not json"))
+	if err != nil || len(problems) != 0 {
+		t.Fatalf("LintFile config.toml = %v, %v", problems, err)
+	}
+	problems, err = LintFile(CodexTool(), "auth.json", []byte(`{"OPENAI_API_KEY":42}`))
+	if err != nil || len(problems) != 1 || problems[0].Pointer != "/OPENAI_API_KEY" {
+		t.Fatalf("LintFile auth.json = %v, %v", problems, err)
+	}
+}
+
+func TestValidateFile(t *testing.T) {
+	tests := []struct {
+		tool    Tool
+		name    string
+		data    string
+		problem string
+	}{
+		{CodexTool(), "config.toml", "model = \"o3\"\n[profiles.fast]\nmodel = \"mini\"\n", ""},
+		{CodexTool(), "config.toml", "model = \n", "invalid TOML"},
+		{CodexTool(), "auth.json", `{"OPENAI_API_KEY":`, "invalid JSON"},
+		{CodexTool(), "auth.json", `{"OPENAI_API_KEY":42}`, "/OPENAI_API_KEY"},
+		{ClaudeTool(), "settings.json", `{"model":"opus"}`, ""},
+	}
+	for _, tt := range tests {
+		problems, err := ValidateFile(tt.tool, tt.name, []byte(tt.data))
+		if err != nil {
+			t.Fatalf("ValidateFile %s: %v", tt.name, err)
+		}
+		if tt.problem == "" {
+			if len(problems) != 0 {
+				t.Errorf("%s %q: unexpected problems %v", tt.name, tt.data, problems)
+			}
+			continue
+		}
+		if len(problems) != 1 || !strings.Contains(problems[0].String(), tt.problem) {
+			t.Errorf("%s %q: expected a problem mentioning %q, got %v", tt.name, tt.data, tt.problem, problems)
+		}
+	}
+}
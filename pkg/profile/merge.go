@@ -0,0 +1,295 @@
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// ErrMergeConflict is returned when drift in the live config cannot be merged
+// into the profile being switched to.
+var ErrMergeConflict = errors.New("merge conflict")
+
+const (
+	// SwitchOverwrite replaces drifted live files with the profile's files.
+	// The drift is still autosaved.
+	SwitchOverwrite = "overwrite"
+	// SwitchKeep saves drifted live files into the active profile first.
+	SwitchKeep = "keep"
+	// SwitchMerge carries the drift over into the profile being switched to,
+	// using the active profile's files as the common base.
+	SwitchMerge = "merge"
+)
+
+// ValidateSwitchStrategy checks that strategy is a supported way of handling
+// drift on switch.
+func ValidateSwitchStrategy(strategy string) error {
+	switch strategy {
+	case SwitchOverwrite, SwitchKeep, SwitchMerge:
+		return nil
+	}
+	return fmt.Errorf("unsupported strategy %q (supported: keep, overwrite, merge)", strategy)
+}
+
+// keepDrift saves drifted live files into activeProfile, as SaveCurrent
+// does, so switching away does not lose them.
+func keepDrift(t Tool, activeProfile string) error {
+	exists, err := Exists(t, activeProfile)
+	if err != nil || !exists {
+		return err
+	}
+	match, err := matches(t, activeProfile)
+	if err != nil || match {
+		return err
+	}
+	meta, err := readMetaFile(t, activeProfile)
+	if err != nil {
+		return err
+	}
+	t.logger().Debug("keep drift", "profile", activeProfile)
+	return save(t, activeProfile, meta.Base, true)
+}
+
+// mergeDrift three-way merges the changes made to the live files since
+// activeProfile was switched to into the files of profile. It returns the
+// merged content by live path; files without drift are not included.
+func mergeDrift(t Tool, activeProfile, profile string, pairs []filePair) (map[string][]byte, error) {
+	if activeProfile == "" {
+		return nil, nil
+	}
+	exists, err := Exists(t, activeProfile)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	merged := map[string][]byte{}
+	for _, pair := range pairs {
+		name := filepath.Base(pair.dst)
+		live, err := ensureRegularFileIfExists(pair.dst)
+		if err != nil {
+			return nil, err
+		}
+		if !live {
+			continue
+		}
+		base, err := t.resolveProfileFile(activeProfile, name)
+		if err != nil {
+			return nil, err
+		}
+		if ok, err := ensureRegularFileIfExists(base); err != nil || !ok {
+			return nil, err
+		}
+		same, err := t.storedFileIdentical(base, pair.dst)
+		if err != nil {
+			return nil, err
+		}
+		if same {
+			continue
+		}
+
+		baseData, err := readStored(base)
+		if err != nil {
+			return nil, err
+		}
+		theirs, err := readStored(pair.src)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, newUserError(ErrProfileMissingFile, "profile %q is missing file: %s", profile, name)
+			}
+			return nil, err
+		}
+		ours, err := os.ReadFile(pair.dst)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", pair.dst, err)
+		}
+		data, err := mergeFile(name, profile, baseData, ours, theirs)
+		if err != nil {
+			return nil, err
+		}
+		merged[pair.dst] = data
+		t.logger().Debug("merged", "file", pair.dst, "base", base, "profile", pair.src)
+	}
+	return merged, nil
+}
+
+// mergeFile merges ours and theirs, two edits of base. JSON documents are
+// merged by value; anything else, such as TOML, line by line.
+func mergeFile(name, profile string, base, ours, theirs []byte) ([]byte, error) {
+	baseDoc, baseOK := decodeJSON(base)
+	ourDoc, oursOK := decodeJSON(ours)
+	theirDoc, theirsOK := decodeJSON(theirs)
+	if baseOK && oursOK && theirsOK {
+		doc, conflict := mergeJSON(baseDoc, ourDoc, theirDoc, "")
+		if conflict != "" {
+			return nil, newUserError(ErrMergeConflict, "cannot merge %s into profile %q: %s changed on both sides", name, profile, conflict)
+		}
+		var buf bytes.Buffer
+		enc := json.NewEncoder(&buf)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(doc); err != nil {
+			return nil, fmt.Errorf("merge %s: %w", name, err)
+		}
+		return buf.Bytes(), nil
+	}
+
+	lines, conflict := mergeLines(splitLines(base), splitLines(ours), splitLines(theirs))
+	if conflict > 0 {
+		return nil, newUserError(ErrMergeConflict, "cannot merge %s into profile %q: conflicting changes near line %d", name, profile, conflict)
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// decodeJSON parses data as a single JSON value, keeping numbers as written.
+func decodeJSON(data []byte) (any, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil || dec.More() {
+		return nil, false
+	}
+	return doc, true
+}
+
+// absent stands in for an object member missing on one side of a merge.
+type absent struct{}
+
+// mergeJSON merges two edits of base. Objects are merged member by member;
+// any other value changed differently on both sides is a conflict, reported
+// as the JSON pointer of that value.
+func mergeJSON(base, ours, theirs any, pointer string) (any, string) {
+	switch {
+	case reflect.DeepEqual(ours, theirs), reflect.DeepEqual(theirs, base):
+		return ours, ""
+	case reflect.DeepEqual(ours, base):
+		return theirs, ""
+	}
+	baseObj, ok1 := base.(map[string]any)
+	ourObj, ok2 := ours.(map[string]any)
+	theirObj, ok3 := theirs.(map[string]any)
+	if !ok1 || !ok2 || !ok3 {
+		if pointer == "" {
+			pointer = "/"
+		}
+		return nil, pointer
+	}
+
+	keys := map[string]bool{}
+	for _, obj := range []map[string]any{baseObj, ourObj, theirObj} {
+		for k := range obj {
+			keys[k] = true
+		}
+	}
+	sorted := make([]string, 0, len(keys))
+	for k := range keys {
+		sorted = append(sorted, k)
+	}
+	sort.Strings(sorted)
+
+	merged := map[string]any{}
+	for _, k := range sorted {
+		member := func(obj map[string]any) any {
+			if v, ok := obj[k]; ok {
+				return v
+			}
+			return absent{}
+		}
+		escaped := strings.ReplaceAll(strings.ReplaceAll(k, "~", "~0"), "/", "~1")
+		v, conflict := mergeJSON(member(baseObj), member(ourObj), member(theirObj), pointer+"/"+escaped)
+		if conflict != "" {
+			return nil, conflict
+		}
+		if _, ok := v.(absent); !ok {
+			merged[k] = v
+		}
+	}
+	return merged, ""
+}
+
+// splitLines splits data into lines that keep their line endings.
+func splitLines(data []byte) []string {
+	if len(data) == 0 {
+		return nil
+	}
+	return strings.SplitAfter(string(data), "\n")
+}
+
+// mergeLines merges two edits of base line by line. Regions between lines
+// all three agree on take whichever side changed them; a region both sides
+// changed differently is a conflict, reported as its 1-based line in ours.
+func mergeLines(base, ours, theirs []string) ([]string, int) {
+	ourMatch := matchLines(base, ours)
+	theirMatch := matchLines(base, theirs)
+
+	var out []string
+	b, o, th := 0, 0, 0
+	for {
+		i := b
+		for i < len(base) && (ourMatch[i] < 0 || theirMatch[i] < 0) {
+			i++
+		}
+		oEnd, tEnd := len(ours), len(theirs)
+		if i < len(base) {
+			oEnd, tEnd = ourMatch[i], theirMatch[i]
+		}
+
+		baseChunk, ourChunk, theirChunk := base[b:i], ours[o:oEnd], theirs[th:tEnd]
+		switch {
+		case slices.Equal(ourChunk, theirChunk), slices.Equal(theirChunk, baseChunk):
+			out = append(out, ourChunk...)
+		case slices.Equal(ourChunk, baseChunk):
+			out = append(out, theirChunk...)
+		default:
+			return nil, o + 1
+		}
+
+		if i == len(base) {
+			return out, 0
+		}
+		out = append(out, base[i])
+		b, o, th = i+1, oEnd+1, tEnd+1
+	}
+}
+
+// matchLines returns, for each line of a, the index of the line of b it is
+// paired with in a longest common subsequence, or -1.
+func matchLines(a, b []string) []int {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	match := make([]int, len(a))
+	i, j := 0, 0
+	for i < len(a) {
+		switch {
+		case j < len(b) && a[i] == b[j]:
+			match[i] = j
+			i++
+			j++
+		case j < len(b) && lcs[i][j+1] >= lcs[i+1][j]:
+			j++
+		default:
+			match[i] = -1
+			i++
+		}
+	}
+	return match
+}
@@ -0,0 +1,143 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setupDriftedCodex(t *testing.T, work, personal, live string) (Tool, string) {
+	t.Helper()
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(codexDir, "config.toml")
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(`{"token":"a"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	for _, p := range []struct{ name, content string }{{"personal", personal}, {"work", work}} {
+		if err := os.WriteFile(configPath, []byte(p.content), 0o600); err != nil {
+			t.Fatalf("write config.toml: %v", err)
+		}
+		if err := Save(tool, p.name, false); err != nil {
+			t.Fatalf("Save %s: %v", p.name, err)
+		}
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(live), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	return tool, configPath
+}
+
+func TestSwitchMergeCarriesDriftOver(t *testing.T) {
+	tool, configPath := setupDriftedCodex(t,
+		"model = \"o3\"\n\n[sandbox]\napproval = \"never\"\n",
+		"model = \"gpt-5\"\n\n[sandbox]\napproval = \"never\"\n",
+		"model = \"o3\"\n\n[sandbox]\napproval = \"on-request\"\n")
+
+	if err := SwitchWithStrategy(tool, "personal", SwitchMerge); err != nil {
+		t.Fatalf("SwitchWithStrategy: %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config.toml: %v", err)
+	}
+	if want := "model = \"gpt-5\"\n\n[sandbox]\napproval = \"on-request\"\n"; string(data) != want {
+		t.Fatalf("merged config = %q, want %q", data, want)
+	}
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "personal (modified)" {
+		t.Fatalf("expected merged drift to show as modified, got %q", status)
+	}
+}
+
+func TestSwitchMergeConflictLeavesLiveConfig(t *testing.T) {
+	live := "model = \"o4-mini\"\n"
+	tool, configPath := setupDriftedCodex(t, "model = \"o3\"\n", "model = \"gpt-5\"\n", live)
+
+	err := SwitchWithStrategy(tool, "personal", SwitchMerge)
+	if !errors.Is(err, ErrMergeConflict) {
+		t.Fatalf("expected ErrMergeConflict, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "line 1") {
+		t.Fatalf("expected conflict to name the line, got %v", err)
+	}
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config.toml: %v", err)
+	}
+	if string(data) != live {
+		t.Fatalf("expected live config to be untouched, got %q", data)
+	}
+}
+
+func TestSwitchKeepSavesDriftIntoActiveProfile(t *testing.T) {
+	tool, _ := setupDriftedCodex(t, "model = \"o3\"\n", "model = \"gpt-5\"\n", "model = \"o4-mini\"\n")
+
+	if err := SwitchWithStrategy(tool, "personal", SwitchKeep); err != nil {
+		t.Fatalf("SwitchWithStrategy: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(tool.Home, ".codex", "config.toml"))
+	if err != nil {
+		t.Fatalf("read config.toml: %v", err)
+	}
+	if string(data) != "model = \"o4-mini\"\n" {
+		t.Fatalf("expected kept drift in work, got %q", data)
+	}
+}
+
+func TestMergeJSON(t *testing.T) {
+	base := []byte(`{"model":"opus","env":{"A":"1","B":"2"},"hooks":[1]}`)
+	ours := []byte(`{"model":"opus","env":{"A":"1","B":"3"},"hooks":[1],"theme":"dark"}`)
+	theirs := []byte(`{"model":"sonnet","env":{"B":"2"},"hooks":[1]}`)
+
+	got, err := mergeFile("settings.json", "personal", base, ours, theirs)
+	if err != nil {
+		t.Fatalf("mergeFile: %v", err)
+	}
+	want := "{\n  \"env\": {\n    \"B\": \"3\"\n  },\n  \"hooks\": [\n    1\n  ],\n  \"model\": \"sonnet\",\n  \"theme\": \"dark\"\n}\n"
+	if string(got) != want {
+		t.Fatalf("mergeFile = %s, want %s", got, want)
+	}
+
+	_, err = mergeFile("settings.json", "personal", base, ours, []byte(`{"model":"opus","env":{"B":"4"},"hooks":[1]}`))
+	if !errors.Is(err, ErrMergeConflict) || !strings.Contains(err.Error(), "/env/B") {
+		t.Fatalf("expected conflict at /env/B, got %v", err)
+	}
+}
+
+func TestMergeLines(t *testing.T) {
+	tests := []struct {
+		base, ours, theirs, want string
+		conflict                 int
+	}{
+		{"a\nb\nc\nd\n", "a\nB\nc\nd\n", "a\nb\nc\nD\n", "a\nB\nc\nD\n", 0},
+		{"a\nb\nc\n", "a\nB\nc\n", "a\nb\nC\n", "", 2},
+		{"a\nb\n", "x\na\nb\n", "a\nb\ny\n", "x\na\nb\ny\n", 0},
+		{"a\nb\nc\n", "a\nc\n", "a\nb\nc\n", "a\nc\n", 0},
+		{"a\nb\nc\n", "a\nX\nc\n", "a\nY\nc\n", "", 2},
+	}
+	for _, tt := range tests {
+		got, conflict := mergeLines(splitLines([]byte(tt.base)), splitLines([]byte(tt.ours)), splitLines([]byte(tt.theirs)))
+		if conflict != tt.conflict {
+			t.Fatalf("mergeLines(%q, %q, %q) conflict = %d, want %d", tt.base, tt.ours, tt.theirs, conflict, tt.conflict)
+		}
+		if conflict == 0 && strings.Join(got, "") != tt.want {
+			t.Fatalf("mergeLines(%q, %q, %q) = %q, want %q", tt.base, tt.ours, tt.theirs, strings.Join(got, ""), tt.want)
+		}
+	}
+}
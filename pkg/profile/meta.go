@@ -0,0 +1,289 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const metaFileName = "meta.json"
+
+// Meta is optional per-profile metadata stored next to the profile files.
+type Meta struct {
+	// Env holds environment variables injected by `tokyo exec --env` and
+	// printed by `switch --print-env`.
+	Env map[string]string `json:"env,omitempty"`
+	// Tags are free-form key/value labels used for filtering. A tag without
+	// a value is stored with an empty value.
+	Tags map[string]string `json:"tags,omitempty"`
+	// Annotations are key/value notes on the account a profile belongs to,
+	// such as its org or plan, printed when switching to it.
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// Locked profiles refuse delete and overwrite until unlocked.
+	Locked bool `json:"locked,omitempty"`
+	// Base names the profile that provides any file this profile does not
+	// store itself (see SaveFrom).
+	Base string `json:"base,omitempty"`
+	// ToolVersion is the version of the tool installed when the profile was
+	// saved, if it could be found.
+	ToolVersion string `json:"tool_version,omitempty"`
+}
+
+// ProtectedTag marks a profile whose switch or delete must be confirmed,
+// such as one holding customer-facing credentials.
+const ProtectedTag = "protected"
+
+// Protected reports whether the profile carries ProtectedTag, whatever its
+// value.
+func (m Meta) Protected() bool {
+	_, ok := m.Tags[ProtectedTag]
+	return ok
+}
+
+// WithProtectedConfirmed returns a copy of t that switches to and deletes
+// protected profiles. Without it both fail with ErrProfileProtected, so every
+// caller has to get the user's confirmation first.
+func (t Tool) WithProtectedConfirmed() Tool {
+	t.ProtectedConfirmed = true
+	return t
+}
+
+// CheckProtected returns ErrProfileProtected when profile is protected and t
+// was not made with WithProtectedConfirmed. A missing profile passes, for the
+// action to report.
+func CheckProtected(t Tool, profile string) error {
+	if t.ProtectedConfirmed {
+		return nil
+	}
+	meta, err := readMetaFile(t, profile)
+	if err != nil || !meta.Protected() {
+		return err
+	}
+	return newUserError(ErrProfileProtected, "profile %q is tagged protected (confirm with --confirm-production)", profile)
+}
+
+// ParseTag splits "key=value" or a bare "key" into its parts.
+func ParseTag(tag string) (key, value string, err error) {
+	key, value, _ = strings.Cut(tag, "=")
+	if key == "" || strings.ContainsFunc(key, unicode.IsSpace) {
+		return "", "", fmt.Errorf("invalid tag %q (expected key or key=value)", tag)
+	}
+	return key, value, nil
+}
+
+// ParseAnnotation splits "key=value" into its parts. Unlike a tag, an
+// annotation needs a value.
+func ParseAnnotation(annotation string) (key, value string, err error) {
+	key, value, ok := strings.Cut(annotation, "=")
+	if !ok || key == "" || value == "" || strings.ContainsFunc(key, unicode.IsSpace) {
+		return "", "", fmt.Errorf("invalid annotation %q (expected key=value)", annotation)
+	}
+	return key, value, nil
+}
+
+// AnnotationSummary formats the annotations named by keys, in that order, as
+// "key: value, key: value". Keys without an annotation are skipped; no keys
+// means every annotation, sorted by key.
+func (m Meta) AnnotationSummary(keys []string) string {
+	if len(keys) == 0 {
+		keys = slices.Sorted(maps.Keys(m.Annotations))
+	}
+	var parts []string
+	for _, k := range keys {
+		if v, ok := m.Annotations[k]; ok {
+			parts = append(parts, k+": "+v)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// MatchesTags reports whether the metadata carries every filter. A filter
+// "key=value" requires an exact value; a bare "key" only requires presence.
+func (m Meta) MatchesTags(filters []string) bool {
+	for _, f := range filters {
+		key, value, hasValue := strings.Cut(f, "=")
+		got, ok := m.Tags[key]
+		if !ok || (hasValue && got != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ListTagged returns the profiles whose tags match every filter.
+func ListTagged(t Tool, filters []string) ([]string, error) {
+	profiles, err := List(t)
+	if err != nil || len(filters) == 0 {
+		return profiles, err
+	}
+
+	matched := []string{}
+	for _, p := range profiles {
+		meta, err := ReadMeta(t, p)
+		if err != nil {
+			return nil, err
+		}
+		if meta.MatchesTags(filters) {
+			matched = append(matched, p)
+		}
+	}
+	return matched, nil
+}
+
+func (t Tool) metaFile(profile string) (string, error) {
+	profileDir, err := t.profileDir(profile)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(profileDir, metaFileName), nil
+}
+
+// ReadMeta returns the metadata of a profile. A profile without a meta file
+// has empty metadata.
+func ReadMeta(t Tool, profile string) (Meta, error) {
+	if err := requireProfile(t, profile); err != nil {
+		return Meta{}, err
+	}
+	return readMetaFile(t, profile)
+}
+
+// readMetaFile reads a profile's meta file without checking that the profile
+// exists.
+// writeMetaDir writes meta into the profile directory dir, leaving it out
+// when it is empty.
+func writeMetaDir(dir string, meta Meta) error {
+	if reflect.DeepEqual(meta, Meta{}) {
+		return nil
+	}
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, metaFileName), data, 0o600)
+}
+
+func readMetaFile(t Tool, profile string) (Meta, error) {
+	metaFile, err := t.metaFile(profile)
+	if err != nil {
+		return Meta{}, err
+	}
+
+	data, err := os.ReadFile(metaFile)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Meta{}, nil
+		}
+		return Meta{}, err
+	}
+
+	var meta Meta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return Meta{}, fmt.Errorf("parse %s: %w", metaFile, err)
+	}
+	return meta, nil
+}
+
+// WriteMeta replaces the metadata of an existing profile.
+func WriteMeta(t Tool, profile string, meta Meta) error {
+	if err := requireProfile(t, profile); err != nil {
+		return err
+	}
+	if err := checkWritable(t, profile); err != nil {
+		return err
+	}
+	metaFile, err := t.metaFile(profile)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(meta, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(metaFile, data, 0o600)
+}
+
+// UpdateMeta applies fn to the metadata of a profile and writes the result.
+func UpdateMeta(t Tool, profile string, fn func(*Meta) error) error {
+	meta, err := ReadMeta(t, profile)
+	if err != nil {
+		return err
+	}
+	if err := fn(&meta); err != nil {
+		return err
+	}
+	return WriteMeta(t, profile, meta)
+}
+
+// SetLocked marks a profile as locked or unlocked.
+func SetLocked(t Tool, profile string, locked bool) error {
+	return UpdateMeta(t, profile, func(meta *Meta) error {
+		meta.Locked = locked
+		return nil
+	})
+}
+
+// CaptureEnv stores the current values of the named environment variables,
+// as reported by lookup, with a profile. Variables that are not set are
+// removed from the profile, so a capture always reflects the environment it
+// was taken from. It returns the names of the variables stored.
+func CaptureEnv(t Tool, profile string, names []string, lookup func(string) (string, bool)) ([]string, error) {
+	var captured []string
+	err := UpdateMeta(t, profile, func(meta *Meta) error {
+		for _, name := range names {
+			if name == "" || strings.ContainsAny(name, "= \t\n") {
+				return fmt.Errorf("invalid environment variable name %q", name)
+			}
+			value, ok := lookup(name)
+			if !ok {
+				delete(meta.Env, name)
+				continue
+			}
+			if meta.Env == nil {
+				meta.Env = map[string]string{}
+			}
+			meta.Env[name] = value
+			captured = append(captured, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return captured, nil
+}
+
+// checkUnlocked returns ErrProfileLocked if an existing profile is locked.
+// Missing profiles are not an error here.
+func checkUnlocked(t Tool, profile string) error {
+	meta, err := readMetaFile(t, profile)
+	if err != nil {
+		return err
+	}
+	if meta.Locked {
+		return newUserError(ErrProfileLocked, "profile %q is locked (run 'tokyo %s unlock %s' first)", profile, t.Name, profile)
+	}
+	return nil
+}
+
+func requireProfile(t Tool, profile string) error {
+	if err := ValidateProfileName(profile); err != nil {
+		return err
+	}
+	exists, err := Exists(t, profile)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return newUserError(ErrProfileNotFound, "profile %q not found", profile)
+	}
+	return nil
+}
@@ -0,0 +1,307 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetaRoundTripSurvivesForceSave(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	meta, err := ReadMeta(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadMeta: %v", err)
+	}
+	if len(meta.Env) != 0 {
+		t.Fatalf("expected empty meta, got %+v", meta)
+	}
+
+	err = UpdateMeta(tool, "work", func(m *Meta) error {
+		m.Env = map[string]string{"ANTHROPIC_API_KEY": "sk-test"}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+	if err := Save(tool, "work", true); err != nil {
+		t.Fatalf("Save --force: %v", err)
+	}
+
+	meta, err = ReadMeta(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadMeta after force save: %v", err)
+	}
+	if meta.Env["ANTHROPIC_API_KEY"] != "sk-test" {
+		t.Fatalf("expected env preserved, got %+v", meta)
+	}
+}
+
+func TestReadMetaMissingProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	if _, err := ReadMeta(ClaudeTool(), "missing"); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+}
+
+func TestListTagged(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	tags := map[string]map[string]string{
+		"alpha": {"team": "ai", "billing": "acme"},
+		"beta":  {"team": "web"},
+		"gamma": {"team": "ai", "protected": ""},
+	}
+	for name, tt := range tags {
+		err := UpdateMeta(tool, name, func(m *Meta) error {
+			m.Tags = tt
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("UpdateMeta %s: %v", name, err)
+		}
+	}
+
+	cases := []struct {
+		filters []string
+		want    []string
+	}{
+		{filters: nil, want: []string{"alpha", "beta", "gamma"}},
+		{filters: []string{"team=ai"}, want: []string{"alpha", "gamma"}},
+		{filters: []string{"team=ai", "billing=acme"}, want: []string{"alpha"}},
+		{filters: []string{"protected"}, want: []string{"gamma"}},
+		{filters: []string{"team=none"}, want: []string{}},
+	}
+	for _, tc := range cases {
+		got, err := ListTagged(tool, tc.filters)
+		if err != nil {
+			t.Fatalf("ListTagged %v: %v", tc.filters, err)
+		}
+		if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+			t.Fatalf("ListTagged %v: expected %v, got %v", tc.filters, tc.want, got)
+		}
+	}
+}
+
+func TestParseTag(t *testing.T) {
+	cases := []struct {
+		tag     string
+		key     string
+		value   string
+		wantErr bool
+	}{
+		{tag: "team=ai", key: "team", value: "ai"},
+		{tag: "protected", key: "protected"},
+		{tag: "url=a=b", key: "url", value: "a=b"},
+		{tag: "=ai", wantErr: true},
+		{tag: "my team=ai", wantErr: true},
+	}
+	for _, tc := range cases {
+		key, value, err := ParseTag(tc.tag)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("ParseTag(%q): expected error", tc.tag)
+			}
+			continue
+		}
+		if err != nil || key != tc.key || value != tc.value {
+			t.Fatalf("ParseTag(%q) = %q, %q, %v", tc.tag, key, value, err)
+		}
+	}
+}
+
+func TestParseAnnotation(t *testing.T) {
+	cases := []struct {
+		annotation string
+		key        string
+		value      string
+		wantErr    bool
+	}{
+		{annotation: "org=acme", key: "org", value: "acme"},
+		{annotation: "plan=Max 20x", key: "plan", value: "Max 20x"},
+		{annotation: "plan", wantErr: true},
+		{annotation: "plan=", wantErr: true},
+		{annotation: "=acme", wantErr: true},
+	}
+	for _, tc := range cases {
+		key, value, err := ParseAnnotation(tc.annotation)
+		if tc.wantErr {
+			if err == nil {
+				t.Fatalf("ParseAnnotation(%q): expected error", tc.annotation)
+			}
+			continue
+		}
+		if err != nil || key != tc.key || value != tc.value {
+			t.Fatalf("ParseAnnotation(%q) = %q, %q, %v", tc.annotation, key, value, err)
+		}
+	}
+}
+
+func TestAnnotationSummary(t *testing.T) {
+	meta := Meta{Annotations: map[string]string{"plan": "Max", "org": "acme", "account": "billing@acme.test"}}
+	if got, want := meta.AnnotationSummary(nil), "account: billing@acme.test, org: acme, plan: Max"; got != want {
+		t.Fatalf("all annotations = %q, want %q", got, want)
+	}
+	if got, want := meta.AnnotationSummary([]string{"plan", "region", "org"}), "plan: Max, org: acme"; got != want {
+		t.Fatalf("selected annotations = %q, want %q", got, want)
+	}
+	if got := (Meta{}).AnnotationSummary(nil); got != "" {
+		t.Fatalf("no annotations = %q, want empty", got)
+	}
+}
+
+func TestLockedProfileRefusesDeleteAndOverwrite(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"x":1}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := SetLocked(tool, "work", true); err != nil {
+		t.Fatalf("SetLocked: %v", err)
+	}
+
+	if err := Save(tool, "work", true); !errors.Is(err, ErrProfileLocked) {
+		t.Fatalf("expected ErrProfileLocked on save --force, got %v", err)
+	}
+	if _, err := Delete(tool, "work"); !errors.Is(err, ErrProfileLocked) {
+		t.Fatalf("expected ErrProfileLocked on delete, got %v", err)
+	}
+
+	if err := SetLocked(tool, "work", false); err != nil {
+		t.Fatalf("SetLocked false: %v", err)
+	}
+	if err := Save(tool, "work", true); err != nil {
+		t.Fatalf("Save --force after unlock: %v", err)
+	}
+	if _, err := Delete(tool, "work"); err != nil {
+		t.Fatalf("Delete after unlock: %v", err)
+	}
+}
+
+func TestProtectedProfileRefusesSwitchAndDelete(t *testing.T) {
+	tool, _ := setupClaudeHome(t)
+	if err := Save(tool, "prod", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := UpdateMeta(tool, "prod", func(m *Meta) error {
+		m.Tags = map[string]string{ProtectedTag: ""}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+	if err := Save(tool, "dev", false); err != nil {
+		t.Fatalf("Save dev: %v", err)
+	}
+	if err := Switch(tool, "dev"); err != nil {
+		t.Fatalf("Switch dev: %v", err)
+	}
+
+	if err := Switch(tool, "prod"); !errors.Is(err, ErrProfileProtected) {
+		t.Fatalf("expected ErrProfileProtected on switch, got %v", err)
+	}
+	if _, err := SwitchFor(tool, "prod", SwitchOverwrite, time.Hour, time.Now()); !errors.Is(err, ErrProfileProtected) {
+		t.Fatalf("expected ErrProfileProtected on a temporary switch, got %v", err)
+	}
+	if _, err := Delete(tool, "prod"); !errors.Is(err, ErrProfileProtected) {
+		t.Fatalf("expected ErrProfileProtected on delete, got %v", err)
+	}
+	if active, _ := ActiveProfile(tool); active != "dev" {
+		t.Fatalf("active = %q, want dev", active)
+	}
+
+	confirmed := tool.WithProtectedConfirmed()
+	if err := Switch(confirmed, "prod"); err != nil {
+		t.Fatalf("confirmed Switch: %v", err)
+	}
+	if _, err := Delete(confirmed, "prod"); err != nil {
+		t.Fatalf("confirmed Delete: %v", err)
+	}
+}
+
+func TestCaptureEnv(t *testing.T) {
+	home := t.TempDir()
+	tool := ClaudeTool().WithHome(home)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := UpdateMeta(tool, "work", func(meta *Meta) error {
+		meta.Env = map[string]string{"HTTPS_PROXY": "http://old", "KEEP": "1"}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+
+	env := map[string]string{"ANTHROPIC_BASE_URL": "https://gw.corp"}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+	captured, err := CaptureEnv(tool, "work", []string{"ANTHROPIC_BASE_URL", "HTTPS_PROXY"}, lookup)
+	if err != nil {
+		t.Fatalf("CaptureEnv: %v", err)
+	}
+	if !reflect.DeepEqual(captured, []string{"ANTHROPIC_BASE_URL"}) {
+		t.Fatalf("captured = %v", captured)
+	}
+	meta, err := ReadMeta(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadMeta: %v", err)
+	}
+	want := map[string]string{"ANTHROPIC_BASE_URL": "https://gw.corp", "KEEP": "1"}
+	if !reflect.DeepEqual(meta.Env, want) {
+		t.Fatalf("env = %v, want %v", meta.Env, want)
+	}
+
+	if _, err := CaptureEnv(tool, "work", []string{"A=B"}, lookup); err == nil {
+		t.Fatalf("expected invalid name to be rejected")
+	}
+}
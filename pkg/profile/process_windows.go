@@ -0,0 +1,13 @@
+package profile
+
+import "os"
+
+// processAlive reports whether a process with the given id exists.
+func processAlive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -1,15 +1,24 @@
 package profile
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
 )
 
 var (
@@ -17,15 +26,25 @@ var (
 	ErrExpectedFileIsDir   = errors.New("expected file but found directory")
 	ErrExpectedRegularFile = errors.New("expected regular file")
 
+	ErrInvalidProfileName   = errors.New("invalid profile name")
 	ErrProfileAlreadyExists = errors.New("profile already exists")
 	ErrProfileNotFound      = errors.New("profile not found")
 	ErrConfigFileNotFound   = errors.New("config file not found")
 	ErrProfileMissingFile   = errors.New("profile is missing file")
+	ErrProfileLocked        = errors.New("profile is locked")
+	ErrProfileInUse         = errors.New("profile is in use")
+	ErrAlreadyManaged       = errors.New("live config is already managed")
+	ErrConfigChanged        = errors.New("config changed during save")
+	ErrProfileBusy          = errors.New("profile is being modified")
+	ErrNoActiveProfile      = errors.New("no active profile")
+	ErrProfileProtected     = errors.New("profile is protected")
 )
 
 type userError struct {
-	kind error
-	msg  string
+	kind   error
+	msg    string
+	format string
+	args   []any
 }
 
 func (e *userError) Error() string {
@@ -36,14 +55,69 @@ func (e *userError) Unwrap() error {
 	return e.kind
 }
 
-func newUserError(kind error, msg string) error {
-	return &userError{kind: kind, msg: msg}
+func newUserError(kind error, format string, args ...any) error {
+	return &userError{kind: kind, msg: fmt.Sprintf(format, args...), format: format, args: args}
+}
+
+// UserMessage returns the format and arguments the message of a user-facing
+// error was built from, so that callers can translate it. ok is false for
+// other errors, including user-facing errors wrapped with more context.
+func UserMessage(err error) (format string, args []any, ok bool) {
+	ue, ok := err.(*userError)
+	if !ok {
+		return "", nil, false
+	}
+	return ue.format, ue.args, true
 }
 
 type Tool struct {
 	Name           string
 	DisplayName    string
 	ConfigRelPaths []string
+	// Ignore holds built-in ignore rules for content the tool rewrites on
+	// its own; see AddIgnoreRule.
+	Ignore []string
+	// ConfigDirEnv names the environment variable that points the tool at
+	// another directory holding its config files; see Sandbox.
+	ConfigDirEnv string
+	// Binary is the tool's executable, whose --version is recorded with
+	// saved profiles.
+	Binary string
+	// SecretFields are where the tool keeps its own credentials, which the
+	// secret scanner does not report; see ScanSecrets.
+	SecretFields []string
+
+	// Home overrides the user's home directory for both the config files and
+	// the default store location. Empty means os.UserHomeDir.
+	Home string
+	// StoreRoot overrides the directory holding every tool's store. Empty
+	// means <home>/.config/tokyo.
+	StoreRoot string
+	// Workspace keeps the store in a separate set under
+	// <store root>/workspaces/<name>. Empty means the default set.
+	Workspace string
+	// SystemStoreRoot overrides the directory holding the read-only system
+	// store. Empty means DefaultSystemStoreRoot.
+	SystemStoreRoot string
+	// Logger receives debug records for every file staged, renamed, backed
+	// up and rolled back. Nil discards them.
+	Logger *slog.Logger
+	// Retry bounds how switches retry transient file errors. Nil means
+	// DefaultRetryPolicy.
+	Retry *RetryPolicy
+	// FollowDirSymlinks allows symlinked directories between the home
+	// directory and the config files, like the store setting of the same
+	// name; see SetFollowDirSymlinks.
+	FollowDirSymlinks bool
+	// MaxFileSize is the largest config file, in bytes, that is saved or
+	// switched to. Zero means DefaultMaxFileSize; negative means no limit.
+	MaxFileSize int64
+	// Progress, when set, is told how far switches and saves got; see
+	// WithProgress.
+	Progress ProgressFunc
+	// ProtectedConfirmed lets switches to and deletes of profiles tagged
+	// ProtectedTag go ahead; see WithProtectedConfirmed.
+	ProtectedConfirmed bool
 }
 
 type currentState struct {
@@ -59,6 +133,9 @@ type rollbackEntry struct {
 	target  string
 	backup  string
 	existed bool
+	// link is the target of the managed symlink that stood at target, which
+	// is restored instead of a backup copy.
+	link string
 }
 
 func ClaudeTool() Tool {
@@ -66,6 +143,13 @@ func ClaudeTool() Tool {
 		Name:           "claude",
 		DisplayName:    "Claude Code",
 		ConfigRelPaths: []string{filepath.Join(".claude", "settings.json")},
+		Ignore:         []string{"/feedbackSurveyState"},
+		ConfigDirEnv:   "CLAUDE_CONFIG_DIR",
+		Binary:         "claude",
+		SecretFields: []string{
+			"settings.json#/env/ANTHROPIC_API_KEY",
+			"settings.json#/env/ANTHROPIC_AUTH_TOKEN",
+		},
 	}
 }
 
@@ -77,11 +161,65 @@ func CodexTool() Tool {
 			filepath.Join(".codex", "config.toml"),
 			filepath.Join(".codex", "auth.json"),
 		},
+		ConfigDirEnv: "CODEX_HOME",
+		Binary:       "codex",
+		SecretFields: []string{"auth.json"},
+	}
+}
+
+// Tools returns the built-in tools in display order.
+func Tools() []Tool {
+	return []Tool{ClaudeTool(), CodexTool()}
+}
+
+// LookupTool returns the built-in tool with the given name.
+func LookupTool(name string) (Tool, bool) {
+	for _, t := range Tools() {
+		if t.Name == name {
+			return t, true
+		}
+	}
+	return Tool{}, false
+}
+
+// WithHome returns a copy of t that resolves config files and its store under
+// home. It lets tests and embedders work on a scratch directory without
+// changing the process's HOME.
+func (t Tool) WithHome(home string) Tool {
+	t.Home = home
+	return t
+}
+
+// WithStoreRoot returns a copy of t that keeps its store under root/<name>.
+func (t Tool) WithStoreRoot(root string) Tool {
+	t.StoreRoot = root
+	return t
+}
+
+// WithLogger returns a copy of t that logs its file operations to logger.
+func (t Tool) WithLogger(logger *slog.Logger) Tool {
+	t.Logger = logger
+	return t
+}
+
+func (t Tool) logger() *slog.Logger {
+	if t.Logger != nil {
+		return t.Logger.With("tool", t.Name)
 	}
+	return discardLogger
+}
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+func (t Tool) home() (string, error) {
+	if t.Home != "" {
+		return t.Home, nil
+	}
+	return os.UserHomeDir()
 }
 
 func (t Tool) configFiles() ([]string, error) {
-	home, err := os.UserHomeDir()
+	home, err := t.home()
 	if err != nil {
 		return nil, err
 	}
@@ -90,16 +228,51 @@ func (t Tool) configFiles() ([]string, error) {
 	for _, relPath := range t.ConfigRelPaths {
 		files = append(files, filepath.Join(home, relPath))
 	}
+	if err := t.checkConfigDirs(home, files); err != nil {
+		return nil, err
+	}
 
 	return files, nil
 }
 
 func (t Tool) tokyoDir() (string, error) {
+	root, err := t.storeRoot()
+	if err != nil {
+		return "", err
+	}
+	if t.Workspace != "" {
+		root = filepath.Join(root, workspacesDir, t.Workspace)
+	}
+	return filepath.Join(root, t.Name), nil
+}
+
+// storeRoot returns the directory holding every tool's store, ignoring the
+// workspace.
+func (t Tool) storeRoot() (string, error) {
+	if t.StoreRoot != "" {
+		return t.StoreRoot, nil
+	}
+	if t.Home != "" {
+		return filepath.Join(t.Home, ".config", "tokyo"), nil
+	}
+	return DefaultStoreRoot()
+}
+
+// StoreRootEnv names the environment variable that moves tokyo's directory
+// away from ~/.config/tokyo.
+const StoreRootEnv = "TOKYO_HOME"
+
+// DefaultStoreRoot returns tokyo's directory: $TOKYO_HOME when set,
+// otherwise ~/.config/tokyo.
+func DefaultStoreRoot() (string, error) {
+	if root := os.Getenv(StoreRootEnv); root != "" {
+		return root, nil
+	}
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".config", "tokyo", t.Name), nil
+	return filepath.Join(home, ".config", "tokyo"), nil
 }
 
 func (t Tool) profilesDir() (string, error) {
@@ -110,7 +283,19 @@ func (t Tool) profilesDir() (string, error) {
 	return filepath.Join(base, "profiles"), nil
 }
 
+// profileDir returns the directory profile is read from: the user store's,
+// or the system store's when only the system store has the profile.
 func (t Tool) profileDir(profile string) (string, error) {
+	dir, ok, err := t.systemProfileDir(profile)
+	if err != nil || ok {
+		return dir, err
+	}
+	return t.userProfileDir(profile)
+}
+
+// userProfileDir returns the directory of profile in the user store, where
+// it is written to.
+func (t Tool) userProfileDir(profile string) (string, error) {
 	profilesDir, err := t.profilesDir()
 	if err != nil {
 		return "", err
@@ -126,61 +311,124 @@ func (t Tool) currentFile() (string, error) {
 	return filepath.Join(base, "current.json"), nil
 }
 
+// ValidateProfileName checks that profile can be used as a profile name.
+// Names are made of letters, digits, combining marks, '_' and '-' in any
+// script, so they are safe as directory names on every platform. They must
+// be in Unicode normal form C, the form keyboards produce, so that one name
+// never has two spellings on disk; see NormalizeProfileName.
 func ValidateProfileName(profile string) error {
 	const maxLen = 64
+	// maxBytes keeps the name, plus the prefix and suffix of save's build
+	// directory, under the 255-byte file name limit of common file systems.
+	const maxBytes = 192
 
 	if strings.TrimSpace(profile) == "" {
-		return errors.New("profile name cannot be empty")
+		return newUserError(ErrInvalidProfileName, "profile name cannot be empty")
 	}
 	if strings.TrimSpace(profile) != profile {
-		return errors.New("profile name cannot start or end with whitespace")
+		return newUserError(ErrInvalidProfileName, "profile name cannot start or end with whitespace")
+	}
+	if !utf8.ValidString(profile) {
+		return newUserError(ErrInvalidProfileName, "invalid profile name: %q (not valid UTF-8)", profile)
 	}
-	if len(profile) > maxLen {
-		return fmt.Errorf("profile name too long (max %d characters)", maxLen)
+	if utf8.RuneCountInString(profile) > maxLen || len(profile) > maxBytes {
+		return newUserError(ErrInvalidProfileName, "profile name too long (max %d characters)", maxLen)
 	}
 	if profile == "<custom>" {
-		return errors.New("profile name is reserved")
+		return newUserError(ErrInvalidProfileName, "profile name is reserved")
 	}
 	if strings.HasSuffix(profile, " (modified)") {
-		return errors.New("profile name cannot end with ' (modified)'")
+		return newUserError(ErrInvalidProfileName, "profile name cannot end with ' (modified)'")
 	}
 	if strings.HasPrefix(profile, ".") {
-		return errors.New("profile name cannot start with '.'")
+		return newUserError(ErrInvalidProfileName, "profile name cannot start with '.'")
 	}
 	if filepath.Base(profile) != profile || strings.Contains(profile, string(os.PathSeparator)) {
-		return fmt.Errorf("invalid profile name: %q", profile)
+		return newUserError(ErrInvalidProfileName, "invalid profile name: %q", profile)
 	}
 
-	for _, r := range profile {
-		if r > 0x7f {
-			return fmt.Errorf("invalid profile name: %q (ASCII only)", profile)
+	for i, r := range profile {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '-' || r == '_' {
+			continue
 		}
-		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+		if unicode.IsMark(r) && i > 0 {
 			continue
 		}
-		return fmt.Errorf("invalid profile name: %q (allowed: A-Z a-z 0-9 _ -)", profile)
+		return newUserError(ErrInvalidProfileName, "invalid profile name: %q (allowed: letters, digits, _ -)", profile)
+	}
+	if nfc := NormalizeProfileName(profile); nfc != profile {
+		return newUserError(ErrInvalidProfileName, "invalid profile name: %q is not in Unicode normal form C; use %q", profile, nfc)
+	}
+	if isWindowsDeviceName(profile) {
+		return newUserError(ErrInvalidProfileName, "invalid profile name: %q is a reserved device name on Windows", profile)
 	}
 
 	return nil
 }
 
+// NormalizeProfileName returns profile in Unicode normal form C, the form
+// ValidateProfileName requires. Callers taking names from outside, such as
+// file names listed on macOS, normalize them first.
+func NormalizeProfileName(profile string) string {
+	return norm.NFC.String(profile)
+}
+
+// isWindowsDeviceName reports whether name is one Windows reserves for a
+// device in every directory, such as NUL or COM1.
+func isWindowsDeviceName(name string) bool {
+	switch upper := strings.ToUpper(name); upper {
+	case "CON", "PRN", "AUX", "NUL":
+		return true
+	default:
+		return len(upper) == 4 && (strings.HasPrefix(upper, "COM") || strings.HasPrefix(upper, "LPT")) && upper[3] >= '1' && upper[3] <= '9'
+	}
+}
+
+// List returns the profiles of t's store together with those the system
+// store provides.
 func List(t Tool) ([]string, error) {
+	profiles, err := listUserProfiles(t)
+	if err != nil {
+		return nil, err
+	}
+	system, err := listSystemProfiles(t)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range system {
+		if !slices.Contains(profiles, p) {
+			profiles = append(profiles, p)
+		}
+	}
+
+	sort.Strings(profiles)
+
+	return profiles, nil
+}
+
+// listUserProfiles returns the profiles of t's own store, leaving out the
+// system store's.
+func listUserProfiles(t Tool) ([]string, error) {
 	profilesDir, err := t.profilesDir()
 	if err != nil {
 		return nil, err
 	}
+	return listProfilesIn(profilesDir)
+}
 
+func listProfilesIn(profilesDir string) ([]string, error) {
 	entries, err := os.ReadDir(profilesDir)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return []string{}, nil
 		}
 		return nil, err
 	}
 
-	var profiles []string
+	profiles := []string{}
 	for _, entry := range entries {
-		if entry.IsDir() {
+		// Dot directories are profiles still being built by save.
+		if entry.IsDir() && !strings.HasPrefix(entry.Name(), ".") {
 			profiles = append(profiles, entry.Name())
 		}
 	}
@@ -191,45 +439,151 @@ func List(t Tool) ([]string, error) {
 }
 
 func Save(t Tool, profile string, force bool) error {
+	return save(t, profile, "", force)
+}
+
+// SaveFrom saves the live config as a profile that only stores the files
+// differing from base. Files it does not store are read from base.
+func SaveFrom(t Tool, profile, base string, force bool) error {
+	if err := requireProfile(t, base); err != nil {
+		return err
+	}
+	if base == profile {
+		return errors.New("a profile cannot be based on itself")
+	}
+	return save(t, profile, base, force)
+}
+
+// SaveCurrent overwrites the active profile with the live config and returns
+// its name. A profile saved with a base keeps it, so only the files that
+// differ from the base are stored.
+func SaveCurrent(t Tool) (string, error) {
+	active, err := ActiveProfile(t)
+	if err != nil {
+		return "", err
+	}
+	if active == "" {
+		return "", newUserError(ErrNoActiveProfile, "no active profile (switch to or adopt one first)")
+	}
+	meta, err := readMetaFile(t, active)
+	if err != nil {
+		return "", err
+	}
+	return active, save(t, active, meta.Base, true)
+}
+
+// save captures a stable snapshot of the live config, builds the profile in
+// profiles/.tmp-<name>-<rand> and renames it into place, so a profile never
+// holds a mix of old and new content and a crash never leaves a partial
+// profile behind.
+func save(t Tool, profile, base string, force bool) error {
+	return saveWith(t, profile, base, nil, force, func(dir string) ([]string, error) {
+		return snapshotLiveFiles(t, dir)
+	})
+}
+
+// saveWith is save with the files to store written by snapshot into dir,
+// which returns their paths. A non-nil meta replaces the profile's metadata
+// instead of carrying it over.
+func saveWith(t Tool, profile, base string, meta *Meta, force bool, snapshot func(dir string) ([]string, error)) error {
 	if err := ValidateProfileName(profile); err != nil {
 		return err
 	}
 
-	profileDir, err := t.profileDir(profile)
+	profileDir, err := t.userProfileDir(profile)
+	if err != nil {
+		return err
+	}
+
+	release, err := claimProfile(t, profile)
 	if err != nil {
 		return err
 	}
+	defer release()
 
+	if err := checkCaseCollision(t, profile, ""); err != nil {
+		return err
+	}
+	exists, err := Exists(t, profile)
+	if err != nil {
+		return err
+	}
+	if exists && !force {
+		return newUserError(ErrProfileAlreadyExists, "profile %q already exists (use --force to overwrite)", profile)
+	}
 	if force {
-		if err := os.RemoveAll(profileDir); err != nil {
-			return err
-		}
-		if err := os.MkdirAll(profileDir, 0o700); err != nil {
-			return err
-		}
-	} else {
-		if err := os.MkdirAll(filepath.Dir(profileDir), 0o700); err != nil {
+		if err := checkUnlocked(t, profile); err != nil {
 			return err
 		}
-		if err := os.Mkdir(profileDir, 0o700); err != nil {
-			if os.IsExist(err) {
-				return newUserError(ErrProfileAlreadyExists, fmt.Sprintf("profile %q already exists (use --force to overwrite)", profile))
+		if base != "" {
+			if err := checkNotAncestor(t, profile, base); err != nil {
+				return err
 			}
-			return err
 		}
 	}
 
-	configFiles, err := t.configFiles()
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return err
+	}
+
+	staging, err := createSaveStaging(t)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	files, err := snapshot(filepath.Join(staging, "live"))
 	if err != nil {
 		return err
 	}
 
-	for _, src := range configFiles {
-		dst := filepath.Join(profileDir, filepath.Base(src))
-		if err := copyFile(src, dst); err != nil {
-			if os.IsNotExist(err) {
-				return newUserError(ErrConfigFileNotFound, fmt.Sprintf("config file not found: %s", src))
+	build, err := createProfileBuildDir(t, profile)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(build)
+	for i, src := range files {
+		if base != "" {
+			same, err := sameAsProfileFile(t, base, src)
+			if err != nil {
+				return err
+			}
+			if same {
+				t.progress(ProgressStore, i+1, len(files))
+				continue
 			}
+		}
+		if err := storeProfileFile(t, settings, build, filepath.Base(src), src); err != nil {
+			return fmt.Errorf("store %s: %w", filepath.Base(src), err)
+		}
+		t.progress(ProgressStore, i+1, len(files))
+	}
+
+	// Metadata describes the profile rather than the config snapshot, so
+	// it survives an overwrite.
+	if meta == nil {
+		existing, err := readMetaFile(t, profile)
+		if err != nil {
+			return err
+		}
+		meta = &existing
+	}
+	meta.Base = base
+	if err := writeMetaDir(build, *meta); err != nil {
+		return err
+	}
+
+	if err := commitProfileDir(build, profileDir, filepath.Join(staging, "old"), force); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			return newUserError(ErrProfileAlreadyExists, "profile %q already exists (use --force to overwrite)", profile)
+		}
+		return err
+	}
+	t.logger().Debug("renamed", "from", build, "to", profileDir)
+
+	if force && settings.ContentAddressed {
+		if _, err := PruneBlobs(t); err != nil {
 			return err
 		}
 	}
@@ -248,19 +602,49 @@ func Delete(t Tool, profile string) (cleared bool, err error) {
 	}
 
 	if _, err := os.Stat(profileDir); err != nil {
-		if os.IsNotExist(err) {
-			return false, newUserError(ErrProfileNotFound, fmt.Sprintf("profile %q not found", profile))
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, newUserError(ErrProfileNotFound, "profile %q not found", profile)
 		}
 		return false, err
 	}
 
+	if err := checkWritable(t, profile); err != nil {
+		return false, err
+	}
+	if err := checkUnlocked(t, profile); err != nil {
+		return false, err
+	}
+	if err := checkNoDependents(t, profile); err != nil {
+		return false, err
+	}
+	if err := CheckProtected(t, profile); err != nil {
+		return false, err
+	}
+
 	current, err := readCurrentProfile(t)
 	if err != nil {
 		return false, err
 	}
 	wasCurrent := current == profile
+	if wasCurrent {
+		// Live files linked into the profile would dangle once it moves
+		// to the trash.
+		if err := materializeLinks(t); err != nil {
+			return false, err
+		}
+	}
 
-	if err := os.RemoveAll(profileDir); err != nil {
+	trashPath, err := newRetainedEntry(t, CategoryTrash, profile, time.Now())
+	if err != nil {
+		return false, err
+	}
+	if err := os.Rename(profileDir, trashPath); err != nil {
+		return false, err
+	}
+	if _, err := PruneBlobs(t); err != nil {
+		return false, err
+	}
+	if err := forgetIntent(t, profile); err != nil {
 		return false, err
 	}
 
@@ -300,10 +684,39 @@ func Current(t Tool) (string, error) {
 	return fmt.Sprintf("%s (modified)", profile), nil
 }
 
+// IsActive reports whether profile is the active profile and the live config
+// still matches it. With allowModified, changes made to the live config since
+// the switch are ignored.
+func IsActive(t Tool, profile string, allowModified bool) (bool, error) {
+	if err := requireProfile(t, profile); err != nil {
+		return false, err
+	}
+	active, err := ActiveProfile(t)
+	if err != nil || active != profile {
+		return false, err
+	}
+	if allowModified {
+		return true, nil
+	}
+	return matches(t, profile)
+}
+
+// Switch makes profile the live config. Drift in the live config is
+// autosaved and then overwritten; see SwitchWithStrategy.
 func Switch(t Tool, profile string) error {
+	return SwitchWithStrategy(t, profile, SwitchOverwrite)
+}
+
+// SwitchWithStrategy makes profile the live config, handling drift from the
+// active profile as strategy says: overwrite it, keep it by saving it into
+// the active profile first, or merge it into the files of profile.
+func SwitchWithStrategy(t Tool, profile, strategy string) error {
 	if err := ValidateProfileName(profile); err != nil {
 		return err
 	}
+	if err := ValidateSwitchStrategy(strategy); err != nil {
+		return err
+	}
 
 	previousProfile := ""
 	previousProfileKnown := false
@@ -317,22 +730,72 @@ func Switch(t Tool, profile string) error {
 		return err
 	}
 	if _, err := os.Stat(profileDir); err != nil {
-		if os.IsNotExist(err) {
-			return newUserError(ErrProfileNotFound, fmt.Sprintf("profile %q not found", profile))
+		if errors.Is(err, fs.ErrNotExist) {
+			return newUserError(ErrProfileNotFound, "profile %q not found", profile)
 		}
 		return err
 	}
+	if err := CheckProtected(t, profile); err != nil {
+		return err
+	}
 
-	pairs, err := profilePairs(t, profileDir)
+	mode, err := t.switchMode()
 	if err != nil {
 		return err
 	}
+	if mode == SwitchModeSymlink && strategy == SwitchMerge {
+		return errors.New("the merge strategy is not available with symlink switching, where the live files are the profile's own")
+	}
+	if mode == SwitchModeSymlink {
+		if err := checkWritable(t, profile); err != nil {
+			return fmt.Errorf("symlink switching would make the live files read-only: %w", err)
+		}
+	}
 
-	stageFiles, err := stageProfileFiles(pairs)
+	if strategy == SwitchKeep && previousProfile != "" {
+		if err := keepDrift(t, previousProfile); err != nil {
+			return err
+		}
+	}
+
+	pairs, err := profilePairs(t, profile)
+	if err != nil {
+		return err
+	}
+
+	log := t.logger()
+	log.Debug("switch", "profile", profile, "previous", previousProfile, "strategy", strategy)
+
+	var merged map[string][]byte
+	if strategy == SwitchMerge {
+		if merged, err = mergeDrift(t, previousProfile, profile, pairs); err != nil {
+			return err
+		}
+	}
+
+	retry := t.newRetrier()
+	var stageFiles map[string]string
+	err = retry.do("stage", func() error {
+		if mode == SwitchModeSymlink {
+			stageFiles, err = stageProfileLinks(t, profile, pairs)
+		} else {
+			stageFiles, err = stageProfileFiles(t, pairs)
+		}
+		return err
+	})
 	if err != nil {
 		return err
 	}
 	defer cleanupStageFiles(stageFiles)
+	for dst, data := range merged {
+		if err := os.WriteFile(stageFiles[dst], data, 0o600); err != nil {
+			return fmt.Errorf("stage %s: %w", dst, err)
+		}
+	}
+
+	if err := autosave(t, previousProfile, pairs); err != nil {
+		return err
+	}
 
 	rollbackDir, err := createRollbackDir(t)
 	if err != nil {
@@ -340,24 +803,36 @@ func Switch(t Tool, profile string) error {
 	}
 	defer os.RemoveAll(rollbackDir)
 
-	rollbackEntries, err := backupCurrentFiles(pairs, rollbackDir)
+	var rollbackEntries []rollbackEntry
+	err = retry.do("back up", func() error {
+		rollbackEntries, err = backupCurrentFiles(t, pairs, rollbackDir)
+		return err
+	})
 	if err != nil {
 		return err
 	}
+	if err := writeSwitchJournal(rollbackDir, profile, previousProfile, previousProfileKnown, stageFiles, rollbackEntries); err != nil {
+		return err
+	}
 
-	for _, pair := range pairs {
+	for i, pair := range pairs {
 		stagePath := stageFiles[pair.dst]
-		if err := os.Rename(stagePath, pair.dst); err != nil {
+		err := retry.do("rename "+pair.dst, func() error { return os.Rename(stagePath, pair.dst) })
+		if err != nil {
+			log.Debug("rename failed", "from", stagePath, "to", pair.dst, "err", err)
 			rollbackErr := rollbackSwitch(t, previousProfile, previousProfileKnown, rollbackEntries)
 			if rollbackErr != nil {
 				return errors.Join(fmt.Errorf("switch failed: %w", err), rollbackErr)
 			}
 			return fmt.Errorf("switch failed: %w", err)
 		}
+		log.Debug("renamed", "from", stagePath, "to", pair.dst)
 		delete(stageFiles, pair.dst)
+		t.progress(ProgressReplace, i+1, len(pairs))
 	}
 
-	if err := writeCurrentProfile(t, profile); err != nil {
+	err = retry.do("record current profile", func() error { return writeCurrentProfile(t, profile) })
+	if err != nil {
 		rollbackErr := rollbackSwitch(t, previousProfile, previousProfileKnown, rollbackEntries)
 		if rollbackErr != nil {
 			return errors.Join(fmt.Errorf("switch failed: %w", err), rollbackErr)
@@ -374,7 +849,7 @@ func Exists(t Tool, profile string) (bool, error) {
 		return false, err
 	}
 	if _, err := os.Stat(profileDir); err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return false, nil
 		}
 		return false, err
@@ -388,32 +863,36 @@ func matches(t Tool, profile string) (bool, error) {
 		return false, err
 	}
 	if _, err := os.Stat(profileDir); err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return false, nil
 		}
 		return false, err
 	}
 
-	pairs, err := profilePairs(t, profileDir)
+	pairs, err := profilePairs(t, profile)
 	if err != nil {
 		return false, err
 	}
 
 	for _, pair := range pairs {
 		if err := ensureRegularFile(pair.src); err != nil {
-			if os.IsNotExist(err) {
-				return false, newUserError(ErrProfileMissingFile, fmt.Sprintf("profile is missing file: %s", filepath.Base(pair.src)))
+			if errors.Is(err, fs.ErrNotExist) {
+				return false, newUserError(ErrProfileMissingFile, "profile is missing file: %s", filepath.Base(pair.src))
 			}
 			return false, err
 		}
-		exists, err := ensureRegularFileIfExists(pair.dst)
+		live, err := t.liveFile(pair.dst)
+		if err != nil {
+			return false, err
+		}
+		exists, err := ensureRegularFileIfExists(live)
 		if err != nil {
 			return false, err
 		}
 		if !exists {
 			return false, nil
 		}
-		same, err := filesEqual(pair.src, pair.dst)
+		same, err := t.storedFileEqual(pair.src, live)
 		if err != nil {
 			return false, err
 		}
@@ -425,7 +904,9 @@ func matches(t Tool, profile string) (bool, error) {
 	return true, nil
 }
 
-func profilePairs(t Tool, profileDir string) ([]filePair, error) {
+// profilePairs maps each live config file to the stored file that provides
+// it, following the profile's base chain for files it does not store itself.
+func profilePairs(t Tool, profile string) ([]filePair, error) {
 	configFiles, err := t.configFiles()
 	if err != nil {
 		return nil, err
@@ -433,34 +914,47 @@ func profilePairs(t Tool, profileDir string) ([]filePair, error) {
 
 	pairs := make([]filePair, 0, len(configFiles))
 	for _, dst := range configFiles {
-		src := filepath.Join(profileDir, filepath.Base(dst))
+		src, err := t.resolveProfileFile(profile, filepath.Base(dst))
+		if err != nil {
+			return nil, err
+		}
 		pairs = append(pairs, filePair{src: src, dst: dst})
 	}
 
 	return pairs, nil
 }
 
-func stageProfileFiles(pairs []filePair) (map[string]string, error) {
+func stageProfileFiles(t Tool, pairs []filePair) (map[string]string, error) {
 	stageFiles := make(map[string]string, len(pairs))
-	for _, pair := range pairs {
-		if err := ensureParentDir(pair.dst); err != nil {
+	limit, ok := t.FileSizeLimit()
+	if !ok {
+		limit = -1
+	}
+	for i, pair := range pairs {
+		if err := t.checkFileSize(pair.src); err != nil {
 			cleanupStageFiles(stageFiles)
 			return nil, err
 		}
+		if err := ensureParentDir(pair.dst); err != nil {
+			cleanupStageFiles(stageFiles)
+			return nil, fmt.Errorf("stage %s: %w", pair.dst, err)
+		}
 		tmpFile, err := os.CreateTemp(filepath.Dir(pair.dst), ".tokyo-stage-")
 		if err != nil {
 			cleanupStageFiles(stageFiles)
-			return nil, err
+			return nil, fmt.Errorf("stage %s: %w", pair.dst, err)
 		}
-		if err := copyFileToFile(pair.src, tmpFile); err != nil {
+		if err := copyFileToFile(pair.src, tmpFile, limit); err != nil {
 			os.Remove(tmpFile.Name())
 			cleanupStageFiles(stageFiles)
-			if os.IsNotExist(err) {
-				return nil, newUserError(ErrProfileMissingFile, fmt.Sprintf("profile is missing file: %s", filepath.Base(pair.src)))
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, newUserError(ErrProfileMissingFile, "profile is missing file: %s", filepath.Base(pair.src))
 			}
-			return nil, err
+			return nil, fmt.Errorf("stage %s: %w", pair.dst, err)
 		}
 		stageFiles[pair.dst] = tmpFile.Name()
+		t.logger().Debug("staged", "src", pair.src, "stage", tmpFile.Name())
+		t.progress(ProgressStage, i+1, len(pairs))
 	}
 	return stageFiles, nil
 }
@@ -482,45 +976,78 @@ func createRollbackDir(t Tool) (string, error) {
 	return os.MkdirTemp(base, "rollback-")
 }
 
-func backupCurrentFiles(pairs []filePair, rollbackDir string) ([]rollbackEntry, error) {
+func backupCurrentFiles(t Tool, pairs []filePair, rollbackDir string) ([]rollbackEntry, error) {
 	entries := make([]rollbackEntry, 0, len(pairs))
 	for _, pair := range pairs {
+		link, managed, err := t.managedLink(pair.dst)
+		if err != nil {
+			return nil, fmt.Errorf("back up %s: %w", pair.dst, err)
+		}
+		if managed {
+			entries = append(entries, rollbackEntry{target: pair.dst, existed: true, link: link})
+			t.logger().Debug("backed up", "target", pair.dst, "link", link)
+			continue
+		}
 		existed, err := ensureRegularFileIfExists(pair.dst)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("back up %s: %w", pair.dst, err)
 		}
 		if !existed {
 			entries = append(entries, rollbackEntry{target: pair.dst, existed: false})
+			t.logger().Debug("backed up", "target", pair.dst, "existed", false)
 			continue
 		}
 		backup := filepath.Join(rollbackDir, filepath.Base(pair.dst))
 		if err := copyFile(pair.dst, backup); err != nil {
-			return nil, err
+			return nil, fmt.Errorf("back up %s: %w", pair.dst, err)
 		}
 		entries = append(entries, rollbackEntry{target: pair.dst, backup: backup, existed: true})
+		t.logger().Debug("backed up", "target", pair.dst, "backup", backup)
 	}
 	return entries, nil
 }
 
-func restoreRollback(entries []rollbackEntry) error {
+func restoreRollback(t Tool, entries []rollbackEntry) error {
+	log := t.logger()
 	var errs []error
 	for _, entry := range entries {
+		if entry.link != "" {
+			if err := replaceWithLink(entry.target, entry.link); err != nil {
+				log.Debug("roll back failed", "target", entry.target, "link", entry.link, "err", err)
+				errs = append(errs, fmt.Errorf("restore %s: %w", entry.target, err))
+				continue
+			}
+			log.Debug("rolled back", "target", entry.target, "link", entry.link)
+			continue
+		}
+		if _, managed, err := t.managedLink(entry.target); err == nil && managed {
+			if err := os.Remove(entry.target); err != nil {
+				errs = append(errs, fmt.Errorf("restore %s: %w", entry.target, err))
+				continue
+			}
+		}
 		if entry.existed {
 			if err := copyFile(entry.backup, entry.target); err != nil {
-				errs = append(errs, err)
+				log.Debug("roll back failed", "target", entry.target, "backup", entry.backup, "err", err)
+				errs = append(errs, fmt.Errorf("restore %s: %w", entry.target, err))
+				continue
 			}
+			log.Debug("rolled back", "target", entry.target, "backup", entry.backup)
 			continue
 		}
-		if err := os.Remove(entry.target); err != nil && !os.IsNotExist(err) {
-			errs = append(errs, err)
+		if err := os.Remove(entry.target); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.Debug("roll back failed", "target", entry.target, "err", err)
+			errs = append(errs, fmt.Errorf("restore %s: %w", entry.target, err))
+			continue
 		}
+		log.Debug("rolled back", "target", entry.target, "removed", true)
 	}
 	return errors.Join(errs...)
 }
 
 func rollbackSwitch(t Tool, previousProfile string, previousProfileKnown bool, entries []rollbackEntry) error {
 	var errs []error
-	if err := restoreRollback(entries); err != nil {
+	if err := restoreRollback(t, entries); err != nil {
 		errs = append(errs, err)
 	}
 	if previousProfileKnown {
@@ -539,7 +1066,7 @@ func readCurrentProfile(t Tool) (string, error) {
 
 	data, err := os.ReadFile(currentFile)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return "", nil
 		}
 		return "", err
@@ -547,7 +1074,14 @@ func readCurrentProfile(t Tool) (string, error) {
 
 	var state currentState
 	if err := json.Unmarshal(data, &state); err != nil {
-		return "", err
+		return "", fmt.Errorf("parse %s: %w", currentFile, err)
+	}
+	// The name ends up in paths, so a corrupted or hand-edited file must
+	// not point outside the store.
+	if state.Profile != "" {
+		if err := ValidateProfileName(state.Profile); err != nil {
+			return "", fmt.Errorf("parse %s: %w", currentFile, err)
+		}
 	}
 	return state.Profile, nil
 }
@@ -594,11 +1128,34 @@ func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
 	return nil
 }
 
+// writeCurrentProfile records profile as the active one, "" for none, and
+// adds the change to the history.
 func writeCurrentProfile(t Tool, profile string) error {
 	currentFile, err := t.currentFile()
 	if err != nil {
 		return err
 	}
+	// Checked before reading the previous profile, which would block on a
+	// FIFO.
+	if err := rejectNonRegularFile(currentFile); err != nil {
+		return err
+	}
+	previous, readErr := readCurrentProfile(t)
+	if err := writeCurrentState(t, profile); err != nil {
+		return err
+	}
+	if readErr != nil || previous != profile {
+		recordHistory(t, historyEntry{Time: time.Now().UTC(), Profile: profile})
+	}
+	return nil
+}
+
+// writeCurrentState is writeCurrentProfile without the history.
+func writeCurrentState(t Tool, profile string) error {
+	currentFile, err := t.currentFile()
+	if err != nil {
+		return err
+	}
 
 	state := currentState{Profile: profile}
 	data, err := json.Marshal(state)
@@ -632,7 +1189,7 @@ func ensureRegularFile(path string) error {
 func ensureRegularFileIfExists(path string) (bool, error) {
 	info, err := os.Lstat(path)
 	if err != nil {
-		if os.IsNotExist(err) {
+		if errors.Is(err, fs.ErrNotExist) {
 			return false, nil
 		}
 		return false, err
@@ -676,26 +1233,36 @@ func copyFile(src, dst string) error {
 	}
 	if _, err := io.Copy(out, in); err != nil {
 		out.Close()
-		return err
+		return fmt.Errorf("copy %s to %s: %w", src, dst, err)
 	}
 	return out.Close()
 }
 
-func copyFileToFile(src string, dst *os.File) error {
-	if err := ensureRegularFile(src); err != nil {
-		dst.Close()
-		return err
-	}
-	in, err := os.Open(src)
+// copyFileToFile copies the uncompressed content of src to dst and closes
+// it. Content over limit bytes is refused with ErrFileTooLarge; a negative
+// limit means none. The stored size checkFileSize looks at is the compressed
+// one for gzip payloads and blobs, so the limit is enforced here too.
+func copyFileToFile(src string, dst *os.File, limit int64) error {
+	in, err := openStored(src)
 	if err != nil {
 		dst.Close()
 		return err
 	}
 	defer in.Close()
 
-	if _, err := io.Copy(dst, in); err != nil {
+	var r io.Reader = in
+	if limit >= 0 {
+		r = io.LimitReader(in, limit+1)
+	}
+	n, err := io.Copy(dst, r)
+	if err != nil {
 		dst.Close()
-		return err
+		return fmt.Errorf("copy %s to %s: %w", src, dst.Name(), err)
+	}
+	if limit >= 0 && n > limit {
+		dst.Close()
+		return newUserError(ErrFileTooLarge, "%s expands to over the %s limit for config files; raise max_file_size if it is expected",
+			src, FormatSize(limit))
 	}
 	if err := dst.Sync(); err != nil {
 		dst.Close()
@@ -724,15 +1291,46 @@ func filesEqual(pathA, pathB string) (bool, error) {
 		return false, nil
 	}
 
-	hashA, err := fileHash(pathA)
+	fileA, err := os.Open(pathA)
 	if err != nil {
 		return false, err
 	}
-	hashB, err := fileHash(pathB)
+	defer fileA.Close()
+	fileB, err := os.Open(pathB)
 	if err != nil {
 		return false, err
 	}
-	return hashA == hashB, nil
+	defer fileB.Close()
+
+	equal, err := readersEqual(fileA, fileB)
+	if err != nil {
+		return false, fmt.Errorf("compare %s and %s: %w", pathA, pathB, err)
+	}
+	return equal, nil
+}
+
+// readersEqual compares two streams chunk by chunk, stopping at the first
+// difference.
+func readersEqual(a, b io.Reader) (bool, error) {
+	const chunkSize = 32 * 1024
+	bufA := make([]byte, chunkSize)
+	bufB := make([]byte, chunkSize)
+	for {
+		nA, errA := io.ReadFull(a, bufA)
+		if errA != nil && errA != io.EOF && errA != io.ErrUnexpectedEOF {
+			return false, errA
+		}
+		nB, errB := io.ReadFull(b, bufB)
+		if errB != nil && errB != io.EOF && errB != io.ErrUnexpectedEOF {
+			return false, errB
+		}
+		if !bytes.Equal(bufA[:nA], bufB[:nB]) {
+			return false, nil
+		}
+		if errA != nil || errB != nil {
+			return errA != nil && errB != nil, nil
+		}
+	}
 }
 
 func fileHash(path string) (string, error) {
@@ -744,7 +1342,31 @@ func fileHash(path string) (string, error) {
 
 	hasher := sha256.New()
 	if _, err := io.Copy(hasher, file); err != nil {
-		return "", err
+		return "", fmt.Errorf("read %s: %w", path, err)
 	}
 	return fmt.Sprintf("%x", hasher.Sum(nil)), nil
 }
+
+// CheckStore verifies that the home directory resolves and the tool's store
+// directory can be created and written to.
+func CheckStore(t Tool) error {
+	if _, err := t.configFiles(); err != nil {
+		return fmt.Errorf("resolve home: %w", err)
+	}
+
+	base, err := t.tokyoDir()
+	if err != nil {
+		return fmt.Errorf("resolve store: %w", err)
+	}
+	if err := os.MkdirAll(base, 0o700); err != nil {
+		return fmt.Errorf("create store: %w", err)
+	}
+
+	probe, err := os.CreateTemp(base, ".tokyo-probe-")
+	if err != nil {
+		return fmt.Errorf("store not writable: %w", err)
+	}
+	name := probe.Name()
+	probe.Close()
+	return os.Remove(name)
+}
@@ -1,10 +1,17 @@
 package profile
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
+	"io"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -25,6 +32,21 @@ func TestValidateProfileName(t *testing.T) {
 		{name: "modified_suffix", profile: "work (modified)", wantErr: true},
 		{name: "ok", profile: "work", wantErr: false},
 		{name: "ok_with_hyphen", profile: "work-1", wantErr: false},
+		{name: "ok_japanese", profile: "仕事", wantErr: false},
+		{name: "ok_accented_nfc", profile: "caf\u00e9", wantErr: false},
+		{name: "ok_devanagari_marks", profile: "\u0915\u093e\u092e", wantErr: false},
+		{name: "decomposed", profile: "cafe\u0301", wantErr: true},
+		{name: "leading_mark", profile: "\u0301work", wantErr: true},
+		{name: "bidi_override", profile: "work\u202egpj", wantErr: true},
+		{name: "zero_width", profile: "wo\u200brk", wantErr: true},
+		{name: "fullwidth_slash", profile: "a\uff0fb", wantErr: true},
+		{name: "control", profile: "work\x00", wantErr: true},
+		{name: "invalid_utf8", profile: "work\xff", wantErr: true},
+		{name: "windows_device", profile: "nul", wantErr: true},
+		{name: "windows_port", profile: "COM1", wantErr: true},
+		{name: "ok_device_prefix", profile: "console", wantErr: false},
+		{name: "too_many_characters", profile: strings.Repeat("仕", 65), wantErr: true},
+		{name: "ok_64_characters", profile: strings.Repeat("仕", 64), wantErr: false},
 	}
 
 	for _, tc := range cases {
@@ -40,6 +62,37 @@ func TestValidateProfileName(t *testing.T) {
 	}
 }
 
+func TestUnicodeProfileName(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	name := "仕事"
+	if err := Save(tool, name, false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	profiles, err := List(tool)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !slices.Contains(profiles, name) {
+		t.Fatalf("expected %q in %v", name, profiles)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := Switch(tool, name); err != nil {
+		t.Fatalf("Switch %s: %v", name, err)
+	}
+	if current, err := Current(tool); err != nil || current != name {
+		t.Fatalf("Current = %q, %v; want %q", current, err, name)
+	}
+	assertLive(t, codexDir, "personal")
+	if err := Save(tool, NormalizeProfileName("cafe\u0301"), false); err != nil {
+		t.Fatalf("Save normalized name: %v", err)
+	}
+	if exists, err := Exists(tool, "caf\u00e9"); err != nil || !exists {
+		t.Fatalf("expected the normalized name to be saved as NFC, got %v, %v", exists, err)
+	}
+}
+
 func TestClaudeLifecycle(t *testing.T) {
 	home := t.TempDir()
 	t.Setenv("HOME", home)
@@ -596,6 +649,50 @@ func TestCurrentStatusWithDeletedProfile(t *testing.T) {
 	}
 }
 
+func TestIsActive(t *testing.T) {
+	home := t.TempDir()
+	tool := ClaudeTool().WithHome(home)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	for _, name := range []string{"work", "personal"} {
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	check := func(profile string, allowModified, want bool) {
+		t.Helper()
+		got, err := IsActive(tool, profile, allowModified)
+		if err != nil {
+			t.Fatalf("IsActive %s: %v", profile, err)
+		}
+		if got != want {
+			t.Fatalf("IsActive(%s, %v) = %v, want %v", profile, allowModified, got, want)
+		}
+	}
+	check("work", false, true)
+	check("personal", false, false)
+
+	if err := os.WriteFile(configPath, []byte(`{"x":1}`), 0o600); err != nil {
+		t.Fatalf("modify config: %v", err)
+	}
+	check("work", false, false)
+	check("work", true, true)
+	check("personal", true, false)
+
+	if _, err := IsActive(tool, "missing", false); !errors.Is(err, ErrProfileNotFound) {
+		t.Fatalf("expected ErrProfileNotFound, got %v", err)
+	}
+}
+
 func TestSwitchProfileCreatesConfigDir(t *testing.T) {
 	home := t.TempDir()
 	t.Setenv("HOME", home)
@@ -649,3 +746,296 @@ func TestCopyFileSuccess(t *testing.T) {
 		t.Fatalf("expected %q, got %q", string(content), string(got))
 	}
 }
+
+func TestFilesEqualAcrossChunks(t *testing.T) {
+	dir := t.TempDir()
+	fileA := filepath.Join(dir, "a.txt")
+	fileB := filepath.Join(dir, "b.txt")
+
+	content := []byte(strings.Repeat("0123456789abcdef", 10000))
+	if err := os.WriteFile(fileA, content, 0o600); err != nil {
+		t.Fatalf("write fileA: %v", err)
+	}
+	if err := os.WriteFile(fileB, content, 0o600); err != nil {
+		t.Fatalf("write fileB: %v", err)
+	}
+
+	equal, err := filesEqual(fileA, fileB)
+	if err != nil {
+		t.Fatalf("filesEqual: %v", err)
+	}
+	if !equal {
+		t.Fatalf("expected files to be equal")
+	}
+
+	changed := append([]byte(nil), content...)
+	changed[len(changed)-1] = 'x'
+	if err := os.WriteFile(fileB, changed, 0o600); err != nil {
+		t.Fatalf("write fileB: %v", err)
+	}
+
+	equal, err = filesEqual(fileA, fileB)
+	if err != nil {
+		t.Fatalf("filesEqual: %v", err)
+	}
+	if equal {
+		t.Fatalf("expected files differing in the last chunk to be different")
+	}
+}
+
+func TestReadersEqualDifferentLengths(t *testing.T) {
+	equal, err := readersEqual(strings.NewReader("abc"), strings.NewReader("abcd"))
+	if err != nil {
+		t.Fatalf("readersEqual: %v", err)
+	}
+	if equal {
+		t.Fatalf("expected readers of different length to differ")
+	}
+}
+
+func TestToolWithHomeAndStoreRoot(t *testing.T) {
+	home := t.TempDir()
+	storeRoot := t.TempDir()
+	t.Setenv("HOME", t.TempDir())
+
+	tool := ClaudeTool().WithHome(home).WithStoreRoot(storeRoot)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(storeRoot, "claude", "profiles", "work", "settings.json")); err != nil {
+		t.Fatalf("expected profile under store root: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storeRoot, "claude", "current.json")); err != nil {
+		t.Fatalf("expected current.json under store root: %v", err)
+	}
+}
+
+func TestStoreRootEnvMovesStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	root := t.TempDir()
+	t.Setenv(StoreRootEnv, root)
+
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(ClaudeTool(), "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, "claude", "profiles", "work", "settings.json")); err != nil {
+		t.Fatalf("expected profile under %s: %v", StoreRootEnv, err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "tokyo")); !os.IsNotExist(err) {
+		t.Fatalf("expected default store to be untouched, got %v", err)
+	}
+}
+
+func TestSwitchLogsFileOperations(t *testing.T) {
+	home := t.TempDir()
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	tool := ClaudeTool().WithHome(home).WithLogger(logger)
+
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"x":1}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	out := buf.String()
+	for _, want := range []string{"msg=staged", `msg="backed up"`, "msg=renamed", "tool=claude", configPath} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected log to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	dir := t.TempDir()
+	entries, err := backupCurrentFiles(tool, []filePair{{dst: configPath}}, dir)
+	if err != nil {
+		t.Fatalf("backupCurrentFiles: %v", err)
+	}
+	if err := restoreRollback(tool, entries); err != nil {
+		t.Fatalf("restoreRollback: %v", err)
+	}
+	if !strings.Contains(buf.String(), `msg="rolled back"`) {
+		t.Fatalf("expected rollback to be logged, got:\n%s", buf.String())
+	}
+}
+
+func TestToolWithoutLoggerDiscards(t *testing.T) {
+	if ClaudeTool().logger().Enabled(context.Background(), slog.LevelError) {
+		t.Fatal("expected a tool without a logger to discard records")
+	}
+}
+
+func TestFileErrorsNameTheFile(t *testing.T) {
+	home := t.TempDir()
+	tool := ClaudeTool().WithHome(home)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"x":1}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	profileDir, err := tool.profileDir("work")
+	if err != nil {
+		t.Fatalf("profileDir: %v", err)
+	}
+
+	t.Run("corrupt current.json", func(t *testing.T) {
+		currentFile, err := tool.currentFile()
+		if err != nil {
+			t.Fatalf("currentFile: %v", err)
+		}
+		if err := os.WriteFile(currentFile, []byte("{"), 0o600); err != nil {
+			t.Fatalf("write current: %v", err)
+		}
+		defer os.Remove(currentFile)
+
+		_, err = Current(tool)
+		want := "parse " + currentFile + ": "
+		if err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Fatalf("expected error starting with %q, got %v", want, err)
+		}
+	})
+
+	t.Run("truncated stored file", func(t *testing.T) {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write([]byte(strings.Repeat(`{"x":2}`, 100))); err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		stored := filepath.Join(profileDir, "settings.json")
+		if err := os.Remove(stored); err != nil {
+			t.Fatalf("remove: %v", err)
+		}
+		truncated := stored + gzipSuffix
+		if err := os.WriteFile(truncated, buf.Bytes()[:buf.Len()/2], 0o600); err != nil {
+			t.Fatalf("write stored: %v", err)
+		}
+
+		err := Switch(tool, "work")
+		if err == nil {
+			t.Fatal("expected switch to fail")
+		}
+		for _, want := range []string{"stage " + configPath + ": ", "copy " + truncated + " to ", "unexpected EOF"} {
+			if !strings.Contains(err.Error(), want) {
+				t.Fatalf("expected error to contain %q, got %v", want, err)
+			}
+		}
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatalf("expected wrapped io.ErrUnexpectedEOF, got %v", err)
+		}
+	})
+
+	t.Run("missing rollback backup", func(t *testing.T) {
+		backup := filepath.Join(t.TempDir(), "settings.json")
+		err := restoreRollback(tool, []rollbackEntry{{target: configPath, backup: backup, existed: true}})
+		want := "restore " + configPath + ": "
+		if err == nil || !strings.HasPrefix(err.Error(), want) || !strings.Contains(err.Error(), backup) {
+			t.Fatalf("expected error starting with %q naming %s, got %v", want, backup, err)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			t.Fatalf("expected wrapped fs.ErrNotExist, got %v", err)
+		}
+	})
+}
+
+func TestSaveCurrentUpdatesActiveProfile(t *testing.T) {
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(codexDir, "config.toml")
+	authPath := filepath.Join(codexDir, "auth.json")
+	if err := os.WriteFile(configPath, []byte(`model = "o3"`), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"a"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+
+	if _, err := SaveCurrent(tool); !errors.Is(err, ErrNoActiveProfile) {
+		t.Fatalf("expected ErrNoActiveProfile, got %v", err)
+	}
+
+	if err := Save(tool, "base", false); err != nil {
+		t.Fatalf("Save base: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"b"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+	if err := SaveFrom(tool, "work", "base", false); err != nil {
+		t.Fatalf("SaveFrom: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(authPath, []byte(`{"token":"c"}`), 0o600); err != nil {
+		t.Fatalf("write auth.json: %v", err)
+	}
+
+	name, err := SaveCurrent(tool)
+	if err != nil {
+		t.Fatalf("SaveCurrent: %v", err)
+	}
+	if name != "work" {
+		t.Fatalf("expected work to be saved, got %q", name)
+	}
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work" {
+		t.Fatalf("expected clean status after SaveCurrent, got %q", status)
+	}
+	meta, err := ReadMeta(tool, "work")
+	if err != nil {
+		t.Fatalf("ReadMeta: %v", err)
+	}
+	if meta.Base != "base" {
+		t.Fatalf("expected base to be kept, got %q", meta.Base)
+	}
+
+	if err := SetLocked(tool, "work", true); err != nil {
+		t.Fatalf("SetLocked: %v", err)
+	}
+	if _, err := SaveCurrent(tool); !errors.Is(err, ErrProfileLocked) {
+		t.Fatalf("expected ErrProfileLocked, got %v", err)
+	}
+}
@@ -0,0 +1,29 @@
+package profile
+
+// Steps reported to a ProgressFunc.
+const (
+	// ProgressStage is a switch copying the profile's files next to the live
+	// ones.
+	ProgressStage = "stage"
+	// ProgressReplace is a switch moving the staged files over the live ones.
+	ProgressReplace = "replace"
+	// ProgressStore is a save writing the live files into the profile.
+	ProgressStore = "store"
+)
+
+// ProgressFunc is told how far a switch or save got: done of total config
+// files have finished step.
+type ProgressFunc func(step string, done, total int)
+
+// WithProgress returns a copy of t that reports the progress of its
+// switches and saves to fn.
+func (t Tool) WithProgress(fn ProgressFunc) Tool {
+	t.Progress = fn
+	return t
+}
+
+func (t Tool) progress(step string, done, total int) {
+	if t.Progress != nil {
+		t.Progress(step, done, total)
+	}
+}
@@ -0,0 +1,31 @@
+package profile
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	tool, _ := setupCodexProfiles(t)
+	var steps []string
+	tracked := tool.WithProgress(func(step string, done, total int) {
+		steps = append(steps, fmt.Sprintf("%s %d/%d", step, done, total))
+	})
+
+	if err := Switch(tracked, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	want := []string{"stage 1/2", "stage 2/2", "replace 1/2", "replace 2/2"}
+	if !slices.Equal(steps, want) {
+		t.Fatalf("switch progress = %v, want %v", steps, want)
+	}
+
+	steps = nil
+	if err := Save(tracked, "copy", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if want := []string{"store 1/2", "store 2/2"}; !slices.Equal(steps, want) {
+		t.Fatalf("save progress = %v, want %v", steps, want)
+	}
+}
@@ -0,0 +1,27 @@
+package profile
+
+import "regexp"
+
+const redacted = "****"
+
+var (
+	// secretAssignment matches a key that looks credential-like followed by
+	// its value, in JSON, TOML, YAML, or env syntax.
+	secretAssignment = regexp.MustCompile(`(?i)("?[\w.-]*(?:key|token|secret|password|passwd|credential)[\w.-]*"?\s*[:=]\s*)("[^"]*"|'[^']*'|[^\s,}]+)`)
+
+	// secretValue matches well-known token shapes regardless of key name.
+	secretValue = regexp.MustCompile(`\b(sk-[A-Za-z0-9_-]{4})[A-Za-z0-9_-]{8,}|\b(gh[pousr]_)[A-Za-z0-9]{20,}|\b(AKIA)[A-Z0-9]{16}\b`)
+)
+
+// Redact masks values that look like credentials in a line of config text.
+func Redact(line string) string {
+	line = secretAssignment.ReplaceAllStringFunc(line, func(m string) string {
+		parts := secretAssignment.FindStringSubmatch(m)
+		value := parts[2]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			return parts[1] + value[:1] + redacted + value[len(value)-1:]
+		}
+		return parts[1] + redacted
+	})
+	return secretValue.ReplaceAllString(line, "${1}${2}${3}"+redacted)
+}
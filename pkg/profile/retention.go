@@ -0,0 +1,236 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Retention categories. Each lives in a directory of the same name inside
+// the tool's store, one timestamped entry per item.
+const (
+	// CategoryAutosaves holds snapshots of unsaved live config taken
+	// before Switch overwrites it.
+	CategoryAutosaves = "autosaves"
+	// CategoryTrash holds profiles removed by Delete.
+	CategoryTrash = "trash"
+)
+
+// stampLayout prefixes entry names so that they sort chronologically.
+const stampLayout = "20060102T150405.000000000Z"
+
+// Retention bounds one category. Zero fields mean no limit.
+type Retention struct {
+	MaxCount int
+	MaxAge   time.Duration
+}
+
+// RetentionPolicy bounds every category.
+type RetentionPolicy struct {
+	Autosaves Retention
+	Trash     Retention
+}
+
+// DefaultRetention is the policy used when none is configured.
+func DefaultRetention() RetentionPolicy {
+	return RetentionPolicy{
+		Autosaves: Retention{MaxCount: 20, MaxAge: 30 * 24 * time.Hour},
+		Trash:     Retention{MaxCount: 50, MaxAge: 30 * 24 * time.Hour},
+	}
+}
+
+// RetainedEntry is one autosave or trashed profile.
+type RetainedEntry struct {
+	Category string
+	// Name is the profile name for trash entries and empty for autosaves.
+	Name    string
+	Created time.Time
+	Path    string
+}
+
+// GCResult lists the entries GC removed, or would remove in a dry run.
+type GCResult struct {
+	Removed      []RetainedEntry
+	BlobsRemoved int
+	// TempRemoved counts unfinished profile builds left behind by a crash.
+	TempRemoved int
+}
+
+func (t Tool) retentionDir(category string) (string, error) {
+	base, err := t.tokyoDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, category), nil
+}
+
+// retainedEntries returns the entries of a category, oldest first. Entries
+// whose names do not carry a timestamp are ignored.
+func retainedEntries(t Tool, category string) ([]RetainedEntry, error) {
+	dir, err := t.retentionDir(category)
+	if err != nil {
+		return nil, err
+	}
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var entries []RetainedEntry
+	for _, d := range dirEntries {
+		stamp, name, _ := strings.Cut(d.Name(), "-")
+		created, err := time.Parse(stampLayout, stamp)
+		if err != nil || !d.IsDir() {
+			continue
+		}
+		entries = append(entries, RetainedEntry{
+			Category: category,
+			Name:     name,
+			Created:  created,
+			Path:     filepath.Join(dir, d.Name()),
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Created.Before(entries[j].Created) })
+	return entries, nil
+}
+
+// RetainedEntries returns the autosaves and trashed profiles of t, oldest
+// first within each category.
+func RetainedEntries(t Tool) ([]RetainedEntry, error) {
+	var all []RetainedEntry
+	for _, category := range []string{CategoryAutosaves, CategoryTrash} {
+		entries, err := retainedEntries(t, category)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, entries...)
+	}
+	return all, nil
+}
+
+// newRetainedEntry returns the path for a new entry of category, creating the
+// category directory. The caller creates the entry or renames into it.
+func newRetainedEntry(t Tool, category, name string, now time.Time) (string, error) {
+	dir, err := t.retentionDir(category)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	entry := now.UTC().Format(stampLayout)
+	if name != "" {
+		entry += "-" + name
+	}
+	return filepath.Join(dir, entry), nil
+}
+
+// expired returns the entries beyond r's limits, given entries oldest first.
+func (r Retention) expired(entries []RetainedEntry, now time.Time) []RetainedEntry {
+	var out []RetainedEntry
+	for i, entry := range entries {
+		tooMany := r.MaxCount > 0 && len(entries)-i > r.MaxCount
+		tooOld := r.MaxAge > 0 && now.Sub(entry.Created) > r.MaxAge
+		if tooMany || tooOld {
+			out = append(out, entry)
+		}
+	}
+	return out
+}
+
+// GC removes autosaves and trashed profiles beyond policy and unfinished
+// profile builds left by a crash, then prunes blobs that only they
+// referenced. With dryRun it only reports the autosaves and trashed profiles
+// it would remove.
+func GC(t Tool, policy RetentionPolicy, now time.Time, dryRun bool) (GCResult, error) {
+	var result GCResult
+	for _, c := range []struct {
+		category  string
+		retention Retention
+	}{
+		{CategoryAutosaves, policy.Autosaves},
+		{CategoryTrash, policy.Trash},
+	} {
+		entries, err := retainedEntries(t, c.category)
+		if err != nil {
+			return result, err
+		}
+		for _, entry := range c.retention.expired(entries, now) {
+			if !dryRun {
+				if err := os.RemoveAll(entry.Path); err != nil {
+					return result, fmt.Errorf("remove %s: %w", entry.Path, err)
+				}
+			}
+			result.Removed = append(result.Removed, entry)
+		}
+	}
+	if dryRun {
+		return result, nil
+	}
+	temp, err := removeStaleTempProfiles(t, now)
+	if err != nil {
+		return result, err
+	}
+	result.TempRemoved = temp
+	if len(result.Removed) == 0 && temp == 0 {
+		return result, nil
+	}
+	removed, err := PruneBlobs(t)
+	if err != nil {
+		return result, err
+	}
+	result.BlobsRemoved = removed
+	return result, nil
+}
+
+// autosave copies the live files of pairs into a new autosave entry unless
+// they are already saved in the active profile.
+func autosave(t Tool, activeProfile string, pairs []filePair) error {
+	if activeProfile != "" {
+		match, err := matches(t, activeProfile)
+		if err == nil && match {
+			return nil
+		}
+	}
+
+	var live []string
+	for _, pair := range pairs {
+		path, err := t.liveFile(pair.dst)
+		if err != nil {
+			return err
+		}
+		exists, err := ensureRegularFileIfExists(path)
+		if err != nil {
+			return err
+		}
+		if exists {
+			live = append(live, path)
+		}
+	}
+	if len(live) == 0 {
+		return nil
+	}
+
+	dir, err := newRetainedEntry(t, CategoryAutosaves, "", time.Now())
+	if err != nil {
+		return err
+	}
+	if err := os.Mkdir(dir, 0o700); err != nil {
+		return fmt.Errorf("autosave: %w", err)
+	}
+	for _, src := range live {
+		if err := copyFile(src, filepath.Join(dir, filepath.Base(src))); err != nil {
+			os.RemoveAll(dir)
+			return fmt.Errorf("autosave: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDeleteTrashesAndSwitchAutosaves(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"model":"a"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	// Unsaved live config is kept before the switch overwrites it.
+	if err := os.WriteFile(configPath, []byte(`{"model":"unsaved"}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	// Switching away from a clean active profile takes no autosave.
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if _, err := Delete(tool, "work"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	entries, err := RetainedEntries(tool)
+	if err != nil {
+		t.Fatalf("RetainedEntries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Category != CategoryAutosaves || entries[1].Category != CategoryTrash || entries[1].Name != "work" {
+		t.Fatalf("expected one autosave and one trashed profile, got %+v", entries)
+	}
+	data, err := os.ReadFile(filepath.Join(entries[0].Path, "settings.json"))
+	if err != nil {
+		t.Fatalf("read autosave: %v", err)
+	}
+	if string(data) != `{"model":"unsaved"}` {
+		t.Fatalf("unexpected autosave content %q", data)
+	}
+	if _, err := os.Stat(filepath.Join(entries[1].Path, "settings.json")); err != nil {
+		t.Fatalf("expected trashed profile files: %v", err)
+	}
+}
+
+func TestGCAppliesCountAndAge(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	now := time.Date(2026, 1, 31, 12, 0, 0, 0, time.UTC)
+	for i := range 4 {
+		path, err := newRetainedEntry(tool, CategoryTrash, "p", now.Add(-time.Duration(i)*24*time.Hour))
+		if err != nil {
+			t.Fatalf("newRetainedEntry: %v", err)
+		}
+		if err := os.Mkdir(path, 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+	}
+
+	policy := RetentionPolicy{Trash: Retention{MaxCount: 3, MaxAge: 36 * time.Hour}}
+	result, err := GC(tool, policy, now, true)
+	if err != nil {
+		t.Fatalf("GC dry run: %v", err)
+	}
+	if len(result.Removed) != 2 {
+		t.Fatalf("expected 2 entries over the limits, got %+v", result.Removed)
+	}
+	if entries, _ := RetainedEntries(tool); len(entries) != 4 {
+		t.Fatalf("expected dry run to keep everything, got %d entries", len(entries))
+	}
+
+	if _, err := GC(tool, policy, now, false); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	entries, err := RetainedEntries(tool)
+	if err != nil {
+		t.Fatalf("RetainedEntries: %v", err)
+	}
+	if len(entries) != 2 || !entries[0].Created.Equal(now.Add(-24*time.Hour)) {
+		t.Fatalf("expected the two newest entries to remain, got %+v", entries)
+	}
+}
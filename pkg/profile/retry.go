@@ -0,0 +1,89 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrSwitchTimeout is returned when a switch runs past its RetryPolicy
+// timeout. Files already replaced are rolled back.
+var ErrSwitchTimeout = errors.New("switch timed out")
+
+const (
+	retryBaseDelay = 50 * time.Millisecond
+	retryMaxDelay  = time.Second
+)
+
+// retrySleep waits between attempts; tests replace it.
+var retrySleep = time.Sleep
+
+// RetryPolicy bounds how a switch retries file operations that fail with a
+// transient error, such as a file held open by a virus scanner on Windows or
+// a stale NFS handle. Other errors fail the switch at once.
+type RetryPolicy struct {
+	// Retries is how many more times a failed operation is tried, with
+	// exponential backoff from 50ms up to 1s between attempts.
+	Retries int
+	// Timeout bounds the whole switch, retries included. Zero means no
+	// limit.
+	Timeout time.Duration
+}
+
+// DefaultRetryPolicy is the policy used when none is configured.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{Retries: 5, Timeout: 30 * time.Second}
+}
+
+// WithRetry returns a copy of t that switches under policy.
+func (t Tool) WithRetry(policy RetryPolicy) Tool {
+	t.Retry = &policy
+	return t
+}
+
+// retrier runs the steps of one switch under t's retry policy, sharing one
+// deadline.
+type retrier struct {
+	policy   RetryPolicy
+	deadline time.Time
+	log      *slog.Logger
+}
+
+func (t Tool) newRetrier() *retrier {
+	policy := DefaultRetryPolicy()
+	if t.Retry != nil {
+		policy = *t.Retry
+	}
+	r := &retrier{policy: policy, log: t.logger()}
+	if policy.Timeout > 0 {
+		r.deadline = time.Now().Add(policy.Timeout)
+	}
+	return r
+}
+
+// do runs step, trying it again after transient failures while retries and
+// time remain.
+func (r *retrier) do(step string, fn func() error) error {
+	delay := retryBaseDelay
+	for attempt := 0; ; attempt++ {
+		if r.expired(0) {
+			return fmt.Errorf("%w before %s", ErrSwitchTimeout, step)
+		}
+		err := fn()
+		if err == nil || !isTransient(err) || attempt >= r.policy.Retries {
+			return err
+		}
+		if r.expired(delay) {
+			return fmt.Errorf("%w: %s: %w", ErrSwitchTimeout, step, err)
+		}
+		r.log.Debug("retrying", "step", step, "attempt", attempt+1, "delay", delay, "err", err)
+		retrySleep(delay)
+		delay = min(delay*2, retryMaxDelay)
+	}
+}
+
+// expired reports whether the deadline passes within wait.
+func (r *retrier) expired(wait time.Duration) bool {
+	return !r.deadline.IsZero() && time.Now().Add(wait).After(r.deadline)
+}
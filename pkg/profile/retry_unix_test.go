@@ -0,0 +1,80 @@
+//go:build !windows
+
+package profile
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func stubRetrySleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var slept []time.Duration
+	old := retrySleep
+	retrySleep = func(d time.Duration) { slept = append(slept, d) }
+	t.Cleanup(func() { retrySleep = old })
+	return &slept
+}
+
+func busy() error {
+	return &os.LinkError{Op: "rename", Old: "a", New: "b", Err: syscall.EBUSY}
+}
+
+func TestRetrierRetriesTransientErrors(t *testing.T) {
+	slept := stubRetrySleep(t)
+	r := ClaudeTool().WithRetry(RetryPolicy{Retries: 3}).newRetrier()
+
+	calls := 0
+	err := r.do("rename", func() error {
+		calls++
+		if calls < 3 {
+			return busy()
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("do: %v", err)
+	}
+	if calls != 3 {
+		t.Fatalf("expected 3 attempts, got %d", calls)
+	}
+	if want := []time.Duration{retryBaseDelay, 2 * retryBaseDelay}; len(*slept) != 2 || (*slept)[0] != want[0] || (*slept)[1] != want[1] {
+		t.Fatalf("backoff = %v, want %v", *slept, want)
+	}
+
+	calls = 0
+	err = r.do("rename", func() error { calls++; return busy() })
+	if !errors.Is(err, syscall.EBUSY) || calls != 4 {
+		t.Fatalf("expected EBUSY after 4 attempts, got %v after %d", err, calls)
+	}
+}
+
+func TestRetrierFailsFastOnOtherErrors(t *testing.T) {
+	stubRetrySleep(t)
+	r := ClaudeTool().newRetrier()
+	calls := 0
+	err := r.do("rename", func() error { calls++; return fs.ErrPermission })
+	if !errors.Is(err, fs.ErrPermission) || calls != 1 {
+		t.Fatalf("expected one attempt failing with ErrPermission, got %v after %d", err, calls)
+	}
+}
+
+func TestRetrierStopsAtDeadline(t *testing.T) {
+	stubRetrySleep(t)
+	r := ClaudeTool().WithRetry(RetryPolicy{Retries: 10, Timeout: time.Millisecond}).newRetrier()
+	err := r.do("rename", func() error { return busy() })
+	if !errors.Is(err, ErrSwitchTimeout) || !errors.Is(err, syscall.EBUSY) {
+		t.Fatalf("expected ErrSwitchTimeout wrapping EBUSY, got %v", err)
+	}
+
+	r.deadline = time.Now().Add(-time.Second)
+	called := false
+	err = r.do("rename", func() error { called = true; return nil })
+	if !errors.Is(err, ErrSwitchTimeout) || called {
+		t.Fatalf("expected a step after the deadline not to run, got %v (ran: %v)", err, called)
+	}
+}
@@ -0,0 +1,162 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// revertFileName is the file next to current.json that holds the switch
+// back a time-boxed switch scheduled. It outlives the process that made the
+// switch, so whichever tokyo runs next when it is due can carry it out.
+const revertFileName = "revert.json"
+
+// Revert is the switch back to Profile that a switch to From for a limited
+// time scheduled for At.
+type Revert struct {
+	Profile string    `json:"profile"`
+	From    string    `json:"from"`
+	At      time.Time `json:"at"`
+}
+
+func (t Tool) revertFile() (string, error) {
+	base, err := t.tokyoDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, revertFileName), nil
+}
+
+// SwitchFor switches t to profile as SwitchWithStrategy does, and schedules
+// the switch back to the active profile after d. It replaces any switch back
+// scheduled before; there must be an active profile to go back to.
+func SwitchFor(t Tool, profile, strategy string, d time.Duration, now time.Time) (Revert, error) {
+	if d <= 0 {
+		return Revert{}, fmt.Errorf("switch duration must be positive, got %s", d)
+	}
+	if err := requireProfile(t, profile); err != nil {
+		return Revert{}, err
+	}
+	previous, err := ActiveProfile(t)
+	if err != nil {
+		return Revert{}, err
+	}
+	if pending, ok, err := PendingRevert(t); err != nil {
+		return Revert{}, err
+	} else if ok && pending.From == previous {
+		// Extending or changing a temporary switch still returns to where
+		// the first one started.
+		previous = pending.Profile
+	}
+	if previous == "" {
+		return Revert{}, newUserError(ErrNoActiveProfile, "no active profile to switch back to")
+	}
+	if previous == profile {
+		return Revert{}, fmt.Errorf("profile %q is the one to switch back to", profile)
+	}
+	if err := SwitchWithStrategy(t, profile, strategy); err != nil {
+		return Revert{}, err
+	}
+	r := Revert{Profile: previous, From: profile, At: now.Add(d).UTC()}
+	if err := writeRevert(t, r); err != nil {
+		return Revert{}, err
+	}
+	return r, nil
+}
+
+func writeRevert(t Tool, r Revert) error {
+	path, err := t.revertFile()
+	if err != nil {
+		return err
+	}
+	if err := ensureParentDir(path); err != nil {
+		return err
+	}
+	if err := rejectNonRegularFile(path); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o600)
+}
+
+// PendingRevert returns the switch back scheduled for t, if any.
+func PendingRevert(t Tool) (Revert, bool, error) {
+	path, err := t.revertFile()
+	if err != nil {
+		return Revert{}, false, err
+	}
+	if err := rejectNonRegularFile(path); err != nil {
+		return Revert{}, false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return Revert{}, false, nil
+		}
+		return Revert{}, false, err
+	}
+	var r Revert
+	if err := json.Unmarshal(data, &r); err != nil {
+		return Revert{}, false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	// The names end up in paths, as with current.json.
+	for _, name := range []string{r.Profile, r.From} {
+		if err := ValidateProfileName(name); err != nil {
+			return Revert{}, false, fmt.Errorf("parse %s: %w", path, err)
+		}
+	}
+	return r, true, nil
+}
+
+// CancelRevert forgets the switch back scheduled for t, leaving the active
+// profile as it is.
+func CancelRevert(t Tool) error {
+	path, err := t.revertFile()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// ApplyRevert carries out the switch back scheduled for t once it is due at
+// now, or right away with early set. done reports whether it switched. A
+// switch back is dropped without switching when another profile than the
+// temporary one has been made active since, or the profile to go back to no
+// longer exists.
+func ApplyRevert(t Tool, now time.Time, early bool) (r Revert, done bool, err error) {
+	r, ok, err := PendingRevert(t)
+	if err != nil || !ok {
+		return Revert{}, false, err
+	}
+	if !early && now.Before(r.At) {
+		return r, false, nil
+	}
+	active, err := ActiveProfile(t)
+	if err != nil {
+		return r, false, err
+	}
+	exists, err := Exists(t, r.Profile)
+	if err != nil {
+		return r, false, err
+	}
+	if active != r.From || !exists {
+		t.logger().Debug("dropping switch back", "profile", r.Profile, "from", r.From, "active", active)
+		return r, false, CancelRevert(t)
+	}
+	// Changes made during the temporary switch are autosaved, not lost. The
+	// profile was active before it, so a protected one was confirmed then.
+	if err := SwitchWithStrategy(t.WithProtectedConfirmed(), r.Profile, SwitchOverwrite); err != nil {
+		return r, false, err
+	}
+	return r, true, CancelRevert(t)
+}
@@ -0,0 +1,136 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSwitchForRevertsWhenDue(t *testing.T) {
+	tool, configPath := setupClaudeHome(t)
+	for _, name := range []string{"work", "personal"} {
+		if err := os.WriteFile(configPath, []byte(`{"profile":"`+name+`"}`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	r, err := SwitchFor(tool, "personal", SwitchOverwrite, 2*time.Hour, now)
+	if err != nil {
+		t.Fatalf("SwitchFor: %v", err)
+	}
+	if want := (Revert{Profile: "work", From: "personal", At: now.Add(2 * time.Hour)}); r != want {
+		t.Fatalf("SwitchFor = %+v, want %+v", r, want)
+	}
+	if active, _ := ActiveProfile(tool); active != "personal" {
+		t.Fatalf("active = %q, want personal", active)
+	}
+
+	// Extending the switch keeps the profile to go back to.
+	if r, err = SwitchFor(tool, "personal", SwitchOverwrite, 3*time.Hour, now); err != nil || r.Profile != "work" {
+		t.Fatalf("extend SwitchFor = %+v, %v", r, err)
+	}
+
+	if _, done, err := ApplyRevert(tool, now.Add(time.Hour), false); err != nil || done {
+		t.Fatalf("ApplyRevert before it is due = %v, %v", done, err)
+	}
+	if active, _ := ActiveProfile(tool); active != "personal" {
+		t.Fatalf("switched back early: active = %q", active)
+	}
+	if _, done, err := ApplyRevert(tool, now.Add(3*time.Hour), false); err != nil || !done {
+		t.Fatalf("ApplyRevert when due = %v, %v", done, err)
+	}
+	if active, _ := ActiveProfile(tool); active != "work" {
+		t.Fatalf("active = %q, want work", active)
+	}
+	if _, ok, err := PendingRevert(tool); err != nil || ok {
+		t.Fatalf("PendingRevert after the switch back = %v, %v", ok, err)
+	}
+}
+
+func TestApplyRevertDropsOutdatedRevert(t *testing.T) {
+	tool, configPath := setupClaudeHome(t)
+	for _, name := range []string{"work", "personal", "other"} {
+		if err := os.WriteFile(configPath, []byte(`{"profile":"`+name+`"}`), 0o600); err != nil {
+			t.Fatalf("write config: %v", err)
+		}
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	now := time.Now()
+	if _, err := SwitchFor(tool, "personal", SwitchOverwrite, time.Minute, now); err != nil {
+		t.Fatalf("SwitchFor: %v", err)
+	}
+	if err := Switch(tool, "other"); err != nil {
+		t.Fatalf("Switch other: %v", err)
+	}
+
+	if _, done, err := ApplyRevert(tool, now.Add(time.Hour), false); err != nil || done {
+		t.Fatalf("ApplyRevert after another switch = %v, %v", done, err)
+	}
+	if active, _ := ActiveProfile(tool); active != "other" {
+		t.Fatalf("active = %q, want other", active)
+	}
+	if _, ok, _ := PendingRevert(tool); ok {
+		t.Fatalf("expected the outdated switch back to be dropped")
+	}
+}
+
+func TestSwitchForNeedsAProfileToGoBackTo(t *testing.T) {
+	tool, _ := setupClaudeHome(t)
+	if err := Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if _, err := SwitchFor(tool, "personal", SwitchOverwrite, time.Hour, time.Now()); !errors.Is(err, ErrNoActiveProfile) {
+		t.Fatalf("SwitchFor without an active profile: err = %v, want ErrNoActiveProfile", err)
+	}
+	if _, err := SwitchFor(tool, "personal", SwitchOverwrite, 0, time.Now()); err == nil {
+		t.Fatalf("expected a zero duration to be refused")
+	}
+}
+
+func TestStateFilesRejectInvalidProfileNames(t *testing.T) {
+	tool, _ := setupClaudeHome(t)
+	revertPath, err := tool.revertFile()
+	if err != nil {
+		t.Fatalf("revertFile: %v", err)
+	}
+	intentPath, err := tool.intentFile()
+	if err != nil {
+		t.Fatalf("intentFile: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(revertPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	for _, data := range []string{
+		`{"profile":"../../escape","from":"work","at":"2026-03-01T09:00:00Z"}`,
+		`{"profile":"work","from":"../escape","at":"2026-03-01T09:00:00Z"}`,
+	} {
+		if err := os.WriteFile(revertPath, []byte(data), 0o600); err != nil {
+			t.Fatalf("write revert.json: %v", err)
+		}
+		if _, _, err := PendingRevert(tool); err == nil {
+			t.Fatalf("PendingRevert accepted %s", data)
+		}
+	}
+
+	if err := os.WriteFile(intentPath, []byte(`{"profile":"../escape"}`), 0o600); err != nil {
+		t.Fatalf("write intent.json: %v", err)
+	}
+	if _, err := Intent(tool); err == nil {
+		t.Fatalf("Intent accepted an invalid profile name")
+	}
+}
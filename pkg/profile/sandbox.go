@@ -0,0 +1,46 @@
+package profile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Sandbox writes the config files of profile into a new private directory
+// under the system temporary directory and returns the directory and the
+// environment that points the tool at it: the profile's stored variables
+// and t.ConfigDirEnv set to the directory. The live config and the active
+// profile are left alone, so a sandbox switches one shell session only. The
+// caller removes the directory when done with it.
+func Sandbox(t Tool, profile string) (string, map[string]string, error) {
+	if t.ConfigDirEnv == "" {
+		return "", nil, fmt.Errorf("%s cannot be pointed at another config directory", t.DisplayName)
+	}
+	files, err := ReadFiles(t, profile)
+	if err != nil {
+		return "", nil, err
+	}
+	meta, err := readMetaFile(t, profile)
+	if err != nil {
+		return "", nil, err
+	}
+
+	dir, err := os.MkdirTemp("", fmt.Sprintf("tokyo-%s-%s-", t.Name, profile))
+	if err != nil {
+		return "", nil, err
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			os.RemoveAll(dir)
+			return "", nil, err
+		}
+	}
+	t.logger().Debug("sandbox", "profile", profile, "dir", dir)
+
+	env := make(map[string]string, len(meta.Env)+1)
+	for k, v := range meta.Env {
+		env[k] = v
+	}
+	env[t.ConfigDirEnv] = dir
+	return dir, env, nil
+}
@@ -0,0 +1,58 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSandboxLeavesLiveConfigAlone(t *testing.T) {
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	for name, content := range map[string]string{"config.toml": "model = \"o3\"\n", "auth.json": `{"key":"work"}`} {
+		path := filepath.Join(home, ".codex", name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := UpdateMeta(tool, "work", func(meta *Meta) error {
+		meta.Env = map[string]string{"OPENAI_BASE_URL": "https://gw.corp", "CODEX_HOME": "/elsewhere"}
+		return nil
+	}); err != nil {
+		t.Fatalf("UpdateMeta: %v", err)
+	}
+	livePath := filepath.Join(home, ".codex", "auth.json")
+	if err := os.WriteFile(livePath, []byte(`{"key":"live"}`), 0o600); err != nil {
+		t.Fatalf("write live: %v", err)
+	}
+
+	dir, env, err := Sandbox(tool, "work")
+	if err != nil {
+		t.Fatalf("Sandbox: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	if env["CODEX_HOME"] != dir || env["OPENAI_BASE_URL"] != "https://gw.corp" {
+		t.Fatalf("unexpected env: %v", env)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "auth.json"))
+	if err != nil || string(data) != `{"key":"work"}` {
+		t.Fatalf("sandbox auth.json = %q, %v", data, err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "config.toml"))
+	if err != nil || info.Mode().Perm() != 0o600 {
+		t.Fatalf("sandbox config.toml: %v, %v", info, err)
+	}
+	if data, _ := os.ReadFile(livePath); string(data) != `{"key":"live"}` {
+		t.Fatalf("live config changed: %q", data)
+	}
+	if active, err := ActiveProfile(tool); err != nil || active != "" {
+		t.Fatalf("active profile = %q, %v", active, err)
+	}
+}
@@ -0,0 +1,80 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// ExportScript writes a POSIX shell script to w that recreates the config
+// files of profile under $HOME, for machines without tokyo. Each file is
+// written from a heredoc to a temporary file, made private (0600, as switch
+// leaves live files) and renamed into place.
+func ExportScript(t Tool, profile string, w io.Writer) error {
+	if err := requireProfile(t, profile); err != nil {
+		return err
+	}
+	pairs, err := profilePairs(t, profile)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "#!/bin/sh\n# Written by tokyo: installs the %s profile %q.\nset -eu\numask 077\n", t.DisplayName, profile)
+	for i, pair := range pairs {
+		name := filepath.Base(pair.dst)
+		if err := ensureRegularFile(pair.src); err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return newUserError(ErrProfileMissingFile, "profile %q is missing file: %s", profile, name)
+			}
+			return err
+		}
+		data, err := readStored(pair.src)
+		if err != nil {
+			return err
+		}
+		if bytes.IndexByte(data, 0) >= 0 {
+			return fmt.Errorf("cannot write binary file %s to a script", name)
+		}
+
+		target := `"$HOME/` + filepath.ToSlash(t.ConfigRelPaths[i]) + `"`
+		tmp := `"$HOME/` + filepath.ToSlash(t.ConfigRelPaths[i]) + `.tokyo-tmp"`
+		delim := heredocDelimiter(data)
+		fmt.Fprintf(&b, "\nmkdir -p \"$HOME/%s\"\n", filepath.ToSlash(filepath.Dir(t.ConfigRelPaths[i])))
+		fmt.Fprintf(&b, "cat > %s <<'%s'\n%s", tmp, delim, data)
+		if len(data) > 0 && !bytes.HasSuffix(data, []byte("\n")) {
+			// The heredoc ends the file with a newline the profile does not
+			// have; command substitution strips exactly that one.
+			fmt.Fprintf(&b, "\n%s\ncontent=$(cat %s)\nprintf '%%s' \"$content\" > %s\n", delim, tmp, tmp)
+		} else {
+			fmt.Fprintf(&b, "%s\n", delim)
+		}
+		fmt.Fprintf(&b, "chmod 600 %s\nmv -f %s %s\n", tmp, tmp, target)
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// heredocDelimiter returns a heredoc delimiter that no line of data equals.
+func heredocDelimiter(data []byte) string {
+	lines := strings.Split(string(data), "\n")
+	delim := "TOKYO_EOF"
+	for n := 1; ; n++ {
+		clash := false
+		for _, line := range lines {
+			if line == delim {
+				clash = true
+				break
+			}
+		}
+		if !clash {
+			return delim
+		}
+		delim = fmt.Sprintf("TOKYO_EOF_%d", n)
+	}
+}
@@ -0,0 +1,72 @@
+package profile
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestExportScriptRecreatesFiles(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home)
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	contents := map[string]string{
+		// No trailing newline, and a line equal to the default delimiter.
+		"config.toml": "model = \"o3\"\nTOKYO_EOF\nnote = '$HOME `x`'",
+		"auth.json":   "{\"token\":\"a\"}\n",
+	}
+	for name, data := range contents {
+		if err := os.WriteFile(filepath.Join(codexDir, name), []byte(data), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+
+	var script bytes.Buffer
+	if err := ExportScript(tool, "work", &script); err != nil {
+		t.Fatalf("ExportScript: %v", err)
+	}
+
+	target := t.TempDir()
+	run := exec.Command(sh, "-s")
+	run.Stdin = &script
+	run.Env = []string{"HOME=" + target, "PATH=" + os.Getenv("PATH")}
+	if out, err := run.CombinedOutput(); err != nil {
+		t.Fatalf("run script: %v\n%s", err, out)
+	}
+	for name, want := range contents {
+		path := filepath.Join(target, ".codex", name)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if string(data) != want {
+			t.Fatalf("%s = %q, want %q", name, data, want)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatalf("stat %s: %v", name, err)
+		}
+		if info.Mode().Perm() != 0o600 {
+			t.Fatalf("%s mode = %o, want 600", name, info.Mode().Perm())
+		}
+	}
+}
+
+func TestHeredocDelimiter(t *testing.T) {
+	if got := heredocDelimiter([]byte("a\nTOKYO_EOF\nTOKYO_EOF_1\n")); got != "TOKYO_EOF_2" {
+		t.Fatalf("heredocDelimiter = %q", got)
+	}
+}
@@ -0,0 +1,92 @@
+package profile
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ErrWrongPassphrase is returned by Unseal when the passphrase does not open
+// the data or the data was changed after sealing.
+var ErrWrongPassphrase = errors.New("wrong passphrase or damaged file")
+
+// ErrNotSealed is returned by Unseal for data Seal did not produce.
+var ErrNotSealed = errors.New("not a sealed tokyo file")
+
+// sealMagic starts every sealed file. The header it begins, magic and salt,
+// is authenticated along with the content.
+const sealMagic = "tokyo-sealed-v1\n"
+
+const (
+	sealSaltSize = 16
+	// scrypt parameters recommended for interactive use in 2017, which
+	// take about 100 ms and 32 MiB per key.
+	sealScryptN = 1 << 15
+	sealScryptR = 8
+	sealScryptP = 1
+)
+
+// Seal encrypts data with a key derived from passphrase, for carrying a
+// store on a USB stick or other medium that may be lost. The result holds
+// everything Unseal needs besides the passphrase.
+func Seal(data []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, errors.New("empty passphrase")
+	}
+	header := make([]byte, len(sealMagic)+sealSaltSize)
+	copy(header, sealMagic)
+	salt := header[len(sealMagic):]
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := sealCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(header, nonce...)
+	return aead.Seal(out, nonce, data, header), nil
+}
+
+// Unseal returns the data sealed with passphrase.
+func Unseal(sealed []byte, passphrase string) ([]byte, error) {
+	if !IsSealed(sealed) {
+		return nil, ErrNotSealed
+	}
+	headerSize := len(sealMagic) + sealSaltSize
+	if len(sealed) < headerSize+chacha20poly1305.NonceSizeX+chacha20poly1305.Overhead {
+		return nil, fmt.Errorf("%w: file is truncated", ErrWrongPassphrase)
+	}
+	header := sealed[:headerSize]
+	aead, err := sealCipher(passphrase, header[len(sealMagic):])
+	if err != nil {
+		return nil, err
+	}
+	nonce := sealed[headerSize : headerSize+aead.NonceSize()]
+	data, err := aead.Open(nil, nonce, sealed[headerSize+aead.NonceSize():], header)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return data, nil
+}
+
+// IsSealed reports whether data looks like the output of Seal.
+func IsSealed(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(sealMagic))
+}
+
+func sealCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, sealScryptN, sealScryptR, sealScryptP, chacha20poly1305.KeySize)
+	if err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
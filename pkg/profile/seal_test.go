@@ -0,0 +1,43 @@
+package profile
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestSealRoundTrip(t *testing.T) {
+	data := []byte("the whole store")
+	sealed, err := Seal(data, "correct horse")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if !IsSealed(sealed) || bytes.Contains(sealed, data) {
+		t.Fatal("expected sealed output that does not reveal the data")
+	}
+	got, err := Unseal(sealed, "correct horse")
+	if err != nil {
+		t.Fatalf("Unseal: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("Unseal = %q, want %q", got, data)
+	}
+
+	if _, err := Unseal(sealed, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("wrong passphrase: expected ErrWrongPassphrase, got %v", err)
+	}
+	tampered := bytes.Clone(sealed)
+	tampered[len(sealMagic)] ^= 1 // the salt is authenticated too
+	if _, err := Unseal(tampered, "correct horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("tampered header: expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := Unseal(sealed[:len(sealMagic)+4], "correct horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("truncated: expected ErrWrongPassphrase, got %v", err)
+	}
+	if _, err := Unseal(data, "correct horse"); !errors.Is(err, ErrNotSealed) {
+		t.Fatalf("plain data: expected ErrNotSealed, got %v", err)
+	}
+	if _, err := Seal(data, ""); err == nil {
+		t.Fatal("expected an empty passphrase to be refused")
+	}
+}
@@ -0,0 +1,134 @@
+package profile
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// secretPatterns are credential shapes the secret scanner looks for, most
+// specific first.
+var secretPatterns = []struct {
+	kind string
+	re   *regexp.Regexp
+}{
+	{"Anthropic API key", regexp.MustCompile(`\bsk-ant-[A-Za-z0-9_-]{20,}`)},
+	{"OpenAI API key", regexp.MustCompile(`\bsk-(?:proj-)?[A-Za-z0-9_-]{20,}`)},
+	{"GitHub token", regexp.MustCompile(`\b(?:gh[pousr]_[A-Za-z0-9]{20,}|github_pat_[A-Za-z0-9_]{22,})`)},
+	{"AWS access key", regexp.MustCompile(`\bAKIA[A-Z0-9]{16}\b`)},
+	{"Slack token", regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+	{"Google API key", regexp.MustCompile(`\bAIza[A-Za-z0-9_-]{35}`)},
+	{"private key", regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----`)},
+}
+
+// SecretFinding is a value that looks like a credential outside the places
+// a tool keeps its own.
+type SecretFinding struct {
+	// File is the base name of the config file.
+	File string
+	// Location is the JSON pointer of the value in a JSON file, or
+	// "line N" in other files.
+	Location string
+	Kind     string
+}
+
+func (f SecretFinding) String() string {
+	return fmt.Sprintf("%s %s looks like a %s", f.File, f.Location, f.Kind)
+}
+
+// ScanLiveSecrets scans t's live config files, as save is about to store
+// them; see ScanSecrets.
+func ScanLiveSecrets(t Tool) ([]SecretFinding, error) {
+	files, err := readLiveFiles(t)
+	if err != nil {
+		return nil, err
+	}
+	return ScanSecrets(t, files), nil
+}
+
+// ScanSecrets looks for values resembling API keys and tokens in files, by
+// base name, skipping t.SecretFields, where the tool is expected to keep its
+// own credentials. Findings are sorted by file.
+func ScanSecrets(t Tool, files map[string][]byte) []SecretFinding {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var findings []SecretFinding
+	for _, name := range names {
+		if t.expectsSecret(name, "") {
+			continue
+		}
+		var doc any
+		if strings.HasSuffix(name, ".json") && json.Unmarshal(files[name], &doc) == nil {
+			scanJSON(t, name, "", doc, &findings)
+			continue
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(files[name]))
+		for n := 1; scanner.Scan(); n++ {
+			if kind := secretKind(scanner.Text()); kind != "" {
+				findings = append(findings, SecretFinding{File: name, Location: "line " + strconv.Itoa(n), Kind: kind})
+			}
+		}
+	}
+	return findings
+}
+
+// scanJSON walks a decoded JSON value, checking every string in it.
+func scanJSON(t Tool, name, pointer string, v any, findings *[]SecretFinding) {
+	if t.expectsSecret(name, pointer) {
+		return
+	}
+	switch v := v.(type) {
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			escaped := strings.ReplaceAll(strings.ReplaceAll(k, "~", "~0"), "/", "~1")
+			scanJSON(t, name, pointer+"/"+escaped, v[k], findings)
+		}
+	case []any:
+		for i, item := range v {
+			scanJSON(t, name, pointer+"/"+strconv.Itoa(i), item, findings)
+		}
+	case string:
+		if kind := secretKind(v); kind != "" {
+			*findings = append(*findings, SecretFinding{File: name, Location: pointer, Kind: kind})
+		}
+	}
+}
+
+func secretKind(s string) string {
+	for _, p := range secretPatterns {
+		if p.re.MatchString(s) {
+			return p.kind
+		}
+	}
+	return ""
+}
+
+// expectsSecret reports whether the value at pointer in file name is one of
+// t.SecretFields: the whole file ("auth.json") or a JSON subtree
+// ("settings.json#/env/ANTHROPIC_API_KEY").
+func (t Tool) expectsSecret(name, pointer string) bool {
+	for _, field := range t.SecretFields {
+		file, prefix, _ := strings.Cut(field, "#")
+		if file != name {
+			continue
+		}
+		if prefix == "" || pointer == prefix || strings.HasPrefix(pointer, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
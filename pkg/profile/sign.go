@@ -0,0 +1,215 @@
+package profile
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// SignatureNamespace is the namespace bundle signatures are made in, so a
+// signature made for another purpose with the same key is not accepted:
+// ssh-keygen -Y verify -n tokyo-bundle checks them too.
+const SignatureNamespace = "tokyo-bundle"
+
+// ErrBadSignature is returned by VerifyBundle when a signature does not
+// match the bundle or was not made by a trusted key.
+var ErrBadSignature = errors.New("bad bundle signature")
+
+const (
+	sshsigMagic   = "SSHSIG"
+	sshsigVersion = 1
+	sshsigHash    = "sha512"
+	sshsigPEM     = "SSH SIGNATURE"
+)
+
+// sshsigBlob is the signature file format of ssh-keygen -Y sign.
+type sshsigBlob struct {
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshsigSignedData follows the magic in the message an SSH signature signs.
+type sshsigSignedData struct {
+	Namespace     string
+	Reserved      []byte
+	HashAlgorithm string
+	Hash          []byte
+}
+
+// sshsigMessage returns the message an SSH signature over data signs.
+func sshsigMessage(data []byte) []byte {
+	hash := sha512.Sum512(data)
+	return append([]byte(sshsigMagic), ssh.Marshal(sshsigSignedData{
+		Namespace:     SignatureNamespace,
+		HashAlgorithm: sshsigHash,
+		Hash:          hash[:],
+	})...)
+}
+
+// SignBundle signs data with the SSH private key at keyPath and returns the
+// armored signature, in the format of ssh-keygen -Y sign. A key protected by
+// a passphrase, or given as its .pub file, is used through ssh-agent.
+func SignBundle(data []byte, keyPath string) ([]byte, error) {
+	signer, err := loadSigner(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	signed := sshsigMessage(data)
+	var sig *ssh.Signature
+	if algSigner, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		// ssh-keygen refuses SHA-1 RSA signatures.
+		sig, err = algSigner.SignWithAlgorithm(rand.Reader, signed, ssh.KeyAlgoRSASHA512)
+	} else {
+		sig, err = signer.Sign(rand.Reader, signed)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("sign with %s: %w", keyPath, err)
+	}
+
+	blob := append([]byte(sshsigMagic), ssh.Marshal(sshsigBlob{
+		Version:       sshsigVersion,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     SignatureNamespace,
+		HashAlgorithm: sshsigHash,
+		Signature:     ssh.Marshal(sig),
+	})...)
+	return pem.EncodeToMemory(&pem.Block{Type: sshsigPEM, Bytes: blob}), nil
+}
+
+// VerifyBundle checks that signature, as written by SignBundle or
+// ssh-keygen -Y sign -n tokyo-bundle, was made over data by one of the
+// public keys in the authorized_keys style file at keysPath.
+func VerifyBundle(data, signature []byte, keysPath string) error {
+	trusted, err := readPublicKeys(keysPath)
+	if err != nil {
+		return err
+	}
+
+	block, _ := pem.Decode(signature)
+	if block == nil || block.Type != sshsigPEM || !bytes.HasPrefix(block.Bytes, []byte(sshsigMagic)) {
+		return fmt.Errorf("%w: not an SSH signature", ErrBadSignature)
+	}
+	var blob sshsigBlob
+	if err := ssh.Unmarshal(block.Bytes[len(sshsigMagic):], &blob); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	if blob.Version != sshsigVersion {
+		return fmt.Errorf("%w: unsupported version %d", ErrBadSignature, blob.Version)
+	}
+	if blob.Namespace != SignatureNamespace {
+		return fmt.Errorf("%w: made for %q, not %q", ErrBadSignature, blob.Namespace, SignatureNamespace)
+	}
+	if blob.HashAlgorithm != sshsigHash {
+		return fmt.Errorf("%w: unsupported hash %q", ErrBadSignature, blob.HashAlgorithm)
+	}
+	key, err := ssh.ParsePublicKey(blob.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	if !containsKey(trusted, key) {
+		return fmt.Errorf("%w: signed by %s, which %s does not list", ErrBadSignature, ssh.FingerprintSHA256(key), keysPath)
+	}
+
+	var sig ssh.Signature
+	if err := ssh.Unmarshal(blob.Signature, &sig); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	signed := sshsigMessage(data)
+	if err := key.Verify(signed, &sig); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadSignature, err)
+	}
+	return nil
+}
+
+// loadSigner reads the private key at path, falling back to ssh-agent for a
+// passphrase-protected key or a public key.
+func loadSigner(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	signer, err := ssh.ParsePrivateKey(data)
+	if err == nil {
+		return signer, nil
+	}
+
+	var public ssh.PublicKey
+	var missing *ssh.PassphraseMissingError
+	if errors.As(err, &missing) && missing.PublicKey != nil {
+		public = missing.PublicKey
+	} else if key, _, _, _, parseErr := ssh.ParseAuthorizedKey(data); parseErr == nil {
+		public = key
+	} else {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return agentSigner(path, public)
+}
+
+func agentSigner(path string, public ssh.PublicKey) (ssh.Signer, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("%s needs ssh-agent (SSH_AUTH_SOCK is not set)", path)
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("connect to ssh-agent: %w", err)
+	}
+	signers, err := agent.NewClient(conn).Signers()
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("ssh-agent: %w", err)
+	}
+	for _, signer := range signers {
+		if bytes.Equal(signer.PublicKey().Marshal(), public.Marshal()) {
+			// The connection stays open for the signer; tokyo exits soon
+			// after signing.
+			return signer, nil
+		}
+	}
+	conn.Close()
+	return nil, fmt.Errorf("ssh-agent does not hold the key of %s (ssh-add it first)", path)
+}
+
+// readPublicKeys reads every key of an authorized_keys style file, such as
+// a single id_ed25519.pub.
+func readPublicKeys(path string) ([]ssh.PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	var keys []ssh.PublicKey
+	for rest := data; len(bytes.TrimSpace(rest)) > 0; {
+		key, _, _, next, err := ssh.ParseAuthorizedKey(rest)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", path, err)
+		}
+		keys = append(keys, key)
+		rest = next
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s holds no public keys", path)
+	}
+	return keys, nil
+}
+
+func containsKey(keys []ssh.PublicKey, key ssh.PublicKey) bool {
+	for _, k := range keys {
+		if bytes.Equal(k.Marshal(), key.Marshal()) {
+			return true
+		}
+	}
+	return false
+}
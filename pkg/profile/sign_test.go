@@ -0,0 +1,129 @@
+package profile
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// writeTestKey writes an ed25519 key pair as id (OpenSSH private key) and
+// id.pub, returning their paths.
+func writeTestKey(t *testing.T, dir, name string) (string, string) {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("marshal key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatalf("signer: %v", err)
+	}
+	keyPath := filepath.Join(dir, name)
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatalf("write key: %v", err)
+	}
+	if err := os.WriteFile(keyPath+".pub", ssh.MarshalAuthorizedKey(signer.PublicKey()), 0o644); err != nil {
+		t.Fatalf("write public key: %v", err)
+	}
+	return keyPath, keyPath + ".pub"
+}
+
+func TestSignBundleVerifies(t *testing.T) {
+	dir := t.TempDir()
+	key, pub := writeTestKey(t, dir, "lead")
+	_, otherPub := writeTestKey(t, dir, "other")
+	data := []byte("bundle content")
+
+	sig, err := SignBundle(data, key)
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+	if err := VerifyBundle(data, sig, pub); err != nil {
+		t.Fatalf("VerifyBundle: %v", err)
+	}
+
+	if err := VerifyBundle([]byte("bundle content!"), sig, pub); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("tampered bundle: err = %v, want ErrBadSignature", err)
+	}
+	if err := VerifyBundle(data, sig, otherPub); !errors.Is(err, ErrBadSignature) {
+		t.Fatalf("untrusted key: err = %v, want ErrBadSignature", err)
+	}
+
+	// A file listing several keys trusts each of them.
+	both := filepath.Join(dir, "team.pub")
+	var keys []byte
+	for _, p := range []string{otherPub, pub} {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatalf("read %s: %v", p, err)
+		}
+		keys = append(keys, data...)
+	}
+	if err := os.WriteFile(both, keys, 0o644); err != nil {
+		t.Fatalf("write keys: %v", err)
+	}
+	if err := VerifyBundle(data, sig, both); err != nil {
+		t.Fatalf("VerifyBundle with several keys: %v", err)
+	}
+}
+
+func TestSignBundleMatchesSSHKeygen(t *testing.T) {
+	keygen, err := exec.LookPath("ssh-keygen")
+	if err != nil {
+		t.Skip("ssh-keygen not available")
+	}
+	dir := t.TempDir()
+	key, pub := writeTestKey(t, dir, "lead")
+	bundle := filepath.Join(dir, "claude-work.tar.gz")
+	data := []byte("bundle content")
+	if err := os.WriteFile(bundle, data, 0o600); err != nil {
+		t.Fatalf("write bundle: %v", err)
+	}
+
+	// ssh-keygen accepts our signature...
+	sig, err := SignBundle(data, key)
+	if err != nil {
+		t.Fatalf("SignBundle: %v", err)
+	}
+	if err := os.WriteFile(bundle+".sig", sig, 0o644); err != nil {
+		t.Fatalf("write signature: %v", err)
+	}
+	pubData, err := os.ReadFile(pub)
+	if err != nil {
+		t.Fatalf("read public key: %v", err)
+	}
+	allowed := filepath.Join(dir, "allowed_signers")
+	if err := os.WriteFile(allowed, append([]byte("lead "), pubData...), 0o644); err != nil {
+		t.Fatalf("write allowed signers: %v", err)
+	}
+	verify := exec.Command(keygen, "-Y", "verify", "-f", allowed, "-I", "lead", "-n", SignatureNamespace, "-s", bundle+".sig")
+	verify.Stdin = bytes.NewReader(data)
+	if out, err := verify.CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen -Y verify: %v\n%s", err, out)
+	}
+
+	// ...and we accept ssh-keygen's.
+	os.Remove(bundle + ".sig")
+	if out, err := exec.Command(keygen, "-Y", "sign", "-f", key, "-n", SignatureNamespace, bundle).CombinedOutput(); err != nil {
+		t.Fatalf("ssh-keygen -Y sign: %v\n%s", err, out)
+	}
+	sig, err = os.ReadFile(bundle + ".sig")
+	if err != nil {
+		t.Fatalf("read signature: %v", err)
+	}
+	if err := VerifyBundle(data, sig, pub); err != nil {
+		t.Fatalf("VerifyBundle of ssh-keygen signature: %v", err)
+	}
+}
@@ -0,0 +1,61 @@
+package profile
+
+import (
+	"errors"
+	"os"
+)
+
+// Snapshot holds a copy of a tool's live config files and current profile so
+// they can be put back after a temporary switch.
+type Snapshot struct {
+	tool         Tool
+	dir          string
+	entries      []rollbackEntry
+	profile      string
+	profileKnown bool
+}
+
+// TakeSnapshot copies the live config files of t into a private directory
+// inside the tool's store.
+func TakeSnapshot(t Tool) (*Snapshot, error) {
+	configFiles, err := t.configFiles()
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]filePair, 0, len(configFiles))
+	for _, dst := range configFiles {
+		pairs = append(pairs, filePair{dst: dst})
+	}
+
+	snap := &Snapshot{tool: t}
+	if current, err := readCurrentProfile(t); err == nil {
+		snap.profile = current
+		snap.profileKnown = true
+	}
+
+	dir, err := createRollbackDir(t)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := backupCurrentFiles(t, pairs, dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	snap.dir = dir
+	snap.entries = entries
+	return snap, nil
+}
+
+// Restore writes the captured files and current profile back and releases
+// the snapshot.
+func (s *Snapshot) Restore() error {
+	err := rollbackSwitch(s.tool, s.profile, s.profileKnown, s.entries)
+	return errors.Join(err, s.Discard())
+}
+
+// Discard releases the snapshot without restoring it.
+func (s *Snapshot) Discard() error {
+	return os.RemoveAll(s.dir)
+}
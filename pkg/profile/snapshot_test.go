@@ -0,0 +1,90 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"x":1}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"x":2}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"x":3}`), 0o600); err != nil {
+		t.Fatalf("write config (modified): %v", err)
+	}
+
+	snap, err := TakeSnapshot(tool)
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	if err := Switch(tool, "personal"); err != nil {
+		t.Fatalf("Switch personal: %v", err)
+	}
+	if err := snap.Restore(); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	data, err := os.ReadFile(configPath)
+	if err != nil {
+		t.Fatalf("read config: %v", err)
+	}
+	if string(data) != `{"x":3}` {
+		t.Fatalf("expected modified config restored, got %q", string(data))
+	}
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "work (modified)" {
+		t.Fatalf("expected work (modified), got %q", status)
+	}
+	if _, err := os.Stat(snap.dir); !os.IsNotExist(err) {
+		t.Fatalf("expected snapshot dir removed, got %v", err)
+	}
+}
+
+func TestSnapshotRestoreRemovesCreatedFiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+
+	snap, err := TakeSnapshot(tool)
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+	if err := snap.Restore(); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	if _, err := os.Stat(configPath); !os.IsNotExist(err) {
+		t.Fatalf("expected config removed, got %v", err)
+	}
+}
@@ -0,0 +1,215 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// snapshotAttempts bounds how often save re-reads live files that keep
+// changing underneath it.
+const snapshotAttempts = 3
+
+// beforeSnapshotVerify runs between copying and re-reading live files. Tests
+// use it to simulate a tool rewriting its config mid-save.
+var beforeSnapshotVerify = func() {}
+
+// createSaveStaging creates a scratch directory inside t's store, on the same
+// filesystem as the profiles so the finished profile can be renamed into
+// place.
+func createSaveStaging(t Tool) (string, error) {
+	base, err := t.tokyoDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(base, 0o700); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(base, ".save-")
+}
+
+// tempProfilePrefix marks directories in profiles/ that save is still
+// building. Profile names cannot start with a dot, so they never collide
+// with real profiles and List skips them.
+const tempProfilePrefix = ".tmp-"
+
+// staleTempAge is how old an unfinished build directory must be before GC
+// treats it as left behind by a crash.
+const staleTempAge = time.Hour
+
+// createProfileBuildDir creates profiles/.tmp-<name>-<rand> for save to fill.
+func createProfileBuildDir(t Tool, profile string) (string, error) {
+	profilesDir, err := t.profilesDir()
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(profilesDir, 0o700); err != nil {
+		return "", err
+	}
+	return os.MkdirTemp(profilesDir, tempProfilePrefix+profile+"-")
+}
+
+// tempProfileDirs returns the unfinished build directories in profiles/.
+func tempProfileDirs(t Tool) ([]string, error) {
+	profilesDir, err := t.profilesDir()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(profilesDir)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), tempProfilePrefix) {
+			dirs = append(dirs, filepath.Join(profilesDir, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+// removeStaleTempProfiles deletes build directories older than staleTempAge
+// and returns how many it removed.
+func removeStaleTempProfiles(t Tool, now time.Time) (int, error) {
+	dirs, err := tempProfileDirs(t)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, dir := range dirs {
+		info, err := os.Stat(dir)
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return removed, err
+		}
+		if now.Sub(info.ModTime()) < staleTempAge {
+			continue
+		}
+		if err := os.RemoveAll(dir); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+// snapshotLiveFiles copies every live config file of t into dir and returns
+// the copies in config order. A snapshot only counts when a second read of
+// every live file matches what was copied; otherwise the tool was writing
+// its config concurrently and the snapshot is retaken.
+func snapshotLiveFiles(t Tool, dir string) ([]string, error) {
+	configFiles, err := t.configFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	var changed string
+	for range snapshotAttempts {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, err
+		}
+		if err := os.Mkdir(dir, 0o700); err != nil {
+			return nil, err
+		}
+
+		copies := make([]string, 0, len(configFiles))
+		hashes := make([]string, 0, len(configFiles))
+		sources := make([]string, 0, len(configFiles))
+		for _, src := range configFiles {
+			dst := filepath.Join(dir, filepath.Base(src))
+			live, err := t.liveFile(src)
+			if err != nil {
+				return nil, fmt.Errorf("snapshot %s: %w", src, err)
+			}
+			if err := t.checkFileSize(live); err != nil {
+				return nil, err
+			}
+			if err := copyFile(live, dst); err != nil {
+				if errors.Is(err, fs.ErrNotExist) {
+					return nil, newUserError(ErrConfigFileNotFound, "config file not found: %s", src)
+				}
+				return nil, fmt.Errorf("snapshot %s: %w", src, err)
+			}
+			hash, err := fileHash(dst)
+			if err != nil {
+				return nil, fmt.Errorf("snapshot %s: %w", src, err)
+			}
+			copies = append(copies, dst)
+			hashes = append(hashes, hash)
+			sources = append(sources, live)
+			t.logger().Debug("staged", "src", src, "stage", dst)
+		}
+
+		beforeSnapshotVerify()
+		changed = ""
+		for i, src := range configFiles {
+			hash, err := fileHash(sources[i])
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("verify %s: %w", src, err)
+			}
+			if hash != hashes[i] {
+				changed = src
+				break
+			}
+		}
+		if changed == "" {
+			return copies, nil
+		}
+		t.logger().Debug("config changed during snapshot, retrying", "file", changed)
+	}
+	return nil, newUserError(ErrConfigChanged, "config file kept changing while saving: %s (try again)", changed)
+}
+
+// commitProfileDir renames the finished profile directory build to dst. An
+// existing dst is only replaced when force is set; it is first moved to old
+// so it can be put back if the rename fails.
+func commitProfileDir(build, dst, old string, force bool) error {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o700); err != nil {
+		return err
+	}
+	if !force {
+		return renameNoReplace(build, dst)
+	}
+
+	if err := os.Rename(dst, old); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := os.Rename(build, dst); err != nil {
+		if restoreErr := os.Rename(old, dst); restoreErr != nil && !errors.Is(restoreErr, fs.ErrNotExist) {
+			return errors.Join(err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// renameNoReplace renames a directory, reporting os.ErrExist when dst
+// already exists. rename(2) replaces an empty directory, so existence is
+// checked first; the remaining window is closed by the non-empty check of
+// rename itself for any profile that has files.
+func renameNoReplace(src, dst string) error {
+	if _, err := os.Lstat(dst); err == nil {
+		return &os.LinkError{Op: "rename", Old: src, New: dst, Err: os.ErrExist}
+	}
+	if err := os.Rename(src, dst); err != nil {
+		if isNotEmpty(err) {
+			return &os.LinkError{Op: "rename", Old: src, New: dst, Err: os.ErrExist}
+		}
+		return err
+	}
+	return nil
+}
+
+func isNotEmpty(err error) bool {
+	return errors.Is(err, fs.ErrExist) || errors.Is(err, syscall.ENOTEMPTY)
+}
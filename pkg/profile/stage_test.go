@@ -0,0 +1,108 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSaveRetakesSnapshotWhenConfigChanges(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{"v":0}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	version := 0
+	rewrite := func(limit int) func() {
+		writes := 0
+		return func() {
+			if writes >= limit {
+				return
+			}
+			writes++
+			version++
+			if err := os.WriteFile(configPath, []byte(fmt.Sprintf(`{"v":%d}`, version)), 0o600); err != nil {
+				t.Fatalf("rewrite config: %v", err)
+			}
+		}
+	}
+	t.Cleanup(func() { beforeSnapshotVerify = func() {} })
+
+	beforeSnapshotVerify = rewrite(1)
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	stored, err := os.ReadFile(filepath.Join(home, ".config", "tokyo", "claude", "profiles", "work", "settings.json"))
+	if err != nil {
+		t.Fatalf("read stored: %v", err)
+	}
+	if string(stored) != `{"v":1}` {
+		t.Fatalf("expected the settled content, got %q", stored)
+	}
+
+	beforeSnapshotVerify = rewrite(snapshotAttempts)
+	if err := Save(tool, "busy", false); !errors.Is(err, ErrConfigChanged) {
+		t.Fatalf("expected ErrConfigChanged, got %v", err)
+	}
+	if exists, _ := Exists(tool, "busy"); exists {
+		t.Fatalf("expected no profile to be created")
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(home, ".config", "tokyo", "claude", ".save-*"))
+	if len(leftovers) != 0 {
+		t.Fatalf("expected staging to be cleaned up, got %v", leftovers)
+	}
+}
+
+func TestListIgnoresUnfinishedSaves(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := ClaudeTool()
+	build, err := createProfileBuildDir(tool, "work")
+	if err != nil {
+		t.Fatalf("createProfileBuildDir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(build, "settings.json"), []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if base := filepath.Base(build); !strings.HasPrefix(base, tempProfilePrefix+"work-") {
+		t.Fatalf("unexpected build dir name %q", base)
+	}
+
+	profiles, err := List(tool)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(profiles) != 0 {
+		t.Fatalf("expected unfinished save to be hidden, got %v", profiles)
+	}
+
+	now := time.Now()
+	result, err := GC(tool, RetentionPolicy{}, now, false)
+	if err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if result.TempRemoved != 0 {
+		t.Fatalf("expected a fresh build dir to be kept")
+	}
+	if result, err = GC(tool, RetentionPolicy{}, now.Add(2*staleTempAge), false); err != nil {
+		t.Fatalf("GC: %v", err)
+	}
+	if result.TempRemoved != 1 {
+		t.Fatalf("expected the stale build dir to be removed, got %+v", result)
+	}
+	if _, err := os.Stat(build); !os.IsNotExist(err) {
+		t.Fatalf("expected %s to be gone, got %v", build, err)
+	}
+}
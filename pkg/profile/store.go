@@ -0,0 +1,78 @@
+package profile
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// StoreSettings describe how a tool's store lays out profile payloads on
+// disk and how they are compared with the live files. They live in
+// <store>/store.json because they must travel with the data they describe.
+type StoreSettings struct {
+	// ContentAddressed stores each distinct file payload once under
+	// blobs/<sha256>, with profiles holding a manifest of hashes.
+	ContentAddressed bool `json:"contentAddressed,omitempty"`
+	// Compression applies to newly written payloads; see CompressionGzip.
+	Compression string `json:"compression,omitempty"`
+	// Compare selects how live files are compared with stored copies; see
+	// CompareJSON.
+	Compare string `json:"compare,omitempty"`
+	// Ignore holds ignore rules added on top of the tool's built-in ones;
+	// see AddIgnoreRule.
+	Ignore []string `json:"ignore,omitempty"`
+	// FollowDirSymlinks allows symlinked directories on the way to the
+	// live config files; see SetFollowDirSymlinks.
+	FollowDirSymlinks bool `json:"followDirSymlinks,omitempty"`
+	// SwitchMode selects how switches replace the live files; see
+	// SwitchModeSymlink.
+	SwitchMode string `json:"switchMode,omitempty"`
+	// HardLinks makes saves hard-link files identical to one already
+	// stored in another profile instead of keeping a copy; see
+	// SetHardLinks.
+	HardLinks bool `json:"hardLinks,omitempty"`
+}
+
+func (t Tool) storeSettingsFile() (string, error) {
+	base, err := t.tokyoDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, "store.json"), nil
+}
+
+// ReadStoreSettings returns the store settings of t. A store without a
+// settings file uses the defaults.
+func ReadStoreSettings(t Tool) (StoreSettings, error) {
+	path, err := t.storeSettingsFile()
+	if err != nil {
+		return StoreSettings{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return StoreSettings{}, nil
+		}
+		return StoreSettings{}, err
+	}
+	var settings StoreSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return StoreSettings{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return settings, nil
+}
+
+func writeStoreSettings(t Tool, settings StoreSettings) error {
+	path, err := t.storeSettingsFile()
+	if err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0o600)
+}
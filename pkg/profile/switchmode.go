@@ -0,0 +1,294 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// SwitchModeCopy switches by copying the profile's files over the live
+	// ones.
+	SwitchModeCopy = ""
+	// SwitchModeSymlink switches by pointing each live config file at the
+	// profile's own copy with a symlink, so the tool edits the profile
+	// directly and the live files cannot drift from it.
+	SwitchModeSymlink = "symlink"
+)
+
+// ValidateSwitchMode checks that mode is a supported switch mode setting.
+func ValidateSwitchMode(mode string) error {
+	switch mode {
+	case SwitchModeCopy, SwitchModeSymlink:
+		return nil
+	}
+	return fmt.Errorf("unsupported switch mode %q (supported: copy, symlink)", mode)
+}
+
+// SetSwitchMode changes how t switches profiles and migrates the live files
+// of the active profile: to symlinks into the profile when turning symlink
+// mode on, and back to plain copies when turning it off. Symlink mode needs
+// a store that keeps each profile's files as-is, so it is refused while the
+// store is content-addressed or compressed, and it is refused while the live
+// files differ from the active profile.
+func SetSwitchMode(t Tool, mode string) error {
+	if mode == "copy" {
+		mode = SwitchModeCopy
+	}
+	if err := ValidateSwitchMode(mode); err != nil {
+		return err
+	}
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return err
+	}
+	if settings.SwitchMode == mode {
+		return nil
+	}
+
+	switch mode {
+	case SwitchModeSymlink:
+		if err := requirePlainStore(settings); err != nil {
+			return err
+		}
+		active, err := ActiveProfile(t)
+		if err != nil {
+			return err
+		}
+		if active != "" {
+			if err := linkActiveProfile(t, active); err != nil {
+				return err
+			}
+		}
+	case SwitchModeCopy:
+		if err := materializeLinks(t); err != nil {
+			return err
+		}
+	}
+
+	settings.SwitchMode = mode
+	return writeStoreSettings(t, settings)
+}
+
+func (t Tool) switchMode() (string, error) {
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return "", err
+	}
+	return settings.SwitchMode, nil
+}
+
+func requirePlainStore(settings StoreSettings) error {
+	if settings.ContentAddressed || settings.Compression != CompressionNone {
+		return errors.New("symlink switching needs each profile to keep its files as-is; it cannot be combined with content-addressed or compressed storage")
+	}
+	if settings.HardLinks {
+		return errors.New("symlink switching cannot be combined with hard links, since the tool's edits would reach every profile sharing a file; run 'store hardlinks off' first")
+	}
+	return nil
+}
+
+func linkActiveProfile(t Tool, active string) error {
+	match, err := matches(t, active)
+	if err != nil {
+		return err
+	}
+	if !match {
+		return fmt.Errorf("the live config files differ from the active profile %q; save or switch before changing the switch mode", active)
+	}
+	pairs, err := profilePairs(t, active)
+	if err != nil {
+		return err
+	}
+	links, err := stageProfileLinks(t, active, pairs)
+	if err != nil {
+		return err
+	}
+	defer cleanupStageFiles(links)
+	for _, pair := range pairs {
+		if err := os.Rename(links[pair.dst], pair.dst); err != nil {
+			return fmt.Errorf("link %s: %w", pair.dst, err)
+		}
+		delete(links, pair.dst)
+		t.logger().Debug("linked", "target", pair.dst, "src", pair.src)
+	}
+	return nil
+}
+
+// stageProfileLinks creates a symlink to each of the profile's files next to
+// the live file it replaces. Every file must be stored in the profile itself:
+// a link into a base profile or a compressed payload would not be the file
+// the tool expects.
+func stageProfileLinks(t Tool, profile string, pairs []filePair) (map[string]string, error) {
+	stageFiles := make(map[string]string, len(pairs))
+	for i, pair := range pairs {
+		own, err := t.profileFile(profile, filepath.Base(pair.dst))
+		if err != nil {
+			cleanupStageFiles(stageFiles)
+			return nil, err
+		}
+		if pair.src != own {
+			cleanupStageFiles(stageFiles)
+			return nil, fmt.Errorf("profile %q does not keep %s as a plain file of its own (it comes from a base profile or is compressed); save it again to use symlink switching", profile, filepath.Base(pair.dst))
+		}
+		if err := ensureRegularFile(own); err != nil {
+			cleanupStageFiles(stageFiles)
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil, newUserError(ErrProfileMissingFile, "profile is missing file: %s", filepath.Base(own))
+			}
+			return nil, err
+		}
+		if err := ensureParentDir(pair.dst); err != nil {
+			cleanupStageFiles(stageFiles)
+			return nil, fmt.Errorf("stage %s: %w", pair.dst, err)
+		}
+		link, err := tempLink(filepath.Dir(pair.dst), own)
+		if err != nil {
+			cleanupStageFiles(stageFiles)
+			return nil, fmt.Errorf("stage %s: %w", pair.dst, err)
+		}
+		stageFiles[pair.dst] = link
+		t.logger().Debug("staged", "src", own, "stage", link)
+		t.progress(ProgressStage, i+1, len(pairs))
+	}
+	return stageFiles, nil
+}
+
+// tempLink creates a uniquely named symlink to target in dir.
+func tempLink(dir, target string) (string, error) {
+	for range 10 {
+		f, err := os.CreateTemp(dir, ".tokyo-stage-")
+		if err != nil {
+			return "", err
+		}
+		name := f.Name()
+		f.Close()
+		if err := os.Remove(name); err != nil {
+			return "", err
+		}
+		if err := os.Symlink(target, name); err == nil {
+			return name, nil
+		} else if !errors.Is(err, fs.ErrExist) {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("create symlink in %s: too many collisions", dir)
+}
+
+// replaceWithLink atomically replaces path with a symlink to target.
+func replaceWithLink(path, target string) error {
+	link, err := tempLink(filepath.Dir(path), target)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(link, path); err != nil {
+		os.Remove(link)
+		return err
+	}
+	return nil
+}
+
+// managedLink reports whether path is a symlink tokyo created, one pointing
+// into t's profiles directory, and returns its target. Any other symlink is
+// not managed and stays rejected wherever a regular file is expected.
+func (t Tool) managedLink(path string) (string, bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return "", false, nil
+		}
+		return "", false, err
+	}
+	if info.Mode()&os.ModeSymlink == 0 {
+		return "", false, nil
+	}
+	target, err := os.Readlink(path)
+	if err != nil {
+		return "", false, err
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(path), target)
+	}
+	profilesDir, err := t.profilesDir()
+	if err != nil {
+		return "", false, err
+	}
+	if !withinDir(profilesDir, target) {
+		return "", false, nil
+	}
+	return target, true, nil
+}
+
+// withinDir reports whether path lies below dir.
+func withinDir(dir, path string) bool {
+	rel, err := filepath.Rel(dir, filepath.Clean(path))
+	return err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// liveFile returns the path to read the live config file at path from: the
+// profile's file when path is a managed symlink, path itself otherwise.
+func (t Tool) liveFile(path string) (string, error) {
+	target, ok, err := t.managedLink(path)
+	if err != nil || !ok {
+		return path, err
+	}
+	return target, nil
+}
+
+// materializeLinks replaces every managed symlink among t's live config
+// files with a copy of the file it points to.
+func materializeLinks(t Tool) error {
+	configFiles, err := t.configFiles()
+	if err != nil {
+		return err
+	}
+	for _, path := range configFiles {
+		target, ok, err := t.managedLink(path)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		tmpFile, err := os.CreateTemp(filepath.Dir(path), ".tokyo-stage-")
+		if err != nil {
+			return fmt.Errorf("copy %s: %w", path, err)
+		}
+		// The file was switched to already, within the limit then.
+		if err := copyFileToFile(target, tmpFile, -1); err != nil {
+			os.Remove(tmpFile.Name())
+			return fmt.Errorf("copy %s: %w", path, err)
+		}
+		if err := os.Rename(tmpFile.Name(), path); err != nil {
+			os.Remove(tmpFile.Name())
+			return fmt.Errorf("copy %s: %w", path, err)
+		}
+		t.logger().Debug("materialized", "target", path, "src", target)
+	}
+	return nil
+}
+
+// relinkLive points the managed symlinks among the live files at profile,
+// after the profile they pointed into was renamed.
+func relinkLive(t Tool, profile string) error {
+	pairs, err := profilePairs(t, profile)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		_, ok, err := t.managedLink(pair.dst)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		if err := replaceWithLink(pair.dst, pair.src); err != nil {
+			return fmt.Errorf("link %s: %w", pair.dst, err)
+		}
+	}
+	return nil
+}
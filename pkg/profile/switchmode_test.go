@@ -0,0 +1,135 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// enableSymlinkMode turns on symlink switching for tool, skipping the test
+// where symlinks cannot be created.
+func enableSymlinkMode(t *testing.T, tool Tool) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Symlink(dir, filepath.Join(dir, "probe")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if err := SetSwitchMode(tool, SwitchModeSymlink); err != nil {
+		t.Fatalf("SetSwitchMode: %v", err)
+	}
+}
+
+func assertLinked(t *testing.T, tool Tool, live, profile string) {
+	t.Helper()
+	target, ok, err := tool.managedLink(live)
+	if err != nil || !ok {
+		t.Fatalf("expected %s to be a managed symlink, got ok=%v err=%v", live, ok, err)
+	}
+	want, err := tool.profileFile(profile, filepath.Base(live))
+	if err != nil {
+		t.Fatalf("profileFile: %v", err)
+	}
+	if target != want {
+		t.Fatalf("%s links to %s, want %s", live, target, want)
+	}
+}
+
+func TestSymlinkSwitching(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	enableSymlinkMode(t, tool)
+	config := filepath.Join(codexDir, "config.toml")
+	assertLinked(t, tool, config, "personal")
+
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	assertLinked(t, tool, config, "work")
+	assertLinked(t, tool, filepath.Join(codexDir, "auth.json"), "work")
+
+	// Edits land in the profile, so the live files never drift from it.
+	if err := os.WriteFile(config, []byte("model = \"edited\"\n"), 0o600); err != nil {
+		t.Fatalf("write config.toml: %v", err)
+	}
+	stored, err := tool.profileFile("work", "config.toml")
+	if err != nil {
+		t.Fatalf("profileFile: %v", err)
+	}
+	if data, _ := os.ReadFile(stored); string(data) != "model = \"edited\"\n" {
+		t.Fatalf("expected the edit in the profile, got %q", data)
+	}
+	if current, err := Current(tool); err != nil || current != "work" {
+		t.Fatalf("Current = %q, %v; want work", current, err)
+	}
+	if err := SwitchWithStrategy(tool, "personal", SwitchMerge); err == nil {
+		t.Fatal("expected the merge strategy to be refused")
+	}
+
+	// Renaming the active profile repoints the links.
+	if err := Rename(tool, "work", "job"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	assertLinked(t, tool, config, "job")
+
+	// Turning symlink mode off leaves plain copies behind.
+	if err := SetSwitchMode(tool, "copy"); err != nil {
+		t.Fatalf("SetSwitchMode copy: %v", err)
+	}
+	if _, ok, _ := tool.managedLink(config); ok {
+		t.Fatal("expected config.toml to be a regular file again")
+	}
+	if data, _ := os.ReadFile(config); string(data) != "model = \"edited\"\n" {
+		t.Fatalf("config.toml = %q after turning symlink mode off", data)
+	}
+}
+
+func TestSymlinkSwitchingDeleteActive(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	enableSymlinkMode(t, tool)
+	if _, err := Delete(tool, "personal"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	config := filepath.Join(codexDir, "config.toml")
+	if _, ok, _ := tool.managedLink(config); ok {
+		t.Fatal("expected the deleted profile's files to be copied back")
+	}
+	assertLive(t, codexDir, "personal")
+}
+
+func TestSymlinkSwitchingRestoresLinks(t *testing.T) {
+	tool, codexDir := setupCodexProfiles(t)
+	enableSymlinkMode(t, tool)
+	snap, err := TakeSnapshot(tool)
+	if err != nil {
+		t.Fatalf("TakeSnapshot: %v", err)
+	}
+	if err := SetSwitchMode(tool, SwitchModeCopy); err != nil {
+		t.Fatalf("SetSwitchMode: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if err := snap.Restore(); err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+	assertLinked(t, tool, filepath.Join(codexDir, "config.toml"), "personal")
+}
+
+func TestSymlinkSwitchingNeedsPlainStore(t *testing.T) {
+	tool, _ := setupCodexProfiles(t)
+	if err := SetCompression(tool, CompressionGzip); err != nil {
+		t.Fatalf("SetCompression: %v", err)
+	}
+	if err := SetSwitchMode(tool, SwitchModeSymlink); err == nil {
+		t.Fatal("expected symlink mode to be refused with compression on")
+	}
+	if err := SetCompression(tool, "none"); err != nil {
+		t.Fatalf("SetCompression: %v", err)
+	}
+	enableSymlinkMode(t, tool)
+	if err := SetCompression(tool, CompressionGzip); err == nil {
+		t.Fatal("expected compression to be refused in symlink mode")
+	}
+	if err := EnableContentAddressing(tool); err == nil {
+		t.Fatal("expected content addressing to be refused in symlink mode")
+	}
+}
@@ -0,0 +1,72 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetFollowDirSymlinks allows or forbids symlinked directories on the way
+// from the home directory to t's config files, as dotfile managers create
+// when they link ~/.claude into a repository. Symlinked config files stay
+// rejected either way.
+func SetFollowDirSymlinks(t Tool, follow bool) error {
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return err
+	}
+	settings.FollowDirSymlinks = follow
+	return writeStoreSettings(t, settings)
+}
+
+// checkConfigDirs rejects config files reached through a symlinked directory
+// below home unless t follows directory symlinks. Tokyo replaces config files
+// by renaming over them, so a symlinked directory lets whoever controls its
+// target decide where those writes land.
+func (t Tool) checkConfigDirs(home string, files []string) error {
+	for _, file := range files {
+		rel, err := filepath.Rel(home, filepath.Dir(file))
+		if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+			continue
+		}
+		dir := home
+		for _, part := range strings.Split(rel, string(filepath.Separator)) {
+			dir = filepath.Join(dir, part)
+			info, err := os.Lstat(dir)
+			if errors.Is(err, fs.ErrNotExist) {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if info.Mode()&os.ModeSymlink == 0 {
+				continue
+			}
+			follow, err := t.followsDirSymlinks()
+			if err != nil {
+				return err
+			}
+			if follow {
+				break
+			}
+			target, _ := os.Readlink(dir)
+			return fmt.Errorf("%w: %s is a symlink to %s; tokyo replaces the config files inside it, so it only follows symlinked config directories you have opted into with 'tokyo %s store follow-symlinks on' (symlinked files stay rejected)",
+				ErrSymlinkNotAllowed, dir, target, t.Name)
+		}
+	}
+	return nil
+}
+
+func (t Tool) followsDirSymlinks() (bool, error) {
+	if t.FollowDirSymlinks {
+		return true, nil
+	}
+	settings, err := ReadStoreSettings(t)
+	if err != nil {
+		return false, err
+	}
+	return settings.FollowDirSymlinks, nil
+}
@@ -0,0 +1,89 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupSymlinkedClaudeDir makes ~/.claude a symlink to a dotfiles directory
+// holding settings.json.
+func setupSymlinkedClaudeDir(t *testing.T) (Tool, string) {
+	t.Helper()
+	home := t.TempDir()
+	dotfiles := filepath.Join(t.TempDir(), "dotfiles", "claude")
+	if err := os.MkdirAll(dotfiles, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dotfiles, "settings.json"), []byte(`{"model":"opus"}`), 0o600); err != nil {
+		t.Fatalf("write settings.json: %v", err)
+	}
+	if err := os.Symlink(dotfiles, filepath.Join(home, ".claude")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	return ClaudeTool().WithHome(home), dotfiles
+}
+
+func TestSymlinkedConfigDirRejectedByDefault(t *testing.T) {
+	tool, _ := setupSymlinkedClaudeDir(t)
+	err := Save(tool, "work", false)
+	if !errors.Is(err, ErrSymlinkNotAllowed) {
+		t.Fatalf("expected ErrSymlinkNotAllowed, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "store follow-symlinks on") {
+		t.Fatalf("expected the error to explain the opt-in, got %v", err)
+	}
+}
+
+func TestFollowDirSymlinks(t *testing.T) {
+	tool, dotfiles := setupSymlinkedClaudeDir(t)
+	if err := SetFollowDirSymlinks(tool, true); err != nil {
+		t.Fatalf("SetFollowDirSymlinks: %v", err)
+	}
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	target := filepath.Join(dotfiles, "settings.json")
+	if err := os.WriteFile(target, []byte(`{"model":"sonnet"}`), 0o600); err != nil {
+		t.Fatalf("write settings.json: %v", err)
+	}
+	if err := Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+	if err := Switch(tool, "work"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	data, err := os.ReadFile(target)
+	if err != nil {
+		t.Fatalf("read settings.json: %v", err)
+	}
+	if string(data) != `{"model":"opus"}` {
+		t.Fatalf("expected the switch to write through the directory symlink, got %s", data)
+	}
+
+	// A symlinked file is still rejected.
+	if err := os.Remove(target); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	elsewhere := filepath.Join(t.TempDir(), "settings.json")
+	if err := os.WriteFile(elsewhere, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := os.Symlink(elsewhere, target); err != nil {
+		t.Fatalf("symlink: %v", err)
+	}
+	if err := Switch(tool, "personal"); !errors.Is(err, ErrSymlinkNotAllowed) {
+		t.Fatalf("expected a symlinked file to be rejected, got %v", err)
+	}
+
+	// The Tool field works without the store setting.
+	if err := SetFollowDirSymlinks(tool, false); err != nil {
+		t.Fatalf("SetFollowDirSymlinks: %v", err)
+	}
+	tool.FollowDirSymlinks = true
+	if _, err := tool.configFiles(); err != nil {
+		t.Fatalf("configFiles with FollowDirSymlinks: %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package profile
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// ErrProfileReadOnly is returned when changing a profile that comes from the
+// system store.
+var ErrProfileReadOnly = errors.New("profile is read-only")
+
+// SystemStoreRootEnv names the environment variable that moves the system
+// store away from its default location.
+const SystemStoreRootEnv = "TOKYO_SYSTEM_HOME"
+
+// DefaultSystemStoreRoot returns the directory of the machine-wide store that
+// holds profiles provided by an organization: $TOKYO_SYSTEM_HOME when set,
+// otherwise %ProgramData%\tokyo on Windows and /etc/tokyo elsewhere.
+//
+// The system store has the layout of a user store. Its profiles are listed
+// and switched to like the user's own, but cannot be changed without
+// --system; a user profile of the same name takes precedence.
+func DefaultSystemStoreRoot() string {
+	if root := os.Getenv(SystemStoreRootEnv); root != "" {
+		return root
+	}
+	if runtime.GOOS == "windows" {
+		if data := os.Getenv("ProgramData"); data != "" {
+			return filepath.Join(data, "tokyo")
+		}
+	}
+	return filepath.Join(string(filepath.Separator), "etc", "tokyo")
+}
+
+// WithSystemStoreRoot returns a copy of t that reads system profiles from
+// root/<name>.
+func (t Tool) WithSystemStoreRoot(root string) Tool {
+	t.SystemStoreRoot = root
+	return t
+}
+
+// WithSystemStore returns a copy of t that works on the system store itself
+// instead of the user's, for administrators managing the profiles it
+// provides. Workspaces do not apply to the system store.
+func (t Tool) WithSystemStore() Tool {
+	t.StoreRoot = t.systemStoreRoot()
+	t.Workspace = ""
+	return t
+}
+
+func (t Tool) systemStoreRoot() string {
+	if t.SystemStoreRoot != "" {
+		return t.SystemStoreRoot
+	}
+	return DefaultSystemStoreRoot()
+}
+
+// systemProfilesDir returns the directory holding t's system profiles. ok is
+// false when t works on the system store itself, which then has no system
+// store behind it.
+func (t Tool) systemProfilesDir() (dir string, ok bool, err error) {
+	profilesDir, err := t.profilesDir()
+	if err != nil {
+		return "", false, err
+	}
+	dir = filepath.Join(t.systemStoreRoot(), t.Name, "profiles")
+	if filepath.Clean(dir) == filepath.Clean(profilesDir) {
+		return "", false, nil
+	}
+	return dir, true, nil
+}
+
+// systemProfileDir returns the directory of profile in the system store. ok
+// is false when the system store does not provide profile or the user store
+// has a profile of the same name, which takes precedence.
+func (t Tool) systemProfileDir(profile string) (dir string, ok bool, err error) {
+	user, err := t.userProfileDir(profile)
+	if err != nil {
+		return "", false, err
+	}
+	if exists, err := dirExists(user); err != nil || exists {
+		return "", false, err
+	}
+	systemDir, ok, err := t.systemProfilesDir()
+	if err != nil || !ok {
+		return "", false, err
+	}
+	dir = filepath.Join(systemDir, profile)
+	exists, err := dirExists(dir)
+	if err != nil || !exists {
+		return "", false, err
+	}
+	return dir, true, nil
+}
+
+// IsSystemProfile reports whether profile comes from the system store, and
+// is therefore read-only.
+func IsSystemProfile(t Tool, profile string) (bool, error) {
+	_, ok, err := t.systemProfileDir(profile)
+	return ok, err
+}
+
+// checkWritable refuses changes to a profile from the system store.
+func checkWritable(t Tool, profile string) error {
+	system, err := IsSystemProfile(t, profile)
+	if err != nil {
+		return err
+	}
+	if system {
+		return newUserError(ErrProfileReadOnly, "profile %q is provided by the system store and is read-only (copy it to a profile of your own, or change it with --system)", profile)
+	}
+	return nil
+}
+
+// listSystemProfiles returns the profiles of the system store, including any
+// a user profile of the same name hides.
+func listSystemProfiles(t Tool) ([]string, error) {
+	dir, ok, err := t.systemProfilesDir()
+	if err != nil || !ok {
+		return nil, err
+	}
+	return listProfilesIn(dir)
+}
+
+func dirExists(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return false, nil
+		}
+		return false, err
+	}
+	return info.IsDir(), nil
+}
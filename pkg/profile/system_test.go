@@ -0,0 +1,131 @@
+package profile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// setupSystemStore returns a codex tool whose system store provides the
+// profile "org", and the live config directory.
+func setupSystemStore(t *testing.T) (Tool, string) {
+	t.Helper()
+	home := t.TempDir()
+	tool := CodexTool().WithHome(home).WithSystemStoreRoot(t.TempDir())
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	writeCodexFiles(t, codexDir, "org")
+	if err := Save(tool.WithSystemStore(), "org", false); err != nil {
+		t.Fatalf("Save to the system store: %v", err)
+	}
+	writeCodexFiles(t, codexDir, "work")
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+	return tool, codexDir
+}
+
+func TestSystemProfilesListedAndSwitchable(t *testing.T) {
+	tool, codexDir := setupSystemStore(t)
+
+	profiles, err := List(tool)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !slices.Equal(profiles, []string{"org", "work"}) {
+		t.Fatalf("List = %v, want [org work]", profiles)
+	}
+	system, err := IsSystemProfile(tool, "org")
+	if err != nil || !system {
+		t.Fatalf("IsSystemProfile(org) = %v, %v", system, err)
+	}
+	if system, _ := IsSystemProfile(tool, "work"); system {
+		t.Fatal("work reported as a system profile")
+	}
+
+	if err := Switch(tool, "org"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	assertLive(t, codexDir, "org")
+	if current, err := Current(tool); err != nil || current != "org" {
+		t.Fatalf("Current = %q, %v", current, err)
+	}
+
+	// Administrators see only the system store.
+	admin, err := List(tool.WithSystemStore())
+	if err != nil {
+		t.Fatalf("List --system: %v", err)
+	}
+	if !slices.Equal(admin, []string{"org"}) {
+		t.Fatalf("List --system = %v, want [org]", admin)
+	}
+}
+
+func TestSystemProfilesReadOnly(t *testing.T) {
+	tool, _ := setupSystemStore(t)
+
+	if _, err := Delete(tool, "org"); !errors.Is(err, ErrProfileReadOnly) {
+		t.Fatalf("Delete: expected ErrProfileReadOnly, got %v", err)
+	}
+	if err := Rename(tool, "org", "mine"); !errors.Is(err, ErrProfileReadOnly) {
+		t.Fatalf("Rename: expected ErrProfileReadOnly, got %v", err)
+	}
+	if err := SetLocked(tool, "org", true); !errors.Is(err, ErrProfileReadOnly) {
+		t.Fatalf("SetLocked: expected ErrProfileReadOnly, got %v", err)
+	}
+
+	if err := Copy(tool, "org", "mine"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if system, _ := IsSystemProfile(tool, "mine"); system {
+		t.Fatal("a copy of a system profile should be the user's own")
+	}
+	if err := SetLocked(tool, "mine", true); err != nil {
+		t.Fatalf("SetLocked on the copy: %v", err)
+	}
+
+	if _, err := Delete(tool.WithSystemStore(), "org"); err != nil {
+		t.Fatalf("Delete --system: %v", err)
+	}
+	if exists, _ := Exists(tool, "org"); exists {
+		t.Fatal("expected org to be gone once deleted from the system store")
+	}
+}
+
+func TestUserProfileTakesPrecedence(t *testing.T) {
+	tool, codexDir := setupSystemStore(t)
+
+	if err := Save(tool, "org", false); !errors.Is(err, ErrProfileAlreadyExists) {
+		t.Fatalf("Save over a system profile: expected ErrProfileAlreadyExists, got %v", err)
+	}
+	// The live config holds work's files; saving them as org with force
+	// makes a user profile that hides the system one.
+	if err := Save(tool, "org", true); err != nil {
+		t.Fatalf("Save --force: %v", err)
+	}
+	if system, _ := IsSystemProfile(tool, "org"); system {
+		t.Fatal("the user's org should hide the system one")
+	}
+	profiles, err := List(tool)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if !slices.Equal(profiles, []string{"org", "work"}) {
+		t.Fatalf("List = %v, want [org work]", profiles)
+	}
+	writeCodexFiles(t, codexDir, "other")
+	if err := Switch(tool, "org"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	assertLive(t, codexDir, "work")
+
+	// The system store still has its own.
+	if err := Switch(tool.WithSystemStore(), "org"); err != nil {
+		t.Fatalf("Switch --system: %v", err)
+	}
+	assertLive(t, codexDir, "org")
+}
@@ -0,0 +1,19 @@
+//go:build !windows
+
+package profile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// isTransient reports whether err is a file system error that may go away
+// when the operation is tried again.
+func isTransient(err error) bool {
+	for _, errno := range []syscall.Errno{syscall.EBUSY, syscall.EAGAIN, syscall.EINTR, syscall.ESTALE, syscall.ETXTBSY} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
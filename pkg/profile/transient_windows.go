@@ -0,0 +1,26 @@
+package profile
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Windows error codes for files another process holds open, typically a
+// virus scanner or search indexer looking at a file just written.
+const (
+	errorAccessDenied      syscall.Errno = 5
+	errorSharingViolation  syscall.Errno = 32
+	errorLockViolation     syscall.Errno = 33
+	errorUserMappedSection syscall.Errno = 1224
+)
+
+// isTransient reports whether err is a file system error that may go away
+// when the operation is tried again.
+func isTransient(err error) bool {
+	for _, errno := range []syscall.Errno{errorAccessDenied, errorSharingViolation, errorLockViolation, errorUserMappedSection} {
+		if errors.Is(err, errno) {
+			return true
+		}
+	}
+	return false
+}
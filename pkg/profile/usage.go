@@ -0,0 +1,167 @@
+package profile
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// historyFileName is the file next to current.json that records every
+// change of the active profile, one JSON object per line.
+const historyFileName = "history.jsonl"
+
+type historyEntry struct {
+	Time time.Time `json:"time"`
+	// Profile is the profile active from Time on; "" when none is.
+	Profile string `json:"profile"`
+	// RenamedFrom marks the rename of RenamedFrom to Profile rather than a
+	// change of the active profile.
+	RenamedFrom string `json:"renamed_from,omitempty"`
+}
+
+// Usage is how much a profile has been used, as the history of switches
+// tells it.
+type Usage struct {
+	Profile string
+	// Active is how long the profile has been the active one in total,
+	// including the time up to now when it still is.
+	Active time.Duration
+	// Activations counts the switches to the profile.
+	Activations int
+	// LastActive is when the profile was last the active one.
+	LastActive time.Time
+}
+
+func (t Tool) historyFile() (string, error) {
+	base, err := t.tokyoDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(base, historyFileName), nil
+}
+
+// recordHistory appends entry to the history. The history only feeds
+// statistics, so failing to write it does not fail the change it records.
+func recordHistory(t Tool, entry historyEntry) {
+	if err := appendHistory(t, entry); err != nil {
+		t.logger().Debug("record profile history", "error", err)
+	}
+}
+
+func appendHistory(t Tool, entry historyEntry) error {
+	path, err := t.historyFile()
+	if err != nil {
+		return err
+	}
+	if err := ensureParentDir(path); err != nil {
+		return err
+	}
+	if err := rejectNonRegularFile(path); err != nil {
+		return err
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// UsageStats adds up the history of t into the usage of each profile that
+// still exists, most used first. Time spent in a profile before it was
+// renamed counts for its new name. The active profile's last period runs
+// until now.
+func UsageStats(t Tool, now time.Time) ([]Usage, error) {
+	path, err := t.historyFile()
+	if err != nil {
+		return nil, err
+	}
+	usage := map[string]*Usage{}
+	get := func(name string) *Usage {
+		u, ok := usage[name]
+		if !ok {
+			u = &Usage{Profile: name}
+			usage[name] = u
+		}
+		return u
+	}
+
+	active, since := "", time.Time{}
+	closePeriod := func(at time.Time) {
+		if active == "" || !at.After(since) {
+			return
+		}
+		u := get(active)
+		u.Active += at.Sub(since)
+		u.LastActive = at
+	}
+
+	f, err := os.Open(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			var e historyEntry
+			// A line cut short by a crash is skipped, not fatal.
+			if json.Unmarshal(scanner.Bytes(), &e) != nil {
+				continue
+			}
+			if e.RenamedFrom != "" {
+				if old, ok := usage[e.RenamedFrom]; ok {
+					delete(usage, e.RenamedFrom)
+					u := get(e.Profile)
+					u.Active += old.Active
+					u.Activations += old.Activations
+					if old.LastActive.After(u.LastActive) {
+						u.LastActive = old.LastActive
+					}
+				}
+				if active == e.RenamedFrom {
+					active = e.Profile
+				}
+				continue
+			}
+			closePeriod(e.Time)
+			active, since = e.Profile, e.Time
+			if active != "" {
+				u := get(active)
+				u.Activations++
+				u.LastActive = e.Time
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+	}
+	closePeriod(now)
+
+	profiles, err := List(t)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Usage, 0, len(profiles))
+	for _, name := range profiles {
+		if u, ok := usage[name]; ok {
+			out = append(out, *u)
+		} else {
+			out = append(out, Usage{Profile: name})
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool { return out[i].Active > out[j].Active })
+	return out, nil
+}
@@ -0,0 +1,96 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUsageStats(t *testing.T) {
+	tool, _ := setupClaudeHome(t)
+	for _, name := range []string{"work", "personal", "spare"} {
+		if err := Save(tool, name, false); err != nil {
+			t.Fatalf("Save %s: %v", name, err)
+		}
+	}
+
+	// Write the history by hand: the periods are what is being tested, and
+	// real switches are milliseconds apart.
+	path, err := tool.historyFile()
+	if err != nil {
+		t.Fatalf("historyFile: %v", err)
+	}
+	start := time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC)
+	for _, e := range []historyEntry{
+		{Time: start, Profile: "job"},
+		{Time: start.Add(8 * time.Hour), Profile: "personal"},
+		{Time: start.Add(10 * time.Hour), Profile: ""},
+		{Time: start.Add(24 * time.Hour), Profile: "job"},
+		{Time: start.Add(26 * time.Hour), RenamedFrom: "job", Profile: "work"},
+	} {
+		if err := appendHistory(tool, e); err != nil {
+			t.Fatalf("appendHistory: %v", err)
+		}
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatalf("open history: %v", err)
+	}
+	f.WriteString(`{"time":"2026-01-06T1`) // cut short by a crash
+	f.Close()
+
+	usage, err := UsageStats(tool, start.Add(27*time.Hour))
+	if err != nil {
+		t.Fatalf("UsageStats: %v", err)
+	}
+	want := []Usage{
+		{Profile: "work", Active: 11 * time.Hour, Activations: 2, LastActive: start.Add(27 * time.Hour)},
+		{Profile: "personal", Active: 2 * time.Hour, Activations: 1, LastActive: start.Add(10 * time.Hour)},
+		{Profile: "spare"},
+	}
+	if len(usage) != len(want) {
+		t.Fatalf("usage = %+v, want %+v", usage, want)
+	}
+	for i := range want {
+		if usage[i] != want[i] {
+			t.Fatalf("usage[%d] = %+v, want %+v", i, usage[i], want[i])
+		}
+	}
+}
+
+func TestSwitchesAreRecordedInHistory(t *testing.T) {
+	tool, _ := setupClaudeHome(t)
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := Save(tool, "home", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	for _, name := range []string{"work", "work", "home"} {
+		if err := Switch(tool, name); err != nil {
+			t.Fatalf("Switch %s: %v", name, err)
+		}
+	}
+	if err := Rename(tool, "home", "house"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	usage, err := UsageStats(tool, time.Now())
+	if err != nil {
+		t.Fatalf("UsageStats: %v", err)
+	}
+	activations := map[string]int{}
+	for _, u := range usage {
+		activations[u.Profile] = u.Activations
+	}
+	// Switching to the active profile again is not a new activation, and
+	// the rename carries home's over.
+	if activations["work"] != 1 || activations["house"] != 1 || len(usage) != 2 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	base, _ := tool.tokyoDir()
+	if _, err := os.Stat(filepath.Join(base, historyFileName)); err != nil {
+		t.Fatalf("history file: %v", err)
+	}
+}
@@ -0,0 +1,50 @@
+package profile
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var versionPattern = regexp.MustCompile(`\d+\.\d+(\.\d+)?`)
+
+// ParseToolVersion extracts the version number from the output of a tool's
+// --version, such as "1.0.43 (Claude Code)" or "codex-cli 0.20.0". It
+// returns "" when there is none.
+func ParseToolVersion(output string) string {
+	return versionPattern.FindString(output)
+}
+
+// VersionsDiffer reports whether two tool versions are far enough apart for
+// the tool's settings schema to have changed: a different major version, or
+// a different minor version before 1.0. Unknown versions never differ.
+func VersionsDiffer(a, b string) bool {
+	aMajor, aMinor, ok := splitVersion(a)
+	if !ok {
+		return false
+	}
+	bMajor, bMinor, ok := splitVersion(b)
+	if !ok {
+		return false
+	}
+	if aMajor != bMajor {
+		return true
+	}
+	return aMajor == 0 && aMinor != bMinor
+}
+
+func splitVersion(v string) (major, minor int, ok bool) {
+	parts := strings.SplitN(v, ".", 3)
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
@@ -0,0 +1,35 @@
+package profile
+
+import "testing"
+
+func TestParseToolVersion(t *testing.T) {
+	for output, want := range map[string]string{
+		"1.0.43 (Claude Code)\n": "1.0.43",
+		"codex-cli 0.20.0\n":     "0.20.0",
+		"v2.1":                   "2.1",
+		"command not found":      "",
+	} {
+		if got := ParseToolVersion(output); got != want {
+			t.Fatalf("ParseToolVersion(%q) = %q, want %q", output, got, want)
+		}
+	}
+}
+
+func TestVersionsDiffer(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"1.0.43", "1.0.50", false},
+		{"1.0.43", "1.2.0", false},
+		{"1.0.43", "2.0.0", true},
+		{"0.20.0", "0.20.3", false},
+		{"0.20.0", "0.21.0", true},
+		{"", "2.0.0", false},
+		{"1.0.43", "", false},
+	} {
+		if got := VersionsDiffer(tc.a, tc.b); got != tc.want {
+			t.Fatalf("VersionsDiffer(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package profile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Which reports which saved profiles hold exactly the current content of a
+// live config file. file is either the file's path or its base name, such
+// as "auth.json". It returns the live path and the matching profiles; unlike
+// Current it does not rely on current.json.
+func Which(t Tool, file string) (string, []string, error) {
+	live, err := t.lookupConfigFile(file)
+	if err != nil {
+		return "", nil, err
+	}
+	path, err := t.liveFile(live)
+	if err != nil {
+		return "", nil, err
+	}
+	exists, err := ensureRegularFileIfExists(path)
+	if err != nil {
+		return "", nil, err
+	}
+	if !exists {
+		return "", nil, newUserError(ErrConfigFileNotFound, "config file not found: %s", live)
+	}
+	liveHash, _, err := storedDigest(path)
+	if err != nil {
+		return "", nil, err
+	}
+
+	profiles, err := List(t)
+	if err != nil {
+		return "", nil, err
+	}
+	var matched []string
+	for _, p := range profiles {
+		src, err := t.resolveProfileFile(p, filepath.Base(live))
+		if err != nil {
+			return "", nil, err
+		}
+		stored, err := ensureRegularFileIfExists(src)
+		if err != nil {
+			return "", nil, err
+		}
+		if !stored {
+			continue
+		}
+		hash, ok := t.blobHash(src)
+		if !ok {
+			if hash, err = storedHash(src); err != nil {
+				return "", nil, err
+			}
+		}
+		if hash == liveHash {
+			matched = append(matched, p)
+		}
+	}
+	return live, matched, nil
+}
+
+// lookupConfigFile resolves a path or base name to one of t's config files.
+func (t Tool) lookupConfigFile(file string) (string, error) {
+	configFiles, err := t.configFiles()
+	if err != nil {
+		return "", err
+	}
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return "", err
+	}
+	names := make([]string, 0, len(configFiles))
+	for _, path := range configFiles {
+		if path == abs || filepath.Base(path) == file {
+			return path, nil
+		}
+		names = append(names, filepath.Base(path))
+	}
+	return "", fmt.Errorf("%q is not a %s config file (expected %s)", file, t.DisplayName, strings.Join(names, ", "))
+}
@@ -0,0 +1,66 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestWhichMatchesByContent(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	tool := CodexTool()
+	codexDir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(codexDir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	configPath := filepath.Join(codexDir, "config.toml")
+	authPath := filepath.Join(codexDir, "auth.json")
+	write := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	write(configPath, `model = "o3"`)
+	write(authPath, `{"token":"work"}`)
+	if err := Save(tool, "work", false); err != nil {
+		t.Fatalf("Save work: %v", err)
+	}
+	write(configPath, `model = "o4"`)
+	if err := Save(tool, "work-o4", false); err != nil {
+		t.Fatalf("Save work-o4: %v", err)
+	}
+	write(authPath, `{"token":"personal"}`)
+	if err := Save(tool, "personal", false); err != nil {
+		t.Fatalf("Save personal: %v", err)
+	}
+
+	// Simulate a stale current.json by restoring the work token by hand.
+	write(authPath, `{"token":"work"}`)
+
+	live, profiles, err := Which(tool, "auth.json")
+	if err != nil {
+		t.Fatalf("Which: %v", err)
+	}
+	if live != authPath {
+		t.Fatalf("expected %s, got %s", authPath, live)
+	}
+	if !reflect.DeepEqual(profiles, []string{"work", "work-o4"}) {
+		t.Fatalf("expected [work work-o4], got %v", profiles)
+	}
+
+	if _, profiles, err = Which(tool, configPath); err != nil {
+		t.Fatalf("Which by path: %v", err)
+	}
+	if !reflect.DeepEqual(profiles, []string{"personal", "work-o4"}) {
+		t.Fatalf("expected [personal work-o4], got %v", profiles)
+	}
+
+	if _, _, err := Which(tool, "settings.json"); err == nil {
+		t.Fatalf("expected an error for a file the tool does not manage")
+	}
+}
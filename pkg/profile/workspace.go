@@ -0,0 +1,70 @@
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// workspacesDir holds one complete set of tool stores per workspace, next to
+// the default set.
+const workspacesDir = "workspaces"
+
+// DefaultWorkspace names the set of stores used when no workspace is
+// selected. It cannot be used as a workspace name.
+const DefaultWorkspace = "default"
+
+// ValidateWorkspaceName checks that name can be used as a workspace.
+func ValidateWorkspaceName(name string) error {
+	if name == "" {
+		return errors.New("workspace name cannot be empty")
+	}
+	if name == DefaultWorkspace {
+		return errors.New("workspace name is reserved")
+	}
+	if len(name) > 64 {
+		return errors.New("workspace name too long (max 64 characters)")
+	}
+	for _, r := range name {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '-' || r == '_' {
+			continue
+		}
+		return fmt.Errorf("invalid workspace name: %q (allowed: A-Z a-z 0-9 _ -)", name)
+	}
+	return nil
+}
+
+// WithWorkspace returns a copy of t that keeps its profiles, metadata and
+// current state in workspace name, separate from every other workspace. An
+// empty name selects the default set.
+func (t Tool) WithWorkspace(name string) Tool {
+	t.Workspace = name
+	return t
+}
+
+// Workspaces returns the names of the workspaces that exist next to the
+// store of t, sorted.
+func Workspaces(t Tool) ([]string, error) {
+	root, err := t.storeRoot()
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(filepath.Join(root, workspacesDir))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		if e.IsDir() && ValidateWorkspaceName(e.Name()) == nil {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
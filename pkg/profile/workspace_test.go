@@ -0,0 +1,73 @@
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWorkspacesKeepSeparateProfiles(t *testing.T) {
+	home := t.TempDir()
+	tool := ClaudeTool().WithHome(home)
+	configPath := filepath.Join(home, ".claude", "settings.json")
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(configPath, []byte(`{}`), 0o600); err != nil {
+		t.Fatalf("write config: %v", err)
+	}
+
+	acme := tool.WithWorkspace("acme")
+	if err := Save(acme, "client", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := Switch(acme, "client"); err != nil {
+		t.Fatalf("Switch: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(home, ".config", "tokyo", "workspaces", "acme", "claude", "profiles", "client")); err != nil {
+		t.Fatalf("expected profile inside the workspace: %v", err)
+	}
+
+	profiles, err := List(tool)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(profiles) != 0 {
+		t.Fatalf("expected default workspace to stay empty, got %v", profiles)
+	}
+	status, err := Current(tool)
+	if err != nil {
+		t.Fatalf("Current: %v", err)
+	}
+	if status != "<custom>" {
+		t.Fatalf("expected no active profile outside the workspace, got %q", status)
+	}
+	if status, _ := Current(acme); status != "client" {
+		t.Fatalf("expected client in acme, got %q", status)
+	}
+
+	if err := Save(tool.WithWorkspace("globex"), "client", false); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	names, err := Workspaces(acme)
+	if err != nil {
+		t.Fatalf("Workspaces: %v", err)
+	}
+	if !slices.Equal(names, []string{"acme", "globex"}) {
+		t.Fatalf("Workspaces = %v", names)
+	}
+}
+
+func TestValidateWorkspaceName(t *testing.T) {
+	for _, name := range []string{"acme", "client_2", "a-b"} {
+		if err := ValidateWorkspaceName(name); err != nil {
+			t.Fatalf("ValidateWorkspaceName(%q): %v", name, err)
+		}
+	}
+	for _, name := range []string{"", DefaultWorkspace, "../x", "a b", ".hidden"} {
+		if err := ValidateWorkspaceName(name); err == nil {
+			t.Fatalf("ValidateWorkspaceName(%q): expected error", name)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package project
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// AllowFileName is the name of the allow-list inside the tokyo directory.
+const AllowFileName = "allowed.json"
+
+// AllowList records the project files trusted to switch profiles on their
+// own, by absolute path, with the SHA-256 of the content that was trusted:
+// a file that changes has to be allowed again.
+type AllowList struct {
+	path  string
+	files map[string]string
+}
+
+// LoadAllowList reads the allow-list kept in the tokyo directory root. A
+// missing allow-list is empty.
+func LoadAllowList(root string) (*AllowList, error) {
+	l := &AllowList{path: filepath.Join(root, AllowFileName), files: map[string]string{}}
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return l, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", l.path, err)
+	}
+	if err := json.Unmarshal(data, &l.files); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", l.path, err)
+	}
+	return l, nil
+}
+
+// Allowed reports whether the project file at path is trusted with its
+// current content.
+func (l *AllowList) Allowed(path string) (bool, error) {
+	sum, ok := l.files[path]
+	if !ok {
+		return false, nil
+	}
+	current, err := fileSum(path)
+	if err != nil {
+		return false, err
+	}
+	return sum == current, nil
+}
+
+// Allow trusts the current content of the project file at path and saves the
+// allow-list.
+func (l *AllowList) Allow(path string) error {
+	sum, err := fileSum(path)
+	if err != nil {
+		return err
+	}
+	l.files[path] = sum
+	return l.save()
+}
+
+// Deny stops trusting the project file at path and saves the allow-list. It
+// reports whether the file was listed.
+func (l *AllowList) Deny(path string) (bool, error) {
+	if _, ok := l.files[path]; !ok {
+		return false, nil
+	}
+	delete(l.files, path)
+	return true, l.save()
+}
+
+func (l *AllowList) save() error {
+	data, err := json.MarshalIndent(l.files, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), ".allowed-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
+
+func fileSum(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("read %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,48 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAllowListTracksContent(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(t.TempDir(), ".tokyo")
+	if err := os.WriteFile(path, []byte("claude: work\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	l, err := LoadAllowList(root)
+	if err != nil {
+		t.Fatalf("LoadAllowList: %v", err)
+	}
+	if ok, err := l.Allowed(path); err != nil || ok {
+		t.Fatalf("Allowed before Allow = %v, %v", ok, err)
+	}
+	if err := l.Allow(path); err != nil {
+		t.Fatalf("Allow: %v", err)
+	}
+
+	l, err = LoadAllowList(root)
+	if err != nil {
+		t.Fatalf("LoadAllowList: %v", err)
+	}
+	if ok, err := l.Allowed(path); err != nil || !ok {
+		t.Fatalf("Allowed after Allow = %v, %v", ok, err)
+	}
+
+	if err := os.WriteFile(path, []byte("claude: other\n"), 0o600); err != nil {
+		t.Fatalf("rewrite: %v", err)
+	}
+	if ok, err := l.Allowed(path); err != nil || ok {
+		t.Fatalf("expected a changed file to need allowing again, got %v, %v", ok, err)
+	}
+
+	if denied, err := l.Deny(path); err != nil || !denied {
+		t.Fatalf("Deny = %v, %v", denied, err)
+	}
+	if denied, err := l.Deny(path); err != nil || denied {
+		t.Fatalf("second Deny = %v, %v", denied, err)
+	}
+}
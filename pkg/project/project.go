@@ -0,0 +1,109 @@
+// Package project reads .tokyo files, which declare the profiles a directory
+// tree expects, and keeps the list of .tokyo files the user trusts enough to
+// act on automatically.
+package project
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"tokyo/pkg/profile"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// FileNames are the names of the project file looked up from a directory
+// upwards, in order of preference.
+var FileNames = []string{".tokyo", ".tokyo.yaml"}
+
+// File is a parsed project file.
+type File struct {
+	// Path is the absolute path of the file.
+	Path string
+	// Profiles maps tool names to the profile the project expects.
+	Profiles map[string]string
+}
+
+// Tools returns the tool names of f, sorted.
+func (f File) Tools() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Find returns the path of the project file in dir or its nearest parent
+// that has one, or "" when there is none. A .tokyo file is preferred over a
+// .tokyo.yaml file in the same directory.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	for {
+		for _, name := range FileNames {
+			path := filepath.Join(dir, name)
+			info, err := os.Stat(path)
+			if err == nil && info.Mode().IsRegular() {
+				return path, nil
+			}
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return "", err
+			}
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", nil
+		}
+		dir = parent
+	}
+}
+
+// Load reads and checks the project file at path.
+func Load(path string) (File, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return File{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, fmt.Errorf("read %s: %w", path, err)
+	}
+	f, err := Parse(data)
+	if err != nil {
+		return File{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	f.Path = path
+	return f, nil
+}
+
+// Parse decodes and checks a project file: a YAML mapping of tool names to
+// profile names.
+//
+//	claude: work
+//	codex: work
+func Parse(data []byte) (File, error) {
+	var profiles map[string]string
+	if err := yaml.Unmarshal(data, &profiles); err != nil {
+		return File{}, err
+	}
+	if len(profiles) == 0 {
+		return File{}, errors.New("no tools declared")
+	}
+	f := File{Profiles: profiles}
+	for _, name := range f.Tools() {
+		if _, ok := profile.LookupTool(name); !ok {
+			return File{}, fmt.Errorf("unknown tool: %q", name)
+		}
+		if err := profile.ValidateProfileName(profiles[name]); err != nil {
+			return File{}, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,63 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindWalksUpToNearestFile(t *testing.T) {
+	root := t.TempDir()
+	nested := filepath.Join(root, "repo", "src", "pkg")
+	if err := os.MkdirAll(nested, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+
+	path, err := Find(nested)
+	if err != nil {
+		t.Fatalf("Find: %v", err)
+	}
+	if path != "" {
+		t.Fatalf("expected no project file, got %s", path)
+	}
+
+	want := filepath.Join(root, "repo", ".tokyo.yaml")
+	if err := os.WriteFile(want, []byte("claude: work\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if path, err = Find(nested); err != nil || path != want {
+		t.Fatalf("Find = %q, %v; want %q", path, err, want)
+	}
+
+	want = filepath.Join(root, "repo", ".tokyo")
+	if err := os.WriteFile(want, []byte("claude: work\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if path, err = Find(nested); err != nil || path != want {
+		t.Fatalf("Find with both files = %q, %v; want %q", path, err, want)
+	}
+}
+
+func TestLoadAndParse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".tokyo")
+	if err := os.WriteFile(path, []byte("claude: work\ncodex: personal\n"), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if f.Path != path || !reflect.DeepEqual(f.Profiles, map[string]string{"claude": "work", "codex": "personal"}) {
+		t.Fatalf("unexpected file: %+v", f)
+	}
+	if !reflect.DeepEqual(f.Tools(), []string{"claude", "codex"}) {
+		t.Fatalf("Tools = %v", f.Tools())
+	}
+
+	for _, bad := range []string{"", "vim: work\n", "claude: ../x\n", "- claude\n"} {
+		if _, err := Parse([]byte(bad)); err == nil {
+			t.Fatalf("expected %q to be rejected", bad)
+		}
+	}
+}
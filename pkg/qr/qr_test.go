@@ -0,0 +1,244 @@
+package qr
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// "HELLO WORLD" at version 1-M, the worked example of the standard.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := rsRemainder(data, rsDivisor(10)); !bytes.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
+
+func TestFormatAndVersionBits(t *testing.T) {
+	if got, want := formatBits(0), 0b111011111000100; got != want {
+		t.Errorf("formatBits(0) = %015b, want %015b", got, want)
+	}
+	if got, want := formatBits(7), 0b110100101110110; got != want {
+		t.Errorf("formatBits(7) = %015b, want %015b", got, want)
+	}
+	if got, want := versionBits(7), 0b000111110010010100; got != want {
+		t.Errorf("versionBits(7) = %018b, want %018b", got, want)
+	}
+}
+
+func TestAlignmentPositions(t *testing.T) {
+	tests := map[int][]int{
+		2:  {6, 18},
+		7:  {6, 22, 38},
+		32: {6, 34, 60, 86, 112, 138},
+		40: {6, 30, 58, 86, 114, 142, 170},
+	}
+	for version, want := range tests {
+		if got := alignmentPositions(version); !slicesEqual(got, want) {
+			t.Errorf("version %d: got %v, want %v", version, got, want)
+		}
+	}
+}
+
+func TestEncodeChoosesSmallestVersion(t *testing.T) {
+	tests := []struct {
+		size    int
+		version int
+	}{
+		{0, 1},
+		{17, 1},
+		{18, 2},
+		{271, 10},
+		{MaxSize, 40},
+	}
+	for _, tt := range tests {
+		c, err := Encode(bytes.Repeat([]byte{'a'}, tt.size))
+		if err != nil {
+			t.Fatalf("%d bytes: %v", tt.size, err)
+		}
+		if c.Version != tt.version || c.Size != 17+4*tt.version {
+			t.Errorf("%d bytes: version %d size %d, want version %d", tt.size, c.Version, c.Size, tt.version)
+		}
+	}
+	if _, err := Encode(make([]byte, MaxSize+1)); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("expected ErrTooLarge, got %v", err)
+	}
+}
+
+// TestEncodeReadsBack reads the data back out of encoded codes the way a
+// scanner does after locating them: format bits, unmasking, the zigzag
+// order, de-interleaving and the byte-mode header.
+func TestEncodeReadsBack(t *testing.T) {
+	for _, size := range []int{0, 5, 17, 100, 500, 1500, MaxSize} {
+		data := make([]byte, size)
+		for i := range data {
+			data[i] = byte(i*7 + 3)
+		}
+		c, err := Encode(data)
+		if err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+		if got := readBack(t, c); !bytes.Equal(got, data) {
+			t.Fatalf("%d bytes: read back %d bytes that differ", size, len(got))
+		}
+	}
+}
+
+func readBack(t *testing.T, c *Code) []byte {
+	t.Helper()
+	var format int
+	for i := 0; i < 8; i++ {
+		if c.Dark(c.Size-1-i, 8) {
+			format |= 1 << i
+		}
+	}
+	for i := 8; i < 15; i++ {
+		if c.Dark(8, c.Size-15+i) {
+			format |= 1 << i
+		}
+	}
+	mask := -1
+	for m := range 8 {
+		if formatBits(m) == format {
+			mask = m
+		}
+	}
+	if mask < 0 {
+		t.Fatalf("format bits %015b match no mask at level L", format)
+	}
+
+	plain := newCode(c.Version)
+	plain.drawFunctionPatterns()
+	for y := range c.Size {
+		for x := range c.Size {
+			if !plain.isFunction[y][x] {
+				plain.modules[y][x] = c.Dark(x, y) != maskAt(mask, x, y)
+			}
+		}
+	}
+	var raw []byte
+	var cur byte
+	n := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		upward := (right+1)&2 == 0
+		for vert := range c.Size {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := range 2 {
+				x := right - j
+				if plain.isFunction[y][x] {
+					continue
+				}
+				cur = cur<<1 | b2i(plain.modules[y][x])
+				if n++; n%8 == 0 {
+					raw = append(raw, cur)
+				}
+			}
+		}
+	}
+	if len(raw) != rawModules(c.Version)/8 {
+		t.Fatalf("read %d codewords, want %d", len(raw), rawModules(c.Version)/8)
+	}
+
+	numBlocks := eccBlocks[c.Version]
+	eccLen := eccPerBlock[c.Version]
+	numShort := numBlocks - len(raw)%numBlocks
+	shortData := len(raw)/numBlocks - eccLen
+	blocks := make([][]byte, numBlocks)
+	k := 0
+	for i := 0; i <= shortData; i++ {
+		for j := range blocks {
+			if i < shortData || j >= numShort {
+				blocks[j] = append(blocks[j], raw[k])
+				k++
+			}
+		}
+	}
+	var codewords []byte
+	for _, block := range blocks {
+		codewords = append(codewords, block...)
+	}
+	for j, block := range blocks {
+		var got []byte
+		for i := range eccLen {
+			got = append(got, raw[k+i*numBlocks+j])
+		}
+		if want := rsRemainder(block, rsDivisor(eccLen)); !bytes.Equal(got, want) {
+			t.Fatalf("block %d: error correction codewords do not match", j)
+		}
+	}
+
+	if codewords[0]>>4 != 0b0100 {
+		t.Fatalf("mode %04b, want byte mode", codewords[0]>>4)
+	}
+	var bits bitBuffer
+	for _, b := range codewords {
+		bits.append(int(b), 8)
+	}
+	read := func(from, n int) int {
+		v := 0
+		for _, bit := range bits[from : from+n] {
+			v = v<<1 | int(b2i(bit))
+		}
+		return v
+	}
+	count := read(4, countBits(c.Version))
+	out := make([]byte, count)
+	for i := range out {
+		out[i] = byte(read(4+countBits(c.Version)+8*i, 8))
+	}
+	return out
+}
+
+func b2i(b bool) byte {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func slicesEqual(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestWriteTerminal(t *testing.T) {
+	c, err := Encode([]byte("https://example.com"))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	var out bytes.Buffer
+	if err := c.WriteTerminal(&out); err != nil {
+		t.Fatalf("WriteTerminal: %v", err)
+	}
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if want := (c.Size + 8 + 1) / 2; len(lines) != want {
+		t.Fatalf("got %d lines, want %d", len(lines), want)
+	}
+	for _, line := range lines {
+		if n := len([]rune(line)); n != c.Size+8 {
+			t.Fatalf("line is %d wide, want %d", n, c.Size+8)
+		}
+	}
+	// The top quiet zone is light; the finder's top edge is dark.
+	if lines[0] != strings.Repeat("█", c.Size+8) {
+		t.Fatalf("quiet zone not light: %q", lines[0])
+	}
+	if r := []rune(lines[2])[4]; r != ' ' && r != '▄' {
+		t.Fatalf("finder corner drawn as %q", r)
+	}
+}
@@ -0,0 +1,205 @@
+// Package token manages the API tokens `tokyo serve` accepts. Only the
+// SHA-256 of each token is kept, so the tokens file is useless to someone
+// who reads it.
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the tokens file inside the tokyo directory.
+const FileName = "tokens.json"
+
+// Scopes a token can have.
+const (
+	// ScopeRead allows only requests that do not modify anything.
+	ScopeRead = "read"
+	// ScopeReadWrite allows every request.
+	ScopeReadWrite = "read-write"
+)
+
+// prefix starts every token, so that leaked tokens are easy to recognize.
+const prefix = "tokyo_"
+
+// ErrExists is returned by Create for a name that is already taken.
+var ErrExists = errors.New("token already exists")
+
+// Token is one API token, without its secret.
+type Token struct {
+	Name  string `json:"name"`
+	Hash  string `json:"hash"`
+	Scope string `json:"scope"`
+	// Tools limits the token to these tools; empty means every tool.
+	Tools []string `json:"tools,omitempty"`
+	// Created is when the token was created.
+	Created time.Time `json:"created"`
+	// Expires is when the token stops being accepted; zero means never.
+	Expires time.Time `json:"expires,omitzero"`
+}
+
+// Expired reports whether the token is no longer accepted at now.
+func (t Token) Expired(now time.Time) bool {
+	return !t.Expires.IsZero() && !now.Before(t.Expires)
+}
+
+// ReadOnly reports whether the token only allows requests that do not
+// modify anything.
+func (t Token) ReadOnly() bool {
+	return t.Scope == ScopeRead
+}
+
+// AllowsTool reports whether the token may use the tool called name.
+func (t Token) AllowsTool(name string) bool {
+	return len(t.Tools) == 0 || slices.Contains(t.Tools, name)
+}
+
+// ValidateScope checks that scope is ScopeRead or ScopeReadWrite.
+func ValidateScope(scope string) error {
+	switch scope {
+	case ScopeRead, ScopeReadWrite:
+		return nil
+	}
+	return fmt.Errorf("unsupported scope %q (supported: %s, %s)", scope, ScopeRead, ScopeReadWrite)
+}
+
+// Path returns the location of the tokens file under root.
+func Path(root string) string {
+	return filepath.Join(root, FileName)
+}
+
+// Store is the set of tokens kept in a tokyo directory.
+type Store struct {
+	path   string
+	tokens []Token
+}
+
+// Load reads the tokens kept in the tokyo directory root. A missing tokens
+// file is empty.
+func Load(root string) (*Store, error) {
+	s := &Store{path: Path(root)}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return s, nil
+		}
+		return nil, fmt.Errorf("read %s: %w", s.path, err)
+	}
+	if err := json.Unmarshal(data, &s.tokens); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", s.path, err)
+	}
+	return s, nil
+}
+
+// List returns the tokens, sorted by name.
+func (s *Store) List() []Token {
+	tokens := slices.Clone(s.tokens)
+	slices.SortFunc(tokens, func(a, b Token) int { return strings.Compare(a.Name, b.Name) })
+	return tokens
+}
+
+// Create adds a token called name with the given scope, limited to tools
+// unless that is empty and expiring at expires unless that is zero, and
+// saves the store. It returns the secret, which cannot be recovered later.
+func (s *Store) Create(name, scope string, tools []string, expires time.Time) (string, error) {
+	if strings.TrimSpace(name) == "" {
+		return "", errors.New("token name must not be empty")
+	}
+	if err := ValidateScope(scope); err != nil {
+		return "", err
+	}
+	if _, ok := s.find(name); ok {
+		return "", fmt.Errorf("%w: %s", ErrExists, name)
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	secret := prefix + base64.RawURLEncoding.EncodeToString(buf)
+	s.tokens = append(s.tokens, Token{
+		Name:    name,
+		Hash:    hash(secret),
+		Scope:   scope,
+		Tools:   slices.Clone(tools),
+		Created: time.Now().UTC().Truncate(time.Second),
+		Expires: expires.UTC(),
+	})
+	return secret, s.save()
+}
+
+// Revoke removes the token called name and saves the store. It reports
+// whether the token existed.
+func (s *Store) Revoke(name string) (bool, error) {
+	i, ok := s.find(name)
+	if !ok {
+		return false, nil
+	}
+	s.tokens = slices.Delete(s.tokens, i, i+1)
+	return true, s.save()
+}
+
+// Authenticate returns the token whose secret is secret, if it has not
+// expired at now.
+func (s *Store) Authenticate(secret string, now time.Time) (Token, bool) {
+	sum := []byte(hash(secret))
+	for _, t := range s.tokens {
+		if subtle.ConstantTimeCompare(sum, []byte(t.Hash)) == 1 {
+			if t.Expired(now) {
+				return Token{}, false
+			}
+			return t, true
+		}
+	}
+	return Token{}, false
+}
+
+func (s *Store) find(name string) (int, bool) {
+	i := slices.IndexFunc(s.tokens, func(t Token) bool { return t.Name == name })
+	return i, i >= 0
+}
+
+func (s *Store) save() error {
+	tokens := s.tokens
+	if tokens == nil {
+		// Keep an empty file rather than none: a server that had tokens
+		// must not become open when the last one is revoked.
+		tokens = []Token{}
+	}
+	data, err := json.MarshalIndent(tokens, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), ".tokens-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), s.path)
+}
+
+func hash(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
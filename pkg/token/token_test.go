@@ -0,0 +1,95 @@
+package token
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCreateAuthenticateRevoke(t *testing.T) {
+	root := t.TempDir()
+	s, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	secret, err := s.Create("dashboard", ScopeRead, nil, time.Time{})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if !strings.HasPrefix(secret, prefix) {
+		t.Fatalf("secret %q lacks the %q prefix", secret, prefix)
+	}
+	if _, err := s.Create("dashboard", ScopeReadWrite, nil, time.Time{}); !errors.Is(err, ErrExists) {
+		t.Fatalf("expected ErrExists for a duplicate name, got %v", err)
+	}
+
+	data, err := os.ReadFile(Path(root))
+	if err != nil {
+		t.Fatalf("read tokens file: %v", err)
+	}
+	if strings.Contains(string(data), secret) {
+		t.Fatalf("tokens file holds the secret: %s", data)
+	}
+
+	loaded, err := Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	tok, ok := loaded.Authenticate(secret, time.Now())
+	if !ok || tok.Name != "dashboard" || !tok.ReadOnly() {
+		t.Fatalf("Authenticate = %+v, %v; want the read-only dashboard token", tok, ok)
+	}
+	if _, ok := loaded.Authenticate(secret+"x", time.Now()); ok {
+		t.Fatalf("expected a wrong secret to be rejected")
+	}
+
+	if revoked, err := loaded.Revoke("dashboard"); err != nil || !revoked {
+		t.Fatalf("Revoke = %v, %v", revoked, err)
+	}
+	if revoked, err := loaded.Revoke("dashboard"); err != nil || revoked {
+		t.Fatalf("second Revoke = %v, %v; want false", revoked, err)
+	}
+	if _, err := os.Stat(Path(root)); err != nil {
+		t.Fatalf("expected the tokens file to stay after the last revoke: %v", err)
+	}
+	loaded, err = Load(root)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, ok := loaded.Authenticate(secret, time.Now()); ok {
+		t.Fatalf("expected a revoked token to be rejected")
+	}
+}
+
+func TestAuthenticateRejectsExpired(t *testing.T) {
+	s, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	expires := time.Now().Add(time.Hour)
+	secret, err := s.Create("ci", ScopeReadWrite, nil, expires)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if _, ok := s.Authenticate(secret, expires.Add(-time.Minute)); !ok {
+		t.Fatalf("expected the token to be accepted before it expires")
+	}
+	if _, ok := s.Authenticate(secret, expires); ok {
+		t.Fatalf("expected the token to be rejected once expired")
+	}
+}
+
+func TestCreateRejectsInvalid(t *testing.T) {
+	s, err := Load(t.TempDir())
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if _, err := s.Create("ci", "admin", nil, time.Time{}); err == nil {
+		t.Fatalf("expected an unknown scope to be rejected")
+	}
+	if _, err := s.Create(" ", ScopeRead, nil, time.Time{}); err == nil {
+		t.Fatalf("expected an empty name to be rejected")
+	}
+}
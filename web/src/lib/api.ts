@@ -1,4 +1,54 @@
-const BASE_URL = '/api';
+// Relative so the UI works when the server runs under --base-path.
+export const BASE_URL = 'api/v1';
+const TOKEN_KEY = 'tokyo.token';
+
+export function setToken(token: string) {
+  if (token) localStorage.setItem(TOKEN_KEY, token);
+  else localStorage.removeItem(TOKEN_KEY);
+}
+
+export function authHeaders(): Record<string, string> {
+  const token = localStorage.getItem(TOKEN_KEY);
+  return token ? { Authorization: `Bearer ${token}` } : {};
+}
+
+export interface UITool {
+  name: string;
+  displayName: string;
+}
+
+export interface UIConfig {
+  theme: 'system' | 'dark' | 'light';
+  tools: UITool[];
+  readOnly: boolean;
+}
+
+export async function getUIConfig(): Promise<UIConfig> {
+  const res = await fetch(`${BASE_URL}/ui-config`, { headers: authHeaders() });
+  if (!res.ok) {
+    const data = await res.json();
+    throw new Error(data.error || 'Failed to load settings');
+  }
+  return res.json();
+}
+
+export interface UILocale {
+  language: string;
+  languages: string[];
+  messages: Record<string, string>;
+}
+
+// getUILocale fetches the UI's message catalog for lang, or for the
+// browser's Accept-Language when lang is empty.
+export async function getUILocale(lang: string = ''): Promise<UILocale> {
+  const query = lang ? `?lang=${encodeURIComponent(lang)}` : '';
+  const res = await fetch(`${BASE_URL}/ui-locale${query}`, { headers: authHeaders() });
+  if (!res.ok) {
+    const data = await res.json();
+    throw new Error(data.error || 'Failed to load translations');
+  }
+  return res.json();
+}
 
 export interface CurrentStatus {
   profile: string;
@@ -11,14 +61,28 @@ export interface ProfilesResponse {
 }
 
 export async function getProfiles(tool: string): Promise<string[]> {
-  const res = await fetch(`${BASE_URL}/${tool}/profiles`);
+  const res = await fetch(`${BASE_URL}/${tool}/profiles`, { headers: authHeaders() });
   if (!res.ok) throw new Error(await res.text());
   const data: ProfilesResponse = await res.json();
   return data.profiles || [];
 }
 
+export interface ProfileUsage {
+  profile: string;
+  active_seconds: number;
+  activations: number;
+  last_active?: string;
+}
+
+export async function getUsage(tool: string): Promise<ProfileUsage[]> {
+  const res = await fetch(`${BASE_URL}/${tool}/usage`, { headers: authHeaders() });
+  if (!res.ok) throw new Error(await res.text());
+  const data: { usage: ProfileUsage[] } = await res.json();
+  return data.usage || [];
+}
+
 export async function getCurrent(tool: string): Promise<CurrentStatus> {
-  const res = await fetch(`${BASE_URL}/${tool}/current`);
+  const res = await fetch(`${BASE_URL}/${tool}/current`, { headers: authHeaders() });
   if (!res.ok) throw new Error(await res.text());
   return res.json();
 }
@@ -26,7 +90,7 @@ export async function getCurrent(tool: string): Promise<CurrentStatus> {
 export async function saveProfile(tool: string, profile: string, force: boolean = false): Promise<void> {
   const res = await fetch(`${BASE_URL}/${tool}/profiles`, {
     method: 'POST',
-    headers: { 'Content-Type': 'application/json' },
+    headers: { 'Content-Type': 'application/json', ...authHeaders() },
     body: JSON.stringify({ profile, force }),
   });
   if (!res.ok) {
@@ -35,19 +99,108 @@ export async function saveProfile(tool: string, profile: string, force: boolean
   }
 }
 
-export async function switchProfile(tool: string, profile: string): Promise<void> {
-  const res = await fetch(`${BASE_URL}/${tool}/switch/${encodeURIComponent(profile)}`, {
+export type FileStatus = 'unchanged' | 'modified' | 'missing' | 'not_stored';
+
+export interface FileDiff {
+  name: string;
+  path: string;
+  status: FileStatus;
+  liveSize: number;
+  profileSize: number;
+}
+
+// Thrown by switchProfile when the live config has unsaved changes.
+export class DriftError extends Error {
+  profile: string;
+  files: FileDiff[];
+
+  constructor(message: string, profile: string, files: FileDiff[]) {
+    super(message);
+    this.profile = profile;
+    this.files = files;
+  }
+}
+
+// A switch or save the server runs in the background.
+export interface Operation {
+  id: string;
+  kind: 'switch' | 'save';
+  tool: string;
+  profile: string;
+  state: 'running' | 'succeeded' | 'failed';
+  step?: string;
+  done: number;
+  total: number;
+  error?: string;
+}
+
+const OPERATION_POLL_INTERVAL = 200;
+
+// waitForOperation polls an operation until it finishes, passing each state
+// to onProgress, and throws when it failed.
+async function waitForOperation(op: Operation, onProgress?: (op: Operation) => void): Promise<void> {
+  while (op.state === 'running') {
+    onProgress?.(op);
+    await new Promise((resolve) => setTimeout(resolve, OPERATION_POLL_INTERVAL));
+    const res = await fetch(`${BASE_URL}/operations/${op.id}`, { headers: authHeaders() });
+    const data = await res.json();
+    if (!res.ok) throw new Error(data.error || 'Failed to follow the operation');
+    op = data;
+  }
+  if (op.state === 'failed') throw new Error(op.error || 'Operation failed');
+}
+
+// switchProfile switches in the background, so a switch of many or large
+// files can report its progress.
+export async function switchProfile(
+  tool: string,
+  profile: string,
+  force: boolean = false,
+  onProgress?: (op: Operation) => void,
+): Promise<void> {
+  const query = force ? '?force=true' : '';
+  const res = await fetch(`${BASE_URL}/${tool}/switch/${encodeURIComponent(profile)}${query}`, {
     method: 'POST',
+    headers: { ...authHeaders(), Prefer: 'respond-async' },
   });
+  const data = await res.json();
   if (!res.ok) {
-    const data = await res.json();
+    if (data.code === 'unsaved_changes') {
+      throw new DriftError(data.error, data.profile, data.files);
+    }
     throw new Error(data.error || 'Failed to switch profile');
   }
+  if (res.status === 202) await waitForOperation(data, onProgress);
+}
+
+// getDiff compares the live config with the active profile.
+export async function getDiff(tool: string): Promise<FileDiff[]> {
+  const res = await fetch(`${BASE_URL}/${tool}/diff`, { headers: authHeaders() });
+  if (!res.ok) {
+    const data = await res.json();
+    throw new Error(data.error || 'Failed to compare files');
+  }
+  const data = await res.json();
+  return data.files || [];
+}
+
+// resolveDrift stores the live copy of a drifted file in the active profile
+// ('accept') or puts the profile's copy back in place ('revert').
+export async function resolveDrift(tool: string, name: string, action: 'accept' | 'revert'): Promise<void> {
+  const res = await fetch(`${BASE_URL}/${tool}/drift/${encodeURIComponent(name)}/${action}`, {
+    method: 'POST',
+    headers: authHeaders(),
+  });
+  if (!res.ok) {
+    const data = await res.json();
+    throw new Error(data.error || `Failed to ${action} ${name}`);
+  }
 }
 
 export async function deleteProfile(tool: string, profile: string): Promise<boolean> {
   const res = await fetch(`${BASE_URL}/${tool}/profiles/${encodeURIComponent(profile)}`, {
     method: 'DELETE',
+    headers: authHeaders(),
   });
   if (!res.ok) {
     const data = await res.json();
@@ -56,3 +209,53 @@ export async function deleteProfile(tool: string, profile: string): Promise<bool
   const data = await res.json();
   return data.cleared;
 }
+
+export interface ProfileFile {
+  name: string;
+  content: string;
+}
+
+export async function getFiles(tool: string, profile: string): Promise<ProfileFile[]> {
+  const res = await fetch(`${BASE_URL}/${tool}/profiles/${encodeURIComponent(profile)}/files`, { headers: authHeaders() });
+  if (!res.ok) {
+    const data = await res.json();
+    throw new Error(data.error || 'Failed to load files');
+  }
+  const data = await res.json();
+  return data.files || [];
+}
+
+export interface FileProblem {
+  file: string;
+  pointer?: string;
+  message: string;
+}
+
+// Thrown by writeFile when the server refuses content that does not parse or
+// breaks the file's schema.
+export class ValidationError extends Error {
+  problems: FileProblem[];
+
+  constructor(message: string, problems: FileProblem[]) {
+    super(message);
+    this.problems = problems;
+  }
+}
+
+export async function writeFile(tool: string, profile: string, name: string, content: string): Promise<void> {
+  const res = await fetch(
+    `${BASE_URL}/${tool}/profiles/${encodeURIComponent(profile)}/files/${encodeURIComponent(name)}`,
+    {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/octet-stream', ...authHeaders() },
+      body: content,
+    },
+  );
+  if (!res.ok) {
+    const data = await res.json();
+    if (res.status === 422 && data.problems) {
+      throw new ValidationError(data.error, data.problems);
+    }
+    throw new Error(data.error || 'Failed to save file');
+  }
+}
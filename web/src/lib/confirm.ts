@@ -0,0 +1,29 @@
+import { writable } from 'svelte/store';
+
+export interface ConfirmRequest {
+  message: string;
+  confirmLabel: string;
+  resolve: (confirmed: boolean) => void;
+}
+
+// pending is the question ConfirmSheet is showing, if any.
+export const pending = writable<ConfirmRequest | null>(null);
+
+// confirmAction asks in the confirmation sheet before a destructive action
+// and resolves to whether the user went ahead. Asking again cancels an
+// unanswered question.
+export function confirmAction(message: string, confirmLabel: string): Promise<boolean> {
+  return new Promise((resolve) => {
+    pending.update((previous) => {
+      previous?.resolve(false);
+      return { message, confirmLabel, resolve };
+    });
+  });
+}
+
+export function answer(confirmed: boolean) {
+  pending.update((request) => {
+    request?.resolve(confirmed);
+    return null;
+  });
+}
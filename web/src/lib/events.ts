@@ -0,0 +1,96 @@
+import { BASE_URL, authHeaders } from './api';
+
+export interface ProfileEvent {
+  type: string;
+  tool: string;
+  profile: string;
+  time: string;
+  // files lists the changed live files of a drift.detected event.
+  files?: string[];
+}
+
+const MAX_RETRY_DELAY = 30_000;
+
+// subscribe calls onEvent for every profile change made through the server
+// until the returned function is called. It uses the WebSocket at /api/v1/ws
+// and falls back to the server-sent events of /api/v1/events when a WebSocket
+// cannot be opened, e.g. behind a proxy that does not pass them.
+export function subscribe(onEvent: (ev: ProfileEvent) => void): () => void {
+  let stopped = false;
+  let retryDelay = 1000;
+  let socket: WebSocket | null = null;
+  let stream: AbortController | null = null;
+  let timer: ReturnType<typeof setTimeout> | undefined;
+
+  function retry(connect: () => void) {
+    if (stopped) return;
+    timer = setTimeout(connect, retryDelay);
+    retryDelay = Math.min(retryDelay * 2, MAX_RETRY_DELAY);
+  }
+
+  function connectWebSocket() {
+    const url = new URL(`${BASE_URL}/ws`, location.href);
+    url.protocol = url.protocol === 'https:' ? 'wss:' : 'ws:';
+    // Browsers cannot set headers on a WebSocket, so the token travels as
+    // a subprotocol.
+    const token = authHeaders().Authorization?.replace(/^Bearer /, '');
+    const protocols = token ? ['tokyo.v1', `bearer.${token}`] : ['tokyo.v1'];
+    let opened = false;
+    try {
+      socket = new WebSocket(url, protocols);
+    } catch {
+      // A token that is not a valid subprotocol name.
+      connectEventStream();
+      return;
+    }
+    socket.onopen = () => {
+      opened = true;
+      retryDelay = 1000;
+    };
+    socket.onmessage = (msg) => onEvent(JSON.parse(msg.data));
+    socket.onclose = () => {
+      socket = null;
+      if (opened) retry(connectWebSocket);
+      else connectEventStream();
+    };
+  }
+
+  async function connectEventStream() {
+    if (stopped) return;
+    stream = new AbortController();
+    try {
+      const res = await fetch(`${BASE_URL}/events`, { headers: authHeaders(), signal: stream.signal });
+      if (!res.ok || !res.body) throw new Error(res.statusText);
+      retryDelay = 1000;
+      const reader = res.body.pipeThrough(new TextDecoderStream()).getReader();
+      let buffered = '';
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buffered += value;
+        let end;
+        while ((end = buffered.indexOf('\n\n')) >= 0) {
+          const block = buffered.slice(0, end);
+          buffered = buffered.slice(end + 2);
+          const data = block
+            .split('\n')
+            .filter((line) => line.startsWith('data: '))
+            .map((line) => line.slice(6))
+            .join('\n');
+          if (data) onEvent(JSON.parse(data));
+        }
+      }
+    } catch {
+      // Reconnect below.
+    }
+    retry(connectEventStream);
+  }
+
+  connectWebSocket();
+  return () => {
+    stopped = true;
+    clearTimeout(timer);
+    socket?.close();
+    stream?.abort();
+  };
+}
@@ -0,0 +1,52 @@
+import { derived, writable } from 'svelte/store';
+import { getUILocale } from './api';
+
+const LANGUAGE_KEY = 'tokyo.language';
+
+const messages = writable<Record<string, string>>({});
+
+// language is the language the UI is shown in; languages are the ones the
+// server can translate it to.
+export const language = writable('en');
+export const languages = writable<string[]>(['en']);
+
+// t translates an English UI string, filling in its {name} placeholders.
+// Strings missing from the catalog stay in English.
+export const t = derived(messages, ($messages) => (msg: string, params: Record<string, string> = {}) =>
+  ($messages[msg] ?? msg).replace(/\{([a-z]+)\}/g, (match, name) => params[name] ?? match),
+);
+
+// storedLanguage is the language picked in this browser, or '' to follow
+// its Accept-Language.
+export function storedLanguage(): string {
+  return localStorage.getItem(LANGUAGE_KEY) ?? '';
+}
+
+// loadLanguage switches the UI to lang ('' for the browser's language),
+// remembering the choice in this browser when persist is set.
+export async function loadLanguage(lang: string, persist: boolean = false) {
+  if (persist) {
+    if (lang) localStorage.setItem(LANGUAGE_KEY, lang);
+    else localStorage.removeItem(LANGUAGE_KEY);
+  }
+  let locale;
+  try {
+    locale = await getUILocale(lang);
+  } catch (e) {
+    // A language the server no longer has: fall back to the browser's.
+    if (!lang) throw e;
+    localStorage.removeItem(LANGUAGE_KEY);
+    locale = await getUILocale();
+  }
+  messages.set(locale.messages);
+  languages.set(locale.languages);
+  language.set(locale.language);
+  document.documentElement.lang = locale.language;
+}
+
+// Languages are listed by their own names, so they can be found whatever the
+// UI is shown in.
+export const languageNames: Record<string, string> = {
+  en: 'English',
+  ja: '日本語',
+};
@@ -0,0 +1,28 @@
+export type Theme = 'system' | 'dark' | 'light';
+
+const THEME_KEY = 'tokyo.theme';
+const prefersLight = window.matchMedia('(prefers-color-scheme: light)');
+
+let current: Theme = 'system';
+
+// storedTheme is the theme picked in this browser, if any. It wins over the
+// server's default.
+export function storedTheme(): Theme | null {
+  const theme = localStorage.getItem(THEME_KEY);
+  return theme === 'system' || theme === 'dark' || theme === 'light' ? theme : null;
+}
+
+function apply() {
+  const light = current === 'light' || (current === 'system' && prefersLight.matches);
+  document.documentElement.dataset.theme = light ? 'light' : 'dark';
+}
+
+// Follow the OS while the theme is 'system'.
+prefersLight.addEventListener('change', apply);
+
+// applyTheme shows theme, remembering it in this browser when persist is set.
+export function applyTheme(theme: Theme, persist: boolean = false) {
+  current = theme;
+  if (persist) localStorage.setItem(THEME_KEY, theme);
+  apply();
+}
@@ -1,6 +1,10 @@
 import { mount } from 'svelte'
 import './app.css'
 import App from './App.svelte'
+import { applyTheme, storedTheme } from './lib/theme'
+
+// Before mounting, so a light theme does not flash dark.
+applyTheme(storedTheme() ?? 'system')
 
 const app = mount(App, {
   target: document.getElementById('app')!,
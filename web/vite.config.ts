@@ -3,6 +3,8 @@ import { svelte } from '@sveltejs/vite-plugin-svelte'
 
 export default defineConfig({
   plugins: [svelte()],
+  // Relative asset URLs so the UI also works when served under --base-path.
+  base: './',
   build: {
     outDir: '../api/dist',
     emptyOutDir: true,